@@ -0,0 +1,364 @@
+// Copyright 2019 Axel Wagner
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package apiversion defines an Analyzer that checks that structs of
+// versioned API packages stay compatible with earlier versions.
+package apiversion
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"path"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"github.com/Merovius/go-tools/internal/analysisutil"
+	"github.com/Merovius/go-tools/internal/nodefilter"
+	"golang.org/x/tools/go/analysis"
+)
+
+const Doc = `check compatibility of structs in versioned API packages
+
+APIs are often versioned by sibling packages, like api/v1 and api/v2, with
+structs of the same name in each. Objects encoded by one version are decoded
+by the other, so its JSON encoding must stay compatible, unless the versions
+are converted explicitly. The analyzer compares the exported structs of a
+package whose last path element is a version (v1, v2beta1, ...) with structs
+of the same name in sibling versions, and reports fields
+  - whose json key changed, so they are dropped when decoding objects of the
+    other version, and
+  - whose json key is the same, but whose type is encoded as a different
+    JSON kind (like a string instead of a number), so objects of the other
+    version fail to decode.
+
+Structs are not compared if the package has a function or method mentioning
+both versions of the struct, as it presumably converts between them. Sibling
+versions are looked up in the dependencies of the package, so only earlier
+versions which are imported, like they are for conversion functions, can be
+taken into account.`
+
+var Analyzer = &analysis.Analyzer{
+	Name: "apiversion",
+	Doc:  Doc,
+	Run:  run,
+	Requires: []*analysis.Analyzer{
+		nodefilter.Analyzer,
+	},
+	FactTypes: []analysis.Fact{new(apiTypes)},
+}
+
+var funcs = nodefilter.New(false, new(ast.FuncDecl))
+
+var versionRegexp = regexp.MustCompile(`^v[0-9]+((alpha|beta)[0-9]+)?$`)
+
+// apiTypes is a package fact describing the exported structs of a versioned
+// package.
+type apiTypes struct {
+	Types []apiType
+}
+
+// An apiType is an exported struct of a versioned package.
+type apiType struct {
+	Name   string
+	Fields []apiField
+}
+
+// An apiField is a field of an apiType, as encoded by encoding/json.
+type apiField struct {
+	Name string
+	Key  string // json key, or "" if it isn't encoded
+	Kind string // the JSON kind of its values, or "" if it can't be told
+}
+
+func (*apiTypes) AFact() {}
+
+func (a *apiTypes) String() string {
+	var names []string
+	for _, t := range a.Types {
+		names = append(names, t.Name)
+	}
+	return "api types " + strings.Join(names, ", ")
+}
+
+func (a *apiTypes) lookup(name string) *apiType {
+	for i := range a.Types {
+		if a.Types[i].Name == name {
+			return &a.Types[i]
+		}
+	}
+	return nil
+}
+
+func run(pass *analysis.Pass) (interface{}, error) {
+	pkgPath := analysisutil.TrimVendor(pass.Pkg.Path())
+	if !versionRegexp.MatchString(path.Base(pkgPath)) {
+		return nil, nil
+	}
+
+	var (
+		own   apiTypes
+		named = make(map[string]*types.Named)
+		pos   = make(map[string]token.Pos) // of fields, by "T.F"
+	)
+	scope := pass.Pkg.Scope()
+	for _, name := range scope.Names() {
+		tn, ok := scope.Lookup(name).(*types.TypeName)
+		if !ok || !tn.Exported() || tn.IsAlias() {
+			continue
+		}
+		n, ok := tn.Type().(*types.Named)
+		if !ok {
+			continue
+		}
+		st, ok := n.Underlying().(*types.Struct)
+		if !ok {
+			continue
+		}
+		t := apiType{Name: name}
+		for _, f := range jsonFields(st, 0) {
+			t.Fields = append(t.Fields, newField(f))
+			if f.Pkg() == pass.Pkg && f.Pos().IsValid() {
+				pos[name+"."+f.Name()] = f.Pos()
+			} else {
+				pos[name+"."+f.Name()] = tn.Pos()
+			}
+		}
+		own.Types = append(own.Types, t)
+		named[name] = n
+	}
+	if len(own.Types) == 0 {
+		return nil, nil
+	}
+	pass.ExportPackageFact(&own)
+
+	conversions := conversionFuncs(pass)
+	for _, f := range pass.AllPackageFacts() {
+		other, ok := f.Fact.(*apiTypes)
+		otherPath := analysisutil.TrimVendor(f.Package.Path())
+		if !ok || f.Package == pass.Pkg || path.Dir(otherPath) != path.Dir(pkgPath) {
+			continue
+		}
+		version, otherVersion := path.Base(pkgPath), path.Base(otherPath)
+		for _, t := range own.Types {
+			ot := other.lookup(t.Name)
+			if ot == nil {
+				continue
+			}
+			if on, ok := f.Package.Scope().Lookup(t.Name).(*types.TypeName); ok && converts(conversions, named[t.Name], on.Type()) {
+				continue
+			}
+			for _, fld := range t.Fields {
+				var msg string
+				if of := ot.byKey(fld.Key); of != nil {
+					if fld.Kind == "" || of.Kind == "" || fld.Kind == of.Kind {
+						continue
+					}
+					field := ""
+					if of.Name != fld.Name {
+						field = " (field " + of.Name + ")"
+					}
+					msg = fmt.Sprintf("json key %q of field %s.%s is %s in %s but %s%s in %s, so objects of one version fail to decode in the other",
+						fld.Key, t.Name, fld.Name, article(fld.Kind), version, article(of.Kind), field, otherVersion)
+				} else if of := ot.byName(fld.Name); of != nil && of.Key != fld.Key {
+					msg = fmt.Sprintf("json key of field %s.%s changed from %s in %s to %s in %s, so it is dropped when decoding objects of the other version",
+						t.Name, fld.Name, quoteKey(of.Key), otherVersion, quoteKey(fld.Key), version)
+				} else {
+					continue
+				}
+				pass.Report(analysis.Diagnostic{
+					Pos:     pos[t.Name+"."+fld.Name],
+					Message: msg + "; convert between " + otherVersion + "." + t.Name + " and " + t.Name + " explicitly",
+				})
+			}
+		}
+	}
+	return nil, nil
+}
+
+func (t *apiType) byKey(key string) *apiField {
+	if key == "" {
+		return nil
+	}
+	for i := range t.Fields {
+		if t.Fields[i].Key == key {
+			return &t.Fields[i]
+		}
+	}
+	return nil
+}
+
+func (t *apiType) byName(name string) *apiField {
+	for i := range t.Fields {
+		if t.Fields[i].Name == name {
+			return &t.Fields[i]
+		}
+	}
+	return nil
+}
+
+func quoteKey(key string) string {
+	if key == "" {
+		return `"-"`
+	}
+	return fmt.Sprintf("%q", key)
+}
+
+func article(kind string) string {
+	switch kind[0] {
+	case 'a', 'e', 'i', 'o', 'u':
+		return "an " + kind
+	}
+	return "a " + kind
+}
+
+// A structField is a field of a struct with its tag.
+type structField struct {
+	*types.Var
+	tag string
+}
+
+// jsonFields returns the fields of st as encoded by encoding/json, with the
+// fields of embedded structs without a json key promoted. Fields which are
+// not encoded are included, so changes of their keys can be reported.
+func jsonFields(st *types.Struct, depth int) []structField {
+	var fields []structField
+	for i := 0; i < st.NumFields(); i++ {
+		f := st.Field(i)
+		tag := reflect.StructTag(st.Tag(i)).Get("json")
+		if f.Anonymous() && tag == "" && depth < 8 {
+			t := f.Type()
+			if p, ok := t.Underlying().(*types.Pointer); ok {
+				t = p.Elem()
+			}
+			if est, ok := t.Underlying().(*types.Struct); ok {
+				fields = append(fields, jsonFields(est, depth+1)...)
+				continue
+			}
+		}
+		if f.Exported() {
+			fields = append(fields, structField{f, tag})
+		}
+	}
+	return fields
+}
+
+func newField(f structField) apiField {
+	fld := apiField{Name: f.Name(), Key: f.Name()}
+	name, opts := f.tag, ""
+	if i := strings.Index(f.tag, ","); i >= 0 {
+		name, opts = f.tag[:i], f.tag[i+1:]
+	}
+	switch {
+	case f.tag == "-":
+		fld.Key = ""
+		return fld
+	case name != "":
+		fld.Key = name
+	}
+	fld.Kind = kind(f.Type(), 0)
+	for _, o := range strings.Split(opts, ",") {
+		if o == "string" && (fld.Kind == "number" || fld.Kind == "integer" || fld.Kind == "boolean") {
+			fld.Kind = "string"
+		}
+	}
+	return fld
+}
+
+// kind returns the JSON kind values of type t are encoded as, or "" if it
+// depends on the value.
+func kind(t types.Type, depth int) string {
+	if hasMethod(t, "MarshalJSON") || hasMethod(t, "MarshalText") {
+		return ""
+	}
+	switch u := t.Underlying().(type) {
+	case *types.Basic:
+		switch {
+		case u.Info()&types.IsBoolean != 0:
+			return "boolean"
+		case u.Info()&types.IsInteger != 0:
+			return "integer"
+		case u.Info()&types.IsFloat != 0:
+			return "number"
+		case u.Info()&types.IsString != 0:
+			return "string"
+		}
+	case *types.Pointer:
+		if depth < 8 {
+			return kind(u.Elem(), depth+1)
+		}
+	case *types.Slice:
+		if b, ok := u.Elem().Underlying().(*types.Basic); ok && b.Kind() == types.Byte {
+			// Encoded as base64.
+			return "string"
+		}
+		return "array"
+	case *types.Array:
+		return "array"
+	case *types.Map, *types.Struct:
+		return "object"
+	}
+	return ""
+}
+
+func hasMethod(t types.Type, name string) bool {
+	if _, ok := t.Underlying().(*types.Pointer); !ok {
+		t = types.NewPointer(t)
+	}
+	return types.NewMethodSet(t).Lookup(nil, name) != nil
+}
+
+// conversionFuncs returns the signatures of the functions and methods
+// declared in the package.
+func conversionFuncs(pass *analysis.Pass) []*types.Signature {
+	var sigs []*types.Signature
+	nodes := pass.ResultOf[nodefilter.Analyzer].(*nodefilter.Result)
+	for _, n := range nodes.Nodes(funcs) {
+		fd := n.Node.(*ast.FuncDecl)
+		if fn, ok := pass.TypesInfo.Defs[fd.Name].(*types.Func); ok {
+			sigs = append(sigs, fn.Type().(*types.Signature))
+		}
+	}
+	return sigs
+}
+
+// converts returns whether one of sigs mentions both a and b in its
+// receiver, parameters or results.
+func converts(sigs []*types.Signature, a, b types.Type) bool {
+	for _, sig := range sigs {
+		var hasA, hasB bool
+		check := func(v *types.Var) {
+			t := v.Type()
+			if p, ok := t.(*types.Pointer); ok {
+				t = p.Elem()
+			}
+			hasA = hasA || types.Identical(t, a)
+			hasB = hasB || types.Identical(t, b)
+		}
+		if sig.Recv() != nil {
+			check(sig.Recv())
+		}
+		for _, tup := range []*types.Tuple{sig.Params(), sig.Results()} {
+			for i := 0; i < tup.Len(); i++ {
+				check(tup.At(i))
+			}
+		}
+		if hasA && hasB {
+			return true
+		}
+	}
+	return false
+}
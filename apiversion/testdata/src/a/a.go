@@ -0,0 +1,11 @@
+package a
+
+import (
+	v1 "example.com/api/v1"
+)
+
+type Deployment struct {
+	Replicas string `json:"replicas"`
+}
+
+var _ v1.Deployment
@@ -0,0 +1,29 @@
+package v1 // want package:"api types Deployment, Meta, Service, Status"
+
+import "time"
+
+type Meta struct {
+	Name string `json:"name"`
+}
+
+type Deployment struct {
+	Meta
+	Replicas int               `json:"replicas"`
+	Image    string            `json:"image"`
+	Labels   map[string]string `json:"labels,omitempty"`
+	Paused   bool              `json:"paused"`
+	Created  time.Time         `json:"created"`
+	Internal string            `json:"-"`
+}
+
+type Service struct {
+	Port int `json:"port"`
+}
+
+type Status struct {
+	Ready int `json:"ready"`
+}
+
+type unexported struct {
+	X int
+}
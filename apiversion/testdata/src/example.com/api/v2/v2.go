@@ -0,0 +1,34 @@
+package v2 // want package:"api types Deployment, Meta, Service, Status"
+
+import (
+	"time"
+
+	v1 "example.com/api/v1"
+)
+
+type Meta struct {
+	Name string `json:"name"`
+}
+
+type Deployment struct {
+	Meta
+	Replicas string            `json:"replicas"`       // want `json key "replicas" of field Deployment.Replicas is a string in v2 but an integer in v1, so objects of one version fail to decode in the other; convert between v1.Deployment and Deployment explicitly`
+	Image    string            `json:"containerImage"` // want `json key of field Deployment.Image changed from "image" in v1 to "containerImage" in v2, so it is dropped when decoding objects of the other version`
+	Labels   map[string]string `json:"labels"`
+	Paused   bool              `json:"paused,string"` // want `json key "paused" of field Deployment.Paused is a string in v2 but a boolean in v1`
+	Created  *time.Time        `json:"created"`
+	Internal string            `json:"internal"` // want `json key of field Deployment.Internal changed from "-" in v1 to "internal" in v2`
+}
+
+type Service struct {
+	Port string `json:"port"`
+}
+
+// ServiceFromV1 converts a v1.Service.
+func ServiceFromV1(s *v1.Service) Service {
+	return Service{}
+}
+
+type Status struct {
+	Ready float64 `json:"ready"` // want `json key "ready" of field Status.Ready is a number in v2 but an integer in v1`
+}
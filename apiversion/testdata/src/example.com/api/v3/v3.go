@@ -0,0 +1,11 @@
+package v3 // want package:"api types Deployment"
+
+import v2 "example.com/api/v2"
+
+type Deployment struct {
+	Name     string   `json:"name"`
+	Replicas int      `json:"replicas"`       // want `json key "replicas" of field Deployment.Replicas is an integer in v3 but a string in v2`
+	Images   []string `json:"containerImage"` // want `json key "containerImage" of field Deployment.Images is an array in v3 but a string \(field Image\) in v2`
+}
+
+func (d *Deployment) ConvertFrom(v2.Service) {}
@@ -0,0 +1,175 @@
+// Copyright 2019 Axel Wagner
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package appendexpand defines an Analyzer that checks for slices appended
+// as single elements and appends overwriting the slice they read from.
+package appendexpand
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+
+	"github.com/Merovius/go-tools/internal/analysisutil"
+	"github.com/Merovius/go-tools/internal/fix"
+	"github.com/Merovius/go-tools/internal/nodefilter"
+	"golang.org/x/tools/go/analysis"
+)
+
+const Doc = `check for slices appended as single elements and appends overwriting their source
+
+The analyzer reports
+  - calls like append(dst, src), where src is a slice and the elements of
+    dst are empty interfaces. They compile, as the slice is an interface value,
+    but append it as a single element, where appending its elements with
+    src... was most likely intended. Slice literals, which are clearly
+    meant as one element, are not reported. If src has the type of dst,
+    the analyzer suggests adding the ..., and
+  - calls like t := append(s[:i], s[j:]...), which remove elements of s by
+    moving later ones down in the backing array of s, but assign the result
+    to a variable other than s, while s is used afterwards. The elements of
+    s are overwritten too, which the use of a separate variable suggests
+    wasn't intended. Copy s first, or assign the result to s.`
+
+var Analyzer = &analysis.Analyzer{
+	Name: "appendexpand",
+	Doc:  Doc,
+	Run:  run,
+	Requires: []*analysis.Analyzer{
+		nodefilter.Analyzer,
+	},
+}
+
+func init() {
+	// Expanding the slice changes what is appended.
+	fix.Register(Analyzer, fix.Unverified)
+}
+
+var calls = nodefilter.New(true, new(ast.CallExpr))
+
+func run(pass *analysis.Pass) (interface{}, error) {
+	nodes := pass.ResultOf[nodefilter.Analyzer].(*nodefilter.Result)
+	for _, n := range nodes.Nodes(calls) {
+		call := n.Node.(*ast.CallExpr)
+		if !isAppend(pass, call) || len(call.Args) != 2 {
+			continue
+		}
+		if call.Ellipsis.IsValid() {
+			checkOverwrite(pass, call, n.Stack)
+		} else {
+			checkElement(pass, call)
+		}
+	}
+	return nil, nil
+}
+
+func isAppend(pass *analysis.Pass, call *ast.CallExpr) bool {
+	id, ok := analysisutil.Unparen(call.Fun).(*ast.Ident)
+	if !ok {
+		return false
+	}
+	b, ok := pass.TypesInfo.Uses[id].(*types.Builtin)
+	return ok && b.Name() == "append"
+}
+
+// checkElement reports append(dst, src) appending the slice src as an
+// element of empty interface type.
+func checkElement(pass *analysis.Pass, call *ast.CallExpr) {
+	dst, ok := pass.TypesInfo.TypeOf(call.Args[0]).Underlying().(*types.Slice)
+	if !ok {
+		return
+	}
+	// Slices implementing non-empty interfaces have methods, so they are
+	// meant to be used as values of the interface.
+	if it, ok := dst.Elem().Underlying().(*types.Interface); !ok || it.NumMethods() > 0 {
+		return
+	}
+	src := call.Args[1]
+	if _, ok := pass.TypesInfo.TypeOf(src).Underlying().(*types.Slice); !ok || analysisutil.IsConst(pass.TypesInfo, src) {
+		return
+	}
+	if _, ok := analysisutil.Unparen(src).(*ast.CompositeLit); ok {
+		// A literal is clearly meant as a single element.
+		return
+	}
+	d := analysis.Diagnostic{
+		Pos:     src.Pos(),
+		End:     src.End(),
+		Message: "append adds the slice " + analysisutil.Render(pass.Fset, src) + " as a single element; write " + analysisutil.Render(pass.Fset, src) + "... to append its elements",
+	}
+	if types.Identical(pass.TypesInfo.TypeOf(src), pass.TypesInfo.TypeOf(call.Args[0])) {
+		d.SuggestedFixes = []analysis.SuggestedFix{{
+			Message: "append the elements",
+			TextEdits: []analysis.TextEdit{{
+				Pos:     src.End(),
+				End:     src.End(),
+				NewText: []byte("..."),
+			}},
+		}}
+	}
+	pass.Report(d)
+}
+
+// checkOverwrite reports t := append(s[:i], s[j:]...), if t is not s and s
+// is used later.
+func checkOverwrite(pass *analysis.Pass, call *ast.CallExpr, stack []ast.Node) {
+	se, ok := analysisutil.Unparen(call.Args[0]).(*ast.SliceExpr)
+	if !ok || se.High == nil {
+		return
+	}
+	s, ok := analysisutil.ObjectOf(pass.TypesInfo, se.X).(*types.Var)
+	if !ok || sliceOf(pass, call.Args[1]) != s {
+		return
+	}
+	as, ok := stack[len(stack)-2].(*ast.AssignStmt)
+	if !ok || len(as.Lhs) != len(as.Rhs) {
+		return
+	}
+	var lhs ast.Expr
+	for i, r := range as.Rhs {
+		if r == ast.Expr(call) {
+			lhs = as.Lhs[i]
+		}
+	}
+	t, ok := analysisutil.ObjectOf(pass.TypesInfo, lhs).(*types.Var)
+	if !ok || t == s {
+		return
+	}
+	_, body := analysisutil.EnclosingFunc(stack)
+	if body == nil || !usedAfter(pass, body, s, as.End()) {
+		return
+	}
+	pass.Reportf(call.Pos(), "append overwrites the elements of %s in place, but the result is assigned to %s and %s is used afterwards; copy %s first or assign the result to it", s.Name(), t.Name(), s.Name(), s.Name())
+}
+
+// sliceOf returns the variable e is or slices, or nil.
+func sliceOf(pass *analysis.Pass, e ast.Expr) types.Object {
+	e = analysisutil.Unparen(e)
+	if se, ok := e.(*ast.SliceExpr); ok {
+		e = se.X
+	}
+	return analysisutil.ObjectOf(pass.TypesInfo, e)
+}
+
+// usedAfter returns whether v is used in body after pos.
+func usedAfter(pass *analysis.Pass, body *ast.BlockStmt, v *types.Var, pos token.Pos) bool {
+	used := false
+	ast.Inspect(body, func(n ast.Node) bool {
+		if id, ok := n.(*ast.Ident); ok && id.Pos() > pos && pass.TypesInfo.Uses[id] == v {
+			used = true
+		}
+		return !used
+	})
+	return used
+}
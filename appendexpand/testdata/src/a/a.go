@@ -0,0 +1,53 @@
+package a
+
+import "fmt"
+
+type Stringer interface{ String() string }
+
+type list []string
+
+func (list) String() string { return "" }
+
+type empty = interface{}
+
+func elements(args []interface{}, more []interface{}, names []string) {
+	args = append(args, more)                        // want `append adds the slice more as a single element; write more... to append its elements`
+	args = append(args, names)                       // want `append adds the slice names as a single element`
+	args = append(args, more[1:])                    // want `append adds the slice more\[1:\] as a single element`
+	fmt.Println(append([]interface{}{"x"}, args)...) // want `append adds the slice args as a single element`
+
+	var as []empty
+	var bs []empty
+	_ = append(as, bs) // want `append adds the slice bs as a single element`
+
+	args = append(args, more...)
+	_ = append([]Stringer{}, list{})
+	var l list
+	_ = append([]Stringer{}, l)
+	args = append(args, []interface{}{1, 2})
+	args = append(args, nil)
+	args = append(args, more, names)
+	_ = append(names, "x")
+	_ = append([][]string{}, names)
+	fmt.Println(args...)
+}
+
+func overwrite(s []int, i int) {
+	t := append(s[:i], s[i+1:]...) // want `append overwrites the elements of s in place, but the result is assigned to t and s is used afterwards; copy s first or assign the result to it`
+	fmt.Println(s, t)
+
+	var u []int
+	u = append(s[:1], s[2:]...) // want `append overwrites the elements of s in place`
+	fmt.Println(u, len(s))
+
+	s = append(s[:i], s[i+1:]...)
+	v := append(s[:i], s[i+1:]...) // s not used afterwards
+	fmt.Println(v)
+}
+
+func notAliased(s, r []int) []int {
+	t := append(s[:1], r...)
+	w := append(s, s...)
+	fmt.Println(s, t, w)
+	return append(s[:1], s[2:]...)
+}
@@ -0,0 +1,309 @@
+// Copyright 2019 Axel Wagner
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package assertmisuse defines an Analyzer that checks for misuse of the
+// assertion packages of github.com/stretchr/testify.
+package assertmisuse
+
+import (
+	"go/ast"
+	"go/types"
+	"strings"
+
+	"github.com/Merovius/go-tools/internal/analysisutil"
+	"github.com/Merovius/go-tools/internal/fix"
+	"github.com/Merovius/go-tools/internal/nodefilter"
+	"golang.org/x/tools/go/analysis"
+)
+
+const Doc = `check for misuse of testify assertions
+
+The analyzer reports
+  - equality assertions with the expected and actual value swapped, that is
+    with a constant as the actual value,
+  - require assertions in goroutines, which call t.FailNow from a goroutine
+    other than the one running the test,
+  - values dereferenced after assert.Error, which doesn't stop the test if the
+    error is nil, so the value is usually nil as well, and
+  - equality assertions of floating-point numbers, which should allow for
+    rounding errors with InDelta or InEpsilon.`
+
+var Analyzer = &analysis.Analyzer{
+	Name: "assertmisuse",
+	Doc:  Doc,
+	Run:  run,
+	Requires: []*analysis.Analyzer{
+		nodefilter.Analyzer,
+	},
+}
+
+func init() {
+	fix.Register(Analyzer, fix.Safe)
+}
+
+const (
+	assertPkg  = "github.com/stretchr/testify/assert"
+	requirePkg = "github.com/stretchr/testify/require"
+)
+
+var (
+	calls  = nodefilter.New(false, new(ast.CallExpr))
+	gos    = nodefilter.New(false, new(ast.GoStmt))
+	blocks = nodefilter.New(false, new(ast.BlockStmt), new(ast.CaseClause), new(ast.CommClause))
+)
+
+// An assertion is a call of a testify assertion.
+type assertion struct {
+	call *ast.CallExpr
+	pkg  string // "assert" or "require"
+	name string // without the "f" suffix of formatting variants
+	args []ast.Expr
+}
+
+// asAssertion returns the testify assertion called by call, if any. Both
+// package-level functions and methods of Assertions are recognized; args
+// doesn't include the TestingT argument of the former.
+func asAssertion(info *types.Info, call *ast.CallExpr) (assertion, bool) {
+	fn := analysisutil.Callee(info, call)
+	if fn == nil || fn.Pkg() == nil {
+		return assertion{}, false
+	}
+	path := analysisutil.TrimVendor(fn.Pkg().Path())
+	if path != assertPkg && path != requirePkg {
+		return assertion{}, false
+	}
+	a := assertion{
+		call: call,
+		pkg:  path[strings.LastIndexByte(path, '/')+1:],
+		name: fn.Name(),
+		args: call.Args,
+	}
+	if fn.Type().(*types.Signature).Recv() == nil {
+		if len(a.args) == 0 {
+			return assertion{}, false
+		}
+		a.args = a.args[1:]
+	}
+	a.name = strings.TrimSuffix(a.name, "f")
+	return a, true
+}
+
+func (a assertion) String() string {
+	return a.pkg + "." + a.name
+}
+
+// equality lists the assertions comparing an expected and an actual value.
+var equality = map[string]bool{
+	"Equal":          true,
+	"NotEqual":       true,
+	"Exactly":        true,
+	"EqualValues":    true,
+	"NotEqualValues": true,
+}
+
+func run(pass *analysis.Pass) (interface{}, error) {
+	if !analysisutil.Imports(pass.Pkg, assertPkg) && !analysisutil.Imports(pass.Pkg, requirePkg) {
+		return nil, nil
+	}
+	nodes := pass.ResultOf[nodefilter.Analyzer].(*nodefilter.Result)
+	for _, n := range nodes.Nodes(calls) {
+		a, ok := asAssertion(pass.TypesInfo, n.Node.(*ast.CallExpr))
+		if !ok || !equality[a.name] || len(a.args) < 2 {
+			continue
+		}
+		checkOrder(pass, a)
+		checkFloat(pass, a)
+	}
+	for _, n := range nodes.Nodes(gos) {
+		checkGoroutine(pass, n.Node.(*ast.GoStmt))
+	}
+	for _, n := range nodes.Nodes(blocks) {
+		switch n := n.Node.(type) {
+		case *ast.BlockStmt:
+			checkDeref(pass, n.List)
+		case *ast.CaseClause:
+			checkDeref(pass, n.Body)
+		case *ast.CommClause:
+			checkDeref(pass, n.Body)
+		}
+	}
+	return nil, nil
+}
+
+func checkOrder(pass *analysis.Pass, a assertion) {
+	expected, actual := a.args[0], a.args[1]
+	if !analysisutil.IsConst(pass.TypesInfo, actual) || analysisutil.IsConst(pass.TypesInfo, expected) {
+		return
+	}
+	pass.Report(analysis.Diagnostic{
+		Pos:     a.call.Pos(),
+		End:     a.call.End(),
+		Message: "arguments of " + a.String() + " are swapped: the expected value comes first",
+		SuggestedFixes: []analysis.SuggestedFix{{
+			Message: "swap expected and actual value",
+			TextEdits: []analysis.TextEdit{
+				{Pos: expected.Pos(), End: expected.End(), NewText: []byte(analysisutil.Render(pass.Fset, actual))},
+				{Pos: actual.Pos(), End: actual.End(), NewText: []byte(analysisutil.Render(pass.Fset, expected))},
+			},
+		}},
+	})
+}
+
+func checkFloat(pass *analysis.Pass, a assertion) {
+	if a.name == "Exactly" {
+		// Exactly is for comparing values exactly, including types.
+		return
+	}
+	for _, e := range a.args[:2] {
+		if analysisutil.IsConst(pass.TypesInfo, e) {
+			continue
+		}
+		if b, ok := pass.TypesInfo.TypeOf(e).Underlying().(*types.Basic); ok && b.Info()&types.IsFloat != 0 {
+			pass.Report(analysis.Diagnostic{
+				Pos:      a.call.Pos(),
+				End:      a.call.End(),
+				Category: "float",
+				Message:  a.String() + " compares floating-point numbers exactly; use InDelta or InEpsilon",
+			})
+			return
+		}
+	}
+}
+
+func checkGoroutine(pass *analysis.Pass, g *ast.GoStmt) {
+	lit, ok := analysisutil.Unparen(g.Call.Fun).(*ast.FuncLit)
+	if !ok {
+		return
+	}
+	ast.Inspect(lit.Body, func(n ast.Node) bool {
+		call, ok := n.(*ast.CallExpr)
+		if !ok {
+			return true
+		}
+		if a, ok := asAssertion(pass.TypesInfo, call); ok && a.pkg == "require" {
+			pass.Report(analysis.Diagnostic{
+				Pos:      call.Pos(),
+				End:      call.End(),
+				Category: "goroutine",
+				Message:  a.String() + " in a goroutine calls t.FailNow, which must be called from the test goroutine; use assert",
+			})
+		}
+		return true
+	})
+}
+
+// checkDeref reports uses of values dereferenced after an assert.Error
+// checking the error returned alongside them.
+func checkDeref(pass *analysis.Pass, stmts []ast.Stmt) {
+	for i, s := range stmts {
+		vals, err := assignedWithError(pass.TypesInfo, s)
+		if err == nil || i+1 >= len(stmts) {
+			continue
+		}
+		es, ok := stmts[i+1].(*ast.ExprStmt)
+		if !ok {
+			continue
+		}
+		call, ok := es.X.(*ast.CallExpr)
+		if !ok {
+			continue
+		}
+		a, ok := asAssertion(pass.TypesInfo, call)
+		if !ok || a.pkg != "assert" || a.name != "Error" || len(a.args) == 0 || analysisutil.ObjectOf(pass.TypesInfo, a.args[0]) != err {
+			continue
+		}
+		if d := firstDeref(pass.TypesInfo, stmts[i+2:], vals); d != nil {
+			pass.Report(analysis.Diagnostic{
+				Pos:      d.Pos(),
+				End:      d.End(),
+				Category: "errderef",
+				Message:  "value is dereferenced after assert.Error, which doesn't stop the test if there is no error; use require.Error",
+			})
+		}
+	}
+}
+
+// assignedWithError returns the pointer variables and the error variable
+// assigned by s, if it assigns the results of a call returning an error as
+// last result.
+func assignedWithError(info *types.Info, s ast.Stmt) (vals map[types.Object]bool, err types.Object) {
+	as, ok := s.(*ast.AssignStmt)
+	if !ok || len(as.Lhs) < 2 || len(as.Rhs) != 1 {
+		return nil, nil
+	}
+	if _, ok := as.Rhs[0].(*ast.CallExpr); !ok {
+		return nil, nil
+	}
+	err = analysisutil.ObjectOf(info, as.Lhs[len(as.Lhs)-1])
+	if err == nil || !types.Identical(err.Type(), types.Universe.Lookup("error").Type()) {
+		return nil, nil
+	}
+	vals = make(map[types.Object]bool)
+	for _, l := range as.Lhs[:len(as.Lhs)-1] {
+		if obj := analysisutil.ObjectOf(info, l); obj != nil {
+			if _, ok := obj.Type().Underlying().(*types.Pointer); ok {
+				vals[obj] = true
+			}
+		}
+	}
+	if len(vals) == 0 {
+		return nil, nil
+	}
+	return vals, err
+}
+
+// firstDeref returns the first dereference of one of vals in stmts, before
+// any of them is assigned again or the block is left.
+func firstDeref(info *types.Info, stmts []ast.Stmt, vals map[types.Object]bool) ast.Node {
+	var found ast.Node
+	for _, s := range stmts {
+		if _, ok := s.(*ast.IfStmt); ok {
+			// The value might be checked in the condition.
+			break
+		}
+		stop := false
+		ast.Inspect(s, func(n ast.Node) bool {
+			if found != nil || stop {
+				return false
+			}
+			switch n := n.(type) {
+			case *ast.FuncLit:
+				return false
+			case *ast.ReturnStmt:
+				stop = true
+			case *ast.AssignStmt:
+				for _, l := range n.Lhs {
+					if vals[analysisutil.ObjectOf(info, l)] {
+						stop = true
+					}
+				}
+			case *ast.StarExpr:
+				if vals[analysisutil.ObjectOf(info, n.X)] {
+					found = n
+				}
+			case *ast.SelectorExpr:
+				if vals[analysisutil.ObjectOf(info, n.X)] {
+					if sel, ok := info.Selections[n]; ok && sel.Kind() == types.FieldVal {
+						found = n
+					}
+				}
+			}
+			return true
+		})
+		if found != nil || stop {
+			break
+		}
+	}
+	return found
+}
@@ -0,0 +1,83 @@
+// Copyright 2019 Axel Wagner
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package a
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type T struct{ X int }
+
+func get() (*T, error) { return nil, nil }
+
+func compute() int { return 42 }
+
+func TestOrder(t *testing.T) {
+	got := compute()
+	assert.Equal(t, got, 42)             // want `arguments of assert.Equal are swapped: the expected value comes first`
+	assert.Equalf(t, got, 42, "%d", got) // want `arguments of assert.Equal are swapped`
+	require.Equal(t, got, "x")           // want `arguments of require.Equal are swapped`
+	assert.New(t).Equal(got, 42)         // want `arguments of assert.Equal are swapped`
+	assert.Equal(t, 42, got)
+	assert.Equal(t, 1, 1)
+	var err error
+	assert.Equal(t, err, nil) // want `arguments of assert.Equal are swapped`
+}
+
+func TestFloat(t *testing.T) {
+	f := 0.1 + 0.2
+	assert.Equal(t, 0.3, f)    // want `assert.Equal compares floating-point numbers exactly; use InDelta or InEpsilon`
+	assert.NotEqual(t, 0.3, f) // want `assert.NotEqual compares floating-point numbers exactly`
+	assert.Exactly(t, 0.3, f)
+	assert.InDelta(t, 0.3, f, 1e-9)
+	assert.Equal(t, 0.5, 0.5)
+}
+
+func TestGoroutine(t *testing.T) {
+	r := require.New(t)
+	done := make(chan bool)
+	go func() {
+		require.NoError(t, nil) // want `require.NoError in a goroutine calls t.FailNow, which must be called from the test goroutine; use assert`
+		r.NoError(nil)          // want `require.NoError in a goroutine`
+		assert.NoError(t, nil)
+		done <- true
+	}()
+	require.NoError(t, nil)
+	<-done
+}
+
+func TestDeref(t *testing.T) {
+	v, err := get()
+	assert.Error(t, err)
+	_ = v.X // want `value is dereferenced after assert.Error, which doesn't stop the test if there is no error; use require.Error`
+
+	w, err := get()
+	require.Error(t, err)
+	_ = w.X
+
+	u, err := get()
+	assert.Error(t, err)
+	if u != nil {
+		_ = u.X
+	}
+
+	x, err := get()
+	assert.Error(t, err)
+	x = &T{}
+	_ = *x
+}
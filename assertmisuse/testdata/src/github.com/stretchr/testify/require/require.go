@@ -0,0 +1,31 @@
+// Copyright 2019 Axel Wagner
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package require is a stub of github.com/stretchr/testify/require.
+package require
+
+type TestingT interface {
+	Errorf(format string, args ...interface{})
+	FailNow()
+}
+
+func Equal(t TestingT, expected, actual interface{}, msgAndArgs ...interface{}) {}
+func NoError(t TestingT, err error, msgAndArgs ...interface{})                  {}
+func Error(t TestingT, err error, msgAndArgs ...interface{})                    {}
+
+type Assertions struct{ t TestingT }
+
+func New(t TestingT) *Assertions { return &Assertions{t} }
+
+func (a *Assertions) NoError(err error, msgAndArgs ...interface{}) {}
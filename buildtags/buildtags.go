@@ -0,0 +1,542 @@
+// Copyright 2019 Axel Wagner
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package buildtags defines an Analyzer that checks the build constraints
+// of files against the build configurations the code is built in.
+package buildtags
+
+import (
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"golang.org/x/tools/go/analysis"
+)
+
+const Doc = `check for files which are never built and build tags which are never set
+
+The analyzer reads the build constraints of all Go files in the directory of
+a package, including the ones not built in the current configuration, and
+reports
+  - files whose build constraints, together with the GOOS and GOARCH
+    suffixes of their name, can't be satisfied by any of the targets, like
+    "linux && windows" or a file named x_windows.go constrained to linux,
+    and
+  - build tags used in constraints which none of the targets sets (category
+    "tags").
+
+The targets are given by the -targets flag, which gotools sets to the
+targets of its configuration. Without targets, files are checked against
+all ports, with any tags, and tags are not checked. Tags set by the
+toolchain, like cgo, race or go1.N, can always be set. Files constrained by
+the ignore or tools tags are excluded on purpose and not checked.`
+
+var Analyzer = &analysis.Analyzer{
+	Name: "buildtags",
+	Doc:  Doc,
+	Run:  run,
+}
+
+var targetList string
+
+func init() {
+	Analyzer.Flags.StringVar(&targetList, "targets", "", "space-separated build configurations goos/goarch[:tags] the code is built in; empty means all ports with any tags")
+}
+
+// ports lists the valid GOOS/GOARCH combinations, as printed by
+// "go tool dist list".
+var ports = strings.Fields(`aix/ppc64 android/386 android/amd64 android/arm
+android/arm64 darwin/amd64 darwin/arm64 dragonfly/amd64 freebsd/386
+freebsd/amd64 freebsd/arm freebsd/arm64 freebsd/riscv64 illumos/amd64
+ios/amd64 ios/arm64 js/wasm linux/386 linux/amd64 linux/arm linux/arm64
+linux/loong64 linux/mips linux/mips64 linux/mips64le linux/mipsle linux/ppc64
+linux/ppc64le linux/riscv64 linux/s390x netbsd/386 netbsd/amd64 netbsd/arm
+netbsd/arm64 openbsd/386 openbsd/amd64 openbsd/arm openbsd/arm64
+openbsd/ppc64 openbsd/riscv64 plan9/386 plan9/amd64 plan9/arm solaris/amd64
+wasip1/wasm windows/386 windows/amd64 windows/arm windows/arm64`)
+
+var (
+	knownOS = words(`aix android darwin dragonfly freebsd hurd illumos ios js
+linux nacl netbsd openbsd plan9 solaris wasip1 windows zos`)
+	knownArch = words(`386 amd64 amd64p32 arm armbe arm64 arm64be loong64 mips
+mipsle mips64 mips64le mips64p32 mips64p32le ppc ppc64 ppc64le riscv riscv64
+s390 s390x sparc sparc64 wasm`)
+	unixOS = words(`aix android darwin dragonfly freebsd hurd illumos ios linux
+netbsd openbsd solaris`)
+
+	// toolchain lists tags set by the go command or the toolchain, which
+	// are not part of targets.
+	toolchain = words(`cgo gc gccgo race msan asan boringcrypto`)
+
+	// excluded lists tags used to exclude files from all builds on
+	// purpose.
+	excluded = words(`ignore tools`)
+
+	releaseTag = regexp.MustCompile(`^go1\.[0-9]+$`)
+)
+
+func words(s string) map[string]bool {
+	m := make(map[string]bool)
+	for _, w := range strings.Fields(s) {
+		m[w] = true
+	}
+	return m
+}
+
+// A target is a build configuration.
+type target struct {
+	goos, goarch string
+	tags         map[string]bool
+}
+
+func parseTargets(s string) ([]target, error) {
+	var ts []target
+	for _, f := range strings.Fields(s) {
+		t := target{tags: make(map[string]bool)}
+		if i := strings.IndexByte(f, ':'); i >= 0 {
+			for _, tag := range strings.Split(f[i+1:], ",") {
+				if tag != "" {
+					t.tags[tag] = true
+				}
+			}
+			f = f[:i]
+		}
+		i := strings.IndexByte(f, '/')
+		if i < 0 || !knownOS[f[:i]] || !knownArch[f[i+1:]] {
+			return nil, fmt.Errorf("invalid target %q: want goos/goarch[:tags]", f)
+		}
+		t.goos, t.goarch = f[:i], f[i+1:]
+		ts = append(ts, t)
+	}
+	return ts, nil
+}
+
+// fixed returns the value of tag in t, if t determines it.
+func (t target) fixed(tag string) (value, ok bool) {
+	switch {
+	case knownOS[tag]:
+		return tag == t.goos ||
+			tag == "linux" && t.goos == "android" ||
+			tag == "solaris" && t.goos == "illumos" ||
+			tag == "darwin" && t.goos == "ios", true
+	case knownArch[tag]:
+		return tag == t.goarch, true
+	case tag == "unix":
+		return unixOS[t.goos], true
+	case t.tags[tag]:
+		return true, true
+	}
+	return false, false
+}
+
+func run(pass *analysis.Pass) (interface{}, error) {
+	if len(pass.Files) == 0 {
+		return nil, nil
+	}
+	targets, err := parseTargets(targetList)
+	if err != nil {
+		return nil, err
+	}
+	matrix := len(targets) > 0
+	if !matrix {
+		for _, p := range ports {
+			i := strings.IndexByte(p, '/')
+			targets = append(targets, target{goos: p[:i], goarch: p[i+1:]})
+		}
+	}
+	set := make(map[string]bool)
+	for _, t := range targets {
+		for tag := range t.tags {
+			set[tag] = true
+		}
+	}
+
+	parsed := make(map[string]*ast.File)
+	for _, f := range pass.Files {
+		parsed[pass.Fset.File(f.Pos()).Name()] = f
+	}
+	dir := filepath.Dir(pass.Fset.File(pass.Files[0].Pos()).Name())
+	fis, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, fi := range fis {
+		name := fi.Name()
+		if !strings.HasSuffix(name, ".go") || strings.HasPrefix(name, "_") || strings.HasPrefix(name, ".") {
+			continue
+		}
+		path := filepath.Join(dir, name)
+		f := parsed[path]
+		if f == nil {
+			if f, err = parser.ParseFile(pass.Fset, path, nil, parser.PackageClauseOnly|parser.ParseComments); err != nil {
+				continue
+			}
+		}
+		checkFile(pass, f, name, targets, matrix, set)
+	}
+	return nil, nil
+}
+
+func checkFile(pass *analysis.Pass, f *ast.File, name string, targets []target, matrix bool, set map[string]bool) {
+	c, text, err := fileConstraint(f)
+	if err != nil {
+		// The go command reports malformed constraints.
+		return
+	}
+	if c != nil && hasTag(c, func(tag string) bool { return excluded[tag] }) {
+		return
+	}
+	var desc []string
+	if text != "" {
+		desc = append(desc, "build constraint "+text)
+	}
+	if n, suffix := nameConstraint(name); n != nil {
+		desc = append(desc, "file name suffix "+suffix)
+		if c == nil {
+			c = n
+		} else {
+			c = &andExpr{c, n}
+		}
+	}
+	if c == nil {
+		return
+	}
+
+	if !satisfiable(c, targets) {
+		where := "on any port"
+		if matrix {
+			where = "by any of the configured targets"
+		}
+		pass.Reportf(f.Package, "%s is never built: its %s can't be satisfied %s", name, strings.Join(desc, " and "), where)
+		return
+	}
+	if !matrix {
+		return
+	}
+	var unset []string
+	tags(c, func(tag string) {
+		if !set[tag] && !builtin(tag) {
+			unset = append(unset, tag)
+		}
+	})
+	sort.Strings(unset)
+	for i, tag := range unset {
+		if i > 0 && unset[i-1] == tag {
+			continue
+		}
+		pass.Report(analysis.Diagnostic{
+			Pos:      f.Package,
+			Category: "tags",
+			Message:  fmt.Sprintf("build tag %s is not set by any of the configured targets; add a target setting it or remove it from the constraint", tag),
+		})
+	}
+}
+
+// builtin returns whether tag is set by the build system, depending on
+// the configuration.
+func builtin(tag string) bool {
+	return knownOS[tag] || knownArch[tag] || tag == "unix" || toolchain[tag] || releaseTag.MatchString(tag) || strings.HasPrefix(tag, "goexperiment.")
+}
+
+// satisfiable returns whether c is true in any of targets, for some values
+// of the tags not determined by the target.
+func satisfiable(c expr, targets []target) bool {
+	for _, t := range targets {
+		var free []string
+		seen := make(map[string]bool)
+		tags(c, func(tag string) {
+			if _, ok := t.fixed(tag); !ok && !seen[tag] {
+				seen[tag] = true
+				free = append(free, tag)
+			}
+		})
+		if len(free) > 12 {
+			// Too many combinations to try, so give it the benefit of
+			// the doubt.
+			return true
+		}
+		for bits := 0; bits < 1<<uint(len(free)); bits++ {
+			ok := c.eval(func(tag string) bool {
+				if v, ok := t.fixed(tag); ok {
+					return v
+				}
+				for i, f := range free {
+					if f == tag {
+						return bits&(1<<uint(i)) != 0
+					}
+				}
+				return false
+			})
+			if ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// nameConstraint returns the constraint implied by the _GOOS, _GOARCH or
+// _GOOS_GOARCH suffix of the file name, and the suffix.
+func nameConstraint(name string) (expr, string) {
+	name = strings.TrimSuffix(strings.TrimSuffix(name, ".go"), "_test")
+	parts := strings.Split(name, "_")[1:]
+	n := len(parts)
+	switch {
+	case n >= 2 && knownOS[parts[n-2]] && knownArch[parts[n-1]]:
+		return &andExpr{&tagExpr{parts[n-2]}, &tagExpr{parts[n-1]}}, "_" + parts[n-2] + "_" + parts[n-1]
+	case n >= 1 && (knownOS[parts[n-1]] || knownArch[parts[n-1]]):
+		return &tagExpr{parts[n-1]}, "_" + parts[n-1]
+	}
+	return nil, ""
+}
+
+// fileConstraint returns the build constraint of f and its text. A
+// //go:build line takes precedence over // +build lines.
+func fileConstraint(f *ast.File) (expr, string, error) {
+	var (
+		plus  expr
+		texts []string
+	)
+	for _, g := range f.Comments {
+		if g.Pos() >= f.Package || g == f.Doc {
+			break
+		}
+		for _, c := range g.List {
+			if strings.HasPrefix(c.Text, "//go:build ") {
+				text := strings.TrimSpace(strings.TrimPrefix(c.Text, "//go:build "))
+				x, err := parseExpr(text)
+				return x, text, err
+			}
+			line := strings.TrimSpace(strings.TrimPrefix(c.Text, "//"))
+			if !strings.HasPrefix(line, "+build ") {
+				continue
+			}
+			text := strings.TrimSpace(strings.TrimPrefix(line, "+build "))
+			x, err := parsePlusBuild(text)
+			if err != nil {
+				return nil, "", err
+			}
+			if plus == nil {
+				plus = x
+			} else {
+				plus = &andExpr{plus, x}
+			}
+			texts = append(texts, text)
+		}
+	}
+	return plus, strings.Join(texts, "; "), nil
+}
+
+// An expr is a build constraint expression.
+type expr interface {
+	eval(tag func(string) bool) bool
+}
+
+type (
+	tagExpr struct{ tag string }
+	notExpr struct{ x expr }
+	andExpr struct{ x, y expr }
+	orExpr  struct{ x, y expr }
+)
+
+func (x *tagExpr) eval(tag func(string) bool) bool { return tag(x.tag) }
+func (x *notExpr) eval(tag func(string) bool) bool { return !x.x.eval(tag) }
+func (x *andExpr) eval(tag func(string) bool) bool { return x.x.eval(tag) && x.y.eval(tag) }
+func (x *orExpr) eval(tag func(string) bool) bool  { return x.x.eval(tag) || x.y.eval(tag) }
+
+// tags calls fn for every tag in x.
+func tags(x expr, fn func(string)) {
+	switch x := x.(type) {
+	case *tagExpr:
+		fn(x.tag)
+	case *notExpr:
+		tags(x.x, fn)
+	case *andExpr:
+		tags(x.x, fn)
+		tags(x.y, fn)
+	case *orExpr:
+		tags(x.x, fn)
+		tags(x.y, fn)
+	}
+}
+
+// hasTag returns whether pred is true for any tag in x.
+func hasTag(x expr, pred func(string) bool) bool {
+	found := false
+	tags(x, func(tag string) {
+		found = found || pred(tag)
+	})
+	return found
+}
+
+// parsePlusBuild parses the arguments of a // +build line: space-separated
+// options are or-ed, comma-separated terms and-ed.
+func parsePlusBuild(text string) (expr, error) {
+	var x expr
+	for _, opt := range strings.Fields(text) {
+		var y expr
+		for _, term := range strings.Split(opt, ",") {
+			var z expr
+			if strings.HasPrefix(term, "!") {
+				term = term[1:]
+				z = &notExpr{&tagExpr{term}}
+			} else {
+				z = &tagExpr{term}
+			}
+			if !isTag(term) {
+				return nil, fmt.Errorf("invalid build tag %q", term)
+			}
+			if y == nil {
+				y = z
+			} else {
+				y = &andExpr{y, z}
+			}
+		}
+		if x == nil {
+			x = y
+		} else {
+			x = &orExpr{x, y}
+		}
+	}
+	if x == nil {
+		return nil, errors.New("empty +build line")
+	}
+	return x, nil
+}
+
+func isTag(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' || r == '.') {
+			return false
+		}
+	}
+	return true
+}
+
+// parseExpr parses a //go:build expression.
+func parseExpr(text string) (expr, error) {
+	p := &exprParser{s: text}
+	x, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.next(); t != "" {
+		return nil, fmt.Errorf("unexpected %q in build constraint", t)
+	}
+	return x, nil
+}
+
+type exprParser struct {
+	s   string
+	tok string // the last token returned by next, if it was pushed back
+	pos int
+}
+
+// next returns the next token, or "" at the end of the input.
+func (p *exprParser) next() string {
+	if p.tok != "" {
+		t := p.tok
+		p.tok = ""
+		return t
+	}
+	for p.pos < len(p.s) && (p.s[p.pos] == ' ' || p.s[p.pos] == '\t') {
+		p.pos++
+	}
+	if p.pos == len(p.s) {
+		return ""
+	}
+	for _, op := range []string{"&&", "||", "!", "(", ")"} {
+		if strings.HasPrefix(p.s[p.pos:], op) {
+			p.pos += len(op)
+			return op
+		}
+	}
+	start := p.pos
+	for p.pos < len(p.s) && isTag(p.s[p.pos:p.pos+1]) {
+		p.pos++
+	}
+	if p.pos == start {
+		p.pos++
+	}
+	return p.s[start:p.pos]
+}
+
+func (p *exprParser) or() (expr, error) {
+	x, err := p.and()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		if t := p.next(); t != "||" {
+			p.tok = t
+			return x, nil
+		}
+		y, err := p.and()
+		if err != nil {
+			return nil, err
+		}
+		x = &orExpr{x, y}
+	}
+}
+
+func (p *exprParser) and() (expr, error) {
+	x, err := p.not()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		if t := p.next(); t != "&&" {
+			p.tok = t
+			return x, nil
+		}
+		y, err := p.not()
+		if err != nil {
+			return nil, err
+		}
+		x = &andExpr{x, y}
+	}
+}
+
+func (p *exprParser) not() (expr, error) {
+	switch t := p.next(); {
+	case t == "!":
+		x, err := p.not()
+		if err != nil {
+			return nil, err
+		}
+		return &notExpr{x}, nil
+	case t == "(":
+		x, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		if p.next() != ")" {
+			return nil, errors.New("missing ) in build constraint")
+		}
+		return x, nil
+	case isTag(t):
+		return &tagExpr{t}, nil
+	default:
+		return nil, fmt.Errorf("unexpected %q in build constraint", t)
+	}
+}
@@ -0,0 +1,128 @@
+// Copyright 2019 Axel Wagner
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package buildtags
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+
+	"golang.org/x/tools/go/analysis"
+)
+
+// Files not built in any target are not part of the analyzed package, so
+// analysistest can't check their diagnostics. The test runs the analyzer
+// directly instead.
+func TestBuildTags(t *testing.T) {
+	for _, tc := range []struct {
+		targets string
+		want    []string
+	}{
+		{"", []string{
+			"never.go: never.go is never built: its build constraint linux && windows can't be satisfied on any port",
+			"plus.go: plus.go is never built: its build constraint linux,windows can't be satisfied on any port",
+			"port_plan9_arm64.go: port_plan9_arm64.go is never built: its file name suffix _plan9_arm64 can't be satisfied on any port",
+			"suffix_windows.go: suffix_windows.go is never built: its build constraint linux and file name suffix _windows can't be satisfied on any port",
+		}},
+		{"linux/amd64:netgo windows/amd64", []string{
+			"integration.go: tags: build tag integration is not set by any of the configured targets; add a target setting it or remove it from the constraint",
+			"mac_darwin.go: mac_darwin.go is never built: its file name suffix _darwin can't be satisfied by any of the configured targets",
+			"netgo.go: netgo.go is never built: its build constraint linux && !netgo can't be satisfied by any of the configured targets",
+			"never.go: never.go is never built: its build constraint linux && windows can't be satisfied by any of the configured targets",
+			"plus.go: plus.go is never built: its build constraint linux,windows can't be satisfied by any of the configured targets",
+			"port_plan9_arm64.go: port_plan9_arm64.go is never built: its file name suffix _plan9_arm64 can't be satisfied by any of the configured targets",
+			"suffix_windows.go: suffix_windows.go is never built: its build constraint linux and file name suffix _windows can't be satisfied by any of the configured targets",
+		}},
+	} {
+		t.Run(tc.targets, func(t *testing.T) {
+			defer func(old string) { targetList = old }(targetList)
+			targetList = tc.targets
+			if got := diagnostics(t); !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("got diagnostics\n%q\nwant\n%q", got, tc.want)
+			}
+		})
+	}
+}
+
+func diagnostics(t *testing.T) []string {
+	fset := token.NewFileSet()
+	var files []*ast.File
+	for _, name := range []string{"a.go", "a_test.go"} {
+		f, err := parser.ParseFile(fset, filepath.Join("testdata", "a", name), nil, parser.ParseComments)
+		if err != nil {
+			t.Fatal(err)
+		}
+		files = append(files, f)
+	}
+	var got []string
+	pass := &analysis.Pass{
+		Analyzer: Analyzer,
+		Fset:     fset,
+		Files:    files,
+		Report: func(d analysis.Diagnostic) {
+			s := filepath.Base(fset.Position(d.Pos).Filename) + ": "
+			if d.Category != "" {
+				s += d.Category + ": "
+			}
+			got = append(got, s+d.Message)
+		},
+	}
+	if _, err := Analyzer.Run(pass); err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(got)
+	return got
+}
+
+func TestParseExpr(t *testing.T) {
+	for text, want := range map[string]string{
+		"linux":                  "linux",
+		"!linux && (arm || 386)": "(!linux && (arm || 386))",
+		"a || b && c":            "(a || (b && c))",
+		"!!a":                    "!!a",
+		"a && ":                  "error",
+		"(a":                     "error",
+		"a b":                    "error",
+		"go1.18 && !purego_x.y ": "(go1.18 && !purego_x.y)",
+	} {
+		x, err := parseExpr(text)
+		got := "error"
+		if err == nil {
+			got = format(x)
+		}
+		if got != want {
+			t.Errorf("parseExpr(%q) = %s, want %s", text, got, want)
+		}
+	}
+}
+
+func format(x expr) string {
+	switch x := x.(type) {
+	case *tagExpr:
+		return x.tag
+	case *notExpr:
+		return "!" + format(x.x)
+	case *andExpr:
+		return fmt.Sprintf("(%s && %s)", format(x.x), format(x.y))
+	case *orExpr:
+		return fmt.Sprintf("(%s || %s)", format(x.x), format(x.y))
+	}
+	return "?"
+}
@@ -0,0 +1,3 @@
+// +build linux,windows
+
+package a
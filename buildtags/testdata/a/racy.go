@@ -0,0 +1,5 @@
+//go:build race && cgo
+
+// +build race,cgo
+
+package a
@@ -0,0 +1,3 @@
+//go:build (unix && !windows) || go1.18
+
+package a
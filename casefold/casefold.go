@@ -0,0 +1,189 @@
+// Copyright 2019 Axel Wagner
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package casefold defines an Analyzer that checks for locale-sensitive
+// string comparisons.
+package casefold
+
+import (
+	"go/ast"
+	"go/constant"
+	"go/token"
+	"go/types"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/Merovius/go-tools/internal/analysisutil"
+	"github.com/Merovius/go-tools/internal/fix"
+	"github.com/Merovius/go-tools/internal/nodefilter"
+	"golang.org/x/tools/go/analysis"
+)
+
+const Doc = `check for locale-sensitive string comparisons
+
+The analyzer reports
+  - case-insensitive comparisons using strings.ToLower or strings.ToUpper,
+    like strings.ToLower(a) == strings.ToLower(b), which allocate and don't
+    handle all of Unicode. A fix replaces them with strings.EqualFold.
+    Comparisons with a constant which can never be equal, like
+    strings.ToLower(s) == "Go", are reported as well, and
+  - byte-wise comparisons with constants containing non-ASCII letters, like
+    s == "café". The same text in another Unicode normalization form, like
+    an e followed by a combining accent, compares unequal. Normalize both
+    sides, for example with golang.org/x/text/unicode/norm.`
+
+var Analyzer = &analysis.Analyzer{
+	Name: "casefold",
+	Doc:  Doc,
+	Run:  run,
+	Requires: []*analysis.Analyzer{
+		nodefilter.Analyzer,
+	},
+}
+
+func init() {
+	// strings.EqualFold uses simple case folding, which differs from
+	// comparing the results of ToLower for a few characters.
+	fix.Register(Analyzer, fix.Unverified)
+}
+
+var (
+	binaries = nodefilter.New(false, new(ast.BinaryExpr))
+	switches = nodefilter.New(false, new(ast.SwitchStmt))
+)
+
+var caseFuncs = map[string]func(string) string{
+	"strings.ToLower": strings.ToLower,
+	"strings.ToUpper": strings.ToUpper,
+}
+
+func run(pass *analysis.Pass) (interface{}, error) {
+	nodes := pass.ResultOf[nodefilter.Analyzer].(*nodefilter.Result)
+	for _, n := range nodes.Nodes(binaries) {
+		e := n.Node.(*ast.BinaryExpr)
+		if e.Op != token.EQL && e.Op != token.NEQ {
+			continue
+		}
+		if !checkCase(pass, e) {
+			checkNormalization(pass, e.X, e.Y)
+			checkNormalization(pass, e.Y, e.X)
+		}
+	}
+	for _, n := range nodes.Nodes(switches) {
+		s := n.Node.(*ast.SwitchStmt)
+		if s.Tag == nil {
+			continue
+		}
+		for _, c := range s.Body.List {
+			for _, e := range c.(*ast.CaseClause).List {
+				checkNormalization(pass, e, s.Tag)
+			}
+		}
+	}
+	return nil, nil
+}
+
+// caseCall returns the argument of e, the name of the function and the
+// package name it is qualified with, if e is a call to strings.ToLower or
+// strings.ToUpper.
+func caseCall(pass *analysis.Pass, e ast.Expr) (arg ast.Expr, name, qual string) {
+	call, ok := analysisutil.Unparen(e).(*ast.CallExpr)
+	if !ok || len(call.Args) != 1 {
+		return nil, "", ""
+	}
+	name = analysisutil.CalleeName(pass.TypesInfo, call)
+	sel, ok := call.Fun.(*ast.SelectorExpr)
+	if caseFuncs[name] == nil || !ok {
+		return nil, "", ""
+	}
+	return call.Args[0], name, analysisutil.Render(pass.Fset, sel.X)
+}
+
+// checkCase reports the comparison e, if it compares strings converted to
+// the same case. It returns whether it did.
+func checkCase(pass *analysis.Pass, e *ast.BinaryExpr) bool {
+	x, fx, qual := caseCall(pass, e.X)
+	y, fy, _ := caseCall(pass, e.Y)
+	switch {
+	case fx != "" && fx == fy:
+	case fx != "" && isConst(pass, e.Y):
+		y = e.Y
+	case fy != "" && isConst(pass, e.X):
+		x, _, qual = caseCall(pass, e.Y)
+		y, fx = e.X, fy
+	default:
+		return false
+	}
+	if c := pass.TypesInfo.Types[y].Value; c != nil {
+		if s := constant.StringVal(c); caseFuncs[fx](s) != s {
+			pass.Report(analysis.Diagnostic{
+				Pos:     e.Pos(),
+				End:     e.End(),
+				Message: "result of " + fx + " can never equal " + c.String() + "; compare with strings.EqualFold instead",
+			})
+			return true
+		}
+	}
+	repl := qual + ".EqualFold(" + analysisutil.Render(pass.Fset, x) + ", " + analysisutil.Render(pass.Fset, y) + ")"
+	if e.Op == token.NEQ {
+		repl = "!" + repl
+	}
+	pass.Report(analysis.Diagnostic{
+		Pos:     e.Pos(),
+		End:     e.End(),
+		Message: "case-insensitive comparison using " + fx + "; use " + repl,
+		SuggestedFixes: []analysis.SuggestedFix{{
+			Message:   "use strings.EqualFold",
+			TextEdits: []analysis.TextEdit{{Pos: e.Pos(), End: e.End(), NewText: []byte(repl)}},
+		}},
+	})
+	return true
+}
+
+func isConst(pass *analysis.Pass, e ast.Expr) bool {
+	tv := pass.TypesInfo.Types[e]
+	return tv.Value != nil && tv.Value.Kind() == constant.String
+}
+
+// checkNormalization reports the byte-wise comparison of c and other, if
+// c is a constant containing non-ASCII letters.
+func checkNormalization(pass *analysis.Pass, c, other ast.Expr) {
+	if !isConst(pass, c) || isConst(pass, other) {
+		return
+	}
+	if t := pass.TypesInfo.TypeOf(other); t == nil || !types.Identical(t.Underlying(), types.Typ[types.String]) {
+		return
+	}
+	s := constant.StringVal(pass.TypesInfo.Types[c].Value)
+	if !nonASCIILetters(s) {
+		return
+	}
+	pass.Report(analysis.Diagnostic{
+		Pos:      c.Pos(),
+		End:      c.End(),
+		Category: "normalization",
+		Message:  "byte-wise comparison with " + strconv.Quote(s) + ", which contains non-ASCII letters; the same text in another Unicode normalization form compares unequal",
+	})
+}
+
+func nonASCIILetters(s string) bool {
+	for _, r := range s {
+		if r >= utf8.RuneSelf && (unicode.IsLetter(r) || unicode.Is(unicode.Mn, r)) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,40 @@
+package a
+
+import (
+	"strings"
+	str "strings"
+)
+
+type user struct{ Name, City string }
+
+func compare(a, b string, u user) bool {
+	if strings.ToLower(a) == strings.ToLower(b) { // want `case-insensitive comparison using strings.ToLower; use strings.EqualFold\(a, b\)`
+		return true
+	}
+	if str.ToUpper(u.Name) != str.ToUpper(b) { // want `use !str.EqualFold\(u.Name, b\)`
+		return false
+	}
+	if "yes" == strings.ToLower(a) { // want `use strings.EqualFold\(a, "yes"\)`
+		return true
+	}
+	if strings.ToLower(a) == "Yes" { // want `result of strings.ToLower can never equal "Yes"; compare with strings.EqualFold instead`
+		return true
+	}
+	if strings.ToLower(a) == strings.ToUpper(b) {
+		return false
+	}
+	return strings.ToLower(a) == b
+}
+
+func normalization(u user) bool {
+	if u.City == "Zürich" { // want `byte-wise comparison with "Zürich", which contains non-ASCII letters; the same text in another Unicode normalization form compares unequal`
+		return true
+	}
+	switch u.Name {
+	case "José": // want `byte-wise comparison with "José"`
+		return true
+	case "Jose", "→":
+		return false
+	}
+	return u.City == "Berlin"
+}
@@ -0,0 +1,570 @@
+// Copyright 2019 Axel Wagner
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package cgoaudit defines an Analyzer that checks the use of cgo.
+package cgoaudit
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"strconv"
+	"strings"
+
+	"github.com/Merovius/go-tools/internal/analysisutil"
+	"github.com/Merovius/go-tools/internal/nodefilter"
+	"golang.org/x/tools/go/analysis"
+)
+
+const Doc = `check the use of cgo
+
+The analyzer reports
+  - Go pointers passed to C, which point to Go memory containing Go
+    pointers. This violates the cgo pointer passing rules, as the garbage
+    collector doesn't know about the pointers once C holds them,
+  - C strings allocated with C.CString, which aren't freed with C.free on
+    all paths, and
+  - #cgo directives with flags containing absolute paths, which only work on
+    the machine they were written on. Use ${SRCDIR} or pkg-config instead.
+
+As cgo rewrites the files importing "C" before they are type-checked, the
+analyzer parses the original files again and type-checks them against the
+rewritten package.`
+
+var Analyzer = &analysis.Analyzer{
+	Name:             "cgoaudit",
+	Doc:              Doc,
+	Run:              run,
+	RunDespiteErrors: true,
+	Requires: []*analysis.Analyzer{
+		nodefilter.Analyzer,
+	},
+}
+
+var (
+	calls   = nodefilter.New(true, new(ast.CallExpr))
+	assigns = nodefilter.New(true, new(ast.AssignStmt))
+)
+
+func run(pass *analysis.Pass) (interface{}, error) {
+	var (
+		files []*ast.File
+		info  *types.Info
+		nodes *nodefilter.Result
+	)
+	switch {
+	case analysisutil.Imports(pass.Pkg, "C"):
+		// The package was type-checked without running cgo, with a
+		// fake "C" package.
+		info = pass.TypesInfo
+		nodes = pass.ResultOf[nodefilter.Analyzer].(*nodefilter.Result)
+		seen := make(map[*token.File]bool)
+		for _, f := range pass.Files {
+			if tf := pass.Fset.File(f.Pos()); !seen[tf] {
+				seen[tf] = true
+				files = append(files, f)
+			}
+		}
+	case analysisutil.Imports(pass.Pkg, "runtime/cgo"):
+		// The shared traversal only covers the files rewritten by cgo.
+		files, info = typeCheckCgoFiles(pass)
+		nodes = nodefilter.Walk(files, calls, assigns)
+	default:
+		return nil, nil
+	}
+	for _, f := range files {
+		checkFlags(pass, f)
+	}
+	for _, n := range unique(nodes.Nodes(calls)) {
+		call := n.Node.(*ast.CallExpr)
+		name := cName(call.Fun)
+		if name == "" {
+			continue
+		}
+		checkPointers(pass, info, call, name)
+		if funcBody(n.Stack) != nil {
+			checkCStringArgs(pass, call)
+		}
+	}
+	for _, n := range unique(nodes.Nodes(assigns)) {
+		if body := funcBody(n.Stack); body != nil {
+			checkCStrings(pass, info, body, n.Node.(*ast.AssignStmt))
+		}
+	}
+	return nil, nil
+}
+
+// unique drops repeated nodes. The files of packages type-checked with a
+// fake "C" package can be listed twice.
+func unique(nodes []nodefilter.Node) []nodefilter.Node {
+	seen := make(map[ast.Node]bool)
+	out := nodes[:0:0]
+	for _, n := range nodes {
+		if !seen[n.Node] {
+			seen[n.Node] = true
+			out = append(out, n)
+		}
+	}
+	return out
+}
+
+// funcBody returns the body of the function declaration enclosing the node
+// at the top of stack, or nil if it isn't in one.
+func funcBody(stack []ast.Node) *ast.BlockStmt {
+	for _, n := range stack {
+		if decl, ok := n.(*ast.FuncDecl); ok {
+			return decl.Body
+		}
+	}
+	return nil
+}
+
+// cName returns the name of the C object e refers to, if it is of the form
+// C.name.
+func cName(e ast.Expr) string {
+	sel, ok := analysisutil.Unparen(e).(*ast.SelectorExpr)
+	if !ok {
+		return ""
+	}
+	if id, ok := sel.X.(*ast.Ident); !ok || id.Name != "C" {
+		return ""
+	}
+	return sel.Sel.Name
+}
+
+// typeCheckCgoFiles returns the files importing "C" of the package, as they
+// were before being processed by cgo, and their type information.
+//
+// Every file is type-checked as part of a package dot-importing the
+// package processed by cgo, with the package-level declarations stripped of
+// their names, so that their function bodies and initializers refer to the
+// objects of the package. For example
+//
+//	package p
+//	import "C"
+//	type T int
+//	var x = C.f()
+//	func (t T) m() { C.g(t) }
+//
+// is checked as
+//
+//	package p
+//	import . "·this·"
+//	import "C"
+//	var _ = C.f()
+//	func _(t T) { C.g(t) }
+//
+// This is the approach of the cgocall analyzer in golang.org/x/tools. As
+// the "C" package is faked, C objects have invalid types.
+func typeCheckCgoFiles(pass *analysis.Pass) ([]*ast.File, *types.Info) {
+	const this = "·this·"
+	var (
+		files   []*ast.File
+		imports = map[string]*types.Package{this: pass.Pkg}
+		seen    = make(map[string]bool)
+	)
+	for _, cooked := range pass.Files {
+		// The position of the cooked file refers to the original one
+		// through //line directives.
+		name := pass.Fset.Position(cooked.Pos()).Filename
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+		f, err := parser.ParseFile(pass.Fset, name, nil, parser.ParseComments)
+		if err != nil || !importsC(f) {
+			continue
+		}
+		for _, imp := range pass.Pkg.Imports() {
+			imports[imp.Path()] = imp
+		}
+		decls := []ast.Decl{&ast.GenDecl{
+			Tok: token.IMPORT,
+			Specs: []ast.Spec{&ast.ImportSpec{
+				Name: ast.NewIdent("."),
+				Path: &ast.BasicLit{Kind: token.STRING, Value: strconv.Quote(this)},
+			}},
+		}}
+		for _, d := range f.Decls {
+			switch d := d.(type) {
+			case *ast.GenDecl:
+				switch d.Tok {
+				case token.TYPE:
+					continue
+				case token.VAR, token.CONST:
+					for _, s := range d.Specs {
+						for _, n := range s.(*ast.ValueSpec).Names {
+							n.Name = "_"
+						}
+					}
+				}
+			case *ast.FuncDecl:
+				d.Name.Name = "_"
+				if d.Recv != nil {
+					d.Type.Params.List = append(d.Recv.List, d.Type.Params.List...)
+					d.Recv = nil
+				}
+			}
+			decls = append(decls, d)
+		}
+		f.Decls = decls
+		files = append(files, f)
+	}
+	info := &types.Info{
+		Types: make(map[ast.Expr]types.TypeAndValue),
+		Defs:  make(map[*ast.Ident]types.Object),
+		Uses:  make(map[*ast.Ident]types.Object),
+	}
+	if len(files) == 0 {
+		return nil, info
+	}
+	conf := &types.Config{
+		FakeImportC: true,
+		Importer: importerFunc(func(path string) (*types.Package, error) {
+			return imports[path], nil
+		}),
+		Sizes: pass.TypesSizes,
+		// Unexported objects of the package and unused imports cause
+		// errors, which don't matter.
+		Error: func(error) {},
+	}
+	conf.Check(pass.Pkg.Path(), pass.Fset, files, info)
+	return files, info
+}
+
+func importsC(f *ast.File) bool {
+	for _, imp := range f.Imports {
+		if imp.Path.Value == `"C"` {
+			return true
+		}
+	}
+	return false
+}
+
+type importerFunc func(path string) (*types.Package, error)
+
+func (f importerFunc) Import(path string) (*types.Package, error) { return f(path) }
+
+// checkPointers reports arguments of the call to C.name pointing to Go
+// memory which contains Go pointers.
+func checkPointers(pass *analysis.Pass, info *types.Info, call *ast.CallExpr, name string) {
+	if name == "CBytes" || name == "CString" || name == "GoString" || name == "GoStringN" || name == "GoBytes" {
+		return
+	}
+	for _, arg := range call.Args {
+		t := baseType(info, arg)
+		if t == nil {
+			continue
+		}
+		if what := goPointers(t.Underlying(), make(map[types.Type]bool)); what != "" && !isPointer(t) {
+			pass.Reportf(arg.Pos(), "passing %s to C.%s violates the cgo pointer rules", what, name)
+			continue
+		}
+		p, ok := t.Underlying().(*types.Pointer)
+		if !ok {
+			continue
+		}
+		if what := goPointers(p.Elem(), make(map[types.Type]bool)); what != "" {
+			pass.Reportf(arg.Pos(), "passing a pointer to Go memory containing %s to C.%s violates the cgo pointer rules", what, name)
+		}
+	}
+}
+
+func isPointer(t types.Type) bool {
+	switch t := t.Underlying().(type) {
+	case *types.Pointer:
+		return true
+	case *types.Basic:
+		return t.Kind() == types.UnsafePointer
+	}
+	return false
+}
+
+// baseType returns the type of arg, looking through conversions to
+// unsafe.Pointer. For &s[0] of a slice s, it returns a pointer to its
+// element type.
+func baseType(info *types.Info, arg ast.Expr) types.Type {
+	arg = analysisutil.Unparen(arg)
+	if call, ok := arg.(*ast.CallExpr); ok && len(call.Args) == 1 {
+		if t := info.Types[call.Fun]; t.IsType() && isUnsafePointer(t.Type) {
+			return baseType(info, call.Args[0])
+		}
+	}
+	return info.Types[arg].Type
+}
+
+func isUnsafePointer(t types.Type) bool {
+	b, ok := t.Underlying().(*types.Basic)
+	return ok && b.Kind() == types.UnsafePointer
+}
+
+// goPointers describes the Go pointers contained in values of type t, or
+// returns "" if they don't contain any.
+func goPointers(t types.Type, seen map[types.Type]bool) string {
+	if seen[t] {
+		return ""
+	}
+	seen[t] = true
+	switch u := t.Underlying().(type) {
+	case *types.Basic:
+		switch {
+		case u.Info()&types.IsString != 0:
+			return "a string"
+		case u.Kind() == types.UnsafePointer:
+			return "an unsafe.Pointer"
+		}
+	case *types.Pointer:
+		if isC(u.Elem()) {
+			return ""
+		}
+		return "a pointer"
+	case *types.Slice:
+		return "a slice"
+	case *types.Map:
+		return "a map"
+	case *types.Chan:
+		return "a channel"
+	case *types.Signature:
+		return "a func"
+	case *types.Interface:
+		return "an interface"
+	case *types.Array:
+		return goPointers(u.Elem(), seen)
+	case *types.Struct:
+		for i := 0; i < u.NumFields(); i++ {
+			if what := goPointers(u.Field(i).Type(), seen); what != "" {
+				return what + " (field " + u.Field(i).Name() + ")"
+			}
+		}
+	}
+	return ""
+}
+
+// isC returns whether t is a type defined by cgo, which is allocated by C.
+func isC(t types.Type) bool {
+	n, ok := t.(*types.Named)
+	return ok && strings.HasPrefix(n.Obj().Name(), "_Ctype_")
+}
+
+// checkCStringArgs reports C strings allocated by C.CString as arguments of
+// the call to a C function, which are never freed.
+func checkCStringArgs(pass *analysis.Pass, call *ast.CallExpr) {
+	for _, arg := range call.Args {
+		if c, ok := analysisutil.Unparen(arg).(*ast.CallExpr); ok && cName(c.Fun) == "CString" {
+			pass.Report(analysis.Diagnostic{
+				Pos:      c.Pos(),
+				End:      c.End(),
+				Category: "free",
+				Message:  "the C string allocated by C.CString is never freed; assign it to a variable and defer C.free",
+			})
+		}
+	}
+}
+
+// checkCStrings reports C strings allocated by C.CString and assigned to
+// variables by assign in the function body, which aren't freed on all
+// paths.
+func checkCStrings(pass *analysis.Pass, info *types.Info, body *ast.BlockStmt, assign *ast.AssignStmt) {
+	if len(assign.Lhs) != len(assign.Rhs) {
+		return
+	}
+	for i, rhs := range assign.Rhs {
+		call, ok := analysisutil.Unparen(rhs).(*ast.CallExpr)
+		if !ok || cName(call.Fun) != "CString" {
+			continue
+		}
+		id, ok := assign.Lhs[i].(*ast.Ident)
+		if !ok {
+			continue
+		}
+		obj := info.Defs[id]
+		if obj == nil {
+			obj = info.Uses[id]
+		}
+		if obj != nil {
+			checkFree(pass, info, body, call, obj)
+		}
+	}
+}
+
+// checkFree reports the C string allocated by call and assigned to v, if
+// it isn't freed on all paths returning from body.
+func checkFree(pass *analysis.Pass, info *types.Info, body *ast.BlockStmt, call *ast.CallExpr, v types.Object) {
+	var (
+		frees    []*ast.CallExpr
+		deferred bool
+		escapes  bool
+		returns  []*ast.ReturnStmt
+		inDefer  int
+	)
+	var visit func(n ast.Node) bool
+	visit = func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.DeferStmt:
+			inDefer++
+			ast.Inspect(n.Call, visit)
+			inDefer--
+			return false
+		case *ast.FuncLit:
+			if inDefer == 0 {
+				// Closures might be called anytime.
+				ast.Inspect(n.Body, func(n ast.Node) bool {
+					if id, ok := n.(*ast.Ident); ok && info.Uses[id] == v {
+						escapes = true
+					}
+					return true
+				})
+				return false
+			}
+		case *ast.ReturnStmt:
+			if n.Pos() > call.End() {
+				returns = append(returns, n)
+			}
+			for _, r := range n.Results {
+				if id, ok := analysisutil.Unparen(r).(*ast.Ident); ok && info.Uses[id] == v {
+					escapes = true
+				}
+			}
+		case *ast.CallExpr:
+			if cName(n.Fun) == "free" && len(n.Args) == 1 && refersTo(info, n.Args[0], v) {
+				if inDefer > 0 {
+					deferred = true
+				}
+				frees = append(frees, n)
+				return false
+			}
+			if cName(n.Fun) == "" {
+				for _, arg := range n.Args {
+					if refersTo(info, arg, v) {
+						// Go functions might free or keep the string.
+						escapes = true
+					}
+				}
+			}
+		case *ast.AssignStmt:
+			for _, r := range n.Rhs {
+				if refersTo(info, r, v) {
+					escapes = true
+				}
+			}
+		case *ast.CompositeLit:
+			for _, e := range n.Elts {
+				if kv, ok := e.(*ast.KeyValueExpr); ok {
+					e = kv.Value
+				}
+				if refersTo(info, e, v) {
+					escapes = true
+				}
+			}
+		}
+		return true
+	}
+	ast.Inspect(body, visit)
+	if escapes || deferred {
+		return
+	}
+	if len(frees) == 0 {
+		pass.Report(analysis.Diagnostic{
+			Pos:      call.Pos(),
+			End:      call.End(),
+			Category: "free",
+			Message:  "the C string allocated by C.CString is never freed; defer C.free(unsafe.Pointer(" + v.Name() + "))",
+		})
+		return
+	}
+	first := frees[0].Pos()
+	for _, r := range returns {
+		if r.Pos() < first {
+			pass.Report(analysis.Diagnostic{
+				Pos:      call.Pos(),
+				End:      call.End(),
+				Category: "free",
+				Message:  "the C string allocated by C.CString isn't freed when returning at line " + strconv.Itoa(pass.Fset.Position(r.Pos()).Line) + "; defer C.free instead",
+			})
+			return
+		}
+	}
+}
+
+// refersTo returns whether e is v, possibly converted to unsafe.Pointer.
+func refersTo(info *types.Info, e ast.Expr, v types.Object) bool {
+	e = analysisutil.Unparen(e)
+	if call, ok := e.(*ast.CallExpr); ok && len(call.Args) == 1 {
+		if t := info.Types[call.Fun]; t.IsType() && isUnsafePointer(t.Type) {
+			e = analysisutil.Unparen(call.Args[0])
+		}
+	}
+	id, ok := e.(*ast.Ident)
+	return ok && info.Uses[id] == v
+}
+
+// checkFlags reports flags of #cgo directives in the preamble of f
+// containing absolute paths.
+func checkFlags(pass *analysis.Pass, f *ast.File) {
+	for _, d := range f.Decls {
+		gd, ok := d.(*ast.GenDecl)
+		if !ok || gd.Tok != token.IMPORT {
+			continue
+		}
+		for _, s := range gd.Specs {
+			imp := s.(*ast.ImportSpec)
+			if imp.Path.Value != `"C"` {
+				continue
+			}
+			doc := imp.Doc
+			if doc == nil {
+				doc = gd.Doc
+			}
+			if doc != nil {
+				checkPreamble(pass, doc)
+			}
+		}
+	}
+}
+
+func checkPreamble(pass *analysis.Pass, doc *ast.CommentGroup) {
+	for _, c := range doc.List {
+		offset := 0
+		for _, line := range strings.SplitAfter(c.Text, "\n") {
+			pos := c.Pos() + token.Pos(offset)
+			offset += len(line)
+			l := strings.TrimSpace(strings.TrimPrefix(strings.TrimPrefix(strings.TrimSpace(line), "//"), "/*"))
+			if !strings.HasPrefix(l, "#cgo ") {
+				continue
+			}
+			if i := strings.Index(l, "//"); i >= 0 {
+				l = l[:i]
+			}
+			i := strings.IndexByte(l, ':')
+			if i < 0 {
+				continue
+			}
+			for _, flag := range strings.Fields(l[i+1:]) {
+				path := flag
+				for _, p := range []string{"-I", "-L", "-isystem", "-Wl,-rpath,"} {
+					path = strings.TrimPrefix(path, p)
+				}
+				if !strings.HasPrefix(path, "/") || strings.HasPrefix(path, "/usr/") {
+					continue
+				}
+				pass.Report(analysis.Diagnostic{
+					Pos:      pos,
+					Category: "flags",
+					Message:  "#cgo flag " + flag + " contains the absolute path " + path + "; use ${SRCDIR} or pkg-config",
+				})
+			}
+		}
+	}
+}
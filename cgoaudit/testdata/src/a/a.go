@@ -0,0 +1,74 @@
+package a
+
+// #cgo CFLAGS: -I/home/alice/include -I${SRCDIR}/include // want `#cgo flag -I/home/alice/include contains the absolute path /home/alice/include; use \${SRCDIR} or pkg-config`
+// #cgo LDFLAGS: -L/usr/local/lib -lm
+// #include <stdlib.h>
+// #include <stdio.h>
+// #include <string.h>
+//
+// struct point { int x, y; };
+//
+// static void use(void *p) {}
+// static void usePoint(struct point *p) {}
+import "C"
+
+import (
+	"errors"
+	"unsafe"
+)
+
+type node struct {
+	next *node
+	val  int
+}
+
+type plain struct {
+	x, y int32
+}
+
+type named struct {
+	name string
+}
+
+func pointers(n *node, p *plain, nm named, s []*node, ints []int) {
+	C.use(unsafe.Pointer(n)) // want `passing a pointer to Go memory containing a pointer \(field next\) to C.use violates the cgo pointer rules`
+	C.use(unsafe.Pointer(p))
+	C.use(unsafe.Pointer(&nm)) // want `passing a pointer to Go memory containing a string \(field name\) to C.use violates`
+	C.use(unsafe.Pointer(&s[0])) // want `passing a pointer to Go memory containing a pointer to C.use`
+	C.use(unsafe.Pointer(&ints[0]))
+	var cp C.struct_point
+	C.usePoint(&cp)
+}
+
+func leak(s string) {
+	cs := C.CString(s) // want `the C string allocated by C.CString is never freed; defer C.free\(unsafe.Pointer\(cs\)\)`
+	C.puts(cs)
+	C.puts(C.CString(s)) // want `the C string allocated by C.CString is never freed; assign it to a variable and defer C.free`
+}
+
+func early(s string) error {
+	cs := C.CString(s) // want `isn't freed when returning at line 52; defer C.free instead`
+	if C.strlen(cs) == 0 {
+		return errors.New("empty")
+	}
+	C.puts(cs)
+	C.free(unsafe.Pointer(cs))
+	return nil
+}
+
+func deferred(s string) {
+	cs := C.CString(s)
+	defer C.free(unsafe.Pointer(cs))
+	C.puts(cs)
+}
+
+func returned(s string) *C.char {
+	cs := C.CString(s)
+	return cs
+}
+
+func freed(s string) {
+	cs := C.CString(s)
+	C.puts(cs)
+	C.free(unsafe.Pointer(cs))
+}
@@ -0,0 +1,245 @@
+// Copyright 2019 Axel Wagner
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package chandir defines an Analyzer that checks for channel parameters
+// which could have a direction.
+package chandir
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+
+	"github.com/Merovius/go-tools/internal/analysisutil"
+	"github.com/Merovius/go-tools/internal/fix"
+	"github.com/Merovius/go-tools/internal/nodefilter"
+	"golang.org/x/tools/go/analysis"
+)
+
+const Doc = `check for channel parameters which could have a direction
+
+Declaring a channel parameter as <-chan T or chan<- T documents how the
+function uses it and lets the compiler catch mistakes, like closing a channel
+owned by the caller. The analyzer reports bidirectional channel parameters of
+functions which only receive from or only send to (and close) them.
+
+It suggests a fix changing the type of the parameter, if the function is
+unexported and only called directly, so that the change can't break other
+code. Methods are not reported, as their signature might be needed to
+implement an interface.`
+
+var Analyzer = &analysis.Analyzer{
+	Name: "chandir",
+	Doc:  Doc,
+	Run:  run,
+	Requires: []*analysis.Analyzer{
+		nodefilter.Analyzer,
+	},
+}
+
+func init() {
+	// Fixes are only suggested if all uses of the function are calls, which
+	// accept bidirectional channels for directional parameters.
+	fix.Register(Analyzer, fix.Safe)
+}
+
+var (
+	funcs  = nodefilter.New(false, new(ast.FuncDecl))
+	idents = nodefilter.New(true, new(ast.Ident))
+)
+
+// use is how a channel is used.
+type use int
+
+const (
+	none use = 0
+	send use = 1 << iota
+	recv
+	other // anything requiring a bidirectional channel
+)
+
+func run(pass *analysis.Pass) (interface{}, error) {
+	nodes := pass.ResultOf[nodefilter.Analyzer].(*nodefilter.Result)
+
+	var (
+		uses       = make(map[*types.Var]use)
+		calledOnly = make(map[*types.Func]bool)
+	)
+	for _, n := range nodes.Nodes(idents) {
+		id := n.Node.(*ast.Ident)
+		switch obj := pass.TypesInfo.Uses[id].(type) {
+		case *types.Var:
+			if _, ok := obj.Type().Underlying().(*types.Chan); ok {
+				uses[obj] |= classify(pass, n.Stack)
+			}
+		case *types.Func:
+			ok, seen := calledOnly[obj]
+			calledOnly[obj] = (ok || !seen) && isCalled(n.Stack)
+		}
+	}
+
+	for _, n := range nodes.Nodes(funcs) {
+		fn := n.Node.(*ast.FuncDecl)
+		if fn.Recv != nil || fn.Body == nil {
+			continue
+		}
+		obj, ok := pass.TypesInfo.Defs[fn.Name].(*types.Func)
+		if !ok {
+			continue
+		}
+		canFix := !fn.Name.IsExported() && fn.Name.Name != "init" && fn.Name.Name != "main"
+		if ok, seen := calledOnly[obj]; seen && !ok {
+			canFix = false
+		}
+		for _, field := range fn.Type.Params.List {
+			checkField(pass, field, uses, canFix)
+		}
+	}
+	return nil, nil
+}
+
+func checkField(pass *analysis.Pass, field *ast.Field, uses map[*types.Var]use, canFix bool) {
+	ct, ok := field.Type.(*ast.ChanType)
+	if !ok || ct.Dir != ast.SEND|ast.RECV {
+		return
+	}
+	var (
+		dirs   = make(map[use]bool)
+		report []*ast.Ident
+	)
+	for _, name := range field.Names {
+		v, ok := pass.TypesInfo.Defs[name].(*types.Var)
+		if !ok {
+			return
+		}
+		u := uses[v]
+		dirs[u] = true
+		if u == send || u == recv {
+			report = append(report, name)
+		}
+	}
+	// Parameters sharing a type can only be changed together.
+	canFix = canFix && len(dirs) == 1 && len(report) > 0
+
+	elem := pass.TypesInfo.TypeOf(ct.Value)
+	for _, name := range report {
+		v := pass.TypesInfo.Defs[name].(*types.Var)
+		dir, verb, edit := types.SendOnly, "send to", analysis.TextEdit{Pos: ct.Begin + token.Pos(len("chan")), End: ct.Begin + token.Pos(len("chan")), NewText: []byte("<-")}
+		if uses[v] == recv {
+			dir, verb, edit = types.RecvOnly, "receive from", analysis.TextEdit{Pos: ct.Begin, End: ct.Begin, NewText: []byte("<-")}
+		}
+		typ := types.TypeString(types.NewChan(dir, elem), types.RelativeTo(pass.Pkg))
+		d := analysis.Diagnostic{
+			Pos:     name.Pos(),
+			End:     ct.End(),
+			Message: "parameter " + name.Name + " is only used to " + verb + "; declare it as " + typ,
+		}
+		if canFix {
+			d.SuggestedFixes = []analysis.SuggestedFix{{
+				Message:   "declare " + name.Name + " as " + typ,
+				TextEdits: []analysis.TextEdit{edit},
+			}}
+			// All names share the edit, only attach it once.
+			canFix = false
+		}
+		pass.Report(d)
+	}
+}
+
+// classify returns how the channel referred to by the identifier at the top
+// of stack is used.
+func classify(pass *analysis.Pass, stack []ast.Node) use {
+	i := len(stack) - 2
+	for i > 0 {
+		if _, ok := stack[i].(*ast.ParenExpr); !ok {
+			break
+		}
+		i--
+	}
+	child := stack[i+1]
+	switch p := stack[i].(type) {
+	case *ast.SendStmt:
+		if p.Chan == child {
+			return send
+		}
+	case *ast.UnaryExpr:
+		if p.Op == token.ARROW {
+			return recv
+		}
+	case *ast.RangeStmt:
+		if p.X == child {
+			return recv
+		}
+	case *ast.CallExpr:
+		return classifyArg(pass, p, child)
+	}
+	return other
+}
+
+func classifyArg(pass *analysis.Pass, call *ast.CallExpr, arg ast.Node) use {
+	if id, ok := analysisutil.Unparen(call.Fun).(*ast.Ident); ok {
+		if b, ok := pass.TypesInfo.Uses[id].(*types.Builtin); ok {
+			switch b.Name() {
+			case "close":
+				return send
+			case "len", "cap":
+				return none
+			}
+			return other
+		}
+	}
+	sig, ok := pass.TypesInfo.TypeOf(call.Fun).(*types.Signature)
+	if !ok {
+		return other
+	}
+	for i, a := range call.Args {
+		if a != arg {
+			continue
+		}
+		var t types.Type
+		switch {
+		case sig.Variadic() && i >= sig.Params().Len()-1:
+			if call.Ellipsis.IsValid() {
+				return other
+			}
+			t = sig.Params().At(sig.Params().Len() - 1).Type().(*types.Slice).Elem()
+		case i < sig.Params().Len():
+			t = sig.Params().At(i).Type()
+		default:
+			return other
+		}
+		if ch, ok := t.Underlying().(*types.Chan); ok {
+			switch ch.Dir() {
+			case types.SendOnly:
+				return send
+			case types.RecvOnly:
+				return recv
+			}
+		}
+	}
+	return other
+}
+
+// isCalled returns whether the identifier at the top of stack is called.
+func isCalled(stack []ast.Node) bool {
+	i := len(stack) - 2
+	for i > 0 {
+		if _, ok := stack[i].(*ast.ParenExpr); !ok {
+			break
+		}
+		i--
+	}
+	call, ok := stack[i].(*ast.CallExpr)
+	return ok && analysisutil.Unparen(call.Fun) == stack[len(stack)-1]
+}
@@ -0,0 +1,71 @@
+package a
+
+func consume(ch chan int) { // want `parameter ch is only used to receive from; declare it as <-chan int`
+	for v := range ch {
+		println(v)
+	}
+}
+
+func produce(ch chan int, n int) { // want `parameter ch is only used to send to; declare it as chan<- int`
+	for i := 0; i < n; i++ {
+		ch <- i
+	}
+	close(ch)
+}
+
+func both(ch chan int) {
+	ch <- <-ch
+}
+
+func pair(in, out chan string) { // want `parameter in is only used to receive from` `parameter out is only used to send to`
+	out <- <-in
+}
+
+func sinks(a, b chan error) { // want `parameter a is only used to send to` `parameter b is only used to send to`
+	a <- nil
+	b <- nil
+}
+
+func forward(ch chan int) { // want `parameter ch is only used to receive from`
+	consumeDir(ch)
+	println(len(ch))
+}
+
+func consumeDir(ch <-chan int) {
+	<-ch
+}
+
+func escapes(ch chan int) chan int {
+	<-ch
+	return ch
+}
+
+func Exported(ch chan bool) { // want `parameter ch is only used to send to`
+	select {
+	case ch <- true:
+	default:
+	}
+}
+
+func callback(done chan struct{}) { // want `parameter done is only used to receive from`
+	<-done
+}
+
+var hook = callback
+
+type T struct{}
+
+func (T) method(ch chan int) {
+	<-ch
+}
+
+func main() {
+	ch := make(chan int)
+	go produce(ch, 3)
+	consume(ch)
+	forward(ch)
+	both(ch)
+	escapes(ch)
+	pair(make(chan string), make(chan string))
+	sinks(nil, nil)
+}
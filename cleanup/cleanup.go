@@ -0,0 +1,353 @@
+// Copyright 2019 Axel Wagner
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package cleanup defines an Analyzer that checks the cleanup of resources
+// which are closed explicitly.
+package cleanup
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+
+	"github.com/Merovius/go-tools/internal/analysisutil"
+	"github.com/Merovius/go-tools/internal/nodefilter"
+	"golang.org/x/tools/go/analysis"
+)
+
+const Doc = `check the cleanup of resources acquired by a function
+
+A resource is a value with a Close method, returned together with an error,
+like the result of os.Open. The analyzer reports
+  - cleanup of a resource whose acquisition failed: a Close deferred before
+    the error is checked, or called in the error check itself. The resource
+    is invalid in that case, usually nil,
+  - resources closed in the order they were acquired (category "order").
+    Later resources often depend on earlier ones, like a transaction on its
+    connection, so they should be closed first. Deferred closes run in
+    reverse order, so they have to be deferred in acquisition order, and
+  - deferred functions assigning the error of a Close to the error result
+    of the function without checking it is nil (category "clobber"). They
+    silently replace the error the function actually failed with.`
+
+var Analyzer = &analysis.Analyzer{
+	Name: "cleanup",
+	Doc:  Doc,
+	Run:  run,
+	Requires: []*analysis.Analyzer{
+		nodefilter.Analyzer,
+	},
+}
+
+var (
+	assigns = nodefilter.New(true, new(ast.AssignStmt))
+	lists   = nodefilter.New(false, new(ast.BlockStmt), new(ast.CaseClause), new(ast.CommClause))
+	defers  = nodefilter.New(true, new(ast.DeferStmt))
+)
+
+// An acquisition is an assignment of a resource and an error.
+type acquisition struct {
+	res  types.Object
+	err  types.Object
+	body *ast.BlockStmt // of the enclosing function
+	pos  token.Pos
+}
+
+func run(pass *analysis.Pass) (interface{}, error) {
+	nodes := pass.ResultOf[nodefilter.Analyzer].(*nodefilter.Result)
+
+	byStmt := make(map[*ast.AssignStmt]*acquisition)
+	byRes := make(map[types.Object]*acquisition)
+	for _, n := range nodes.Nodes(assigns) {
+		as := n.Node.(*ast.AssignStmt)
+		acq := acquire(pass, as)
+		if acq == nil {
+			continue
+		}
+		_, acq.body = analysisutil.EnclosingFunc(n.Stack)
+		byStmt[as] = acq
+		if byRes[acq.res] == nil {
+			byRes[acq.res] = acq
+		}
+	}
+
+	for _, n := range nodes.Nodes(lists) {
+		var stmts []ast.Stmt
+		switch n := n.Node.(type) {
+		case *ast.BlockStmt:
+			stmts = n.List
+		case *ast.CaseClause:
+			stmts = n.Body
+		case *ast.CommClause:
+			stmts = n.Body
+		}
+		for i, s := range stmts {
+			if as, ok := s.(*ast.AssignStmt); ok && byStmt[as] != nil {
+				checkFailed(pass, byStmt[as], stmts[i+1:])
+			}
+		}
+		checkOrder(pass, byRes, stmts)
+	}
+
+	for _, n := range nodes.Nodes(defers) {
+		d := n.Node.(*ast.DeferStmt)
+		lit, ok := d.Call.Fun.(*ast.FuncLit)
+		if !ok {
+			continue
+		}
+		if typ, _ := analysisutil.EnclosingFunc(n.Stack); typ != nil {
+			checkClobber(pass, typ, lit)
+		}
+	}
+	return nil, nil
+}
+
+// acquire returns the acquisition of a resource by as, if any.
+func acquire(pass *analysis.Pass, as *ast.AssignStmt) *acquisition {
+	if len(as.Rhs) != 1 || len(as.Lhs) < 2 {
+		return nil
+	}
+	if _, ok := analysisutil.Unparen(as.Rhs[0]).(*ast.CallExpr); !ok {
+		return nil
+	}
+	res := analysisutil.ObjectOf(pass.TypesInfo, as.Lhs[0])
+	err := analysisutil.ObjectOf(pass.TypesInfo, as.Lhs[len(as.Lhs)-1])
+	if res == nil || err == nil || err.Name() == "_" || !closable(res.Type()) || !isError(err.Type()) {
+		return nil
+	}
+	return &acquisition{res: res, err: err, pos: as.Pos()}
+}
+
+// closable returns whether t has a Close method without arguments.
+func closable(t types.Type) bool {
+	obj, _, _ := types.LookupFieldOrMethod(t, true, nil, "Close")
+	fn, ok := obj.(*types.Func)
+	return ok && fn.Type().(*types.Signature).Params().Len() == 0
+}
+
+func isError(t types.Type) bool {
+	return types.Identical(t, types.Universe.Lookup("error").Type())
+}
+
+// closeRecv returns the receiver of call, if it calls a Close method.
+func closeRecv(pass *analysis.Pass, call *ast.CallExpr) ast.Expr {
+	sel, ok := analysisutil.Unparen(call.Fun).(*ast.SelectorExpr)
+	if !ok || sel.Sel.Name != "Close" || len(call.Args) != 0 {
+		return nil
+	}
+	if _, ok := pass.TypesInfo.Uses[sel.Sel].(*types.Func); !ok {
+		return nil
+	}
+	return sel.X
+}
+
+// closed returns the variable closed by call, if it closes one.
+func closed(pass *analysis.Pass, call *ast.CallExpr) types.Object {
+	if recv := closeRecv(pass, call); recv != nil {
+		return analysisutil.ObjectOf(pass.TypesInfo, recv)
+	}
+	return nil
+}
+
+// closes returns whether n contains a call closing res.
+func closes(pass *analysis.Pass, n ast.Node, res types.Object) bool {
+	found := false
+	ast.Inspect(n, func(n ast.Node) bool {
+		if call, ok := n.(*ast.CallExpr); ok && closed(pass, call) == res {
+			found = true
+		}
+		return !found
+	})
+	return found
+}
+
+// uses returns whether n refers to obj.
+func uses(pass *analysis.Pass, n ast.Node, obj types.Object) bool {
+	found := false
+	ast.Inspect(n, func(n ast.Node) bool {
+		if id, ok := n.(*ast.Ident); ok && pass.TypesInfo.Uses[id] == obj {
+			found = true
+		}
+		return !found
+	})
+	return found
+}
+
+// checkFailed checks the statements following acq, up to the check of its
+// error, for cleanup of the resource.
+func checkFailed(pass *analysis.Pass, acq *acquisition, rest []ast.Stmt) {
+	name := acq.res.Name()
+	for _, s := range rest {
+		switch s := s.(type) {
+		case *ast.DeferStmt:
+			if closes(pass, s, acq.res) {
+				pass.Reportf(s.Pos(), "%s.Close is deferred before the error of acquiring %s is checked; if that failed, %s is not valid, so defer it after the check", name, name, name)
+				return
+			}
+		case *ast.IfStmt:
+			if !uses(pass, s.Cond, acq.err) {
+				break
+			}
+			ast.Inspect(s.Body, func(n ast.Node) bool {
+				if call, ok := n.(*ast.CallExpr); ok && closed(pass, call) == acq.res {
+					pass.Reportf(call.Pos(), "%s is closed although acquiring it failed; it is not valid, so only clean up resources acquired before", name)
+				}
+				return true
+			})
+			return
+		case *ast.AssignStmt:
+			for _, l := range s.Lhs {
+				if analysisutil.ObjectOf(pass.TypesInfo, l) == acq.err {
+					return
+				}
+			}
+		}
+	}
+}
+
+// A closeStmt is a statement closing a resource.
+type closeStmt struct {
+	res  types.Object
+	stmt ast.Stmt
+}
+
+// checkOrder checks that the resources closed by stmts are closed in reverse
+// order of acquisition. Deferred closes run in reverse order.
+func checkOrder(pass *analysis.Pass, byRes map[types.Object]*acquisition, stmts []ast.Stmt) {
+	var explicit, deferred []closeStmt
+	for _, s := range stmts {
+		var call *ast.CallExpr
+		switch s := s.(type) {
+		case *ast.ExprStmt:
+			call, _ = analysisutil.Unparen(s.X).(*ast.CallExpr)
+		case *ast.AssignStmt:
+			if len(s.Rhs) == 1 {
+				call, _ = analysisutil.Unparen(s.Rhs[0]).(*ast.CallExpr)
+			}
+		case *ast.IfStmt:
+			if as, ok := s.Init.(*ast.AssignStmt); ok && len(as.Rhs) == 1 {
+				call, _ = analysisutil.Unparen(as.Rhs[0]).(*ast.CallExpr)
+			}
+		case *ast.DeferStmt:
+			if res := closed(pass, s.Call); res != nil && byRes[res] != nil {
+				deferred = append([]closeStmt{{res, s}}, deferred...)
+			}
+			continue
+		}
+		if call == nil {
+			continue
+		}
+		if res := closed(pass, call); res != nil && byRes[res] != nil {
+			explicit = append(explicit, closeStmt{res, s})
+		}
+	}
+	for _, cs := range [][]closeStmt{explicit, deferred} {
+		for i := 1; i < len(cs); i++ {
+			first, second := byRes[cs[i-1].res], byRes[cs[i].res]
+			if first.body != second.body || first.pos >= second.pos {
+				continue
+			}
+			pass.Report(analysis.Diagnostic{
+				Pos:      cs[i-1].stmt.Pos(),
+				Category: "order",
+				Message:  first.res.Name() + " is closed before " + second.res.Name() + ", which was acquired after it; close resources in reverse order of acquisition",
+			})
+			break
+		}
+	}
+}
+
+// checkClobber checks the deferred function lit for assignments of the
+// error of a Close to the error result of the enclosing function typ.
+func checkClobber(pass *analysis.Pass, typ *ast.FuncType, lit *ast.FuncLit) {
+	var result types.Object
+	if typ.Results != nil {
+		for _, f := range typ.Results.List {
+			for _, name := range f.Names {
+				if obj := pass.TypesInfo.Defs[name]; obj != nil && isError(obj.Type()) {
+					result = obj
+				}
+			}
+		}
+	}
+	if result == nil {
+		return
+	}
+	// Errors of a Close, stored in variables.
+	closeErrs := make(map[types.Object]bool)
+	isClose := func(e ast.Expr) bool {
+		if call, ok := analysisutil.Unparen(e).(*ast.CallExpr); ok {
+			return closeRecv(pass, call) != nil
+		}
+		return closeErrs[analysisutil.ObjectOf(pass.TypesInfo, e)]
+	}
+
+	var visit func(n ast.Node, guarded bool)
+	visit = func(n ast.Node, guarded bool) {
+		ast.Inspect(n, func(n ast.Node) bool {
+			switch n := n.(type) {
+			case *ast.FuncLit:
+				return n == lit
+			case *ast.IfStmt:
+				if n.Init != nil {
+					visit(n.Init, guarded)
+				}
+				visit(n.Body, guarded || checksNil(pass, n.Cond, result))
+				if n.Else != nil {
+					visit(n.Else, guarded)
+				}
+				return false
+			case *ast.AssignStmt:
+				if len(n.Lhs) != len(n.Rhs) {
+					break
+				}
+				for i, l := range n.Lhs {
+					obj := analysisutil.ObjectOf(pass.TypesInfo, l)
+					if !isClose(n.Rhs[i]) || obj == nil {
+						continue
+					}
+					if obj != result {
+						closeErrs[obj] = true
+					} else if !guarded {
+						pass.Report(analysis.Diagnostic{
+							Pos:      n.Pos(),
+							Category: "clobber",
+							Message:  "the error of a Close replaces the error returned by the function, even if that is not nil; only assign it if " + result.Name() + " is nil",
+						})
+					}
+				}
+			}
+			return true
+		})
+	}
+	visit(lit.Body, false)
+}
+
+// checksNil returns whether cond is true only if err is nil.
+func checksNil(pass *analysis.Pass, cond ast.Expr, err types.Object) bool {
+	switch e := analysisutil.Unparen(cond).(type) {
+	case *ast.BinaryExpr:
+		switch e.Op {
+		case token.LAND:
+			return checksNil(pass, e.X, err) || checksNil(pass, e.Y, err)
+		case token.EQL:
+			x, y := e.X, e.Y
+			if analysisutil.ObjectOf(pass.TypesInfo, y) == err {
+				x, y = y, x
+			}
+			return analysisutil.ObjectOf(pass.TypesInfo, x) == err && pass.TypesInfo.Types[y].IsNil()
+		}
+	}
+	return false
+}
@@ -0,0 +1,155 @@
+package a
+
+import (
+	"database/sql"
+	"errors"
+	"os"
+)
+
+func deferBeforeCheck(name string) error {
+	f, err := os.Open(name)
+	defer f.Close() // want `f.Close is deferred before the error of acquiring f is checked`
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func deferAfterCheck(name string) error {
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return nil
+}
+
+func closeFailed(a, b string) error {
+	f, err := os.Open(a)
+	if err != nil {
+		f.Close() // want `f is closed although acquiring it failed`
+		return err
+	}
+	g, err := os.Open(b)
+	if err != nil {
+		f.Close()
+		return err
+	}
+	g.Close()
+	f.Close()
+	return nil
+}
+
+func explicitOrder(a, b string) {
+	f, err := os.Open(a)
+	if err != nil {
+		return
+	}
+	g, err := os.Open(b)
+	if err != nil {
+		f.Close()
+		return
+	}
+	f.Close() // want `f is closed before g, which was acquired after it`
+	g.Close()
+}
+
+func deferOrder(dsn string) error {
+	db, err := sql.Open("driver", dsn)
+	if err != nil {
+		return err
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		db.Close()
+		return err
+	}
+	defer tx.Rollback()
+	conn, err := db.Conn(nil)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	defer db.Close() // want `db is closed before conn, which was acquired after it`
+	return nil
+}
+
+func deferInOrder(a, b string) {
+	f, err := os.Open(a)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	g, err := os.Open(b)
+	if err != nil {
+		return
+	}
+	defer g.Close()
+}
+
+func blank(name string) {
+	f, _ := os.Open(name)
+	defer f.Close()
+}
+
+func deferredFuncOrder(a, b string) {
+	f, err := os.Open(a)
+	if err != nil {
+		return
+	}
+	g, err := os.Open(b)
+	if err != nil {
+		f.Close()
+		return
+	}
+	defer func() {
+		f.Close() // want `f is closed before g, which was acquired after it`
+		g.Close()
+	}()
+}
+
+func clobber(name string) (err error) {
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		err = f.Close() // want `the error of a Close replaces the error returned by the function`
+	}()
+	_, err = f.Write([]byte("x"))
+	return err
+}
+
+func clobberVar(name string) (err error) {
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if cerr := f.Close(); cerr != nil {
+			err = cerr // want `the error of a Close replaces the error returned by the function`
+		}
+	}()
+	return nil
+}
+
+func guarded(name string) (err error) {
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if cerr := f.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}()
+	defer func() {
+		if err == nil {
+			err = f.Close()
+		}
+	}()
+	defer func() {
+		err = errors.New(err.Error() + f.Close().Error())
+	}()
+	return nil
+}
@@ -0,0 +1,262 @@
+// Copyright 2019 Axel Wagner
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package clone defines an Analyzer that checks for duplicated blocks of
+// code.
+package clone
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"go/ast"
+	"go/types"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/Merovius/go-tools/internal/analysisutil"
+	"github.com/Merovius/go-tools/internal/gomod"
+	"github.com/Merovius/go-tools/internal/nodefilter"
+	"golang.org/x/tools/go/analysis"
+)
+
+const Doc = `check for duplicated blocks of code
+
+The analyzer reports blocks which duplicate another block, in the same
+package or in one of its dependencies in the same module. Blocks of other
+modules and the standard library are not considered, as a shared function
+can't be extracted from them. Blocks are compared by their syntax
+tree, with the names of local variables, constants and types and the values
+of literals ignored, but their types taken into account. Only blocks with at
+least as many syntax nodes as given by the -min-tokens flag are compared and
+only the outermost duplicated block is reported.
+
+Duplicated code has to be changed in several places, which is easily
+forgotten. Extracting it into a function avoids that. Test files are not
+checked, as tests are often repetitive on purpose.`
+
+var Analyzer = &analysis.Analyzer{
+	Name: "clone",
+	Doc:  Doc,
+	Run:  run,
+	Requires: []*analysis.Analyzer{
+		nodefilter.Analyzer,
+	},
+	FactTypes: []analysis.Fact{new(blocks)},
+}
+
+var minTokens = 50
+
+func init() {
+	Analyzer.Flags.IntVar(&minTokens, "min-tokens", minTokens, "minimum number of syntax nodes of compared blocks")
+}
+
+var stmts = nodefilter.New(true, new(ast.BlockStmt))
+
+// blocks is a package fact listing the hashes of the blocks of a package,
+// for comparison with the blocks of packages importing it.
+type blocks struct {
+	Module string // path of the module of the package, if it has one
+	Blocks []blockHash
+}
+
+// A blockHash is the hash of a normalized block.
+type blockHash struct {
+	Hash string
+	Pos  string // like "example.com/pkg/file.go:12"
+}
+
+func (*blocks) AFact() {}
+
+func (b *blocks) String() string { return strconv.Itoa(len(b.Blocks)) + " blocks" }
+
+// A block is a block of the package with its hash.
+type block struct {
+	node  *ast.BlockStmt
+	stack []ast.Node
+	hash  string
+}
+
+func run(pass *analysis.Pass) (interface{}, error) {
+	if analysisutil.IsStd(pass.Pkg.Path()) || len(pass.Files) == 0 {
+		return nil, nil
+	}
+	nodes := pass.ResultOf[nodefilter.Analyzer].(*nodefilter.Result)
+	mod, err := gomod.Find(filepath.Dir(pass.Fset.File(pass.Files[0].Pos()).Name()))
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		own    []*block
+		byHash = make(map[string][]*block)
+		fact   blocks
+	)
+	if mod != nil {
+		fact.Module = mod.Module
+	}
+	for _, n := range nodes.Nodes(stmts) {
+		b := n.Node.(*ast.BlockStmt)
+		if analysisutil.IsTestFile(pass, b.Pos()) || analysisutil.IsGenerated(pass, b.Pos()) {
+			continue
+		}
+		hash, tokens := normalize(pass, b)
+		if tokens < minTokens {
+			continue
+		}
+		blk := &block{b, n.Stack, hash}
+		own = append(own, blk)
+		byHash[hash] = append(byHash[hash], blk)
+		fact.Blocks = append(fact.Blocks, blockHash{hash, position(pass, b)})
+	}
+	if len(fact.Blocks) > 0 {
+		pass.ExportPackageFact(&fact)
+	}
+
+	// other maps hashes of blocks of dependencies in the module to their
+	// positions.
+	other := make(map[string]string)
+	for _, f := range pass.AllPackageFacts() {
+		bs, ok := f.Fact.(*blocks)
+		if !ok || f.Package == pass.Pkg || bs.Module != fact.Module {
+			continue
+		}
+		for _, b := range bs.Blocks {
+			if p, ok := other[b.Hash]; !ok || b.Pos < p {
+				other[b.Hash] = b.Pos
+			}
+		}
+	}
+
+	cloned := make(map[ast.Node]bool)
+	for _, b := range own {
+		if len(byHash[b.hash]) > 1 || other[b.hash] != "" {
+			cloned[b.node] = true
+		}
+	}
+	for _, b := range own {
+		if !cloned[b.node] || inCloned(b.stack, cloned) {
+			continue
+		}
+		if p := other[b.hash]; p != "" {
+			pass.Reportf(b.node.Pos(), "block duplicates the one at %s; extract a function", p)
+			continue
+		}
+		// The first of the duplicated blocks of the package is the
+		// original.
+		first := byHash[b.hash][0]
+		if first == b {
+			continue
+		}
+		pass.Reportf(b.node.Pos(), "block duplicates the one at %s; extract a function", shortPos(pass, first.node))
+	}
+	return nil, nil
+}
+
+// inCloned returns whether a block enclosing the one at the top of stack is
+// cloned.
+func inCloned(stack []ast.Node, cloned map[ast.Node]bool) bool {
+	for _, n := range stack[:len(stack)-1] {
+		if cloned[n] {
+			return true
+		}
+	}
+	return false
+}
+
+func position(pass *analysis.Pass, n ast.Node) string {
+	posn := pass.Fset.Position(n.Pos())
+	return analysisutil.TrimVendor(pass.Pkg.Path()) + "/" + filepath.Base(posn.Filename) + ":" + strconv.Itoa(posn.Line)
+}
+
+func shortPos(pass *analysis.Pass, n ast.Node) string {
+	posn := pass.Fset.Position(n.Pos())
+	return filepath.Base(posn.Filename) + ":" + strconv.Itoa(posn.Line)
+}
+
+// normalize returns the hash of the normalized syntax tree of b and the
+// number of its nodes.
+func normalize(pass *analysis.Pass, b *ast.BlockStmt) (hash string, tokens int) {
+	var (
+		buf    strings.Builder
+		locals = make(map[types.Object]int)
+		qual   = func(p *types.Package) string { return analysisutil.TrimVendor(p.Path()) }
+	)
+	ast.Inspect(b, func(n ast.Node) bool {
+		if n == nil {
+			buf.WriteString(")")
+			return true
+		}
+		tokens++
+		fmt.Fprintf(&buf, "(%T", n)
+		switch n := n.(type) {
+		case *ast.Ident:
+			buf.WriteString(" " + identName(pass, n, locals))
+			if t := pass.TypesInfo.TypeOf(n); t != nil {
+				buf.WriteString(" " + types.TypeString(t, qual))
+			}
+		case *ast.BasicLit:
+			buf.WriteString(" " + n.Kind.String())
+		case *ast.BinaryExpr:
+			buf.WriteString(" " + n.Op.String())
+		case *ast.UnaryExpr:
+			buf.WriteString(" " + n.Op.String())
+		case *ast.AssignStmt:
+			buf.WriteString(" " + n.Tok.String())
+		case *ast.IncDecStmt:
+			buf.WriteString(" " + n.Tok.String())
+		case *ast.BranchStmt:
+			buf.WriteString(" " + n.Tok.String())
+		case *ast.ChanType:
+			buf.WriteString(" " + strconv.Itoa(int(n.Dir)))
+		}
+		return true
+	})
+	sum := sha256.Sum256([]byte(buf.String()))
+	return hex.EncodeToString(sum[:8]), tokens
+}
+
+// identName returns the normalized name of id. Local objects are numbered
+// in the order of their first use, other objects are qualified by their
+// package.
+func identName(pass *analysis.Pass, id *ast.Ident, locals map[types.Object]int) string {
+	obj := pass.TypesInfo.ObjectOf(id)
+	switch obj := obj.(type) {
+	case nil:
+		return id.Name
+	case *types.PkgName:
+		return "pkg " + obj.Imported().Path()
+	case *types.Var:
+		if obj.IsField() {
+			return "field " + obj.Name()
+		}
+	case *types.Func:
+		if obj.Pkg() != nil {
+			return analysisutil.FuncName(obj)
+		}
+	}
+	if obj.Pkg() == pass.Pkg && obj.Parent() != nil && obj.Parent() != pass.Pkg.Scope() && obj.Parent() != types.Universe {
+		i, ok := locals[obj]
+		if !ok {
+			i = len(locals)
+			locals[obj] = i
+		}
+		return "local " + strconv.Itoa(i)
+	}
+	if obj.Pkg() == nil {
+		return obj.Name()
+	}
+	return analysisutil.TrimVendor(obj.Pkg().Path()) + "." + obj.Name()
+}
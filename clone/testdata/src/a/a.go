@@ -0,0 +1,70 @@
+package a // want package:"5 blocks"
+
+import (
+	"b"
+	"example.com/lib"
+	"strings"
+)
+
+var (
+	_ = b.Sum
+	_ = lib.Product
+)
+
+func join(names []string) string {
+	var parts []string
+	for _, n := range names {
+		if n == "" {
+			continue
+		}
+		parts = append(parts, strings.ToUpper(n))
+	}
+	return strings.Join(parts, ",")
+}
+
+func joinOther(list []string) string { // want `block duplicates the one at a.go:14; extract a function`
+	var out []string
+	for _, s := range list {
+		if s == "" {
+			continue
+		}
+		out = append(out, strings.ToUpper(s))
+	}
+	return strings.Join(out, ",")
+}
+
+func joinLower(names []string) string {
+	var parts []string
+	for _, n := range names {
+		if n == "" {
+			continue
+		}
+		parts = append(parts, strings.ToLower(n))
+	}
+	return strings.Join(parts, ",")
+}
+
+func alternate(vals []int) int { // want `block duplicates the one at b/b.go:3; extract a function`
+	sum := 0
+	for j, v := range vals {
+		if j%2 == 0 {
+			sum += v
+		} else {
+			sum -= v
+		}
+	}
+	return sum
+}
+
+// Blocks of other modules can't be shared.
+func product(vals []int) int {
+	p := 1
+	for j, v := range vals {
+		if j%3 == 0 {
+			p *= v
+		} else {
+			p /= v
+		}
+	}
+	return p
+}
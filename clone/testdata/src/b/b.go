@@ -0,0 +1,13 @@
+package b // want package:"1 blocks"
+
+func Sum(xs []int) int {
+	total := 0
+	for i, x := range xs {
+		if i%2 == 0 {
+			total += x
+		} else {
+			total -= x
+		}
+	}
+	return total
+}
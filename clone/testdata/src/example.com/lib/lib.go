@@ -0,0 +1,13 @@
+package lib
+
+func Product(xs []int) int {
+	p := 1
+	for i, x := range xs {
+		if i%3 == 0 {
+			p *= x
+		} else {
+			p /= x
+		}
+	}
+	return p
+}
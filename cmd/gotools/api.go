@@ -0,0 +1,81 @@
+// Copyright 2019 Axel Wagner
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/Merovius/go-tools/internal/apisnap"
+	"golang.org/x/tools/go/packages"
+)
+
+func apiCmd(args []string) int {
+	fs := flag.NewFlagSet("api", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: gotools api [flags] [packages]")
+		fmt.Fprintln(os.Stderr)
+		fmt.Fprintln(os.Stderr, "Writes a snapshot of the exported API of the packages, for the -api flag of")
+		fmt.Fprintln(os.Stderr, "the since analyzer. Take it when tagging a release and commit it.")
+		fmt.Fprintln(os.Stderr)
+		fs.PrintDefaults()
+	}
+	out := fs.String("o", "", "`file` to write the snapshot to, instead of standard output")
+	fs.Parse(args)
+	patterns := fs.Args()
+	if len(patterns) == 0 {
+		patterns = []string{"./..."}
+	}
+
+	pkgs, err := packages.Load(&packages.Config{Mode: packages.LoadTypes}, patterns...)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "gotools:", err)
+		return 1
+	}
+	s := make(apisnap.Snapshot)
+	for _, p := range pkgs {
+		if len(p.Errors) > 0 {
+			fmt.Fprintln(os.Stderr, "gotools:", p.Errors[0])
+			return 1
+		}
+		if apisnap.Public(p.Types) {
+			s.Add(p.Types)
+		}
+	}
+
+	if *out == "" {
+		err = s.Write(os.Stdout)
+	} else {
+		err = writeSnapshot(*out, s)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "gotools:", err)
+		return 1
+	}
+	return 0
+}
+
+func writeSnapshot(name string, s apisnap.Snapshot) error {
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	if err := s.Write(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
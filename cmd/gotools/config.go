@@ -0,0 +1,185 @@
+// Copyright 2019 Axel Wagner
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/Merovius/go-tools/internal/config"
+	"github.com/Merovius/go-tools/internal/driver"
+	"github.com/Merovius/go-tools/internal/filter"
+	"golang.org/x/tools/go/analysis"
+)
+
+func configCmd(args []string) int {
+	usage := func() {
+		fmt.Fprintln(os.Stderr, "usage: gotools config schema")
+		fmt.Fprintln(os.Stderr)
+		fmt.Fprintln(os.Stderr, "Prints a JSON Schema of the "+config.FileName+" configuration file.")
+	}
+	if len(args) != 1 || args[0] != "schema" {
+		usage()
+		return 2
+	}
+	b, err := config.Schema(analyzers)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "gotools:", err)
+		return 1
+	}
+	fmt.Printf("%s\n", b)
+	return 0
+}
+
+// loadFilter parses the filter expr, or the filter of cfg if expr is empty.
+// It returns nil if there is no filter.
+func loadFilter(expr string, cfg *config.Config) (*filter.Filter, error) {
+	if expr == "" {
+		expr = cfg.Filter
+	}
+	if expr == "" {
+		return nil, nil
+	}
+	return filter.Parse(expr, cfg.Dir)
+}
+
+// configFlags registers the -config flag and the namespaced flags of all
+// analyzers.
+type configFlags struct {
+	file  string
+	tests config.Tests
+}
+
+func (cf *configFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&cf.file, "config", "", "configuration `file` (default "+config.FileName+" in the current directory or its parents)")
+	cf.tests = config.RegisterFlags(fs, analyzers)
+}
+
+// load loads the configuration and sets the analyzer flags configured in
+// it, unless they were set in the already parsed fs. If opts is not nil,
+// the analyzers whose diagnostics in test files are dropped are set in it,
+// as well as the configured targets, unless there are targets already.
+func (cf *configFlags) load(fs *flag.FlagSet, opts *driver.Options) (*config.Config, error) {
+	var (
+		cfg *config.Config
+		err error
+	)
+	if cf.file != "" {
+		cfg, err = config.Load(cf.file)
+	} else {
+		cfg, err = config.Find(".")
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := cfg.ApplyFlags(fs); err != nil {
+		return nil, err
+	}
+	if opts != nil {
+		opts.SkipTests = cf.tests.Skip()
+		if len(opts.Targets) == 0 {
+			for _, s := range cfg.Targets {
+				t, err := driver.ParseTarget(s)
+				if err != nil {
+					return nil, fmt.Errorf("%s: %v", config.FileName, err)
+				}
+				opts.Targets = append(opts.Targets, t)
+			}
+		}
+		if err := setTargets(opts.Targets); err != nil {
+			return nil, err
+		}
+	}
+	return cfg, nil
+}
+
+// setTargets sets the targets flag of analyzers checking code against the
+// build configurations, like buildtags, unless it was set explicitly.
+func setTargets(ts driver.Targets) error {
+	if len(ts) == 0 {
+		return nil
+	}
+	for _, a := range analyzers {
+		if f := a.Flags.Lookup("targets"); f != nil && f.Value.String() == "" {
+			if err := f.Value.Set(ts.String()); err != nil {
+				return fmt.Errorf("%s.targets: %v", a.Name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// loadPlugins adds the analyzers of the plugins listed in the configuration
+// to analyzers. Their flags have to be registered before the command line is
+// parsed, so the configuration file given with -config is looked up in args
+// directly.
+func loadPlugins(args []string) error {
+	var (
+		cfg *config.Config
+		err error
+	)
+	if file := configArg(args); file != "" {
+		cfg, err = config.Load(file)
+	} else {
+		cfg, err = config.Find(".")
+	}
+	if err != nil || len(cfg.Plugins) == 0 {
+		// Errors in the configuration are reported by the command.
+		return nil
+	}
+	names := make(map[string]bool)
+	for _, a := range analyzers {
+		names[a.Name] = true
+	}
+	suite := append([]*analysis.Analyzer(nil), analyzers...)
+	for _, p := range cfg.Plugins {
+		as, err := driver.LoadPlugin(cfg.Path(p))
+		if err != nil {
+			return fmt.Errorf("loading plugin: %v", err)
+		}
+		for _, a := range as {
+			if names[a.Name] {
+				return fmt.Errorf("plugin %s: duplicate analyzer %q", p, a.Name)
+			}
+			names[a.Name] = true
+			suite = append(suite, a)
+		}
+	}
+	analyzers = suite
+	return nil
+}
+
+// configArg returns the value of the -config flag in args, or "" if it is
+// not given.
+func configArg(args []string) string {
+	for i, arg := range args {
+		if arg == "--" {
+			break
+		}
+		name := strings.TrimLeft(arg, "-")
+		if len(name) == len(arg) || len(arg)-len(name) > 2 {
+			continue
+		}
+		if name == "config" && i+1 < len(args) {
+			return args[i+1]
+		}
+		if strings.HasPrefix(name, "config=") {
+			return name[len("config="):]
+		}
+	}
+	return ""
+}
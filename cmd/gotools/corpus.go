@@ -0,0 +1,130 @@
+// Copyright 2019 Axel Wagner
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/Merovius/go-tools/internal/corpus"
+	"golang.org/x/tools/go/analysis"
+)
+
+func corpusCmd(args []string) int {
+	fs := flag.NewFlagSet("corpus", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: gotools corpus [flags]")
+		fmt.Fprintln(os.Stderr)
+		fmt.Fprintln(os.Stderr, "Runs analyzers over a pinned list of modules and fails if there are findings")
+		fmt.Fprintln(os.Stderr, "not in the baseline.")
+		fmt.Fprintln(os.Stderr)
+		fs.PrintDefaults()
+	}
+	var (
+		modules  = fs.String("modules", "corpus/modules.json", "JSON `file` listing the modules to analyze")
+		baseline = fs.String("baseline", "corpus/baseline.json", "JSON `file` with the known findings")
+		names    = fs.String("analyzers", "", "comma-separated `list` of analyzers to run (default all)")
+		update   = fs.Bool("update", false, "write the current findings to the baseline")
+		workdir  = fs.String("workdir", "", "`directory` to extract modules to (default a temporary directory)")
+	)
+	fs.Parse(args)
+
+	as, err := selectAnalyzers(*names)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "gotools:", err)
+		return 2
+	}
+	c, err := corpus.Load(*modules)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "gotools:", err)
+		return 1
+	}
+	old := make(corpus.Baseline)
+	if !*update {
+		if old, err = corpus.ReadBaseline(*baseline); err != nil {
+			fmt.Fprintf(os.Stderr, "gotools: %v (use -update to create it)\n", err)
+			return 1
+		}
+	}
+	if *workdir == "" {
+		if *workdir, err = ioutil.TempDir("", "gotools-corpus"); err != nil {
+			fmt.Fprintln(os.Stderr, "gotools:", err)
+			return 1
+		}
+		defer os.RemoveAll(*workdir)
+	}
+
+	cur := make(corpus.Baseline)
+	failed := false
+	for _, m := range c.Modules {
+		dir, err := corpus.Fetch(m, *workdir)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "gotools:", err)
+			return 1
+		}
+		findings, err := corpus.Analyze(dir, as)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "gotools: %v: %v\n", m, err)
+			return 1
+		}
+		cur[m.String()] = findings
+		if *update {
+			continue
+		}
+		added, removed := corpus.Compare(old[m.String()], findings)
+		for _, f := range added {
+			fmt.Printf("%v: new finding: %v\n", m, f)
+			failed = true
+		}
+		if len(removed) > 0 {
+			fmt.Fprintf(os.Stderr, "%v: %d findings disappeared, run with -update to record this\n", m, len(removed))
+		}
+	}
+	if *update {
+		if err := cur.Write(*baseline); err != nil {
+			fmt.Fprintln(os.Stderr, "gotools:", err)
+			return 1
+		}
+		return 0
+	}
+	if failed {
+		return 1
+	}
+	return 0
+}
+
+// selectAnalyzers returns the analyzers with the given comma-separated
+// names, or all analyzers if names is empty.
+func selectAnalyzers(names string) ([]*analysis.Analyzer, error) {
+	if names == "" {
+		return analyzers, nil
+	}
+	byName := make(map[string]*analysis.Analyzer)
+	for _, a := range analyzers {
+		byName[a.Name] = a
+	}
+	var out []*analysis.Analyzer
+	for _, n := range strings.Split(names, ",") {
+		a, ok := byName[strings.TrimSpace(n)]
+		if !ok {
+			return nil, fmt.Errorf("unknown analyzer %q", n)
+		}
+		out = append(out, a)
+	}
+	return out, nil
+}
@@ -0,0 +1,211 @@
+// Copyright 2019 Axel Wagner
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/Merovius/go-tools/internal/driver"
+	"github.com/Merovius/go-tools/internal/gitdiff"
+)
+
+// hookHeader marks hooks written by "gotools hook install", so we don't
+// overwrite other hooks.
+const hookHeader = "# Installed by gotools hook install."
+
+func hookCmd(args []string) int {
+	usage := func() {
+		fmt.Fprintln(os.Stderr, "usage: gotools hook install [-type=pre-commit|pre-push] [-force]")
+		fmt.Fprintln(os.Stderr, "       gotools hook run [-type=pre-commit|pre-push]")
+	}
+	if len(args) == 0 {
+		usage()
+		return 2
+	}
+	switch args[0] {
+	case "install":
+		return hookInstall(args[1:])
+	case "run":
+		return hookRun(args[1:])
+	default:
+		usage()
+		return 2
+	}
+}
+
+func checkHookType(typ string) error {
+	if typ != "pre-commit" && typ != "pre-push" {
+		return fmt.Errorf("invalid hook type %q: must be pre-commit or pre-push", typ)
+	}
+	return nil
+}
+
+func hookInstall(args []string) int {
+	fs := flag.NewFlagSet("hook install", flag.ExitOnError)
+	typ := fs.String("type", "pre-commit", "type of the git `hook` to install (pre-commit or pre-push)")
+	force := fs.Bool("force", false, "overwrite an existing hook not installed by gotools")
+	fs.Parse(args)
+
+	if err := checkHookType(*typ); err != nil {
+		fmt.Fprintln(os.Stderr, "gotools:", err)
+		return 2
+	}
+	out, err := exec.Command("git", "rev-parse", "--git-path", "hooks").Output()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "gotools: can't find git hooks directory:", err)
+		return 1
+	}
+	dir := strings.TrimSpace(string(out))
+	if err := os.MkdirAll(dir, 0777); err != nil {
+		fmt.Fprintln(os.Stderr, "gotools:", err)
+		return 1
+	}
+	path := filepath.Join(dir, *typ)
+	if b, err := ioutil.ReadFile(path); err == nil && !strings.Contains(string(b), hookHeader) && !*force {
+		fmt.Fprintf(os.Stderr, "gotools: %s already exists, use -force to overwrite it\n", path)
+		return 1
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		exe = "gotools"
+	}
+	script := fmt.Sprintf("#!/bin/sh\n%s\nexec %q hook run -type=%s\n", hookHeader, exe, *typ)
+	if err := ioutil.WriteFile(path, []byte(script), 0777); err != nil {
+		fmt.Fprintln(os.Stderr, "gotools:", err)
+		return 1
+	}
+	fmt.Fprintln(os.Stderr, "installed", path)
+	return 0
+}
+
+// hookRun analyzes the packages containing changed files and reports
+// diagnostics on changed lines only. For pre-commit hooks, the changes are
+// those staged for commit, for pre-push hooks, those not yet in the
+// upstream branch. Files with changes not part of those are analyzed with
+// their staged or committed content, so the lines match.
+func hookRun(args []string) int {
+	fs := flag.NewFlagSet("hook run", flag.ExitOnError)
+	typ := fs.String("type", "pre-commit", "type of the git `hook` being run (pre-commit or pre-push)")
+	var cf configFlags
+	cf.register(fs)
+	fs.Parse(args)
+
+	if err := checkHookType(*typ); err != nil {
+		fmt.Fprintln(os.Stderr, "gotools:", err)
+		return 2
+	}
+	var (
+		diffArgs []string
+		rev      string // revision analyzed, the index if empty
+	)
+	if *typ == "pre-commit" {
+		diffArgs = []string{"--cached"}
+	} else {
+		rev = "HEAD"
+		out, err := exec.Command("git", "merge-base", "HEAD", "@{upstream}").Output()
+		if err != nil {
+			// Without an upstream, there is nothing to compare to. Don't
+			// block the push.
+			fmt.Fprintln(os.Stderr, "gotools: no upstream branch, skipping analysis")
+			return 0
+		}
+		diffArgs = []string{strings.TrimSpace(string(out)), "HEAD"}
+	}
+	changes, err := gitdiff.Diff(".", append(diffArgs, "--", "*.go")...)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "gotools:", err)
+		return 1
+	}
+
+	dirs := make(map[string]bool)
+	var patterns []string
+	for _, f := range changes.Files() {
+		d := filepath.Dir(f)
+		if isTestdata(d) {
+			continue
+		}
+		if !dirs[d] {
+			dirs[d] = true
+			patterns = append(patterns, d)
+		}
+	}
+	if len(patterns) == 0 {
+		return 0
+	}
+
+	overlay, err := gitdiff.Contents(".", rev, "*.go")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "gotools:", err)
+		return 1
+	}
+	cfg, err := cf.load(fs, nil)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "gotools:", err)
+		return 1
+	}
+	flt, err := loadFilter("", cfg)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "gotools:", err)
+		return 1
+	}
+	opts := &driver.Options{
+		Analyzers:     analyzers,
+		Tests:         true,
+		ReportIgnored: true,
+		SkipTests:     cf.tests.Skip(),
+		Overlay:       overlay,
+	}
+	diags, err := driver.Run(opts, patterns...)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "gotools:", err)
+		return 1
+	}
+	if diags, _, err = applySuppressions(diags, cfg, "", false, nil); err != nil {
+		fmt.Fprintln(os.Stderr, "gotools:", err)
+		return 1
+	}
+	if flt != nil {
+		diags = flt.Apply(diags)
+	}
+	n := 0
+	for _, d := range diags {
+		if changes.Contains(d.Posn.Filename, d.Posn.Line) {
+			fmt.Println(d)
+			n++
+		}
+	}
+	if n > 0 {
+		fmt.Fprintf(os.Stderr, "gotools: %d issues in changed lines, use --no-verify to bypass\n", n)
+		return 1
+	}
+	return 0
+}
+
+// isTestdata returns whether dir is in a testdata directory, which is
+// ignored by the go tool.
+func isTestdata(dir string) bool {
+	for _, el := range strings.Split(filepath.ToSlash(dir), "/") {
+		if el == "testdata" {
+			return true
+		}
+	}
+	return false
+}
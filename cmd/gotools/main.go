@@ -0,0 +1,88 @@
+// Copyright 2019 Axel Wagner
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Command gotools runs all analyzers of this repository in one invocation.
+//
+// Usage:
+//
+//	gotools <command> [flags] [arguments]
+//
+// Run "gotools help" for a list of commands.
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/Merovius/go-tools/internal/all"
+)
+
+// analyzers is the suite run by gotools, extended by the analyzers of the
+// configured plugins.
+var analyzers = all.Analyzers
+
+type command struct {
+	short string
+	run   func(args []string) int
+}
+
+var commands = map[string]command{
+	"api":           {"write a snapshot of the exported API of packages", apiCmd},
+	"config":        {"print the JSON Schema of the configuration file", configCmd},
+	"corpus":        {"check analyzers for new findings in a corpus of modules", corpusCmd},
+	"hook":          {"install or run a git hook analyzing changed code", hookCmd},
+	"merge-reports": {"combine JSON or SARIF reports of sharded runs", mergeReportsCmd},
+	"nogo-config":   {"print the default nogo configuration", nogoConfigCmd},
+	"rewrite":       {"apply the fixes of one analyzer to packages", rewriteCmd},
+	"run":           {"run analyzers on packages", runCmd},
+	"suppressions":  {"list suppressed findings with their age and author", suppressionsCmd},
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: gotools <command> [flags] [arguments]")
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "Commands:")
+	var names []string
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "\t%-13s %s\n", name, commands[name].short)
+	}
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+	name := os.Args[1]
+	if name == "help" || name == "-h" || name == "-help" {
+		usage()
+		return
+	}
+	cmd, ok := commands[name]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "gotools: unknown command %q\n", name)
+		usage()
+		os.Exit(2)
+	}
+	if err := loadPlugins(os.Args[2:]); err != nil {
+		fmt.Fprintln(os.Stderr, "gotools:", err)
+		os.Exit(1)
+	}
+	os.Exit(cmd.run(os.Args[2:]))
+}
@@ -0,0 +1,76 @@
+// Copyright 2019 Axel Wagner
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/Merovius/go-tools/internal/report"
+)
+
+func mergeReportsCmd(args []string) int {
+	fs := flag.NewFlagSet("merge-reports", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: gotools merge-reports [flags] files")
+		fmt.Fprintln(os.Stderr)
+		fmt.Fprintln(os.Stderr, "Combines reports written by \"gotools run -format=json\" or \"-format=sarif\",")
+		fmt.Fprintln(os.Stderr, "like the ones of runs with -shard, into one.")
+		fmt.Fprintln(os.Stderr)
+		fs.PrintDefaults()
+	}
+	out := fs.String("o", "", "`file` to write the merged report to, instead of standard output")
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return 2
+	}
+
+	var readers []io.Reader
+	for _, name := range fs.Args() {
+		f, err := os.Open(name)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "gotools:", err)
+			return 1
+		}
+		defer f.Close()
+		readers = append(readers, f)
+	}
+	var err error
+	if *out == "" {
+		err = report.Merge(os.Stdout, readers...)
+	} else {
+		err = writeMerged(*out, readers)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "gotools:", err)
+		return 1
+	}
+	return 0
+}
+
+func writeMerged(name string, readers []io.Reader) error {
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	if err := report.Merge(f, readers...); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
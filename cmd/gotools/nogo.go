@@ -0,0 +1,38 @@
+// Copyright 2019 Axel Wagner
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/Merovius/go-tools/nogoadapter"
+)
+
+func nogoConfigCmd(args []string) int {
+	fs := flag.NewFlagSet("nogo-config", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: gotools nogo-config")
+		fmt.Fprintln(os.Stderr)
+		fmt.Fprintln(os.Stderr, "Writes the default nogo configuration for all analyzers to stdout.")
+	}
+	fs.Parse(args)
+	if err := nogoadapter.DefaultConfig().Write(os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, "gotools:", err)
+		return 1
+	}
+	return 0
+}
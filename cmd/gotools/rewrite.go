@@ -0,0 +1,105 @@
+// Copyright 2019 Axel Wagner
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"go/token"
+	"os"
+
+	"github.com/Merovius/go-tools/internal/driver"
+	"github.com/Merovius/go-tools/internal/fix"
+	"golang.org/x/tools/go/analysis"
+)
+
+func rewriteCmd(args []string) int {
+	fs := flag.NewFlagSet("rewrite", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: gotools rewrite -analyzer=name [flags] [packages]")
+		fmt.Fprintln(os.Stderr)
+		fmt.Fprintln(os.Stderr, "Applies the suggested fixes of a single analyzer in one pass, re-resolving")
+		fmt.Fprintln(os.Stderr, "imports and formatting the modified files.")
+		fmt.Fprintln(os.Stderr)
+		fs.PrintDefaults()
+	}
+	var (
+		opts    driver.Options
+		cf      configFlags
+		targets driver.Targets
+		name    = fs.String("analyzer", "", "`name` of the analyzer whose fixes to apply")
+		level   = fix.Safe
+	)
+	fs.BoolVar(&opts.Tests, "tests", true, "also rewrite test files")
+	fs.Var(&targets, "target", "analyze in the build configuration `goos/goarch[:tags]` (repeatable)")
+	fs.Var(&level, "fix-level", "minimum safety `level` of fixes to apply (safe, unverified or unsafe)")
+	cf.register(fs)
+	fs.Parse(args)
+
+	var a *analysis.Analyzer
+	for _, b := range analyzers {
+		if b.Name == *name {
+			a = b
+		}
+	}
+	if a == nil {
+		fmt.Fprintf(os.Stderr, "gotools: unknown analyzer %q\n", *name)
+		fs.Usage()
+		return 2
+	}
+	opts.Analyzers = []*analysis.Analyzer{a}
+	opts.Targets = targets
+	if _, err := cf.load(fs, &opts); err != nil {
+		fmt.Fprintln(os.Stderr, "gotools:", err)
+		return 1
+	}
+	patterns := fs.Args()
+	if len(patterns) == 0 {
+		patterns = []string{"./..."}
+	}
+
+	diags, err := driver.Run(&opts, patterns...)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "gotools:", err)
+		return 1
+	}
+	var (
+		fixes []fix.Fix
+		posns []token.Position
+	)
+	for _, d := range diags {
+		if len(d.Fixes) > 0 {
+			fixes = append(fixes, d.Fixes[0])
+			posns = append(posns, d.Posn)
+		}
+	}
+	res, err := fix.Apply(fixes, fix.Options{Level: level, Imports: true})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "gotools:", err)
+		return 1
+	}
+
+	fmt.Printf("applied %d fixes in %d files\n", res.Applied, len(res.Files))
+	for _, f := range res.Files {
+		fmt.Printf("\t%s\n", f)
+	}
+	if len(res.Skipped) > 0 {
+		fmt.Printf("skipped %d fixes\n", len(res.Skipped))
+		for _, s := range res.Skipped {
+			fmt.Printf("\t%v: %s: %s\n", posns[s.Index], s.Fix.Message, s.Reason)
+		}
+	}
+	return 0
+}
@@ -0,0 +1,396 @@
+// Copyright 2019 Axel Wagner
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Merovius/go-tools/internal/blame"
+	"github.com/Merovius/go-tools/internal/config"
+	"github.com/Merovius/go-tools/internal/coverage"
+	"github.com/Merovius/go-tools/internal/driver"
+	"github.com/Merovius/go-tools/internal/filter"
+	"github.com/Merovius/go-tools/internal/fix"
+	"github.com/Merovius/go-tools/internal/hotspot"
+	"github.com/Merovius/go-tools/internal/owners"
+	"github.com/Merovius/go-tools/internal/posindex"
+	"github.com/Merovius/go-tools/internal/report"
+	"github.com/Merovius/go-tools/internal/suppress"
+)
+
+func runCmd(args []string) int {
+	fs := flag.NewFlagSet("run", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: gotools run [flags] [packages]")
+		fs.PrintDefaults()
+	}
+	var (
+		opts          = driver.Options{ReportIgnored: true}
+		targets       driver.Targets
+		facts         stringList
+		doFix         bool
+		level         = fix.Safe
+		cf            configFlags
+		baseline      string
+		writeBaseline bool
+		format        = report.Text
+		filterExpr    string
+		profile       string
+		hotThreshold  float64
+		hotFilter     string
+		coverProfile  string
+		doBlame       bool
+		splitOwners   string
+		overlay       string
+	)
+	fs.BoolVar(&opts.Tests, "tests", true, "also analyze test files")
+	fs.Var(&targets, "target", "analyze in the build configuration `goos/goarch[:tags]` (repeatable)")
+	fs.Var(&facts, "facts-in", "import facts for dependencies from `file` (repeatable)")
+	fs.IntVar(&opts.MaxIssues, "max-issues", 0, "stop the analysis after `n` findings and only report those")
+	fs.DurationVar(&opts.PackageTimeout, "timeout-per-package", 0, "abandon analyzers still running on a package after `duration`")
+	fs.StringVar(&overlay, "overlay", "", "JSON `file` replacing the content of files, like unsaved editor buffers, in the format of go build -overlay")
+	fs.Var(&opts.Shard, "shard", "only analyze the packages in shard `i/n`, to split the analysis across n jobs (see merge-reports)")
+	fs.Var((*byteSize)(&opts.MaxMemory), "max-memory", "run analyzers one at a time when the heap exceeds `size` (like 4GB or 512MiB)")
+	fs.StringVar(&opts.ExportFacts, "facts-out", "", "write facts of all analyzed packages to `file` (.json for JSON, gob otherwise)")
+	fs.StringVar(&filterExpr, "filter", "", "only report diagnostics matching `expr`, overriding the configuration")
+	fs.StringVar(&profile, "profile", "", "CPU or coverage profile `file`, to only report performance findings in hot code")
+	fs.Float64Var(&hotThreshold, "hot-threshold", 1, "with -profile, minimum hotness in `percent` of reported findings")
+	fs.StringVar(&hotFilter, "hot-filter", "tag:performance", "with -profile, filter `expr` selecting the findings restricted to hot code")
+	fs.StringVar(&coverProfile, "coverprofile", "", "annotate findings with their test coverage according to the coverage profile `file`")
+	fs.BoolVar(&doBlame, "blame", false, "annotate findings with the commit, author and age of the flagged line according to git blame")
+	fs.StringVar(&splitOwners, "split-owners", "", "write one report per owner (from CODEOWNERS and OWNERS files) to `dir`")
+	fs.Var(&format, "format", "output `format` (text, json or sarif)")
+	fs.BoolVar(&doFix, "fix", false, "apply suggested fixes")
+	fs.Var(&level, "fix-level", "minimum safety `level` of fixes applied by -fix (safe, unverified or unsafe)")
+	fs.StringVar(&baseline, "baseline", "", "`file` with known findings, overriding the configuration")
+	fs.BoolVar(&writeBaseline, "write-baseline", false, "write all findings not ignored by directives to the baseline and exit")
+	cf.register(fs)
+	fs.Parse(args)
+
+	opts.Analyzers = analyzers
+	opts.Targets = targets
+	opts.ImportFacts = facts
+	patterns := fs.Args()
+	if len(patterns) == 0 {
+		patterns = []string{"."}
+	}
+
+	cfg, err := cf.load(fs, &opts)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "gotools:", err)
+		return 1
+	}
+	if overlay != "" {
+		if doFix {
+			// Fixes are edits of the analyzed content, not the one on disk.
+			fmt.Fprintln(os.Stderr, "gotools: -fix can't be used with -overlay")
+			return 2
+		}
+		if opts.Overlay, err = driver.ReadOverlay(overlay); err != nil {
+			fmt.Fprintln(os.Stderr, "gotools:", err)
+			return 1
+		}
+	}
+	flt, err := loadFilter(filterExpr, cfg)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "gotools:", err)
+		return 2
+	}
+	var (
+		cover  *coverage.Profile
+		prof   *hotspot.Profile
+		hotSel *filter.Filter
+	)
+	if coverProfile != "" {
+		if cover, err = coverage.Read(coverProfile); err != nil {
+			fmt.Fprintln(os.Stderr, "gotools:", err)
+			return 1
+		}
+	}
+	if profile != "" {
+		if prof, err = hotspot.Read(profile); err != nil {
+			fmt.Fprintln(os.Stderr, "gotools:", err)
+			return 1
+		}
+		if hotSel, err = filter.Parse(hotFilter, cfg.Dir); err != nil {
+			fmt.Fprintln(os.Stderr, "gotools:", err)
+			return 2
+		}
+	}
+	diags, err := driver.Run(&opts, patterns...)
+	inc, _ := err.(*driver.Incomplete)
+	if err != nil && inc == nil {
+		fmt.Fprintln(os.Stderr, "gotools:", err)
+		return 1
+	}
+	var notes []string
+	if inc != nil {
+		notes = inc.Notes()
+		for _, n := range notes {
+			fmt.Fprintln(os.Stderr, "gotools: incomplete analysis:", n)
+		}
+	}
+	if writeBaseline {
+		if inc != nil {
+			fmt.Fprintln(os.Stderr, "gotools: can't write a baseline from an incomplete analysis")
+			return 1
+		}
+		if err := writeBaselineFile(diags, cfg, baseline); err != nil {
+			fmt.Fprintln(os.Stderr, "gotools:", err)
+			return 1
+		}
+		return 0
+	}
+	// Suppressions and reports read the flagged lines, so they share an
+	// index of the source files.
+	ix := posindex.New()
+	for name, src := range opts.Overlay {
+		ix.Add(posindex.NewFile(name, src))
+	}
+	if diags, _, err = applySuppressions(diags, cfg, baseline, false, ix); err != nil {
+		fmt.Fprintln(os.Stderr, "gotools:", err)
+		return 1
+	}
+	if cover != nil {
+		cover.Annotate(diags)
+	}
+	if doBlame {
+		if err := annotateBlame(diags, time.Now(), opts.Overlay); err != nil {
+			// Findings in files git doesn't know are still reported.
+			fmt.Fprintln(os.Stderr, "gotools:", err)
+		}
+	}
+	if flt != nil {
+		diags = flt.Apply(diags)
+	}
+	if prof != nil {
+		diags = prof.Limit(diags, hotSel, hotThreshold)
+	}
+	if doFix {
+		if diags, err = applyFixes(diags, level); err != nil {
+			fmt.Fprintln(os.Stderr, "gotools:", err)
+			return 1
+		}
+	}
+	ropts := &report.Options{Analyzers: analyzers, Root: cfg.Dir, Index: ix, Notes: notes}
+	if splitOwners != "" {
+		err = writeOwnerReports(splitOwners, format, diags, ropts)
+	} else {
+		err = report.Write(os.Stdout, format, diags, ropts)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "gotools:", err)
+		return 1
+	}
+	if len(diags) > 0 || inc != nil {
+		return 3
+	}
+	return 0
+}
+
+// applyFixes applies the fixes of diags with at least the given level and
+// returns the diagnostics which were not fixed.
+func applyFixes(diags []driver.Diagnostic, level fix.Level) ([]driver.Diagnostic, error) {
+	var (
+		fixes []fix.Fix
+		fixed []int // index of the fix of diags[i], or -1
+	)
+	for _, d := range diags {
+		// Only apply the first fix of every diagnostic. Alternatives would
+		// conflict with it.
+		if len(d.Fixes) == 0 {
+			fixed = append(fixed, -1)
+			continue
+		}
+		fixed = append(fixed, len(fixes))
+		fixes = append(fixes, d.Fixes[0])
+	}
+	res, err := fix.Apply(fixes, fix.Options{Level: level})
+	if err != nil {
+		return nil, err
+	}
+	skipped := make(map[int]bool)
+	for _, s := range res.Skipped {
+		skipped[s.Index] = true
+		if s.Fix.Level >= level {
+			fmt.Fprintf(os.Stderr, "gotools: skipped fix %q: %s\n", s.Fix.Message, s.Reason)
+		}
+	}
+	var rest []driver.Diagnostic
+	for i, d := range diags {
+		if fixed[i] < 0 || skipped[fixed[i]] {
+			rest = append(rest, d)
+		}
+	}
+	fmt.Fprintf(os.Stderr, "gotools: applied %d fixes in %d files\n", res.Applied, len(res.Files))
+	return rest, nil
+}
+
+// annotateBlame annotates the diagnostics with the last change of their
+// line. Diagnostics in lines which can't be blamed, including all lines of
+// files in the overlay, are left unchanged and the first error is returned.
+func annotateBlame(diags []driver.Diagnostic, now time.Time, overlay map[string][]byte) error {
+	var (
+		b     = blame.New()
+		first error
+	)
+	for i := range diags {
+		d := &diags[i]
+		if _, ok := overlay[d.Posn.Filename]; ok {
+			continue
+		}
+		l, err := b.Line(d.Posn.Filename, d.Posn.Line)
+		if err != nil {
+			if first == nil {
+				first = err
+			}
+			continue
+		}
+		for k, v := range l.Annotations(now) {
+			d.Annotate(k, v)
+		}
+	}
+	return first
+}
+
+// writeBaselineFile writes the diagnostics not ignored by directives to the
+// baseline file.
+func writeBaselineFile(diags []driver.Diagnostic, cfg *config.Config, file string) error {
+	if file == "" {
+		file = cfg.Path(cfg.Baseline)
+	}
+	if file == "" {
+		return errors.New("no baseline file configured, use -baseline")
+	}
+	var keep []driver.Diagnostic
+	for _, d := range diags {
+		if d.Ignored == nil {
+			keep = append(keep, d)
+		}
+	}
+	if err := suppress.NewBaseline(keep, cfg.Dir).Write(file); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "gotools: wrote %d findings to %s\n", len(keep), file)
+	return nil
+}
+
+// writeOwnerReports writes the diagnostics of every owner to a file in dir.
+// The owners are determined by the files in the root of the report.
+func writeOwnerReports(dir string, format report.Format, diags []driver.Diagnostic, opts *report.Options) error {
+	o, err := owners.Load(opts.Root)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0777); err != nil {
+		return err
+	}
+	ext := map[report.Format]string{report.Text: ".txt", report.JSON: ".json", report.SARIF: ".sarif"}[format]
+	split := o.Split(diags)
+	for _, owner := range owners.Names(split) {
+		file := filepath.Join(dir, ownerFileName(owner)+ext)
+		f, err := os.Create(file)
+		if err != nil {
+			return err
+		}
+		err = report.Write(f, format, split[owner], opts)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%s: %d findings in %s\n", owner, len(split[owner]), file)
+	}
+	return nil
+}
+
+// ownerFileName returns a file name for the report of owner, like
+// "org-team" for "@org/team".
+func ownerFileName(owner string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case 'a' <= r && r <= 'z', 'A' <= r && r <= 'Z', '0' <= r && r <= '9', r == '.', r == '_', r == '-':
+			return r
+		case r == '/':
+			return '-'
+		}
+		return '_'
+	}, strings.TrimPrefix(owner, "@"))
+}
+
+// stringList implements flag.Value for a repeatable string flag.
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(s string) error {
+	*l = append(*l, s)
+	return nil
+}
+
+// byteSize implements flag.Value for a size in bytes, with an optional
+// decimal or binary unit.
+type byteSize uint64
+
+var byteUnits = []struct {
+	suffix string
+	factor uint64
+}{
+	// Longer suffixes first, so "B" doesn't match "KB".
+	{"KiB", 1 << 10}, {"MiB", 1 << 20}, {"GiB", 1 << 30}, {"TiB", 1 << 40},
+	{"KB", 1e3}, {"MB", 1e6}, {"GB", 1e9}, {"TB", 1e12},
+	{"B", 1},
+}
+
+func (b *byteSize) String() string {
+	if *b == 0 {
+		return "0"
+	}
+	// Use the unit giving the shortest representation.
+	best := byteUnits[len(byteUnits)-1]
+	for _, u := range byteUnits {
+		if uint64(*b)%u.factor == 0 && u.factor > best.factor {
+			best = u
+		}
+	}
+	return strconv.FormatUint(uint64(*b)/best.factor, 10) + best.suffix
+}
+
+func (b *byteSize) Set(s string) error {
+	factor := uint64(1)
+	num := strings.TrimSpace(s)
+	for _, u := range byteUnits {
+		if strings.HasSuffix(strings.ToUpper(num), strings.ToUpper(u.suffix)) {
+			factor, num = u.factor, strings.TrimSpace(num[:len(num)-len(u.suffix)])
+			break
+		}
+	}
+	n, err := strconv.ParseFloat(num, 64)
+	if err != nil || n < 0 {
+		return fmt.Errorf("invalid size %q", s)
+	}
+	*b = byteSize(n * float64(factor))
+	return nil
+}
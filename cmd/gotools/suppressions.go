@@ -0,0 +1,134 @@
+// Copyright 2019 Axel Wagner
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/Merovius/go-tools/internal/blame"
+	"github.com/Merovius/go-tools/internal/config"
+	"github.com/Merovius/go-tools/internal/driver"
+	"github.com/Merovius/go-tools/internal/posindex"
+	"github.com/Merovius/go-tools/internal/suppress"
+)
+
+func suppressionsCmd(args []string) int {
+	fs := flag.NewFlagSet("suppressions", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: gotools suppressions [flags] [packages]")
+		fmt.Fprintln(os.Stderr)
+		fmt.Fprintln(os.Stderr, "Lists findings suppressed by //lint:ignore directives or a baseline, with the")
+		fmt.Fprintln(os.Stderr, "age and author of the suppression.")
+		fmt.Fprintln(os.Stderr)
+		fs.PrintDefaults()
+	}
+	var (
+		opts     = driver.Options{ReportIgnored: true}
+		cf       configFlags
+		baseline string
+	)
+	fs.BoolVar(&opts.Tests, "tests", true, "also analyze test files")
+	fs.StringVar(&baseline, "baseline", "", "`file` with known findings, overriding the configuration")
+	cf.register(fs)
+	fs.Parse(args)
+
+	opts.Analyzers = analyzers
+	patterns := fs.Args()
+	if len(patterns) == 0 {
+		patterns = []string{"."}
+	}
+	cfg, err := cf.load(fs, &opts)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "gotools:", err)
+		return 1
+	}
+	diags, err := driver.Run(&opts, patterns...)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "gotools:", err)
+		return 1
+	}
+	now := time.Now()
+	_, sups, err := applySuppressions(diags, cfg, baseline, true, nil)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "gotools:", err)
+		return 1
+	}
+	for _, s := range sups {
+		fmt.Printf("%v\n", s.Diagnostic)
+		desc := string(s.Kind)
+		if s.Reason != "" {
+			desc += fmt.Sprintf(" %q", s.Reason)
+		}
+		if s.Posn.Filename != "" {
+			desc += fmt.Sprintf(" at %s:%d", relPath(s.Posn.Filename), s.Posn.Line)
+		}
+		if s.Blame.Time.IsZero() {
+			desc += ", age unknown"
+		} else if !s.Blame.Committed() {
+			desc += ", not committed"
+		} else {
+			desc += fmt.Sprintf(", %d days old, by %s <%s>", int(s.Age(now)/(24*time.Hour)), s.Blame.Author, s.Blame.Email)
+		}
+		if s.Expired {
+			desc += ", expired"
+		}
+		fmt.Printf("\t%s\n", desc)
+	}
+	return 0
+}
+
+// applySuppressions applies the suppressions configured by cfg to diags,
+// which must include diagnostics ignored by directives. If baseline is not
+// empty, it overrides the baseline of the configuration. If doBlame is set,
+// the age of all suppressions is determined, otherwise only if they can
+// expire. Source files are read using ix, which may be nil.
+func applySuppressions(diags []driver.Diagnostic, cfg *config.Config, baseline string, doBlame bool, ix *posindex.Index) ([]driver.Diagnostic, []suppress.Suppression, error) {
+	opts := suppress.Options{
+		Root:        cfg.Dir,
+		ExpireAfter: time.Duration(cfg.Suppressions.ExpireDays) * 24 * time.Hour,
+		Index:       ix,
+	}
+	if baseline == "" {
+		baseline = cfg.Path(cfg.Baseline)
+	}
+	if baseline != "" {
+		b, err := suppress.ReadBaseline(baseline)
+		if err != nil {
+			return nil, nil, err
+		}
+		opts.Baseline = b
+	}
+	if doBlame || opts.ExpireAfter > 0 {
+		opts.Blame = blame.New().Line
+	}
+	report, sups := suppress.Apply(diags, opts)
+	return report, sups, nil
+}
+
+// relPath returns file relative to the current directory, if possible.
+func relPath(file string) string {
+	wd, err := os.Getwd()
+	if err != nil {
+		return file
+	}
+	if rel, err := filepath.Rel(wd, file); err == nil {
+		return rel
+	}
+	return file
+}
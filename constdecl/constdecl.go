@@ -0,0 +1,330 @@
+// Copyright 2019 Axel Wagner
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package constdecl defines an Analyzer that checks const blocks declaring
+// enumerations.
+package constdecl
+
+import (
+	"go/ast"
+	"go/constant"
+	"go/token"
+	"go/types"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/Merovius/go-tools/internal/analysisutil"
+	"github.com/Merovius/go-tools/internal/nodefilter"
+	"golang.org/x/tools/go/analysis"
+)
+
+const Doc = `check const blocks declaring enumerations
+
+The analyzer reports
+  - constants in a block of constants of the same type which have the same
+    value as an earlier one. Often, an explicit value was inserted into an
+    iota sequence, and the constants following it repeat its expression,
+  - iota restarting after explicit values, which leaves gaps in the sequence,
+  - exported untyped constants in a block of constants of a named type, which
+    can't be used where the named type is expected without a conversion, and
+  - String methods switching over the constants of their type, which don't
+    handle all of them.
+
+Constants explicitly defined as another constant are considered deliberate
+aliases.`
+
+var Analyzer = &analysis.Analyzer{
+	Name: "constdecl",
+	Doc:  Doc,
+	Run:  run,
+	Requires: []*analysis.Analyzer{
+		nodefilter.Analyzer,
+	},
+}
+
+var (
+	decls = nodefilter.New(false, new(ast.GenDecl))
+	funcs = nodefilter.New(false, new(ast.FuncDecl))
+)
+
+func run(pass *analysis.Pass) (interface{}, error) {
+	nodes := pass.ResultOf[nodefilter.Analyzer].(*nodefilter.Result)
+	for _, n := range nodes.Nodes(decls) {
+		if d := n.Node.(*ast.GenDecl); d.Tok == token.CONST && len(d.Specs) > 1 {
+			checkBlock(pass, d)
+		}
+	}
+	for _, n := range nodes.Nodes(funcs) {
+		checkString(pass, n.Node.(*ast.FuncDecl))
+	}
+	return nil, nil
+}
+
+// An entry is a constant declared in a block.
+type entry struct {
+	name  *ast.Ident
+	obj   *types.Const
+	index int // value of iota
+	// expr is the expression defining the constant, which is repeated
+	// from an earlier spec if implicit is set.
+	expr     ast.Expr
+	implicit bool
+}
+
+func entries(pass *analysis.Pass, d *ast.GenDecl) []entry {
+	var (
+		es   []entry
+		last []ast.Expr
+	)
+	for i, s := range d.Specs {
+		vs := s.(*ast.ValueSpec)
+		implicit := len(vs.Values) == 0
+		if !implicit {
+			last = vs.Values
+		}
+		for j, name := range vs.Names {
+			obj, ok := pass.TypesInfo.Defs[name].(*types.Const)
+			if !ok || name.Name == "_" {
+				continue
+			}
+			e := entry{name: name, obj: obj, index: i, implicit: implicit}
+			if j < len(last) {
+				e.expr = last[j]
+			}
+			es = append(es, e)
+		}
+	}
+	return es
+}
+
+func checkBlock(pass *analysis.Pass, d *ast.GenDecl) {
+	es := entries(pass, d)
+	if len(es) < 2 {
+		return
+	}
+	checkUntyped(pass, es)
+	for _, e := range es[1:] {
+		if !types.Identical(e.obj.Type(), es[0].obj.Type()) {
+			return
+		}
+	}
+	if !usesIota(pass, d) {
+		return
+	}
+	checkDuplicates(pass, es)
+	checkGaps(pass, es)
+}
+
+func usesIota(pass *analysis.Pass, n ast.Node) bool {
+	found := false
+	ast.Inspect(n, func(n ast.Node) bool {
+		if id, ok := n.(*ast.Ident); ok && isIota(pass, id) {
+			found = true
+		}
+		return !found
+	})
+	return found
+}
+
+func isIota(pass *analysis.Pass, e ast.Expr) bool {
+	id, ok := e.(*ast.Ident)
+	return ok && id.Name == "iota" && pass.TypesInfo.Uses[id] == types.Universe.Lookup("iota")
+}
+
+// isAlias returns whether e is defined as another constant.
+func isAlias(pass *analysis.Pass, e entry) bool {
+	if e.implicit || e.expr == nil {
+		return false
+	}
+	var id *ast.Ident
+	switch x := e.expr.(type) {
+	case *ast.Ident:
+		id = x
+	case *ast.SelectorExpr:
+		id = x.Sel
+	default:
+		return false
+	}
+	_, ok := pass.TypesInfo.Uses[id].(*types.Const)
+	return ok && !isIota(pass, id)
+}
+
+func checkDuplicates(pass *analysis.Pass, es []entry) {
+	seen := make(map[string]entry)
+	for _, e := range es {
+		if e.obj.Val().Kind() == constant.Unknown {
+			continue
+		}
+		v := e.obj.Val().ExactString()
+		prev, ok := seen[v]
+		if !ok {
+			seen[v] = e
+			continue
+		}
+		if isAlias(pass, e) {
+			continue
+		}
+		msg := e.name.Name + " has the same value as " + prev.name.Name + " (" + e.obj.Val().String() + ")"
+		if e.implicit && e.expr != nil && !usesIota(pass, e.expr) {
+			msg += ", as it repeats the expression " + types.ExprString(e.expr) + ", which doesn't use iota"
+		}
+		pass.Reportf(e.name.Pos(), "%s", msg)
+	}
+}
+
+// checkGaps reports constants restarting a plain iota sequence after
+// explicit values, which leaves the iota values of those unused.
+func checkGaps(pass *analysis.Pass, es []entry) {
+	var (
+		inSeq   bool
+		skipped []entry
+	)
+	for _, e := range es {
+		plain := e.expr != nil && isIota(pass, e.expr)
+		switch {
+		case plain && !e.implicit && inSeq && len(skipped) > 0:
+			var (
+				names []string
+				gaps  []string
+			)
+			for _, s := range skipped {
+				names = append(names, s.name.Name)
+				gaps = append(gaps, strconv.Itoa(s.index))
+			}
+			pass.Report(analysis.Diagnostic{
+				Pos:      e.name.Pos(),
+				Category: "gap",
+				Message:  "iota restarts after " + strings.Join(names, ", ") + ", leaving a gap at " + strings.Join(gaps, ", "),
+			})
+			skipped = nil
+		case plain:
+			inSeq = true
+			skipped = nil
+		case inSeq && !e.implicit && !isAlias(pass, e):
+			skipped = append(skipped, e)
+		}
+	}
+}
+
+func checkUntyped(pass *analysis.Pass, es []entry) {
+	var named *types.Named
+	for _, e := range es {
+		if n, ok := e.obj.Type().(*types.Named); ok && n.Obj().Pkg() == pass.Pkg {
+			if named != nil && named != n {
+				return
+			}
+			named = n
+		}
+	}
+	if named == nil {
+		return
+	}
+	u, ok := named.Underlying().(*types.Basic)
+	if !ok {
+		return
+	}
+	for _, e := range es {
+		b, ok := e.obj.Type().(*types.Basic)
+		if !ok || b.Info()&types.IsUntyped == 0 || !e.obj.Exported() {
+			continue
+		}
+		if !types.ConvertibleTo(b, u) || b.Info()&types.IsString != u.Info()&types.IsString {
+			continue
+		}
+		name := named.Obj().Name()
+		pass.Report(analysis.Diagnostic{
+			Pos:      e.name.Pos(),
+			Category: "untyped",
+			Message:  e.name.Name + " is untyped, unlike the other constants of type " + name + " in the block; declare it as " + e.name.Name + " " + name,
+		})
+	}
+}
+
+func checkString(pass *analysis.Pass, fn *ast.FuncDecl) {
+	if fn.Name.Name != "String" || fn.Recv == nil || len(fn.Recv.List) != 1 || fn.Body == nil || analysisutil.IsGenerated(pass, fn.Pos()) {
+		return
+	}
+	obj, ok := pass.TypesInfo.Defs[fn.Name].(*types.Func)
+	if !ok {
+		return
+	}
+	recv := obj.Type().(*types.Signature).Recv()
+	named, ok := recv.Type().(*types.Named)
+	if !ok {
+		return
+	}
+	ast.Inspect(fn.Body, func(n ast.Node) bool {
+		s, ok := n.(*ast.SwitchStmt)
+		if !ok {
+			return true
+		}
+		if id, ok := s.Tag.(*ast.Ident); ok && pass.TypesInfo.Uses[id] == recv {
+			checkSwitch(pass, named, s)
+		}
+		return false
+	})
+}
+
+func checkSwitch(pass *analysis.Pass, named *types.Named, s *ast.SwitchStmt) {
+	covered := make(map[string]bool)
+	for _, c := range s.Body.List {
+		for _, e := range c.(*ast.CaseClause).List {
+			tv := pass.TypesInfo.Types[e]
+			if tv.Value == nil {
+				return
+			}
+			covered[tv.Value.ExactString()] = true
+		}
+	}
+	if len(covered) < 2 {
+		return
+	}
+	var missing []string
+	scope := pass.Pkg.Scope()
+	for _, name := range scope.Names() {
+		c, ok := scope.Lookup(name).(*types.Const)
+		if !ok || c.Type() != named || sentinel(name) || covered[c.Val().ExactString()] {
+			continue
+		}
+		covered[c.Val().ExactString()] = true
+		missing = append(missing, name)
+	}
+	if len(missing) == 0 {
+		return
+	}
+	sort.Strings(missing)
+	pass.Report(analysis.Diagnostic{
+		Pos:      s.Pos(),
+		Category: "stringer",
+		Message:  named.Obj().Name() + ".String doesn't handle " + strings.Join(missing, ", "),
+	})
+}
+
+// sentinel returns whether name looks like a constant marking the end or
+// size of an enumeration, rather than a value of it.
+func sentinel(name string) bool {
+	l := strings.ToLower(name)
+	for _, p := range []string{"num", "max", "last"} {
+		if strings.HasPrefix(l, p) {
+			return true
+		}
+	}
+	for _, s := range []string{"count", "max", "sentinel"} {
+		if strings.HasSuffix(l, s) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,85 @@
+package a
+
+type Color int
+
+const (
+	Red Color = iota
+	Green
+	Blue
+	Purple
+	numColors
+)
+
+func (c Color) String() string {
+	switch c { // want `Color.String doesn't handle Purple`
+	case Red:
+		return "red"
+	case Green:
+		return "green"
+	case Blue:
+		return "blue"
+	}
+	return "unknown"
+}
+
+type Level int
+
+const (
+	Debug Level = iota
+	Info
+	Warn  Level = 10
+	Error       // want `Error has the same value as Warn \(10\), as it repeats the expression 10, which doesn't use iota`
+)
+
+const (
+	Trace  Level = -1
+	Legacy       = 10 // want `Legacy is untyped, unlike the other constants of type Level in the block; declare it as Legacy Level`
+)
+
+type Op int
+
+const (
+	Add Op = iota
+	Sub
+	Reserved Op = 100
+	Mul      Op = iota // want `iota restarts after Reserved, leaving a gap at 2`
+	Div
+	Minus = Sub
+)
+
+func (o Op) String() string {
+	switch o {
+	case Add, Mul:
+		return "+*"
+	case Sub, Div:
+		return "-/"
+	case Reserved:
+		return "?"
+	}
+	return ""
+}
+
+type Kind string
+
+const (
+	A Kind = "a"
+	B Kind = "b"
+	C      = "c" // want `C is untyped`
+	d      = "d"
+	E      = 1
+)
+
+const (
+	x = iota
+	y
+	_
+	z
+)
+
+type Flag uint
+
+const (
+	F1 Flag = 1 << iota
+	F2
+	F3
+)
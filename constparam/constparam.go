@@ -0,0 +1,186 @@
+// Copyright 2019 Axel Wagner
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package constparam defines an Analyzer that checks for parameters which
+// are passed the same constant at every call site.
+package constparam
+
+import (
+	"go/ast"
+	"go/constant"
+	"go/token"
+	"go/types"
+
+	"github.com/Merovius/go-tools/internal/nodefilter"
+	"golang.org/x/tools/go/analysis"
+)
+
+const Doc = `check for parameters which are passed the same constant at every call
+
+The analyzer reports parameters of unexported functions, which are passed
+the same constant (or nil) at all of at least two call sites. The constant
+can be used in the function instead, or, if the parameter is meant to
+select between behaviors of which only one is used, the function can be
+simplified.
+
+Unexported functions can only be called in their own package, so all call
+sites are known. Arguments of recursive calls passing the parameter on
+are ignored. Functions used as values and methods are not reported.`
+
+var Analyzer = &analysis.Analyzer{
+	Name: "constparam",
+	Doc:  Doc,
+	Run:  run,
+	Requires: []*analysis.Analyzer{
+		nodefilter.Analyzer,
+	},
+}
+
+var (
+	funcs  = nodefilter.New(false, new(ast.FuncDecl))
+	idents = nodefilter.New(true, new(ast.Ident))
+)
+
+// An arg is the argument passed to a parameter.
+type arg struct {
+	val     constant.Value // nil if the argument is nil
+	varying bool           // whether different arguments are passed
+	set     bool           // whether any argument was seen yet
+}
+
+// A usage records the arguments passed to a function.
+type usage struct {
+	calls   int
+	args    []arg
+	invalid bool // the function is used as a value or called unusually
+}
+
+func run(pass *analysis.Pass) (interface{}, error) {
+	nodes := pass.ResultOf[nodefilter.Analyzer].(*nodefilter.Result)
+
+	usages := make(map[*types.Func]*usage)
+	for _, n := range nodes.Nodes(idents) {
+		id := n.Node.(*ast.Ident)
+		fn, ok := pass.TypesInfo.Uses[id].(*types.Func)
+		if !ok || fn.Pkg() != pass.Pkg {
+			continue
+		}
+		sig := fn.Type().(*types.Signature)
+		if sig.Recv() != nil || sig.Params().Len() == 0 {
+			continue
+		}
+		u := usages[fn]
+		if u == nil {
+			u = &usage{args: make([]arg, sig.Params().Len())}
+			usages[fn] = u
+		}
+		call := callOf(n.Stack)
+		if call == nil || len(call.Args) != len(u.args) || call.Ellipsis.IsValid() {
+			u.invalid = true
+			continue
+		}
+		if decl, ok := n.Stack[1].(*ast.FuncDecl); !ok || pass.TypesInfo.Defs[decl.Name] != fn {
+			// Recursive calls are not counted as call sites.
+			u.calls++
+		}
+		for i, e := range call.Args {
+			if obj, ok := pass.TypesInfo.Uses[identOf(e)].(*types.Var); ok && obj == sig.Params().At(i) {
+				// A recursive call passing the parameter on.
+				continue
+			}
+			a := &u.args[i]
+			tv := pass.TypesInfo.Types[e]
+			if tv.Value == nil && !tv.IsNil() {
+				a.varying = true
+				continue
+			}
+			if a.set && !same(a.val, tv.Value) {
+				a.varying = true
+			}
+			a.val, a.set = tv.Value, true
+		}
+	}
+
+	for _, n := range nodes.Nodes(funcs) {
+		decl := n.Node.(*ast.FuncDecl)
+		if decl.Recv != nil || decl.Name.IsExported() {
+			continue
+		}
+		fn, ok := pass.TypesInfo.Defs[decl.Name].(*types.Func)
+		if !ok {
+			continue
+		}
+		u := usages[fn]
+		if u == nil || u.invalid || u.calls < 2 || fn.Type().(*types.Signature).Variadic() {
+			continue
+		}
+		i := 0
+		for _, field := range decl.Type.Params.List {
+			for _, name := range field.Names {
+				if a := u.args[i]; a.set && !a.varying && name.Name != "_" {
+					val := "nil"
+					if a.val != nil {
+						val = a.val.ExactString()
+					}
+					pass.Reportf(name.Pos(), "parameter %s of %s is %s at all %d call sites; use the constant in the function instead", name.Name, decl.Name.Name, val, u.calls)
+				}
+				i++
+			}
+			if len(field.Names) == 0 {
+				i++
+			}
+		}
+	}
+	return nil, nil
+}
+
+func same(a, b constant.Value) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	if a.Kind() != b.Kind() {
+		return false
+	}
+	return constant.Compare(a, token.EQL, b)
+}
+
+func identOf(e ast.Expr) *ast.Ident {
+	for {
+		switch x := e.(type) {
+		case *ast.ParenExpr:
+			e = x.X
+		case *ast.Ident:
+			return x
+		default:
+			return nil
+		}
+	}
+}
+
+// callOf returns the call of the function referred to by the identifier at
+// the top of stack, or nil if it isn't called.
+func callOf(stack []ast.Node) *ast.CallExpr {
+	i := len(stack) - 2
+	for i > 0 {
+		if _, ok := stack[i].(*ast.ParenExpr); !ok {
+			break
+		}
+		i--
+	}
+	call, ok := stack[i].(*ast.CallExpr)
+	if !ok || call.Fun != stack[i+1] {
+		return nil
+	}
+	return call
+}
@@ -0,0 +1,47 @@
+package a
+
+const defaultSize = 10
+
+func resize(buf []byte, size int, zero bool) []byte { // want `parameter size of resize is 10 at all 3 call sites; use the constant in the function instead`
+	if zero {
+		return make([]byte, size)
+	}
+	return buf[:size]
+}
+
+func greet(name, greeting string) string { // want `parameter greeting of greet is "Hello" at all 2 call sites`
+	return greeting + ", " + name
+}
+
+func walk(n int, opts *int) { // want `parameter opts of walk is nil at all 2 call sites`
+	if n > 0 {
+		walk(n-1, opts)
+	}
+}
+
+func once(x int) {}
+
+func value(x int) {}
+
+var f = value
+
+func variadic(xs ...int) {}
+
+func Exported(x int) {}
+
+func main() {
+	a := resize(nil, 10, true)
+	b := resize(a, defaultSize, false)
+	resize(b, 5+5, true)
+	greet("a", "Hello")
+	greet("b", "Hello")
+	walk(1, nil)
+	walk(2, nil)
+	once(1)
+	value(1)
+	value(1)
+	variadic(1)
+	variadic(1)
+	Exported(1)
+	Exported(1)
+}
@@ -0,0 +1,68 @@
+// Copyright 2019 Axel Wagner
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package constraints defines an Analyzer that checks for misuse of the
+// constraints of type parameters.
+package constraints
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/Merovius/go-tools/internal/nodefilter"
+	"golang.org/x/tools/go/analysis"
+)
+
+const Doc = `check for misuse of type parameter constraints
+
+Values of a type parameter can only be compared with == and != and used as
+map keys if it is constrained by comparable. Since Go 1.20, comparable is
+also satisfied by interface types, though, whose comparison panics at run
+time if the dynamic types of the values aren't comparable. The analyzer
+reports instantiations of generic functions and types with such type
+arguments, if they compare values of the type parameter or use them as map
+keys, directly or through other generic functions and types.
+
+It also reports constraints whose type terms and methods contradict each
+other: a type term without tilde, whose type doesn't have the methods of the
+constraint, can never satisfy it. If no type term can, or the embedded
+unions have no type in common, the constraint has an empty type set and
+can't be instantiated at all.`
+
+var Analyzer = &analysis.Analyzer{
+	Name: "constraints",
+	Doc:  Doc,
+	Run:  run,
+	Requires: []*analysis.Analyzer{
+		nodefilter.Analyzer,
+	},
+	FactTypes: []analysis.Fact{new(compares)},
+}
+
+// compares is an object fact of generic functions and types, listing the
+// indices of the type parameters whose values they compare or use as map
+// keys.
+type compares struct {
+	Params []int
+}
+
+func (*compares) AFact() {}
+
+func (c *compares) String() string {
+	s := make([]string, len(c.Params))
+	for i, p := range c.Params {
+		s[i] = strconv.Itoa(p)
+	}
+	return "compares type parameters " + strings.Join(s, ", ")
+}
@@ -0,0 +1,369 @@
+// Copyright 2019 Axel Wagner
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build go1.18
+// +build go1.18
+
+package constraints
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+	"sort"
+
+	"github.com/Merovius/go-tools/internal/analysisutil"
+	"github.com/Merovius/go-tools/internal/nodefilter"
+	"golang.org/x/tools/go/analysis"
+)
+
+var (
+	ifaces = nodefilter.New(false, new(ast.InterfaceType))
+	uses   = nodefilter.New(false, new(ast.BinaryExpr), new(ast.SwitchStmt), new(ast.IndexExpr), new(ast.CompositeLit), new(ast.CallExpr))
+)
+
+func run(pass *analysis.Pass) (interface{}, error) {
+	nodes := pass.ResultOf[nodefilter.Analyzer].(*nodefilter.Result)
+	for _, n := range nodes.Nodes(ifaces) {
+		checkConstraint(pass, n.Node.(*ast.InterfaceType))
+	}
+
+	c := newChecker(pass)
+	for _, n := range nodes.Nodes(uses) {
+		c.use(n.Node)
+	}
+	// Generic code instantiating other generic code with its type
+	// parameters compares them, if the instantiated code does. Repeat
+	// until no further type parameters are found.
+	for c.changed {
+		c.changed = false
+		for id, inst := range pass.TypesInfo.Instances {
+			obj := pass.TypesInfo.Uses[id]
+			for i := 0; i < inst.TypeArgs.Len(); i++ {
+				if c.compares(obj, i) {
+					c.mark(inst.TypeArgs.At(i))
+				}
+			}
+		}
+	}
+	for obj, params := range c.local {
+		fact := new(compares)
+		for i := range params {
+			fact.Params = append(fact.Params, i)
+		}
+		sort.Ints(fact.Params)
+		pass.ExportObjectFact(obj, fact)
+	}
+
+	var ids []*ast.Ident
+	for id := range pass.TypesInfo.Instances {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i].Pos() < ids[j].Pos() })
+	qual := types.RelativeTo(pass.Pkg)
+	for _, id := range ids {
+		obj := pass.TypesInfo.Uses[id]
+		args := pass.TypesInfo.Instances[id].TypeArgs
+		for i := 0; i < args.Len(); i++ {
+			arg := args.At(i)
+			if !c.compares(obj, i) || analysisutil.HasTypeParam(arg) || strictlyComparable(arg) {
+				continue
+			}
+			pass.Reportf(id.Pos(), "%s compares values of type parameter %s, which panics at run time for %s holding non-comparable dynamic types", obj.Name(), typeParams(obj).At(i).Obj().Name(), types.TypeString(arg, qual))
+		}
+	}
+	return nil, nil
+}
+
+// An owner is the generic function or type declaring a type parameter.
+type owner struct {
+	obj   types.Object
+	index int
+}
+
+// A checker finds the type parameters compared by the generic functions
+// and types of a package.
+type checker struct {
+	pass    *analysis.Pass
+	owners  map[*types.TypeParam]owner
+	local   map[types.Object]map[int]bool
+	changed bool
+}
+
+func newChecker(pass *analysis.Pass) *checker {
+	c := &checker{
+		pass:   pass,
+		owners: make(map[*types.TypeParam]owner),
+		local:  make(map[types.Object]map[int]bool),
+	}
+	add := func(obj types.Object, tps *types.TypeParamList) {
+		for i := 0; i < tps.Len(); i++ {
+			c.owners[tps.At(i)] = owner{obj, i}
+		}
+	}
+	for _, obj := range pass.TypesInfo.Defs {
+		switch obj := obj.(type) {
+		case *types.Func:
+			sig := obj.Type().(*types.Signature)
+			add(obj, sig.TypeParams())
+			// The receiver of a method of a generic type declares type
+			// parameters of its own, which stand for those of the type.
+			if recv := sig.Recv(); recv != nil && sig.RecvTypeParams().Len() > 0 {
+				if named, ok := deref(recv.Type()).(*types.Named); ok {
+					add(named.Obj(), sig.RecvTypeParams())
+				}
+			}
+		case *types.TypeName:
+			if named, ok := obj.Type().(*types.Named); ok && !obj.IsAlias() {
+				add(obj, named.TypeParams())
+			}
+		}
+	}
+	return c
+}
+
+// use marks the type parameters compared or used as map keys by n.
+func (c *checker) use(n ast.Node) {
+	info := c.pass.TypesInfo
+	switch n := n.(type) {
+	case *ast.BinaryExpr:
+		if n.Op == token.EQL || n.Op == token.NEQ {
+			c.mark(info.TypeOf(n.X))
+			c.mark(info.TypeOf(n.Y))
+		}
+	case *ast.SwitchStmt:
+		if n.Tag != nil {
+			c.mark(info.TypeOf(n.Tag))
+		}
+	case *ast.IndexExpr:
+		if m, ok := under(info.TypeOf(n.X)).(*types.Map); ok {
+			c.mark(m.Key())
+		}
+	case *ast.CompositeLit:
+		if m, ok := under(info.TypeOf(n)).(*types.Map); ok && len(n.Elts) > 0 {
+			c.mark(m.Key())
+		}
+	case *ast.CallExpr:
+		if id, ok := analysisutil.Unparen(n.Fun).(*ast.Ident); ok && len(n.Args) > 0 {
+			if b, ok := info.Uses[id].(*types.Builtin); ok && b.Name() == "delete" {
+				if m, ok := under(info.TypeOf(n.Args[0])).(*types.Map); ok {
+					c.mark(m.Key())
+				}
+			}
+		}
+	}
+}
+
+// mark marks the type parameters compared by comparing values of type t.
+func (c *checker) mark(t types.Type) {
+	switch t := t.(type) {
+	case *types.TypeParam:
+		o, ok := c.owners[t]
+		if !ok {
+			return
+		}
+		if c.local[o.obj] == nil {
+			c.local[o.obj] = make(map[int]bool)
+		}
+		if !c.local[o.obj][o.index] {
+			c.local[o.obj][o.index] = true
+			c.changed = true
+		}
+	case *types.Named:
+		if t.TypeArgs().Len() > 0 {
+			c.mark(t.Underlying())
+		}
+	case *types.Struct:
+		for i := 0; i < t.NumFields(); i++ {
+			c.mark(t.Field(i).Type())
+		}
+	case *types.Array:
+		c.mark(t.Elem())
+	}
+}
+
+// compares returns whether the generic function or type obj compares
+// values of its i'th type parameter.
+func (c *checker) compares(obj types.Object, i int) bool {
+	if obj == nil {
+		return false
+	}
+	if obj.Pkg() == c.pass.Pkg {
+		return c.local[obj][i]
+	}
+	var fact compares
+	if !c.pass.ImportObjectFact(obj, &fact) {
+		return false
+	}
+	for _, p := range fact.Params {
+		if p == i {
+			return true
+		}
+	}
+	return false
+}
+
+// typeParams returns the type parameters of the generic function or type
+// obj.
+func typeParams(obj types.Object) *types.TypeParamList {
+	if sig, ok := obj.Type().(*types.Signature); ok {
+		return sig.TypeParams()
+	}
+	return obj.Type().(*types.Named).TypeParams()
+}
+
+// strictlyComparable returns whether values of type t can be compared
+// without panicking.
+func strictlyComparable(t types.Type) bool {
+	switch u := t.Underlying().(type) {
+	case *types.Interface:
+		return false
+	case *types.Struct:
+		for i := 0; i < u.NumFields(); i++ {
+			if !strictlyComparable(u.Field(i).Type()) {
+				return false
+			}
+		}
+		return true
+	case *types.Array:
+		return strictlyComparable(u.Elem())
+	}
+	return types.Comparable(t)
+}
+
+func under(t types.Type) types.Type {
+	if t == nil {
+		return nil
+	}
+	return t.Underlying()
+}
+
+func deref(t types.Type) types.Type {
+	if p, ok := t.(*types.Pointer); ok {
+		return p.Elem()
+	}
+	return t
+}
+
+// A term is a type term of a constraint, like ~int.
+type term struct {
+	tilde bool
+	typ   types.Type
+}
+
+func checkConstraint(pass *analysis.Pass, node *ast.InterfaceType) {
+	iface, ok := pass.TypesInfo.TypeOf(node).(*types.Interface)
+	if !ok || iface.IsMethodSet() {
+		return
+	}
+	terms, all := typeSet(iface)
+	if all {
+		return
+	}
+	if len(terms) == 0 {
+		pass.Reportf(node.Pos(), "constraint has an empty type set, as its embedded types have none in common")
+		return
+	}
+	if iface.NumMethods() == 0 {
+		return
+	}
+	var missing []string
+	qual := types.RelativeTo(pass.Pkg)
+	for _, t := range terms {
+		if t.tilde {
+			continue
+		}
+		if m, _ := types.MissingMethod(t.typ, iface, true); m != nil {
+			missing = append(missing, types.TypeString(t.typ, qual)+" can never satisfy the constraint, as it has no method "+m.Name())
+		}
+	}
+	if len(missing) == len(terms) {
+		pass.Reportf(node.Pos(), "constraint has an empty type set, as none of its types has all of its methods")
+		return
+	}
+	for _, msg := range missing {
+		pass.Reportf(node.Pos(), "%s", msg)
+	}
+}
+
+// typeSet returns the type terms of the type set of iface, or all=true, if
+// it isn't restricted by any.
+func typeSet(iface *types.Interface) (terms []term, all bool) {
+	all = true
+	for i := 0; i < iface.NumEmbeddeds(); i++ {
+		ts, a := embedded(iface.EmbeddedType(i))
+		if a {
+			continue
+		}
+		if all {
+			terms, all = ts, false
+			continue
+		}
+		terms = intersect(terms, ts)
+	}
+	return terms, all
+}
+
+// embedded returns the type terms of an element embedded into a
+// constraint, or all=true, if it doesn't restrict them.
+func embedded(t types.Type) (terms []term, all bool) {
+	if u, ok := t.(*types.Union); ok {
+		for i := 0; i < u.Len(); i++ {
+			tm := u.Term(i)
+			if it, ok := tm.Type().Underlying().(*types.Interface); ok && !tm.Tilde() {
+				ts, a := typeSet(it)
+				if a {
+					return nil, true
+				}
+				terms = append(terms, ts...)
+				continue
+			}
+			terms = append(terms, term{tm.Tilde(), tm.Type()})
+		}
+		return terms, false
+	}
+	if it, ok := t.Underlying().(*types.Interface); ok {
+		return typeSet(it)
+	}
+	return []term{{false, t}}, false
+}
+
+// intersect returns the terms of the intersection of the type sets given
+// by a and b.
+func intersect(a, b []term) []term {
+	var terms []term
+	for _, x := range a {
+		for _, y := range b {
+			switch {
+			case x.tilde && y.tilde:
+				if types.Identical(x.typ, y.typ) {
+					terms = append(terms, x)
+				}
+			case x.tilde:
+				if types.Identical(x.typ, y.typ.Underlying()) {
+					terms = append(terms, y)
+				}
+			case y.tilde:
+				if types.Identical(x.typ.Underlying(), y.typ) {
+					terms = append(terms, x)
+				}
+			default:
+				if types.Identical(x.typ, y.typ) {
+					terms = append(terms, x)
+				}
+			}
+		}
+	}
+	return terms
+}
@@ -0,0 +1,133 @@
+package a
+
+import (
+	"fmt"
+	"time"
+)
+
+func Index[T comparable](s []T, v T) int { // want Index:"compares type parameters 0"
+	for i := range s {
+		if s[i] == v {
+			return i
+		}
+	}
+	return -1
+}
+
+// Contains only compares through Index.
+func Contains[T comparable](s []T, v T) bool { // want Contains:"compares type parameters 0"
+	return Index(s, v) >= 0
+}
+
+type Set[K comparable] map[K]struct{} // want Set:"compares type parameters 0"
+
+func (s Set[K]) Add(k K) {
+	s[k] = struct{}{}
+}
+
+func NewSet[K comparable]() Set[K] { // want NewSet:"compares type parameters 0"
+	return make(Set[K])
+}
+
+type Pair[A, B any] struct {
+	A A
+	B B
+}
+
+func Equal[A, B comparable](x, y Pair[A, B]) bool { // want Equal:"compares type parameters 0, 1"
+	return x == y
+}
+
+func Lookup[K comparable, V any](m map[K]V, keys []K) []V { // want Lookup:"compares type parameters 0"
+	var vs []V
+	for _, k := range keys {
+		vs = append(vs, m[k])
+	}
+	return vs
+}
+
+// Keys doesn't compare or hash keys.
+func Keys[K comparable, V any](m map[K]V) []K {
+	var ks []K
+	for k := range m {
+		ks = append(ks, k)
+	}
+	return ks
+}
+
+func Kind[T comparable](v, zero T) string { // want Kind:"compares type parameters 0"
+	switch v {
+	case zero:
+		return "zero"
+	}
+	return "other"
+}
+
+type Key struct {
+	A, B int
+}
+
+type Named struct {
+	Name  string
+	Value interface{}
+}
+
+func Uses() {
+	_ = Index([]int{1}, 1)
+	_ = Index([]Key{{1, 2}}, Key{1, 2})
+	_ = Index([]interface{}{1}, 1)                                  // want `Index compares values of type parameter T, which panics at run time for interface.. holding non-comparable dynamic types`
+	_ = Contains([]error{nil}, nil)                                 // want `Contains compares values of type parameter T, which panics at run time for error holding`
+	_ = Contains([]Named{}, Named{})                                // want `Contains compares values of type parameter T, which panics at run time for Named holding`
+	_ = Contains([][2]Key{}, [2]Key{})                              // ok
+	_ = Equal(Pair[int, fmt.Stringer]{}, Pair[int, fmt.Stringer]{}) // want `Equal compares values of type parameter B, which panics at run time for fmt.Stringer holding`
+	_ = Keys(map[interface{}]int{})
+	_ = Lookup(map[string]int{}, nil)
+	_ = Kind[any](1, 0) // want `Kind compares values of type parameter T`
+
+	s := NewSet[fmt.Stringer]() // want `NewSet compares values of type parameter K`
+	s.Add(time.Second)
+	var t Set[string]
+	t.Add("a")
+}
+
+type Stringish interface { // want `constraint has an empty type set, as none of its types has all of its methods`
+	int | string
+	String() string
+}
+
+type Duration interface {
+	~int32 | time.Duration
+	String() string
+}
+
+type Partial interface { // want `int can never satisfy the constraint, as it has no method String`
+	time.Duration | int
+	String() string
+}
+
+type Disjoint interface { // want `constraint has an empty type set, as its embedded types have none in common`
+	~int | ~uint
+	~string
+}
+
+type Number interface {
+	~int | ~float64
+}
+
+type Overlap interface {
+	Number
+	int | string
+}
+
+type Integer interface {
+	Number
+	~int
+	fmt.Stringer
+}
+
+func Print[T interface { // want `constraint has an empty type set, as none of its types has all of its methods`
+	int
+	fmt.Stringer
+}](v T) {
+	fmt.Println(v)
+}
@@ -0,0 +1,277 @@
+// Copyright 2019 Axel Wagner
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package ctxkey defines an Analyzer that checks that the keys of context
+// values are both stored and looked up.
+package ctxkey
+
+import (
+	"go/ast"
+	"go/types"
+	"sort"
+	"strings"
+
+	"github.com/Merovius/go-tools/internal/analysisutil"
+	"github.com/Merovius/go-tools/internal/nodefilter"
+	"golang.org/x/tools/go/analysis"
+)
+
+const Doc = `check that context value keys are stored and looked up
+
+Values are stored in a context with context.WithValue and looked up with
+the Value method, by a key compared with ==. As keys are usually of a type
+of their own, mixing up keys doesn't fail to compile, but makes lookups
+silently return nil. The analyzer records the key types and the types of
+the values stored in contexts, and reports
+  - lookups with a key of a type which is never stored, so that they
+    always return nil,
+  - lookups whose result is asserted to a type no value stored with the key
+    has, so that the assertion always fails, and
+  - values stored with keys of an unexported type of the package which are
+    never looked up in it.
+
+Stores are looked up in the package and its dependencies, so keys stored by
+a package which isn't imported by the looking up package can't be taken
+into account. The last check is therefore limited to keys which can't be
+used by other packages: those of unexported types, which are not exposed
+by exported variables, constants or functions.`
+
+var Analyzer = &analysis.Analyzer{
+	Name: "ctxkey",
+	Doc:  Doc,
+	Run:  run,
+	Requires: []*analysis.Analyzer{
+		nodefilter.Analyzer,
+	},
+	FactTypes: []analysis.Fact{new(stored)},
+}
+
+var (
+	calls   = nodefilter.New(false, new(ast.CallExpr))
+	asserts = nodefilter.New(false, new(ast.TypeAssertExpr))
+)
+
+// stored is a package fact listing the keys stored in contexts in a
+// package.
+type stored struct {
+	Keys []storedKey
+}
+
+// A storedKey is the type of a key stored in contexts, with the types of
+// the values stored with it.
+type storedKey struct {
+	Key    string
+	Values []string // sorted, "" if one is an interface
+}
+
+func (*stored) AFact() {}
+
+func (s *stored) String() string {
+	var keys []string
+	for _, k := range s.Keys {
+		keys = append(keys, k.Key+" ("+strings.Join(k.Values, ", ")+")")
+	}
+	return "stores " + strings.Join(keys, ", ")
+}
+
+// A lookup is a call of the Value method of a context.
+type lookup struct {
+	call   *ast.CallExpr
+	key    types.Type
+	assert types.Type // the type its result is asserted to, or nil
+}
+
+// A store is a call of context.WithValue.
+type store struct {
+	call *ast.CallExpr
+	key  types.Type
+}
+
+func run(pass *analysis.Pass) (interface{}, error) {
+	nodes := pass.ResultOf[nodefilter.Analyzer].(*nodefilter.Result)
+
+	asserted := make(map[*ast.CallExpr]types.Type)
+	for _, n := range nodes.Nodes(asserts) {
+		ta := n.Node.(*ast.TypeAssertExpr)
+		if call, ok := analysisutil.Unparen(ta.X).(*ast.CallExpr); ok && ta.Type != nil {
+			asserted[call] = pass.TypesInfo.TypeOf(ta.Type)
+		}
+	}
+
+	var (
+		lookups []lookup
+		stores  []store
+		values  = make(map[string]map[string]bool) // stored value types, by key
+	)
+	for _, n := range nodes.Nodes(calls) {
+		call := n.Node.(*ast.CallExpr)
+		switch analysisutil.CalleeName(pass.TypesInfo, call) {
+		case "context.WithValue":
+			if len(call.Args) != 3 {
+				continue
+			}
+			key := keyType(pass.TypesInfo.TypeOf(call.Args[1]))
+			if key == nil {
+				continue
+			}
+			stores = append(stores, store{call, key})
+			vs := values[typeString(key)]
+			if vs == nil {
+				vs = make(map[string]bool)
+				values[typeString(key)] = vs
+			}
+			vs[valueType(pass.TypesInfo.TypeOf(call.Args[2]))] = true
+		case "(context.Context).Value":
+			if len(call.Args) != 1 {
+				continue
+			}
+			if key := keyType(pass.TypesInfo.TypeOf(call.Args[0])); key != nil {
+				lookups = append(lookups, lookup{call, key, asserted[call]})
+			}
+		}
+	}
+
+	if len(values) > 0 {
+		own := new(stored)
+		for k, vs := range values {
+			own.Keys = append(own.Keys, storedKey{Key: k, Values: sortedKeys(vs)})
+		}
+		sort.Slice(own.Keys, func(i, j int) bool { return own.Keys[i].Key < own.Keys[j].Key })
+		pass.ExportPackageFact(own)
+	}
+	for _, f := range pass.AllPackageFacts() {
+		s, ok := f.Fact.(*stored)
+		if !ok || f.Package == pass.Pkg {
+			continue
+		}
+		for _, k := range s.Keys {
+			vs := values[k.Key]
+			if vs == nil {
+				vs = make(map[string]bool)
+				values[k.Key] = vs
+			}
+			for _, v := range k.Values {
+				vs[v] = true
+			}
+		}
+	}
+
+	read := make(map[string]bool)
+	for _, l := range lookups {
+		key := typeString(l.key)
+		read[key] = true
+		if analysisutil.IsGenerated(pass, l.call.Pos()) {
+			continue
+		}
+		vs, ok := values[key]
+		if !ok {
+			pass.Reportf(l.call.Pos(), "no value is stored in contexts with a key of type %s, so this lookup always returns nil", key)
+			continue
+		}
+		if l.assert == nil || types.IsInterface(l.assert) || vs[""] {
+			continue
+		}
+		if t := typeString(l.assert); !vs[t] {
+			pass.Reportf(l.call.Pos(), "the value of key type %s is asserted to %s, but only values of type %s are stored with it", key, t, strings.Join(sortedKeys(vs), ", "))
+		}
+	}
+	for _, s := range stores {
+		key := typeString(s.key)
+		if read[key] || !private(pass.Pkg, s.key) || analysisutil.IsGenerated(pass, s.call.Pos()) {
+			continue
+		}
+		pass.Reportf(s.call.Pos(), "the value stored with a key of type %s is never looked up", key)
+	}
+	return nil, nil
+}
+
+// keyType returns t, if it is a named type or a pointer to one, which can be
+// told apart from the keys of other packages. It returns nil otherwise.
+func keyType(t types.Type) types.Type {
+	if t == nil || types.IsInterface(t) {
+		return nil
+	}
+	n, _ := t.(*types.Named)
+	if p, ok := t.(*types.Pointer); ok {
+		n, _ = p.Elem().(*types.Named)
+	}
+	if n == nil || n.Obj().Pkg() == nil {
+		return nil
+	}
+	return t
+}
+
+// valueType returns the name of the type of a stored value, or "" if it is
+// an interface, whose dynamic type isn't known.
+func valueType(t types.Type) string {
+	if t == nil || types.IsInterface(t) {
+		return ""
+	}
+	return typeString(t)
+}
+
+func typeString(t types.Type) string {
+	return types.TypeString(t, func(p *types.Package) string {
+		return analysisutil.TrimVendor(p.Path())
+	})
+}
+
+// private returns whether values of the key type t can only be used in
+// pkg: its type is unexported, and not exposed by exported package-level
+// variables, constants or function results.
+func private(pkg *types.Package, t types.Type) bool {
+	n, ok := t.(*types.Named)
+	if p, isPtr := t.(*types.Pointer); isPtr {
+		n, ok = p.Elem().(*types.Named)
+	}
+	if !ok || n.Obj().Pkg() != pkg || n.Obj().Exported() {
+		return false
+	}
+	exposes := func(u types.Type) bool {
+		if p, ok := u.(*types.Pointer); ok {
+			u = p.Elem()
+		}
+		return types.Identical(u, n)
+	}
+	scope := pkg.Scope()
+	for _, name := range scope.Names() {
+		obj := scope.Lookup(name)
+		if !obj.Exported() {
+			continue
+		}
+		switch obj := obj.(type) {
+		case *types.Var, *types.Const:
+			if exposes(obj.Type()) {
+				return false
+			}
+		case *types.Func:
+			res := obj.Type().(*types.Signature).Results()
+			for i := 0; i < res.Len(); i++ {
+				if exposes(res.At(i).Type()) {
+					return false
+				}
+			}
+		}
+	}
+	return true
+}
+
+func sortedKeys(m map[string]bool) []string {
+	var s []string
+	for k := range m {
+		s = append(s, k)
+	}
+	sort.Strings(s)
+	return s
+}
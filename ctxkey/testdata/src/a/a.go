@@ -0,0 +1,47 @@
+package a // want package:"stores \\*a.ptrKey \\(int\\), a.exposedKey \\(string\\), a.reqKey \\(a.Request\\), a.traceKey \\(string\\)"
+
+import (
+	"context"
+	"fmt"
+
+	"example.com/auth"
+)
+
+type Request struct{ ID int }
+
+type (
+	reqKey     struct{}
+	traceKey   int
+	spanKey    struct{}
+	ptrKey     struct{}
+	exposedKey struct{}
+)
+
+var ExposedKey = exposedKey{}
+
+func Store(ctx context.Context) context.Context {
+	ctx = context.WithValue(ctx, reqKey{}, Request{1})
+	ctx = context.WithValue(ctx, traceKey(0), "trace") // want `the value stored with a key of type a.traceKey is never looked up`
+	ctx = context.WithValue(ctx, &ptrKey{}, 1)         // want `the value stored with a key of type \*a.ptrKey is never looked up`
+	ctx = context.WithValue(ctx, ExposedKey, "x")
+	return context.WithValue(ctx, "legacy", 1)
+}
+
+func Load(ctx context.Context) {
+	req, _ := ctx.Value(reqKey{}).(Request)
+	fmt.Println(req)
+	p, _ := ctx.Value(reqKey{}).(*Request) // want `the value of key type a.reqKey is asserted to \*a.Request, but only values of type a.Request are stored with it`
+	fmt.Println(p)
+	fmt.Println(ctx.Value(spanKey{})) // want `no value is stored in contexts with a key of type a.spanKey, so this lookup always returns nil`
+	fmt.Println(ctx.Value(ptrKey{}))  // want `no value is stored in contexts with a key of type a.ptrKey`
+	fmt.Println(ctx.Value("legacy"))
+	s, _ := ctx.Value(fmt.Stringer(nil)).(string)
+	fmt.Println(s)
+}
+
+func User(ctx context.Context) {
+	name, _ := ctx.Value(auth.UserKey{}).(string)
+	id, _ := ctx.Value(auth.UserKey{}).(int) // want `the value of key type example.com/auth.UserKey is asserted to int, but only values of type string are stored with it`
+	_, _ = ctx.Value(auth.UserKey{}).(fmt.Stringer)
+	fmt.Println(name, id, ctx.Value(auth.RoleKey{})) // want `no value is stored in contexts with a key of type example.com/auth.RoleKey`
+}
@@ -0,0 +1,11 @@
+package auth // want package:"stores example.com/auth.UserKey \\(string\\)"
+
+import "context"
+
+type UserKey struct{}
+
+type RoleKey struct{}
+
+func WithUser(ctx context.Context, name string) context.Context {
+	return context.WithValue(ctx, UserKey{}, name)
+}
@@ -0,0 +1,223 @@
+// Copyright 2019 Axel Wagner
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package ctxloop defines an Analyzer that checks for loops ignoring the
+// cancellation of a context.
+package ctxloop
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+
+	"github.com/Merovius/go-tools/internal/analysisutil"
+	"github.com/Merovius/go-tools/internal/nodefilter"
+	"golang.org/x/tools/go/analysis"
+)
+
+const Doc = `check for loops ignoring the cancellation of a context
+
+In functions taking a context.Context, the analyzer reports loops which
+block in channel operations or network calls, but never look at the
+context: neither checking ctx.Done() or ctx.Err(), nor passing it on. Such
+workers keep running, or hang, after their context is canceled, which
+breaks graceful shutdown. Only the outermost such loop is reported.
+
+Selects with a default don't block. Loops passing the context to any call,
+like http.NewRequestWithContext, are assumed to be canceled by it.`
+
+var Analyzer = &analysis.Analyzer{
+	Name: "ctxloop",
+	Doc:  Doc,
+	Run:  run,
+	Requires: []*analysis.Analyzer{
+		nodefilter.Analyzer,
+	},
+}
+
+var funcs = nodefilter.New(false, new(ast.FuncDecl), new(ast.FuncLit))
+
+// network are blocking network calls.
+var network = []string{
+	"net.Dial",
+	"net.DialTimeout",
+	"(*net.Dialer).Dial",
+	"(net.Conn).Read",
+	"(net.Conn).Write",
+	"(*net.TCPConn).Read",
+	"(*net.TCPConn).Write",
+	"(*net.UDPConn).Read",
+	"(*net.UDPConn).ReadFrom",
+	"(*net.UDPConn).ReadFromUDP",
+	"(net.PacketConn).ReadFrom",
+	"(net.Listener).Accept",
+	"(*net.TCPListener).Accept",
+	"(*net.TCPListener).AcceptTCP",
+	"net/http.Get",
+	"net/http.Post",
+	"net/http.Head",
+	"(*net/http.Client).Do",
+	"(*net/http.Client).Get",
+	"(*net/http.Client).Post",
+	"(*net/http.Client).Head",
+}
+
+func run(pass *analysis.Pass) (interface{}, error) {
+	if !analysisutil.Imports(pass.Pkg, "context") {
+		return nil, nil
+	}
+	nodes := pass.ResultOf[nodefilter.Analyzer].(*nodefilter.Result)
+	for _, n := range nodes.Nodes(funcs) {
+		var (
+			typ  *ast.FuncType
+			body *ast.BlockStmt
+		)
+		switch fn := n.Node.(type) {
+		case *ast.FuncDecl:
+			typ, body = fn.Type, fn.Body
+		case *ast.FuncLit:
+			typ, body = fn.Type, fn.Body
+		}
+		if body == nil {
+			continue
+		}
+		if ctx := contextParam(pass, typ); ctx != nil {
+			checkLoops(pass, ctx, body)
+		}
+	}
+	return nil, nil
+}
+
+// contextParam returns the context.Context parameter of typ, or nil.
+func contextParam(pass *analysis.Pass, typ *ast.FuncType) types.Object {
+	for _, f := range typ.Params.List {
+		for _, name := range f.Names {
+			obj := pass.TypesInfo.Defs[name]
+			if obj != nil && name.Name != "_" && analysisutil.IsType(obj.Type(), "context.Context") {
+				return obj
+			}
+		}
+	}
+	return nil
+}
+
+// checkLoops reports the outermost loops in n blocking without using ctx.
+func checkLoops(pass *analysis.Pass, ctx types.Object, n ast.Node) {
+	ast.Inspect(n, func(n ast.Node) bool {
+		switch n.(type) {
+		case *ast.FuncLit:
+			// Checked on their own, if they take a context.
+			return false
+		case *ast.ForStmt, *ast.RangeStmt:
+			if uses(pass, n, ctx) {
+				return true
+			}
+			if what := blocks(pass, n); what != "" {
+				pass.Reportf(n.Pos(), "loop blocks in %s, but never checks %s.Done() or %s.Err(); it keeps running after %s is canceled", what, ctx.Name(), ctx.Name(), ctx.Name())
+				return false
+			}
+		}
+		return true
+	})
+}
+
+// uses returns whether n refers to obj.
+func uses(pass *analysis.Pass, n ast.Node, obj types.Object) bool {
+	found := false
+	ast.Inspect(n, func(n ast.Node) bool {
+		if id, ok := n.(*ast.Ident); ok && pass.TypesInfo.Uses[id] == obj {
+			found = true
+		}
+		return !found
+	})
+	return found
+}
+
+// blocks returns a description of the first blocking operation in loop,
+// or "" if there is none.
+func blocks(pass *analysis.Pass, loop ast.Node) string {
+	what := ""
+	if r, ok := loop.(*ast.RangeStmt); ok && isChan(pass, r.X) {
+		return "a range over a channel"
+	}
+	// Channel operations in the cases of selects with a default don't
+	// block.
+	nonBlocking := make(map[ast.Node]bool)
+	ast.Inspect(loop, func(n ast.Node) bool {
+		if what != "" {
+			return false
+		}
+		switch n := n.(type) {
+		case *ast.FuncLit:
+			return false
+		case *ast.SelectStmt:
+			for _, s := range n.Body.List {
+				if s.(*ast.CommClause).Comm == nil {
+					for _, s := range n.Body.List {
+						if comm := s.(*ast.CommClause).Comm; comm != nil {
+							nonBlocking[comm] = true
+						}
+					}
+					break
+				}
+			}
+		case *ast.CommClause:
+			if nonBlocking[n.Comm] {
+				for _, s := range n.Body {
+					ast.Inspect(s, func(n ast.Node) bool {
+						if what == "" {
+							what = blocking(pass, n)
+						}
+						return what == ""
+					})
+				}
+				return false
+			}
+		case *ast.RangeStmt:
+			if n != loop && isChan(pass, n.X) {
+				what = "a range over a channel"
+			}
+		default:
+			what = blocking(pass, n)
+		}
+		return what == ""
+	})
+	return what
+}
+
+// blocking returns a description of n if it is a blocking operation.
+func blocking(pass *analysis.Pass, n ast.Node) string {
+	switch n := n.(type) {
+	case *ast.SendStmt:
+		return "a send on a channel"
+	case *ast.UnaryExpr:
+		if n.Op == token.ARROW {
+			return "a receive from a channel"
+		}
+	case *ast.CallExpr:
+		if analysisutil.IsCall(pass.TypesInfo, n, network...) {
+			return "a call of " + analysisutil.CalleeName(pass.TypesInfo, n)
+		}
+	}
+	return ""
+}
+
+func isChan(pass *analysis.Pass, e ast.Expr) bool {
+	t := pass.TypesInfo.TypeOf(e)
+	if t == nil {
+		return false
+	}
+	_, ok := t.Underlying().(*types.Chan)
+	return ok
+}
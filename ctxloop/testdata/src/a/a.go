@@ -0,0 +1,100 @@
+package a
+
+import (
+	"context"
+	"net"
+	"net/http"
+)
+
+func worker(ctx context.Context, jobs chan int) {
+	for { // want `loop blocks in a receive from a channel, but never checks ctx.Done\(\) or ctx.Err\(\)`
+		j := <-jobs
+		_ = j
+	}
+}
+
+func rangeWorker(ctx context.Context, jobs chan int) {
+	for j := range jobs { // want `loop blocks in a range over a channel`
+		_ = j
+	}
+}
+
+func selectWorker(ctx context.Context, jobs chan int) {
+	for {
+		select {
+		case j := <-jobs:
+			_ = j
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func errWorker(ctx context.Context, jobs chan int) {
+	for ctx.Err() == nil {
+		<-jobs
+	}
+}
+
+func producer(ctx context.Context, out chan<- int, items []int) {
+	for _, x := range items { // want `loop blocks in a send on a channel`
+		out <- x
+	}
+}
+
+func poller(ctx context.Context, jobs chan int) {
+	for i := 0; i < 10; i++ {
+		select {
+		case <-jobs:
+		default:
+		}
+	}
+}
+
+func server(ctx context.Context, l net.Listener) {
+	for { // want `loop blocks in a call of \(net.Listener\).Accept`
+		c, err := l.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			for {
+				var buf [64]byte
+				if _, err := c.Read(buf[:]); err != nil {
+					return
+				}
+			}
+		}()
+	}
+}
+
+func fetch(ctx context.Context, urls []string) {
+	for _, u := range urls {
+		req, err := http.NewRequestWithContext(ctx, "GET", u, nil)
+		if err != nil {
+			return
+		}
+		http.DefaultClient.Do(req)
+	}
+}
+
+func nested(ctx context.Context, batches [][]int, out chan int) {
+	for _, b := range batches { // want `loop blocks in a send on a channel`
+		for _, x := range b {
+			out <- x
+		}
+	}
+}
+
+func noContext(jobs chan int) {
+	for range jobs {
+	}
+}
+
+func literal(jobs chan int) {
+	run := func(ctx context.Context) {
+		for range jobs { // want `loop blocks in a range over a channel`
+		}
+	}
+	_ = run
+}
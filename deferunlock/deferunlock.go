@@ -0,0 +1,132 @@
+// Copyright 2019 Axel Wagner
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package deferunlock defines an Analyzer that checks for deferred unlocks
+// in small leaf functions.
+package deferunlock
+
+import (
+	"go/ast"
+	"go/types"
+
+	"github.com/Merovius/go-tools/internal/analysisutil"
+	"github.com/Merovius/go-tools/internal/goversion"
+	"github.com/Merovius/go-tools/internal/nodefilter"
+	"golang.org/x/tools/go/analysis"
+)
+
+const Doc = `check for deferred unlocks in small leaf functions
+
+Before Go 1.14, which open-codes most defers, a defer costs about 35ns per
+call. In a function which only locks a mutex, does a few statements of work
+without calling other functions and unlocks it again, that is most of its
+run time. The analyzer reports such functions with a single deferred unlock
+in modules whose go directive is older than go1.14, where they might still
+be built with an older toolchain. Functions with more statements than given
+by the -max-statements flag are not reported.
+
+The overhead only matters in hot functions. Run with a CPU profile (the
+-profile flag of gotools) to only report functions the profile shows to be
+hot, annotated with their share of samples.`
+
+var Analyzer = &analysis.Analyzer{
+	Name: "deferunlock",
+	Doc:  Doc,
+	Run:  run,
+	Requires: []*analysis.Analyzer{
+		goversion.Analyzer,
+		nodefilter.Analyzer,
+	},
+}
+
+var maxStatements = 4
+
+func init() {
+	Analyzer.Flags.IntVar(&maxStatements, "max-statements", maxStatements, "maximum number of statements, including the lock and the defer, of reported functions")
+}
+
+var funcs = nodefilter.New(false, new(ast.FuncDecl))
+
+// unlocks are the methods unlocking mutexes.
+var unlocks = []string{
+	"(*sync.Mutex).Unlock",
+	"(*sync.RWMutex).Unlock",
+	"(*sync.RWMutex).RUnlock",
+}
+
+func run(pass *analysis.Pass) (interface{}, error) {
+	if !analysisutil.Imports(pass.Pkg, "sync") {
+		return nil, nil
+	}
+	versions := pass.ResultOf[goversion.Analyzer].(*goversion.Result)
+	nodes := pass.ResultOf[nodefilter.Analyzer].(*nodefilter.Result)
+	for _, n := range nodes.Nodes(funcs) {
+		fn := n.Node.(*ast.FuncDecl)
+		if fn.Body == nil || versions.AtLeast(analysisutil.File(pass, fn.Pos()), "go1.14") {
+			continue
+		}
+		checkFunc(pass, fn)
+	}
+	return nil, nil
+}
+
+func checkFunc(pass *analysis.Pass, fn *ast.FuncDecl) {
+	var (
+		unlock *ast.DeferStmt
+		stmts  int
+		leaf   = true
+	)
+	ast.Inspect(fn.Body, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.FuncLit:
+			leaf = false
+		case *ast.DeferStmt:
+			if unlock != nil || !analysisutil.IsCall(pass.TypesInfo, n.Call, unlocks...) {
+				leaf = false
+				return false
+			}
+			unlock = n
+			stmts++
+			return false
+		case *ast.BlockStmt:
+		case ast.Stmt:
+			stmts++
+		case *ast.CallExpr:
+			if !cheap(pass, n) {
+				leaf = false
+			}
+		}
+		return leaf
+	})
+	if !leaf || unlock == nil || stmts > maxStatements {
+		return
+	}
+	call := analysisutil.Render(pass.Fset, unlock.Call)
+	pass.Reportf(unlock.Pos(), "deferred %s in a leaf function of %d statements; before Go 1.14, the defer adds about 35ns per call, which dominates the function; unlock explicitly instead",
+		call, stmts)
+}
+
+// cheap returns whether call is a conversion, a call to a builtin or locks
+// a mutex.
+func cheap(pass *analysis.Pass, call *ast.CallExpr) bool {
+	if tv, ok := pass.TypesInfo.Types[call.Fun]; ok && (tv.IsType() || tv.IsBuiltin()) {
+		return true
+	}
+	if id, ok := analysisutil.Unparen(call.Fun).(*ast.Ident); ok {
+		if _, ok := pass.TypesInfo.Uses[id].(*types.Builtin); ok {
+			return true
+		}
+	}
+	return analysisutil.IsCall(pass.TypesInfo, call, "(*sync.Mutex).Lock", "(*sync.RWMutex).Lock", "(*sync.RWMutex).RLock")
+}
@@ -0,0 +1,51 @@
+package a
+
+import (
+	"fmt"
+	"sync"
+)
+
+type counter struct {
+	mu sync.Mutex
+	n  map[string]int
+}
+
+func (c *counter) Inc(k string) {
+	c.mu.Lock()
+	defer c.mu.Unlock() // want `deferred c.mu.Unlock\(\) in a leaf function of 3 statements; before Go 1.14, the defer adds about 35ns per call, which dominates the function; unlock explicitly instead`
+	c.n[k]++
+}
+
+func (c *counter) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock() // want `leaf function of 3 statements`
+	return len(c.n)
+}
+
+func (c *counter) Print() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	fmt.Println(c.n)
+}
+
+func (c *counter) Reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for k := range c.n {
+		if k != "" {
+			delete(c.n, k)
+		}
+	}
+}
+
+type cache struct {
+	mu sync.RWMutex
+	m  map[string]string
+}
+
+func (c *cache) Get(k string) (string, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock() // want `deferred c.mu.RUnlock\(\)`
+	v, ok := c.m[k]
+	return v, ok
+}
@@ -0,0 +1,14 @@
+package modern
+
+import "sync"
+
+var (
+	mu sync.Mutex
+	n  int
+)
+
+func Inc() {
+	mu.Lock()
+	defer mu.Unlock()
+	n++
+}
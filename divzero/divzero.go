@@ -0,0 +1,193 @@
+// Copyright 2019 Axel Wagner
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package divzero defines an Analyzer that checks for integer divisions by
+// values which can be zero.
+package divzero
+
+import (
+	"go/ast"
+	"go/constant"
+	"go/token"
+	"go/types"
+
+	"github.com/Merovius/go-tools/internal/analysisutil"
+	"github.com/Merovius/go-tools/internal/nodefilter"
+	"github.com/Merovius/go-tools/internal/ssaflow"
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/passes/buildssa"
+	"golang.org/x/tools/go/ssa"
+)
+
+const Doc = `check for integer division by values which can be zero
+
+Integer division and modulo by zero panic. The analyzer reports divisors
+which are zero in common cases, like the length of a possibly empty slice or
+string, or the result of strings.Count, unless the division is only reached
+if the divisor was checked to not be zero, as in
+
+	if len(s) == 0 {
+		return 0
+	}
+	return sum / len(s)
+
+Loop conditions like i < len(s) are assumed to guard the loop body, as loop
+indices are usually not negative.`
+
+var Analyzer = &analysis.Analyzer{
+	Name: "divzero",
+	Doc:  Doc,
+	Run:  run,
+	Requires: []*analysis.Analyzer{
+		buildssa.Analyzer,
+		nodefilter.Analyzer,
+	},
+}
+
+var exprs = nodefilter.New(false, new(ast.BinaryExpr), new(ast.AssignStmt))
+
+// counts lists functions returning counts, which are zero in common cases.
+var counts = map[string]bool{
+	"strings.Count":                  true,
+	"bytes.Count":                    true,
+	"unicode/utf8.RuneCount":         true,
+	"unicode/utf8.RuneCountInString": true,
+}
+
+func run(pass *analysis.Pass) (interface{}, error) {
+	// Divisors in the source, by the position SSA uses for the division.
+	divisors := make(map[token.Pos]ast.Expr)
+	nodes := pass.ResultOf[nodefilter.Analyzer].(*nodefilter.Result)
+	for _, n := range nodes.Nodes(exprs) {
+		switch n := n.Node.(type) {
+		case *ast.BinaryExpr:
+			if n.Op == token.QUO || n.Op == token.REM {
+				divisors[n.OpPos] = n.Y
+			}
+		case *ast.AssignStmt:
+			if (n.Tok == token.QUO_ASSIGN || n.Tok == token.REM_ASSIGN) && len(n.Rhs) == 1 {
+				divisors[n.Pos()] = n.Rhs[0]
+			}
+		}
+	}
+
+	ssainfo := pass.ResultOf[buildssa.Analyzer].(*buildssa.SSA)
+	for _, fn := range ssainfo.SrcFuncs {
+		for _, b := range fn.Blocks {
+			for _, instr := range b.Instrs {
+				op, ok := instr.(*ssa.BinOp)
+				if !ok || op.Op != token.QUO && op.Op != token.REM {
+					continue
+				}
+				if t, ok := op.Type().Underlying().(*types.Basic); !ok || t.Info()&types.IsInteger == 0 {
+					continue
+				}
+				if !possiblyZero(op.Y) || guarded(b, op.Y) {
+					continue
+				}
+				what := "division"
+				if op.Op == token.REM {
+					what = "modulo"
+				}
+				divisor := "a value"
+				if e, ok := divisors[op.Pos()]; ok {
+					divisor = analysisutil.Render(pass.Fset, e)
+				}
+				pass.Reportf(op.Pos(), "integer %s by %s, which can be zero; check it first", what, divisor)
+			}
+		}
+	}
+	return nil, nil
+}
+
+// possiblyZero returns whether v is a length or count, which is zero in
+// common cases.
+func possiblyZero(v ssa.Value) bool {
+	if call := ssaflow.BuiltinCall(v, "len", "cap"); call != nil {
+		return len(call.Call.Args) == 1 && !nonEmpty(call.Call.Args[0])
+	}
+	call, ok := ssaflow.Unconvert(v).(*ssa.Call)
+	if !ok {
+		return false
+	}
+	if fn := call.Call.StaticCallee(); fn != nil {
+		return counts[fn.String()]
+	}
+	return false
+}
+
+// nonEmpty returns whether v is known not to be empty, because it is the
+// result of append or of a non-empty composite literal.
+func nonEmpty(v ssa.Value) bool {
+	switch v := v.(type) {
+	case *ssa.Call:
+		return ssaflow.BuiltinCall(v, "append") != nil
+	case *ssa.Slice:
+		if v.Low != nil || v.High != nil {
+			return false
+		}
+		p, ok := v.X.Type().Underlying().(*types.Pointer)
+		if !ok {
+			return false
+		}
+		a, ok := p.Elem().Underlying().(*types.Array)
+		return ok && a.Len() > 0
+	case *ssa.Const:
+		return v.Value != nil && v.Value.Kind() == constant.String && constant.StringVal(v.Value) != ""
+	}
+	return false
+}
+
+// guarded returns whether b can only be reached after checking that v is
+// not zero.
+func guarded(b *ssa.BasicBlock, v ssa.Value) bool {
+	for _, c := range ssaflow.Conditions(b) {
+		c, ok := c.About(v)
+		if !ok {
+			continue
+		}
+		n, ok := c.Int()
+		if !ok {
+			// Assume that v is compared to a loop index, which is
+			// not negative.
+			if _, isConst := c.Y.(*ssa.Const); !isConst && c.Op == token.GTR {
+				return true
+			}
+			continue
+		}
+		if excludesZero(c.Op, n) {
+			return true
+		}
+	}
+	return false
+}
+
+// excludesZero returns whether v op n implies that v is not zero.
+func excludesZero(op token.Token, n int64) bool {
+	switch op {
+	case token.NEQ:
+		return n == 0
+	case token.EQL:
+		return n != 0
+	case token.GTR:
+		return n >= 0
+	case token.GEQ:
+		return n >= 1
+	case token.LSS:
+		return n <= 0
+	case token.LEQ:
+		return n < 0
+	}
+	return false
+}
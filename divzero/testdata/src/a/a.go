@@ -0,0 +1,89 @@
+package a
+
+import "strings"
+
+func mean(xs []int) int {
+	sum := 0
+	for _, x := range xs {
+		sum += x
+	}
+	return sum / len(xs) // want `integer division by len\(xs\), which can be zero; check it first`
+}
+
+func meanChecked(xs []int) int {
+	if len(xs) == 0 {
+		return 0
+	}
+	sum := 0
+	for _, x := range xs {
+		sum += x
+	}
+	return sum / len(xs)
+}
+
+func meanPositive(xs []int) int64 {
+	n := len(xs)
+	if n > 0 {
+		return int64(100) / int64(n)
+	}
+	return int64(100) % int64(len(xs)) // want `integer modulo by int64\(len\(xs\)\), which can be zero`
+}
+
+func pick(xs []string, seed int) string {
+	if len(xs) < 1 {
+		return ""
+	}
+	return xs[seed%len(xs)]
+}
+
+func loop(xs []int) {
+	for i := 0; i < len(xs); i++ {
+		xs[i] = 100 / len(xs)
+	}
+}
+
+func compound(s string, x int) int {
+	x /= strings.Count(s, ",") // want `integer division by strings.Count\(s, ","\), which can be zero`
+	return x
+}
+
+func nonEmpty(xs []int) int {
+	ys := []int{1, 2, 3}
+	zs := append(xs, 4)
+	return 100/len(ys) + 100/len(zs)
+}
+
+func floats(xs []float64) float64 {
+	return 1 / float64(len(xs))
+}
+
+func notZero(xs []int) int {
+	if len(xs) != 0 {
+		return 100 / len(xs)
+	}
+	return 0
+}
+
+type stats struct {
+	items []int
+	sum   int
+}
+
+func (s *stats) mean() int {
+	if len(s.items) == 0 {
+		return 0
+	}
+	return s.sum / len(s.items)
+}
+
+func (s *stats) meanUnchecked() int {
+	return s.sum / len(s.items) // want `integer division by len\(s.items\), which can be zero`
+}
+
+func (s *stats) meanReset() int {
+	if len(s.items) == 0 {
+		return 0
+	}
+	s.items = nil
+	return s.sum / len(s.items) // want `integer division by len\(s.items\), which can be zero`
+}
@@ -0,0 +1,254 @@
+// Copyright 2019 Axel Wagner
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package doccomment defines an Analyzer that checks the doc comments of
+// exported API.
+package doccomment
+
+import (
+	"go/ast"
+	"go/token"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/Merovius/go-tools/internal/analysisutil"
+	"github.com/Merovius/go-tools/internal/nodefilter"
+	"golang.org/x/tools/go/analysis"
+)
+
+const Doc = `check the doc comments of exported API
+
+The analyzer reports
+  - doc comments of exported identifiers not starting with their name
+    (optionally preceded by an article), as godoc and readers expect,
+  - deprecation notices not detected by tools, because they don't start a
+    paragraph with "Deprecated: ", and
+  - doc comments of functions referring to a mixedCaps name that appears
+    nowhere in the package, usually a parameter that was renamed.
+
+Generated files are not checked.`
+
+var Analyzer = &analysis.Analyzer{
+	Name: "doccomment",
+	Doc:  Doc,
+	Run:  run,
+	Requires: []*analysis.Analyzer{
+		nodefilter.Analyzer,
+	},
+}
+
+var (
+	decls  = nodefilter.New(false, new(ast.FuncDecl), new(ast.GenDecl))
+	idents = nodefilter.New(false, new(ast.Ident))
+)
+
+func run(pass *analysis.Pass) (interface{}, error) {
+	nodes := pass.ResultOf[nodefilter.Analyzer].(*nodefilter.Result)
+	known := make(map[string]bool)
+	for _, n := range nodes.Nodes(idents) {
+		known[n.Node.(*ast.Ident).Name] = true
+	}
+	for _, f := range pass.Files {
+		if f.Doc != nil && !analysisutil.IsGenerated(pass, f.Pos()) {
+			checkDeprecated(pass, f.Doc)
+		}
+	}
+	for _, n := range nodes.Nodes(decls) {
+		if analysisutil.IsGenerated(pass, n.Node.Pos()) {
+			continue
+		}
+		switch d := n.Node.(type) {
+		case *ast.FuncDecl:
+			if d.Doc == nil {
+				continue
+			}
+			checkDeprecated(pass, d.Doc)
+			if d.Name.IsExported() && (d.Recv == nil || exportedRecv(d.Recv)) {
+				checkStart(pass, d.Doc, d.Name.Name)
+			}
+			checkParams(pass, d, known)
+		case *ast.GenDecl:
+			if d.Doc != nil {
+				checkDeprecated(pass, d.Doc)
+			}
+			for _, s := range d.Specs {
+				doc := specDoc(s)
+				if doc != nil {
+					checkDeprecated(pass, doc)
+				} else if !d.Lparen.IsValid() {
+					doc = d.Doc
+				}
+				if doc == nil {
+					continue
+				}
+				switch s := s.(type) {
+				case *ast.TypeSpec:
+					if s.Name.IsExported() {
+						checkStart(pass, doc, s.Name.Name)
+					}
+				case *ast.ValueSpec:
+					// Doc comments on groups of constants or variables
+					// describe all of them.
+					if len(s.Names) == 1 && s.Names[0].IsExported() {
+						checkStart(pass, doc, s.Names[0].Name)
+					}
+				}
+			}
+		}
+	}
+	return nil, nil
+}
+
+func specDoc(s ast.Spec) *ast.CommentGroup {
+	switch s := s.(type) {
+	case *ast.TypeSpec:
+		return s.Doc
+	case *ast.ValueSpec:
+		return s.Doc
+	}
+	return nil
+}
+
+// exportedRecv returns whether the base type of the receiver is exported.
+// Methods of unexported types are not part of the documentation.
+func exportedRecv(recv *ast.FieldList) bool {
+	if len(recv.List) != 1 {
+		return false
+	}
+	t := recv.List[0].Type
+	if s, ok := t.(*ast.StarExpr); ok {
+		t = s.X
+	}
+	id, ok := t.(*ast.Ident)
+	return ok && id.IsExported()
+}
+
+func checkStart(pass *analysis.Pass, doc *ast.CommentGroup, name string) {
+	text := doc.Text()
+	for _, article := range []string{"A ", "An ", "The "} {
+		text = strings.TrimPrefix(text, article)
+	}
+	if strings.HasPrefix(text, name) {
+		rest := strings.TrimPrefix(text, name)
+		if rest == "" || !isIdent(rune(rest[0])) {
+			return
+		}
+	}
+	if strings.HasPrefix(doc.Text(), "Deprecated: ") {
+		return
+	}
+	pass.Reportf(doc.Pos(), "doc comment of %s should start with its name", name)
+}
+
+func isIdent(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// A line is a line of a comment, without the comment markers.
+type line struct {
+	pos  token.Pos
+	text string
+}
+
+func lines(doc *ast.CommentGroup) []line {
+	var out []line
+	for _, c := range doc.List {
+		text := c.Text[2:]
+		off := 2
+		if c.Text[1] == '*' {
+			text = text[:len(text)-2]
+		}
+		for {
+			i := strings.IndexByte(text, '\n')
+			l := text
+			if i >= 0 {
+				l = text[:i]
+			}
+			out = append(out, line{c.Slash + token.Pos(off), strings.TrimSpace(l)})
+			if i < 0 {
+				break
+			}
+			text, off = text[i+1:], off+i+1
+		}
+	}
+	return out
+}
+
+// deprecated matches lines which look like deprecation notices.
+var deprecated = regexp.MustCompile(`^(?i:deprecated)(\s*[-:.!]|$)`)
+
+func checkDeprecated(pass *analysis.Pass, doc *ast.CommentGroup) {
+	ls := lines(doc)
+	for i, l := range ls {
+		start := i == 0 || ls[i-1].text == ""
+		if start && strings.HasPrefix(l.text, "Deprecated: ") {
+			continue
+		}
+		switch {
+		case deprecated.MatchString(l.text) && start:
+			pass.Report(analysis.Diagnostic{
+				Pos:      l.pos,
+				Category: "deprecated",
+				Message:  `deprecation notice is not detected by tools; start it with "Deprecated: "`,
+			})
+		case deprecated.MatchString(l.text):
+			pass.Report(analysis.Diagnostic{
+				Pos:      l.pos,
+				Category: "deprecated",
+				Message:  "deprecation notice is not detected by tools; separate it from the previous paragraph by an empty line",
+			})
+		case strings.Contains(l.text, " Deprecated: "):
+			pass.Report(analysis.Diagnostic{
+				Pos:      l.pos,
+				Category: "deprecated",
+				Message:  `deprecation notice is not detected by tools; start a new paragraph with "Deprecated: "`,
+			})
+		}
+	}
+}
+
+// mixedCaps matches words which look like the names of parameters.
+var mixedCaps = regexp.MustCompile(`[a-z][a-z0-9]*[A-Z][A-Z0-9]*[a-z][A-Za-z0-9]*`)
+
+func checkParams(pass *analysis.Pass, fn *ast.FuncDecl, known map[string]bool) {
+	var params []string
+	for _, f := range fn.Type.Params.List {
+		for _, n := range f.Names {
+			params = append(params, n.Name)
+		}
+	}
+	if len(params) == 0 {
+		return
+	}
+	for _, l := range lines(fn.Doc) {
+		for _, m := range mixedCaps.FindAllStringIndex(l.text, -1) {
+			if m[0] > 0 && (isIdent(rune(l.text[m[0]-1])) || strings.ContainsRune("./", rune(l.text[m[0]-1]))) {
+				continue
+			}
+			if m[1] < len(l.text) && (isIdent(rune(l.text[m[1]])) || l.text[m[1]] == '/') {
+				continue
+			}
+			word := l.text[m[0]:m[1]]
+			if known[word] {
+				continue
+			}
+			pass.Report(analysis.Diagnostic{
+				Pos:      l.pos,
+				Category: "params",
+				Message:  "doc comment of " + fn.Name.Name + " refers to " + word + ", which is not a parameter (parameters are " + strings.Join(params, ", ") + ")",
+			})
+		}
+	}
+}
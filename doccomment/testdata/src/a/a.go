@@ -0,0 +1,71 @@
+package a
+
+// Server serves requests.
+type Server struct{}
+
+// A Client sends requests.
+type Client struct{}
+
+// This type is broken. // want `doc comment of Broken should start with its name`
+type Broken struct{}
+
+// Serve serves requests.
+func (s *Server) Serve() {}
+
+// serves requests. // want `doc comment of Close should start with its name`
+func (s *Server) Close() {}
+
+// ServeHTTPish is a different name. // want `doc comment of ServeHTTP should start with its name`
+func (s *Server) ServeHTTP() {}
+
+type unexported struct{}
+
+// does something.
+func (unexported) Exported() {}
+
+// Limit is the limit.
+const Limit = 10
+
+// Values for the mode.
+const (
+	// ModeA is the first mode.
+	ModeA = iota
+	// the second mode. // want `doc comment of ModeB should start with its name`
+	ModeB
+)
+
+// Old does things.
+//
+// Deprecated: Use New instead.
+func Old() {}
+
+// Old2 does things.
+// Deprecated: Use New instead. // want `separate it from the previous paragraph by an empty line`
+func Old2() {}
+
+// Old3 does things.
+//
+// DEPRECATED: use New instead. // want `start it with "Deprecated: "`
+func Old3() {}
+
+// Old4 does things. Deprecated: use New instead. // want `start a new paragraph with "Deprecated: "`
+func Old4() {}
+
+// Old5 does things.
+//
+// Deprecated functions are bad, but this one isn't.
+func Old5() {}
+
+// Copy copies n bytes from src to dst. At most maxSize bytes are copied. // want `refers to max.ize, which is not a parameter \(parameters are dst, src, limit\)`
+func Copy(dst, src []byte, limit int) {}
+
+// Resize sets the size to newSize, see also github.com/foo/barBaz and
+// http.maxBytes. Works on macOS.
+func Resize(newSize int) {}
+
+/*
+Block is documented with a block comment.
+
+Deprecated: Use Client instead.
+*/
+type Block struct{}
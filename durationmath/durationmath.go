@@ -0,0 +1,325 @@
+// Copyright 2019 Axel Wagner
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package durationmath defines an Analyzer that checks arithmetic with
+// time.Duration.
+package durationmath
+
+import (
+	"go/ast"
+	"go/constant"
+	"go/token"
+	"go/types"
+	"strings"
+
+	"github.com/Merovius/go-tools/internal/analysisutil"
+	"github.com/Merovius/go-tools/internal/fix"
+	"github.com/Merovius/go-tools/internal/nodefilter"
+	"golang.org/x/tools/go/analysis"
+)
+
+const Doc = `check arithmetic with time.Duration
+
+A time.Duration is a number of nanoseconds. The analyzer reports
+  - conversions to time.Duration of integers named like counts of seconds,
+    milliseconds, minutes or hours, which aren't multiplied by the unit. A
+    fix multiplies them,
+  - products of two durations, like timeout * time.Second for a timeout of
+    type time.Duration, whose unit is nanoseconds squared, and
+  - time.Sleep, time.After and similar functions called with small
+    constants without a unit, like time.Sleep(5), which sleeps for 5
+    nanoseconds.`
+
+var Analyzer = &analysis.Analyzer{
+	Name: "durationmath",
+	Doc:  Doc,
+	Run:  run,
+	Requires: []*analysis.Analyzer{
+		nodefilter.Analyzer,
+	},
+}
+
+func init() {
+	// The fix changes the duration, to what the name says it is.
+	fix.Register(Analyzer, fix.Unverified)
+}
+
+var (
+	calls    = nodefilter.New(true, new(ast.CallExpr))
+	binaries = nodefilter.New(true, new(ast.BinaryExpr))
+)
+
+// sleeps are the functions taking a duration as first argument, for which
+// tiny constants are reported.
+var sleeps = []string{
+	"time.Sleep",
+	"time.After",
+	"time.AfterFunc",
+	"time.NewTimer",
+	"time.NewTicker",
+	"time.Tick",
+}
+
+func run(pass *analysis.Pass) (interface{}, error) {
+	if !analysisutil.Imports(pass.Pkg, "time") {
+		return nil, nil
+	}
+	nodes := pass.ResultOf[nodefilter.Analyzer].(*nodefilter.Result)
+	for _, n := range nodes.Nodes(calls) {
+		call := n.Node.(*ast.CallExpr)
+		if analysisutil.IsCall(pass.TypesInfo, call, sleeps...) && len(call.Args) > 0 {
+			checkSleep(pass, call)
+			continue
+		}
+		if isCount(pass, call) {
+			checkConversion(pass, call, n.Stack)
+		}
+	}
+	for _, n := range nodes.Nodes(binaries) {
+		checkProduct(pass, n.Node.(*ast.BinaryExpr), n.Stack)
+	}
+	return nil, nil
+}
+
+func isDuration(t types.Type) bool {
+	return t != nil && analysisutil.IsType(t, "time.Duration")
+}
+
+// isCount returns whether call converts a non-constant integer, which isn't
+// a duration, to time.Duration.
+func isCount(pass *analysis.Pass, call *ast.CallExpr) bool {
+	tv, ok := pass.TypesInfo.Types[call.Fun]
+	if !ok || !tv.IsType() || !isDuration(tv.Type) || len(call.Args) != 1 {
+		return false
+	}
+	arg := pass.TypesInfo.Types[call.Args[0]]
+	if arg.Value != nil || arg.Type == nil || isDuration(arg.Type) {
+		return false
+	}
+	b, ok := arg.Type.Underlying().(*types.Basic)
+	return ok && b.Info()&types.IsInteger != 0
+}
+
+// units maps words of names to the units they suggest. Nanoseconds need
+// no unit.
+var units = map[string]string{
+	"ns":           "",
+	"nanos":        "",
+	"nanosecond":   "",
+	"nanoseconds":  "",
+	"us":           "Microsecond",
+	"micros":       "Microsecond",
+	"microsecond":  "Microsecond",
+	"microseconds": "Microsecond",
+	"ms":           "Millisecond",
+	"millis":       "Millisecond",
+	"millisecond":  "Millisecond",
+	"milliseconds": "Millisecond",
+	"sec":          "Second",
+	"secs":         "Second",
+	"second":       "Second",
+	"seconds":      "Second",
+	"min":          "Minute",
+	"mins":         "Minute",
+	"minute":       "Minute",
+	"minutes":      "Minute",
+	"hour":         "Hour",
+	"hours":        "Hour",
+}
+
+// unit returns the unit suggested by the name of e, if it has one.
+func unit(e ast.Expr) string {
+	var name string
+	switch e := analysisutil.Unparen(e).(type) {
+	case *ast.Ident:
+		name = e.Name
+	case *ast.SelectorExpr:
+		name = e.Sel.Name
+	case *ast.CallExpr:
+		// Conversions between integer types.
+		if len(e.Args) == 1 {
+			return unit(e.Args[0])
+		}
+	}
+	ws := analysisutil.Words(name)
+	for i := len(ws) - 1; i >= 0; i-- {
+		if u, ok := units[ws[i]]; ok {
+			return u
+		}
+	}
+	return ""
+}
+
+func checkConversion(pass *analysis.Pass, call *ast.CallExpr, stack []ast.Node) {
+	u := unit(call.Args[0])
+	if u == "" {
+		return
+	}
+	// Skip conversions multiplied or divided by something, like
+	// time.Duration(secs) * time.Second.
+	for i := len(stack) - 2; i >= 0; i-- {
+		switch p := stack[i].(type) {
+		case *ast.ParenExpr:
+			continue
+		case *ast.BinaryExpr:
+			if p.Op == token.MUL || p.Op == token.QUO {
+				return
+			}
+		case *ast.AssignStmt:
+			if p.Tok == token.MUL_ASSIGN {
+				return
+			}
+		}
+		break
+	}
+	d := analysis.Diagnostic{
+		Pos:     call.Pos(),
+		End:     call.End(),
+		Message: analysisutil.Render(pass.Fset, call) + " is a number of nanoseconds, but " + analysisutil.Render(pass.Fset, call.Args[0]) + " seems to count " + strings.ToLower(u) + "s; multiply it by time." + u,
+	}
+	if sel, ok := call.Fun.(*ast.SelectorExpr); ok {
+		mul := " * " + analysisutil.Render(pass.Fset, sel.X) + "." + u
+		edits := []analysis.TextEdit{{Pos: call.End(), End: call.End(), NewText: []byte(mul)}}
+		if needsParens(parent(stack), call) {
+			edits = []analysis.TextEdit{
+				{Pos: call.Pos(), End: call.Pos(), NewText: []byte("(")},
+				{Pos: call.End(), End: call.End(), NewText: []byte(mul + ")")},
+			}
+		}
+		d.SuggestedFixes = []analysis.SuggestedFix{{
+			Message:   "multiply by time." + u,
+			TextEdits: edits,
+		}}
+	}
+	pass.Report(d)
+}
+
+// parent returns the node enclosing the last node of stack.
+func parent(stack []ast.Node) ast.Node {
+	if len(stack) < 2 {
+		return nil
+	}
+	return stack[len(stack)-2]
+}
+
+// needsParens returns whether a product replacing e, a child of p, has to
+// be parenthesized, like the operand of a selector.
+func needsParens(p ast.Node, e ast.Expr) bool {
+	switch p := p.(type) {
+	case *ast.BinaryExpr:
+		return p.Op.Precedence() >= token.MUL.Precedence()
+	case *ast.SelectorExpr, *ast.UnaryExpr, *ast.StarExpr, *ast.TypeAssertExpr:
+		return true
+	case *ast.IndexExpr:
+		return p.X == e
+	case *ast.SliceExpr:
+		return p.X == e
+	case *ast.CallExpr:
+		return p.Fun == e
+	}
+	return false
+}
+
+// checkProduct reports products, like a * b * c, with more than one factor
+// being a duration. Only the outermost product of a chain is checked, as a
+// whole: in time.Duration(n) * 24 * time.Hour, time.Duration(n) * 24 is a
+// count.
+func checkProduct(pass *analysis.Pass, e *ast.BinaryExpr, stack []ast.Node) {
+	if e.Op != token.MUL {
+		return
+	}
+	for i := len(stack) - 2; i >= 0; i-- {
+		if _, ok := stack[i].(*ast.ParenExpr); ok {
+			continue
+		}
+		if p, ok := stack[i].(*ast.BinaryExpr); ok && p.Op == token.MUL {
+			return
+		}
+		break
+	}
+	durations := 0
+	for _, x := range factors(e, nil) {
+		tv := pass.TypesInfo.Types[x]
+		if !isDuration(tv.Type) || tv.Value != nil && !isUnit(pass, x) {
+			continue
+		}
+		if call, ok := analysisutil.Unparen(x).(*ast.CallExpr); ok && isConversion(pass, call) {
+			continue
+		}
+		durations++
+	}
+	if durations < 2 {
+		return
+	}
+	pass.Report(analysis.Diagnostic{
+		Pos:      e.Pos(),
+		End:      e.End(),
+		Category: "product",
+		Message:  analysisutil.Render(pass.Fset, e) + " multiplies two durations; convert one of them from a count, like time.Duration(n)",
+	})
+}
+
+// factors appends the factors of the product e to fs.
+func factors(e ast.Expr, fs []ast.Expr) []ast.Expr {
+	if b, ok := analysisutil.Unparen(e).(*ast.BinaryExpr); ok && b.Op == token.MUL {
+		return factors(b.Y, factors(b.X, fs))
+	}
+	return append(fs, e)
+}
+
+// isUnit returns whether e refers to one of the duration constants of
+// package time, like time.Second.
+func isUnit(pass *analysis.Pass, e ast.Expr) bool {
+	e = analysisutil.Unparen(e)
+	if sel, ok := e.(*ast.SelectorExpr); ok {
+		e = sel.Sel
+	}
+	obj := analysisutil.ObjectOf(pass.TypesInfo, e)
+	_, ok := obj.(*types.Const)
+	return ok && obj.Pkg() != nil && obj.Pkg().Path() == "time"
+}
+
+// isConversion returns whether call converts a value, which isn't a
+// duration, to time.Duration.
+func isConversion(pass *analysis.Pass, call *ast.CallExpr) bool {
+	tv, ok := pass.TypesInfo.Types[call.Fun]
+	return ok && tv.IsType() && len(call.Args) == 1 && !isDuration(pass.TypesInfo.TypeOf(call.Args[0]))
+}
+
+func checkSleep(pass *analysis.Pass, call *ast.CallExpr) {
+	arg := call.Args[0]
+	tv := pass.TypesInfo.Types[arg]
+	if tv.Value == nil || tv.Value.Kind() != constant.Int {
+		return
+	}
+	// Constants derived from the units of package time are deliberate.
+	unit := false
+	ast.Inspect(arg, func(n ast.Node) bool {
+		if e, ok := n.(ast.Expr); ok && isUnit(pass, e) {
+			unit = true
+		}
+		return !unit
+	})
+	if unit {
+		return
+	}
+	if v, ok := constant.Int64Val(tv.Value); ok && v > 0 && v < 1000 {
+		pass.Report(analysis.Diagnostic{
+			Pos:      arg.Pos(),
+			End:      arg.End(),
+			Category: "sleep",
+			Message:  analysisutil.CalleeName(pass.TypesInfo, call) + " with " + analysisutil.Render(pass.Fset, arg) + " waits for " + tv.Value.String() + "ns; multiply it by a unit like time.Second",
+		})
+	}
+}
@@ -0,0 +1,45 @@
+package a
+
+import "time"
+
+type config struct {
+	TimeoutMS   int
+	IntervalSec int64
+	Retries     int
+}
+
+func conversions(c config, delaySecs int, n int, items int, nanos int64) {
+	time.Sleep(time.Duration(delaySecs))    // want `time.Duration\(delaySecs\) is a number of nanoseconds, but delaySecs seems to count seconds; multiply it by time.Second`
+	_ = time.Duration(c.TimeoutMS)          // want `c.TimeoutMS seems to count milliseconds; multiply it by time.Millisecond`
+	_ = time.Duration(int64(c.IntervalSec)) // want `seems to count seconds`
+	_ = time.Duration(delaySecs) * time.Second
+	_ = time.Duration(c.Retries)
+	_ = time.Duration(items)
+	_ = time.Duration(nanos)
+	_ = time.Second / time.Duration(n)
+	_ = time.Duration(delaySecs).String()      // want `time.Duration\(delaySecs\) is a number of nanoseconds`
+	_ = -time.Duration(delaySecs)              // want `time.Duration\(delaySecs\) is a number of nanoseconds`
+	_ = time.Duration(delaySecs) + time.Minute // want `time.Duration\(delaySecs\) is a number of nanoseconds`
+}
+
+func products(timeout time.Duration, n int) {
+	_ = timeout * time.Second // want `timeout \* time.Second multiplies two durations; convert one of them from a count, like time.Duration\(n\)`
+	_ = time.Duration(n) * time.Second
+	_ = 5 * time.Second
+	_ = timeout * 2
+	_ = timeout * timeout // want `multiplies two durations`
+	_ = time.Duration(n) * 24 * time.Hour
+	_ = 2 * timeout * time.Second // want `2 \* timeout \* time.Second multiplies two durations`
+	_ = time.Duration(n) * (24 * time.Hour)
+}
+
+const short = 10
+
+func sleeps() {
+	time.Sleep(5)       // want `time.Sleep with 5 waits for 5ns; multiply it by a unit like time.Second`
+	<-time.After(short) // want `time.After with short waits for 10ns`
+	time.Sleep(5 * time.Nanosecond)
+	time.Sleep(100 * time.Millisecond)
+	time.Sleep(0)
+	time.NewTimer(5000)
+}
@@ -0,0 +1,347 @@
+// Copyright 2019 Axel Wagner
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package elemcopy defines an Analyzer that checks for mutations of copies
+// of map and slice elements, which are lost.
+package elemcopy
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+
+	"github.com/Merovius/go-tools/internal/analysisutil"
+	"github.com/Merovius/go-tools/internal/nodefilter"
+	"golang.org/x/tools/go/analysis"
+)
+
+const Doc = `check for mutations of copies of map and slice elements
+
+Map elements aren't addressable, so m[k].f = v and calls of methods with
+pointer receivers on m[k] don't compile. The usual workaround copies the
+element to a variable, but the changes to the copy have to be written back.
+The analyzer reports
+  - variables initialized with a map element of struct type, like v :=
+    m[k], and the value variable of range loops over maps, slices and
+    arrays of structs, whose fields are assigned or which are passed to a
+    method of the package with pointer receiver assigning to its fields, if
+    the variable isn't used afterwards. The change is lost, as only the copy
+    is modified, and
+  - calls of methods with value receivers on map elements, like
+    m[k].SetName(x), if the method assigns to fields of its receiver. They
+    compile, but modify a copy of the element.`
+
+var Analyzer = &analysis.Analyzer{
+	Name: "elemcopy",
+	Doc:  Doc,
+	Run:  run,
+	Requires: []*analysis.Analyzer{
+		nodefilter.Analyzer,
+	},
+}
+
+var (
+	funcs   = nodefilter.New(false, new(ast.FuncDecl))
+	assigns = nodefilter.New(true, new(ast.AssignStmt))
+	ranges  = nodefilter.New(true, new(ast.RangeStmt))
+	calls   = nodefilter.New(false, new(ast.CallExpr))
+)
+
+func run(pass *analysis.Pass) (interface{}, error) {
+	nodes := pass.ResultOf[nodefilter.Analyzer].(*nodefilter.Result)
+
+	// mutators maps the methods with value receivers assigning to fields of
+	// their receiver to the field, ptrMutators holds those with pointer
+	// receivers.
+	mutators := make(map[*types.Func]string)
+	ptrMutators := make(map[*types.Func]bool)
+	for _, n := range nodes.Nodes(funcs) {
+		fn := n.Node.(*ast.FuncDecl)
+		if field := mutatesValueReceiver(pass, fn); field != "" {
+			mutators[pass.TypesInfo.Defs[fn.Name].(*types.Func)] = field
+		}
+		if mutatesPointerReceiver(pass, fn) {
+			ptrMutators[pass.TypesInfo.Defs[fn.Name].(*types.Func)] = true
+		}
+	}
+
+	for _, n := range nodes.Nodes(assigns) {
+		as := n.Node.(*ast.AssignStmt)
+		if as.Tok != token.DEFINE || len(as.Lhs) != len(as.Rhs) {
+			continue
+		}
+		_, body := analysisutil.EnclosingFunc(n.Stack)
+		if body == nil {
+			continue
+		}
+		for i, r := range as.Rhs {
+			ix, ok := analysisutil.Unparen(r).(*ast.IndexExpr)
+			if !ok || !isMap(pass, ix.X) {
+				continue
+			}
+			checkCopy(pass, as.Lhs[i], body, ptrMutators, "map element "+analysisutil.Render(pass.Fset, ix))
+		}
+	}
+	for _, n := range nodes.Nodes(ranges) {
+		rs := n.Node.(*ast.RangeStmt)
+		if rs.Value == nil || rs.Tok != token.DEFINE {
+			continue
+		}
+		switch pass.TypesInfo.TypeOf(rs.X).Underlying().(type) {
+		case *types.Map, *types.Slice, *types.Array, *types.Pointer:
+			checkCopy(pass, rs.Value, rs.Body, ptrMutators, "element of "+analysisutil.Render(pass.Fset, rs.X))
+		}
+	}
+
+	if len(mutators) == 0 {
+		return nil, nil
+	}
+	for _, n := range nodes.Nodes(calls) {
+		call := n.Node.(*ast.CallExpr)
+		sel, ok := analysisutil.Unparen(call.Fun).(*ast.SelectorExpr)
+		if !ok {
+			continue
+		}
+		ix, ok := analysisutil.Unparen(sel.X).(*ast.IndexExpr)
+		if !ok || !isMap(pass, ix.X) {
+			continue
+		}
+		fn, ok := pass.TypesInfo.Uses[sel.Sel].(*types.Func)
+		if !ok {
+			continue
+		}
+		if field, ok := mutators[fn]; ok {
+			pass.Reportf(call.Pos(), "%s assigns to %s of its value receiver, so it modifies a copy of the map element %s; store the element in a variable and write it back, or use a pointer receiver", fn.Name(), field, analysisutil.Render(pass.Fset, ix))
+		}
+	}
+	return nil, nil
+}
+
+func isMap(pass *analysis.Pass, e ast.Expr) bool {
+	_, ok := pass.TypesInfo.TypeOf(e).Underlying().(*types.Map)
+	return ok
+}
+
+func isStruct(t types.Type) bool {
+	_, ok := t.Underlying().(*types.Struct)
+	return ok
+}
+
+// checkCopy reports the variable defined by lhs, a copy of the element
+// described by what, if it is mutated in body and not used afterwards.
+// Calls of the methods in mutators count as mutations.
+func checkCopy(pass *analysis.Pass, lhs ast.Expr, body *ast.BlockStmt, mutators map[*types.Func]bool, what string) {
+	id, ok := lhs.(*ast.Ident)
+	if !ok || id.Name == "_" {
+		return
+	}
+	v, ok := pass.TypesInfo.Defs[id].(*types.Var)
+	if !ok || !isStruct(v.Type()) {
+		return
+	}
+	var (
+		last    ast.Node // the last mutation
+		lastUse token.Pos
+		escapes bool
+	)
+	roots := make(map[*ast.Ident]bool) // uses of v being mutated
+	ast.Inspect(body, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.AssignStmt:
+			for _, l := range n.Lhs {
+				if r := fieldRoot(pass, l, v); r != nil {
+					roots[r] = true
+					last = n
+				}
+			}
+		case *ast.IncDecStmt:
+			if r := fieldRoot(pass, n.X, v); r != nil {
+				roots[r] = true
+				last = n
+			}
+		case *ast.CallExpr:
+			sel, ok := analysisutil.Unparen(n.Fun).(*ast.SelectorExpr)
+			if !ok {
+				break
+			}
+			if r := fieldRoot(pass, sel.X, v); r != nil && mutatorCall(pass, sel, mutators) {
+				roots[r] = true
+				last = n
+			}
+		case *ast.UnaryExpr:
+			if n.Op == token.AND && fieldRoot(pass, n.X, v) != nil {
+				escapes = true
+			}
+		case *ast.Ident:
+			if pass.TypesInfo.Uses[n] == v && !roots[n] && n.Pos() > lastUse {
+				lastUse = n.Pos()
+			}
+		}
+		return true
+	})
+	if last == nil || escapes || lastUse > last.End() {
+		return
+	}
+	pass.Reportf(last.Pos(), "%s modifies %s, a copy of the %s, and is not used afterwards; the change is lost", renderMutation(pass, last), v.Name(), what)
+}
+
+// fieldRoot returns the identifier of v, if e is v or a field selection on
+// v, without indirection.
+func fieldRoot(pass *analysis.Pass, e ast.Expr, v *types.Var) *ast.Ident {
+	for {
+		switch x := analysisutil.Unparen(e).(type) {
+		case *ast.Ident:
+			if pass.TypesInfo.Uses[x] == v {
+				return x
+			}
+			return nil
+		case *ast.SelectorExpr:
+			sel, ok := pass.TypesInfo.Selections[x]
+			if !ok || sel.Kind() != types.FieldVal || sel.Indirect() {
+				return nil
+			}
+			e = x.X
+		case *ast.IndexExpr:
+			if _, ok := pass.TypesInfo.TypeOf(x.X).Underlying().(*types.Array); !ok {
+				return nil
+			}
+			e = x.X
+		default:
+			return nil
+		}
+	}
+}
+
+// mutatorCall returns whether sel is a method in mutators, called on an
+// addressable value. Methods promoted through an embedded pointer operate on
+// the shared pointee instead of the copy.
+func mutatorCall(pass *analysis.Pass, sel *ast.SelectorExpr, mutators map[*types.Func]bool) bool {
+	s, ok := pass.TypesInfo.Selections[sel]
+	if !ok || s.Kind() != types.MethodVal || s.Indirect() {
+		return false
+	}
+	if _, isPtr := s.Recv().Underlying().(*types.Pointer); isPtr {
+		return false
+	}
+	return mutators[s.Obj().(*types.Func)]
+}
+
+func renderMutation(pass *analysis.Pass, n ast.Node) string {
+	switch n := n.(type) {
+	case *ast.CallExpr:
+		return "the call of " + analysisutil.Render(pass.Fset, n.Fun)
+	default:
+		return "the assignment"
+	}
+}
+
+// mutatesValueReceiver returns the field of the value receiver of the
+// struct type fn assigns to, or "".
+func mutatesValueReceiver(pass *analysis.Pass, fn *ast.FuncDecl) string {
+	if fn.Recv == nil || fn.Body == nil || len(fn.Recv.List) == 0 || len(fn.Recv.List[0].Names) == 0 {
+		return ""
+	}
+	recv, ok := pass.TypesInfo.Defs[fn.Recv.List[0].Names[0]].(*types.Var)
+	if !ok || !isStruct(recv.Type()) {
+		return ""
+	}
+	field := ""
+	ast.Inspect(fn.Body, func(n ast.Node) bool {
+		as, ok := n.(*ast.AssignStmt)
+		if !ok || field != "" {
+			return field == ""
+		}
+		for _, l := range as.Lhs {
+			if sel, ok := analysisutil.Unparen(l).(*ast.SelectorExpr); ok && fieldRoot(pass, sel, recv) != nil {
+				field = sel.Sel.Name
+			}
+		}
+		return true
+	})
+	return field
+}
+
+// mutatesPointerReceiver returns whether fn is a method with pointer
+// receiver to a struct type, which assigns to the fields of its receiver or
+// to the receiver itself.
+func mutatesPointerReceiver(pass *analysis.Pass, fn *ast.FuncDecl) bool {
+	if fn.Recv == nil || fn.Body == nil || len(fn.Recv.List) == 0 || len(fn.Recv.List[0].Names) == 0 {
+		return false
+	}
+	recv, ok := pass.TypesInfo.Defs[fn.Recv.List[0].Names[0]].(*types.Var)
+	if !ok {
+		return false
+	}
+	p, ok := recv.Type().(*types.Pointer)
+	if !ok || !isStruct(p.Elem()) {
+		return false
+	}
+	mutates := false
+	ast.Inspect(fn.Body, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.AssignStmt:
+			for _, l := range n.Lhs {
+				if throughReceiver(pass, l, recv) {
+					mutates = true
+				}
+			}
+		case *ast.IncDecStmt:
+			if throughReceiver(pass, n.X, recv) {
+				mutates = true
+			}
+		}
+		return !mutates
+	})
+	return mutates
+}
+
+// throughReceiver returns whether e is the pointee of the pointer receiver
+// recv or a field of it, without further indirection.
+func throughReceiver(pass *analysis.Pass, e ast.Expr, recv *types.Var) bool {
+	deref := false
+	for {
+		switch x := analysisutil.Unparen(e).(type) {
+		case *ast.Ident:
+			return deref && pass.TypesInfo.Uses[x] == recv
+		case *ast.StarExpr:
+			if deref {
+				return false
+			}
+			deref = true
+			e = x.X
+		case *ast.SelectorExpr:
+			sel, ok := pass.TypesInfo.Selections[x]
+			if !ok || sel.Kind() != types.FieldVal {
+				return false
+			}
+			if sel.Indirect() {
+				// Only the implicit dereference of the receiver
+				// itself.
+				id, ok := analysisutil.Unparen(x.X).(*ast.Ident)
+				if deref || !ok || pass.TypesInfo.Uses[id] != recv || len(sel.Index()) != 1 {
+					return false
+				}
+				deref = true
+			}
+			e = x.X
+		case *ast.IndexExpr:
+			if _, ok := pass.TypesInfo.TypeOf(x.X).Underlying().(*types.Array); !ok {
+				return false
+			}
+			e = x.X
+		default:
+			return false
+		}
+	}
+}
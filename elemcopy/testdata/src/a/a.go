@@ -0,0 +1,102 @@
+package a
+
+import "fmt"
+
+type user struct {
+	name  string
+	count int
+	tags  [2]string
+	info  *info
+}
+
+type info struct{ seen bool }
+
+func (u *user) rename(n string) { u.name = n }
+
+func (u *user) reset() { *u = user{} }
+
+func (u *user) Name() string { return u.name }
+
+type account struct {
+	*user
+	balance int
+}
+
+func (u user) String() string { return u.name }
+
+func (u user) SetName(n string) { u.name = n }
+
+func (u user) Count() int { return u.count }
+
+func maps(m map[string]user, k string) {
+	u := m[k]
+	u.name = "x" // want `the assignment modifies u, a copy of the map element m\[k\], and is not used afterwards; the change is lost`
+
+	v := m[k]
+	v.count++
+	v.tags[0] = "y" // want `the assignment modifies v, a copy of the map element m\[k\]`
+
+	w := m[k]
+	w.rename("z") // want `the call of w.rename modifies w, a copy of the map element m\[k\]`
+
+	m[k].SetName("n") // want `SetName assigns to name of its value receiver, so it modifies a copy of the map element m\[k\]; store the element in a variable and write it back, or use a pointer receiver`
+	_ = m[k].Count()
+
+	// Written back or used.
+	x := m[k]
+	x.name = "x"
+	m[k] = x
+	y := m[k]
+	y.count = y.count + 1
+	fmt.Println(y)
+	z := m[k]
+	z.name = "z"
+	p := &z
+	fmt.Println(p)
+
+	// Pointers share the element.
+	ptrs := map[string]*user{}
+	q := ptrs[k]
+	q.name = "q"
+	r := m[k]
+	r.info.seen = true
+}
+
+func ranges(us []user, m map[int]user) {
+	for _, u := range us {
+		u.count = 1 // want `the assignment modifies u, a copy of the element of us, and is not used afterwards`
+	}
+	for _, u := range m {
+		if u.name == "" {
+			u.rename("anon") // want `the call of u.rename modifies u, a copy of the element of m`
+		}
+	}
+	for i, u := range us {
+		u.count++
+		us[i] = u
+	}
+	for _, u := range us {
+		u.count++
+		fmt.Println(u.count)
+	}
+	for _, u := range us {
+		fmt.Println(u.String())
+	}
+	for _, u := range us {
+		u.reset() // want `the call of u.reset modifies u, a copy of the element of us`
+	}
+	for _, u := range us {
+		if u.Name() == "" {
+			continue
+		}
+	}
+}
+
+func promoted(as []account, m map[string]account) {
+	// The embedded pointer is shared with the element.
+	for _, a := range as {
+		a.rename("x")
+	}
+	b := m["b"]
+	b.rename("y")
+}
@@ -0,0 +1,279 @@
+// Copyright 2019 Axel Wagner
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package encodeiface defines an Analyzer that checks that values with
+// interface-typed fields can be decoded by encoding/gob and encoding/json.
+package encodeiface
+
+import (
+	"go/ast"
+	"go/types"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/Merovius/go-tools/internal/analysisutil"
+	"github.com/Merovius/go-tools/internal/nodefilter"
+	"golang.org/x/tools/go/analysis"
+)
+
+const Doc = `check encoding of interface-typed fields with gob and json
+
+encoding/gob can only transmit values of interface-typed fields if their
+dynamic type was registered with gob.Register. The analyzer reports calls of
+(*gob.Encoder).Encode with values containing fields of an interface type which
+no registered type implements. Registrations are looked up in the package and
+its dependencies, so types registered by a package which isn't imported by the
+encoding package can't be taken into account.
+
+encoding/json decodes JSON objects into fields of type interface{} as
+map[string]interface{}, and can't decode into fields of other interface types
+at all. The analyzer reports decoding into values of types with such fields,
+if the package encodes values of the same type as well, as the round trip
+doesn't result in the original value.`
+
+var Analyzer = &analysis.Analyzer{
+	Name: "encodeiface",
+	Doc:  Doc,
+	Run:  run,
+	Requires: []*analysis.Analyzer{
+		nodefilter.Analyzer,
+	},
+	FactTypes: []analysis.Fact{new(registered)},
+}
+
+var calls = nodefilter.New(false, new(ast.CallExpr))
+
+// registered is a package fact listing the types registered with gob in a
+// package.
+type registered struct {
+	Types []gobType
+}
+
+// A gobType is a type registered with gob.
+type gobType struct {
+	Name    string
+	Methods []string // names of the methods in its method set
+}
+
+func (*registered) AFact() {}
+
+func (r *registered) String() string {
+	var names []string
+	for _, t := range r.Types {
+		names = append(names, t.Name)
+	}
+	return "registered " + strings.Join(names, ", ")
+}
+
+// implements returns whether t has the methods of it. Only method names are
+// compared, which is good enough for the purposes of this check.
+func (t gobType) implements(it *types.Interface) bool {
+	for i := 0; i < it.NumMethods(); i++ {
+		j := sort.SearchStrings(t.Methods, it.Method(i).Name())
+		if j == len(t.Methods) || t.Methods[j] != it.Method(i).Name() {
+			return false
+		}
+	}
+	return true
+}
+
+func run(pass *analysis.Pass) (interface{}, error) {
+	nodes := pass.ResultOf[nodefilter.Analyzer].(*nodefilter.Result)
+
+	var (
+		own            registered
+		gobEncodes     []*ast.CallExpr
+		jsonDecodes    []*ast.CallExpr
+		jsonEncoded    = make(map[types.Type]bool)
+		qual           = types.RelativeTo(pass.Pkg)
+		typeOfArgument = func(call *ast.CallExpr, i int) types.Type {
+			if i >= len(call.Args) {
+				return nil
+			}
+			return pass.TypesInfo.TypeOf(call.Args[i])
+		}
+	)
+	for _, n := range nodes.Nodes(calls) {
+		call := n.Node.(*ast.CallExpr)
+		switch analysisutil.CalleeName(pass.TypesInfo, call) {
+		case "encoding/gob.Register":
+			if t := typeOfArgument(call, 0); t != nil && !types.IsInterface(t) {
+				own.Types = append(own.Types, newGobType(t))
+			}
+		case "encoding/gob.RegisterName":
+			if t := typeOfArgument(call, 1); t != nil && !types.IsInterface(t) {
+				own.Types = append(own.Types, newGobType(t))
+			}
+		case "(*encoding/gob.Encoder).Encode":
+			gobEncodes = append(gobEncodes, call)
+		case "encoding/json.Marshal", "encoding/json.MarshalIndent", "(*encoding/json.Encoder).Encode":
+			if t := typeOfArgument(call, 0); t != nil {
+				jsonEncoded[deref(t)] = true
+			}
+		case "encoding/json.Unmarshal":
+			jsonDecodes = append(jsonDecodes, call)
+		case "(*encoding/json.Decoder).Decode":
+			jsonDecodes = append(jsonDecodes, call)
+		}
+	}
+	if len(own.Types) > 0 {
+		pass.ExportPackageFact(&own)
+	}
+
+	if len(gobEncodes) > 0 {
+		all := own.Types
+		for _, f := range pass.AllPackageFacts() {
+			if r, ok := f.Fact.(*registered); ok && f.Package != pass.Pkg {
+				all = append(all, r.Types...)
+			}
+		}
+		for _, call := range gobEncodes {
+			t := typeOfArgument(call, 0)
+			if t == nil || types.IsInterface(t) {
+				continue
+			}
+			for _, f := range interfaceFields(t, false) {
+				if isRegistered(all, f.iface) {
+					continue
+				}
+				pass.Report(analysis.Diagnostic{
+					Pos:     call.Pos(),
+					End:     call.End(),
+					Message: "gob encodes field " + f.name + " of interface type " + types.TypeString(f.typ, qual) + ", but no type implementing it is registered with gob.Register",
+				})
+			}
+		}
+	}
+
+	for _, call := range jsonDecodes {
+		i := 0
+		if len(call.Args) == 2 {
+			i = 1
+		}
+		t := typeOfArgument(call, i)
+		if t == nil || !jsonEncoded[deref(t)] {
+			continue
+		}
+		for _, f := range interfaceFields(deref(t), true) {
+			msg := "json decodes field " + f.name + " of type interface{} as map[string]interface{}, not as the type it was encoded from"
+			if f.iface.NumMethods() > 0 {
+				msg = "json can't decode field " + f.name + " of interface type " + types.TypeString(f.typ, qual) + "; implement json.Unmarshaler"
+			}
+			pass.Report(analysis.Diagnostic{
+				Pos:      call.Pos(),
+				End:      call.End(),
+				Category: "json",
+				Message:  msg,
+			})
+		}
+	}
+	return nil, nil
+}
+
+func newGobType(t types.Type) gobType {
+	gt := gobType{Name: types.TypeString(t, func(p *types.Package) string {
+		return analysisutil.TrimVendor(p.Path())
+	})}
+	ms := types.NewMethodSet(t)
+	for i := 0; i < ms.Len(); i++ {
+		gt.Methods = append(gt.Methods, ms.At(i).Obj().Name())
+	}
+	sort.Strings(gt.Methods)
+	return gt
+}
+
+func isRegistered(all []gobType, it *types.Interface) bool {
+	for _, t := range all {
+		if t.implements(it) {
+			return true
+		}
+	}
+	return false
+}
+
+func deref(t types.Type) types.Type {
+	if p, ok := t.Underlying().(*types.Pointer); ok {
+		return p.Elem()
+	}
+	return t
+}
+
+// An interfaceField is a field of interface type.
+type interfaceField struct {
+	name  string // like "T.F"
+	typ   types.Type
+	iface *types.Interface
+}
+
+// ignored returns whether the encoding doesn't encode or decode values of
+// type t through their fields, as they implement its marshaling interfaces.
+func ignored(t types.Type, json bool) bool {
+	if json {
+		return hasMethod(t, "UnmarshalJSON") || hasMethod(t, "UnmarshalText")
+	}
+	return hasMethod(t, "GobEncode") || hasMethod(t, "MarshalBinary")
+}
+
+func hasMethod(t types.Type, name string) bool {
+	if _, ok := t.Underlying().(*types.Pointer); !ok {
+		t = types.NewPointer(t)
+	}
+	return types.NewMethodSet(t).Lookup(nil, name) != nil
+}
+
+// interfaceFields returns the exported fields of interface type reachable
+// from values of type t, when encoded with json or gob.
+func interfaceFields(t types.Type, json bool) []interfaceField {
+	var (
+		fields []interfaceField
+		seen   = make(map[types.Type]bool)
+		walk   func(t types.Type, name string)
+	)
+	walk = func(t types.Type, name string) {
+		if seen[t] || ignored(t, json) {
+			return
+		}
+		seen[t] = true
+		if n, ok := t.(*types.Named); ok {
+			name = n.Obj().Name()
+		}
+		switch u := t.Underlying().(type) {
+		case *types.Pointer:
+			walk(u.Elem(), name)
+		case *types.Slice:
+			walk(u.Elem(), name)
+		case *types.Array:
+			walk(u.Elem(), name)
+		case *types.Map:
+			walk(u.Key(), name)
+			walk(u.Elem(), name)
+		case *types.Struct:
+			for i := 0; i < u.NumFields(); i++ {
+				f := u.Field(i)
+				if !f.Exported() || json && reflect.StructTag(u.Tag(i)).Get("json") == "-" {
+					continue
+				}
+				fname := name + "." + f.Name()
+				if it, ok := f.Type().Underlying().(*types.Interface); ok {
+					fields = append(fields, interfaceField{fname, f.Type(), it})
+					continue
+				}
+				walk(f.Type(), fname)
+			}
+		}
+	}
+	walk(t, types.TypeString(t, nil))
+	return fields
+}
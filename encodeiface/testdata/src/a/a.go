@@ -0,0 +1,66 @@
+package a
+
+import (
+	"encoding/gob"
+	"encoding/json"
+	"io"
+	"time"
+
+	"shapes"
+)
+
+type Drawing struct {
+	Shapes []shapes.Shape
+	Pets   map[string]*Pet
+	Tags   interface{}
+	Time   time.Time
+
+	private shapes.Animal
+}
+
+type Pet struct {
+	Animal shapes.Animal
+}
+
+type Raw struct {
+	Animal shapes.Animal
+}
+
+func (r *Raw) GobEncode() ([]byte, error) { return nil, nil }
+
+func encode(w io.Writer, d *Drawing, r Raw, v interface{}) error {
+	enc := gob.NewEncoder(w)
+	enc.Encode(d) // want `gob encodes field Pet.Animal of interface type shapes.Animal, but no type implementing it is registered with gob.Register`
+	enc.Encode(r)
+	enc.Encode(v)
+	return enc.Encode(Pet{}) // want `gob encodes field Pet.Animal of interface type shapes.Animal`
+}
+
+type Event struct {
+	Kind    string
+	Payload interface{}
+	Source  shapes.Shape
+	Ignored interface{} `json:"-"`
+}
+
+type Custom struct {
+	Payload interface{}
+}
+
+func (c *Custom) UnmarshalJSON(b []byte) error { return nil }
+
+type Config struct {
+	Extra interface{}
+}
+
+func roundTrip(e Event, c Custom, r io.Reader) {
+	b, _ := json.Marshal(e)
+	var e2 Event
+	json.Unmarshal(b, &e2) // want `json decodes field Event.Payload of type interface{} as map\[string\]interface{}, not as the type it was encoded from` `json can't decode field Event.Source of interface type shapes.Shape; implement json.Unmarshaler`
+
+	b, _ = json.Marshal(&c)
+	json.Unmarshal(b, &c)
+
+	var cfg Config
+	json.NewDecoder(r).Decode(&cfg)
+}
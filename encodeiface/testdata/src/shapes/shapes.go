@@ -0,0 +1,28 @@
+package shapes // want package:"registered shapes.Circle, \\*shapes.Square"
+
+import "encoding/gob"
+
+type Shape interface {
+	Area() float64
+}
+
+type Circle struct{ R float64 }
+
+func (c Circle) Area() float64 { return 3 * c.R * c.R }
+
+type Square struct{ A float64 }
+
+func (s *Square) Area() float64 { return s.A * s.A }
+
+type Animal interface {
+	Sound() string
+}
+
+type Dog struct{}
+
+func (Dog) Sound() string { return "woof" }
+
+func init() {
+	gob.Register(Circle{})
+	gob.RegisterName("square", &Square{})
+}
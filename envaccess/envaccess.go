@@ -0,0 +1,144 @@
+// Copyright 2019 Axel Wagner
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package envaccess defines an Analyzer that checks how environment
+// variables are read.
+package envaccess
+
+import (
+	"go/ast"
+
+	"github.com/Merovius/go-tools/internal/analysisutil"
+	"github.com/Merovius/go-tools/internal/nodefilter"
+	"golang.org/x/tools/go/analysis"
+)
+
+const Doc = `check how environment variables are read
+
+Configuration read from environment variables all over a program is hard to
+discover and to test. If the -config flag names the packages responsible for
+configuration, the analyzer reports reading environment variables in other
+packages, except in tests.
+
+It also reports
+  - the result of os.Getenv being parsed directly. If the variable isn't set,
+    parsing fails on the empty string; use os.LookupEnv to tell the cases
+    apart, and
+  - calls of os.Getenv in loops, which read the same variable over and over.`
+
+var Analyzer = &analysis.Analyzer{
+	Name: "envaccess",
+	Doc:  Doc,
+	Run:  run,
+	Requires: []*analysis.Analyzer{
+		nodefilter.Analyzer,
+	},
+}
+
+var config string
+
+func init() {
+	Analyzer.Flags.StringVar(&config, "config", "", "comma-separated import paths of the packages allowed to read environment variables; paths ending in /... include subpackages")
+}
+
+var calls = nodefilter.New(true, new(ast.CallExpr))
+
+var readers = []string{"os.Getenv", "os.LookupEnv", "os.Environ", "syscall.Getenv"}
+
+// parsers lists functions parsing a string argument, which fail on empty
+// strings.
+var parsers = map[string]bool{
+	"strconv.Atoi":       true,
+	"strconv.ParseBool":  true,
+	"strconv.ParseInt":   true,
+	"strconv.ParseUint":  true,
+	"strconv.ParseFloat": true,
+	"time.ParseDuration": true,
+	"net.ParseIP":        true,
+	"net.ParseCIDR":      true,
+}
+
+func run(pass *analysis.Pass) (interface{}, error) {
+	if !analysisutil.Imports(pass.Pkg, "os") && !analysisutil.Imports(pass.Pkg, "syscall") {
+		return nil, nil
+	}
+	restricted := config != "" && !analysisutil.MatchPackages(pass.Pkg.Path(), config)
+	nodes := pass.ResultOf[nodefilter.Analyzer].(*nodefilter.Result)
+	for _, n := range nodes.Nodes(calls) {
+		call := n.Node.(*ast.CallExpr)
+		name := analysisutil.CalleeName(pass.TypesInfo, call)
+		if restricted && analysisutil.IsCall(pass.TypesInfo, call, readers...) && !analysisutil.IsTestFile(pass, call.Pos()) {
+			pass.Reportf(call.Pos(), "%s outside of the configuration packages; read configuration in %s", name, config)
+		}
+		if name == "os.Getenv" {
+			checkParsed(pass, call, n.Stack)
+			checkLoop(pass, call, n.Stack)
+		}
+	}
+	return nil, nil
+}
+
+func checkParsed(pass *analysis.Pass, call *ast.CallExpr, stack []ast.Node) {
+	i := len(stack) - 2
+	for i >= 0 {
+		if _, ok := stack[i].(*ast.ParenExpr); !ok {
+			break
+		}
+		i--
+	}
+	parent, ok := stack[i].(*ast.CallExpr)
+	if !ok || len(parent.Args) == 0 || analysisutil.Unparen(parent.Args[0]) != call {
+		return
+	}
+	parser := analysisutil.CalleeName(pass.TypesInfo, parent)
+	if !parsers[parser] {
+		return
+	}
+	pass.Report(analysis.Diagnostic{
+		Pos:      call.Pos(),
+		End:      call.End(),
+		Category: "lookup",
+		Message:  "result of os.Getenv is passed to " + parser + ", which fails if the variable is unset; use os.LookupEnv to handle that case",
+	})
+}
+
+func checkLoop(pass *analysis.Pass, call *ast.CallExpr, stack []ast.Node) {
+	for i := len(stack) - 2; i >= 0; i-- {
+		switch n := stack[i].(type) {
+		case *ast.FuncLit, *ast.FuncDecl:
+			return
+		case *ast.ForStmt:
+			if !within(call, n.Body) {
+				continue
+			}
+		case *ast.RangeStmt:
+			if !within(call, n.Body) {
+				continue
+			}
+		default:
+			continue
+		}
+		pass.Report(analysis.Diagnostic{
+			Pos:      call.Pos(),
+			End:      call.End(),
+			Category: "loop",
+			Message:  "os.Getenv is called in a loop; read the variable once before the loop",
+		})
+		return
+	}
+}
+
+func within(n, outer ast.Node) bool {
+	return outer.Pos() <= n.Pos() && n.End() <= outer.End()
+}
@@ -0,0 +1,30 @@
+package a
+
+import (
+	"os"
+	"strconv"
+	"time"
+)
+
+func parse() (int, time.Duration) {
+	n, _ := strconv.Atoi(os.Getenv("WORKERS"))          // want `result of os.Getenv is passed to strconv.Atoi, which fails if the variable is unset; use os.LookupEnv to handle that case`
+	d, _ := time.ParseDuration((os.Getenv("TIMEOUT")))  // want `result of os.Getenv is passed to time.ParseDuration`
+	b, _ := strconv.ParseInt(os.Getenv("BASE"), 10, 64) // want `result of os.Getenv is passed to strconv.ParseInt`
+	if s, ok := os.LookupEnv("RETRIES"); ok {
+		n, _ = strconv.Atoi(s)
+	}
+	_ = strconv.Quote(os.Getenv("NAME"))
+	return n + int(b), d
+}
+
+func loop(items []string) {
+	for _, it := range items {
+		if os.Getenv("DEBUG") != "" { // want `os.Getenv is called in a loop; read the variable once before the loop`
+			println(it)
+		}
+	}
+	for i := len(os.Getenv("PREFIX")); i > 0; i-- {
+		f := func() string { return os.Getenv("SUFFIX") }
+		_ = f
+	}
+}
@@ -0,0 +1,14 @@
+package b
+
+import (
+	"os"
+
+	"config"
+)
+
+func addr() string {
+	if a, ok := os.LookupEnv("ADDR"); ok { // want `os.LookupEnv outside of the configuration packages; read configuration in config`
+		return a
+	}
+	return config.Addr
+}
@@ -0,0 +1,12 @@
+package b
+
+import (
+	"os"
+	"testing"
+)
+
+func TestAddr(t *testing.T) {
+	if os.Getenv("INTEGRATION") == "" {
+		t.Skip("not an integration test")
+	}
+}
@@ -0,0 +1,5 @@
+package config
+
+import "os"
+
+var Addr = os.Getenv("ADDR")
@@ -0,0 +1,282 @@
+// Copyright 2019 Axel Wagner
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package errgroup defines an Analyzer that checks for misuse of
+// golang.org/x/sync/errgroup.
+package errgroup
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+
+	"github.com/Merovius/go-tools/internal/analysisutil"
+	"github.com/Merovius/go-tools/internal/goversion"
+	"github.com/Merovius/go-tools/internal/nodefilter"
+	"golang.org/x/tools/go/analysis"
+)
+
+const Doc = `check for misuse of golang.org/x/sync/errgroup
+
+The analyzer reports
+  - function literals passed to Go or TryGo in a loop, which capture a
+    variable of the loop, if the file is compiled for a Go version before
+    1.22. All goroutines share the variable and likely see its last value,
+  - groups declared in a function, which start goroutines, but are never
+    waited for and don't leave the function. Errors of the goroutines are
+    lost and the function returns before they are done,
+  - calls of SetLimit after a call of Go or TryGo on the same group, which
+    panic if any goroutine of the group is still running, and
+  - groups created by WithContext, whose context is discarded or isn't used
+    by the function literals started in the group, which use the context
+    passed to WithContext instead. The goroutines aren't canceled when one
+    of them fails, which is why the context is returned.`
+
+var Analyzer = &analysis.Analyzer{
+	Name: "errgroup",
+	Doc:  Doc,
+	Run:  run,
+	Requires: []*analysis.Analyzer{
+		goversion.Analyzer,
+		nodefilter.Analyzer,
+	},
+}
+
+const errgroupPkg = "golang.org/x/sync/errgroup"
+
+var (
+	funcs = nodefilter.New(false, new(ast.FuncDecl))
+	calls = nodefilter.New(true, new(ast.CallExpr))
+)
+
+func run(pass *analysis.Pass) (interface{}, error) {
+	if !analysisutil.Imports(pass.Pkg, errgroupPkg) {
+		return nil, nil
+	}
+	versions := pass.ResultOf[goversion.Analyzer].(*goversion.Result)
+	nodes := pass.ResultOf[nodefilter.Analyzer].(*nodefilter.Result)
+	for _, n := range nodes.Nodes(calls) {
+		call := n.Node.(*ast.CallExpr)
+		if !isGroupCall(pass, call, "Go", "TryGo") || len(call.Args) != 1 {
+			continue
+		}
+		lit, ok := analysisutil.Unparen(call.Args[0]).(*ast.FuncLit)
+		if !ok || versions.AtLeast(analysisutil.File(pass, call.Pos()), "go1.22") {
+			continue
+		}
+		if id := capturedLoopVar(pass, lit, n.Stack); id != nil {
+			pass.Reportf(id.Pos(), "function literal passed to %s captures the loop variable %s, which all iterations share before Go 1.22; copy it with %s := %s before the call", analysisutil.Render(pass.Fset, call.Fun), id.Name, id.Name, id.Name)
+		}
+	}
+	for _, n := range nodes.Nodes(funcs) {
+		if fn := n.Node.(*ast.FuncDecl); fn.Body != nil {
+			checkGroups(pass, fn.Body)
+		}
+	}
+	return nil, nil
+}
+
+func isGroup(t types.Type) bool {
+	return analysisutil.IsType(t, errgroupPkg+".Group") || analysisutil.IsPointerTo(t, errgroupPkg+".Group")
+}
+
+// isGroupCall returns whether call calls one of the methods of
+// errgroup.Group.
+func isGroupCall(pass *analysis.Pass, call *ast.CallExpr, methods ...string) bool {
+	for _, m := range methods {
+		if analysisutil.IsCall(pass.TypesInfo, call, "(*"+errgroupPkg+".Group)."+m) {
+			return true
+		}
+	}
+	return false
+}
+
+// capturedLoopVar returns the first use in lit of a variable declared by a
+// loop enclosing it in stack, or nil.
+func capturedLoopVar(pass *analysis.Pass, lit *ast.FuncLit, stack []ast.Node) *ast.Ident {
+	vars := make(map[types.Object]bool)
+	add := func(e ast.Expr) {
+		if id, ok := e.(*ast.Ident); ok && id.Name != "_" {
+			if obj := pass.TypesInfo.Defs[id]; obj != nil {
+				vars[obj] = true
+			}
+		}
+	}
+	for _, n := range stack {
+		switch n := n.(type) {
+		case *ast.RangeStmt:
+			if n.Tok == token.DEFINE {
+				add(n.Key)
+				if n.Value != nil {
+					add(n.Value)
+				}
+			}
+		case *ast.ForStmt:
+			if as, ok := n.Init.(*ast.AssignStmt); ok && as.Tok == token.DEFINE {
+				for _, l := range as.Lhs {
+					add(l)
+				}
+			}
+		}
+	}
+	if len(vars) == 0 {
+		return nil
+	}
+	var found *ast.Ident
+	ast.Inspect(lit.Body, func(n ast.Node) bool {
+		if id, ok := n.(*ast.Ident); ok && vars[pass.TypesInfo.Uses[id]] {
+			found = id
+		}
+		return found == nil
+	})
+	return found
+}
+
+// A group is a variable holding an errgroup.Group, declared in a function.
+type group struct {
+	v       *types.Var
+	decl    *ast.Ident
+	gos     []*ast.CallExpr
+	waited  bool
+	escapes bool
+	limits  []*ast.CallExpr
+}
+
+func checkGroups(pass *analysis.Pass, body *ast.BlockStmt) {
+	groups := make(map[*types.Var]*group)
+	ast.Inspect(body, func(n ast.Node) bool {
+		if id, ok := n.(*ast.Ident); ok {
+			if v, ok := pass.TypesInfo.Defs[id].(*types.Var); ok && isGroup(v.Type()) {
+				groups[v] = &group{v: v, decl: id}
+			}
+		}
+		if call, ok := n.(*ast.CallExpr); ok {
+			checkWithContext(pass, call, body)
+		}
+		return true
+	})
+	if len(groups) == 0 {
+		return
+	}
+
+	// receivers are the uses of groups as receiver of a method call.
+	receivers := make(map[*ast.Ident]bool)
+	ast.Inspect(body, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.CallExpr:
+			sel, ok := analysisutil.Unparen(n.Fun).(*ast.SelectorExpr)
+			if !ok {
+				break
+			}
+			id, ok := analysisutil.Unparen(sel.X).(*ast.Ident)
+			if !ok {
+				break
+			}
+			g := groups[asVar(pass.TypesInfo.Uses[id])]
+			if g == nil {
+				break
+			}
+			receivers[id] = true
+			switch sel.Sel.Name {
+			case "Go", "TryGo":
+				g.gos = append(g.gos, n)
+			case "Wait":
+				g.waited = true
+			case "SetLimit":
+				g.limits = append(g.limits, n)
+			}
+		case *ast.Ident:
+			if g := groups[asVar(pass.TypesInfo.Uses[n])]; g != nil && !receivers[n] {
+				g.escapes = true
+			}
+		}
+		return true
+	})
+	for _, g := range groups {
+		if len(g.gos) == 0 {
+			continue
+		}
+		if !g.waited && !g.escapes {
+			pass.Reportf(g.decl.Pos(), "goroutines are started in the errgroup %s, but it is never waited for; call %s.Wait to wait for them and get their error", g.v.Name(), g.v.Name())
+		}
+		for _, l := range g.limits {
+			if g.gos[0].Pos() < l.Pos() {
+				pass.Reportf(l.Pos(), "SetLimit is called after goroutines were started in the errgroup %s; it panics if any of them is still running, so call it before", g.v.Name())
+			}
+		}
+	}
+}
+
+func asVar(obj types.Object) *types.Var {
+	v, _ := obj.(*types.Var)
+	return v
+}
+
+// checkWithContext reports g, ctx := errgroup.WithContext(parent) in body,
+// if ctx is discarded or function literals started in g use parent instead.
+func checkWithContext(pass *analysis.Pass, call *ast.CallExpr, body *ast.BlockStmt) {
+	if !analysisutil.IsCall(pass.TypesInfo, call, errgroupPkg+".WithContext") || len(call.Args) != 1 {
+		return
+	}
+	var as *ast.AssignStmt
+	ast.Inspect(body, func(n ast.Node) bool {
+		if a, ok := n.(*ast.AssignStmt); ok && len(a.Lhs) == 2 && len(a.Rhs) == 1 && a.Rhs[0] == ast.Expr(call) {
+			as = a
+		}
+		return as == nil
+	})
+	if as == nil {
+		return
+	}
+	if id, ok := as.Lhs[1].(*ast.Ident); ok && id.Name == "_" {
+		pass.Reportf(call.Pos(), "the context returned by errgroup.WithContext is discarded, so the goroutines aren't canceled when one fails; pass it to them or use a plain errgroup.Group")
+		return
+	}
+	g := asVar(analysisutil.ObjectOf(pass.TypesInfo, as.Lhs[0]))
+	ctx := analysisutil.ObjectOf(pass.TypesInfo, as.Lhs[1])
+	parent := analysisutil.ObjectOf(pass.TypesInfo, call.Args[0])
+	if g == nil || ctx == nil || parent == nil {
+		return
+	}
+	ast.Inspect(body, func(n ast.Node) bool {
+		c, ok := n.(*ast.CallExpr)
+		if !ok || !isGroupCall(pass, c, "Go", "TryGo") || len(c.Args) != 1 {
+			return true
+		}
+		sel := analysisutil.Unparen(c.Fun).(*ast.SelectorExpr)
+		lit, ok := analysisutil.Unparen(c.Args[0]).(*ast.FuncLit)
+		if !ok || analysisutil.ObjectOf(pass.TypesInfo, sel.X) != types.Object(g) {
+			return true
+		}
+		var usesParent *ast.Ident
+		usesCtx := false
+		ast.Inspect(lit.Body, func(n ast.Node) bool {
+			if id, ok := n.(*ast.Ident); ok {
+				switch pass.TypesInfo.Uses[id] {
+				case parent:
+					if usesParent == nil {
+						usesParent = id
+					}
+				case ctx:
+					usesCtx = true
+				}
+			}
+			return true
+		})
+		if usesParent != nil && !usesCtx {
+			pass.Reportf(usesParent.Pos(), "goroutine of the errgroup %s uses %s instead of %s returned by errgroup.WithContext, so it isn't canceled when another goroutine fails", g.Name(), parent.Name(), ctx.Name())
+		}
+		return true
+	})
+}
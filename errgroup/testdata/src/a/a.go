@@ -0,0 +1,76 @@
+package a
+
+import (
+	"context"
+	"fmt"
+
+	"golang.org/x/sync/errgroup"
+)
+
+func fetch(ctx context.Context, s string) error { return nil }
+
+func loops(urls []string) error {
+	var g errgroup.Group
+	for _, u := range urls {
+		g.Go(func() error {
+			return fetch(context.Background(), u) // want `function literal passed to g.Go captures the loop variable u, which all iterations share before Go 1.22; copy it with u := u before the call`
+		})
+	}
+	for i := 0; i < len(urls); i++ {
+		g.TryGo(func() error {
+			return fetch(context.Background(), urls[i]) // want `captures the loop variable i`
+		})
+	}
+	for _, u := range urls {
+		u := u
+		g.Go(func() error { return fetch(context.Background(), u) })
+	}
+	return g.Wait()
+}
+
+func notWaited(urls []string) {
+	g := new(errgroup.Group) // want `goroutines are started in the errgroup g, but it is never waited for; call g.Wait to wait for them and get their error`
+	g.Go(func() error { return nil })
+}
+
+func escapes() *errgroup.Group {
+	var g errgroup.Group
+	g.Go(func() error { return nil })
+	return &g
+}
+
+func deferred() error {
+	var g errgroup.Group
+	defer g.Wait()
+	g.Go(func() error { return nil })
+	return nil
+}
+
+func limit() error {
+	var g errgroup.Group
+	g.SetLimit(2)
+	g.Go(func() error { return nil })
+	g.SetLimit(4) // want `SetLimit is called after goroutines were started in the errgroup g; it panics if any of them is still running, so call it before`
+	return g.Wait()
+}
+
+func contexts(ctx context.Context) error {
+	g, gctx := errgroup.WithContext(ctx)
+	g.Go(func() error {
+		return fetch(ctx, "a") // want `goroutine of the errgroup g uses ctx instead of gctx returned by errgroup.WithContext, so it isn't canceled when another goroutine fails`
+	})
+	g.Go(func() error {
+		return fetch(gctx, "b")
+	})
+	g.Go(func() error {
+		fmt.Println(ctx.Err())
+		return fetch(gctx, "c")
+	})
+
+	h, _ := errgroup.WithContext(ctx) // want `the context returned by errgroup.WithContext is discarded, so the goroutines aren't canceled when one fails; pass it to them or use a plain errgroup.Group`
+	h.Go(func() error { return fetch(ctx, "d") })
+	if err := h.Wait(); err != nil {
+		return err
+	}
+	return g.Wait()
+}
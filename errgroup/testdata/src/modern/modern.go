@@ -0,0 +1,13 @@
+package modern
+
+import "golang.org/x/sync/errgroup"
+
+func loops(n []int) error {
+	var g errgroup.Group
+	for _, x := range n {
+		g.Go(func() error { return check(x) })
+	}
+	return g.Wait()
+}
+
+func check(int) error { return nil }
@@ -0,0 +1,250 @@
+// Copyright 2019 Axel Wagner
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package errname defines an Analyzer that checks the names of error
+// variables and types.
+package errname
+
+import (
+	"go/ast"
+	"go/types"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/Merovius/go-tools/internal/analysisutil"
+	"github.com/Merovius/go-tools/internal/fix"
+	"github.com/Merovius/go-tools/internal/nodefilter"
+	"golang.org/x/tools/go/analysis"
+)
+
+const Doc = `check the names of error variables and types
+
+Go code conventionally names errors consistently, so that readers recognize
+them at a glance. The analyzer reports
+  - local variables, parameters and results of type error not named err or
+    ending in Err, like closeErr, which tells several errors apart. With the
+    -short-scope flag, e is accepted as well in scopes of at most that many
+    lines,
+  - package-level error variables initialized in their declaration, that is
+    sentinel errors, whose names don't start with Err (or err, if
+    unexported), and
+  - error types, that is types whose values or pointers implement error,
+    whose names don't end in Error.
+
+It suggests renaming variables and unexported names, if the new name
+doesn't conflict with, or shadow, another declaration. Exported names are
+not renamed, as that would break importers.`
+
+var Analyzer = &analysis.Analyzer{
+	Name: "errname",
+	Doc:  Doc,
+	Run:  run,
+	Requires: []*analysis.Analyzer{
+		nodefilter.Analyzer,
+	},
+}
+
+var shortScope int
+
+func init() {
+	Analyzer.Flags.IntVar(&shortScope, "short-scope", 0, "accept error variables named e in scopes of at most `lines` lines")
+	// Renames are only suggested if the new name doesn't conflict.
+	fix.Register(Analyzer, fix.Safe)
+}
+
+var (
+	idents    = nodefilter.New(false, new(ast.Ident))
+	specs     = nodefilter.New(false, new(ast.ValueSpec), new(ast.TypeSpec))
+	errorType = types.Universe.Lookup("error").Type()
+)
+
+func run(pass *analysis.Pass) (interface{}, error) {
+	nodes := pass.ResultOf[nodefilter.Analyzer].(*nodefilter.Result)
+	r := &renamer{pass: pass, uses: make(map[types.Object][]*ast.Ident), names: make(map[string][]*ast.Ident)}
+	for _, n := range nodes.Nodes(idents) {
+		id := n.Node.(*ast.Ident)
+		r.names[id.Name] = append(r.names[id.Name], id)
+		if obj := pass.TypesInfo.Uses[id]; obj != nil {
+			r.uses[obj] = append(r.uses[obj], id)
+		}
+	}
+	for _, n := range nodes.Nodes(idents) {
+		id := n.Node.(*ast.Ident)
+		v, ok := pass.TypesInfo.Defs[id].(*types.Var)
+		if !ok || v.IsField() || v.Parent() == nil || v.Parent() == pass.Pkg.Scope() {
+			continue
+		}
+		if !types.Identical(v.Type(), errorType) || analysisutil.IsGenerated(pass, id.Pos()) {
+			continue
+		}
+		checkLocal(pass, r, id, v)
+	}
+	for _, n := range nodes.Nodes(specs) {
+		if analysisutil.IsGenerated(pass, n.Pos()) {
+			continue
+		}
+		switch spec := n.Node.(type) {
+		case *ast.ValueSpec:
+			if len(spec.Values) == 0 {
+				continue
+			}
+			for _, id := range spec.Names {
+				v, ok := pass.TypesInfo.Defs[id].(*types.Var)
+				if ok && v.Parent() == pass.Pkg.Scope() && types.Identical(v.Type(), errorType) {
+					checkSentinel(pass, r, id, v)
+				}
+			}
+		case *ast.TypeSpec:
+			tn, ok := pass.TypesInfo.Defs[spec.Name].(*types.TypeName)
+			if ok && tn.Parent() == pass.Pkg.Scope() && !tn.IsAlias() && isErrorType(tn.Type()) {
+				checkType(pass, r, spec.Name, tn)
+			}
+		}
+	}
+	return nil, nil
+}
+
+func checkLocal(pass *analysis.Pass, r *renamer, id *ast.Ident, v *types.Var) {
+	name := v.Name()
+	if name == "_" || name == "err" || strings.HasSuffix(name, "Err") {
+		return
+	}
+	if name == "e" && shortScope > 0 {
+		end := pass.Fset.Position(v.Parent().End()).Line
+		if end-pass.Fset.Position(id.Pos()).Line+1 <= shortScope {
+			return
+		}
+	}
+	r.report(id, v, "err", "error variable "+name+" should be named err, or end in Err to tell it apart from others")
+}
+
+func checkSentinel(pass *analysis.Pass, r *renamer, id *ast.Ident, v *types.Var) {
+	name := v.Name()
+	prefix := "err"
+	if v.Exported() {
+		prefix = "Err"
+	}
+	if name == "_" || strings.HasPrefix(name, prefix) && !startsLower(name[len(prefix):]) {
+		return
+	}
+	base := trimAffix(trimAffix(name, "Error", "error"), "Err", "err")
+	r.report(id, v, prefix+upperFirst(base), "sentinel error "+name+" should be named like "+prefix+upperFirst(base))
+}
+
+func checkType(pass *analysis.Pass, r *renamer, id *ast.Ident, tn *types.TypeName) {
+	name := tn.Name()
+	if strings.HasSuffix(name, "Error") || name == "_" {
+		return
+	}
+	base := trimAffix(trimAffix(name, "Error", "error"), "Err", "err")
+	if tn.Exported() {
+		base = upperFirst(base)
+	} else {
+		base = lowerFirst(base)
+	}
+	r.report(id, tn, base+"Error", "error type "+name+" should be named like "+base+"Error")
+}
+
+// isErrorType returns whether t or a pointer to it implements error, and t
+// isn't an interface.
+func isErrorType(t types.Type) bool {
+	if types.IsInterface(t) {
+		return false
+	}
+	iface := errorType.Underlying().(*types.Interface)
+	return types.Implements(t, iface) || types.Implements(types.NewPointer(t), iface)
+}
+
+// trimAffix trims the prefix and suffix affix from name, using the lower
+// case form lower for the prefix of unexported names. Names consisting only
+// of the affix are returned unchanged.
+func trimAffix(name, affix, lower string) string {
+	if s := strings.TrimSuffix(name, affix); s != "" {
+		name = s
+	}
+	for _, p := range []string{affix, lower} {
+		if s := strings.TrimPrefix(name, p); s != name && s != "" && !startsLower(s) {
+			return s
+		}
+	}
+	return name
+}
+
+func startsLower(s string) bool {
+	r, _ := utf8.DecodeRuneInString(s)
+	return unicode.IsLower(r)
+}
+
+func upperFirst(s string) string {
+	r, n := utf8.DecodeRuneInString(s)
+	return string(unicode.ToUpper(r)) + s[n:]
+}
+
+func lowerFirst(s string) string {
+	r, n := utf8.DecodeRuneInString(s)
+	return string(unicode.ToLower(r)) + s[n:]
+}
+
+// A renamer reports names, suggesting to rename them if that is safe.
+type renamer struct {
+	pass  *analysis.Pass
+	uses  map[types.Object][]*ast.Ident
+	names map[string][]*ast.Ident // all identifiers, by name
+}
+
+func (r *renamer) report(id *ast.Ident, obj types.Object, name, msg string) {
+	d := analysis.Diagnostic{Pos: id.Pos(), End: id.End(), Message: msg}
+	if r.canRename(obj, name) {
+		edits := []analysis.TextEdit{{Pos: id.Pos(), End: id.End(), NewText: []byte(name)}}
+		for _, u := range r.uses[obj] {
+			edits = append(edits, analysis.TextEdit{Pos: u.Pos(), End: u.End(), NewText: []byte(name)})
+		}
+		d.SuggestedFixes = []analysis.SuggestedFix{{
+			Message:   "rename " + obj.Name() + " to " + name,
+			TextEdits: edits,
+		}}
+		// Later renames must not pick the name as well.
+		r.names[name] = append(r.names[name], id)
+	}
+	r.pass.Report(d)
+}
+
+// canRename returns whether obj can be renamed to name without changing
+// the meaning of the program: obj must be unexported and no identifier
+// named name may be used in its scope, where it would either conflict with
+// obj or be shadowed by it.
+func (r *renamer) canRename(obj types.Object, name string) bool {
+	if obj.Exported() || obj.Name() == name {
+		return false
+	}
+	scope := obj.Parent()
+	if scope == r.pass.Pkg.Scope() {
+		if scope.Lookup(name) != nil || types.Universe.Lookup(name) != nil {
+			return false
+		}
+		// The package scope contains all files, so any use of the name
+		// might be affected.
+		return len(r.names[name]) == 0
+	}
+	if scope.Lookup(name) != nil {
+		return false
+	}
+	for _, id := range r.names[name] {
+		if scope.Pos() <= id.Pos() && id.Pos() < scope.End() {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,84 @@
+// Copyright 2019 Axel Wagner
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package a
+
+import (
+	"errors"
+	"io"
+	"os"
+)
+
+var (
+	ErrClosed     = errors.New("closed")
+	errNotFound   = errors.New("not found")
+	Missing       = errors.New("missing")  // want `sentinel error Missing should be named like ErrMissing`
+	invalid       = errors.New("invalid")  // want `sentinel error invalid should be named like errInvalid`
+	timeoutError  = errors.New("timeout")  // want `sentinel error timeoutError should be named like errTimeout`
+	Errorf        = errors.New("format")   // want `sentinel error Errorf should be named like ErrErrorf`
+	conflict      = errors.New("conflict") // want `sentinel error conflict should be named like errConflict`
+	errConflict   = 1
+	lastErr       error
+	defaultReader io.Reader = os.Stdin
+)
+
+type NotFoundError struct{ Name string }
+
+func (e *NotFoundError) Error() string { return e.Name + " not found" }
+
+type ErrTimeout struct{} // want `error type ErrTimeout should be named like TimeoutError`
+
+func (ErrTimeout) Error() string { return "timeout" }
+
+type parseErr struct{ line int } // want `error type parseErr should be named like parseError`
+
+func (p parseErr) Error() string { return "parse error" }
+
+type errorList []error // want `error type errorList should be named like listError`
+
+func (l errorList) Error() string { return "errors" }
+
+type Errors interface{ Error() string }
+
+func Open(name string) error {
+	f, e := os.Open(name) // want `error variable e should be named err, or end in Err`
+	if e != nil {
+		return e
+	}
+	closeErr := f.Close()
+	return closeErr
+}
+
+func Copy(w io.Writer, r io.Reader) (n int64, failure error) { // want `error variable failure should be named err`
+	n, failure = io.Copy(w, r)
+	return n, failure
+}
+
+func Wrap(err error) error {
+	if err == nil {
+		return nil
+	}
+	e2 := errors.Unwrap(err) // want `error variable e2 should be named err`
+	return e2
+}
+
+func Handle(fn func() error) {
+	_ = fn()
+	_ = func(e error) bool { return e == io.EOF } // want `error variable e should be named err`
+	var _ error
+	_ = invalid
+	_ = timeoutError
+	_ = conflict
+	_ = lastErr
+}
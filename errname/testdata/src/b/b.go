@@ -0,0 +1,41 @@
+// Copyright 2019 Axel Wagner
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package b
+
+import (
+	"io"
+	"os"
+)
+
+func IsEOF(errs []error) bool {
+	for _, e := range errs {
+		if e == io.EOF {
+			return true
+		}
+	}
+	return false
+}
+
+func Open(name string) error {
+	f, e := os.Open(name) // want `error variable e should be named err`
+	if e != nil {
+		return e
+	}
+	defer f.Close()
+	_, e = f.Stat()
+	return e
+}
+
+var isEOF = func(e error) bool { return e == io.EOF }
@@ -0,0 +1,258 @@
+// Copyright 2019 Axel Wagner
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package errortype defines an Analyzer that checks the implementation of
+// custom error types.
+package errortype
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/Merovius/go-tools/internal/analysisutil"
+	"github.com/Merovius/go-tools/internal/nodefilter"
+	"golang.org/x/tools/go/analysis"
+)
+
+const Doc = `check the implementation of custom error types
+
+The analyzer reports
+  - Is, As and Unwrap methods of error types whose signatures don't match
+    those the errors package looks for, so that they are silently ignored,
+  - error types with an Error method on the value receiver, which are
+    returned as error both as values and as pointers. errors.As with a
+    target of one of both types doesn't match errors of the other, and
+  - Unwrap methods returning their receiver, which makes errors.Is and
+    errors.As loop forever.`
+
+var Analyzer = &analysis.Analyzer{
+	Name: "errortype",
+	Doc:  Doc,
+	Run:  run,
+	Requires: []*analysis.Analyzer{
+		nodefilter.Analyzer,
+	},
+}
+
+var (
+	funcs   = nodefilter.New(false, new(ast.FuncDecl))
+	returns = nodefilter.New(true, new(ast.ReturnStmt))
+)
+
+var errorType = types.Universe.Lookup("error").Type()
+
+// signatures are the signatures the errors package looks for, by method
+// name.
+var signatures = map[string][]string{
+	"Is":     {"func(error) bool"},
+	"As":     {"func(interface{}) bool", "func(any) bool"},
+	"Unwrap": {"func() error", "func() []error"},
+}
+
+func run(pass *analysis.Pass) (interface{}, error) {
+	nodes := pass.ResultOf[nodefilter.Analyzer].(*nodefilter.Result)
+	for _, n := range nodes.Nodes(funcs) {
+		fn := n.Node.(*ast.FuncDecl)
+		if fn.Recv == nil {
+			continue
+		}
+		m, ok := pass.TypesInfo.Defs[fn.Name].(*types.Func)
+		if !ok || !isError(m.Type().(*types.Signature).Recv().Type()) {
+			continue
+		}
+		if checkSignature(pass, fn, m) && fn.Name.Name == "Unwrap" {
+			checkUnwrap(pass, fn, m)
+		}
+	}
+	checkReceivers(pass, nodes)
+	return nil, nil
+}
+
+// isError returns whether t or a pointer to it implements error.
+func isError(t types.Type) bool {
+	if p, ok := t.(*types.Pointer); ok {
+		t = p.Elem()
+	}
+	return types.Implements(t, errorType.Underlying().(*types.Interface)) ||
+		types.Implements(types.NewPointer(t), errorType.Underlying().(*types.Interface))
+}
+
+// checkSignature reports methods named like those the errors package looks
+// for, but with a different signature. It returns whether the signature is
+// correct.
+func checkSignature(pass *analysis.Pass, fn *ast.FuncDecl, m *types.Func) bool {
+	want, ok := signatures[fn.Name.Name]
+	if !ok {
+		return true
+	}
+	got := signature(pass, m.Type().(*types.Signature))
+	for _, w := range want {
+		if got == w {
+			return true
+		}
+	}
+	pass.Reportf(fn.Name.Pos(), "%s method has signature %s, but the errors package only calls %s %s",
+		fn.Name.Name, got[len("func"):], fn.Name.Name, want[0][len("func"):])
+	return false
+}
+
+// signature formats sig like types.TypeString, but without parameter names.
+func signature(pass *analysis.Pass, sig *types.Signature) string {
+	qual := types.RelativeTo(pass.Pkg)
+	tuple := func(t *types.Tuple, variadic bool) string {
+		var s []string
+		for i := 0; i < t.Len(); i++ {
+			if variadic && i == t.Len()-1 {
+				s = append(s, "..."+types.TypeString(t.At(i).Type().(*types.Slice).Elem(), qual))
+				continue
+			}
+			s = append(s, types.TypeString(t.At(i).Type(), qual))
+		}
+		return strings.Join(s, ", ")
+	}
+	s := "func(" + tuple(sig.Params(), sig.Variadic()) + ")"
+	switch sig.Results().Len() {
+	case 0:
+	case 1:
+		s += " " + tuple(sig.Results(), false)
+	default:
+		s += " (" + tuple(sig.Results(), false) + ")"
+	}
+	return s
+}
+
+func checkUnwrap(pass *analysis.Pass, fn *ast.FuncDecl, m *types.Func) {
+	recv := m.Type().(*types.Signature).Recv()
+	if fn.Body == nil || recv.Name() == "" || recv.Name() == "_" {
+		return
+	}
+	ast.Inspect(fn.Body, func(n ast.Node) bool {
+		if _, ok := n.(*ast.FuncLit); ok {
+			return false
+		}
+		ret, ok := n.(*ast.ReturnStmt)
+		if !ok || len(ret.Results) != 1 {
+			return true
+		}
+		e := analysisutil.Unparen(ret.Results[0])
+		if star, ok := e.(*ast.StarExpr); ok {
+			e = analysisutil.Unparen(star.X)
+		}
+		if id, ok := e.(*ast.Ident); ok && pass.TypesInfo.Uses[id] == recv {
+			pass.Report(analysis.Diagnostic{
+				Pos:      ret.Pos(),
+				End:      ret.End(),
+				Category: "unwrap",
+				Message:  "Unwrap returns its receiver, so errors.Is and errors.As loop forever",
+			})
+		}
+		return true
+	})
+}
+
+// checkReceivers reports error types with an Error method on the value
+// receiver, which are returned as error both as values and as pointers.
+func checkReceivers(pass *analysis.Pass, nodes *nodefilter.Result) {
+	type uses struct {
+		value, pointer token.Pos
+	}
+	returned := make(map[*types.Named]*uses)
+	var order []*types.Named
+	for _, n := range nodes.Nodes(returns) {
+		ret := n.Node.(*ast.ReturnStmt)
+		ft, _ := analysisutil.EnclosingFunc(n.Stack)
+		results := resultTypes(pass.TypesInfo, ft)
+		if len(results) != len(ret.Results) {
+			continue
+		}
+		for i, e := range ret.Results {
+			if !types.Identical(results[i], errorType) {
+				continue
+			}
+			t := pass.TypesInfo.TypeOf(e)
+			ptr := false
+			if p, ok := t.(*types.Pointer); ok {
+				t, ptr = p.Elem(), true
+			}
+			named, ok := t.(*types.Named)
+			if !ok || named.Obj().Pkg() != pass.Pkg || !valueError(named) {
+				continue
+			}
+			u := returned[named]
+			if u == nil {
+				u = new(uses)
+				returned[named] = u
+				order = append(order, named)
+			}
+			if ptr && u.pointer == token.NoPos {
+				u.pointer = e.Pos()
+			} else if !ptr && u.value == token.NoPos {
+				u.value = e.Pos()
+			}
+		}
+	}
+	sort.Slice(order, func(i, j int) bool { return order[i].Obj().Pos() < order[j].Obj().Pos() })
+	for _, named := range order {
+		u := returned[named]
+		if u.value == token.NoPos || u.pointer == token.NoPos {
+			continue
+		}
+		name := named.Obj().Name()
+		pass.Report(analysis.Diagnostic{
+			Pos:      named.Obj().Pos(),
+			Category: "receiver",
+			Message: name + " is returned as error both as " + name + " (at " + shortPos(pass, u.value) +
+				") and as *" + name + " (at " + shortPos(pass, u.pointer) + "); errors.As only matches one of both",
+		})
+	}
+}
+
+// valueError returns whether t has an Error method on the value receiver.
+func valueError(t *types.Named) bool {
+	for i := 0; i < t.NumMethods(); i++ {
+		if m := t.Method(i); m.Name() == "Error" {
+			_, ptr := m.Type().(*types.Signature).Recv().Type().(*types.Pointer)
+			return !ptr
+		}
+	}
+	return false
+}
+
+func resultTypes(info *types.Info, ft *ast.FuncType) []types.Type {
+	if ft == nil || ft.Results == nil {
+		return nil
+	}
+	var ts []types.Type
+	for _, f := range ft.Results.List {
+		t := info.TypeOf(f.Type)
+		n := len(f.Names)
+		if n == 0 {
+			n = 1
+		}
+		for i := 0; i < n; i++ {
+			ts = append(ts, t)
+		}
+	}
+	return ts
+}
+
+func shortPos(pass *analysis.Pass, pos token.Pos) string {
+	p := pass.Fset.Position(pos)
+	return filepath.Base(p.Filename) + ":" + strconv.Itoa(p.Line)
+}
@@ -0,0 +1,63 @@
+package a
+
+import "errors"
+
+type NotFound struct{ Name string } // want `NotFound is returned as error both as NotFound \(at a.go:13\) and as \*NotFound \(at a.go:11\); errors.As only matches one of both`
+
+func (e NotFound) Error() string { return e.Name + " not found" }
+
+func find(name string, ptr bool) (int, error) {
+	if ptr {
+		return 0, &NotFound{name}
+	}
+	return 0, NotFound{name}
+}
+
+type Timeout struct{}
+
+func (Timeout) Error() string { return "timeout" }
+
+func wait() error { return Timeout{} }
+
+type Wrapped struct{ err error }
+
+func (w *Wrapped) Error() string { return w.err.Error() }
+
+func (w *Wrapped) Unwrap() error { return w.err }
+
+func (w *Wrapped) Is(target error) bool { return target == w.err }
+
+func (w *Wrapped) As(target interface{}) bool { return false }
+
+func wrap(err error) error { return &Wrapped{err} }
+
+type Bad struct{ err error }
+
+func (b *Bad) Error() string { return "bad" }
+
+func (b *Bad) Unwrap() *Bad { return b } // want `Unwrap method has signature \(\) \*Bad, but the errors package only calls Unwrap \(\) error`
+
+func (b *Bad) Is(target *Bad) bool { return b == target } // want `Is method has signature \(\*Bad\) bool, but the errors package only calls Is \(error\) bool`
+
+func (b *Bad) As(target interface{}) error { return nil } // want `As method has signature`
+
+type Loop struct{}
+
+func (l *Loop) Error() string { return "loop" }
+
+func (l *Loop) Unwrap() error {
+	if l == nil {
+		return errors.New("nil")
+	}
+	return l // want `Unwrap returns its receiver, so errors.Is and errors.As loop forever`
+}
+
+type Multi struct{ errs []error }
+
+func (m Multi) Error() string { return "multi" }
+
+func (m Multi) Unwrap() []error { return m.errs }
+
+type notAnError struct{}
+
+func (notAnError) Is(x int) bool { return false }
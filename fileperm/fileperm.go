@@ -0,0 +1,181 @@
+// Copyright 2019 Axel Wagner
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package fileperm defines an Analyzer that checks file permissions passed to
+// functions of package os.
+package fileperm
+
+import (
+	"go/ast"
+	"go/token"
+	"strconv"
+	"strings"
+
+	"github.com/Merovius/go-tools/internal/analysisutil"
+	"github.com/Merovius/go-tools/internal/fix"
+	"github.com/Merovius/go-tools/internal/goversion"
+	"github.com/Merovius/go-tools/internal/nodefilter"
+	"golang.org/x/tools/go/analysis"
+)
+
+const Doc = `check file permissions passed to os functions
+
+The analyzer reports permissions given as decimal literals which look like
+octal ones, like 644 instead of 0644, and permissions which make files or
+directories writable by everyone, like 0666 or 0777. It suggests rewriting
+them to the intended octal literal, or to one without write permissions for
+group and others. Octal literals use the 0o prefix in files compiled with
+Go 1.13 or later.`
+
+var Analyzer = &analysis.Analyzer{
+	Name: "fileperm",
+	Doc:  Doc,
+	Run:  run,
+	Requires: []*analysis.Analyzer{
+		goversion.Analyzer,
+		nodefilter.Analyzer,
+	},
+}
+
+func init() {
+	// The fixes change the permissions of created files, hopefully to
+	// the intended ones.
+	fix.Register(Analyzer, fix.Unsafe)
+}
+
+var calls = nodefilter.New(false, new(ast.CallExpr))
+
+// permArgs maps functions taking a permission to the index of the
+// argument.
+var permArgs = map[string]int{
+	"os.OpenFile":         2,
+	"os.WriteFile":        2,
+	"io/ioutil.WriteFile": 2,
+	"os.Mkdir":            1,
+	"os.MkdirAll":         1,
+	"os.Chmod":            1,
+	"(*os.File).Chmod":    0,
+}
+
+func run(pass *analysis.Pass) (interface{}, error) {
+	if !analysisutil.Imports(pass.Pkg, "os") && !analysisutil.Imports(pass.Pkg, "io/ioutil") {
+		return nil, nil
+	}
+	versions := pass.ResultOf[goversion.Analyzer].(*goversion.Result)
+	nodes := pass.ResultOf[nodefilter.Analyzer].(*nodefilter.Result)
+	for _, n := range nodes.Nodes(calls) {
+		call := n.Node.(*ast.CallExpr)
+		i, ok := permArgs[analysisutil.CalleeName(pass.TypesInfo, call)]
+		if !ok || i >= len(call.Args) {
+			continue
+		}
+		lit, ok := permLiteral(pass, call.Args[i])
+		if !ok {
+			continue
+		}
+		prefix := "0"
+		if versions.AtLeast(analysisutil.File(pass, lit.Pos()), "go1.13") {
+			prefix = "0o"
+		}
+		check(pass, lit, prefix)
+	}
+	return nil, nil
+}
+
+// permLiteral returns the integer literal in e, which may be converted to
+// os.FileMode.
+func permLiteral(pass *analysis.Pass, e ast.Expr) (*ast.BasicLit, bool) {
+	e = analysisutil.Unparen(e)
+	if call, ok := e.(*ast.CallExpr); ok && len(call.Args) == 1 {
+		if tv := pass.TypesInfo.Types[call.Fun]; tv.IsType() {
+			e = analysisutil.Unparen(call.Args[0])
+		}
+	}
+	lit, ok := e.(*ast.BasicLit)
+	return lit, ok && lit.Kind == token.INT
+}
+
+func check(pass *analysis.Pass, lit *ast.BasicLit, prefix string) {
+	v, err := strconv.ParseInt(lit.Value, 0, 64)
+	if err != nil || v < 0 {
+		return
+	}
+	if isDecimal(lit.Value) {
+		if v < 8 || !octalDigits(lit.Value) || len(lit.Value) > 4 || common[v] {
+			return
+		}
+		intended := prefix + lit.Value
+		report(pass, lit, "decimal", "permission "+lit.Value+" is a decimal literal; did you mean "+intended+"?", intended)
+		return
+	}
+	if v&0002 == 0 {
+		return
+	}
+	if p := literalPrefix(lit.Value); p != "" {
+		prefix = p
+	}
+	report(pass, lit, "", "permission "+lit.Value+" makes the file writable by everyone", format(prefix, v&^0022))
+}
+
+// common contains common permissions. Decimal literals with their value,
+// like 420 for 0644, are assumed to be intentional.
+var common = map[int64]bool{
+	0400: true, 0444: true, 0555: true, 0600: true, 0640: true,
+	0644: true, 0666: true, 0700: true, 0750: true, 0755: true, 0777: true,
+}
+
+func report(pass *analysis.Pass, lit *ast.BasicLit, category, msg, replacement string) {
+	pass.Report(analysis.Diagnostic{
+		Pos:      lit.Pos(),
+		End:      lit.End(),
+		Category: category,
+		Message:  msg,
+		SuggestedFixes: []analysis.SuggestedFix{{
+			Message: "use " + replacement,
+			TextEdits: []analysis.TextEdit{{
+				Pos:     lit.Pos(),
+				End:     lit.End(),
+				NewText: []byte(replacement),
+			}},
+		}},
+	})
+}
+
+func isDecimal(s string) bool {
+	return s == "0" || s[0] != '0'
+}
+
+func octalDigits(s string) bool {
+	return strings.Trim(s, "01234567") == ""
+}
+
+// literalPrefix returns the prefix of the octal literal s, or "" if it isn't
+// one.
+func literalPrefix(s string) string {
+	switch {
+	case strings.HasPrefix(s, "0o"), strings.HasPrefix(s, "0O"):
+		return s[:2]
+	case len(s) > 1 && s[0] == '0' && octalDigits(s):
+		return "0"
+	}
+	return ""
+}
+
+func format(prefix string, v int64) string {
+	s := strconv.FormatInt(v, 8)
+	for len(s) < 3 {
+		s = "0" + s
+	}
+	return prefix + s
+}
@@ -0,0 +1,25 @@
+package a
+
+import (
+	"io/ioutil"
+	"os"
+)
+
+const perm = 0644
+
+func f(name string, data []byte, file *os.File) {
+	os.OpenFile(name, os.O_CREATE, 644)            // want `permission 644 is a decimal literal; did you mean 0o644\?`
+	ioutil.WriteFile(name, data, os.FileMode(600)) // want `permission 600 is a decimal literal; did you mean 0o600\?`
+	os.Mkdir(name, 755)                            // want `permission 755 is a decimal literal; did you mean 0o755\?`
+	os.MkdirAll(name, 0777)                        // want `permission 0777 makes the file writable by everyone`
+	os.Chmod(name, 0o666)                          // want `permission 0o666 makes the file writable by everyone`
+	file.Chmod(0x1ff)                              // want `permission 0x1ff makes the file writable by everyone`
+	os.Chmod(name, 1777)                           // want `permission 1777 is a decimal literal; did you mean 0o1777\?`
+	os.Chmod(name, 0)
+	os.Chmod(name, 4)
+	os.Chmod(name, 0644)
+	os.Chmod(name, 0o700)
+	os.Chmod(name, 420)
+	os.Chmod(name, perm)
+	os.Chmod(name, 0640|os.ModeSticky)
+}
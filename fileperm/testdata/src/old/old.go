@@ -0,0 +1,8 @@
+package old
+
+import "os"
+
+func f(name string) {
+	os.Mkdir(name, 755)   // want `permission 755 is a decimal literal; did you mean 0755\?`
+	os.Mkdir(name, 00777) // want `permission 00777 makes the file writable by everyone`
+}
@@ -0,0 +1,199 @@
+// Copyright 2019 Axel Wagner
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package finalizer defines an Analyzer that checks the use of
+// runtime.SetFinalizer.
+package finalizer
+
+import (
+	"go/ast"
+	"go/types"
+	"strings"
+
+	"github.com/Merovius/go-tools/internal/analysisutil"
+	"github.com/Merovius/go-tools/internal/nodefilter"
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/types/typeutil"
+)
+
+const Doc = `check the use of runtime.SetFinalizer
+
+The analyzer reports
+  - finalizers referring to the object they are set on, like closures using
+    it or method values of it. The finalizer keeps the object reachable, so
+    it is never collected and the finalizer never runs, and
+  - handles stored in fields of objects with finalizers, like file
+    descriptors, which are passed to syscalls or C functions after the last
+    use of the object. The object might be collected and finalized while
+    the call still uses the handle, unless runtime.KeepAlive is called
+    after it.`
+
+var Analyzer = &analysis.Analyzer{
+	Name: "finalizer",
+	Doc:  Doc,
+	Run:  run,
+	Requires: []*analysis.Analyzer{
+		nodefilter.Analyzer,
+	},
+}
+
+var (
+	calls = nodefilter.New(false, new(ast.CallExpr))
+	funcs = nodefilter.New(false, new(ast.FuncDecl), new(ast.FuncLit))
+)
+
+func run(pass *analysis.Pass) (interface{}, error) {
+	nodes := pass.ResultOf[nodefilter.Analyzer].(*nodefilter.Result)
+	finalized := new(typeutil.Map)
+	for _, n := range nodes.Nodes(calls) {
+		call := n.Node.(*ast.CallExpr)
+		if !analysisutil.IsCall(pass.TypesInfo, call, "runtime.SetFinalizer") || len(call.Args) != 2 {
+			continue
+		}
+		if t := pass.TypesInfo.TypeOf(call.Args[0]); t != nil {
+			finalized.Set(t, true)
+		}
+		checkSelfReference(pass, call)
+	}
+	if finalized.Len() == 0 {
+		return nil, nil
+	}
+	for _, n := range nodes.Nodes(funcs) {
+		var body *ast.BlockStmt
+		switch fn := n.Node.(type) {
+		case *ast.FuncDecl:
+			body = fn.Body
+		case *ast.FuncLit:
+			body = fn.Body
+		}
+		if body != nil {
+			checkKeepAlive(pass, finalized, body)
+		}
+	}
+	return nil, nil
+}
+
+// checkSelfReference reports the finalizer set by call, if it refers to
+// the object it is set on.
+func checkSelfReference(pass *analysis.Pass, call *ast.CallExpr) {
+	obj := analysisutil.ObjectOf(pass.TypesInfo, call.Args[0])
+	v, ok := obj.(*types.Var)
+	if !ok {
+		return
+	}
+	switch fn := analysisutil.Unparen(call.Args[1]).(type) {
+	case *ast.FuncLit:
+		// The object is passed to the finalizer as its argument,
+		// closures don't need to refer to it.
+		ast.Inspect(fn.Body, func(n ast.Node) bool {
+			id, ok := n.(*ast.Ident)
+			if ok && pass.TypesInfo.Uses[id] == v {
+				pass.Reportf(id.Pos(), "finalizer refers to %s, which keeps it reachable, so it is never finalized; use the argument of the finalizer instead", v.Name())
+				return false
+			}
+			return true
+		})
+	case *ast.SelectorExpr:
+		if sel := pass.TypesInfo.Selections[fn]; sel == nil || sel.Kind() != types.MethodVal {
+			return
+		}
+		if analysisutil.ObjectOf(pass.TypesInfo, fn.X) == v {
+			pass.Reportf(fn.Pos(), "method value %s refers to %s, which keeps it reachable, so it is never finalized; use a method expression like (*T).%s instead",
+				analysisutil.Render(pass.Fset, fn), v.Name(), fn.Sel.Name)
+		}
+	}
+}
+
+// checkKeepAlive reports handles of objects with finalizers in body,
+// which are passed to a call after the last use of the object.
+func checkKeepAlive(pass *analysis.Pass, finalized *typeutil.Map, body *ast.BlockStmt) {
+	// The last use of every variable of a finalized type in body.
+	last := make(map[*types.Var]ast.Node)
+	ast.Inspect(body, func(n ast.Node) bool {
+		if _, ok := n.(*ast.FuncLit); ok {
+			// Closures are checked on their own.
+			return false
+		}
+		id, ok := n.(*ast.Ident)
+		if !ok {
+			return true
+		}
+		if v, ok := pass.TypesInfo.Uses[id].(*types.Var); ok && finalized.At(v.Type()) != nil {
+			last[v] = id
+		}
+		return true
+	})
+	ast.Inspect(body, func(n ast.Node) bool {
+		if _, ok := n.(*ast.FuncLit); ok {
+			return false
+		}
+		call, ok := n.(*ast.CallExpr)
+		if !ok || !usesHandles(pass, call) {
+			return true
+		}
+		for _, arg := range call.Args {
+			sel, ok := analysisutil.Unparen(arg).(*ast.SelectorExpr)
+			if !ok || !isHandle(pass.TypesInfo.TypeOf(sel)) {
+				continue
+			}
+			id, ok := analysisutil.Unparen(sel.X).(*ast.Ident)
+			if !ok {
+				continue
+			}
+			v, ok := pass.TypesInfo.Uses[id].(*types.Var)
+			if !ok || finalized.At(v.Type()) == nil || last[v] != id {
+				continue
+			}
+			pass.Report(analysis.Diagnostic{
+				Pos:      arg.Pos(),
+				End:      arg.End(),
+				Category: "keepalive",
+				Message:  v.Name() + " might be finalized while the call still uses " + analysisutil.Render(pass.Fset, sel) + "; call runtime.KeepAlive(" + v.Name() + ") after it",
+			})
+		}
+		return true
+	})
+}
+
+// usesHandles returns whether call is to a function using handles of
+// operating system resources or C memory.
+func usesHandles(pass *analysis.Pass, call *ast.CallExpr) bool {
+	if sel, ok := analysisutil.Unparen(call.Fun).(*ast.SelectorExpr); ok {
+		if id, ok := sel.X.(*ast.Ident); ok && id.Name == "C" {
+			return true
+		}
+	}
+	fn := analysisutil.Callee(pass.TypesInfo, call)
+	if fn == nil || fn.Pkg() == nil {
+		return false
+	}
+	if strings.HasPrefix(fn.Name(), "_Cfunc_") {
+		return true
+	}
+	switch analysisutil.TrimVendor(fn.Pkg().Path()) {
+	case "syscall", "golang.org/x/sys/unix", "golang.org/x/sys/windows":
+		return true
+	}
+	return false
+}
+
+// isHandle returns whether t might be a handle to a resource released by a
+// finalizer, like a file descriptor or a pointer to C memory.
+func isHandle(t types.Type) bool {
+	if t == nil {
+		return false
+	}
+	b, ok := t.Underlying().(*types.Basic)
+	return ok && (b.Info()&types.IsInteger != 0 || b.Kind() == types.UnsafePointer)
+}
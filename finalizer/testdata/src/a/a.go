@@ -0,0 +1,82 @@
+package a
+
+import (
+	"fmt"
+	"runtime"
+	"syscall"
+)
+
+type File struct {
+	fd   int
+	name string
+}
+
+func Open(name string) (*File, error) {
+	fd, err := syscall.Open(name, syscall.O_RDONLY, 0)
+	if err != nil {
+		return nil, err
+	}
+	f := &File{fd: fd, name: name}
+	runtime.SetFinalizer(f, (*File).Close)
+	return f, nil
+}
+
+func (f *File) Close() error {
+	return syscall.Close(f.fd) // want `f might be finalized while the call still uses f.fd`
+}
+
+func (f *File) CloseOnce() error {
+	err := syscall.Close(f.fd)
+	runtime.SetFinalizer(f, nil)
+	return err
+}
+
+func (f *File) Read(b []byte) (int, error) {
+	return syscall.Read(f.fd, b) // want `f might be finalized while the call still uses f.fd; call runtime.KeepAlive\(f\) after it`
+}
+
+func (f *File) ReadKept(b []byte) (int, error) {
+	n, err := syscall.Read(f.fd, b)
+	runtime.KeepAlive(f)
+	return n, err
+}
+
+func (f *File) Write(b []byte) (int, error) {
+	n, err := syscall.Write(f.fd, b)
+	if err != nil {
+		return n, fmt.Errorf("%s: %v", f.name, err)
+	}
+	return n, nil
+}
+
+func (f *File) String() string {
+	return fmt.Sprint(f.fd)
+}
+
+type conn struct{ fd int }
+
+func (c *conn) close() { syscall.Close(c.fd) } // want `c might be finalized`
+
+func newConn(fd int) *conn {
+	c := &conn{fd}
+	runtime.SetFinalizer(c, func(*conn) {
+		c.close() // want `finalizer refers to c, which keeps it reachable, so it is never finalized; use the argument of the finalizer instead`
+	})
+	return c
+}
+
+func newConn2(fd int) *conn {
+	c := &conn{fd}
+	runtime.SetFinalizer(c, func(c *conn) { c.close() })
+	return c
+}
+
+type buf struct{ p uintptr }
+
+func (b *buf) free() {}
+
+func newBuf() *buf {
+	b := new(buf)
+	runtime.SetFinalizer(b, b.free) // want `method value b.free refers to b, which keeps it reachable, so it is never finalized; use a method expression like \(\*T\).free instead`
+	return b
+}
@@ -0,0 +1,84 @@
+// Copyright 2019 Axel Wagner
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package genericbloat defines an Analyzer that checks for generic code
+// instantiated so often, or with such large type arguments, that it
+// noticeably grows binaries and build times.
+package genericbloat
+
+import (
+	"strconv"
+
+	"golang.org/x/tools/go/analysis"
+)
+
+const Doc = `check for costly instantiations of generic code
+
+The compiler generates a copy of the code of a generic function or type for
+every shape of its type arguments. All pointer types have the same shape,
+other types have the shape of their underlying type. The analyzer counts the
+distinct shapes each generic function and type of the module is instantiated
+with, in a package and all of its dependencies in the same module, and
+reports the instantiation exceeding the -max-shapes flag. Past that, an
+interface type argument or a non-generic function is usually cheaper.
+
+It also reports instantiations with struct and array type arguments of at
+least as many bytes as given by the -max-size flag. They get a copy of the
+code of their own and are copied by value, where a pointer would share the
+code of all pointer types.
+
+Only instantiations outside of test files and with type arguments not
+depending on type parameters are considered.`
+
+var Analyzer = &analysis.Analyzer{
+	Name:      "genericbloat",
+	Doc:       Doc,
+	Run:       run,
+	FactTypes: []analysis.Fact{new(instances)},
+}
+
+var (
+	maxShapes       = 16
+	maxSize   int64 = 512
+)
+
+func init() {
+	Analyzer.Flags.IntVar(&maxShapes, "max-shapes", maxShapes, "maximum number of shapes a generic function or type is instantiated with")
+	Analyzer.Flags.Int64Var(&maxSize, "max-size", maxSize, "minimum size in bytes of reported struct and array type arguments")
+}
+
+// instances is a package fact listing the shapes of the type arguments the
+// generic functions and types of the module are instantiated with in the
+// package. It is exported for every package, to record its module.
+type instances struct {
+	Module   string // path of the module of the package, if it has one
+	Generics []generic
+}
+
+// A generic lists the shapes of the instantiations of a generic function or
+// type.
+type generic struct {
+	Name   string // like "example.com/pkg.Map"
+	Shapes []string
+}
+
+func (*instances) AFact() {}
+
+func (in *instances) String() string {
+	n := 0
+	for _, g := range in.Generics {
+		n += len(g.Shapes)
+	}
+	return strconv.Itoa(n) + " instances"
+}
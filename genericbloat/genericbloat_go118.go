@@ -0,0 +1,174 @@
+// Copyright 2019 Axel Wagner
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build go1.18
+// +build go1.18
+
+package genericbloat
+
+import (
+	"go/ast"
+	"go/types"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/Merovius/go-tools/internal/analysisutil"
+	"github.com/Merovius/go-tools/internal/gomod"
+	"golang.org/x/tools/go/analysis"
+)
+
+// A site is an instantiation in the package.
+type site struct {
+	id    *ast.Ident
+	obj   types.Object
+	name  string
+	shape string
+}
+
+func run(pass *analysis.Pass) (interface{}, error) {
+	if analysisutil.IsStd(pass.Pkg.Path()) || len(pass.Files) == 0 {
+		return nil, nil
+	}
+	mod, err := gomod.Find(filepath.Dir(pass.Fset.File(pass.Files[0].Pos()).Name()))
+	if err != nil {
+		return nil, err
+	}
+	fact := new(instances)
+	if mod != nil {
+		fact.Module = mod.Module
+	}
+	inModule := func(pkg *types.Package) bool {
+		if pkg == pass.Pkg {
+			return true
+		}
+		var f instances
+		return pass.ImportPackageFact(pkg, &f) && f.Module == fact.Module
+	}
+
+	var ids []*ast.Ident
+	for id := range pass.TypesInfo.Instances {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i].Pos() < ids[j].Pos() })
+
+	var sites []site
+	own := make(map[string]map[string]bool)
+	for _, id := range ids {
+		obj := pass.TypesInfo.Uses[id]
+		if obj == nil || obj.Pkg() == nil || analysisutil.IsTestFile(pass, id.Pos()) || !inModule(obj.Pkg()) {
+			continue
+		}
+		args := pass.TypesInfo.Instances[id].TypeArgs
+		shapes := make([]string, args.Len())
+		for i := range shapes {
+			arg := args.At(i)
+			if analysisutil.HasTypeParam(arg) {
+				shapes = nil
+				break
+			}
+			shapes[i] = shape(arg)
+		}
+		if shapes == nil {
+			continue
+		}
+		for i := range shapes {
+			checkSize(pass, id, obj, args.At(i))
+		}
+		s := site{id, obj, obj.Pkg().Path() + "." + obj.Name(), strings.Join(shapes, ", ")}
+		sites = append(sites, s)
+		if own[s.name] == nil {
+			own[s.name] = make(map[string]bool)
+		}
+		own[s.name][s.shape] = true
+	}
+	for name, shapes := range own {
+		g := generic{Name: name}
+		for s := range shapes {
+			g.Shapes = append(g.Shapes, s)
+		}
+		sort.Strings(g.Shapes)
+		fact.Generics = append(fact.Generics, g)
+	}
+	sort.Slice(fact.Generics, func(i, j int) bool { return fact.Generics[i].Name < fact.Generics[j].Name })
+	pass.ExportPackageFact(fact)
+
+	// deps maps the generics of the module to the shapes they are
+	// instantiated with in the dependencies of the package.
+	deps := make(map[string]map[string]bool)
+	for _, f := range pass.AllPackageFacts() {
+		in, ok := f.Fact.(*instances)
+		if !ok || f.Package == pass.Pkg || in.Module != fact.Module {
+			continue
+		}
+		for _, g := range in.Generics {
+			if deps[g.Name] == nil {
+				deps[g.Name] = make(map[string]bool)
+			}
+			for _, s := range g.Shapes {
+				deps[g.Name][s] = true
+			}
+		}
+	}
+
+	// Report the first instantiation adding a shape, if the package pushes
+	// the number of shapes past the limit. Packages importing it then
+	// don't report it again.
+	reported := make(map[string]bool)
+	for _, s := range sites {
+		if reported[s.name] || deps[s.name][s.shape] || len(deps[s.name]) > maxShapes {
+			continue
+		}
+		total := len(deps[s.name])
+		for sh := range own[s.name] {
+			if !deps[s.name][sh] {
+				total++
+			}
+		}
+		if total <= maxShapes {
+			continue
+		}
+		reported[s.name] = true
+		pass.Reportf(s.id.Pos(), "%s is instantiated with %d shapes of type arguments in this package and its dependencies, more than %d, each compiled to a copy of its code", s.obj.Name(), total, maxShapes)
+	}
+	return nil, nil
+}
+
+// shape returns the shape of a type argument, which determines the copy of
+// the code of a generic function or type used for it.
+func shape(t types.Type) string {
+	switch u := t.Underlying().(type) {
+	case *types.Pointer:
+		return "pointer"
+	case *types.Basic:
+		if u.Kind() == types.UnsafePointer {
+			return "pointer"
+		}
+	}
+	return types.TypeString(t.Underlying(), nil)
+}
+
+// checkSize reports arg, if it is a large struct or array.
+func checkSize(pass *analysis.Pass, id *ast.Ident, obj types.Object, arg types.Type) {
+	switch arg.Underlying().(type) {
+	case *types.Struct, *types.Array:
+	default:
+		return
+	}
+	size := pass.TypesSizes.Sizeof(arg)
+	if size < maxSize {
+		return
+	}
+	pass.Reportf(id.Pos(), "%s is instantiated with %s of %d bytes, which gets a copy of the code of its own and is copied by value; use a pointer instead", obj.Name(), types.TypeString(arg, types.RelativeTo(pass.Pkg)), size)
+}
@@ -0,0 +1,29 @@
+package a // want package:"3 instances"
+
+import "strconv"
+
+type Big struct {
+	Data [128]byte
+}
+
+func Map[T, U any](s []T, f func(T) U) []U {
+	var us []U
+	for _, v := range s {
+		us = append(us, f(v))
+	}
+	return us
+}
+
+type Box[T any] struct {
+	V T
+}
+
+func Get[T any](b *Box[T]) T {
+	return b.V
+}
+
+var (
+	_ = Map([]int{1}, strconv.Itoa)
+	_ = Box[Big]{} // want `Box is instantiated with Big of 128 bytes, which gets a copy of the code of its own`
+	_ = Box[*Big]{}
+)
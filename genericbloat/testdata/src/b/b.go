@@ -0,0 +1,12 @@
+package b // want package:"3 instances"
+
+import "a"
+
+type ID int
+
+func F() {
+	_ = a.Map([]ID{1}, func(ID) string { return "" })
+	_ = a.Map([]string{"a"}, func(string) int { return 0 })
+	_ = a.Map([]*int{nil}, func(*int) *string { return nil })
+	_ = a.Map([]*string{nil}, func(*string) *ID { return nil })
+}
@@ -0,0 +1,15 @@
+package c // want package:"3 instances"
+
+import (
+	"a"
+	"b"
+	"sort"
+)
+
+func G() {
+	b.F()
+	_ = a.Map([]b.ID{1}, func(b.ID) string { return "" })
+	_ = a.Map([]float64{1}, func(float64) string { return "" }) // want `Map is instantiated with 5 shapes of type arguments in this package and its dependencies, more than 3`
+	_ = a.Map([]bool{true}, func(bool) string { return "" })
+	sort.Slice([]int{}, func(i, j int) bool { return false })
+}
@@ -0,0 +1,288 @@
+// Copyright 2019 Axel Wagner
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package gorecover defines an Analyzer that checks for goroutines in servers
+// which can panic without recovering.
+package gorecover
+
+import (
+	"go/ast"
+	"go/types"
+	"strings"
+
+	"github.com/Merovius/go-tools/internal/analysisutil"
+	"github.com/Merovius/go-tools/internal/nodefilter"
+	"golang.org/x/tools/go/analysis"
+)
+
+const Doc = `check for goroutines in servers which can panic without recovering
+
+A panic which isn't recovered by the goroutine it happens in crashes the
+whole program, even if the goroutine serves only a single request or job.
+net/http recovers panics of handlers, but not of goroutines they start. The
+analyzer reports go statements in servers and workers, whose function can
+panic and doesn't defer a function calling recover.
+
+A function can panic if it calls panic, a function of the standard library
+documented to panic, like regexp.MustCompile, or another function which can
+panic, in the same or another package outside the standard library. Other
+functions of the standard library are assumed not to panic, as their
+panics are bugs or caused by misuse. Calls of function values and
+interface methods are not followed. Go statements are considered to be in
+servers and workers if they are in a loop, in a function with a name like
+Serve or Run, or in a package importing net/http or gRPC.`
+
+var Analyzer = &analysis.Analyzer{
+	Name: "gorecover",
+	Doc:  Doc,
+	Run:  run,
+	Requires: []*analysis.Analyzer{
+		nodefilter.Analyzer,
+	},
+	FactTypes: []analysis.Fact{new(funcFact)},
+}
+
+var (
+	funcs = nodefilter.New(false, new(ast.FuncDecl))
+	gos   = nodefilter.New(true, new(ast.GoStmt))
+)
+
+// funcFact is an object fact of functions which can panic or recover.
+type funcFact struct {
+	Panics   string // name of a called function which can panic
+	Recovers bool   // whether the function calls recover
+}
+
+func (*funcFact) AFact() {}
+
+func (f *funcFact) String() string {
+	var s []string
+	if f.Panics != "" {
+		s = append(s, "panics via "+f.Panics)
+	}
+	if f.Recovers {
+		s = append(s, "recovers")
+	}
+	return strings.Join(s, ", ")
+}
+
+// stdPanics are functions of the standard library documented to panic.
+var stdPanics = []string{
+	"regexp.MustCompile",
+	"regexp.MustCompilePOSIX",
+	"text/template.Must",
+	"html/template.Must",
+	"log.Panic",
+	"log.Panicf",
+	"log.Panicln",
+	"(*log.Logger).Panic",
+	"(*log.Logger).Panicf",
+	"(*log.Logger).Panicln",
+}
+
+// serverWords are words in the names of functions running servers or
+// workers.
+var serverWords = map[string]bool{
+	"handle": true,
+	"listen": true,
+	"loop":   true,
+	"run":    true,
+	"serve":  true,
+	"server": true,
+	"start":  true,
+	"worker": true,
+}
+
+type checker struct {
+	pass     *analysis.Pass
+	panics   map[*types.Func]string
+	recovers map[*types.Func]bool
+}
+
+func run(pass *analysis.Pass) (interface{}, error) {
+	if analysisutil.IsStd(pass.Pkg.Path()) {
+		// Only the functions in stdPanics are considered to panic.
+		return nil, nil
+	}
+	nodes := pass.ResultOf[nodefilter.Analyzer].(*nodefilter.Result)
+	c := &checker{pass, make(map[*types.Func]string), make(map[*types.Func]bool)}
+
+	// Determine the functions of the package which can panic. As they can
+	// call each other, repeat until nothing changes.
+	var all, decls []*ast.FuncDecl
+	for _, n := range nodes.Nodes(funcs) {
+		fn := n.Node.(*ast.FuncDecl)
+		obj, ok := pass.TypesInfo.Defs[fn.Name].(*types.Func)
+		if !ok || fn.Body == nil {
+			continue
+		}
+		c.recovers[obj] = callsRecover(pass, fn.Body)
+		all = append(all, fn)
+	}
+	for _, fn := range all {
+		if !defersRecover(c, fn.Body) {
+			decls = append(decls, fn)
+		}
+	}
+	for changed := true; changed; {
+		changed = false
+		for _, fn := range decls {
+			obj := pass.TypesInfo.Defs[fn.Name].(*types.Func)
+			if _, ok := c.panics[obj]; ok {
+				continue
+			}
+			if via := c.mayPanic(fn.Body); via != "" {
+				c.panics[obj] = via
+				changed = true
+			}
+		}
+	}
+	for _, fn := range all {
+		obj := pass.TypesInfo.Defs[fn.Name].(*types.Func)
+		if f := (funcFact{c.panics[obj], c.recovers[obj]}); f != (funcFact{}) {
+			pass.ExportObjectFact(obj, &f)
+		}
+	}
+
+	server := analysisutil.Imports(pass.Pkg, "net/http") || analysisutil.Imports(pass.Pkg, "google.golang.org/grpc")
+	for _, n := range nodes.Nodes(gos) {
+		g := n.Node.(*ast.GoStmt)
+		if !server && !inServer(n.Stack) {
+			continue
+		}
+		var via string
+		if lit, ok := g.Call.Fun.(*ast.FuncLit); ok {
+			if defersRecover(c, lit.Body) {
+				continue
+			}
+			via = c.mayPanic(lit.Body)
+		} else if fn := analysisutil.Callee(pass.TypesInfo, g.Call); fn != nil {
+			via = c.funcPanics(fn)
+		}
+		if via == "" {
+			continue
+		}
+		pass.Reportf(g.Pos(), "goroutine can panic (via %s) without recovering, which crashes the program; defer a function calling recover in it", via)
+	}
+	return nil, nil
+}
+
+// inServer returns whether the go statement at the top of stack is in a
+// loop or in a function with a name suggesting a server or worker.
+func inServer(stack []ast.Node) bool {
+	for _, n := range stack {
+		switch n := n.(type) {
+		case *ast.ForStmt, *ast.RangeStmt:
+			return true
+		case *ast.FuncDecl:
+			for _, w := range analysisutil.Words(n.Name.Name) {
+				if serverWords[w] {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+func isBuiltin(pass *analysis.Pass, e ast.Expr, name string) bool {
+	id, ok := analysisutil.Unparen(e).(*ast.Ident)
+	if !ok {
+		return false
+	}
+	b, ok := pass.TypesInfo.Uses[id].(*types.Builtin)
+	return ok && b.Name() == name
+}
+
+// callsRecover returns whether body calls recover directly.
+func callsRecover(pass *analysis.Pass, body *ast.BlockStmt) bool {
+	found := false
+	ast.Inspect(body, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.FuncLit:
+			return false
+		case *ast.CallExpr:
+			if isBuiltin(pass, n.Fun, "recover") {
+				found = true
+			}
+		}
+		return !found
+	})
+	return found
+}
+
+// defersRecover returns whether body defers a function calling recover.
+func defersRecover(c *checker, body *ast.BlockStmt) bool {
+	for _, stmt := range body.List {
+		d, ok := stmt.(*ast.DeferStmt)
+		if !ok {
+			continue
+		}
+		if lit, ok := d.Call.Fun.(*ast.FuncLit); ok {
+			if callsRecover(c.pass, lit.Body) {
+				return true
+			}
+			continue
+		}
+		if fn := analysisutil.Callee(c.pass.TypesInfo, d.Call); fn != nil && c.fact(fn).Recovers {
+			return true
+		}
+	}
+	return false
+}
+
+// mayPanic returns the name of a function called in body which can panic,
+// or "" if there is none. Deferred calls and goroutines started by body are
+// not considered.
+func (c *checker) mayPanic(body *ast.BlockStmt) string {
+	var via string
+	ast.Inspect(body, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.GoStmt, *ast.DeferStmt:
+			return false
+		case *ast.CallExpr:
+			if isBuiltin(c.pass, n.Fun, "panic") {
+				via = "panic"
+			} else if fn := analysisutil.Callee(c.pass.TypesInfo, n); fn != nil {
+				via = c.funcPanics(fn)
+			}
+		}
+		return via == ""
+	})
+	return via
+}
+
+// funcPanics returns the name of fn, if it can panic, or "".
+func (c *checker) funcPanics(fn *types.Func) string {
+	name := analysisutil.FuncName(fn)
+	for _, p := range stdPanics {
+		if p == name {
+			return name
+		}
+	}
+	if c.fact(fn).Panics != "" {
+		return name
+	}
+	return ""
+}
+
+// fact returns what is known about fn.
+func (c *checker) fact(fn *types.Func) funcFact {
+	if fn.Pkg() == c.pass.Pkg {
+		return funcFact{c.panics[fn], c.recovers[fn]}
+	}
+	var f funcFact
+	c.pass.ImportObjectFact(fn, &f)
+	return f
+}
@@ -0,0 +1,74 @@
+package a
+
+import (
+	"b"
+	"bytes"
+	"errors"
+	"sync"
+)
+
+type job struct{}
+
+func process(j job) { // want process:"panics via b.Must"
+	b.Must(errors.New("x"))
+}
+
+func check(j job) { // want check:"panics via a.process"
+	process(j)
+}
+
+func safe(j job) {
+	defer func() {
+		recover()
+	}()
+	process(j)
+}
+
+func logPanic() { // want logPanic:"recovers"
+	if r := recover(); r != nil {
+		println(r)
+	}
+}
+
+func Workers(jobs []job) {
+	for _, j := range jobs {
+		go check(j) // want `goroutine can panic \(via a.check\) without recovering`
+		go safe(j)
+		go func(j job) { // want `goroutine can panic \(via b.Pattern\) without recovering`
+			b.Pattern("x")
+		}(j)
+		go func() {
+			defer logPanic()
+			process(j)
+		}()
+		go func() {
+			defer b.Recover()
+			process(j)
+		}()
+		go b.Safe()
+	}
+}
+
+func helper() {
+	go check(job{})
+}
+
+func Serve() {
+	go func() { // want `goroutine can panic \(via panic\) without recovering`
+		panic("x")
+	}()
+}
+
+// Functions of the standard library only panic if documented to.
+func Std(jobs []job) {
+	var wg sync.WaitGroup
+	for range jobs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var buf bytes.Buffer
+			buf.WriteString("x")
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,19 @@
+package b
+
+import "regexp"
+
+func Must(err error) { // want Must:"panics via panic"
+	if err != nil {
+		panic(err)
+	}
+}
+
+func Pattern(s string) *regexp.Regexp { // want Pattern:"panics via regexp.MustCompile"
+	return regexp.MustCompile(s)
+}
+
+func Safe() {}
+
+func Recover() { // want Recover:"recovers"
+	recover()
+}
@@ -0,0 +1,364 @@
+// Copyright 2019 Axel Wagner
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package grpchygiene defines an Analyzer that checks gRPC servers and
+// clients for common mistakes.
+package grpchygiene
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+	"strings"
+
+	"github.com/Merovius/go-tools/internal/analysisutil"
+	"github.com/Merovius/go-tools/internal/nodefilter"
+	"golang.org/x/tools/go/analysis"
+)
+
+const Doc = `check gRPC servers and clients for common mistakes
+
+The analyzer reports
+  - handlers returning errors created by fmt.Errorf or errors.New, which
+    clients receive with code Unknown; use status.Error or status.Errorf,
+  - unbounded loops in streaming handlers that neither receive from the
+    stream nor check its context, so they keep running after the client
+    went away, and
+  - connections created by grpc.Dial or grpc.DialContext that are never
+    closed.
+
+Handlers are the methods of types implementing a FooServer interface
+generated by protoc-gen-go, in the analyzed package or one it imports.`
+
+var Analyzer = &analysis.Analyzer{
+	Name: "grpchygiene",
+	Doc:  Doc,
+	Run:  run,
+	Requires: []*analysis.Analyzer{
+		nodefilter.Analyzer,
+	},
+}
+
+const grpcPkg = "google.golang.org/grpc"
+
+var (
+	funcs = nodefilter.New(false, new(ast.FuncDecl))
+	calls = nodefilter.New(true, new(ast.CallExpr))
+)
+
+func run(pass *analysis.Pass) (interface{}, error) {
+	ifaces := serverInterfaces(pass.Pkg)
+	if len(ifaces) == 0 && !analysisutil.Imports(pass.Pkg, grpcPkg) {
+		return nil, nil
+	}
+	nodes := pass.ResultOf[nodefilter.Analyzer].(*nodefilter.Result)
+	for _, n := range nodes.Nodes(funcs) {
+		decl := n.Node.(*ast.FuncDecl)
+		if decl.Body == nil || !isHandler(pass, decl, ifaces) {
+			continue
+		}
+		checkErrors(pass, decl)
+		if isStreaming(pass, decl) {
+			checkLoops(pass, decl)
+		}
+	}
+	for _, n := range nodes.Nodes(calls) {
+		checkDial(pass, n.Node.(*ast.CallExpr), n.Stack)
+	}
+	return nil, nil
+}
+
+// serverInterfaces returns the service interfaces generated for gRPC
+// servers in pkg and the packages it imports.
+func serverInterfaces(pkg *types.Package) []*types.Interface {
+	var ifaces []*types.Interface
+	for _, p := range append([]*types.Package{pkg}, pkg.Imports()...) {
+		if !analysisutil.Imports(p, grpcPkg) {
+			continue
+		}
+		scope := p.Scope()
+		for _, name := range scope.Names() {
+			if !strings.HasSuffix(name, "Server") {
+				continue
+			}
+			obj, ok := scope.Lookup(name).(*types.TypeName)
+			if !ok {
+				continue
+			}
+			if it, ok := obj.Type().Underlying().(*types.Interface); ok && it.NumMethods() > 0 {
+				ifaces = append(ifaces, it)
+			}
+		}
+	}
+	return ifaces
+}
+
+// isHandler returns whether decl declares a method of a service interface
+// implemented by its receiver.
+func isHandler(pass *analysis.Pass, decl *ast.FuncDecl, ifaces []*types.Interface) bool {
+	if decl.Recv == nil {
+		return false
+	}
+	fn, ok := pass.TypesInfo.Defs[decl.Name].(*types.Func)
+	if !ok {
+		return false
+	}
+	recv := fn.Type().(*types.Signature).Recv().Type()
+	if p, ok := recv.(*types.Pointer); ok {
+		recv = p.Elem()
+	}
+	for _, it := range ifaces {
+		if !types.Implements(recv, it) && !types.Implements(types.NewPointer(recv), it) {
+			continue
+		}
+		for i := 0; i < it.NumMethods(); i++ {
+			if it.Method(i).Name() == fn.Name() {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// inspect is like ast.Inspect, but doesn't descend into function literals.
+func inspect(n ast.Node, f func(ast.Node) bool) {
+	ast.Inspect(n, func(n ast.Node) bool {
+		if _, ok := n.(*ast.FuncLit); ok {
+			return false
+		}
+		return f(n)
+	})
+}
+
+func checkErrors(pass *analysis.Pass, decl *ast.FuncDecl) {
+	results := decl.Type.Results
+	if results == nil || len(results.List) == 0 {
+		return
+	}
+	last := pass.TypesInfo.TypeOf(results.List[len(results.List)-1].Type)
+	if last == nil || !types.Identical(last, types.Universe.Lookup("error").Type()) {
+		return
+	}
+	inspect(decl.Body, func(n ast.Node) bool {
+		ret, ok := n.(*ast.ReturnStmt)
+		if !ok || len(ret.Results) == 0 {
+			return true
+		}
+		call, ok := analysisutil.Unparen(ret.Results[len(ret.Results)-1]).(*ast.CallExpr)
+		if !ok {
+			return true
+		}
+		if name := analysisutil.CalleeName(pass.TypesInfo, call); name == "fmt.Errorf" || name == "errors.New" {
+			pass.Report(analysis.Diagnostic{
+				Pos:     call.Pos(),
+				End:     call.End(),
+				Message: "gRPC handler " + decl.Name.Name + " returns an error created by " + name + ", which clients receive with code Unknown; use status.Error or status.Errorf",
+			})
+		}
+		return true
+	})
+}
+
+// isStream returns whether t is a server stream, that is an interface with
+// the methods of grpc.ServerStream.
+func isStream(t types.Type) bool {
+	it, ok := t.Underlying().(*types.Interface)
+	if !ok {
+		return false
+	}
+	var found int
+	for i := 0; i < it.NumMethods(); i++ {
+		switch it.Method(i).Name() {
+		case "Context", "SendMsg", "RecvMsg", "SetHeader":
+			found++
+		}
+	}
+	return found == 4
+}
+
+func isStreaming(pass *analysis.Pass, decl *ast.FuncDecl) bool {
+	for _, f := range decl.Type.Params.List {
+		if t := pass.TypesInfo.TypeOf(f.Type); t != nil && isStream(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkLoops reports unbounded loops in the streaming handler decl which
+// don't observe the cancellation of the stream.
+func checkLoops(pass *analysis.Pass, decl *ast.FuncDecl) {
+	inspect(decl.Body, func(n ast.Node) bool {
+		var body *ast.BlockStmt
+		switch n := n.(type) {
+		case *ast.ForStmt:
+			if n.Cond != nil {
+				return true
+			}
+			body = n.Body
+		case *ast.RangeStmt:
+			if t := pass.TypesInfo.TypeOf(n.X); t == nil {
+				return true
+			} else if _, ok := t.Underlying().(*types.Chan); !ok {
+				return true
+			}
+			body = n.Body
+		default:
+			return true
+		}
+		if !observesCancel(pass, body) {
+			pass.Reportf(n.Pos(), "loop in streaming handler %s doesn't observe cancellation of the stream's context", decl.Name.Name)
+		}
+		return true
+	})
+}
+
+// observesCancel returns whether body receives from a stream, checks the
+// error of sending to it or uses a context.
+func observesCancel(pass *analysis.Pass, body *ast.BlockStmt) bool {
+	found := false
+	ast.Inspect(body, func(n ast.Node) bool {
+		if found {
+			return false
+		}
+		switch n := n.(type) {
+		case *ast.ExprStmt:
+			// The error of a call whose result is discarded can't be
+			// checked. Only look at its arguments.
+			if call, ok := n.X.(*ast.CallExpr); ok {
+				for _, a := range call.Args {
+					ast.Inspect(a, func(n ast.Node) bool {
+						found = found || isCancelCheck(pass, n)
+						return !found
+					})
+				}
+				return false
+			}
+		case ast.Expr:
+			found = isCancelCheck(pass, n)
+		}
+		return !found
+	})
+	return found
+}
+
+func isCancelCheck(pass *analysis.Pass, n ast.Node) bool {
+	e, ok := n.(ast.Expr)
+	if !ok {
+		return false
+	}
+	if t := pass.TypesInfo.TypeOf(e); t != nil && analysisutil.IsType(t, "context.Context") {
+		return true
+	}
+	call, ok := e.(*ast.CallExpr)
+	if !ok {
+		return false
+	}
+	sel, ok := call.Fun.(*ast.SelectorExpr)
+	if !ok {
+		return false
+	}
+	t := pass.TypesInfo.TypeOf(sel.X)
+	if t == nil || !isStream(t) {
+		return false
+	}
+	return strings.HasPrefix(sel.Sel.Name, "Recv") || strings.HasPrefix(sel.Sel.Name, "Send")
+}
+
+func checkDial(pass *analysis.Pass, call *ast.CallExpr, stack []ast.Node) {
+	name := analysisutil.CalleeName(pass.TypesInfo, call)
+	if name != grpcPkg+".Dial" && name != grpcPkg+".DialContext" {
+		return
+	}
+	var conn *ast.Ident
+	switch parent := stack[len(stack)-2].(type) {
+	case *ast.AssignStmt:
+		id, ok := parent.Lhs[0].(*ast.Ident)
+		if !ok || len(parent.Rhs) != 1 {
+			// Stored in a field or element.
+			return
+		}
+		conn = id
+	case *ast.ValueSpec:
+		if len(parent.Values) != 1 {
+			return
+		}
+		conn = parent.Names[0]
+	case *ast.ExprStmt:
+	default:
+		// The connection is returned, passed on or stored.
+		return
+	}
+	if conn != nil && conn.Name != "_" {
+		obj := pass.TypesInfo.ObjectOf(conn)
+		v, ok := obj.(*types.Var)
+		if !ok || v.Parent() == v.Pkg().Scope() {
+			return
+		}
+		_, body := analysisutil.EnclosingFunc(stack)
+		if body == nil || closedOrEscapes(pass, body, obj) {
+			return
+		}
+	}
+	pass.Report(analysis.Diagnostic{
+		Pos:      call.Pos(),
+		End:      call.End(),
+		Category: "close",
+		Message:  "connection created by grpc." + name[len(grpcPkg)+1:] + " is never closed",
+	})
+}
+
+// closedOrEscapes returns whether the connection conn is closed in body, or
+// used in a way that might close it elsewhere. Passing it to a NewFooClient
+// function generated by protoc-gen-go doesn't count, as clients can't close
+// their connection.
+func closedOrEscapes(pass *analysis.Pass, body *ast.BlockStmt, conn types.Object) bool {
+	handled := make(map[*ast.Ident]bool)
+	result := false
+	ast.Inspect(body, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.SelectorExpr:
+			if id, ok := n.X.(*ast.Ident); ok && pass.TypesInfo.Uses[id] == conn {
+				handled[id] = true
+				if n.Sel.Name == "Close" {
+					result = true
+				}
+			}
+		case *ast.CallExpr:
+			fn := analysisutil.Callee(pass.TypesInfo, n)
+			if fn == nil || !strings.HasPrefix(fn.Name(), "New") || !strings.HasSuffix(fn.Name(), "Client") {
+				break
+			}
+			for _, a := range n.Args {
+				if id, ok := a.(*ast.Ident); ok && pass.TypesInfo.Uses[id] == conn {
+					handled[id] = true
+				}
+			}
+		case *ast.AssignStmt:
+			if n.Tok == token.ASSIGN {
+				for _, l := range n.Lhs {
+					if id, ok := l.(*ast.Ident); ok {
+						handled[id] = true
+					}
+				}
+			}
+		case *ast.Ident:
+			if pass.TypesInfo.Uses[n] == conn && !handled[n] {
+				result = true
+			}
+		}
+		return !result
+	})
+	return result
+}
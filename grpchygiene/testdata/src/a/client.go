@@ -0,0 +1,50 @@
+package a
+
+import (
+	"context"
+
+	"a/pb"
+
+	"google.golang.org/grpc"
+)
+
+func leak() {
+	conn, err := grpc.Dial("localhost:1234") // want `connection created by grpc.Dial is never closed`
+	if err != nil {
+		return
+	}
+	c := pb.NewGreeterClient(conn)
+	c.SayHello(context.Background(), &pb.Request{})
+}
+
+func discard(ctx context.Context) {
+	grpc.DialContext(ctx, "localhost:1234") // want `connection created by grpc.DialContext is never closed`
+	_, _ = grpc.Dial("localhost:1234")      // want `connection created by grpc.Dial is never closed`
+}
+
+func closed() error {
+	conn, err := grpc.Dial("localhost:1234")
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	_, err = pb.NewGreeterClient(conn).SayHello(context.Background(), &pb.Request{})
+	return err
+}
+
+func returned() (*grpc.ClientConn, error) {
+	conn, err := grpc.Dial("localhost:1234")
+	if err != nil {
+		return nil, err
+	}
+	return conn, nil
+}
+
+type holder struct{ conn *grpc.ClientConn }
+
+func stored(h *holder) (err error) {
+	h.conn, err = grpc.Dial("localhost:1234")
+	return err
+}
+
+var global, globalErr = grpc.Dial("localhost:1234")
@@ -0,0 +1,72 @@
+package a
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"a/pb"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type server struct {
+	updates chan *pb.Reply
+}
+
+func (s *server) SayHello(ctx context.Context, req *pb.Request) (*pb.Reply, error) {
+	if req.Name == "" {
+		return nil, errors.New("empty name") // want `gRPC handler SayHello returns an error created by errors.New, which clients receive with code Unknown; use status.Error or status.Errorf`
+	}
+	if req.Name == "nobody" {
+		return nil, status.Errorf(codes.NotFound, "no such user %q", req.Name)
+	}
+	if err := s.lookup(req.Name); err != nil {
+		return nil, err
+	}
+	return &pb.Reply{Message: "Hello " + req.Name}, nil
+}
+
+// lookup isn't a handler.
+func (s *server) lookup(name string) error {
+	return fmt.Errorf("no user %q", name)
+}
+
+func (s *server) Watch(req *pb.Request, stream pb.Greeter_WatchServer) error {
+	for { // want `loop in streaming handler Watch doesn't observe cancellation of the stream's context`
+		stream.Send(&pb.Reply{})
+		time.Sleep(time.Second)
+	}
+	for {
+		if err := stream.Send(&pb.Reply{}); err != nil {
+			return err
+		}
+	}
+	for r := range s.updates { // want `loop in streaming handler Watch doesn't observe cancellation`
+		_ = r
+	}
+	for {
+		select {
+		case r := <-s.updates:
+			_ = r
+		case <-stream.Context().Done():
+			return fmt.Errorf("watch: %v", stream.Context().Err()) // want `gRPC handler Watch returns an error created by fmt.Errorf`
+		}
+	}
+}
+
+func (s *server) Chat(stream pb.Greeter_ChatServer) error {
+	for {
+		req, err := stream.Recv()
+		if err != nil {
+			return err
+		}
+		_ = req
+	}
+	for i := 0; i < 10; i++ {
+		time.Sleep(time.Second)
+	}
+	return nil
+}
@@ -0,0 +1,24 @@
+package grpc
+
+import "context"
+
+type ClientConn struct{}
+
+func (cc *ClientConn) Close() error { return nil }
+
+type DialOption interface{}
+
+func Dial(target string, opts ...DialOption) (*ClientConn, error) { return nil, nil }
+
+func DialContext(ctx context.Context, target string, opts ...DialOption) (*ClientConn, error) {
+	return nil, nil
+}
+
+type Server struct{}
+
+type ServerStream interface {
+	SetHeader(map[string][]string) error
+	Context() context.Context
+	SendMsg(m interface{}) error
+	RecvMsg(m interface{}) error
+}
@@ -0,0 +1,7 @@
+package status
+
+import "google.golang.org/grpc/codes"
+
+func Error(c codes.Code, msg string) error { return nil }
+
+func Errorf(c codes.Code, format string, a ...interface{}) error { return nil }
@@ -0,0 +1,209 @@
+// Copyright 2019 Axel Wagner
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package heapescape defines an Analyzer that checks for code moving values
+// to the heap unnecessarily.
+package heapescape
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+
+	"github.com/Merovius/go-tools/internal/analysisutil"
+	"github.com/Merovius/go-tools/internal/nodefilter"
+	"github.com/Merovius/go-tools/internal/ssaflow"
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/passes/buildssa"
+	"golang.org/x/tools/go/ssa"
+)
+
+const Doc = `check for code moving values to the heap unnecessarily
+
+Values whose address outlives the function creating them are allocated on
+the heap, which is slower than the stack and adds work for the garbage
+collector. The analyzer reports
+  - pointers to large local variables and composite literals returned from
+    functions, which move them to the heap. Letting callers provide the
+    storage allows them to reuse it,
+  - integers converted to interfaces in loops, with the -boxing flag.
+    Unless they are constants or a single byte, every conversion allocates.
+    As formatting and logging in loops is common and rarely the bottleneck,
+    these are only reported on request, and
+  - closures capturing large structs, of which they only use a single field.
+    Capturing the variable moves the whole struct to the heap, copying the
+    field to a local variable only moves the field.
+
+Values are large if they have at least as many bytes as given by the -size
+flag. The findings only matter in hot code; run with a CPU profile (the
+-profile flag of gotools) to only report those.`
+
+var Analyzer = &analysis.Analyzer{
+	Name: "heapescape",
+	Doc:  Doc,
+	Run:  run,
+	Requires: []*analysis.Analyzer{
+		buildssa.Analyzer,
+		nodefilter.Analyzer,
+	},
+}
+
+var loops = nodefilter.New(false, new(ast.ForStmt), new(ast.RangeStmt))
+
+var (
+	size   int64 = 256
+	boxing bool
+)
+
+func init() {
+	Analyzer.Flags.Int64Var(&size, "size", size, "minimum size in bytes of reported returned variables and captured structs")
+	Analyzer.Flags.BoolVar(&boxing, "boxing", false, "report integers converted to interfaces in loops")
+}
+
+func run(pass *analysis.Pass) (interface{}, error) {
+	ssainfo := pass.ResultOf[buildssa.Analyzer].(*buildssa.SSA)
+	nodes := pass.ResultOf[nodefilter.Analyzer].(*nodefilter.Result)
+	var ranges []ast.Node
+	for _, n := range nodes.Nodes(loops) {
+		ranges = append(ranges, n.Node)
+	}
+	for _, fn := range ssainfo.SrcFuncs {
+		// Finding the blocks in loops needs a search through the graph,
+		// skip it for functions without loops in their syntax.
+		hasLoop := false
+		if syn := fn.Syntax(); syn != nil {
+			for _, r := range ranges {
+				if syn.Pos() <= r.Pos() && r.End() <= syn.End() {
+					hasLoop = true
+					break
+				}
+			}
+		}
+		for _, b := range fn.Blocks {
+			loop := boxing && hasLoop && ssaflow.InLoop(b)
+			for _, instr := range b.Instrs {
+				switch instr := instr.(type) {
+				case *ssa.Return:
+					checkReturn(pass, instr)
+				case *ssa.MakeInterface:
+					if loop {
+						checkBoxing(pass, instr)
+					}
+				case *ssa.MakeClosure:
+					checkClosure(pass, instr)
+				}
+			}
+		}
+	}
+	return nil, nil
+}
+
+// sizeof returns the size of t, or -1 if it depends on type parameters.
+func sizeof(pass *analysis.Pass, t types.Type) int64 {
+	if analysisutil.HasTypeParam(t) {
+		return -1
+	}
+	return pass.TypesSizes.Sizeof(t)
+}
+
+func checkReturn(pass *analysis.Pass, ret *ssa.Return) {
+	for _, r := range ret.Results {
+		alloc, ok := r.(*ssa.Alloc)
+		if !ok || !alloc.Heap || alloc.Pos() == token.NoPos {
+			continue
+		}
+		elem := alloc.Type().(*types.Pointer).Elem()
+		n := sizeof(pass, elem)
+		if n < size {
+			continue
+		}
+		what := "composite literal"
+		if alloc.Comment != "complit" {
+			what = "local variable " + alloc.Comment
+		}
+		pass.Reportf(alloc.Pos(), "returning a pointer to the %s moves its %d bytes to the heap; let the caller provide the storage", what, n)
+	}
+}
+
+func checkBoxing(pass *analysis.Pass, mi *ssa.MakeInterface) {
+	if _, ok := mi.X.(*ssa.Const); ok {
+		return
+	}
+	b, ok := mi.X.Type().Underlying().(*types.Basic)
+	if !ok || b.Info()&types.IsInteger == 0 || sizeof(pass, b) <= 1 {
+		return
+	}
+	pos := mi.Pos()
+	if pos == token.NoPos {
+		// Implicit conversions have no position, report the instruction
+		// using the interface instead.
+		for _, ref := range *mi.Referrers() {
+			if pos = ref.Pos(); pos != token.NoPos {
+				break
+			}
+		}
+	}
+	if pos == token.NoPos {
+		return
+	}
+	pass.Report(analysis.Diagnostic{
+		Pos:      pos,
+		Category: "boxing",
+		Message:  fmt.Sprintf("conversion of %s to %s in a loop allocates; avoid the interface or convert once outside the loop", types.TypeString(mi.X.Type(), types.RelativeTo(pass.Pkg)), types.TypeString(mi.Type(), types.RelativeTo(pass.Pkg))),
+	})
+}
+
+func checkClosure(pass *analysis.Pass, mc *ssa.MakeClosure) {
+	fn, ok := mc.Fn.(*ssa.Function)
+	if !ok {
+		return
+	}
+	for i, binding := range mc.Bindings {
+		alloc, ok := binding.(*ssa.Alloc)
+		if !ok || i >= len(fn.FreeVars) {
+			continue
+		}
+		elem := alloc.Type().(*types.Pointer).Elem()
+		st, ok := elem.Underlying().(*types.Struct)
+		if !ok || sizeof(pass, elem) < size {
+			continue
+		}
+		field, ok := singleField(fn.FreeVars[i])
+		if !ok {
+			continue
+		}
+		name := fn.FreeVars[i].Name()
+		pass.Report(analysis.Diagnostic{
+			Pos:      fn.Pos(),
+			Category: "closure",
+			Message: fmt.Sprintf("closure captures %s, moving its %d bytes to the heap, but only uses %s.%s; copy the field to a local variable outside the closure",
+				name, sizeof(pass, elem), name, st.Field(field).Name()),
+		})
+	}
+}
+
+// singleField returns the index of the field of the captured struct fv,
+// if it is the only one the closure uses.
+func singleField(fv *ssa.FreeVar) (int, bool) {
+	field := -1
+	for _, ref := range *fv.Referrers() {
+		fa, ok := ref.(*ssa.FieldAddr)
+		if !ok || field >= 0 && fa.Field != field {
+			return 0, false
+		}
+		field = fa.Field
+	}
+	return field, field >= 0
+}
@@ -0,0 +1,28 @@
+// Copyright 2019 Axel Wagner
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package heapescape
+
+import (
+	"testing"
+
+	"github.com/Merovius/go-tools/internal/analysistestx"
+)
+
+func TestHeapEscape(t *testing.T) {
+	analysistestx.RunMatrix(t, analysistestx.TestData(), Analyzer,
+		analysistestx.Case{Name: "default", Patterns: []string{"a"}},
+		analysistestx.Case{Name: "boxing", Flags: map[string]string{"boxing": "true"}, Patterns: []string{"a", "b"}},
+	)
+}
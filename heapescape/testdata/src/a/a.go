@@ -0,0 +1,84 @@
+package a
+
+import "fmt"
+
+type Big struct {
+	buf  [512]byte
+	name string
+}
+
+type Small struct {
+	a, b int
+}
+
+func NewBig() *Big {
+	var b Big // want `returning a pointer to the local variable b moves its 528 bytes to the heap`
+	b.name = "x"
+	return &b
+}
+
+func NewBigLit() *Big {
+	return &Big{name: "x"} // want `returning a pointer to the composite literal moves its 528 bytes to the heap`
+}
+
+func NewSmall() *Small {
+	return &Small{1, 2}
+}
+
+func fill(b *Big) {
+	b.name = "x"
+}
+
+func Stack() string {
+	var b Big
+	fill(&b)
+	return b.name
+}
+
+func Field() func() string {
+	var b Big
+	fill(&b)
+	return func() string { // want `closure captures b, moving its 528 bytes to the heap, but only uses b.name`
+		return b.name
+	}
+}
+
+func Fields() func() string {
+	var b Big
+	fill(&b)
+	return func() string {
+		return b.name + string(b.buf[:])
+	}
+}
+
+func SmallField() func() int {
+	var s Small
+	return func() int {
+		return s.a
+	}
+}
+
+type Pair[T any] struct {
+	a, b T
+	buf  [512]byte
+}
+
+func NewPair[T any](a, b T) *Pair[T] {
+	p := Pair[T]{a: a, b: b}
+	return &p
+}
+
+func NewValue[T any](v T) *T {
+	return &v
+}
+
+func PairField[T any]() func() T {
+	var p Pair[T]
+	return func() T {
+		return p.a
+	}
+}
+
+func NewBigFor[T any](v T) *Big {
+	return &Big{name: fmt.Sprint(v)} // want `returning a pointer to the composite literal moves its 528 bytes to the heap`
+}
@@ -0,0 +1,26 @@
+package b
+
+import "fmt"
+
+func Print(xs []int) {
+	for _, x := range xs {
+		fmt.Println(x) // want `conversion of int to .* in a loop allocates`
+	}
+	for i := 0; i < 3; i++ {
+		fmt.Println(42)
+	}
+	var bs []byte
+	for _, b := range bs {
+		fmt.Println(b)
+	}
+}
+
+func Once(x int) {
+	fmt.Println(x)
+}
+
+func PrintAll[T any](xs []T) {
+	for _, x := range xs {
+		fmt.Println(x)
+	}
+}
@@ -0,0 +1,195 @@
+// Copyright 2019 Axel Wagner
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package hotloop defines an Analyzer that checks for formatting, logging
+// and allocations in nested loops.
+package hotloop
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+	"strconv"
+
+	"github.com/Merovius/go-tools/internal/analysisutil"
+	"github.com/Merovius/go-tools/internal/nodefilter"
+	"golang.org/x/tools/go/analysis"
+)
+
+const Doc = `check for formatting, logging and allocations in nested loops
+
+The body of an inner loop runs for every iteration of all loops enclosing
+it. The analyzer reports calls formatting strings with package fmt and
+logging with package log, and allocations of maps and slices, in loops
+nested at least as deep as given by the -depth flag. Allocations which
+don't depend on the loop can usually be hoisted out of it and reused.
+
+Code on error paths is not reported: blocks ending in a return, break or
+panic, and loops with an unlabeled break, which usually stop early.`
+
+var Analyzer = &analysis.Analyzer{
+	Name: "hotloop",
+	Doc:  Doc,
+	Run:  run,
+	Requires: []*analysis.Analyzer{
+		nodefilter.Analyzer,
+	},
+}
+
+var depth = 2
+
+func init() {
+	Analyzer.Flags.IntVar(&depth, "depth", depth, "loop nesting depth from which code is reported")
+}
+
+var nodes = nodefilter.New(true, new(ast.CallExpr), new(ast.CompositeLit))
+
+// formatting are the functions formatting or logging, which are reported.
+var formatting = []string{
+	"fmt.Sprintf", "fmt.Sprint", "fmt.Sprintln", "fmt.Errorf",
+	"fmt.Printf", "fmt.Print", "fmt.Println",
+	"fmt.Fprintf", "fmt.Fprint", "fmt.Fprintln",
+	"log.Printf", "log.Print", "log.Println",
+	"(*log.Logger).Printf", "(*log.Logger).Print", "(*log.Logger).Println",
+}
+
+func run(pass *analysis.Pass) (interface{}, error) {
+	if depth < 1 {
+		return nil, nil
+	}
+	res := pass.ResultOf[nodefilter.Analyzer].(*nodefilter.Result)
+	for _, n := range res.Nodes(nodes) {
+		var what, category string
+		switch x := n.Node.(type) {
+		case *ast.CallExpr:
+			switch {
+			case analysisutil.IsCall(pass.TypesInfo, x, formatting...):
+				what = analysisutil.CalleeName(pass.TypesInfo, x)
+			case isMake(pass, x):
+				what, category = "allocation of a "+kind(pass.TypesInfo.TypeOf(x)), "alloc"
+			}
+		case *ast.CompositeLit:
+			if k := kind(pass.TypesInfo.TypeOf(x)); k != "" && len(x.Elts) > 0 {
+				what, category = "allocation of a "+k, "alloc"
+			}
+		}
+		if what == "" {
+			continue
+		}
+		if d := loopDepth(n.Stack); d >= depth {
+			pass.Report(analysis.Diagnostic{
+				Pos:      n.Node.Pos(),
+				End:      n.Node.End(),
+				Category: category,
+				Message:  what + " in a loop nested " + strconv.Itoa(d) + " deep; move it out of the inner loop or reuse the result",
+			})
+		}
+	}
+	return nil, nil
+}
+
+func isMake(pass *analysis.Pass, call *ast.CallExpr) bool {
+	id, ok := analysisutil.Unparen(call.Fun).(*ast.Ident)
+	if !ok {
+		return false
+	}
+	b, ok := pass.TypesInfo.Uses[id].(*types.Builtin)
+	return ok && b.Name() == "make" && kind(pass.TypesInfo.TypeOf(call)) != ""
+}
+
+// kind returns "map" or "slice", if t is one.
+func kind(t types.Type) string {
+	if t == nil {
+		return ""
+	}
+	switch t.Underlying().(type) {
+	case *types.Map:
+		return "map"
+	case *types.Slice:
+		return "slice"
+	}
+	return ""
+}
+
+// loopDepth returns the number of loops in the innermost function
+// enclosing the last node of stack. It returns 0 if the node is on an
+// error path or in a loop which might stop early.
+func loopDepth(stack []ast.Node) int {
+	d := 0
+	for i := len(stack) - 2; i >= 0; i-- {
+		switch n := stack[i].(type) {
+		case *ast.FuncDecl, *ast.FuncLit:
+			return d
+		case *ast.ForStmt:
+			if breaks(n.Body) {
+				return 0
+			}
+			d++
+		case *ast.RangeStmt:
+			if breaks(n.Body) {
+				return 0
+			}
+			d++
+		case *ast.BlockStmt:
+			if d == 0 && terminates(n) {
+				return 0
+			}
+		case *ast.CaseClause:
+			if d == 0 && terminates(&ast.BlockStmt{List: n.Body}) {
+				return 0
+			}
+		}
+	}
+	return d
+}
+
+// breaks returns whether body contains an unlabeled break of the loop it
+// is the body of.
+func breaks(body *ast.BlockStmt) bool {
+	found := false
+	ast.Inspect(body, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.ForStmt, *ast.RangeStmt, *ast.SwitchStmt, *ast.TypeSwitchStmt, *ast.SelectStmt, *ast.FuncLit:
+			// Unlabeled breaks in these refer to them.
+			return false
+		case *ast.BranchStmt:
+			if n.Tok == token.BREAK && n.Label == nil {
+				found = true
+			}
+		}
+		return !found
+	})
+	return found
+}
+
+// terminates returns whether the block ends in a return, break or panic.
+func terminates(b *ast.BlockStmt) bool {
+	if len(b.List) == 0 {
+		return false
+	}
+	switch s := b.List[len(b.List)-1].(type) {
+	case *ast.ReturnStmt:
+		return true
+	case *ast.BranchStmt:
+		return s.Tok == token.BREAK || s.Tok == token.GOTO
+	case *ast.ExprStmt:
+		call, ok := s.X.(*ast.CallExpr)
+		if !ok {
+			return false
+		}
+		id, ok := call.Fun.(*ast.Ident)
+		return ok && id.Name == "panic"
+	}
+	return false
+}
@@ -0,0 +1,56 @@
+package a
+
+import (
+	"fmt"
+	"log"
+)
+
+type cell struct{ x, y int }
+
+func grid(rows, cols [][]int, logger *log.Logger) error {
+	for i, row := range rows {
+		fmt.Println(i)
+		seen := make(map[int]bool)
+		for j, v := range row {
+			key := fmt.Sprintf("%d/%d", i, j) // want `fmt.Sprintf in a loop nested 2 deep; move it out of the inner loop or reuse the result`
+			buf := make([]byte, 0, 16)        // want `allocation of a slice in a loop nested 2 deep`
+			m := map[string]int{key: v}       // want `allocation of a map in a loop nested 2 deep`
+			logger.Printf("%v %v", buf, m)    // want `\(\*log.Logger\).Printf in a loop nested 2 deep`
+			seen[v] = true
+			if v < 0 {
+				return fmt.Errorf("negative value at %d/%d", i, j)
+			}
+			var empty []int
+			_ = cell{i, j}
+			_ = append(empty, v)
+			go func() {
+				log.Print(v)
+			}()
+		}
+	}
+	return nil
+}
+
+func search(rows [][]int, target int) {
+	for _, row := range rows {
+		for _, v := range row {
+			log.Printf("checking %d", v)
+			if v == target {
+				break
+			}
+		}
+	}
+}
+
+func switched(rows [][]int) {
+	for _, row := range rows {
+		for _, v := range row {
+			switch v {
+			case 0:
+				break
+			default:
+				log.Println(v) // want `log.Println in a loop nested 2 deep`
+			}
+		}
+	}
+}
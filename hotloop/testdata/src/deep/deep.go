@@ -0,0 +1,14 @@
+package deep
+
+import "fmt"
+
+func cube(n int) {
+	for i := 0; i < n; i++ {
+		for j := 0; j < n; j++ {
+			_ = fmt.Sprint(i, j)
+			for k := 0; k < n; k++ {
+				_ = fmt.Sprint(i, j, k) // want `fmt.Sprint in a loop nested 3 deep`
+			}
+		}
+	}
+}
@@ -0,0 +1,358 @@
+// Copyright 2019 Axel Wagner
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package httphandler defines an Analyzer that checks net/http handlers for
+// common mistakes.
+package httphandler
+
+import (
+	"go/ast"
+	"go/constant"
+	"go/types"
+
+	"github.com/Merovius/go-tools/internal/analysisutil"
+	"github.com/Merovius/go-tools/internal/nodefilter"
+	"golang.org/x/tools/go/analysis"
+)
+
+const Doc = `check net/http handlers for common mistakes
+
+The analyzer checks functions with the signature of an http.HandlerFunc and
+reports
+  - calls of http.Error which are followed by more writes to the response,
+    because the handler doesn't return after the call,
+  - the same for calls of WriteHeader with an error status,
+  - reading the request body until EOF without limiting its size with
+    http.MaxBytesReader, which lets clients exhaust the memory of the server,
+    and
+  - storing the request in a variable, field or channel, or using it in a
+    goroutine, which might outlive the handler. The server reuses the request
+    after the handler returned.`
+
+var Analyzer = &analysis.Analyzer{
+	Name: "httphandler",
+	Doc:  Doc,
+	Run:  run,
+	Requires: []*analysis.Analyzer{
+		nodefilter.Analyzer,
+	},
+}
+
+var funcs = nodefilter.New(false, new(ast.FuncDecl), new(ast.FuncLit))
+
+// A handler is a function with the signature of an http.HandlerFunc.
+type handler struct {
+	w, r types.Object
+	body *ast.BlockStmt
+}
+
+func asHandler(info *types.Info, typ *ast.FuncType, body *ast.BlockStmt) (handler, bool) {
+	if body == nil || typ.Params == nil {
+		return handler{}, false
+	}
+	var params []*ast.Ident
+	for _, f := range typ.Params.List {
+		params = append(params, f.Names...)
+	}
+	if len(params) != 2 {
+		return handler{}, false
+	}
+	w, r := info.Defs[params[0]], info.Defs[params[1]]
+	if w == nil || r == nil || !analysisutil.IsType(w.Type(), "net/http.ResponseWriter") || !analysisutil.IsPointerTo(r.Type(), "net/http.Request") {
+		return handler{}, false
+	}
+	return handler{w: w, r: r, body: body}, true
+}
+
+func run(pass *analysis.Pass) (interface{}, error) {
+	if !analysisutil.Imports(pass.Pkg, "net/http") {
+		return nil, nil
+	}
+	nodes := pass.ResultOf[nodefilter.Analyzer].(*nodefilter.Result)
+	for _, n := range nodes.Nodes(funcs) {
+		var h handler
+		var ok bool
+		switch n := n.Node.(type) {
+		case *ast.FuncDecl:
+			h, ok = asHandler(pass.TypesInfo, n.Type, n.Body)
+		case *ast.FuncLit:
+			h, ok = asHandler(pass.TypesInfo, n.Type, n.Body)
+		}
+		if !ok {
+			continue
+		}
+		checkReturns(pass, h, h.body.List, nil)
+		checkBody(pass, h)
+		checkStore(pass, h)
+	}
+	return nil, nil
+}
+
+// checkReturns reports calls in stmts writing an error response, that are
+// followed by more writes. after lists the statements following stmts in
+// enclosing blocks, from the outermost to the innermost.
+func checkReturns(pass *analysis.Pass, h handler, stmts []ast.Stmt, after [][]ast.Stmt) {
+	for i, s := range stmts {
+		rest := append(after[:len(after):len(after)], stmts[i+1:])
+		switch s := s.(type) {
+		case *ast.ExprStmt:
+			call, ok := s.X.(*ast.CallExpr)
+			if !ok {
+				continue
+			}
+			if msg, category := errorResponse(pass, h, call); msg != "" && writesLater(pass, h, rest) {
+				pass.Report(analysis.Diagnostic{
+					Pos:      call.Pos(),
+					End:      call.End(),
+					Category: category,
+					Message:  msg,
+				})
+			}
+		case *ast.BlockStmt:
+			checkReturns(pass, h, s.List, rest)
+		case *ast.LabeledStmt:
+			checkReturns(pass, h, []ast.Stmt{s.Stmt}, rest)
+		case *ast.IfStmt:
+			checkReturns(pass, h, s.Body.List, rest)
+			if s.Else != nil {
+				checkReturns(pass, h, []ast.Stmt{s.Else}, rest)
+			}
+		case *ast.SwitchStmt:
+			checkClauses(pass, h, s.Body, rest)
+		case *ast.TypeSwitchStmt:
+			checkClauses(pass, h, s.Body, rest)
+		case *ast.SelectStmt:
+			checkClauses(pass, h, s.Body, rest)
+		case *ast.ForStmt:
+			// The statements after the loop might not be reached
+			// directly, so only look at the loop body.
+			checkReturns(pass, h, s.Body.List, nil)
+		case *ast.RangeStmt:
+			checkReturns(pass, h, s.Body.List, nil)
+		}
+	}
+}
+
+func checkClauses(pass *analysis.Pass, h handler, body *ast.BlockStmt, after [][]ast.Stmt) {
+	for _, c := range body.List {
+		switch c := c.(type) {
+		case *ast.CaseClause:
+			checkReturns(pass, h, c.Body, after)
+		case *ast.CommClause:
+			checkReturns(pass, h, c.Body, after)
+		}
+	}
+}
+
+// errorResponse returns a message and category if call writes an error
+// response to the handler's ResponseWriter.
+func errorResponse(pass *analysis.Pass, h handler, call *ast.CallExpr) (msg, category string) {
+	if analysisutil.IsCall(pass.TypesInfo, call, "net/http.Error") {
+		if len(call.Args) == 3 && analysisutil.ObjectOf(pass.TypesInfo, call.Args[0]) == h.w {
+			return "http.Error doesn't stop the handler, which writes to the response afterwards; add a return", ""
+		}
+		return "", ""
+	}
+	sel, ok := call.Fun.(*ast.SelectorExpr)
+	if !ok || sel.Sel.Name != "WriteHeader" || len(call.Args) != 1 || analysisutil.ObjectOf(pass.TypesInfo, sel.X) != h.w {
+		return "", ""
+	}
+	tv := pass.TypesInfo.Types[call.Args[0]]
+	if tv.Value == nil || tv.Value.Kind() != constant.Int {
+		return "", ""
+	}
+	if code, ok := constant.Int64Val(tv.Value); ok && code >= 400 {
+		return "WriteHeader with an error status doesn't stop the handler, which writes to the response afterwards; add a return", "writeheader"
+	}
+	return "", ""
+}
+
+// writesLater returns whether the statements in after, from the innermost
+// block outwards, use the ResponseWriter before returning.
+func writesLater(pass *analysis.Pass, h handler, after [][]ast.Stmt) bool {
+	for i := len(after) - 1; i >= 0; i-- {
+		for _, s := range after[i] {
+			switch s := s.(type) {
+			case *ast.ReturnStmt:
+				return false
+			case *ast.BranchStmt:
+				// Control flow continues elsewhere.
+				return false
+			case *ast.ExprStmt:
+				if call, ok := s.X.(*ast.CallExpr); ok && analysisutil.IsCall(pass.TypesInfo, call, "os.Exit", "log.Fatal", "log.Fatalf", "log.Panic", "log.Panicf") {
+					return false
+				}
+				if call, ok := s.X.(*ast.CallExpr); ok {
+					if id, ok := call.Fun.(*ast.Ident); ok && id.Name == "panic" {
+						return false
+					}
+				}
+			}
+			if uses(pass.TypesInfo, s, h.w) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// uses returns whether obj is used in n, outside of function literals.
+func uses(info *types.Info, n ast.Node, obj types.Object) bool {
+	found := false
+	ast.Inspect(n, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.FuncLit:
+			return false
+		case *ast.Ident:
+			if info.Uses[n] == obj {
+				found = true
+			}
+		}
+		return !found
+	})
+	return found
+}
+
+// unboundedReads lists functions reading from their argument until EOF.
+var unboundedReads = []string{
+	"io/ioutil.ReadAll",
+	"io.ReadAll",
+	"io.Copy",
+	"encoding/json.NewDecoder",
+	"encoding/xml.NewDecoder",
+	"encoding/gob.NewDecoder",
+}
+
+// checkBody reports reads of the request body until EOF, unless the body is
+// limited by http.MaxBytesReader in the handler.
+func checkBody(pass *analysis.Pass, h handler) {
+	limited := false
+	var reads []*ast.CallExpr
+	ast.Inspect(h.body, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.AssignStmt:
+			for _, l := range n.Lhs {
+				if isBody(pass.TypesInfo, h, l) {
+					limited = true
+				}
+			}
+		case *ast.CallExpr:
+			if !analysisutil.IsCall(pass.TypesInfo, n, unboundedReads...) {
+				break
+			}
+			for _, a := range n.Args {
+				if isBody(pass.TypesInfo, h, a) {
+					reads = append(reads, n)
+				}
+			}
+		}
+		return true
+	})
+	if limited {
+		return
+	}
+	for _, call := range reads {
+		pass.Report(analysis.Diagnostic{
+			Pos:      call.Pos(),
+			End:      call.End(),
+			Category: "body",
+			Message:  "request body is read without limiting its size; wrap it with http.MaxBytesReader",
+		})
+	}
+}
+
+// isBody returns whether e is the body of the handler's request.
+func isBody(info *types.Info, h handler, e ast.Expr) bool {
+	sel, ok := analysisutil.Unparen(e).(*ast.SelectorExpr)
+	return ok && sel.Sel.Name == "Body" && analysisutil.ObjectOf(info, sel.X) == h.r
+}
+
+// checkStore reports places where the request is stored beyond the
+// lifetime of the handler.
+func checkStore(pass *analysis.Pass, h handler) {
+	report := func(n ast.Node, what string) {
+		pass.Report(analysis.Diagnostic{
+			Pos:      n.Pos(),
+			End:      n.End(),
+			Category: "store",
+			Message:  "request is " + what + ", which might outlive the handler; copy the values needed instead",
+		})
+	}
+	isRequest := func(e ast.Expr) bool {
+		return analysisutil.ObjectOf(pass.TypesInfo, e) == h.r
+	}
+	ast.Inspect(h.body, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.FuncLit:
+			return false
+		case *ast.AssignStmt:
+			if len(n.Lhs) != len(n.Rhs) {
+				break
+			}
+			for i, r := range n.Rhs {
+				if !isRequest(r) {
+					continue
+				}
+				if escapes(pass.TypesInfo, n.Lhs[i]) {
+					report(n, "stored")
+				}
+			}
+		case *ast.SendStmt:
+			if isRequest(n.Value) {
+				report(n, "sent on a channel")
+			}
+		case *ast.GoStmt:
+			// Arguments are evaluated before the goroutine starts, so
+			// only passing the request itself is a problem.
+			used := usesInLit(pass.TypesInfo, n.Call.Fun, h.r)
+			for _, a := range n.Call.Args {
+				used = used || isRequest(a)
+			}
+			if used {
+				report(n, "used in a goroutine")
+			}
+			return false
+		}
+		return true
+	})
+}
+
+// escapes returns whether assigning to l stores the value outside of the
+// local variables of the function.
+func escapes(info *types.Info, l ast.Expr) bool {
+	switch l := analysisutil.Unparen(l).(type) {
+	case *ast.Ident:
+		v, ok := info.ObjectOf(l).(*types.Var)
+		return ok && v.Pkg() != nil && v.Parent() == v.Pkg().Scope()
+	case *ast.SelectorExpr, *ast.IndexExpr, *ast.StarExpr:
+		return true
+	}
+	return false
+}
+
+// usesInLit returns whether obj is used in the function literal e.
+func usesInLit(info *types.Info, e ast.Expr, obj types.Object) bool {
+	lit, ok := analysisutil.Unparen(e).(*ast.FuncLit)
+	if !ok {
+		return false
+	}
+	found := false
+	ast.Inspect(lit.Body, func(n ast.Node) bool {
+		if id, ok := n.(*ast.Ident); ok && info.Uses[id] == obj {
+			found = true
+		}
+		return !found
+	})
+	return found
+}
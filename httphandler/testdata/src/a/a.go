@@ -0,0 +1,93 @@
+package a
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+)
+
+func missingReturn(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "bad method", http.StatusMethodNotAllowed) // want `http.Error doesn't stop the handler, which writes to the response afterwards; add a return`
+	}
+	fmt.Fprintln(w, "hello")
+}
+
+func withReturn(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "bad method", http.StatusMethodNotAllowed)
+		return
+	}
+	fmt.Fprintln(w, "hello")
+}
+
+func lastStatement(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "bad method", http.StatusMethodNotAllowed)
+		log.Printf("bad method %s", r.Method)
+	} else {
+		fmt.Fprintln(w, "hello")
+	}
+}
+
+func writeHeader(w http.ResponseWriter, r *http.Request) {
+	var v struct{ N int }
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&v); err != nil {
+		w.WriteHeader(http.StatusBadRequest) // want `WriteHeader with an error status doesn't stop the handler`
+	}
+	switch v.N {
+	case 0:
+		w.WriteHeader(500) // want `WriteHeader with an error status doesn't stop the handler`
+	case 1:
+		w.WriteHeader(http.StatusCreated)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	w.Write([]byte("ok"))
+}
+
+func readBody(w http.ResponseWriter, r *http.Request) {
+	b, err := ioutil.ReadAll(r.Body) // want `request body is read without limiting its size; wrap it with http.MaxBytesReader`
+	if err != nil {
+		return
+	}
+	w.Write(b)
+}
+
+func limitedBody(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, 1<<20)
+	var v interface{}
+	json.NewDecoder(r.Body).Decode(&v)
+}
+
+type server struct {
+	last *http.Request
+	reqs chan *http.Request
+}
+
+var lastRequest *http.Request
+
+func (s *server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.last = r      // want `request is stored, which might outlive the handler; copy the values needed instead`
+	lastRequest = r // want `request is stored`
+	s.reqs <- r     // want `request is sent on a channel`
+	go func() {     // want `request is used in a goroutine`
+		log.Println(r.URL)
+	}()
+	go log.Println(r.URL.Path)
+	go s.handle(r) // want `request is used in a goroutine`
+	local := r
+	_ = local
+}
+
+func (s *server) handle(r *http.Request) {}
+
+func register() {
+	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "gone", http.StatusGone) // want `http.Error doesn't stop the handler`
+		w.Write(nil)
+	})
+}
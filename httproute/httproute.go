@@ -0,0 +1,611 @@
+// Copyright 2019 Axel Wagner
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package httproute defines an Analyzer that checks the routes registered
+// on HTTP routers.
+package httproute
+
+import (
+	"fmt"
+	"go/ast"
+	"go/constant"
+	"go/token"
+	"go/types"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/Merovius/go-tools/internal/analysisutil"
+	"github.com/Merovius/go-tools/internal/nodefilter"
+	"golang.org/x/tools/go/analysis"
+)
+
+const Doc = `check the routes registered on HTTP routers
+
+The analyzer collects the routes registered with constant patterns on
+routers of net/http, github.com/gorilla/mux, github.com/go-chi/chi and
+github.com/gin-gonic/gin in a package, including those of gin groups,
+gorilla subrouters and chi sub-routers, and reports
+  - routes registered twice for the same method on the same router, which
+    panics or silently replaces the first handler, depending on the router,
+  - patterns differing only by a trailing slash from another pattern of the
+    same router, which is usually a typo, as requests only match one of them,
+  - handlers reading path parameters with the API of another router, like
+    chi.URLParam in a handler registered on a gorilla/mux router, which
+    always returns nothing, and
+  - handlers reading path parameters which are not in the pattern of any
+    route they are registered with.
+
+Handlers are only followed if they are function literals or functions and
+methods of the package, possibly converted to http.HandlerFunc.`
+
+var Analyzer = &analysis.Analyzer{
+	Name: "httproute",
+	Doc:  Doc,
+	Run:  run,
+	Requires: []*analysis.Analyzer{
+		nodefilter.Analyzer,
+	},
+}
+
+var (
+	calls   = nodefilter.New(true, new(ast.CallExpr))
+	assigns = nodefilter.New(false, new(ast.AssignStmt))
+	funcs   = nodefilter.New(false, new(ast.FuncDecl))
+)
+
+// Routers, by the name used in diagnostics.
+const (
+	stdlib  = "net/http"
+	gorilla = "gorilla/mux"
+	chi     = "chi"
+	gin     = "gin"
+)
+
+const (
+	gorillaPath = "github.com/gorilla/mux"
+	ginPath     = "github.com/gin-gonic/gin"
+)
+
+func isChi(path string) bool {
+	return path == "github.com/go-chi/chi" || strings.HasPrefix(path, "github.com/go-chi/chi/v")
+}
+
+// A route is a registration of a handler on a router.
+type route struct {
+	router  types.Object // the root router
+	kind    string
+	method  string // "" for all methods
+	pattern string // including the prefixes of groups
+	call    *ast.CallExpr
+	handler ast.Node // *ast.FuncLit or *ast.FuncDecl, or nil
+}
+
+// A group is a router derived from another one, whose routes have a
+// prefix.
+type group struct {
+	parent ast.Expr
+	prefix string
+}
+
+type checker struct {
+	pass   *analysis.Pass
+	groups map[types.Object]group
+	decls  map[types.Object]*ast.FuncDecl
+}
+
+func run(pass *analysis.Pass) (interface{}, error) {
+	nodes := pass.ResultOf[nodefilter.Analyzer].(*nodefilter.Result)
+	c := &checker{
+		pass:   pass,
+		groups: make(map[types.Object]group),
+		decls:  make(map[types.Object]*ast.FuncDecl),
+	}
+	for _, n := range nodes.Nodes(funcs) {
+		fn := n.Node.(*ast.FuncDecl)
+		if obj := pass.TypesInfo.Defs[fn.Name]; obj != nil && fn.Body != nil {
+			c.decls[obj] = fn
+		}
+	}
+	for _, n := range nodes.Nodes(assigns) {
+		as := n.Node.(*ast.AssignStmt)
+		if len(as.Lhs) != len(as.Rhs) {
+			continue
+		}
+		for i, rhs := range as.Rhs {
+			if g, ok := c.group(rhs); ok {
+				if obj := objectOf(pass.TypesInfo, as.Lhs[i]); obj != nil {
+					c.groups[obj] = g
+				}
+			}
+		}
+	}
+	// The parameters of the function literals passed to chi's Route and
+	// Group are sub-routers.
+	for _, n := range nodes.Nodes(calls) {
+		call := n.Node.(*ast.CallExpr)
+		fn := analysisutil.Callee(pass.TypesInfo, call)
+		sel, ok := call.Fun.(*ast.SelectorExpr)
+		if fn == nil || fn.Pkg() == nil || !isChi(fn.Pkg().Path()) || !ok || len(call.Args) == 0 {
+			continue
+		}
+		var prefix string
+		switch {
+		case fn.Name() == "Route" && len(call.Args) == 2:
+			if prefix, ok = stringConst(pass.TypesInfo, call.Args[0]); !ok {
+				continue
+			}
+		case fn.Name() == "Group" && len(call.Args) == 1:
+		default:
+			continue
+		}
+		lit, ok := call.Args[len(call.Args)-1].(*ast.FuncLit)
+		if !ok || len(lit.Type.Params.List) != 1 || len(lit.Type.Params.List[0].Names) != 1 {
+			continue
+		}
+		if obj := pass.TypesInfo.Defs[lit.Type.Params.List[0].Names[0]]; obj != nil {
+			c.groups[obj] = group{sel.X, prefix}
+		}
+	}
+
+	var routes []route
+	for _, n := range nodes.Nodes(calls) {
+		routes = append(routes, c.routes(n.Node.(*ast.CallExpr), n.Stack)...)
+	}
+	c.checkRoutes(routes)
+	c.checkParams(routes)
+	return nil, nil
+}
+
+// group returns the group created by the call e, if it is one.
+func (c *checker) group(e ast.Expr) (group, bool) {
+	call, ok := analysisutil.Unparen(e).(*ast.CallExpr)
+	if !ok {
+		return group{}, false
+	}
+	fn := analysisutil.Callee(c.pass.TypesInfo, call)
+	sel, ok := call.Fun.(*ast.SelectorExpr)
+	if fn == nil || fn.Pkg() == nil || !ok {
+		return group{}, false
+	}
+	switch path := analysisutil.TrimVendor(fn.Pkg().Path()); {
+	case isChi(path):
+		// r.With(middleware) shares the routes of r.
+		if fn.Name() == "With" {
+			return group{sel.X, ""}, true
+		}
+	case path == ginPath:
+		if fn.Name() == "Group" && len(call.Args) >= 1 {
+			if prefix, ok := stringConst(c.pass.TypesInfo, call.Args[0]); ok {
+				return group{sel.X, prefix}, true
+			}
+		}
+	case path == gorillaPath:
+		// r.PathPrefix("/api").Subrouter()
+		if fn.Name() != "Subrouter" {
+			break
+		}
+		inner, ok := analysisutil.Unparen(sel.X).(*ast.CallExpr)
+		if !ok || !analysisutil.IsCall(c.pass.TypesInfo, inner, "(*"+gorillaPath+".Router).PathPrefix") || len(inner.Args) != 1 {
+			break
+		}
+		if prefix, ok := stringConst(c.pass.TypesInfo, inner.Args[0]); ok {
+			return group{inner.Fun.(*ast.SelectorExpr).X, prefix}, true
+		}
+	}
+	return group{}, false
+}
+
+// router returns the root router of the router e and the prefix of the
+// patterns registered on e.
+func (c *checker) router(e ast.Expr) (types.Object, string) {
+	var prefixes []string
+	for i := 0; i < 100; i++ {
+		g, ok := c.group(e)
+		if !ok {
+			obj := objectOf(c.pass.TypesInfo, e)
+			if obj == nil {
+				return nil, ""
+			}
+			if g, ok = c.groups[obj]; !ok {
+				return obj, joinPrefixes(prefixes)
+			}
+		}
+		prefixes = append(prefixes, g.prefix)
+		e = g.parent
+	}
+	return nil, ""
+}
+
+// objectOf returns the object denoted by the identifier or selector e, like
+// a field or a variable of another package, or nil.
+func objectOf(info *types.Info, e ast.Expr) types.Object {
+	if sel, ok := analysisutil.Unparen(e).(*ast.SelectorExpr); ok {
+		return info.ObjectOf(sel.Sel)
+	}
+	return analysisutil.ObjectOf(info, e)
+}
+
+func joinPrefixes(prefixes []string) string {
+	var s string
+	for i := len(prefixes) - 1; i >= 0; i-- {
+		s = joinPath(s, prefixes[i])
+	}
+	return s
+}
+
+func joinPath(prefix, p string) string {
+	if prefix == "" {
+		return p
+	}
+	if p == "" {
+		return prefix
+	}
+	return strings.TrimSuffix(prefix, "/") + "/" + strings.TrimPrefix(p, "/")
+}
+
+var chiMethods = map[string]string{
+	"Connect": "CONNECT", "Delete": "DELETE", "Get": "GET", "Head": "HEAD",
+	"Options": "OPTIONS", "Patch": "PATCH", "Post": "POST", "Put": "PUT",
+	"Trace": "TRACE",
+}
+
+var ginMethods = map[string]bool{
+	"DELETE": true, "GET": true, "HEAD": true, "OPTIONS": true,
+	"PATCH": true, "POST": true, "PUT": true,
+}
+
+// routes returns the routes registered by call, if any. stack is the stack
+// of call, including it.
+func (c *checker) routes(call *ast.CallExpr, stack []ast.Node) []route {
+	info := c.pass.TypesInfo
+	fn := analysisutil.Callee(info, call)
+	if fn == nil || fn.Pkg() == nil {
+		return nil
+	}
+	var (
+		recv          ast.Expr
+		kind, method  string
+		methods       []string
+		pat, handler  = 0, 1
+		sel, isMethod = call.Fun.(*ast.SelectorExpr)
+		path          = analysisutil.TrimVendor(fn.Pkg().Path())
+	)
+	if isMethod && fn.Type().(*types.Signature).Recv() != nil {
+		recv = sel.X
+	}
+	switch {
+	case path == "net/http":
+		switch analysisutil.FuncName(fn) {
+		case "(*net/http.ServeMux).Handle", "(*net/http.ServeMux).HandleFunc":
+		case "net/http.Handle", "net/http.HandleFunc":
+			obj := fn.Pkg().Scope().Lookup("DefaultServeMux")
+			if obj == nil || len(call.Args) != 2 {
+				return nil
+			}
+			return c.register(call, obj, stdlib, "", 0, 1, "")
+		default:
+			return nil
+		}
+		kind = stdlib
+	case path == gorillaPath:
+		if fn.Name() != "Handle" && fn.Name() != "HandleFunc" || !isRouter(fn, "Router") {
+			return nil
+		}
+		kind = gorilla
+		// r.HandleFunc("/x", h).Methods("GET", "POST")
+		if len(stack) >= 3 {
+			if s, ok := stack[len(stack)-2].(*ast.SelectorExpr); ok && s.Sel.Name == "Methods" {
+				if mc, ok := stack[len(stack)-3].(*ast.CallExpr); ok && mc.Fun == s {
+					for _, arg := range mc.Args {
+						if m, ok := stringConst(info, arg); ok {
+							methods = append(methods, strings.ToUpper(m))
+						}
+					}
+				}
+			}
+		}
+	case isChi(path):
+		kind = chi
+		switch name := fn.Name(); {
+		case chiMethods[name] != "":
+			method = chiMethods[name]
+		case name == "Handle" || name == "HandleFunc":
+		case name == "Method" || name == "MethodFunc":
+			m, ok := stringConst(info, call.Args[0])
+			if !ok {
+				return nil
+			}
+			method, pat, handler = strings.ToUpper(m), 1, 2
+		default:
+			return nil
+		}
+	case path == ginPath:
+		kind = gin
+		handler = len(call.Args) - 1
+		switch name := fn.Name(); {
+		case ginMethods[name]:
+			method = name
+		case name == "Any":
+		case name == "Handle":
+			m, ok := stringConst(info, call.Args[0])
+			if !ok {
+				return nil
+			}
+			method, pat = strings.ToUpper(m), 1
+		default:
+			return nil
+		}
+	default:
+		return nil
+	}
+	if recv == nil || handler <= pat || handler >= len(call.Args) {
+		return nil
+	}
+	root, prefix := c.router(recv)
+	if root == nil {
+		return nil
+	}
+	if len(methods) == 0 {
+		return c.register(call, root, kind, method, pat, handler, prefix)
+	}
+	var rs []route
+	for _, m := range methods {
+		rs = append(rs, c.register(call, root, kind, m, pat, handler, prefix)...)
+	}
+	return rs
+}
+
+// isRouter returns whether the method fn has a receiver of type name or a
+// pointer to it.
+func isRouter(fn *types.Func, name string) bool {
+	recv := fn.Type().(*types.Signature).Recv()
+	return recv != nil && strings.HasSuffix(analysisutil.TypeName(recv.Type()), "."+name)
+}
+
+func (c *checker) register(call *ast.CallExpr, root types.Object, kind, method string, pat, handler int, prefix string) []route {
+	p, ok := stringConst(c.pass.TypesInfo, call.Args[pat])
+	if !ok {
+		return nil
+	}
+	if kind == stdlib {
+		// Since Go 1.22, patterns can start with a method.
+		if i := strings.IndexAny(p, " \t"); i >= 0 {
+			method, p = p[:i], strings.TrimLeft(p[i:], " \t")
+		}
+	}
+	return []route{{
+		router:  root,
+		kind:    kind,
+		method:  method,
+		pattern: joinPath(prefix, p),
+		call:    call,
+		handler: c.handler(call.Args[handler]),
+	}}
+}
+
+// handler returns the function literal or declaration of the handler e, or
+// nil.
+func (c *checker) handler(e ast.Expr) ast.Node {
+	e = analysisutil.Unparen(e)
+	if call, ok := e.(*ast.CallExpr); ok && len(call.Args) == 1 {
+		if tv, ok := c.pass.TypesInfo.Types[call.Fun]; ok && tv.IsType() {
+			return c.handler(call.Args[0])
+		}
+		return nil
+	}
+	if lit, ok := e.(*ast.FuncLit); ok {
+		return lit
+	}
+	if fn, ok := objectOf(c.pass.TypesInfo, e).(*types.Func); ok {
+		if decl, ok := c.decls[fn]; ok {
+			return decl
+		}
+	}
+	return nil
+}
+
+func (c *checker) checkRoutes(routes []route) {
+	type key struct {
+		router  types.Object
+		method  string
+		pattern string
+	}
+	seen := make(map[key]route)
+	for _, r := range routes {
+		if analysisutil.IsGenerated(c.pass, r.call.Pos()) {
+			continue
+		}
+		k := key{r.router, r.method, r.pattern}
+		if first, ok := seen[k]; ok {
+			name := r.pattern
+			if r.method != "" {
+				name = r.method + " " + name
+			}
+			c.pass.Reportf(r.call.Pos(), "route %s is already registered on the same router at %s", name, c.position(first.call))
+			continue
+		}
+		seen[k] = r
+		other := strings.TrimSuffix(r.pattern, "/")
+		if other == r.pattern {
+			other += "/"
+		}
+		if other == "" || other == "/" {
+			continue
+		}
+		for _, m := range []string{r.method, ""} {
+			if first, ok := seen[key{r.router, m, other}]; ok {
+				c.pass.Reportf(r.call.Pos(), "pattern %s differs only by a trailing slash from %s, registered on the same router at %s", r.pattern, other, c.position(first.call))
+				break
+			}
+		}
+	}
+}
+
+func (c *checker) position(n ast.Node) string {
+	posn := c.pass.Fset.Position(n.Pos())
+	return fmt.Sprintf("%s:%d", filepath.Base(posn.Filename), posn.Line)
+}
+
+// A param is a read of a path parameter in a handler.
+type param struct {
+	pos  token.Pos
+	kind string // the router whose API is used
+	api  string
+	name string // "" if not known
+}
+
+func (c *checker) checkParams(routes []route) {
+	byHandler := make(map[ast.Node][]route)
+	var handlers []ast.Node
+	for _, r := range routes {
+		if r.handler == nil {
+			continue
+		}
+		if _, ok := byHandler[r.handler]; !ok {
+			handlers = append(handlers, r.handler)
+		}
+		byHandler[r.handler] = append(byHandler[r.handler], r)
+	}
+	for _, h := range handlers {
+		rs := byHandler[h]
+		for _, p := range c.params(h) {
+			var (
+				kinds    []string
+				patterns []string
+				found    bool
+			)
+			for _, r := range rs {
+				if !compatible(p.kind, r.kind) {
+					kinds = appendUnique(kinds, r.kind)
+					continue
+				}
+				patterns = appendUnique(patterns, r.pattern)
+				if p.name == "" || hasParam(r.kind, r.pattern, p.name) {
+					found = true
+				}
+			}
+			switch {
+			case found:
+			case len(patterns) == 0:
+				sort.Strings(kinds)
+				c.pass.Reportf(p.pos, "%s only works with %s routers, but the handler is registered on a %s router, so the parameter is always empty", p.api, p.kind, strings.Join(kinds, " and "))
+			default:
+				c.pass.Reportf(p.pos, "path parameter %q is not in the pattern of any route of the handler (%s)", p.name, strings.Join(patterns, ", "))
+			}
+		}
+	}
+}
+
+func appendUnique(s []string, v string) []string {
+	for _, x := range s {
+		if x == v {
+			return s
+		}
+	}
+	return append(s, v)
+}
+
+// compatible returns whether the path parameters of routers of kind router
+// can be read with the API of reader.
+func compatible(reader, router string) bool {
+	// chi sets the path values of requests as well.
+	return reader == router || reader == stdlib && router == chi
+}
+
+var (
+	braceParam = regexp.MustCompile(`\{([^}:.]*)`)
+	ginParam   = regexp.MustCompile(`[:*]([^/]+)`)
+)
+
+// hasParam returns whether the pattern of a router of the given kind has
+// the parameter name.
+func hasParam(kind, pattern, name string) bool {
+	re := braceParam
+	if kind == gin {
+		re = ginParam
+	}
+	for _, m := range re.FindAllStringSubmatch(pattern, -1) {
+		if m[1] == name {
+			return true
+		}
+	}
+	return false
+}
+
+// params returns the reads of path parameters in the handler h.
+func (c *checker) params(h ast.Node) []param {
+	info := c.pass.TypesInfo
+	// Variables holding the result of mux.Vars.
+	vars := make(map[types.Object]bool)
+	indexed := make(map[*ast.CallExpr]bool)
+	var ps []param
+	ast.Inspect(h, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.AssignStmt:
+			if len(n.Lhs) == 1 && len(n.Rhs) == 1 {
+				if call, ok := analysisutil.Unparen(n.Rhs[0]).(*ast.CallExpr); ok && analysisutil.IsCall(info, call, gorillaPath+".Vars") {
+					if obj := analysisutil.ObjectOf(info, n.Lhs[0]); obj != nil {
+						vars[obj] = true
+						indexed[call] = true
+					}
+				}
+			}
+		case *ast.IndexExpr:
+			name, ok := stringConst(info, n.Index)
+			if !ok {
+				break
+			}
+			x := analysisutil.Unparen(n.X)
+			if call, ok := x.(*ast.CallExpr); ok && analysisutil.IsCall(info, call, gorillaPath+".Vars") {
+				indexed[call] = true
+				ps = append(ps, param{n.Pos(), gorilla, "mux.Vars", name})
+			} else if obj := analysisutil.ObjectOf(info, x); obj != nil && vars[obj] {
+				ps = append(ps, param{n.Pos(), gorilla, "mux.Vars", name})
+			}
+		case *ast.CallExpr:
+			fn := analysisutil.Callee(info, n)
+			if fn == nil || fn.Pkg() == nil {
+				break
+			}
+			path := analysisutil.TrimVendor(fn.Pkg().Path())
+			switch {
+			case path == gorillaPath && fn.Name() == "Vars":
+				if !indexed[n] {
+					ps = append(ps, param{n.Pos(), gorilla, "mux.Vars", ""})
+				}
+			case isChi(path) && (fn.Name() == "URLParam" || fn.Name() == "URLParamFromCtx") && len(n.Args) == 2:
+				name, _ := stringConst(info, n.Args[1])
+				ps = append(ps, param{n.Pos(), chi, "chi." + fn.Name(), name})
+			case analysisutil.FuncName(fn) == "(*"+ginPath+".Context).Param" && len(n.Args) == 1:
+				name, _ := stringConst(info, n.Args[0])
+				ps = append(ps, param{n.Pos(), gin, "gin.Context.Param", name})
+			case analysisutil.FuncName(fn) == "(*net/http.Request).PathValue" && len(n.Args) == 1:
+				name, _ := stringConst(info, n.Args[0])
+				ps = append(ps, param{n.Pos(), stdlib, "Request.PathValue", name})
+			}
+		}
+		return true
+	})
+	return ps
+}
+
+func stringConst(info *types.Info, e ast.Expr) (string, bool) {
+	tv, ok := info.Types[e]
+	if !ok || tv.Value == nil || tv.Value.Kind() != constant.String {
+		return "", false
+	}
+	return constant.StringVal(tv.Value), true
+}
@@ -0,0 +1,25 @@
+package a
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func Chi() {
+	r := chi.NewRouter()
+	r.Get("/posts/{slug}", post)
+	r.Route("/admin", func(r chi.Router) {
+		r.Get("/posts/{slug}", post)
+		r.Post("/posts/{slug}/", post)
+	})
+	r.Get("/admin/posts/{slug}", post)            // want `route GET /admin/posts/{slug} is already registered on the same router at chi.go:13`
+	r.With(nil).Post("/admin/posts/{slug}", post) // want `pattern /admin/posts/{slug} differs only by a trailing slash from /admin/posts/{slug}/, registered on the same router at chi.go:14`
+	r.Method("DELETE", "/posts/{slug}", http.HandlerFunc(post))
+}
+
+func post(w http.ResponseWriter, req *http.Request) {
+	_ = chi.URLParam(req, "slug")
+	_ = req.PathValue("slug")
+	_ = chi.URLParam(req, "id") // want `path parameter "id" is not in the pattern of any route of the handler`
+}
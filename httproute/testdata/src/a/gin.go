@@ -0,0 +1,24 @@
+package a
+
+import "github.com/gin-gonic/gin"
+
+func Gin() {
+	e := gin.New()
+	v1 := e.Group("/v1")
+	{
+		v1.GET("/books/:id", book)
+		v1.GET("/books/:id", auth, book) // want `route GET /v1/books/:id is already registered on the same router at gin.go:9`
+	}
+	e.Group("/v2").GET("/books/:id", book)
+	e.Handle("GET", "/v2/books/:id", book) // want `route GET /v2/books/:id is already registered on the same router at gin.go:12`
+	e.Any("/files/*path", func(c *gin.Context) {
+		_ = c.Param("path")
+		_ = c.Param("name") // want `path parameter "name" is not in the pattern of any route of the handler \(/files/\*path\)`
+	})
+}
+
+func auth(c *gin.Context) {}
+
+func book(c *gin.Context) {
+	_ = c.Param("id")
+}
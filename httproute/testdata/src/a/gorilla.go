@@ -0,0 +1,26 @@
+package a
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/gorilla/mux"
+)
+
+func Gorilla() {
+	r := mux.NewRouter()
+	r.HandleFunc("/orders/{id:[0-9]+}", order).Methods("GET")
+	r.HandleFunc("/orders/{id:[0-9]+}", order).Methods(http.MethodPut, "GET") // want `route GET /orders/{id:\[0-9\]\+} is already registered on the same router at gorilla.go:12`
+	api := r.PathPrefix("/api").Subrouter()
+	api.HandleFunc("/orders/{id}", order)
+	api.Handle("/carts/{cart}", http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		_ = chi.URLParam(req, "cart") // want `chi.URLParam only works with chi routers, but the handler is registered on a gorilla/mux router, so the parameter is always empty`
+	}))
+	r.HandleFunc("/api/orders/{id}", order) // want `route /api/orders/{id} is already registered on the same router at gorilla.go:15`
+}
+
+func order(w http.ResponseWriter, req *http.Request) {
+	vars := mux.Vars(req)
+	_ = vars["id"]
+	_ = mux.Vars(req)["order"] // want `path parameter "order" is not in the pattern of any route of the handler \(/orders/{id:\[0-9\]\+}, /api/orders/{id}\)`
+}
@@ -0,0 +1,27 @@
+package a
+
+import "net/http"
+
+func Stdlib() {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /users/{id}", getUser)
+	mux.HandleFunc("POST /users/{id}", getUser)
+	mux.HandleFunc("GET /users/{id}", getUser) // want `route GET /users/{id} is already registered on the same router at stdlib.go:7`
+	mux.HandleFunc("/items", items)
+	mux.HandleFunc("/items/", items) // want `pattern /items/ differs only by a trailing slash from /items, registered on the same router at stdlib.go:10`
+
+	other := http.NewServeMux()
+	other.HandleFunc("/items", items)
+
+	http.HandleFunc("/health", health)
+	http.DefaultServeMux.HandleFunc("/health", health) // want `route /health is already registered on the same router at stdlib.go:16`
+}
+
+func getUser(w http.ResponseWriter, r *http.Request) {
+	_ = r.PathValue("id")
+	_ = r.PathValue("user") // want `path parameter "user" is not in the pattern of any route of the handler \(/users/{id}\)`
+}
+
+func items(w http.ResponseWriter, r *http.Request) {}
+
+func health(w http.ResponseWriter, r *http.Request) {}
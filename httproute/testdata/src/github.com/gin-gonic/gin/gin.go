@@ -0,0 +1,33 @@
+package gin
+
+import "net/http"
+
+type HandlerFunc func(*Context)
+
+type Context struct {
+	Request *http.Request
+}
+
+func (c *Context) Param(key string) string { return "" }
+
+type IRoutes interface{}
+
+type RouterGroup struct{}
+
+func (g *RouterGroup) Group(relativePath string, handlers ...HandlerFunc) *RouterGroup { return g }
+
+func (g *RouterGroup) GET(relativePath string, handlers ...HandlerFunc) IRoutes { return nil }
+
+func (g *RouterGroup) POST(relativePath string, handlers ...HandlerFunc) IRoutes { return nil }
+
+func (g *RouterGroup) Any(relativePath string, handlers ...HandlerFunc) IRoutes { return nil }
+
+func (g *RouterGroup) Handle(method, relativePath string, handlers ...HandlerFunc) IRoutes {
+	return nil
+}
+
+type Engine struct {
+	RouterGroup
+}
+
+func New() *Engine { return nil }
@@ -0,0 +1,39 @@
+package chi
+
+import "net/http"
+
+type Router interface {
+	http.Handler
+	Get(pattern string, h http.HandlerFunc)
+	Post(pattern string, h http.HandlerFunc)
+	Handle(pattern string, h http.Handler)
+	HandleFunc(pattern string, h http.HandlerFunc)
+	Method(method, pattern string, h http.Handler)
+	Route(pattern string, fn func(r Router)) Router
+	Group(fn func(r Router)) Router
+	With(middlewares ...func(http.Handler) http.Handler) Router
+}
+
+type Mux struct{}
+
+func NewRouter() *Mux { return nil }
+
+func (mx *Mux) ServeHTTP(w http.ResponseWriter, r *http.Request) {}
+
+func (mx *Mux) Get(pattern string, h http.HandlerFunc) {}
+
+func (mx *Mux) Post(pattern string, h http.HandlerFunc) {}
+
+func (mx *Mux) Handle(pattern string, h http.Handler) {}
+
+func (mx *Mux) HandleFunc(pattern string, h http.HandlerFunc) {}
+
+func (mx *Mux) Method(method, pattern string, h http.Handler) {}
+
+func (mx *Mux) Route(pattern string, fn func(r Router)) Router { return nil }
+
+func (mx *Mux) Group(fn func(r Router)) Router { return nil }
+
+func (mx *Mux) With(middlewares ...func(http.Handler) http.Handler) Router { return nil }
+
+func URLParam(r *http.Request, key string) string { return "" }
@@ -0,0 +1,208 @@
+// Copyright 2019 Axel Wagner
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package ignoredresult defines an Analyzer that checks for results of
+// functions which no caller uses.
+package ignoredresult
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"path/filepath"
+
+	"github.com/Merovius/go-tools/internal/analysisutil"
+	"github.com/Merovius/go-tools/internal/nodefilter"
+	"golang.org/x/tools/go/analysis"
+)
+
+const Doc = `check for results of functions which no caller uses
+
+The analyzer reports results of unexported functions, which are ignored at
+every call site, either by calling the function as a statement or by
+assigning the result to _. Either the result is unnecessary and should be
+removed, or the callers should handle it, which is especially likely for
+errors.
+
+Unexported functions can only be called in their own package, so all call
+sites are known. Packages with test files are only checked together with
+them, which needs tests to be analyzed as well. Functions used as values, for example passed as callbacks,
+and methods, which might implement interfaces, are not reported.`
+
+var Analyzer = &analysis.Analyzer{
+	Name: "ignoredresult",
+	Doc:  Doc,
+	Run:  run,
+	Requires: []*analysis.Analyzer{
+		nodefilter.Analyzer,
+	},
+}
+
+var (
+	funcs  = nodefilter.New(false, new(ast.FuncDecl))
+	idents = nodefilter.New(true, new(ast.Ident))
+)
+
+// A usage records how the results of a function are used.
+type usage struct {
+	calls   int
+	used    []bool // by result index
+	invalid bool   // the function is used as a value
+}
+
+func run(pass *analysis.Pass) (interface{}, error) {
+	if missingTests(pass) {
+		return nil, nil
+	}
+	nodes := pass.ResultOf[nodefilter.Analyzer].(*nodefilter.Result)
+
+	usages := make(map[*types.Func]*usage)
+	for _, n := range nodes.Nodes(idents) {
+		id := n.Node.(*ast.Ident)
+		fn, ok := pass.TypesInfo.Uses[id].(*types.Func)
+		if !ok || fn.Pkg() != pass.Pkg {
+			continue
+		}
+		sig := fn.Type().(*types.Signature)
+		if sig.Recv() != nil || sig.Results().Len() == 0 {
+			continue
+		}
+		u := usages[fn]
+		if u == nil {
+			u = &usage{used: make([]bool, sig.Results().Len())}
+			usages[fn] = u
+		}
+		used, ok := resultsUsed(n.Stack, len(u.used))
+		if !ok {
+			u.invalid = true
+			continue
+		}
+		u.calls++
+		for i, b := range used {
+			u.used[i] = u.used[i] || b
+		}
+	}
+
+	for _, n := range nodes.Nodes(funcs) {
+		decl := n.Node.(*ast.FuncDecl)
+		if decl.Recv != nil || decl.Name.IsExported() {
+			continue
+		}
+		fn, ok := pass.TypesInfo.Defs[decl.Name].(*types.Func)
+		if !ok {
+			continue
+		}
+		u := usages[fn]
+		if u == nil || u.invalid || u.calls == 0 {
+			continue
+		}
+		sites := "at the call site"
+		if u.calls > 1 {
+			sites = fmt.Sprintf("at all %d call sites", u.calls)
+		}
+		i := 0
+		for _, field := range decl.Type.Results.List {
+			k := len(field.Names)
+			if k == 0 {
+				k = 1
+			}
+			for j := 0; j < k; j++ {
+				if !u.used[i] {
+					var node ast.Node = field.Type
+					what := "result " + types.ExprString(field.Type)
+					if len(field.Names) > 0 {
+						node, what = field.Names[j], "result "+field.Names[j].Name
+					}
+					if len(u.used) == 1 {
+						what = "result"
+					}
+					pass.Reportf(node.Pos(), "%s of %s is ignored %s; remove it or handle it", what, decl.Name.Name, sites)
+				}
+				i++
+			}
+		}
+	}
+	return nil, nil
+}
+
+// missingTests returns whether the package has test files which aren't
+// analyzed, as it's not the test variant of the package. Functions might
+// be called in them.
+func missingTests(pass *analysis.Pass) bool {
+	if len(pass.Files) == 0 {
+		return false
+	}
+	for _, f := range pass.Files {
+		if analysisutil.IsTestFile(pass, f.Pos()) {
+			return false
+		}
+	}
+	dir := filepath.Dir(pass.Fset.File(pass.Files[0].Pos()).Name())
+	names, _ := filepath.Glob(filepath.Join(dir, "*_test.go"))
+	for _, name := range names {
+		// External tests can't call unexported functions.
+		f, err := parser.ParseFile(token.NewFileSet(), name, nil, parser.PackageClauseOnly)
+		if err == nil && f.Name.Name == pass.Pkg.Name() {
+			return true
+		}
+	}
+	return false
+}
+
+// resultsUsed returns which of the n results of the function referred to by
+// the identifier at the top of stack are used. It returns false if the
+// function isn't called.
+func resultsUsed(stack []ast.Node, n int) ([]bool, bool) {
+	used := make([]bool, n)
+	i := len(stack) - 2
+	for i > 0 {
+		if _, ok := stack[i].(*ast.ParenExpr); !ok {
+			break
+		}
+		i--
+	}
+	call, ok := stack[i].(*ast.CallExpr)
+	if !ok || call.Fun != stack[i+1] {
+		return nil, false
+	}
+	switch p := stack[i-1].(type) {
+	case *ast.ExprStmt, *ast.GoStmt, *ast.DeferStmt:
+		return used, true
+	case *ast.AssignStmt:
+		if len(p.Lhs) == n && len(p.Rhs) == 1 {
+			for j, e := range p.Lhs {
+				used[j] = !isBlank(e)
+			}
+			return used, true
+		}
+	case *ast.ValueSpec:
+		if len(p.Names) == n && len(p.Values) == 1 {
+			for j, id := range p.Names {
+				used[j] = id.Name != "_"
+			}
+			return used, true
+		}
+	}
+	for j := range used {
+		used[j] = true
+	}
+	return used, true
+}
+
+func isBlank(e ast.Expr) bool {
+	id, ok := e.(*ast.Ident)
+	return ok && id.Name == "_"
+}
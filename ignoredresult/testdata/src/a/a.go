@@ -0,0 +1,54 @@
+package a
+
+import "errors"
+
+func save(s string) error { // want `result of save is ignored at all 2 call sites; remove it or handle it`
+	return errors.New(s)
+}
+
+func lookup(k string) (v int, ok bool) { // want `result ok of lookup is ignored at all 2 call sites`
+	return len(k), true
+}
+
+func parse(s string) (int, error) { // want `result error of parse is ignored at the call site`
+	return len(s), nil
+}
+
+func used(s string) error {
+	return nil
+}
+
+func callback() bool {
+	return true
+}
+
+func never() int {
+	return 0
+}
+
+func Exported() error {
+	return nil
+}
+
+type T struct{}
+
+func (T) method() error {
+	return nil
+}
+
+func run(f func() bool) {
+	save("a")
+	defer save("b")
+	v, _ := lookup("x")
+	var w, _ = lookup("y")
+	n, _ := parse("z")
+	println(v, w, n)
+	if err := used("x"); err != nil {
+		panic(err)
+	}
+	used("y")
+	callback()
+	run(callback)
+	Exported()
+	T{}.method()
+}
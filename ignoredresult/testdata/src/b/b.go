@@ -0,0 +1,10 @@
+package b
+
+// The result is only used in a test.
+func compute(s string) []int {
+	return nil
+}
+
+func run() {
+	compute("x")
+}
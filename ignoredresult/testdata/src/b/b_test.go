@@ -0,0 +1,9 @@
+package b
+
+import "testing"
+
+func TestCompute(t *testing.T) {
+	if len(compute("x")) != 0 {
+		t.Error("compute returned results")
+	}
+}
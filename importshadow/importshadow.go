@@ -0,0 +1,160 @@
+// Copyright 2019 Axel Wagner
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package importshadow defines an Analyzer that checks for variables named
+// like an imported package, which is used in the same function.
+package importshadow
+
+import (
+	"go/ast"
+	"go/types"
+
+	"github.com/Merovius/go-tools/internal/analysisutil"
+	"github.com/Merovius/go-tools/internal/fix"
+	"github.com/Merovius/go-tools/internal/nodefilter"
+	"golang.org/x/tools/go/analysis"
+)
+
+const Doc = `check for variables shadowing an imported package used in the same function
+
+A parameter or local variable named like an imported package, like url in
+
+	func fetch(url string) error {
+		u, err := url.Parse(url) // doesn't compile
+		...
+
+hides the package in its scope. Readers have to keep track of which url is
+meant, and later edits using the package in the scope of the variable fail
+to compile. The analyzer reports such variables, if the package is used in
+the same function, for example before the declaration or outside of the
+function literal declaring it, and suggests renaming them to a name which isn't used
+in the function yet.`
+
+var Analyzer = &analysis.Analyzer{
+	Name: "importshadow",
+	Doc:  Doc,
+	Run:  run,
+	Requires: []*analysis.Analyzer{
+		nodefilter.Analyzer,
+	},
+}
+
+func init() {
+	// Renames are only suggested if the new name is unused.
+	fix.Register(Analyzer, fix.Safe)
+}
+
+var funcs = nodefilter.New(false, new(ast.FuncDecl))
+
+func run(pass *analysis.Pass) (interface{}, error) {
+	nodes := pass.ResultOf[nodefilter.Analyzer].(*nodefilter.Result)
+	for _, n := range nodes.Nodes(funcs) {
+		fn := n.Node.(*ast.FuncDecl)
+		if fn.Body == nil || analysisutil.IsGenerated(pass, fn.Pos()) {
+			continue
+		}
+		checkFunc(pass, fn)
+	}
+	return nil, nil
+}
+
+func checkFunc(pass *analysis.Pass, fn *ast.FuncDecl) {
+	var (
+		pkgs  = make(map[string]*types.PkgName) // used in fn, by name
+		vars  []*ast.Ident                      // defining variables
+		names = make(map[string]bool)           // of all identifiers in fn
+		uses  = make(map[*types.Var][]*ast.Ident)
+		kinds = make(map[*ast.Ident]string)
+	)
+	ast.Inspect(fn, func(n ast.Node) bool {
+		if ft, ok := n.(*ast.FuncType); ok {
+			addKind(kinds, ft.Params, "parameter")
+			addKind(kinds, ft.Results, "result")
+		}
+		id, ok := n.(*ast.Ident)
+		if !ok {
+			return true
+		}
+		names[id.Name] = true
+		switch obj := pass.TypesInfo.Uses[id].(type) {
+		case *types.PkgName:
+			pkgs[obj.Name()] = obj
+		case *types.Var:
+			uses[obj] = append(uses[obj], id)
+		}
+		if _, ok := pass.TypesInfo.Defs[id].(*types.Var); ok {
+			vars = append(vars, id)
+		}
+		return true
+	})
+	for _, id := range vars {
+		v := pass.TypesInfo.Defs[id].(*types.Var)
+		pkg, ok := pkgs[id.Name]
+		if !ok || v.IsField() {
+			continue
+		}
+		d := analysis.Diagnostic{
+			Pos:     id.Pos(),
+			End:     id.End(),
+			Message: kindOf(kinds, id) + " " + id.Name + " shadows the imported package " + pkg.Imported().Path() + ", which is used in the same function; rename it",
+		}
+		if name := newName(pass, v, names); name != "" {
+			edits := []analysis.TextEdit{{Pos: id.Pos(), End: id.End(), NewText: []byte(name)}}
+			for _, u := range uses[v] {
+				edits = append(edits, analysis.TextEdit{Pos: u.Pos(), End: u.End(), NewText: []byte(name)})
+			}
+			d.SuggestedFixes = []analysis.SuggestedFix{{
+				Message:   "rename " + id.Name + " to " + name,
+				TextEdits: edits,
+			}}
+			names[name] = true
+		}
+		pass.Report(d)
+	}
+}
+
+func addKind(kinds map[*ast.Ident]string, fields *ast.FieldList, kind string) {
+	if fields == nil {
+		return
+	}
+	for _, f := range fields.List {
+		for _, id := range f.Names {
+			kinds[id] = kind
+		}
+	}
+}
+
+func kindOf(kinds map[*ast.Ident]string, id *ast.Ident) string {
+	if k, ok := kinds[id]; ok {
+		return k
+	}
+	return "variable"
+}
+
+// newName returns a name for v, which isn't among the names used in its
+// function and doesn't refer to anything at package level, or "".
+func newName(pass *analysis.Pass, v *types.Var, names map[string]bool) string {
+	name := v.Name()
+	candidates := []string{name[:1], name + "Val", name + "Var"}
+	for _, c := range candidates {
+		if names[c] || c == "_" {
+			continue
+		}
+		if _, obj := v.Parent().LookupParent(c, v.Pos()); obj != nil {
+			continue
+		}
+		return c
+	}
+	return ""
+}
@@ -0,0 +1,50 @@
+package a
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"strings"
+)
+
+func fetch(u *url.URL, rawurl string) {
+	if u == nil {
+		var err error
+		u, err = url.Parse(path.Clean(rawurl))
+		fmt.Println(err)
+	}
+	path := strings.TrimPrefix(u.Path, "/") // want `variable path shadows the imported package path, which is used in the same function; rename it`
+	fmt.Println(path)
+	fmt.Println(path + "/")
+}
+
+func join(dir string) string {
+	full := strings.ToLower(path.Join(dir, "x"))
+	for _, strings := range []string{full} { // want `variable strings shadows the imported package strings`
+		fmt.Println(strings)
+	}
+	return full
+}
+
+func literals() {
+	u, _ := url.Parse("/")
+	f := func(url string) string { // want `parameter url shadows the imported package net/url`
+		return url
+	}
+	g := func() (url string) { // want `result url shadows the imported package net/url`
+		return
+	}
+	fmt.Println(u, f(""), g())
+}
+
+func taken() {
+	fmt.Println(path.Base("x"))
+	p, pathVal := 1, 2
+	path := p + pathVal // want `variable path shadows the imported package path`
+	fmt.Println(path)
+}
+
+// Not used in the same function.
+func unused(strings []string) {
+	fmt.Println(strings)
+}
@@ -0,0 +1,253 @@
+// Copyright 2019 Axel Wagner
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package indexrange defines an Analyzer that checks for index expressions
+// which are, or can easily be, out of range.
+package indexrange
+
+import (
+	"fmt"
+	"go/constant"
+	"go/token"
+	"go/types"
+
+	"github.com/Merovius/go-tools/internal/ssaflow"
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/passes/buildssa"
+	"golang.org/x/tools/go/ssa"
+)
+
+const Doc = `check for index expressions which can be out of range
+
+The analyzer reports
+  - constant indices and slice bounds beyond the length of a slice or string
+    whose length is known, like a slice created by a composite literal or
+    by make,
+  - indexing a slice or string with its length, like s[len(s)], which is
+    always out of range, and
+  - constant indices into results of functions like strings.Fields or
+    strings.Split, which can have fewer elements than expected, without
+    checking their length first. Checks in any condition dominating the
+    index expression are taken into account.`
+
+var Analyzer = &analysis.Analyzer{
+	Name: "indexrange",
+	Doc:  Doc,
+	Run:  run,
+	Requires: []*analysis.Analyzer{
+		buildssa.Analyzer,
+	},
+}
+
+// splitters maps functions returning slices to the minimum length of their
+// result. Results of the functions marked with nilEmpty are empty if and
+// only if they are nil.
+var splitters = map[string]struct {
+	min      int64
+	nilEmpty bool
+}{
+	"strings.Split":                               {1, false},
+	"strings.SplitN":                              {1, false},
+	"strings.SplitAfter":                          {1, false},
+	"strings.SplitAfterN":                         {1, false},
+	"bytes.Split":                                 {1, false},
+	"bytes.SplitN":                                {1, false},
+	"bytes.SplitAfter":                            {1, false},
+	"bytes.SplitAfterN":                           {1, false},
+	"strings.Fields":                              {0, false},
+	"strings.FieldsFunc":                          {0, false},
+	"bytes.Fields":                                {0, false},
+	"bytes.FieldsFunc":                            {0, false},
+	"path/filepath.SplitList":                     {0, false},
+	"flag.Args":                                   {0, false},
+	"(*flag.FlagSet).Args":                        {0, false},
+	"(*regexp.Regexp).FindStringSubmatch":         {0, true},
+	"(*regexp.Regexp).FindSubmatch":               {0, true},
+	"(*regexp.Regexp).FindStringIndex":            {0, true},
+	"(*regexp.Regexp).FindIndex":                  {0, true},
+	"(*regexp.Regexp).FindStringSubmatchIndex":    {0, true},
+	"(*regexp.Regexp).FindAllString":              {0, true},
+	"(*regexp.Regexp).FindAllStringSubmatch":      {0, true},
+	"(*regexp.Regexp).FindAllStringSubmatchIndex": {0, true},
+}
+
+func run(pass *analysis.Pass) (interface{}, error) {
+	ssainfo := pass.ResultOf[buildssa.Analyzer].(*buildssa.SSA)
+	for _, fn := range ssainfo.SrcFuncs {
+		for _, b := range fn.Blocks {
+			for _, instr := range b.Instrs {
+				switch instr := instr.(type) {
+				case *ssa.IndexAddr:
+					checkIndex(pass, b, instr.Pos(), instr.X, instr.Index)
+				case *ssa.Index:
+					checkIndex(pass, b, instr.Pos(), instr.X, instr.Index)
+				case *ssa.Slice:
+					checkSlice(pass, instr)
+				}
+			}
+		}
+	}
+	return nil, nil
+}
+
+func intConst(v ssa.Value) (int64, bool) {
+	c, ok := v.(*ssa.Const)
+	if !ok || c.Value == nil || c.Value.Kind() != constant.Int {
+		return 0, false
+	}
+	return constant.Int64Val(c.Value)
+}
+
+// knownLen returns the length and capacity of x, if it is a slice of a
+// composite literal or of an array allocated by make, or a constant string.
+func knownLen(x ssa.Value) (length, capacity int64, ok bool) {
+	switch x := x.(type) {
+	case *ssa.Slice:
+		if _, ok := x.X.(*ssa.Alloc); !ok || x.Max != nil {
+			return 0, 0, false
+		}
+		p, ok := x.X.Type().Underlying().(*types.Pointer)
+		if !ok {
+			return 0, 0, false
+		}
+		a, ok := p.Elem().Underlying().(*types.Array)
+		if !ok {
+			return 0, 0, false
+		}
+		lo, hi := int64(0), a.Len()
+		if x.Low != nil {
+			if lo, ok = intConst(x.Low); !ok {
+				return 0, 0, false
+			}
+		}
+		if x.High != nil {
+			if hi, ok = intConst(x.High); !ok {
+				return 0, 0, false
+			}
+		}
+		return hi - lo, a.Len() - lo, true
+	case *ssa.MakeSlice:
+		n, ok := intConst(x.Len)
+		return n, n, ok && x.Cap == x.Len
+	case *ssa.Const:
+		if x.Value != nil && x.Value.Kind() == constant.String {
+			n := int64(len(constant.StringVal(x.Value)))
+			return n, n, true
+		}
+	}
+	return 0, 0, false
+}
+
+func checkIndex(pass *analysis.Pass, b *ssa.BasicBlock, pos token.Pos, x, index ssa.Value) {
+	if pos == token.NoPos {
+		return
+	}
+	if call := ssaflow.BuiltinCall(index, "len"); call != nil && len(call.Call.Args) == 1 && call.Call.Args[0] == x {
+		pass.Report(analysis.Diagnostic{
+			Pos:      pos,
+			Category: "len",
+			Message:  "index is the length of the indexed value, which is always out of range",
+		})
+		return
+	}
+	k, ok := intConst(index)
+	if !ok {
+		return
+	}
+	if n, _, ok := knownLen(x); ok {
+		if k >= n {
+			pass.Reportf(pos, "index %d is out of range for length %d", k, n)
+		}
+		return
+	}
+	call, ok := x.(*ssa.Call)
+	if !ok {
+		return
+	}
+	fn := call.Call.StaticCallee()
+	if fn == nil {
+		return
+	}
+	s, ok := splitters[fn.String()]
+	if !ok || k < s.min || guarded(b, x, k, s.nilEmpty) {
+		return
+	}
+	msg := fn.String() + " can return an empty slice"
+	if s.min > 0 {
+		msg = fmt.Sprintf("%s can return fewer than %d elements", fn.String(), k+1)
+	}
+	pass.Report(analysis.Diagnostic{
+		Pos:      pos,
+		Category: "empty",
+		Message:  fmt.Sprintf("%s, so index %d can be out of range; check the length first", msg, k),
+	})
+}
+
+// guarded returns whether b is only reached if x has more than k elements.
+func guarded(b *ssa.BasicBlock, x ssa.Value, k int64, nilEmpty bool) bool {
+	for _, c := range ssaflow.Conditions(b) {
+		if nilEmpty {
+			if c, ok := c.About(x); ok && c.Op == token.NEQ {
+				if y, ok := c.Y.(*ssa.Const); ok && y.IsNil() {
+					return true
+				}
+			}
+		}
+		c, ok := c.AboutLen(x)
+		if !ok {
+			continue
+		}
+		n, ok := c.Int()
+		if !ok {
+			continue
+		}
+		var min int64
+		switch c.Op {
+		case token.GTR:
+			min = n + 1
+		case token.GEQ, token.EQL:
+			min = n
+		case token.NEQ:
+			if n != 0 {
+				continue
+			}
+			min = 1
+		default:
+			continue
+		}
+		if min > k {
+			return true
+		}
+	}
+	return false
+}
+
+func checkSlice(pass *analysis.Pass, s *ssa.Slice) {
+	if s.Pos() == token.NoPos {
+		return
+	}
+	_, n, ok := knownLen(s.X)
+	if !ok {
+		return
+	}
+	for _, bound := range []ssa.Value{s.Low, s.High, s.Max} {
+		if bound == nil {
+			continue
+		}
+		if k, ok := intConst(bound); ok && k > n {
+			pass.Reportf(s.Pos(), "slice bound %d is out of range for capacity %d", k, n)
+			return
+		}
+	}
+}
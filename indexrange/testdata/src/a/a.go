@@ -0,0 +1,65 @@
+package a
+
+import (
+	"regexp"
+	"strings"
+)
+
+func literals() {
+	xs := []int{1, 2, 3}
+	_ = xs[2]
+	_ = xs[3]  // want `index 3 is out of range for length 3`
+	_ = xs[:4] // want `slice bound 4 is out of range for capacity 3`
+	_ = xs[1:3]
+	ys := make([]string, 2)
+	ys[2] = "x" // want `index 2 is out of range for length 2`
+	s := "abc"
+	_ = s[5] // want `index 5 is out of range for length 3`
+	_ = s[:2]
+}
+
+func lengths(xs []int, s string) {
+	_ = xs[len(xs)] // want `index is the length of the indexed value, which is always out of range`
+	_ = s[len(s)]   // want `index is the length of the indexed value`
+	_ = xs[len(xs)-1]
+}
+
+func split(line string) (string, string) {
+	fields := strings.Fields(line)
+	cmd := fields[0] // want `strings.Fields can return an empty slice, so index 0 can be out of range; check the length first`
+	kv := strings.SplitN(line, "=", 2)
+	return cmd + kv[0], kv[1] // want `strings.SplitN can return fewer than 2 elements, so index 1 can be out of range; check the length first`
+}
+
+func splitChecked(line string) (string, string) {
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return "", ""
+	}
+	kv := strings.Split(line, "=")
+	if len(kv) < 2 {
+		return fields[0], ""
+	}
+	return fields[0], kv[1]
+}
+
+func splitEnough(line string) string {
+	parts := strings.Split(line, ":")
+	if len(parts) >= 3 {
+		return parts[2]
+	}
+	if len(parts) > 1 {
+		return parts[2] // want `strings.Split can return fewer than 3 elements, so index 2 can be out of range`
+	}
+	return parts[0]
+}
+
+var re = regexp.MustCompile(`(\w+)@(\w+)`)
+
+func match(s string) string {
+	if m := re.FindStringSubmatch(s); m != nil {
+		return m[1]
+	}
+	m := re.FindStringSubmatch(s)
+	return m[2] // want `\(\*regexp.Regexp\).FindStringSubmatch can return an empty slice, so index 2 can be out of range`
+}
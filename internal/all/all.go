@@ -0,0 +1,176 @@
+// Copyright 2019 Axel Wagner
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package all lists all analyzers in this repository.
+package all
+
+import (
+	"github.com/Merovius/go-tools/apiversion"
+	"github.com/Merovius/go-tools/appendexpand"
+	"github.com/Merovius/go-tools/assertmisuse"
+	"github.com/Merovius/go-tools/buildtags"
+	"github.com/Merovius/go-tools/casefold"
+	"github.com/Merovius/go-tools/cgoaudit"
+	"github.com/Merovius/go-tools/chandir"
+	"github.com/Merovius/go-tools/cleanup"
+	"github.com/Merovius/go-tools/clone"
+	"github.com/Merovius/go-tools/constdecl"
+	"github.com/Merovius/go-tools/constparam"
+	"github.com/Merovius/go-tools/constraints"
+	"github.com/Merovius/go-tools/ctxkey"
+	"github.com/Merovius/go-tools/ctxloop"
+	"github.com/Merovius/go-tools/deferunlock"
+	"github.com/Merovius/go-tools/divzero"
+	"github.com/Merovius/go-tools/doccomment"
+	"github.com/Merovius/go-tools/durationmath"
+	"github.com/Merovius/go-tools/elemcopy"
+	"github.com/Merovius/go-tools/encodeiface"
+	"github.com/Merovius/go-tools/envaccess"
+	"github.com/Merovius/go-tools/errgroup"
+	"github.com/Merovius/go-tools/errname"
+	"github.com/Merovius/go-tools/errortype"
+	"github.com/Merovius/go-tools/fileperm"
+	"github.com/Merovius/go-tools/finalizer"
+	"github.com/Merovius/go-tools/genericbloat"
+	"github.com/Merovius/go-tools/gorecover"
+	"github.com/Merovius/go-tools/grpchygiene"
+	"github.com/Merovius/go-tools/heapescape"
+	"github.com/Merovius/go-tools/hotloop"
+	"github.com/Merovius/go-tools/httphandler"
+	"github.com/Merovius/go-tools/httproute"
+	"github.com/Merovius/go-tools/ignoredresult"
+	"github.com/Merovius/go-tools/importshadow"
+	"github.com/Merovius/go-tools/indexrange"
+	"github.com/Merovius/go-tools/internalimport"
+	"github.com/Merovius/go-tools/jsonnumber"
+	"github.com/Merovius/go-tools/keyedfields"
+	"github.com/Merovius/go-tools/licenseheader"
+	"github.com/Merovius/go-tools/loopconv"
+	"github.com/Merovius/go-tools/mainpkg"
+	"github.com/Merovius/go-tools/mapaccess"
+	"github.com/Merovius/go-tools/modhygiene"
+	"github.com/Merovius/go-tools/moneyfloat"
+	"github.com/Merovius/go-tools/multierr"
+	"github.com/Merovius/go-tools/otelspan"
+	"github.com/Merovius/go-tools/panicflow"
+	"github.com/Merovius/go-tools/patterns"
+	"github.com/Merovius/go-tools/pkgname"
+	"github.com/Merovius/go-tools/platformcall"
+	"github.com/Merovius/go-tools/prommetrics"
+	"github.com/Merovius/go-tools/protomisuse"
+	"github.com/Merovius/go-tools/redundantbranch"
+	"github.com/Merovius/go-tools/retryloop"
+	"github.com/Merovius/go-tools/rwwrapper"
+	"github.com/Merovius/go-tools/selectmisuse"
+	"github.com/Merovius/go-tools/since"
+	"github.com/Merovius/go-tools/singleflight"
+	"github.com/Merovius/go-tools/sprawl"
+	"github.com/Merovius/go-tools/sqltx"
+	"github.com/Merovius/go-tools/stalemock"
+	"github.com/Merovius/go-tools/strictdecode"
+	"github.com/Merovius/go-tools/stringerdrift"
+	"github.com/Merovius/go-tools/timeformat"
+	"github.com/Merovius/go-tools/todo"
+	"github.com/Merovius/go-tools/typeswitchdefault"
+	"github.com/Merovius/go-tools/unboundedread"
+	"github.com/Merovius/go-tools/zipslip"
+	"golang.org/x/tools/go/analysis"
+)
+
+// Analyzers contains all analyzers run by default, ordered by name.
+var Analyzers = []*analysis.Analyzer{
+	apiversion.Analyzer,
+	appendexpand.Analyzer,
+	assertmisuse.Analyzer,
+	buildtags.Analyzer,
+	casefold.Analyzer,
+	cgoaudit.Analyzer,
+	chandir.Analyzer,
+	cleanup.Analyzer,
+	clone.Analyzer,
+	constdecl.Analyzer,
+	constparam.Analyzer,
+	constraints.Analyzer,
+	ctxkey.Analyzer,
+	ctxloop.Analyzer,
+	deferunlock.Analyzer,
+	divzero.Analyzer,
+	doccomment.Analyzer,
+	durationmath.Analyzer,
+	elemcopy.Analyzer,
+	encodeiface.Analyzer,
+	envaccess.Analyzer,
+	errgroup.Analyzer,
+	errname.Analyzer,
+	errortype.Analyzer,
+	fileperm.Analyzer,
+	finalizer.Analyzer,
+	genericbloat.Analyzer,
+	gorecover.Analyzer,
+	grpchygiene.Analyzer,
+	heapescape.Analyzer,
+	hotloop.Analyzer,
+	httphandler.Analyzer,
+	httproute.Analyzer,
+	ignoredresult.Analyzer,
+	importshadow.Analyzer,
+	indexrange.Analyzer,
+	internalimport.Analyzer,
+	jsonnumber.Analyzer,
+	keyedfields.Analyzer,
+	licenseheader.Analyzer,
+	loopconv.Analyzer,
+	mainpkg.Analyzer,
+	mapaccess.Analyzer,
+	modhygiene.Analyzer,
+	moneyfloat.Analyzer,
+	multierr.Analyzer,
+	otelspan.Analyzer,
+	panicflow.Analyzer,
+	patterns.Analyzer,
+	pkgname.Analyzer,
+	platformcall.Analyzer,
+	prommetrics.Analyzer,
+	protomisuse.Analyzer,
+	redundantbranch.Analyzer,
+	retryloop.Analyzer,
+	rwwrapper.Analyzer,
+	selectmisuse.Analyzer,
+	since.Analyzer,
+	singleflight.Analyzer,
+	sprawl.Analyzer,
+	sqltx.Analyzer,
+	stalemock.Analyzer,
+	strictdecode.Analyzer,
+	stringerdrift.Analyzer,
+	timeformat.Analyzer,
+	todo.Analyzer,
+	unboundedread.Analyzer,
+	zipslip.Analyzer,
+}
+
+// Optional contains the analyzers which are not run by default, as they
+// report too much in code not following their conventions, ordered by
+// name. They can be run by their own command.
+var Optional = []*analysis.Analyzer{
+	// Type switches on interfaces like ast.Node or types.Type are
+	// often exhaustive for the types the code cares about, which the
+	// analyzer can't tell.
+	typeswitchdefault.Analyzer,
+}
+
+// WithOptional returns the analyzers of Analyzers and Optional.
+func WithOptional() []*analysis.Analyzer {
+	return append(append([]*analysis.Analyzer(nil), Analyzers...), Optional...)
+}
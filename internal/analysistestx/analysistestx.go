@@ -0,0 +1,200 @@
+// Copyright 2019 Axel Wagner
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package analysistestx extends analysistest with checks for suggested
+// fixes, facts and analyzer flags. All analyzers in this repository use it
+// for their tests.
+package analysistestx
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/format"
+	"go/token"
+	"go/types"
+	"io/ioutil"
+	"os"
+	"sort"
+	"testing"
+
+	"github.com/Merovius/go-tools/internal/fix"
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/analysistest"
+)
+
+var update = flag.Bool("update", false, "update golden files of suggested fixes")
+
+// TestData returns the effective filename of the program's "testdata"
+// directory.
+func TestData() string {
+	return analysistest.TestData()
+}
+
+// Run runs a on the packages matching patterns in the GOPATH-style tree
+// dir and checks its diagnostics and facts against the "// want" comments,
+// like analysistest.Run.
+func Run(t *testing.T, dir string, a *analysis.Analyzer, patterns ...string) []*analysistest.Result {
+	t.Helper()
+	return analysistest.Run(t, dir, a, patterns...)
+}
+
+// RunWithSuggestedFixes is like Run, but additionally applies all suggested
+// fixes and compares every file with fixes to a golden file with the same
+// name and the suffix ".golden". The result is formatted with gofmt before
+// comparing. If the -update flag is given, golden files are written
+// instead.
+func RunWithSuggestedFixes(t *testing.T, dir string, a *analysis.Analyzer, patterns ...string) []*analysistest.Result {
+	t.Helper()
+	results := Run(t, dir, a, patterns...)
+	for _, r := range results {
+		if r.Pass == nil {
+			continue
+		}
+		if err := checkFixes(r.Pass.Fset, a, r.Diagnostics); err != nil {
+			t.Error(err)
+		}
+	}
+	return results
+}
+
+func checkFixes(fset *token.FileSet, a *analysis.Analyzer, diags []analysis.Diagnostic) error {
+	edits := make(map[string][]fix.Edit)
+	for _, d := range diags {
+		for _, sf := range d.SuggestedFixes {
+			for _, e := range fix.Resolve(fset, a, sf).Edits {
+				edits[e.Filename] = append(edits[e.Filename], e)
+			}
+		}
+	}
+	var names []string
+	for name := range edits {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		src, err := ioutil.ReadFile(name)
+		if err != nil {
+			return err
+		}
+		got, err := fix.ApplyEdits(src, edits[name])
+		if err != nil {
+			return fmt.Errorf("%s: %v", name, err)
+		}
+		if formatted, err := format.Source(got); err == nil {
+			got = formatted
+		}
+		golden := name + ".golden"
+		if *update {
+			if err := ioutil.WriteFile(golden, got, 0666); err != nil {
+				return err
+			}
+			continue
+		}
+		want, err := ioutil.ReadFile(golden)
+		if os.IsNotExist(err) {
+			return fmt.Errorf("%s: suggested fixes, but no golden file (run with -update to create it)", name)
+		}
+		if err != nil {
+			return err
+		}
+		if !bytes.Equal(got, want) {
+			return fmt.Errorf("%s: suggested fixes don't match %s:\n got:\n%s\nwant:\n%s", name, golden, got, want)
+		}
+	}
+	return nil
+}
+
+// A Case is a configuration of analyzer flags and the packages to test
+// with it.
+type Case struct {
+	Name     string
+	Flags    map[string]string
+	Patterns []string
+
+	// Fixes specifies whether suggested fixes should be checked against
+	// golden files.
+	Fixes bool
+}
+
+// RunMatrix runs one subtest per case, with the analyzer flags of the case
+// set. Flags are restored after each case. As flags are global state, the
+// cases are not run in parallel.
+func RunMatrix(t *testing.T, dir string, a *analysis.Analyzer, cases ...Case) {
+	t.Helper()
+	for _, c := range cases {
+		c := c
+		t.Run(c.Name, func(t *testing.T) {
+			restore, err := setFlags(a, c.Flags)
+			defer restore()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if c.Fixes {
+				RunWithSuggestedFixes(t, dir, a, c.Patterns...)
+			} else {
+				Run(t, dir, a, c.Patterns...)
+			}
+		})
+	}
+}
+
+func setFlags(a *analysis.Analyzer, flags map[string]string) (restore func(), err error) {
+	old := make(map[string]string)
+	restore = func() {
+		for name, v := range old {
+			a.Flags.Set(name, v)
+		}
+	}
+	for name, v := range flags {
+		f := a.Flags.Lookup(name)
+		if f == nil {
+			return restore, fmt.Errorf("analyzer %s has no flag %q", a.Name, name)
+		}
+		old[name] = f.Value.String()
+		if err := a.Flags.Set(name, v); err != nil {
+			return restore, err
+		}
+	}
+	return restore, nil
+}
+
+// Facts returns the string representation of the facts exported by the
+// analyzed packages in results, keyed by the name of the object they are
+// about. Functions and methods are named as by types.Func.FullName, other
+// objects as "path.Name" and package facts by the package path. This is
+// useful to check facts of objects which can't carry a "// want" comment,
+// or of several packages depending on each other.
+func Facts(results []*analysistest.Result) map[string][]string {
+	m := make(map[string][]string)
+	for _, r := range results {
+		for obj, facts := range r.Facts {
+			var key string
+			switch obj := obj.(type) {
+			case nil:
+				key = r.Pass.Pkg.Path()
+			case *types.Func:
+				key = obj.FullName()
+			default:
+				key = obj.Pkg().Path() + "." + obj.Name()
+			}
+			for _, f := range facts {
+				m[key] = append(m[key], fmt.Sprint(f))
+			}
+			sort.Strings(m[key])
+		}
+	}
+	return m
+}
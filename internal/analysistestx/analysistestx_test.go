@@ -0,0 +1,106 @@
+// Copyright 2019 Axel Wagner
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package analysistestx
+
+import (
+	"go/ast"
+	"reflect"
+	"testing"
+
+	"golang.org/x/tools/go/analysis"
+)
+
+type namedFact struct{}
+
+func (*namedFact) AFact() {}
+
+func (*namedFact) String() string { return "named" }
+
+// analyzer reports definitions of identifiers with a configurable name and
+// suggests renaming them.
+var analyzer = &analysis.Analyzer{
+	Name:      "named",
+	Doc:       "report definitions of identifiers with a given name",
+	Run:       run,
+	FactTypes: []analysis.Fact{new(namedFact)},
+}
+
+var name string
+
+func init() {
+	analyzer.Flags.StringVar(&name, "name", "foo", "name of identifiers to report")
+}
+
+func run(pass *analysis.Pass) (interface{}, error) {
+	for _, f := range pass.Files {
+		ast.Inspect(f, func(n ast.Node) bool {
+			id, ok := n.(*ast.Ident)
+			if !ok || id.Name != name {
+				return true
+			}
+			obj := pass.TypesInfo.Defs[id]
+			if obj == nil {
+				return true
+			}
+			if obj.Parent() == pass.Pkg.Scope() {
+				pass.ExportObjectFact(obj, new(namedFact))
+			}
+			pass.Report(analysis.Diagnostic{
+				Pos:     id.Pos(),
+				End:     id.End(),
+				Message: "identifier " + name,
+				SuggestedFixes: []analysis.SuggestedFix{{
+					Message: "Rename to renamed",
+					TextEdits: []analysis.TextEdit{{
+						Pos:     id.Pos(),
+						End:     id.End(),
+						NewText: []byte("renamed"),
+					}},
+				}},
+			})
+			return true
+		})
+	}
+	return nil, nil
+}
+
+func TestRunWithSuggestedFixes(t *testing.T) {
+	RunWithSuggestedFixes(t, TestData(), analyzer, "fix")
+}
+
+func TestRunMatrix(t *testing.T) {
+	RunMatrix(t, TestData(), analyzer,
+		Case{Name: "default", Patterns: []string{"fix"}, Fixes: true},
+		Case{Name: "bar", Flags: map[string]string{"name": "bar"}, Patterns: []string{"flags"}},
+	)
+	if name != "foo" {
+		t.Errorf("RunMatrix did not restore flag: name = %q", name)
+	}
+}
+
+func TestFacts(t *testing.T) {
+	results := Run(t, TestData(), analyzer, "facts")
+	got := Facts(results)
+	want := map[string][]string{
+		"facts.foo":     {"named"},
+		"facts.T.foo":   nil,
+		"facts.foo.foo": nil,
+	}
+	for k, v := range want {
+		if !reflect.DeepEqual(got[k], v) {
+			t.Errorf("Facts()[%q] = %v, want %v", k, got[k], v)
+		}
+	}
+}
@@ -0,0 +1,286 @@
+// Copyright 2019 Axel Wagner
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package analysisutil contains helpers shared by the analyzers of this
+// repository, mostly to identify the functions and types used by code.
+//
+// Functions and types are identified by the names produced by FuncName and
+// TypeName, which follow types.Func.FullName, but without the vendor
+// prefixes of import paths, so that vendored copies of packages are
+// recognized as well.
+package analysisutil
+
+import (
+	"bytes"
+	"go/ast"
+	"go/build"
+	"go/format"
+	"go/token"
+	"go/types"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"unicode"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/types/typeutil"
+)
+
+// TrimVendor removes the vendor prefix of an import path, if any.
+func TrimVendor(path string) string {
+	if i := strings.LastIndex(path, "/vendor/"); i >= 0 {
+		return path[i+len("/vendor/"):]
+	}
+	return strings.TrimPrefix(path, "vendor/")
+}
+
+func qualifier(p *types.Package) string {
+	return TrimVendor(p.Path())
+}
+
+// FuncName returns the full name of fn, like "os.Open" or
+// "(*net/http.Request).Context".
+func FuncName(fn *types.Func) string {
+	if recv := fn.Type().(*types.Signature).Recv(); recv != nil {
+		return "(" + types.TypeString(recv.Type(), qualifier) + ")." + fn.Name()
+	}
+	if fn.Pkg() == nil {
+		return fn.Name()
+	}
+	return qualifier(fn.Pkg()) + "." + fn.Name()
+}
+
+// Callee returns the function or method called by call, or nil for calls
+// of function values, conversions and builtins.
+func Callee(info *types.Info, call *ast.CallExpr) *types.Func {
+	fn, _ := typeutil.Callee(info, call).(*types.Func)
+	return fn
+}
+
+// CalleeName returns the full name of the function or method called by
+// call, or "" if it doesn't call one.
+func CalleeName(info *types.Info, call *ast.CallExpr) string {
+	if fn := Callee(info, call); fn != nil {
+		return FuncName(fn)
+	}
+	return ""
+}
+
+// IsCall returns whether call calls one of the functions with the given
+// full names.
+func IsCall(info *types.Info, call *ast.CallExpr, names ...string) bool {
+	name := CalleeName(info, call)
+	if name == "" {
+		return false
+	}
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+// TypeName returns the name of the named type t, or of the named type t
+// points to, like "net/http.Request". It returns "" for other types.
+func TypeName(t types.Type) string {
+	if p, ok := t.(*types.Pointer); ok {
+		t = p.Elem()
+	}
+	n, ok := t.(*types.Named)
+	if !ok {
+		return ""
+	}
+	obj := n.Obj()
+	if obj.Pkg() == nil {
+		return obj.Name()
+	}
+	return qualifier(obj.Pkg()) + "." + obj.Name()
+}
+
+// IsType returns whether t is the named type name (and not a pointer to
+// it).
+func IsType(t types.Type, name string) bool {
+	_, ok := t.(*types.Named)
+	return ok && TypeName(t) == name
+}
+
+// IsPointerTo returns whether t is a pointer to the named type name.
+func IsPointerTo(t types.Type, name string) bool {
+	p, ok := t.(*types.Pointer)
+	return ok && IsType(p.Elem(), name)
+}
+
+// Imports returns whether pkg directly imports the package with the given
+// path. Analyzers use it to skip packages which can't contain findings.
+func Imports(pkg *types.Package, path string) bool {
+	for _, imp := range pkg.Imports() {
+		if TrimVendor(imp.Path()) == path {
+			return true
+		}
+	}
+	return false
+}
+
+var stdPackages sync.Map // import path to whether it is in the standard library
+
+// IsStd returns whether path is a package of the standard library. Local
+// packages, like those of analyzer tests, have paths without a dot as well,
+// so it checks whether the package exists in GOROOT.
+func IsStd(path string) bool {
+	if strings.Contains(strings.SplitN(path, "/", 2)[0], ".") {
+		return false
+	}
+	if std, ok := stdPackages.Load(path); ok {
+		return std.(bool)
+	}
+	fi, err := os.Stat(filepath.Join(build.Default.GOROOT, "src", filepath.FromSlash(path)))
+	std := err == nil && fi.IsDir()
+	stdPackages.Store(path, std)
+	return std
+}
+
+// MatchPackages returns whether the import path matches one of the
+// comma-separated patterns, as accepted by flags of analyzers. Patterns
+// ending in /... match subpackages as well.
+func MatchPackages(path, patterns string) bool {
+	for _, p := range strings.Split(patterns, ",") {
+		p = strings.TrimSpace(p)
+		if p == path {
+			return true
+		}
+		if strings.HasSuffix(p, "/...") {
+			p = strings.TrimSuffix(p, "/...")
+			if path == p || strings.HasPrefix(path, p+"/") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Words splits an identifier in camel case or with underscores into its
+// lower case words.
+func Words(name string) []string {
+	var (
+		ws  []string
+		cur []rune
+	)
+	flush := func() {
+		if len(cur) > 0 {
+			ws = append(ws, strings.ToLower(string(cur)))
+			cur = nil
+		}
+	}
+	rs := []rune(name)
+	for i, r := range rs {
+		switch {
+		case r == '_':
+			flush()
+			continue
+		case unicode.IsUpper(r) && i > 0 && (unicode.IsLower(rs[i-1]) || i+1 < len(rs) && unicode.IsLower(rs[i+1])):
+			flush()
+		}
+		cur = append(cur, r)
+	}
+	flush()
+	return ws
+}
+
+// IsTestFile returns whether the file containing pos is a test file.
+func IsTestFile(pass *analysis.Pass, pos token.Pos) bool {
+	return strings.HasSuffix(pass.Fset.File(pos).Name(), "_test.go")
+}
+
+// File returns the file of pass containing pos, or nil.
+func File(pass *analysis.Pass, pos token.Pos) *ast.File {
+	for _, f := range pass.Files {
+		if f.Pos() <= pos && pos <= f.End() {
+			return f
+		}
+	}
+	return nil
+}
+
+// IsGenerated returns whether pos is in a generated file, as marked by a
+// "Code generated ... DO NOT EDIT." comment.
+func IsGenerated(pass *analysis.Pass, pos token.Pos) bool {
+	f := File(pass, pos)
+	if f == nil {
+		return false
+	}
+	for _, cg := range f.Comments {
+		if cg.Pos() > f.Package {
+			break
+		}
+		for _, c := range cg.List {
+			if strings.HasPrefix(c.Text, "// Code generated ") && strings.HasSuffix(c.Text, " DO NOT EDIT.") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Render returns the source of n, formatted with gofmt.
+func Render(fset *token.FileSet, n ast.Node) string {
+	var buf bytes.Buffer
+	if err := format.Node(&buf, fset, n); err != nil {
+		return ""
+	}
+	return buf.String()
+}
+
+// Unparen returns e with any enclosing parentheses removed.
+func Unparen(e ast.Expr) ast.Expr {
+	for {
+		p, ok := e.(*ast.ParenExpr)
+		if !ok {
+			return e
+		}
+		e = p.X
+	}
+}
+
+// ObjectOf returns the object denoted by the identifier e, or nil if e is
+// not an identifier.
+func ObjectOf(info *types.Info, e ast.Expr) types.Object {
+	id, ok := Unparen(e).(*ast.Ident)
+	if !ok {
+		return nil
+	}
+	return info.ObjectOf(id)
+}
+
+// IsConst returns whether e is a constant or nil.
+func IsConst(info *types.Info, e ast.Expr) bool {
+	tv, ok := info.Types[e]
+	return ok && (tv.Value != nil || tv.IsNil())
+}
+
+// EnclosingFunc returns the type and body of the innermost function
+// declaration or literal in stack, as recorded by nodefilter or
+// inspector.WithStack. It returns nil if there is none.
+func EnclosingFunc(stack []ast.Node) (*ast.FuncType, *ast.BlockStmt) {
+	for i := len(stack) - 1; i >= 0; i-- {
+		switch n := stack[i].(type) {
+		case *ast.FuncDecl:
+			return n.Type, n.Body
+		case *ast.FuncLit:
+			return n.Type, n.Body
+		}
+	}
+	return nil, nil
+}
@@ -0,0 +1,150 @@
+// Copyright 2019 Axel Wagner
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package analysisutil
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"reflect"
+	"testing"
+)
+
+const src = `package p
+
+type T struct{}
+
+func (T) M()  {}
+func (*T) P() {}
+
+type I interface{ N() }
+
+func F() {}
+
+func calls(t T, i I, f func()) {
+	F()
+	t.M()
+	t.P()
+	i.N()
+	f()
+	_ = len("")
+	_ = int(1)
+}
+`
+
+func TestCalleeName(t *testing.T) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "p.go", src, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info := &types.Info{
+		Types:      make(map[ast.Expr]types.TypeAndValue),
+		Uses:       make(map[*ast.Ident]types.Object),
+		Selections: make(map[*ast.SelectorExpr]*types.Selection),
+	}
+	pkg, err := new(types.Config).Check("example.com/vendor/x/p", fset, []*ast.File{f}, info)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	ast.Inspect(f, func(n ast.Node) bool {
+		if call, ok := n.(*ast.CallExpr); ok {
+			got = append(got, CalleeName(info, call))
+		}
+		return true
+	})
+	want := []string{"x/p.F", "(x/p.T).M", "(*x/p.T).P", "(x/p.I).N", "", "", ""}
+	if len(got) != len(want) {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("call %d: got %q, want %q", i, got[i], want[i])
+		}
+	}
+
+	T := pkg.Scope().Lookup("T").Type()
+	if !IsType(T, "x/p.T") || IsType(types.NewPointer(T), "x/p.T") {
+		t.Error("IsType is wrong")
+	}
+	if !IsPointerTo(types.NewPointer(T), "x/p.T") || IsPointerTo(T, "x/p.T") {
+		t.Error("IsPointerTo is wrong")
+	}
+}
+
+func TestTrimVendor(t *testing.T) {
+	for in, want := range map[string]string{
+		"github.com/a/b":                      "github.com/a/b",
+		"example.com/m/vendor/github.com/a/b": "github.com/a/b",
+		"vendor/golang.org/x/net/http2":       "golang.org/x/net/http2",
+	} {
+		if got := TrimVendor(in); got != want {
+			t.Errorf("TrimVendor(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestMatchPackages(t *testing.T) {
+	tcs := []struct {
+		path     string
+		patterns string
+		want     bool
+	}{
+		{"example.com/config", "example.com/config", true},
+		{"example.com/config/env", "example.com/config", false},
+		{"example.com/config/env", "example.com/config/...", true},
+		{"example.com/config", "example.com/x, example.com/config/...", true},
+		{"example.com/configs", "example.com/config/...", false},
+	}
+	for _, tc := range tcs {
+		if got := MatchPackages(tc.path, tc.patterns); got != tc.want {
+			t.Errorf("MatchPackages(%q, %q) = %v, want %v", tc.path, tc.patterns, got, tc.want)
+		}
+	}
+}
+
+func TestIsStd(t *testing.T) {
+	for path, want := range map[string]bool{
+		"fmt":                  true,
+		"net/http":             true,
+		"a":                    false,
+		"example.com/fmt":      false,
+		"github.com/user/repo": false,
+	} {
+		if got := IsStd(path); got != want {
+			t.Errorf("IsStd(%q) = %v, want %v", path, got, want)
+		}
+	}
+}
+
+func TestWords(t *testing.T) {
+	tcs := []struct {
+		name string
+		want []string
+	}{
+		{"timeout", []string{"timeout"}},
+		{"timeoutSecs", []string{"timeout", "secs"}},
+		{"TimeoutMS", []string{"timeout", "ms"}},
+		{"delay_ms", []string{"delay", "ms"}},
+		{"HTTPTimeoutSeconds", []string{"http", "timeout", "seconds"}},
+	}
+	for _, tc := range tcs {
+		if got := Words(tc.name); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("Words(%q) = %q, want %q", tc.name, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+// Copyright 2019 Axel Wagner
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build go1.18
+// +build go1.18
+
+package analysisutil
+
+import "go/types"
+
+// HasTypeParam returns whether t is or contains a type parameter. The
+// layout of such types depends on their instantiation, so neither their
+// size nor, for example, their comparability is known.
+func HasTypeParam(t types.Type) bool {
+	return hasTypeParam(t, make(map[types.Type]bool))
+}
+
+func hasTypeParam(t types.Type, seen map[types.Type]bool) bool {
+	if seen[t] {
+		return false
+	}
+	seen[t] = true
+	switch t := t.(type) {
+	case *types.TypeParam:
+		return true
+	case *types.Named:
+		args := t.TypeArgs()
+		for i := 0; i < args.Len(); i++ {
+			if hasTypeParam(args.At(i), seen) {
+				return true
+			}
+		}
+		return hasTypeParam(t.Underlying(), seen)
+	case *types.Pointer:
+		return hasTypeParam(t.Elem(), seen)
+	case *types.Slice:
+		return hasTypeParam(t.Elem(), seen)
+	case *types.Array:
+		return hasTypeParam(t.Elem(), seen)
+	case *types.Chan:
+		return hasTypeParam(t.Elem(), seen)
+	case *types.Map:
+		return hasTypeParam(t.Key(), seen) || hasTypeParam(t.Elem(), seen)
+	case *types.Struct:
+		for i := 0; i < t.NumFields(); i++ {
+			if hasTypeParam(t.Field(i).Type(), seen) {
+				return true
+			}
+		}
+	case *types.Tuple:
+		for i := 0; i < t.Len(); i++ {
+			if hasTypeParam(t.At(i).Type(), seen) {
+				return true
+			}
+		}
+	case *types.Signature:
+		return hasTypeParam(t.Params(), seen) || hasTypeParam(t.Results(), seen)
+	case *types.Interface:
+		for i := 0; i < t.NumMethods(); i++ {
+			if hasTypeParam(t.Method(i).Type(), seen) {
+				return true
+			}
+		}
+	case *types.Basic:
+	default:
+		// Aliases.
+		return hasTypeParam(t.Underlying(), seen)
+	}
+	return false
+}
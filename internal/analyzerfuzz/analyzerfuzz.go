@@ -0,0 +1,294 @@
+// Copyright 2019 Axel Wagner
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package analyzerfuzz feeds adversarial, but valid, Go programs to
+// analyzers, to find crashes. Programs are derived from seed sources by
+// random mutations of their syntax tree, which exercise control flow
+// constructs in unusual combinations.
+//
+// The package provides a Fuzz function for go-fuzz (with the gofuzz build
+// tag) and a native fuzz target for "go test -fuzz" (with Go 1.18 or
+// later).
+package analyzerfuzz
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/format"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"strings"
+	"sync"
+
+	"golang.org/x/tools/go/analysis"
+)
+
+// Mutate parses src and applies between one and four random mutations,
+// determined by seed. The result is syntactically valid, but might not
+// type-check.
+func Mutate(src []byte, seed int64) ([]byte, error) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "fuzz.go", src, parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+	m := &mutator{rnd: rand.New(rand.NewSource(seed))}
+	for _, d := range f.Decls {
+		if fd, ok := d.(*ast.FuncDecl); ok && fd.Body != nil {
+			m.stmts(&fd.Body.List, false, false)
+		}
+	}
+	if len(m.sites) > 0 {
+		for n := m.rnd.Intn(4) + 1; n > 0; n-- {
+			m.mutate(m.sites[m.rnd.Intn(len(m.sites))])
+		}
+	}
+	// Comments would get attached to the wrong nodes.
+	f.Comments = nil
+	buf := new(bytes.Buffer)
+	if err := format.Node(buf, fset, f); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// A site is a statement list, which can be mutated.
+type site struct {
+	list *[]ast.Stmt
+	// loop and brk specify whether continue and break statements are valid
+	// in the list.
+	loop, brk bool
+}
+
+type mutator struct {
+	rnd    *rand.Rand
+	sites  []site
+	labels int
+}
+
+func (m *mutator) stmts(list *[]ast.Stmt, loop, brk bool) {
+	m.sites = append(m.sites, site{list, loop, brk})
+	for _, s := range *list {
+		m.stmt(s, loop, brk)
+	}
+}
+
+func (m *mutator) stmt(s ast.Stmt, loop, brk bool) {
+	switch s := s.(type) {
+	case *ast.BlockStmt:
+		m.stmts(&s.List, loop, brk)
+	case *ast.IfStmt:
+		m.stmts(&s.Body.List, loop, brk)
+		if s.Else != nil {
+			m.stmt(s.Else, loop, brk)
+		}
+	case *ast.ForStmt:
+		m.stmts(&s.Body.List, true, true)
+	case *ast.RangeStmt:
+		m.stmts(&s.Body.List, true, true)
+	case *ast.SwitchStmt:
+		for _, c := range s.Body.List {
+			m.stmts(&c.(*ast.CaseClause).Body, loop, true)
+		}
+	case *ast.TypeSwitchStmt:
+		for _, c := range s.Body.List {
+			m.stmts(&c.(*ast.CaseClause).Body, loop, true)
+		}
+	case *ast.SelectStmt:
+		for _, c := range s.Body.List {
+			m.stmts(&c.(*ast.CommClause).Body, loop, true)
+		}
+	case *ast.LabeledStmt:
+		m.stmt(s.Stmt, loop, brk)
+	}
+}
+
+func (m *mutator) label() *ast.Ident {
+	m.labels++
+	return ast.NewIdent(fmt.Sprintf("fuzzL%d", m.labels))
+}
+
+func (m *mutator) mutate(s site) {
+	list := *s.list
+	i := m.rnd.Intn(len(list) + 1)
+	insert := func(sts ...ast.Stmt) {
+		out := append([]ast.Stmt(nil), list[:i]...)
+		out = append(out, sts...)
+		*s.list = append(out, list[i:]...)
+	}
+	replace := func(st ast.Stmt) {
+		out := append([]ast.Stmt(nil), list...)
+		out[i] = st
+		*s.list = out
+	}
+	block := func(sts ...ast.Stmt) *ast.BlockStmt {
+		return &ast.BlockStmt{List: sts}
+	}
+
+	switch m.rnd.Intn(8) {
+	case 0:
+		if s.brk {
+			insert(&ast.BranchStmt{Tok: token.BREAK})
+		}
+	case 1:
+		if s.loop {
+			insert(&ast.BranchStmt{Tok: token.CONTINUE})
+		}
+	case 2:
+		// goto L; L: stmt
+		if i < len(list) {
+			l := m.label()
+			replace(&ast.LabeledStmt{Label: l, Stmt: list[i]})
+			list = *s.list
+			insert(&ast.BranchStmt{Tok: token.GOTO, Label: l})
+		}
+	case 3:
+		// for { stmt; break }
+		if i < len(list) {
+			replace(&ast.ForStmt{Body: block(list[i], &ast.BranchStmt{Tok: token.BREAK})})
+		}
+	case 4:
+		// L: for { stmt; break L }
+		if i < len(list) {
+			l := m.label()
+			replace(&ast.LabeledStmt{Label: l, Stmt: &ast.ForStmt{
+				Body: block(list[i], &ast.BranchStmt{Tok: token.BREAK, Label: l}),
+			}})
+		}
+	case 5:
+		// switch { case true: stmt }
+		if i < len(list) {
+			replace(&ast.SwitchStmt{Body: block(&ast.CaseClause{
+				List: []ast.Expr{ast.NewIdent("true")},
+				Body: []ast.Stmt{list[i]},
+			})})
+		}
+	case 6:
+		// select { default: stmt }
+		if i < len(list) {
+			replace(&ast.SelectStmt{Body: block(&ast.CommClause{
+				Body: []ast.Stmt{list[i]},
+			})})
+		}
+	case 7:
+		// Duplicate the statement.
+		if i < len(list) {
+			insert(list[i])
+		}
+	}
+}
+
+var (
+	importMu sync.Mutex
+	imp      types.Importer
+)
+
+// stdImporter imports only packages from the standard library. Fuzzed
+// import paths would otherwise make the source importer search for
+// modules, which is slow and depends on the environment.
+type stdImporter struct {
+	types.Importer
+}
+
+func (imp stdImporter) Import(path string) (*types.Package, error) {
+	if path == "C" || path == "unsafe" {
+		return imp.Importer.Import(path)
+	}
+	fi, err := os.Stat(filepath.Join(build.Default.GOROOT, "src", filepath.FromSlash(path)))
+	if err != nil || !fi.IsDir() || strings.Contains(path, "internal") {
+		return nil, fmt.Errorf("can't import %q: only non-internal standard library packages are supported", path)
+	}
+	return imp.Importer.Import(path)
+}
+
+// Check type-checks src and runs a and its requirements on it. Programs
+// that don't parse or type-check are ignored, as drivers don't run
+// analyzers on them either. It returns an error, if an analyzer panics or
+// fails.
+func Check(a *analysis.Analyzer, src []byte) (err error) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "fuzz.go", src, parser.ParseComments)
+	if err != nil {
+		return nil
+	}
+
+	importMu.Lock()
+	defer importMu.Unlock()
+	if imp == nil {
+		imp = stdImporter{importer.ForCompiler(fset, "source", nil)}
+	}
+	info := &types.Info{
+		Types:      make(map[ast.Expr]types.TypeAndValue),
+		Defs:       make(map[*ast.Ident]types.Object),
+		Uses:       make(map[*ast.Ident]types.Object),
+		Implicits:  make(map[ast.Node]types.Object),
+		Selections: make(map[*ast.SelectorExpr]*types.Selection),
+		Scopes:     make(map[ast.Node]*types.Scope),
+	}
+	recordInstances(info)
+	conf := &types.Config{Importer: imp}
+	pkg, err := conf.Check(f.Name.Name, fset, []*ast.File{f}, info)
+	if err != nil {
+		return nil
+	}
+
+	defer func() {
+		if v := recover(); v != nil {
+			err = fmt.Errorf("analyzer %s panicked: %v\n%s\nsource:\n%s", a.Name, v, debug.Stack(), src)
+		}
+	}()
+	results := make(map[*analysis.Analyzer]interface{})
+	var run func(a *analysis.Analyzer) error
+	run = func(a *analysis.Analyzer) error {
+		if _, ok := results[a]; ok {
+			return nil
+		}
+		for _, req := range a.Requires {
+			if err := run(req); err != nil {
+				return err
+			}
+		}
+		pass := &analysis.Pass{
+			Analyzer:          a,
+			Fset:              fset,
+			Files:             []*ast.File{f},
+			Pkg:               pkg,
+			TypesInfo:         info,
+			TypesSizes:        types.SizesFor("gc", "amd64"),
+			ResultOf:          results,
+			Report:            func(analysis.Diagnostic) {},
+			ImportObjectFact:  func(types.Object, analysis.Fact) bool { return false },
+			ExportObjectFact:  func(types.Object, analysis.Fact) {},
+			ImportPackageFact: func(*types.Package, analysis.Fact) bool { return false },
+			ExportPackageFact: func(analysis.Fact) {},
+			AllObjectFacts:    func() []analysis.ObjectFact { return nil },
+			AllPackageFacts:   func() []analysis.PackageFact { return nil },
+		}
+		res, err := a.Run(pass)
+		if err != nil {
+			return fmt.Errorf("analyzer %s failed: %v", a.Name, err)
+		}
+		results[a] = res
+		return nil
+	}
+	return run(a)
+}
@@ -0,0 +1,130 @@
+// Copyright 2019 Axel Wagner
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package analyzerfuzz
+
+import (
+	"flag"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/Merovius/go-tools/internal/all"
+	"golang.org/x/tools/go/analysis"
+)
+
+// seeds returns the test data of all analyzers, to use as seed corpus.
+func seeds(t testing.TB) map[string][]byte {
+	files, err := filepath.Glob(filepath.Join("..", "..", "*", "testdata", "src", "*", "*.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := make(map[string][]byte)
+	for _, f := range files {
+		b, err := ioutil.ReadFile(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		out[f] = b
+	}
+	if len(out) == 0 {
+		t.Fatal("no seed files found")
+	}
+	return out
+}
+
+func TestMutate(t *testing.T) {
+	for name, src := range seeds(t) {
+		for seed := int64(0); seed < 20; seed++ {
+			out, err := Mutate(src, seed)
+			if err != nil {
+				t.Fatalf("Mutate(%s, %d): %v", name, seed, err)
+			}
+			if _, err := parser.ParseFile(token.NewFileSet(), "fuzz.go", out, 0); err != nil {
+				t.Fatalf("Mutate(%s, %d) is invalid: %v\n%s", name, seed, err, out)
+			}
+		}
+	}
+}
+
+func TestCheckPanic(t *testing.T) {
+	a := &analysis.Analyzer{
+		Name: "panics",
+		Doc:  "panics",
+		Run: func(*analysis.Pass) (interface{}, error) {
+			panic("boom")
+		},
+	}
+	err := Check(a, []byte("package p\n"))
+	if err == nil || !strings.Contains(err.Error(), "boom") {
+		t.Errorf("Check(panicking analyzer) = %v, want panic error", err)
+	}
+	if err := Check(a, []byte("package p\nvar x int = \"\"\n")); err != nil {
+		t.Errorf("Check(ill-typed program) = %v, want nil", err)
+	}
+}
+
+var full = flag.Bool("full", false, "run TestSeeds on all mutations of all seed files instead of a sample")
+
+// sampleSize is the number of mutated seed files TestSeeds checks without
+// -full. Checking all of them takes several minutes.
+const sampleSize = 40
+
+// TestSeeds runs a fixed number of mutations of the seed corpus, so that
+// regressions are caught without fuzzing. By default, it checks a sample
+// spread over the seed files and mutations; -full checks all of them.
+func TestSeeds(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping in short mode")
+	}
+	srcs := seeds(t)
+	names := make([]string, 0, len(srcs))
+	for name := range srcs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	type mutation struct {
+		name string
+		seed int64
+	}
+	var muts []mutation
+	if *full || len(names)*20 <= sampleSize {
+		for _, name := range names {
+			for seed := int64(0); seed < 20; seed++ {
+				muts = append(muts, mutation{name, seed})
+			}
+		}
+	} else {
+		// Files spread over the corpus, each with another seed.
+		for i := 0; i < sampleSize; i++ {
+			muts = append(muts, mutation{names[i*len(names)/sampleSize], int64(i % 20)})
+		}
+	}
+	for _, m := range muts {
+		out, err := Mutate(srcs[m.name], m.seed)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, a := range all.WithOptional() {
+			if err := Check(a, out); err != nil {
+				t.Errorf("%s, seed %d: %v", m.name, m.seed, err)
+			}
+		}
+	}
+}
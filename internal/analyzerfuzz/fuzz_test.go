@@ -0,0 +1,41 @@
+// Copyright 2019 Axel Wagner
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build go1.18
+// +build go1.18
+
+package analyzerfuzz
+
+import (
+	"testing"
+
+	"github.com/Merovius/go-tools/internal/all"
+)
+
+func FuzzAnalyzers(f *testing.F) {
+	for _, src := range seeds(f) {
+		f.Add(src, int64(0))
+	}
+	f.Fuzz(func(t *testing.T, src []byte, seed int64) {
+		out, err := Mutate(src, seed)
+		if err != nil {
+			return
+		}
+		for _, a := range all.WithOptional() {
+			if err := Check(a, out); err != nil {
+				t.Fatal(err)
+			}
+		}
+	})
+}
@@ -0,0 +1,42 @@
+// Copyright 2019 Axel Wagner
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build gofuzz
+// +build gofuzz
+
+package analyzerfuzz
+
+import (
+	"encoding/binary"
+
+	"github.com/Merovius/go-tools/internal/all"
+)
+
+// Fuzz is the entry point for go-fuzz. The first eight bytes of data seed
+// the mutations, the rest is the source to mutate.
+func Fuzz(data []byte) int {
+	if len(data) < 8 {
+		return -1
+	}
+	src, err := Mutate(data[8:], int64(binary.LittleEndian.Uint64(data)))
+	if err != nil {
+		return -1
+	}
+	for _, a := range all.WithOptional() {
+		if err := Check(a, src); err != nil {
+			panic(err)
+		}
+	}
+	return 1
+}
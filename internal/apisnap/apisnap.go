@@ -0,0 +1,161 @@
+// Copyright 2019 Axel Wagner
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package apisnap reads and writes snapshots of the exported API of
+// packages, recording which identifiers a release provides. Analyzers use
+// them to tell identifiers added since the snapshot from existing ones.
+package apisnap
+
+import (
+	"bufio"
+	"fmt"
+	"go/types"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+// A Snapshot is the set of exported identifiers of some packages, keyed by
+// package path. Package-level identifiers are recorded by their name,
+// methods as Type.Method.
+//
+// Snapshots are written as text, one identifier per line, preceded by the
+// path of its package and separated from it by a space. Lines are sorted,
+// empty lines and lines starting with # are ignored. This makes them easy to
+// review in diffs when committed along with a release.
+type Snapshot map[string]map[string]bool
+
+// Public returns whether pkg is part of a public API, i.e. not a command
+// and not internal.
+func Public(pkg *types.Package) bool {
+	if pkg.Name() == "main" {
+		return false
+	}
+	for _, elem := range strings.Split(pkg.Path(), "/") {
+		if elem == "internal" {
+			return false
+		}
+	}
+	return true
+}
+
+// Names returns the sorted exported identifiers of pkg: its exported
+// package-level objects and the exported methods of its exported named
+// types.
+func Names(pkg *types.Package) []string {
+	var names []string
+	scope := pkg.Scope()
+	for _, name := range scope.Names() {
+		obj := scope.Lookup(name)
+		if !obj.Exported() {
+			continue
+		}
+		names = append(names, name)
+		tn, ok := obj.(*types.TypeName)
+		if !ok || tn.IsAlias() {
+			continue
+		}
+		named, ok := tn.Type().(*types.Named)
+		if !ok {
+			continue
+		}
+		for i := 0; i < named.NumMethods(); i++ {
+			if m := named.Method(i); m.Exported() {
+				names = append(names, name+"."+m.Name())
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Add records the exported identifiers of pkg.
+func (s Snapshot) Add(pkg *types.Package) {
+	m := s[pkg.Path()]
+	if m == nil {
+		m = make(map[string]bool)
+		s[pkg.Path()] = m
+	}
+	for _, name := range Names(pkg) {
+		m[name] = true
+	}
+}
+
+// Has returns whether s records the identifier name of the package path.
+func (s Snapshot) Has(path, name string) bool {
+	return s[path][name]
+}
+
+// HasPackage returns whether s records any identifier of the package path.
+// Packages not in the snapshot were added after it was taken.
+func (s Snapshot) HasPackage(path string) bool {
+	return len(s[path]) > 0
+}
+
+// Parse reads a snapshot from r.
+func Parse(r io.Reader) (Snapshot, error) {
+	s := make(Snapshot)
+	sc := bufio.NewScanner(r)
+	for n := 1; sc.Scan(); n++ {
+		l := strings.TrimSpace(sc.Text())
+		if l == "" || strings.HasPrefix(l, "#") {
+			continue
+		}
+		f := strings.Fields(l)
+		if len(f) != 2 {
+			return nil, fmt.Errorf("line %d: want package path and identifier, got %q", n, l)
+		}
+		m := s[f[0]]
+		if m == nil {
+			m = make(map[string]bool)
+			s[f[0]] = m
+		}
+		m[f[1]] = true
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Read reads the snapshot in the named file.
+func Read(name string) (Snapshot, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	s, err := Parse(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", name, err)
+	}
+	return s, nil
+}
+
+// Write writes s to w, in the format read by Parse.
+func (s Snapshot) Write(w io.Writer) error {
+	var lines []string
+	for path, names := range s {
+		for name := range names {
+			lines = append(lines, path+" "+name)
+		}
+	}
+	sort.Strings(lines)
+	bw := bufio.NewWriter(w)
+	for _, l := range lines {
+		fmt.Fprintln(bw, l)
+	}
+	return bw.Flush()
+}
@@ -0,0 +1,115 @@
+// Copyright 2019 Axel Wagner
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package apisnap
+
+import (
+	"bytes"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const src = `package p
+
+type T struct{}
+
+func (T) Exported()   {}
+func (T) unexported() {}
+func (*T) Pointer()   {}
+
+type t struct{}
+
+func (t) Hidden() {}
+
+type Alias = T
+
+const C = 1
+
+var V, v int
+
+func F() {}
+func f() {}
+`
+
+func check(t *testing.T) *types.Package {
+	t.Helper()
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "p.go", src, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkg, err := new(types.Config).Check("example.com/p", fset, []*ast.File{f}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pkg
+}
+
+func TestNames(t *testing.T) {
+	got := Names(check(t))
+	want := []string{"Alias", "C", "F", "T", "T.Exported", "T.Pointer", "V"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Names() = %q, want %q", got, want)
+	}
+}
+
+func TestPublic(t *testing.T) {
+	for _, tc := range []struct {
+		path, name string
+		want       bool
+	}{
+		{"example.com/p", "p", true},
+		{"example.com/internal/p", "p", false},
+		{"example.com/p/internal", "internal", false},
+		{"example.com/internals", "internals", true},
+		{"example.com/cmd/p", "main", false},
+	} {
+		if got := Public(types.NewPackage(tc.path, tc.name)); got != tc.want {
+			t.Errorf("Public(%s) = %v, want %v", tc.path, got, tc.want)
+		}
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	s := make(Snapshot)
+	s.Add(check(t))
+	var buf bytes.Buffer
+	if err := s.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(buf.String(), "example.com/p Alias\nexample.com/p C\n") {
+		t.Errorf("Write wrote\n%s", buf.String())
+	}
+	got, err := Parse(strings.NewReader("# API of v1.0\n\n" + buf.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, s) {
+		t.Errorf("Parse(Write(s)) = %v, want %v", got, s)
+	}
+	if !got.Has("example.com/p", "T.Pointer") || got.Has("example.com/p", "f") || !got.HasPackage("example.com/p") || got.HasPackage("example.com/q") {
+		t.Errorf("lookups in %v are wrong", got)
+	}
+}
+
+func TestParseError(t *testing.T) {
+	if _, err := Parse(strings.NewReader("example.com/p\n")); err == nil || !strings.Contains(err.Error(), "line 1") {
+		t.Errorf("Parse of a line without identifier: err = %v, want error on line 1", err)
+	}
+}
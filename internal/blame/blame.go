@@ -0,0 +1,181 @@
+// Copyright 2019 Axel Wagner
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package blame determines who last changed lines of files in a git
+// repository, and when.
+package blame
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Keys of the annotations returned by Line.Annotations.
+const (
+	// CommitAnnotation is the hash of the commit last changing the line.
+	CommitAnnotation = "commit"
+	// AuthorAnnotation is its author, like "Jane Doe <jane@example.com>".
+	AuthorAnnotation = "author"
+	// ModifiedAnnotation is the date of the change, like "2019-01-01".
+	ModifiedAnnotation = "modified"
+	// AgeAnnotation is the age of the change in days, like "365d".
+	// Uncommitted lines have age "0d".
+	AgeAnnotation = "age"
+)
+
+// A Line is the blame information of a single line.
+type Line struct {
+	Commit string
+	Author string
+	Email  string
+	Time   time.Time
+}
+
+// Committed returns whether the line is part of a commit. Uncommitted
+// lines have an all-zero commit hash.
+func (l Line) Committed() bool {
+	return strings.Trim(l.Commit, "0") != ""
+}
+
+// Annotations returns the annotations of diagnostics in the line, with its
+// age relative to now.
+func (l Line) Annotations(now time.Time) map[string]string {
+	if !l.Committed() {
+		return map[string]string{AgeAnnotation: "0d"}
+	}
+	return map[string]string{
+		CommitAnnotation:   l.Commit,
+		AuthorAnnotation:   fmt.Sprintf("%s <%s>", l.Author, l.Email),
+		ModifiedAnnotation: l.Time.UTC().Format("2006-01-02"),
+		AgeAnnotation:      fmt.Sprintf("%dd", days(now.Sub(l.Time))),
+	}
+}
+
+// A Blamer runs git blame on files and caches the results. It is safe for
+// concurrent use.
+type Blamer struct {
+	mu    sync.Mutex
+	files map[string][]Line
+	errs  map[string]error
+}
+
+// New returns a new Blamer.
+func New() *Blamer {
+	return &Blamer{files: make(map[string][]Line), errs: make(map[string]error)}
+}
+
+// Line returns the blame information of the given line of file. Line
+// numbers start at 1.
+func (b *Blamer) Line(file string, line int) (Line, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if err := b.errs[file]; err != nil {
+		return Line{}, err
+	}
+	lines, ok := b.files[file]
+	if !ok {
+		var err error
+		if lines, err = blame(file); err != nil {
+			// Don't run git again for every line of files it failed on,
+			// like files outside of a repository.
+			b.errs[file] = err
+			return Line{}, err
+		}
+		b.files[file] = lines
+	}
+	if line < 1 || line > len(lines) {
+		return Line{}, fmt.Errorf("%s:%d: no such line", file, line)
+	}
+	return lines[line-1], nil
+}
+
+// days returns the number of whole days in d, or 0 if it is negative.
+func days(d time.Duration) int {
+	if d < 0 {
+		return 0
+	}
+	return int(d / (24 * time.Hour))
+}
+
+func blame(file string) ([]Line, error) {
+	cmd := exec.Command("git", "blame", "--line-porcelain", "--", filepath.Base(file))
+	cmd.Dir = filepath.Dir(file)
+	stderr := new(bytes.Buffer)
+	cmd.Stderr = stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("git blame %s: %v: %s", file, err, strings.TrimSpace(stderr.String()))
+	}
+	return parse(bytes.NewReader(out))
+}
+
+// parse parses the output of "git blame --line-porcelain", which repeats
+// the full commit information for every line.
+func parse(r io.Reader) ([]Line, error) {
+	var (
+		lines []Line
+		cur   Line
+		start = true
+	)
+	s := bufio.NewScanner(r)
+	s.Buffer(nil, 1<<20)
+	for s.Scan() {
+		l := s.Text()
+		if strings.HasPrefix(l, "\t") {
+			// The content of the line ends its entry.
+			lines = append(lines, cur)
+			cur, start = Line{}, true
+			continue
+		}
+		if start {
+			fields := strings.Fields(l)
+			if len(fields) < 3 {
+				return nil, fmt.Errorf("invalid blame header %q", l)
+			}
+			cur.Commit, start = fields[0], false
+			continue
+		}
+		key, val := l, ""
+		if i := strings.IndexByte(l, ' '); i >= 0 {
+			key, val = l[:i], l[i+1:]
+		}
+		switch key {
+		case "author":
+			cur.Author = val
+		case "author-mail":
+			cur.Email = strings.TrimSuffix(strings.TrimPrefix(val, "<"), ">")
+		case "author-time":
+			sec, err := strconv.ParseInt(val, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid author-time %q", val)
+			}
+			cur.Time = time.Unix(sec, 0)
+		}
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	if !start {
+		return nil, fmt.Errorf("unexpected end of blame output")
+	}
+	return lines, nil
+}
@@ -0,0 +1,119 @@
+// Copyright 2019 Axel Wagner
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package blame
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+const porcelain = `1111111111111111111111111111111111111111 1 1 2
+author Jane Doe
+author-mail <jane@example.com>
+author-time 1546300800
+author-tz +0000
+committer Jane Doe
+committer-mail <jane@example.com>
+committer-time 1546300800
+committer-tz +0000
+summary Initial commit
+boundary
+filename a.go
+	package a
+1111111111111111111111111111111111111111 2 2
+author Jane Doe
+author-mail <jane@example.com>
+author-time 1546300800
+author-tz +0000
+committer Jane Doe
+committer-mail <jane@example.com>
+committer-time 1546300800
+committer-tz +0000
+summary Initial commit
+boundary
+filename a.go
+	
+0000000000000000000000000000000000000000 3 3 1
+author Not Committed Yet
+author-mail <not.committed.yet>
+author-time 1561939200
+author-tz +0000
+committer Not Committed Yet
+committer-mail <not.committed.yet>
+committer-time 1561939200
+committer-tz +0000
+summary Version of a.go from a.go
+previous 1111111111111111111111111111111111111111 a.go
+filename a.go
+	var x = 1
+`
+
+func TestParse(t *testing.T) {
+	got, err := parse(strings.NewReader(porcelain))
+	if err != nil {
+		t.Fatal(err)
+	}
+	jane := Line{
+		Commit: strings.Repeat("1", 40),
+		Author: "Jane Doe",
+		Email:  "jane@example.com",
+		Time:   time.Unix(1546300800, 0),
+	}
+	want := []Line{jane, jane, {
+		Commit: strings.Repeat("0", 40),
+		Author: "Not Committed Yet",
+		Email:  "not.committed.yet",
+		Time:   time.Unix(1561939200, 0),
+	}}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("parse() = %+v, want %+v", got, want)
+	}
+	if !got[0].Committed() || got[2].Committed() {
+		t.Errorf("Committed() = %v, %v, want true, false", got[0].Committed(), got[2].Committed())
+	}
+}
+
+func TestParseTruncated(t *testing.T) {
+	if _, err := parse(strings.NewReader(porcelain[:200])); err == nil {
+		t.Fatal("parse(truncated) succeeded, want error")
+	}
+}
+
+func TestAnnotations(t *testing.T) {
+	lines, err := parse(strings.NewReader(porcelain))
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Unix(1546300800, 0).Add(400*24*time.Hour + time.Hour)
+	want := map[string]string{
+		CommitAnnotation:   strings.Repeat("1", 40),
+		AuthorAnnotation:   "Jane Doe <jane@example.com>",
+		ModifiedAnnotation: "2019-01-01",
+		AgeAnnotation:      "400d",
+	}
+	if got := lines[0].Annotations(now); !reflect.DeepEqual(got, want) {
+		t.Errorf("Annotations() = %v, want %v", got, want)
+	}
+	want = map[string]string{AgeAnnotation: "0d"}
+	if got := lines[2].Annotations(now); !reflect.DeepEqual(got, want) {
+		t.Errorf("Annotations() of uncommitted line = %v, want %v", got, want)
+	}
+	// The clock being behind the commit doesn't result in negative ages.
+	if got := lines[0].Annotations(time.Unix(0, 0))[AgeAnnotation]; got != "0d" {
+		t.Errorf("age before commit = %q, want 0d", got)
+	}
+}
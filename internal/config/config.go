@@ -0,0 +1,138 @@
+// Copyright 2019 Axel Wagner
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package config implements the configuration file of gotools.
+//
+// The configuration is a JSON file named gotools.json, which applies to the
+// directory it is in and all directories below it. For example:
+//
+//	{
+//		"baseline": "gotools-baseline.json",
+//		"suppressions": {
+//			"expire_days": 90
+//		},
+//		"filter": "not path:**/*_test.go or severity:error",
+//		"targets": ["linux/amd64", "windows/amd64", "linux/arm64:netgo"],
+//		"plugins": ["tools/inhouse.so"],
+//		"flags": {
+//			"redundantbranch.allow-terminal-break": true
+//		}
+//	}
+//
+// A JSON Schema of the configuration is returned by Schema.
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// FileName is the name of configuration files.
+const FileName = "gotools.json"
+
+// Config is the configuration of gotools.
+type Config struct {
+	// Schema is the JSON Schema of the file, for editors. It is ignored.
+	Schema string `json:"$schema,omitempty"`
+
+	// Baseline is the file listing known findings, which are not
+	// reported. Relative paths are relative to the configuration file.
+	Baseline string `json:"baseline,omitempty"`
+
+	Suppressions Suppressions `json:"suppressions"`
+
+	// Filter is an expression selecting the diagnostics to report, as
+	// described in package filter.
+	Filter string `json:"filter,omitempty"`
+
+	// Targets lists the build configurations to analyze packages in, of
+	// the form goos/goarch[:tags], unless given on the command line.
+	Targets []string `json:"targets,omitempty"`
+
+	// Plugins lists Go plugins providing additional analyzers, as
+	// described in package driver. Relative paths are relative to the
+	// configuration file.
+	Plugins []string `json:"plugins,omitempty"`
+
+	// Flags sets analyzer flags, by their namespaced name of the form
+	// analyzer.flag. Values are JSON booleans, numbers or strings.
+	Flags map[string]json.RawMessage `json:"flags,omitempty"`
+
+	// Dir is the directory of the configuration file. Paths in the
+	// configuration are relative to it.
+	Dir string `json:"-"`
+}
+
+// Suppressions configures the handling of suppressed findings.
+type Suppressions struct {
+	// ExpireDays, if positive, is the number of days after which
+	// suppressions expire and their findings are reported again. The age
+	// of a suppression is determined with git blame.
+	ExpireDays int `json:"expire_days,omitempty"`
+}
+
+// Load reads the configuration file file.
+func Load(file string) (*Config, error) {
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.DisallowUnknownFields()
+	c := new(Config)
+	if err := dec.Decode(c); err != nil {
+		return nil, fmt.Errorf("%s: %v", file, err)
+	}
+	if c.Suppressions.ExpireDays < 0 {
+		return nil, fmt.Errorf("%s: negative suppressions.expire_days", file)
+	}
+	if c.Dir, err = filepath.Abs(filepath.Dir(file)); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Find looks for a configuration file in dir and its parents and loads the
+// first one found. If there is none, it returns an empty configuration for
+// dir.
+func Find(dir string) (*Config, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	for d := dir; ; {
+		file := filepath.Join(d, FileName)
+		if _, err := os.Stat(file); err == nil {
+			return Load(file)
+		}
+		parent := filepath.Dir(d)
+		if parent == d {
+			return &Config{Dir: dir}, nil
+		}
+		d = parent
+	}
+}
+
+// Path resolves p relative to the directory of the configuration. It
+// returns "" if p is empty.
+func (c *Config) Path(p string) string {
+	if p == "" || filepath.IsAbs(p) {
+		return p
+	}
+	return filepath.Join(c.Dir, p)
+}
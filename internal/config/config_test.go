@@ -0,0 +1,188 @@
+// Copyright 2019 Axel Wagner
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"encoding/json"
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"golang.org/x/tools/go/analysis"
+)
+
+func TestFind(t *testing.T) {
+	dir, err := ioutil.TempDir("", "config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	sub := filepath.Join(dir, "a", "b")
+	if err := os.MkdirAll(sub, 0777); err != nil {
+		t.Fatal(err)
+	}
+	src := `{"baseline": "base.json", "suppressions": {"expire_days": 30}, "targets": ["linux/amd64", "windows/386:netgo"], "plugins": ["tools/x.so"]}`
+	if err := ioutil.WriteFile(filepath.Join(dir, FileName), []byte(src), 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := Find(sub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Dir != dir {
+		t.Errorf("Dir = %q, want %q", c.Dir, dir)
+	}
+	if got, want := c.Path(c.Baseline), filepath.Join(dir, "base.json"); got != want {
+		t.Errorf("Path(Baseline) = %q, want %q", got, want)
+	}
+	if c.Suppressions.ExpireDays != 30 {
+		t.Errorf("ExpireDays = %d, want 30", c.Suppressions.ExpireDays)
+	}
+	if want := []string{"linux/amd64", "windows/386:netgo"}; !reflect.DeepEqual(c.Targets, want) {
+		t.Errorf("Targets = %q, want %q", c.Targets, want)
+	}
+	if want := []string{"tools/x.so"}; !reflect.DeepEqual(c.Plugins, want) {
+		t.Errorf("Plugins = %q, want %q", c.Plugins, want)
+	}
+}
+
+func TestLoadInvalid(t *testing.T) {
+	dir, err := ioutil.TempDir("", "config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for _, src := range []string{
+		`{"unknown": true}`,
+		`{"suppressions": {"expire_days": -1}}`,
+		`{`,
+	} {
+		file := filepath.Join(dir, FileName)
+		if err := ioutil.WriteFile(file, []byte(src), 0666); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := Load(file); err == nil {
+			t.Errorf("Load(%s) succeeded, want error", src)
+		}
+	}
+}
+
+func testAnalyzer() *analysis.Analyzer {
+	a := &analysis.Analyzer{Name: "test"}
+	a.Flags.Bool("strict", false, "be strict")
+	a.Flags.Int("max", 3, "maximum")
+	a.Flags.String("mode", "fast", "mode")
+	return a
+}
+
+func TestApplyFlags(t *testing.T) {
+	a := testAnalyzer()
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	tests := RegisterFlags(fs, []*analysis.Analyzer{a})
+	if err := fs.Parse([]string{"-test.max=5"}); err != nil {
+		t.Fatal(err)
+	}
+	c := &Config{Flags: map[string]json.RawMessage{
+		"test.strict": json.RawMessage(`true`),
+		"test.max":    json.RawMessage(`7`),
+		"test.mode":   json.RawMessage(`"slow"`),
+		"test.tests":  json.RawMessage(`false`),
+	}}
+	if err := c.ApplyFlags(fs); err != nil {
+		t.Fatal(err)
+	}
+	if skip := tests.Skip(); !skip["test"] || len(skip) != 1 {
+		t.Errorf("Skip() = %v, want test", skip)
+	}
+	want := map[string]string{
+		"strict": "true",
+		"max":    "5", // the command line takes precedence
+		"mode":   "slow",
+	}
+	for name, v := range want {
+		if got := a.Flags.Lookup(name).Value.String(); got != v {
+			t.Errorf("flag %s = %q, want %q", name, got, v)
+		}
+	}
+
+	for _, flags := range []map[string]json.RawMessage{
+		{"test.unknown": json.RawMessage(`1`)},
+		{"test.strict": json.RawMessage(`[]`)},
+		{"test.max": json.RawMessage(`"many"`)},
+	} {
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+		RegisterFlags(fs, []*analysis.Analyzer{a})
+		c := &Config{Flags: flags}
+		if err := c.ApplyFlags(fs); err == nil {
+			t.Errorf("ApplyFlags(%s) succeeded, want error", flags)
+		}
+	}
+}
+
+func TestSchema(t *testing.T) {
+	b, err := Schema([]*analysis.Analyzer{testAnalyzer()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var s struct {
+		Properties struct {
+			Flags struct {
+				Properties map[string]struct {
+					Type    string
+					Default interface{}
+				}
+			}
+		}
+	}
+	if err := json.Unmarshal(b, &s); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"test.strict": "boolean",
+		"test.max":    "integer",
+		"test.mode":   "string",
+		"test.tests":  "boolean",
+	}
+	got := s.Properties.Flags.Properties
+	if len(got) != len(want) {
+		t.Errorf("schema has flags %v, want %v", got, want)
+	}
+	for name, typ := range want {
+		if got[name].Type != typ {
+			t.Errorf("type of %s = %q, want %q", name, got[name].Type, typ)
+		}
+	}
+	if got["test.max"].Default != 3.0 {
+		t.Errorf("default of test.max = %v, want 3", got["test.max"].Default)
+	}
+}
+
+func TestResolveRule(t *testing.T) {
+	tcs := map[string]string{
+		"GT1001.allow-terminal-break":          "redundantbranch.allow-terminal-break",
+		"redundantbranch.allow-terminal-break": "redundantbranch.allow-terminal-break",
+		"GT9999.flag":                          "GT9999.flag",
+		"flag":                                 "flag",
+	}
+	for in, want := range tcs {
+		if got := resolveRule(in); got != want {
+			t.Errorf("resolveRule(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
@@ -0,0 +1,144 @@
+// Copyright 2019 Axel Wagner
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/Merovius/go-tools/internal/rules"
+	"golang.org/x/tools/go/analysis"
+)
+
+// FlagName returns the namespaced name of the flag f of a.
+func FlagName(a *analysis.Analyzer, f *flag.Flag) string {
+	return a.Name + "." + f.Name
+}
+
+// TestsFlag is the name of a flag every analyzer gets in addition to its own,
+// unless it has a flag with that name. Setting it to false drops the
+// diagnostics of the analyzer in test files, for example to only apply
+// correctness checks to tests, but not style checks.
+const TestsFlag = "tests"
+
+const testsUsage = "report diagnostics in test files"
+
+// Tests holds the values of the tests flags, by analyzer name.
+type Tests map[string]*bool
+
+// Skip returns the set of analyzers whose diagnostics in test files should
+// be dropped.
+func (t Tests) Skip() map[string]bool {
+	skip := make(map[string]bool)
+	for name, v := range t {
+		if !*v {
+			skip[name] = true
+		}
+	}
+	return skip
+}
+
+// hasTestsFlag returns whether the generic tests flag applies to a.
+func hasTestsFlag(a *analysis.Analyzer) bool {
+	return a.Flags.Lookup(TestsFlag) == nil
+}
+
+// RegisterFlags registers the flags of analyzers in fs, under their
+// namespaced names. Setting them sets the flags of the analyzers. It also
+// registers the tests flags of the analyzers and returns their values.
+func RegisterFlags(fs *flag.FlagSet, analyzers []*analysis.Analyzer) Tests {
+	tests := make(Tests)
+	for _, a := range analyzers {
+		a.Flags.VisitAll(func(f *flag.Flag) {
+			fs.Var(f.Value, FlagName(a, f), f.Usage)
+		})
+		if hasTestsFlag(a) {
+			tests[a.Name] = fs.Bool(a.Name+"."+TestsFlag, true, testsUsage)
+		}
+	}
+	return tests
+}
+
+// ApplyFlags sets the flags in fs configured in c, unless they were already
+// set on the command line. fs must have been parsed. In the configuration,
+// flags may also be namespaced by the ID of the rule of their analyzer, like
+// GT1001.allow-terminal-break.
+func (c *Config) ApplyFlags(fs *flag.FlagSet) error {
+	set := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) {
+		set[f.Name] = true
+	})
+	var names []string
+	for name := range c.Flags {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, cname := range names {
+		name := resolveRule(cname)
+		if fs.Lookup(name) == nil {
+			return fmt.Errorf("unknown flag %q in configuration", cname)
+		}
+		if set[name] {
+			continue
+		}
+		v, err := flagValue(c.Flags[cname])
+		if err != nil {
+			return fmt.Errorf("flag %q: %v", name, err)
+		}
+		if err := fs.Set(name, v); err != nil {
+			return fmt.Errorf("flag %q: %v", name, err)
+		}
+	}
+	return nil
+}
+
+// resolveRule replaces a rule ID used as the namespace of a flag name by
+// the name of its analyzer.
+func resolveRule(name string) string {
+	i := strings.IndexByte(name, '.')
+	if i < 0 || !rules.IsID(name[:i]) {
+		return name
+	}
+	r, ok := rules.ByID(name[:i])
+	if !ok {
+		return name
+	}
+	return r.Analyzer + name[i:]
+}
+
+// flagValue returns the string form of a JSON value, to be passed to
+// flag.Value.Set.
+func flagValue(raw json.RawMessage) (string, error) {
+	var v interface{}
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	if err := dec.Decode(&v); err != nil {
+		return "", err
+	}
+	switch v := v.(type) {
+	case string:
+		return v, nil
+	case bool:
+		return fmt.Sprint(v), nil
+	case json.Number:
+		return v.String(), nil
+	default:
+		return "", fmt.Errorf("invalid value %s, want boolean, number or string", raw)
+	}
+}
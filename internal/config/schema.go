@@ -0,0 +1,129 @@
+// Copyright 2019 Axel Wagner
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"encoding/json"
+	"flag"
+	"time"
+
+	"golang.org/x/tools/go/analysis"
+)
+
+// SchemaID is the identifier of the JSON Schema dialect used.
+const SchemaID = "http://json-schema.org/draft-07/schema#"
+
+// Schema returns a JSON Schema describing configuration files, including
+// the flags of analyzers. It can be used by editors for completion and
+// validation.
+func Schema(analyzers []*analysis.Analyzer) ([]byte, error) {
+	flags := make(map[string]interface{})
+	for _, a := range analyzers {
+		a.Flags.VisitAll(func(f *flag.Flag) {
+			flags[FlagName(a, f)] = flagSchema(f)
+		})
+		if hasTestsFlag(a) {
+			flags[a.Name+"."+TestsFlag] = map[string]interface{}{
+				"description": testsUsage,
+				"type":        "boolean",
+				"default":     true,
+			}
+		}
+	}
+	s := map[string]interface{}{
+		"$schema":              SchemaID,
+		"title":                "gotools configuration",
+		"type":                 "object",
+		"additionalProperties": false,
+		"properties": map[string]interface{}{
+			"$schema": map[string]interface{}{
+				"type": "string",
+			},
+			"baseline": map[string]interface{}{
+				"description": "File listing known findings, which are not reported, relative to the configuration file.",
+				"type":        "string",
+			},
+			"suppressions": map[string]interface{}{
+				"type":                 "object",
+				"additionalProperties": false,
+				"properties": map[string]interface{}{
+					"expire_days": map[string]interface{}{
+						"description": "Number of days after which suppressions expire. 0 disables expiry.",
+						"type":        "integer",
+						"minimum":     0,
+					},
+				},
+			},
+			"filter": map[string]interface{}{
+				"description": "Expression selecting the diagnostics to report, like \"severity>=warning and not path:**/*_test.go\".",
+				"type":        "string",
+			},
+			"targets": map[string]interface{}{
+				"description": "Build configurations to analyze packages in, like \"linux/amd64\" or \"windows/386:netgo,osusergo\".",
+				"type":        "array",
+				"items": map[string]interface{}{
+					"type":    "string",
+					"pattern": `^[a-z0-9]*/[a-z0-9]*(:.*)?$`,
+				},
+			},
+			"plugins": map[string]interface{}{
+				"description": "Go plugins providing additional analyzers, relative to the configuration file.",
+				"type":        "array",
+				"items": map[string]interface{}{
+					"type": "string",
+				},
+			},
+			"flags": map[string]interface{}{
+				"description":          "Analyzer flags, by their namespaced name.",
+				"type":                 "object",
+				"additionalProperties": false,
+				"properties":           flags,
+				"patternProperties": map[string]interface{}{
+					`^GT[0-9]{4}\.`: map[string]interface{}{
+						"description": "Analyzer flag, namespaced by a rule ID.",
+					},
+				},
+			},
+		},
+	}
+	return json.MarshalIndent(s, "", "\t")
+}
+
+// flagSchema returns the schema of the values of f. The type is derived
+// from the value of flags defined with the typed functions of package
+// flag, all others are strings.
+func flagSchema(f *flag.Flag) map[string]interface{} {
+	s := map[string]interface{}{
+		"description": f.Usage,
+		"type":        "string",
+	}
+	g, ok := f.Value.(flag.Getter)
+	if !ok {
+		return s
+	}
+	switch v := g.Get().(type) {
+	case bool:
+		s["type"], s["default"] = "boolean", v
+	case int, int64, uint, uint64:
+		s["type"], s["default"] = "integer", v
+	case float64:
+		s["type"], s["default"] = "number", v
+	case time.Duration:
+		s["default"] = v.String()
+	case string:
+		s["default"] = v
+	}
+	return s
+}
@@ -0,0 +1,249 @@
+// Copyright 2019 Axel Wagner
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package corpus runs analyzers over a pinned list of open source modules
+// and compares the findings to a stored baseline. This guards analyzer
+// changes against regressions in the form of new false positives.
+package corpus
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/Merovius/go-tools/internal/driver"
+	"github.com/Merovius/go-tools/internal/fingerprint"
+	"golang.org/x/tools/go/analysis"
+)
+
+// A Module is a module version in the corpus.
+type Module struct {
+	Path    string
+	Version string
+}
+
+func (m Module) String() string {
+	return m.Path + "@" + m.Version
+}
+
+// Corpus is the list of modules to analyze, as stored in a JSON file.
+type Corpus struct {
+	Modules []Module
+}
+
+// Load reads a corpus from a JSON file.
+func Load(file string) (*Corpus, error) {
+	c := new(Corpus)
+	if err := readJSON(file, c); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// A Finding is a diagnostic recorded in a baseline. Only the fingerprint is
+// used for comparison, the other fields are for humans.
+type Finding struct {
+	Fingerprint string
+	Analyzer    string
+	Position    string
+	Message     string
+}
+
+func (f Finding) String() string {
+	return fmt.Sprintf("%s: %s (%s)", f.Position, f.Message, f.Analyzer)
+}
+
+// A Baseline maps modules (as path@version) to their findings.
+type Baseline map[string][]Finding
+
+// ReadBaseline reads a baseline from a JSON file.
+func ReadBaseline(file string) (Baseline, error) {
+	b := make(Baseline)
+	if err := readJSON(file, &b); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// Write writes b as JSON to file.
+func (b Baseline) Write(file string) error {
+	buf, err := json.MarshalIndent(b, "", "\t")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(file, append(buf, '\n'), 0666)
+}
+
+func readJSON(file string, v interface{}) error {
+	buf, err := ioutil.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(buf, v); err != nil {
+		return fmt.Errorf("%s: %v", file, err)
+	}
+	return nil
+}
+
+// Fetch downloads m and copies it into a new directory below workdir, so
+// it can be analyzed without writing to the module cache. It returns the
+// directory.
+func Fetch(m Module, workdir string) (string, error) {
+	// "go mod download" needs a module to run in.
+	tmp, err := ioutil.TempDir(workdir, "download")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(tmp)
+	if err := ioutil.WriteFile(filepath.Join(tmp, "go.mod"), []byte("module gotools-corpus\n"), 0666); err != nil {
+		return "", err
+	}
+	cmd := exec.Command("go", "mod", "download", "-json", m.String())
+	cmd.Dir = tmp
+	cmd.Env = append(os.Environ(), "GO111MODULE=on")
+	stderr := new(bytes.Buffer)
+	cmd.Stderr = stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("downloading %v: %v: %s", m, err, strings.TrimSpace(stderr.String()))
+	}
+	var info struct {
+		Dir   string
+		Error string
+	}
+	if err := json.Unmarshal(out, &info); err != nil {
+		return "", fmt.Errorf("downloading %v: %v", m, err)
+	}
+	if info.Error != "" {
+		return "", fmt.Errorf("downloading %v: %s", m, info.Error)
+	}
+
+	dst := filepath.Join(workdir, strings.Replace(m.String(), "/", "_", -1))
+	if err := os.RemoveAll(dst); err != nil {
+		return "", err
+	}
+	if err := copyTree(dst, info.Dir); err != nil {
+		return "", err
+	}
+	if _, err := os.Stat(filepath.Join(dst, "go.mod")); os.IsNotExist(err) {
+		// Modules predating module mode need a synthesized go.mod.
+		err = ioutil.WriteFile(filepath.Join(dst, "go.mod"), []byte("module "+m.Path+"\n"), 0666)
+		if err != nil {
+			return "", err
+		}
+	}
+	return dst, nil
+}
+
+func copyTree(dst, src string) error {
+	return filepath.Walk(src, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+		if fi.IsDir() {
+			return os.MkdirAll(target, 0777)
+		}
+		if !fi.Mode().IsRegular() {
+			return nil
+		}
+		return copyFile(target, path)
+	})
+}
+
+func copyFile(dst, src string) error {
+	r, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	// Files in the module cache are read-only, but the go tool might need
+	// to update go.mod and go.sum.
+	w, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0666)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(w, r); err != nil {
+		w.Close()
+		return err
+	}
+	return w.Close()
+}
+
+// Analyze runs analyzers on all packages of the module in dir and returns
+// the findings, sorted by fingerprint.
+func Analyze(dir string, analyzers []*analysis.Analyzer) ([]Finding, error) {
+	opts := &driver.Options{
+		Analyzers: analyzers,
+		Tests:     true,
+		Dir:       dir,
+	}
+	diags, err := driver.Run(opts, "./...")
+	if err != nil {
+		return nil, err
+	}
+	fp := fingerprint.New(dir, nil)
+	var out []Finding
+	for _, d := range diags {
+		posn := d.Posn
+		if rel, err := filepath.Rel(dir, posn.Filename); err == nil {
+			posn.Filename = filepath.ToSlash(rel)
+		}
+		out = append(out, Finding{
+			Fingerprint: fp.Fingerprint(d),
+			Analyzer:    d.Analyzer,
+			Position:    posn.String(),
+			Message:     d.Message,
+		})
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].Fingerprint < out[j].Fingerprint
+	})
+	return out, nil
+}
+
+// Compare returns the findings in cur which are not in old and those in old
+// which are not in cur. Findings with the same fingerprint are counted, so
+// a duplicated finding is reported as added.
+func Compare(old, cur []Finding) (added, removed []Finding) {
+	count := make(map[string]int)
+	for _, f := range old {
+		count[f.Fingerprint]++
+	}
+	for _, f := range cur {
+		if count[f.Fingerprint] > 0 {
+			count[f.Fingerprint]--
+			continue
+		}
+		added = append(added, f)
+	}
+	for _, f := range old {
+		if count[f.Fingerprint] > 0 {
+			count[f.Fingerprint]--
+			removed = append(removed, f)
+		}
+	}
+	return added, removed
+}
@@ -0,0 +1,35 @@
+// Copyright 2019 Axel Wagner
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package corpus
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCompare(t *testing.T) {
+	f := func(fp string) Finding {
+		return Finding{Fingerprint: fp}
+	}
+	old := []Finding{f("a"), f("b"), f("b"), f("c")}
+	cur := []Finding{f("a"), f("b"), f("d"), f("d")}
+	added, removed := Compare(old, cur)
+	if want := []Finding{f("d"), f("d")}; !reflect.DeepEqual(added, want) {
+		t.Errorf("Compare() added %v, want %v", added, want)
+	}
+	if want := []Finding{f("b"), f("c")}; !reflect.DeepEqual(removed, want) {
+		t.Errorf("Compare() removed %v, want %v", removed, want)
+	}
+}
@@ -0,0 +1,116 @@
+// Copyright 2019 Axel Wagner
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package coverage annotates diagnostics with whether the flagged code is
+// covered by tests, according to a coverage profile written by "go test
+// -coverprofile".
+package coverage
+
+import (
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/Merovius/go-tools/internal/driver"
+	"golang.org/x/tools/cover"
+)
+
+// Annotation is the key of the annotation added to diagnostics. Its value
+// is one of the Status constants.
+const Annotation = "covered"
+
+// Status is the coverage status of a line.
+type Status string
+
+// Statuses of lines.
+const (
+	// Unknown lines are not in any block of the profile, for example
+	// because they are declarations or in a package without tests.
+	Unknown   Status = "unknown"
+	Covered   Status = "yes"
+	Uncovered Status = "no"
+)
+
+// A Profile is a parsed coverage profile.
+type Profile struct {
+	files  map[string][]cover.ProfileBlock
+	byBase map[string][]string
+}
+
+// Read reads a coverage profile from file.
+func Read(file string) (*Profile, error) {
+	profs, err := cover.ParseProfiles(file)
+	if err != nil {
+		return nil, err
+	}
+	p := &Profile{
+		files:  make(map[string][]cover.ProfileBlock),
+		byBase: make(map[string][]string),
+	}
+	for _, prof := range profs {
+		name := filepath.ToSlash(prof.FileName)
+		if _, ok := p.files[name]; !ok {
+			base := path.Base(name)
+			p.byBase[base] = append(p.byBase[base], name)
+		}
+		p.files[name] = append(p.files[name], prof.Blocks...)
+	}
+	return p, nil
+}
+
+// Status returns the coverage status of the given line of file. Profiles
+// name files by import path, so the file of the profile sharing the longest
+// suffix of path elements with file is used.
+func (p *Profile) Status(file string, line int) Status {
+	file = filepath.ToSlash(file)
+	var (
+		best  string
+		bestN int
+	)
+	for _, cand := range p.byBase[path.Base(file)] {
+		if n := commonSuffix(cand, file); n > bestN {
+			best, bestN = cand, n
+		}
+	}
+	st := Unknown
+	for _, b := range p.files[best] {
+		if line < b.StartLine || line > b.EndLine {
+			continue
+		}
+		if b.Count > 0 {
+			return Covered
+		}
+		st = Uncovered
+	}
+	return st
+}
+
+// commonSuffix returns the number of trailing path elements of a and b
+// which are equal.
+func commonSuffix(a, b string) int {
+	as, bs := strings.Split(a, "/"), strings.Split(b, "/")
+	n := 0
+	for n < len(as) && n < len(bs) && as[len(as)-1-n] == bs[len(bs)-1-n] {
+		n++
+	}
+	return n
+}
+
+// Annotate sets the coverage annotation of all diagnostics.
+func (p *Profile) Annotate(diags []driver.Diagnostic) {
+	for i := range diags {
+		d := &diags[i]
+		d.Annotate(Annotation, string(p.Status(d.Posn.Filename, d.Posn.Line)))
+	}
+}
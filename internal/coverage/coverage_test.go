@@ -0,0 +1,73 @@
+// Copyright 2019 Axel Wagner
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package coverage
+
+import (
+	"go/token"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Merovius/go-tools/internal/driver"
+)
+
+const profile = `mode: set
+example.com/m/a/a.go:3.10,5.2 2 1
+example.com/m/a/a.go:5.2,8.3 1 0
+example.com/m/a/a.go:10.2,12.3 1 0
+example.com/m/b/a.go:3.10,12.2 2 1
+`
+
+func TestAnnotate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "coverage")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "cover.out")
+	if err := ioutil.WriteFile(file, []byte(profile), 0666); err != nil {
+		t.Fatal(err)
+	}
+	p, err := Read(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tcs := []struct {
+		file string
+		line int
+		want Status
+	}{
+		{"/src/m/a/a.go", 4, Covered},
+		// Line 5 is in a covered and an uncovered block.
+		{"/src/m/a/a.go", 5, Covered},
+		{"/src/m/a/a.go", 7, Uncovered},
+		{"/src/m/a/a.go", 9, Unknown},
+		{"/src/m/a/a.go", 11, Uncovered},
+		{"/src/m/b/a.go", 11, Covered},
+		{"/src/m/c/c.go", 4, Unknown},
+	}
+	var diags []driver.Diagnostic
+	for _, tc := range tcs {
+		diags = append(diags, driver.Diagnostic{Posn: token.Position{Filename: tc.file, Line: tc.line}})
+	}
+	p.Annotate(diags)
+	for i, tc := range tcs {
+		if got := diags[i].Annotations[Annotation]; got != string(tc.want) {
+			t.Errorf("coverage of %s:%d = %q, want %q", tc.file, tc.line, got, tc.want)
+		}
+	}
+}
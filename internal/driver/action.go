@@ -0,0 +1,536 @@
+// Copyright 2019 Axel Wagner
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package driver
+
+import (
+	"fmt"
+	"go/token"
+	"go/types"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Merovius/go-tools/internal/fix"
+	"github.com/Merovius/go-tools/internal/rules"
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/packages"
+)
+
+// An action is the application of one analyzer to one package.
+type action struct {
+	once sync.Once
+	a    *analysis.Analyzer
+	pkg  *packages.Package
+	deps []*action
+
+	// imported is set if the facts of this action are imported from a fact
+	// file instead of running the analyzer. It is reset, if they turn out
+	// to be stale.
+	imported *importedFacts
+
+	// hash is the hash of the sources of the package, if facts are
+	// imported or exported.
+	hash string
+
+	// uses records the facts of other packages looked up by the analyzer,
+	// nil for those not found. If usesAll is set, the analyzer looked at
+	// all of them.
+	usesMu  sync.Mutex
+	uses    map[factUseKey]analysis.Fact
+	usesAll bool
+
+	// users is the number of unfinished actions depending on this one
+	// (plus one for root actions). If it drops to zero before the action
+	// ran, it is skipped.
+	users   int32
+	skipped bool
+	state   *pkgState
+	limit   *limiter
+
+	// root is set for the actions whose diagnostics are reported, which
+	// count against the budget.
+	root    bool
+	budget  *budget
+	stopped stopReason
+
+	// guardMu guards the output of the analyzer against it still running
+	// after it was abandoned.
+	guardMu   sync.Mutex
+	abandoned bool
+
+	result       interface{}
+	err          error
+	diagnostics  []analysis.Diagnostic
+	objectFacts  map[objectFactKey]analysis.Fact
+	packageFacts map[packageFactKey]analysis.Fact
+}
+
+type objectFactKey struct {
+	obj types.Object
+	typ reflect.Type
+}
+
+type packageFactKey struct {
+	pkg *types.Package
+	typ reflect.Type
+}
+
+func (act *action) String() string {
+	return fmt.Sprintf("%s@%s", act.a.Name, act.pkg.ID)
+}
+
+// analyze runs the analyzers on the root packages pkgs and returns the
+// diagnostics they report. Facts for dependencies are taken from store, if
+// present. Packages whose analysis is stopped by the limits in opts are
+// recorded in inc.
+func analyze(pkgs []*packages.Package, opts *Options, store factStore, inc *Incomplete) ([]Diagnostic, error) {
+	isRoot := make(map[*packages.Package]bool)
+	for _, pkg := range pkgs {
+		isRoot[pkg] = true
+	}
+
+	type key struct {
+		a   *analysis.Analyzer
+		pkg *packages.Package
+	}
+	actions := make(map[key]*action)
+	hashes := make(map[*packages.Package]string)
+	states := make(map[*packages.Package]*pkgState)
+	limit := &limiter{max: opts.MaxMemory}
+	b := &budget{maxIssues: int32(opts.MaxIssues), timeout: opts.PackageTimeout}
+
+	var mkAction func(a *analysis.Analyzer, pkg *packages.Package) *action
+	mkAction = func(a *analysis.Analyzer, pkg *packages.Package) *action {
+		k := key{a, pkg}
+		if act, ok := actions[k]; ok {
+			return act
+		}
+		act := &action{a: a, pkg: pkg, limit: limit, budget: b}
+		st := states[pkg]
+		if st == nil {
+			st = &pkgState{pkg: pkg}
+			states[pkg] = st
+		}
+		st.add(act)
+		act.state = st
+		imported := store.lookup(a, pkg.PkgPath)
+		if (imported != nil && !isRoot[pkg]) || opts.ExportFacts != "" {
+			h, ok := hashes[pkg]
+			if !ok {
+				h = sourceHash(pkg, opts.Overlay)
+				hashes[pkg] = h
+			}
+			act.hash = h
+		}
+		if imported != nil && !isRoot[pkg] && (imported.Hash == "" || imported.Hash == act.hash) {
+			// Only the facts of the dependencies are needed, to inherit
+			// them. Whether the facts are still valid is only known once
+			// the facts of the dependencies are.
+			act.imported = imported
+		}
+		for _, req := range a.Requires {
+			act.deps = append(act.deps, mkAction(req, pkg))
+		}
+		// An analyzer using facts needs to run on all dependencies, to
+		// get their facts.
+		if len(a.FactTypes) > 0 {
+			paths := make([]string, 0, len(pkg.Imports))
+			for path := range pkg.Imports {
+				paths = append(paths, path)
+			}
+			sort.Strings(paths)
+			for _, path := range paths {
+				act.deps = append(act.deps, mkAction(a, pkg.Imports[path]))
+			}
+		}
+		for _, dep := range act.deps {
+			dep.users++
+		}
+		actions[k] = act
+		return act
+	}
+
+	var roots []*action
+	for _, a := range opts.Analyzers {
+		for _, pkg := range pkgs {
+			act := mkAction(a, pkg)
+			act.users++
+			act.root = true
+			roots = append(roots, act)
+		}
+	}
+	// The syntax of packages is released once they are analyzed, so
+	// directives have to be parsed before.
+	ignores := make(map[*packages.Package][]*Ignore)
+	for _, pkg := range pkgs {
+		ignores[pkg] = parseIgnores(pkg)
+	}
+	b.filter = newDiagFilter(opts, ignores)
+	execAll(roots)
+
+	if opts.ExportFacts != "" {
+		all := make([]*action, 0, len(actions))
+		for _, act := range actions {
+			all = append(all, act)
+		}
+		if err := writeFacts(opts.ExportFacts, all); err != nil {
+			return nil, err
+		}
+	}
+
+	// The filter of the budget has seen the diagnostics already.
+	f := newDiagFilter(opts, ignores)
+	var diags []Diagnostic
+	for _, act := range roots {
+		switch act.stopped {
+		case stopMaxIssues:
+			inc.addSkipped(act.pkg.ID)
+			continue
+		case stopTimeout:
+			inc.addTimedOut(act.pkg.ID)
+			continue
+		}
+		if act.err != nil {
+			return nil, fmt.Errorf("%v: %v", act, act.err)
+		}
+		for _, d := range act.diagnostics {
+			if rd, ok := f.filter(act, d); ok {
+				diags = append(diags, rd)
+			}
+		}
+	}
+	return diags, nil
+}
+
+// A diagFilter decides which diagnostics of root actions are reported.
+type diagFilter struct {
+	opts    *Options
+	ignores map[*packages.Package][]*Ignore
+
+	mu   sync.Mutex
+	seen map[diagKey]bool
+}
+
+type diagKey struct {
+	analyzer string
+	posn     token.Position
+	message  string
+}
+
+func newDiagFilter(opts *Options, ignores map[*packages.Package][]*Ignore) *diagFilter {
+	return &diagFilter{opts: opts, ignores: ignores, seen: make(map[diagKey]bool)}
+}
+
+// filter resolves the diagnostic d of act and returns whether it is
+// reported, which it isn't if it's in a test file of an analyzer in
+// Options.SkipTests, if it was already passed to the filter or, unless
+// Options.ReportIgnored is set, if a directive ignores it.
+func (f *diagFilter) filter(act *action, d analysis.Diagnostic) (Diagnostic, bool) {
+	rd := act.resolve(d)
+	if f.opts.SkipTests[rd.Analyzer] && strings.HasSuffix(rd.Posn.Filename, "_test.go") {
+		return rd, false
+	}
+	// With tests, files of a package are analyzed both in the package and
+	// in its test variant, so the same diagnostic can be reported twice.
+	k := diagKey{rd.Analyzer, rd.Posn, rd.Message}
+	f.mu.Lock()
+	seen := f.seen[k]
+	f.seen[k] = true
+	f.mu.Unlock()
+	if seen {
+		return rd, false
+	}
+	for _, ig := range f.ignores[act.pkg] {
+		if ig.matches(rd) {
+			rd.Ignored = ig
+			break
+		}
+	}
+	return rd, rd.Ignored == nil || f.opts.ReportIgnored
+}
+
+func (act *action) resolve(d analysis.Diagnostic) Diagnostic {
+	fset := act.pkg.Fset
+	rule, _ := rules.Lookup(act.a.Name, d.Category)
+	out := Diagnostic{
+		Analyzer: act.a.Name,
+		Package:  act.pkg.ID,
+		Rule:     rule.ID,
+		Severity: rule.Severity,
+		Category: d.Category,
+		Posn:     fset.Position(d.Pos),
+		Message:  d.Message,
+	}
+	if d.End.IsValid() {
+		out.End = fset.Position(d.End)
+	}
+	for _, sf := range d.SuggestedFixes {
+		out.Fixes = append(out.Fixes, fix.Resolve(fset, act.a, sf))
+	}
+	return out
+}
+
+// execAll executes all actions in parallel, respecting dependencies.
+func execAll(actions []*action) {
+	var wg sync.WaitGroup
+	for _, act := range actions {
+		wg.Add(1)
+		go func(act *action) {
+			defer wg.Done()
+			act.once.Do(func() {
+				act.exec()
+				act.finish()
+			})
+		}(act)
+	}
+	wg.Wait()
+}
+
+func (act *action) exec() {
+	// Facts of dependencies are needed in any case, the results of
+	// required analyzers only if the analyzer has to run.
+	var factDeps, reqDeps []*action
+	for _, dep := range act.deps {
+		if dep.pkg == act.pkg {
+			reqDeps = append(reqDeps, dep)
+		} else {
+			factDeps = append(factDeps, dep)
+		}
+	}
+	execAll(factDeps)
+	if act.stopped = stoppedDeps(factDeps); act.stopped != notStopped {
+		return
+	}
+	if act.err = depErrors(factDeps); act.err != nil {
+		return
+	}
+	act.objectFacts = make(map[objectFactKey]analysis.Fact)
+	act.packageFacts = make(map[packageFactKey]analysis.Fact)
+	for _, dep := range factDeps {
+		if dep.a == act.a {
+			act.inheritFacts(dep)
+		}
+	}
+	if act.imported != nil {
+		if act.importedValid() {
+			act.err = act.importFacts(act.imported)
+			return
+		}
+		act.imported = nil
+	}
+
+	execAll(reqDeps)
+	if act.stopped = stoppedDeps(reqDeps); act.stopped != notStopped {
+		return
+	}
+	if act.err = depErrors(reqDeps); act.err != nil {
+		return
+	}
+	inputs := make(map[*analysis.Analyzer]interface{})
+	for _, dep := range reqDeps {
+		inputs[dep.a] = dep.result
+	}
+
+	pass := &analysis.Pass{
+		Analyzer:          act.a,
+		Fset:              act.pkg.Fset,
+		Files:             act.pkg.Syntax,
+		OtherFiles:        act.pkg.OtherFiles,
+		Pkg:               act.pkg.Types,
+		TypesInfo:         act.pkg.TypesInfo,
+		TypesSizes:        act.pkg.TypesSizes,
+		ResultOf:          inputs,
+		Report:            act.report,
+		ImportObjectFact:  act.importObjectFact,
+		ExportObjectFact:  func(obj types.Object, f analysis.Fact) { act.guard(func() { act.exportObjectFact(obj, f) }) },
+		ImportPackageFact: act.importPackageFact,
+		ExportPackageFact: func(f analysis.Fact) { act.guard(func() { act.exportPackageFact(f) }) },
+		AllObjectFacts:    act.allObjectFacts,
+		AllPackageFacts:   act.allPackageFacts,
+	}
+
+	if act.pkg.IllTyped && !act.a.RunDespiteErrors {
+		act.err = fmt.Errorf("analysis skipped due to errors in package")
+		return
+	}
+	if act.root && act.budget.exhausted() {
+		act.stopped = stopMaxIssues
+		return
+	}
+	act.limit.do(func() { act.run(pass) })
+	if act.err == nil && act.a.ResultType != nil {
+		if got := reflect.TypeOf(act.result); got != act.a.ResultType {
+			act.err = fmt.Errorf("internal error: on package %s, analyzer %s returned a result of type %v, but declared ResultType %v", act.pkg.PkgPath, act.a.Name, got, act.a.ResultType)
+		}
+	}
+}
+
+// run runs the analyzer of act, abandoning it once the timeout of the
+// package expires.
+func (act *action) run(pass *analysis.Pass) {
+	if act.budget.timeout <= 0 {
+		act.result, act.err = act.a.Run(pass)
+		return
+	}
+	remaining := time.Until(act.state.start().Add(act.budget.timeout))
+	if remaining <= 0 {
+		act.stopped = stopTimeout
+		return
+	}
+	type result struct {
+		v   interface{}
+		err error
+	}
+	done := make(chan result, 1)
+	go func() {
+		v, err := act.a.Run(pass)
+		done <- result{v, err}
+	}()
+	t := time.NewTimer(remaining)
+	defer t.Stop()
+	select {
+	case r := <-done:
+		act.result, act.err = r.v, r.err
+	case <-t.C:
+		// Analyzers can't be interrupted, so it keeps running in the
+		// background, but its output is dropped.
+		act.guardMu.Lock()
+		act.abandoned = true
+		act.guardMu.Unlock()
+		act.stopped = stopTimeout
+	}
+}
+
+// guard calls f, which records output of the analyzer, unless the action
+// was abandoned.
+func (act *action) guard(f func()) {
+	act.guardMu.Lock()
+	defer act.guardMu.Unlock()
+	if !act.abandoned {
+		f()
+	}
+}
+
+func (act *action) report(d analysis.Diagnostic) {
+	act.guard(func() {
+		act.diagnostics = append(act.diagnostics, d)
+		if act.root {
+			act.budget.report(act, d)
+		}
+	})
+}
+
+// stoppedDeps returns why the first stopped action of deps was stopped, as
+// actions depending on it can't run without its result or facts.
+func stoppedDeps(deps []*action) stopReason {
+	for _, dep := range deps {
+		if dep.stopped != notStopped {
+			return dep.stopped
+		}
+	}
+	return notStopped
+}
+
+// depErrors returns an error if any of deps failed.
+func depErrors(deps []*action) error {
+	var failed []string
+	for _, dep := range deps {
+		if dep.err != nil {
+			failed = append(failed, dep.String())
+		}
+	}
+	if failed == nil {
+		return nil
+	}
+	sort.Strings(failed)
+	return fmt.Errorf("failed prerequisites: %v", failed)
+}
+
+// inheritFacts copies the facts of an action on an imported package.
+func (act *action) inheritFacts(dep *action) {
+	for k, f := range dep.objectFacts {
+		act.objectFacts[k] = f
+	}
+	for k, f := range dep.packageFacts {
+		act.packageFacts[k] = f
+	}
+}
+
+func (act *action) importObjectFact(obj types.Object, ptr analysis.Fact) bool {
+	if obj == nil {
+		panic("nil object")
+	}
+	f, ok := act.objectFacts[objectFactKey{obj, factType(ptr)}]
+	if obj.Pkg() != act.pkg.Types {
+		act.use(factUseKey{obj, obj.Pkg(), factType(ptr)}, f)
+	}
+	if ok {
+		reflect.ValueOf(ptr).Elem().Set(reflect.ValueOf(f).Elem())
+	}
+	return ok
+}
+
+func (act *action) exportObjectFact(obj types.Object, f analysis.Fact) {
+	if obj.Pkg() != act.pkg.Types {
+		panic(fmt.Sprintf("internal error: in analysis %s of package %s: Fact.Set(%s, %T): can't set facts on objects belonging another package", act.a, act.pkg, obj, f))
+	}
+	act.objectFacts[objectFactKey{obj, factType(f)}] = f
+}
+
+func (act *action) importPackageFact(pkg *types.Package, ptr analysis.Fact) bool {
+	if pkg == nil {
+		panic("nil package")
+	}
+	f, ok := act.packageFacts[packageFactKey{pkg, factType(ptr)}]
+	if pkg != act.pkg.Types {
+		act.use(factUseKey{nil, pkg, factType(ptr)}, f)
+	}
+	if ok {
+		reflect.ValueOf(ptr).Elem().Set(reflect.ValueOf(f).Elem())
+	}
+	return ok
+}
+
+func (act *action) exportPackageFact(f analysis.Fact) {
+	act.packageFacts[packageFactKey{act.pkg.Types, factType(f)}] = f
+}
+
+func (act *action) allObjectFacts() []analysis.ObjectFact {
+	act.useAll()
+	facts := make([]analysis.ObjectFact, 0, len(act.objectFacts))
+	for k, f := range act.objectFacts {
+		facts = append(facts, analysis.ObjectFact{Object: k.obj, Fact: f})
+	}
+	return facts
+}
+
+func (act *action) allPackageFacts() []analysis.PackageFact {
+	act.useAll()
+	facts := make([]analysis.PackageFact, 0, len(act.packageFacts))
+	for k, f := range act.packageFacts {
+		facts = append(facts, analysis.PackageFact{Package: k.pkg, Fact: f})
+	}
+	return facts
+}
+
+func factType(f analysis.Fact) reflect.Type {
+	t := reflect.TypeOf(f)
+	if t.Kind() != reflect.Ptr {
+		panic(fmt.Sprintf("invalid Fact type: got %T, want pointer", f))
+	}
+	return t
+}
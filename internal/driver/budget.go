@@ -0,0 +1,144 @@
+// Copyright 2019 Axel Wagner
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package driver
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/tools/go/analysis"
+)
+
+// Incomplete is the error returned by Run, together with the diagnostics
+// found, if the analysis was cut short by Options.MaxIssues or
+// Options.PackageTimeout. Callers wanting a bounded signal can report the
+// diagnostics and its Notes.
+type Incomplete struct {
+	// MaxIssues is set if diagnostics were dropped or packages skipped
+	// because it was reached. Skipped lists the IDs of the packages which
+	// were not fully analyzed because of it.
+	MaxIssues int
+	Skipped   []string
+
+	// Timeout is set if the analysis of some packages took longer than it.
+	// TimedOut lists their IDs.
+	Timeout  time.Duration
+	TimedOut []string
+}
+
+func (inc *Incomplete) Error() string {
+	return "incomplete analysis: " + strings.Join(inc.Notes(), "; ")
+}
+
+// Notes describes why the analysis is incomplete, one line per reason.
+func (inc *Incomplete) Notes() []string {
+	var notes []string
+	if inc.MaxIssues > 0 {
+		n := fmt.Sprintf("stopped after %d findings, more might exist", inc.MaxIssues)
+		if len(inc.Skipped) > 0 {
+			n += fmt.Sprintf("; %d packages were not fully analyzed: %s", len(inc.Skipped), strings.Join(inc.Skipped, ", "))
+		}
+		notes = append(notes, n)
+	}
+	if len(inc.TimedOut) > 0 {
+		notes = append(notes, fmt.Sprintf("analysis of %d packages took longer than %v and was abandoned, findings of analyzers not done in time are missing: %s", len(inc.TimedOut), inc.Timeout, strings.Join(inc.TimedOut, ", ")))
+	}
+	return notes
+}
+
+func (inc *Incomplete) empty() bool {
+	return inc.MaxIssues == 0 && len(inc.TimedOut) == 0
+}
+
+func (inc *Incomplete) addSkipped(id string) {
+	inc.Skipped = appendTarget(inc.Skipped, id)
+	sort.Strings(inc.Skipped)
+}
+
+func (inc *Incomplete) addTimedOut(id string) {
+	inc.TimedOut = appendTarget(inc.TimedOut, id)
+	sort.Strings(inc.TimedOut)
+}
+
+// A stopReason is the reason an action was stopped before running its
+// analyzer, or didn't finish it.
+type stopReason int
+
+const (
+	notStopped stopReason = iota
+	stopMaxIssues
+	stopTimeout
+)
+
+// A budget bounds the analysis of a run, see Options.MaxIssues and
+// Options.PackageTimeout.
+type budget struct {
+	maxIssues int32
+	issues    int32 // reported by actions on root packages
+	timeout   time.Duration
+
+	// filter drops the diagnostics which are not reported, so that they
+	// don't count toward maxIssues.
+	filter *diagFilter
+}
+
+func (b *budget) exhausted() bool {
+	return b.maxIssues > 0 && atomic.LoadInt32(&b.issues) >= b.maxIssues
+}
+
+// report counts the diagnostic d of the root action act, unless it isn't
+// reported or is ignored by a directive.
+func (b *budget) report(act *action, d analysis.Diagnostic) {
+	if b.maxIssues <= 0 {
+		return
+	}
+	if rd, ok := b.filter.filter(act, d); ok && rd.Ignored == nil {
+		atomic.AddInt32(&b.issues, 1)
+	}
+}
+
+// limit truncates the sorted diagnostics to opts.MaxIssues diagnostics not
+// ignored by directives and returns them, with inc as error if the analysis
+// is incomplete.
+func (inc *Incomplete) limit(diags []Diagnostic, opts *Options) ([]Diagnostic, error) {
+	if opts.MaxIssues > 0 {
+		n := 0
+		out := diags[:0]
+		for _, d := range diags {
+			if d.Ignored == nil {
+				if n == opts.MaxIssues {
+					inc.MaxIssues = opts.MaxIssues
+					continue
+				}
+				n++
+			}
+			out = append(out, d)
+		}
+		diags = out
+		if len(inc.Skipped) > 0 {
+			inc.MaxIssues = opts.MaxIssues
+		}
+	}
+	if len(inc.TimedOut) > 0 {
+		inc.Timeout = opts.PackageTimeout
+	}
+	if inc.empty() {
+		return diags, nil
+	}
+	return diags, inc
+}
@@ -0,0 +1,397 @@
+// Copyright 2019 Axel Wagner
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package driver runs a set of analyzers over packages loaded with
+// go/packages. Unlike the drivers in x/tools, it can analyze the same
+// packages in several build configurations and merge the results, so that
+// platform-specific files are checked even if they don't match the host.
+package driver
+
+import (
+	"errors"
+	"fmt"
+	"go/build"
+	"go/token"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Merovius/go-tools/internal/fix"
+	"github.com/Merovius/go-tools/internal/gomod"
+	"github.com/Merovius/go-tools/internal/rules"
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/packages"
+)
+
+// Options configure a run of the driver.
+type Options struct {
+	// Analyzers to run. Their requirements are run as well, but only
+	// diagnostics of the analyzers given here are reported.
+	Analyzers []*analysis.Analyzer
+
+	// Targets are the build configurations to analyze the packages in. If
+	// empty, only the configuration of the current environment is used.
+	Targets []Target
+
+	// Tests specifies whether test files should be analyzed as well.
+	Tests bool
+
+	// Dir is the directory to run the build system in. If empty, the
+	// current directory is used.
+	Dir string
+
+	// ReportIgnored specifies whether diagnostics suppressed by linter
+	// directives should be returned. If so, their Ignored field is set.
+	ReportIgnored bool
+
+	// ImportFacts lists fact files written by earlier runs. Dependencies
+	// with facts in these files are not analyzed again.
+	ImportFacts []string
+
+	// ExportFacts, if not empty, is the file to write the facts of all
+	// analyzed packages to. It can't be used with more than one target.
+	// Packages whose analysis was stopped by MaxIssues or PackageTimeout
+	// are left out, as their facts are incomplete.
+	ExportFacts string
+
+	// SkipTests lists analyzers whose diagnostics in test files are not
+	// reported. They still analyze test files, as facts about them might be
+	// needed.
+	SkipTests map[string]bool
+
+	// MaxMemory is the heap size in bytes above which analyzers are run one
+	// at a time instead of in parallel. Zero means no limit.
+	MaxMemory uint64
+
+	// MaxIssues, if positive, is the number of diagnostics not ignored by
+	// directives after which the analysis stops. Analyzers not started yet
+	// on the packages matching patterns are skipped, and only the first
+	// MaxIssues diagnostics are returned.
+	MaxIssues int
+
+	// PackageTimeout, if positive, bounds the time analyzers run on a
+	// package, starting when the first one does. Analyzers still running
+	// after it are abandoned and their diagnostics dropped, as are those of
+	// analyzers needing their results or facts.
+	PackageTimeout time.Duration
+
+	// Overlay maps absolute file names to the content they are analyzed
+	// with, instead of the one on disk, like unsaved editor buffers. Files
+	// in it don't need to exist. See ReadOverlay.
+	Overlay map[string][]byte
+
+	// Shard restricts the analysis to the packages matching patterns which
+	// belong to it. Dependencies are still analyzed for facts.
+	Shard Shard
+}
+
+// A Diagnostic is a diagnostic reported by an analyzer, with its positions
+// resolved.
+type Diagnostic struct {
+	Analyzer string
+	Package  string // ID of the package
+	Rule     string // stable ID of the check, see package rules
+	Severity rules.Severity
+	Category string
+	Posn     token.Position
+	End      token.Position
+	Message  string
+	Fixes    []fix.Fix
+
+	// Ignored is the directive suppressing the diagnostic, if any.
+	Ignored *Ignore
+
+	// Targets lists the build configurations the diagnostic was reported
+	// in. It is empty if only the default configuration was analyzed.
+	Targets []string
+
+	// Annotations is additional information attached after analysis, like
+	// the test coverage of the flagged line.
+	Annotations map[string]string
+}
+
+// Annotate sets the annotation key to value.
+func (d *Diagnostic) Annotate(key, value string) {
+	if d.Annotations == nil {
+		d.Annotations = make(map[string]string)
+	}
+	d.Annotations[key] = value
+}
+
+func (d Diagnostic) String() string {
+	s := fmt.Sprintf("%v: %s", d.Posn, d.Message)
+	if d.Rule != "" {
+		s += " (" + d.Rule + ")"
+	}
+	if len(d.Targets) > 0 {
+		s += " [" + strings.Join(d.Targets, ", ") + "]"
+	}
+	if len(d.Annotations) > 0 {
+		var kv []string
+		for k, v := range d.Annotations {
+			kv = append(kv, k+"="+v)
+		}
+		sort.Strings(kv)
+		s += " {" + strings.Join(kv, " ") + "}"
+	}
+	return s
+}
+
+// Run loads the packages matching patterns and runs the analyzers on them,
+// once for every configured target. Diagnostics reported in more than one
+// target are merged. If the analysis is cut short by the limits in opts, the
+// diagnostics found are returned with an *Incomplete error.
+func Run(opts *Options, patterns ...string) ([]Diagnostic, error) {
+	if err := analysis.Validate(opts.Analyzers); err != nil {
+		return nil, err
+	}
+	if opts.ExportFacts != "" && len(opts.Targets) > 1 {
+		return nil, errors.New("can't export facts for more than one target")
+	}
+	store, err := readFacts(opts.ImportFacts)
+	if err != nil {
+		return nil, err
+	}
+	inc := new(Incomplete)
+	if len(opts.Targets) == 0 {
+		pkgs, err := load(opts, nil, patterns)
+		if err != nil {
+			return nil, err
+		}
+		diags, err := analyze(pkgs, opts, store, inc)
+		if err != nil {
+			return nil, err
+		}
+		sortDiagnostics(diags)
+		return inc.limit(diags, opts)
+	}
+
+	type key struct {
+		analyzer string
+		posn     token.Position
+		message  string
+	}
+	var (
+		all  []Diagnostic
+		seen = make(map[key]int)
+	)
+	for _, t := range opts.Targets {
+		t := t
+		pkgs, err := load(opts, &t, patterns)
+		if err != nil {
+			return nil, fmt.Errorf("%v: %v", t, err)
+		}
+		diags, err := analyze(pkgs, opts, store, inc)
+		if err != nil {
+			return nil, fmt.Errorf("%v: %v", t, err)
+		}
+		for _, d := range diags {
+			k := key{d.Analyzer, d.Posn, d.Message}
+			if i, ok := seen[k]; ok {
+				all[i].Targets = appendTarget(all[i].Targets, t.String())
+				continue
+			}
+			d.Targets = []string{t.String()}
+			seen[k] = len(all)
+			all = append(all, d)
+		}
+	}
+	sortDiagnostics(all)
+	return inc.limit(all, opts)
+}
+
+func appendTarget(ts []string, t string) []string {
+	for _, s := range ts {
+		if s == t {
+			return ts
+		}
+	}
+	return append(ts, t)
+}
+
+func load(opts *Options, t *Target, patterns []string) ([]*packages.Package, error) {
+	// Unless an analyzer needs to run on dependencies, their types are
+	// loaded from export data, without parsing or type-checking their
+	// sources.
+	mode := packages.LoadSyntax
+	if needDeps(opts.Analyzers) {
+		mode = packages.LoadAllSyntax
+	}
+	cfg := &packages.Config{
+		Mode:    mode,
+		Tests:   opts.Tests,
+		Dir:     opts.Dir,
+		Overlay: opts.Overlay,
+	}
+	if t != nil {
+		cfg.Env = t.env()
+		cfg.BuildFlags = t.buildFlags()
+	}
+	patterns, err := expandWork(opts.Dir, patterns)
+	if err != nil {
+		return nil, err
+	}
+	pkgs, err := packages.Load(cfg, patterns...)
+	if err != nil {
+		return nil, err
+	}
+	if err := packageErrors(pkgs); err != nil {
+		return nil, err
+	}
+	// Tests create a synthesized main package, which is not interesting
+	// for analysis.
+	out := pkgs[:0]
+	for _, p := range pkgs {
+		if strings.HasSuffix(p.ID, ".test") || !opts.Shard.Contains(p.PkgPath) {
+			continue
+		}
+		out = append(out, p)
+	}
+	addGoMod(out)
+	return out, nil
+}
+
+// expandWork expands the patterns for the go.work workspace dir is in, if
+// any. The go command matches a pattern like ./... only in the directories
+// of workspace modules, so for a directory containing several, like the
+// root of the workspace, it is replaced by one pattern per module. As all
+// modules are loaded together, facts flow across them.
+func expandWork(dir string, patterns []string) ([]string, error) {
+	w, err := gomod.FindWork(dir)
+	if err != nil || w == nil {
+		return patterns, err
+	}
+	if dir, err = filepath.Abs(dir); err != nil {
+		return nil, err
+	}
+	mods := w.Dirs()
+	inModule := func(d string) bool {
+		for _, m := range mods {
+			if d == m || strings.HasPrefix(d, m+string(filepath.Separator)) {
+				return true
+			}
+		}
+		return false
+	}
+	var out []string
+	for _, p := range patterns {
+		base := strings.TrimSuffix(p, "/...")
+		if p == "..." {
+			base = "."
+		}
+		if base == p || !build.IsLocalImport(base) {
+			out = append(out, p)
+			continue
+		}
+		root := filepath.Join(dir, filepath.FromSlash(base))
+		if inModule(root) {
+			out = append(out, p)
+			continue
+		}
+		n := len(out)
+		for _, m := range mods {
+			if !strings.HasPrefix(m, root+string(filepath.Separator)) {
+				continue
+			}
+			rel, err := filepath.Rel(dir, m)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, "./"+filepath.ToSlash(rel)+"/...")
+		}
+		if len(out) == n {
+			// Leave it to the go command to report the pattern.
+			out = append(out, p)
+		}
+	}
+	return out, nil
+}
+
+// addGoMod adds the go.mod file of the module of each package and its
+// dependencies to its OtherFiles, so that analyzers can check it and changes
+// to it invalidate the facts of the package. Dependencies get it as well, as
+// the hash of their sources must not depend on whether they are a root.
+func addGoMod(pkgs []*packages.Package) {
+	packages.Visit(pkgs, nil, func(p *packages.Package) {
+		if len(p.GoFiles) == 0 {
+			return
+		}
+		mod, err := gomod.Find(filepath.Dir(p.GoFiles[0]))
+		if err != nil || mod == nil {
+			// Broken go.mod files are reported by the go command.
+			return
+		}
+		p.OtherFiles = append(p.OtherFiles, mod.Path)
+	})
+}
+
+// needDeps returns whether any of analyzers or their requirements needs
+// the syntax and type information of dependencies. Analyzers declare this
+// by using facts, as only those are run on dependencies.
+func needDeps(analyzers []*analysis.Analyzer) bool {
+	seen := make(map[*analysis.Analyzer]bool)
+	var visit func(as []*analysis.Analyzer) bool
+	visit = func(as []*analysis.Analyzer) bool {
+		for _, a := range as {
+			if seen[a] {
+				continue
+			}
+			seen[a] = true
+			if len(a.FactTypes) > 0 || visit(a.Requires) {
+				return true
+			}
+		}
+		return false
+	}
+	return visit(analyzers)
+}
+
+// packageErrors returns an error summarizing the errors encountered while
+// loading pkgs or any of their dependencies.
+func packageErrors(pkgs []*packages.Package) error {
+	var errs []string
+	packages.Visit(pkgs, nil, func(p *packages.Package) {
+		for _, err := range p.Errors {
+			errs = append(errs, err.Error())
+		}
+	})
+	switch len(errs) {
+	case 0:
+		return nil
+	case 1:
+		return errors.New(errs[0])
+	default:
+		return fmt.Errorf("%s (and %d more errors)", errs[0], len(errs)-1)
+	}
+}
+
+func sortDiagnostics(diags []Diagnostic) {
+	sort.Slice(diags, func(i, j int) bool {
+		a, b := diags[i].Posn, diags[j].Posn
+		if a.Filename != b.Filename {
+			return a.Filename < b.Filename
+		}
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		if a.Column != b.Column {
+			return a.Column < b.Column
+		}
+		if diags[i].Analyzer != diags[j].Analyzer {
+			return diags[i].Analyzer < diags[j].Analyzer
+		}
+		return diags[i].Message < diags[j].Message
+	})
+}
@@ -0,0 +1,682 @@
+// Copyright 2019 Axel Wagner
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package driver
+
+import (
+	"go/ast"
+	"go/types"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Merovius/go-tools/redundantbranch"
+	"golang.org/x/tools/go/analysis"
+)
+
+func TestParseTarget(t *testing.T) {
+	tcs := []struct {
+		in   string
+		want Target
+		err  bool
+	}{
+		{"linux/amd64", Target{GOOS: "linux", GOARCH: "amd64"}, false},
+		{"windows/386:netgo, osusergo", Target{"windows", "386", []string{"netgo", "osusergo"}}, false},
+		{"/arm64", Target{GOARCH: "arm64"}, false},
+		{"linux", Target{}, true},
+		{"linux/amd64/v3", Target{}, true},
+	}
+	for _, tc := range tcs {
+		got, err := ParseTarget(tc.in)
+		if (err != nil) != tc.err {
+			t.Errorf("ParseTarget(%q) = _, %v, want error: %v", tc.in, err, tc.err)
+			continue
+		}
+		if err == nil && !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("ParseTarget(%q) = %+v, want %+v", tc.in, got, tc.want)
+		}
+	}
+}
+
+func TestTargetEnv(t *testing.T) {
+	defer os.Setenv("CGO_ENABLED", os.Getenv("CGO_ENABLED"))
+	os.Unsetenv("CGO_ENABLED")
+	cgo := func(env []string) string {
+		v := ""
+		for _, kv := range env {
+			if strings.HasPrefix(kv, "CGO_ENABLED=") {
+				v = strings.TrimPrefix(kv, "CGO_ENABLED=")
+			}
+		}
+		return v
+	}
+	other := "plan9"
+	if runtime.GOOS == other {
+		other = "linux"
+	}
+	host := Target{GOOS: runtime.GOOS, GOARCH: runtime.GOARCH}
+	cross := Target{GOOS: other, GOARCH: runtime.GOARCH}
+	if got := cgo(host.env()); got != "" {
+		t.Errorf("CGO_ENABLED for host target %v = %q, want unset", host, got)
+	}
+	if got := cgo(cross.env()); got != "0" {
+		t.Errorf("CGO_ENABLED for cross target %v = %q, want 0", cross, got)
+	}
+	os.Setenv("CGO_ENABLED", "1")
+	if got := cgo(cross.env()); got != "1" {
+		t.Errorf("CGO_ENABLED=1 for cross target %v = %q, want the environment to win", cross, got)
+	}
+}
+
+func TestParseShard(t *testing.T) {
+	tcs := []struct {
+		in   string
+		want Shard
+		err  bool
+	}{
+		{"1/4", Shard{1, 4}, false},
+		{"4/4", Shard{4, 4}, false},
+		{"0/4", Shard{}, true},
+		{"5/4", Shard{}, true},
+		{"1/0", Shard{}, true},
+		{"1", Shard{}, true},
+		{"a/b", Shard{}, true},
+	}
+	for _, tc := range tcs {
+		got, err := ParseShard(tc.in)
+		if (err != nil) != tc.err {
+			t.Errorf("ParseShard(%q) = _, %v, want error: %v", tc.in, err, tc.err)
+			continue
+		}
+		if err == nil && got != tc.want {
+			t.Errorf("ParseShard(%q) = %+v, want %+v", tc.in, got, tc.want)
+		}
+	}
+}
+
+func TestShardContains(t *testing.T) {
+	paths := []string{"example.com/a", "example.com/a/b", "example.com/c", "fmt", "net/http", "os", "strings"}
+	for n := 1; n <= 4; n++ {
+		for _, p := range paths {
+			var in []int
+			for i := 1; i <= n; i++ {
+				s := Shard{i, n}
+				if s.Contains(p) {
+					in = append(in, i)
+				}
+				if s.Contains(p) != s.Contains(p+"_test") {
+					t.Errorf("%s and its external tests are in different shards of %d", p, n)
+				}
+			}
+			if len(in) != 1 {
+				t.Errorf("%s is in shards %v of %d, want exactly one", p, in, n)
+			}
+		}
+	}
+	if !(Shard{}).Contains("fmt") {
+		t.Error("zero Shard doesn't contain fmt")
+	}
+}
+
+func TestTargets(t *testing.T) {
+	opts := &Options{
+		Analyzers: []*analysis.Analyzer{redundantbranch.Analyzer},
+		Targets: []Target{
+			{GOOS: "linux", GOARCH: "amd64"},
+			{GOOS: "windows", GOARCH: "amd64"},
+		},
+		Dir: filepath.Join("testdata", "cross"),
+	}
+	diags, err := Run(opts, "./...")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make(map[string][]string)
+	for _, d := range diags {
+		got[filepath.Base(d.Posn.Filename)] = d.Targets
+	}
+	want := map[string][]string{
+		"cross.go":         {"linux/amd64", "windows/amd64"},
+		"cross_windows.go": {"windows/amd64"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Run reported diagnostics in %v, want %v", got, want)
+	}
+}
+
+func TestTestVariant(t *testing.T) {
+	opts := &Options{
+		Analyzers: []*analysis.Analyzer{redundantbranch.Analyzer},
+		Tests:     true,
+		Dir:       filepath.Join("testdata", "cross"),
+	}
+	diags, err := Run(opts, "./...")
+	if err != nil {
+		t.Fatal(err)
+	}
+	seen := make(map[string]bool)
+	for _, d := range diags {
+		if seen[d.String()] {
+			t.Errorf("duplicate diagnostic %v", d)
+		}
+		seen[d.String()] = true
+	}
+	if len(diags) == 0 {
+		t.Error("no diagnostics reported")
+	}
+}
+
+func TestSkipTests(t *testing.T) {
+	opts := &Options{
+		Analyzers: []*analysis.Analyzer{redundantbranch.Analyzer},
+		Tests:     true,
+		Dir:       filepath.Join("testdata", "cross"),
+		SkipTests: map[string]bool{"redundantbranch": true},
+	}
+	diags, err := Run(opts, "./...")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(diags) == 0 {
+		t.Error("no diagnostics reported")
+	}
+	for _, d := range diags {
+		if strings.HasSuffix(d.Posn.Filename, "_test.go") {
+			t.Errorf("diagnostic in test file: %v", d)
+		}
+	}
+}
+
+func TestMemory(t *testing.T) {
+	var want []Diagnostic
+	for _, max := range []uint64{0, 1} {
+		opts := &Options{
+			Analyzers: []*analysis.Analyzer{redundantbranch.Analyzer},
+			Tests:     true,
+			Dir:       filepath.Join("testdata", "cross"),
+			MaxMemory: max,
+		}
+		pkgs, err := load(opts, nil, []string{"./..."})
+		if err != nil {
+			t.Fatal(err)
+		}
+		diags, err := analyze(pkgs, opts, nil, new(Incomplete))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, p := range pkgs {
+			if p.Syntax != nil || p.TypesInfo != nil {
+				t.Errorf("MaxMemory=%d: syntax of %s not released after analysis", max, p.ID)
+			}
+		}
+		if max == 0 {
+			want = diags
+		} else if !reflect.DeepEqual(diags, want) {
+			t.Errorf("MaxMemory=%d: got %v, want %v", max, diags, want)
+		}
+	}
+	if len(want) == 0 {
+		t.Error("no diagnostics reported")
+	}
+}
+
+func TestMaxIssues(t *testing.T) {
+	opts := &Options{
+		Analyzers: []*analysis.Analyzer{redundantbranch.Analyzer},
+		Tests:     true,
+		Dir:       filepath.Join("testdata", "cross"),
+		MaxIssues: 1,
+	}
+	diags, err := Run(opts, "./...")
+	inc, ok := err.(*Incomplete)
+	if !ok {
+		t.Fatalf("Run() = _, %v, want *Incomplete", err)
+	}
+	if len(diags) != 1 {
+		t.Errorf("Run() returned %d diagnostics, want 1", len(diags))
+	}
+	if inc.MaxIssues != 1 || len(inc.Notes()) != 1 {
+		t.Errorf("Incomplete = %+v, want MaxIssues 1 and one note", inc)
+	}
+}
+
+func TestMaxIssuesIgnored(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gotools")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	write := func(name, content string) {
+		name = filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(name), 0777); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(name, []byte(content), 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// The facts of b are needed for c, so the ignored diagnostic in b is
+	// reported first.
+	write("go.mod", "module example.com/ignored\n")
+	write("a/a.go", "package a\n\n//marked\nfunc Marked() {}\n")
+	write("b/b.go", "package b\n\nimport \"example.com/ignored/a\"\n\nfunc F() {\n\t//lint:ignore marked it's fine\n\ta.Marked()\n}\n")
+	write("c/c.go", "package c\n\nimport (\n\t\"example.com/ignored/a\"\n\t\"example.com/ignored/b\"\n)\n\nfunc G() {\n\ta.Marked()\n\tb.F()\n}\n")
+
+	m := &markedAnalyzer{ran: make(map[string]bool)}
+	opts := &Options{
+		Analyzers: []*analysis.Analyzer{m.analyzer()},
+		Dir:       dir,
+		MaxIssues: 1,
+	}
+	diags, err := Run(opts, "./...")
+	if err != nil {
+		t.Fatalf("Run() = _, %v, want no error, as only one diagnostic isn't ignored", err)
+	}
+	if len(diags) != 1 || diags[0].Package != "example.com/ignored/c" {
+		t.Errorf("Run() = %v, want one diagnostic in example.com/ignored/c", diags)
+	}
+}
+
+func TestPackageTimeout(t *testing.T) {
+	slow := &analysis.Analyzer{
+		Name: "slow",
+		Doc:  "report after a while",
+		Run: func(pass *analysis.Pass) (interface{}, error) {
+			time.Sleep(time.Second)
+			pass.Reportf(pass.Files[0].Pos(), "slow")
+			return nil, nil
+		},
+	}
+	opts := &Options{
+		Analyzers:      []*analysis.Analyzer{slow},
+		Dir:            filepath.Join("testdata", "cross"),
+		PackageTimeout: 10 * time.Millisecond,
+	}
+	diags, err := Run(opts, "./...")
+	inc, ok := err.(*Incomplete)
+	if !ok {
+		t.Fatalf("Run() = _, %v, want *Incomplete", err)
+	}
+	if len(diags) != 0 {
+		t.Errorf("Run() returned diagnostics %v of abandoned analyzer", diags)
+	}
+	if want := []string{"example.com/cross"}; !reflect.DeepEqual(inc.TimedOut, want) || inc.Timeout != opts.PackageTimeout {
+		t.Errorf("Incomplete = %+v, want %v timed out", inc, want)
+	}
+}
+
+func TestPackageTimeoutFacts(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gotools")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "facts.json")
+
+	m := &markedAnalyzer{ran: make(map[string]bool)}
+	slow := m.analyzer()
+	run := slow.Run
+	slow.Run = func(pass *analysis.Pass) (interface{}, error) {
+		if pass.Pkg.Path() == "example.com/facts/a" {
+			time.Sleep(time.Second)
+		}
+		return run(pass)
+	}
+	opts := &Options{
+		Analyzers:      []*analysis.Analyzer{slow},
+		Dir:            filepath.Join("testdata", "facts"),
+		PackageTimeout: 10 * time.Millisecond,
+		ExportFacts:    file,
+	}
+	if _, err := Run(opts, "./a"); err == nil {
+		t.Fatal("Run succeeded, want *Incomplete")
+	}
+	ff, err := readFacts([]string{file})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f := ff.lookup(slow, "example.com/facts/a"); f != nil {
+		t.Error("facts of abandoned analyzer were exported")
+	}
+}
+
+func TestOverlay(t *testing.T) {
+	file, err := filepath.Abs(filepath.Join("testdata", "cross", "cross.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir, err := ioutil.TempDir("", "overlay")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	// The unsaved buffer moved the function down by two lines.
+	buf := filepath.Join(dir, "buffer.go")
+	if err := ioutil.WriteFile(buf, []byte("package cross\n\n\n\nfunc Common(x int) {\n\tswitch x {\n\tcase 1:\n\t\tbreak\n\t}\n}\n"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	cfg := filepath.Join(dir, "overlay.json")
+	if err := ioutil.WriteFile(cfg, []byte(`{"Replace": {`+strconv.Quote(file)+`: `+strconv.Quote(buf)+`}}`), 0666); err != nil {
+		t.Fatal(err)
+	}
+	overlay, err := ReadOverlay(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := &Options{
+		Analyzers: []*analysis.Analyzer{redundantbranch.Analyzer},
+		Dir:       filepath.Join("testdata", "cross"),
+		Overlay:   overlay,
+	}
+	diags, err := Run(opts, ".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var lines []int
+	for _, d := range diags {
+		if d.Posn.Filename == file {
+			lines = append(lines, d.Posn.Line)
+		}
+	}
+	if want := []int{8}; !reflect.DeepEqual(lines, want) {
+		t.Errorf("diagnostics in overlaid file on lines %v, want %v", lines, want)
+	}
+}
+
+func TestReadOverlayDelete(t *testing.T) {
+	dir, err := ioutil.TempDir("", "overlay")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	cfg := filepath.Join(dir, "overlay.json")
+	if err := ioutil.WriteFile(cfg, []byte(`{"Replace": {"/src/a.go": ""}}`), 0666); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ReadOverlay(cfg); err == nil {
+		t.Error("ReadOverlay succeeded for deleted file, want error")
+	}
+}
+
+func TestLoadDeps(t *testing.T) {
+	m := &markedAnalyzer{ran: make(map[string]bool)}
+	for _, tc := range []struct {
+		a    *analysis.Analyzer
+		want bool
+	}{
+		{redundantbranch.Analyzer, false},
+		{m.analyzer(), true},
+	} {
+		opts := &Options{
+			Analyzers: []*analysis.Analyzer{tc.a},
+			Dir:       filepath.Join("testdata", "facts"),
+		}
+		pkgs, err := load(opts, nil, []string{"./b"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(pkgs) != 1 || pkgs[0].Syntax == nil {
+			t.Fatalf("%s: no syntax for the root package", tc.a.Name)
+		}
+		dep := pkgs[0].Imports["example.com/facts/a"]
+		if dep == nil || dep.Types == nil {
+			t.Fatalf("%s: no types for dependency", tc.a.Name)
+		}
+		if got := dep.Syntax != nil; got != tc.want {
+			t.Errorf("%s: dependency loaded with syntax = %v, want %v", tc.a.Name, got, tc.want)
+		}
+	}
+}
+
+func TestLoadGoMod(t *testing.T) {
+	opts := &Options{
+		Analyzers: []*analysis.Analyzer{redundantbranch.Analyzer},
+		Dir:       filepath.Join("testdata", "facts"),
+	}
+	pkgs, err := load(opts, nil, []string{"./..."})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := filepath.Abs(filepath.Join("testdata", "facts", "go.mod"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range pkgs {
+		if n := len(p.OtherFiles); n == 0 || p.OtherFiles[n-1] != want {
+			t.Errorf("%s: OtherFiles = %v, want %s", p.ID, p.OtherFiles, want)
+		}
+	}
+}
+
+// markedFact is exported for functions with a //marked comment.
+type markedFact struct {
+	Marked bool
+}
+
+func (*markedFact) AFact() {}
+
+// markedAnalyzer reports calls to marked functions and records the
+// packages it ran on.
+type markedAnalyzer struct {
+	mu  sync.Mutex
+	ran map[string]bool
+}
+
+func (m *markedAnalyzer) analyzer() *analysis.Analyzer {
+	return &analysis.Analyzer{
+		Name:      "marked",
+		Doc:       "report calls to marked functions",
+		FactTypes: []analysis.Fact{new(markedFact)},
+		Run:       m.run,
+	}
+}
+
+func (m *markedAnalyzer) run(pass *analysis.Pass) (interface{}, error) {
+	m.mu.Lock()
+	m.ran[pass.Pkg.Path()] = true
+	m.mu.Unlock()
+
+	for _, f := range pass.Files {
+		ast.Inspect(f, func(n ast.Node) bool {
+			switch n := n.(type) {
+			case *ast.FuncDecl:
+				if n.Doc != nil && strings.Contains(n.Doc.Text(), "marked") {
+					pass.ExportObjectFact(pass.TypesInfo.Defs[n.Name], &markedFact{true})
+				}
+			case *ast.CallExpr:
+				sel, ok := n.Fun.(*ast.SelectorExpr)
+				if !ok {
+					break
+				}
+				fn, ok := pass.TypesInfo.Uses[sel.Sel].(*types.Func)
+				if ok && pass.ImportObjectFact(fn, new(markedFact)) {
+					pass.Reportf(n.Pos(), "call to marked function %s", fn.Name())
+				}
+			}
+			return true
+		})
+	}
+	return nil, nil
+}
+
+func TestFacts(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gotools")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for _, name := range []string{"facts.gob", "facts.json"} {
+		t.Run(name, func(t *testing.T) {
+			file := filepath.Join(dir, name)
+			m := &markedAnalyzer{ran: make(map[string]bool)}
+			opts := &Options{
+				Analyzers:   []*analysis.Analyzer{m.analyzer()},
+				Dir:         filepath.Join("testdata", "facts"),
+				ExportFacts: file,
+			}
+			if _, err := Run(opts, "./a"); err != nil {
+				t.Fatal(err)
+			}
+			if !m.ran["example.com/facts/a"] {
+				t.Fatal("analyzer did not run on example.com/facts/a")
+			}
+
+			m = &markedAnalyzer{ran: make(map[string]bool)}
+			opts = &Options{
+				Analyzers:   []*analysis.Analyzer{m.analyzer()},
+				Dir:         filepath.Join("testdata", "facts"),
+				ImportFacts: []string{file},
+			}
+			diags, err := Run(opts, "./b")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if m.ran["example.com/facts/a"] {
+				t.Error("analyzer ran on example.com/facts/a, despite imported facts")
+			}
+			if len(diags) != 1 || diags[0].Message != "call to marked function Marked" {
+				t.Errorf("Run reported %v, want one call to Marked", diags)
+			}
+		})
+	}
+}
+
+func TestWork(t *testing.T) {
+	// The go command rejects -mod=mod in workspace mode.
+	for _, k := range []string{"GOFLAGS", "GOWORK"} {
+		defer os.Setenv(k, os.Getenv(k))
+		os.Unsetenv(k)
+	}
+	m := &markedAnalyzer{ran: make(map[string]bool)}
+	opts := &Options{
+		Analyzers: []*analysis.Analyzer{m.analyzer()},
+		Dir:       filepath.Join("testdata", "work"),
+	}
+	diags, err := Run(opts, "./...")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range []string{"example.com/work/a", "example.com/work/b"} {
+		if !m.ran[p] {
+			t.Errorf("analyzer did not run on %s", p)
+		}
+	}
+	if len(diags) != 1 || diags[0].Package != "example.com/work/b" {
+		t.Errorf("Run reported %v, want one call to Marked in example.com/work/b", diags)
+	}
+}
+
+func TestFactsInvalidation(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gotools")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	write := func(name, content string) {
+		name = filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(name), 0777); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(name, []byte(content), 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("go.mod", "module example.com/facts\n")
+	write("a/a.go", "package a\n\n//marked\nfunc Marked() {}\n\nfunc Unmarked() {}\n")
+	write("b/b.go", "package b\n\nimport \"example.com/facts/a\"\n\nfunc F() {\n\ta.Marked()\n\ta.Unmarked()\n}\n")
+	write("c/c.go", "package c\n\nimport \"example.com/facts/b\"\n\nfunc G() { b.F() }\n")
+	file := filepath.Join(dir, "facts.json")
+
+	run := func(export bool) map[string]bool {
+		t.Helper()
+		m := &markedAnalyzer{ran: make(map[string]bool)}
+		opts := &Options{
+			Analyzers: []*analysis.Analyzer{m.analyzer()},
+			Dir:       dir,
+		}
+		if export {
+			opts.ExportFacts = file
+		} else {
+			opts.ImportFacts = []string{file}
+		}
+		if _, err := Run(opts, "./c"); err != nil {
+			t.Fatal(err)
+		}
+		return m.ran
+	}
+	check := func(ran map[string]bool, want ...string) {
+		t.Helper()
+		var got []string
+		for p := range ran {
+			got = append(got, strings.TrimPrefix(p, "example.com/facts/"))
+		}
+		sort.Strings(got)
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("analyzer ran on %v, want %v", got, want)
+		}
+	}
+
+	check(run(true), "a", "b", "c")
+	check(run(false), "c")
+
+	// A change not affecting the facts of a doesn't affect b.
+	write("a/a.go", "package a\n\n//marked\nfunc Marked() { println() }\n\nfunc Unmarked() {}\n")
+	check(run(false), "a", "c")
+
+	// A new fact for a function used by b does.
+	write("a/a.go", "package a\n\n//marked\nfunc Marked() {}\n\n//marked\nfunc Unmarked() {}\n")
+	check(run(false), "a", "b", "c")
+
+	// So does a change of b itself.
+	check(run(true), "a", "b", "c")
+	write("b/b.go", "package b\n\nimport \"example.com/facts/a\"\n\nfunc F() {\n\ta.Marked()\n}\n")
+	check(run(false), "b", "c")
+}
+
+func TestLoadPlugin(t *testing.T) {
+	if _, err := LoadPlugin(filepath.Join("testdata", "missing.so")); err == nil {
+		t.Error("LoadPlugin of a missing file succeeded")
+	}
+	if testing.Short() {
+		t.Skip("building plugins is slow")
+	}
+	dir, err := ioutil.TempDir("", "plugin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "plugin.so")
+	cmd := exec.Command("go", "build", "-buildmode=plugin", "-o", file, "./testdata/plugin")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		// Plugins need cgo and a supported platform.
+		t.Skipf("can't build plugin: %v\n%s", err, out)
+	}
+	as, err := LoadPlugin(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(as) != 1 || as[0].Name != "nopanic" {
+		t.Fatalf("LoadPlugin = %v, want the nopanic analyzer", as)
+	}
+}
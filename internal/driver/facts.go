@@ -0,0 +1,468 @@
+// Copyright 2019 Axel Wagner
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package driver
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/gob"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"go/types"
+	"hash"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"sort"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/packages"
+	"golang.org/x/tools/go/types/objectpath"
+)
+
+// A fact file stores the facts computed for a set of packages, so that they
+// can be imported by later runs instead of analyzing those packages again.
+// This mirrors how export data is used by the compiler and enables
+// separate-compilation workflows, like Bazel or please.
+//
+// Facts are stored in one of two encodings, chosen by the extension of the
+// file: ".json" files use encoding/json, all others encoding/gob. In both
+// cases, fact types must be encodable by the respective package.
+type factFile struct {
+	Packages []factPackage
+}
+
+// A factPackage holds the facts of one analyzer for one package. It is
+// recorded even if there are no facts, to mark the package as analyzed.
+//
+// To detect stale facts, it also records a hash of the inputs of the
+// package and the facts of other packages the analyzer looked up. After a
+// change to a dependency, the facts of the package are only computed again
+// if one of those facts changed, not because of the change itself.
+type factPackage struct {
+	Analyzer string
+	Path     string
+	Facts    []factEntry
+
+	// Hash is the hash of the sources of the package and the API of its
+	// direct imports. Facts without a hash are never considered stale.
+	Hash string `json:",omitempty"`
+
+	// Uses lists the facts of other packages looked up by the analyzer.
+	// If UsesAll is set, the analyzer looked at all facts of its
+	// dependencies, so Uses lists them all.
+	Uses    []factUse `json:",omitempty"`
+	UsesAll bool      `json:",omitempty"`
+}
+
+// A factEntry is a single fact. Object is empty for package facts.
+type factEntry struct {
+	Object objectpath.Path `json:",omitempty"`
+	Type   string
+	Data   []byte
+}
+
+// A factUse is a fact looked up by an analyzer. Hash is the hash of its
+// encoding, or empty if there was no such fact.
+type factUse struct {
+	Package string
+	Object  objectpath.Path `json:",omitempty"`
+	Type    string
+	Hash    string `json:",omitempty"`
+}
+
+type factUseKey struct {
+	obj types.Object // nil for package facts
+	pkg *types.Package
+	typ reflect.Type
+}
+
+type factEncoding int
+
+const (
+	gobFacts factEncoding = iota
+	jsonFacts
+)
+
+func encodingFor(path string) factEncoding {
+	if filepath.Ext(path) == ".json" {
+		return jsonFacts
+	}
+	return gobFacts
+}
+
+func (e factEncoding) encode(v interface{}) ([]byte, error) {
+	if e == jsonFacts {
+		return json.Marshal(v)
+	}
+	buf := new(bytes.Buffer)
+	err := gob.NewEncoder(buf).Encode(v)
+	return buf.Bytes(), err
+}
+
+func (e factEncoding) decode(b []byte, v interface{}) error {
+	if e == jsonFacts {
+		return json.Unmarshal(b, v)
+	}
+	return gob.NewDecoder(bytes.NewReader(b)).Decode(v)
+}
+
+// factHash returns the hash of the encoding of f, or "" if f is nil.
+func (e factEncoding) factHash(f analysis.Fact) string {
+	if f == nil {
+		return ""
+	}
+	b, err := e.encode(f)
+	if err != nil {
+		// Such facts can't be written either, so there is no use in
+		// comparing them.
+		return "invalid"
+	}
+	h := sha256.Sum256(b)
+	return hex.EncodeToString(h[:])
+}
+
+// factTypeName returns the name identifying a fact type in a fact file.
+func factTypeName(t reflect.Type) string {
+	return t.Elem().PkgPath() + "." + t.Elem().Name()
+}
+
+// factStore holds facts imported from fact files, indexed by analyzer and
+// package path.
+type factStore map[string]map[string]*importedFacts
+
+type importedFacts struct {
+	factPackage
+	enc factEncoding
+}
+
+// readFacts reads all given fact files.
+func readFacts(paths []string) (factStore, error) {
+	s := make(factStore)
+	for _, p := range paths {
+		enc := encodingFor(p)
+		b, err := ioutil.ReadFile(p)
+		if err != nil {
+			return nil, err
+		}
+		var ff factFile
+		if err := enc.decode(b, &ff); err != nil {
+			return nil, fmt.Errorf("reading facts from %s: %v", p, err)
+		}
+		for _, fp := range ff.Packages {
+			m := s[fp.Analyzer]
+			if m == nil {
+				m = make(map[string]*importedFacts)
+				s[fp.Analyzer] = m
+			}
+			m[fp.Path] = &importedFacts{fp, enc}
+		}
+	}
+	return s, nil
+}
+
+// lookup returns the imported facts of a for the package with the given
+// path, or nil.
+func (s factStore) lookup(a *analysis.Analyzer, path string) *importedFacts {
+	return s[a.Name][path]
+}
+
+// use records that the analyzer looked up the fact k, with the result f.
+func (act *action) use(k factUseKey, f analysis.Fact) {
+	if k.pkg == nil {
+		// Facts of the universe scope can't be exported anyway.
+		return
+	}
+	act.usesMu.Lock()
+	defer act.usesMu.Unlock()
+	if act.uses == nil {
+		act.uses = make(map[factUseKey]analysis.Fact)
+	}
+	act.uses[k] = f
+}
+
+// useAll records that the analyzer looked at all facts.
+func (act *action) useAll() {
+	act.usesMu.Lock()
+	act.usesAll = true
+	act.usesMu.Unlock()
+}
+
+// importedValid returns whether the imported facts of act are still valid,
+// that is, whether all facts looked up when computing them are unchanged.
+// It must be called after inheriting the facts of the dependencies.
+func (act *action) importedValid() bool {
+	imp := act.imported
+	if imp.Hash == "" {
+		return true
+	}
+	byName := make(map[string]reflect.Type)
+	for _, f := range act.a.FactTypes {
+		t := reflect.TypeOf(f)
+		byName[factTypeName(t)] = t
+	}
+	pkgs := make(map[string]*types.Package)
+	var visit func(p *types.Package)
+	visit = func(p *types.Package) {
+		for _, p := range p.Imports() {
+			if pkgs[p.Path()] == nil {
+				pkgs[p.Path()] = p
+				visit(p)
+			}
+		}
+	}
+	visit(act.pkg.Types)
+
+	found := 0
+	for _, u := range imp.Uses {
+		var f analysis.Fact
+		t, ok := byName[u.Type]
+		if !ok {
+			return false
+		}
+		if pkg := pkgs[u.Package]; pkg != nil {
+			if u.Object == "" {
+				f = act.packageFacts[packageFactKey{pkg, t}]
+			} else if obj, err := objectpath.Object(pkg, u.Object); err == nil {
+				f = act.objectFacts[objectFactKey{obj, t}]
+			}
+		}
+		if imp.enc.factHash(f) != u.Hash {
+			return false
+		}
+		if f != nil {
+			found++
+		}
+	}
+	// At this point, all facts are inherited ones.
+	return !imp.UsesAll || found == len(act.objectFacts)+len(act.packageFacts)
+}
+
+// importFacts decodes facts, which were computed for the package of act.
+func (act *action) importFacts(facts *importedFacts) error {
+	byName := make(map[string]reflect.Type)
+	for _, f := range act.a.FactTypes {
+		t := reflect.TypeOf(f)
+		byName[factTypeName(t)] = t
+	}
+	for _, e := range facts.Facts {
+		t, ok := byName[e.Type]
+		if !ok {
+			return fmt.Errorf("unknown fact type %s", e.Type)
+		}
+		f := reflect.New(t.Elem()).Interface().(analysis.Fact)
+		if err := facts.enc.decode(e.Data, f); err != nil {
+			return fmt.Errorf("decoding fact %s: %v", e.Type, err)
+		}
+		if e.Object == "" {
+			act.packageFacts[packageFactKey{act.pkg.Types, t}] = f
+			continue
+		}
+		obj, err := objectpath.Object(act.pkg.Types, e.Object)
+		if err != nil {
+			// The package changed since the facts were computed.
+			return fmt.Errorf("stale fact for %s.%s: %v", facts.Path, e.Object, err)
+		}
+		act.objectFacts[objectFactKey{obj, t}] = f
+	}
+	return nil
+}
+
+// exportFacts returns the facts owned by the package of act.
+func (act *action) exportFacts(enc factEncoding) (factPackage, error) {
+	out := factPackage{
+		Analyzer: act.a.Name,
+		Path:     act.pkg.PkgPath,
+		Hash:     act.hash,
+	}
+	if act.imported != nil {
+		out.Uses, out.UsesAll = act.imported.Uses, act.imported.UsesAll
+	} else {
+		out.Uses, out.UsesAll = act.exportUses(enc), act.usesAll
+	}
+	add := func(obj types.Object, f analysis.Fact) error {
+		e := factEntry{Type: factTypeName(reflect.TypeOf(f))}
+		if obj != nil {
+			p, err := objectpath.For(obj)
+			if err != nil {
+				// Facts about objects not reachable from the package
+				// scope can't be used by other packages anyway.
+				return nil
+			}
+			e.Object = p
+		}
+		b, err := enc.encode(f)
+		if err != nil {
+			return fmt.Errorf("encoding fact %T: %v", f, err)
+		}
+		e.Data = b
+		out.Facts = append(out.Facts, e)
+		return nil
+	}
+	for k, f := range act.objectFacts {
+		if k.obj.Pkg() != act.pkg.Types {
+			continue
+		}
+		if err := add(k.obj, f); err != nil {
+			return out, err
+		}
+	}
+	for k, f := range act.packageFacts {
+		if k.pkg != act.pkg.Types {
+			continue
+		}
+		if err := add(nil, f); err != nil {
+			return out, err
+		}
+	}
+	sort.Slice(out.Facts, func(i, j int) bool {
+		a, b := out.Facts[i], out.Facts[j]
+		if a.Object != b.Object {
+			return a.Object < b.Object
+		}
+		return a.Type < b.Type
+	})
+	return out, nil
+}
+
+// exportUses returns the facts looked up by the analyzer, when it ran.
+func (act *action) exportUses(enc factEncoding) []factUse {
+	uses := make(map[factUseKey]analysis.Fact)
+	for k, f := range act.uses {
+		uses[k] = f
+	}
+	if act.usesAll {
+		for k, f := range act.objectFacts {
+			if k.obj.Pkg() != act.pkg.Types {
+				uses[factUseKey{k.obj, k.obj.Pkg(), k.typ}] = f
+			}
+		}
+		for k, f := range act.packageFacts {
+			if k.pkg != act.pkg.Types {
+				uses[factUseKey{nil, k.pkg, k.typ}] = f
+			}
+		}
+	}
+	var out []factUse
+	for k, f := range uses {
+		u := factUse{
+			Package: k.pkg.Path(),
+			Type:    factTypeName(k.typ),
+			Hash:    enc.factHash(f),
+		}
+		if k.obj != nil {
+			p, err := objectpath.For(k.obj)
+			if err != nil {
+				continue
+			}
+			u.Object = p
+		}
+		out = append(out, u)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		a, b := out[i], out[j]
+		if a.Package != b.Package {
+			return a.Package < b.Package
+		}
+		if a.Object != b.Object {
+			return a.Object < b.Object
+		}
+		return a.Type < b.Type
+	})
+	return out
+}
+
+// sourceHash returns a hash of the inputs determining the facts of pkg,
+// apart from the facts of its dependencies: its files and the API of its
+// direct imports. The bodies of functions in dependencies don't influence
+// it. Files in overlay are hashed with their content there.
+func sourceHash(pkg *packages.Package, overlay map[string][]byte) string {
+	h := sha256.New()
+	files := append(append([]string(nil), pkg.CompiledGoFiles...), pkg.OtherFiles...)
+	if len(pkg.CompiledGoFiles) == 0 {
+		files = append(files, pkg.GoFiles...)
+	}
+	sort.Strings(files)
+	for _, name := range files {
+		b, err := readFile(name, overlay)
+		if err != nil {
+			// Never matches, so the facts are computed again.
+			return ""
+		}
+		fmt.Fprintf(h, "%s %d\n", filepath.Base(name), len(b))
+		h.Write(b)
+	}
+	var paths []string
+	for path := range pkg.Imports {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		if imp := pkg.Imports[path].Types; imp != nil {
+			writeAPI(h, imp)
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// writeAPI writes the exported and unexported package-level declarations
+// of pkg to h.
+func writeAPI(h hash.Hash, pkg *types.Package) {
+	fmt.Fprintf(h, "package %s\n", pkg.Path())
+	scope := pkg.Scope()
+	for _, name := range scope.Names() {
+		obj := scope.Lookup(name)
+		fmt.Fprintln(h, types.ObjectString(obj, nil))
+		if tn, ok := obj.(*types.TypeName); ok {
+			if n, ok := tn.Type().(*types.Named); ok {
+				for i := 0; i < n.NumMethods(); i++ {
+					fmt.Fprintln(h, types.ObjectString(n.Method(i), nil))
+				}
+			}
+		}
+	}
+}
+
+// writeFacts writes the facts of all actions using facts to path.
+func writeFacts(path string, actions []*action) error {
+	enc := encodingFor(path)
+	var ff factFile
+	for _, act := range actions {
+		// Actions never executed (as their results were not needed) have
+		// no facts. Those stopped by the limits of the run have incomplete
+		// ones, which later runs must not trust.
+		if len(act.a.FactTypes) == 0 || act.err != nil || act.objectFacts == nil || act.stopped != notStopped {
+			continue
+		}
+		fp, err := act.exportFacts(enc)
+		if err != nil {
+			return fmt.Errorf("%v: %v", act, err)
+		}
+		ff.Packages = append(ff.Packages, fp)
+	}
+	sort.Slice(ff.Packages, func(i, j int) bool {
+		a, b := ff.Packages[i], ff.Packages[j]
+		if a.Analyzer != b.Analyzer {
+			return a.Analyzer < b.Analyzer
+		}
+		return a.Path < b.Path
+	})
+	b, err := enc.encode(ff)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, b, 0666)
+}
@@ -0,0 +1,97 @@
+// Copyright 2019 Axel Wagner
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package driver
+
+import (
+	"go/ast"
+	"go/token"
+	"path"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// An Ignore is a linter directive suppressing diagnostics. There are two
+// forms:
+//
+//	//lint:ignore analyzer[,analyzer...] reason
+//	//lint:file-ignore analyzer[,analyzer...] reason
+//
+// The first suppresses diagnostics on the line of the directive and the one
+// following it, the second those in the whole file. Instead of analyzer
+// names, rule IDs (like GT1001) may be given. Both may be patterns as
+// understood by path.Match, like "*". The reason is mandatory;
+// directives without one are ignored.
+type Ignore struct {
+	Posn      token.Position
+	Analyzers []string
+	Reason    string
+	File      bool
+}
+
+// matches returns whether the directive suppresses d.
+func (ig *Ignore) matches(d Diagnostic) bool {
+	if d.Posn.Filename != ig.Posn.Filename {
+		return false
+	}
+	if !ig.File && d.Posn.Line != ig.Posn.Line && d.Posn.Line != ig.Posn.Line+1 {
+		return false
+	}
+	for _, pat := range ig.Analyzers {
+		if ok, _ := path.Match(pat, d.Analyzer); ok {
+			return true
+		}
+		if ok, _ := path.Match(pat, d.Rule); ok && d.Rule != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// parseIgnores returns the linter directives in pkg.
+func parseIgnores(pkg *packages.Package) []*Ignore {
+	var out []*Ignore
+	for _, f := range pkg.Syntax {
+		for _, cg := range f.Comments {
+			for _, c := range cg.List {
+				if ig := parseIgnore(pkg, c); ig != nil {
+					out = append(out, ig)
+				}
+			}
+		}
+	}
+	return out
+}
+
+func parseIgnore(pkg *packages.Package, c *ast.Comment) *Ignore {
+	var file bool
+	switch {
+	case strings.HasPrefix(c.Text, "//lint:ignore "):
+	case strings.HasPrefix(c.Text, "//lint:file-ignore "):
+		file = true
+	default:
+		return nil
+	}
+	fields := strings.Fields(c.Text)
+	if len(fields) < 3 {
+		return nil
+	}
+	return &Ignore{
+		Posn:      pkg.Fset.Position(c.Pos()),
+		Analyzers: strings.Split(fields[1], ","),
+		Reason:    strings.Join(fields[2:], " "),
+		File:      file,
+	}
+}
@@ -0,0 +1,115 @@
+// Copyright 2019 Axel Wagner
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package driver
+
+import (
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// A limiter bounds the memory used by running analyzers. Normally, actions
+// run in parallel. Once the heap grows beyond the limit, actions run one at
+// a time, so memory of finished packages can be released before more is
+// allocated.
+type limiter struct {
+	max uint64
+	mu  sync.RWMutex
+}
+
+// do calls f, after waiting for other calls to finish if the heap exceeds
+// the limit.
+func (l *limiter) do(f func()) {
+	if l.max == 0 {
+		f()
+		return
+	}
+	if heapSize() > l.max {
+		runtime.GC()
+		if heapSize() > l.max {
+			l.mu.Lock()
+			defer l.mu.Unlock()
+			f()
+			return
+		}
+	}
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	f()
+}
+
+func heapSize() uint64 {
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+	return ms.HeapAlloc
+}
+
+// A pkgState tracks the actions on a package which are not finished yet.
+// Once all are, the syntax trees and type information of the package and
+// the results of the actions are released. Only the types, facts and
+// diagnostics are retained, as other packages or the report need them.
+type pkgState struct {
+	pkg     *packages.Package
+	pending int32
+	actions []*action
+
+	// started is when the first analyzer started running on the package,
+	// for Options.PackageTimeout.
+	startOnce sync.Once
+	started   time.Time
+}
+
+func (s *pkgState) start() time.Time {
+	s.startOnce.Do(func() { s.started = time.Now() })
+	return s.started
+}
+
+func (s *pkgState) add(act *action) {
+	s.pending++
+	s.actions = append(s.actions, act)
+}
+
+func (s *pkgState) done() {
+	if atomic.AddInt32(&s.pending, -1) > 0 {
+		return
+	}
+	for _, act := range s.actions {
+		act.result = nil
+	}
+	s.pkg.Syntax = nil
+	s.pkg.TypesInfo = nil
+}
+
+// finish marks act as finished and skips the dependencies no action needs
+// anymore. It must be called exactly once, from act.once.
+func (act *action) finish() {
+	for _, dep := range act.deps {
+		if atomic.AddInt32(&dep.users, -1) == 0 {
+			dep.once.Do(dep.skip)
+		}
+	}
+	if act.state != nil {
+		act.state.done()
+	}
+}
+
+// skip finishes act without running it, as no other action needs it.
+func (act *action) skip() {
+	act.skipped = true
+	act.finish()
+}
@@ -0,0 +1,66 @@
+// Copyright 2019 Axel Wagner
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package driver
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+)
+
+// ReadOverlay reads an overlay file in the format of the -overlay flag of
+// go build, which editors use for unsaved buffers:
+//
+//	{"Replace": {"/src/a/a.go": "/tmp/buffer-a.go"}}
+//
+// It returns a map from the absolute names of the replaced files to the
+// content of their replacements, for Options.Overlay. Relative names are
+// relative to the current directory. Deleting files, by replacing them with
+// an empty name, is not supported.
+func ReadOverlay(file string) (map[string][]byte, error) {
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var o struct {
+		Replace map[string]string
+	}
+	if err := json.Unmarshal(b, &o); err != nil {
+		return nil, fmt.Errorf("%s: %v", file, err)
+	}
+	overlay := make(map[string][]byte)
+	for name, repl := range o.Replace {
+		if repl == "" {
+			return nil, fmt.Errorf("%s: can't delete %s, deleting files is not supported", file, name)
+		}
+		abs, err := filepath.Abs(name)
+		if err != nil {
+			return nil, err
+		}
+		if overlay[abs], err = ioutil.ReadFile(repl); err != nil {
+			return nil, err
+		}
+	}
+	return overlay, nil
+}
+
+// readFile reads the named file, or returns its content in overlay.
+func readFile(name string, overlay map[string][]byte) ([]byte, error) {
+	if b, ok := overlay[name]; ok {
+		return b, nil
+	}
+	return ioutil.ReadFile(name)
+}
@@ -0,0 +1,65 @@
+// Copyright 2019 Axel Wagner
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package driver
+
+import (
+	"fmt"
+	"plugin"
+
+	"golang.org/x/tools/go/analysis"
+)
+
+// LoadPlugin loads additional analyzers from the Go plugin in file, so that
+// in-house analyzers can run next to the built-in ones. The plugin is a main
+// package built with go build -buildmode=plugin, which exports a variable
+//
+//	var Analyzers []*analysis.Analyzer
+//
+// or a single
+//
+//	var Analyzer *analysis.Analyzer
+//
+// It must be built with the same version of Go and of the packages it
+// shares with the program loading it, including golang.org/x/tools. Go
+// plugins need cgo and are only supported on some platforms, like linux and
+// darwin; elsewhere, LoadPlugin returns an error.
+func LoadPlugin(file string) ([]*analysis.Analyzer, error) {
+	p, err := plugin.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	var as []*analysis.Analyzer
+	if sym, err := p.Lookup("Analyzers"); err == nil {
+		v, ok := sym.(*[]*analysis.Analyzer)
+		if !ok {
+			return nil, fmt.Errorf("%s: Analyzers is a %T, not a []*analysis.Analyzer", file, sym)
+		}
+		as = append(as, *v...)
+	}
+	if sym, err := p.Lookup("Analyzer"); err == nil {
+		v, ok := sym.(**analysis.Analyzer)
+		if !ok {
+			return nil, fmt.Errorf("%s: Analyzer is a %T, not an *analysis.Analyzer", file, sym)
+		}
+		as = append(as, *v)
+	}
+	if len(as) == 0 {
+		return nil, fmt.Errorf("%s: plugin has neither an Analyzers nor an Analyzer variable", file)
+	}
+	if err := analysis.Validate(as); err != nil {
+		return nil, fmt.Errorf("%s: %v", file, err)
+	}
+	return as, nil
+}
@@ -0,0 +1,77 @@
+// Copyright 2019 Axel Wagner
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package driver
+
+import (
+	"fmt"
+	"hash/fnv"
+	"strconv"
+	"strings"
+)
+
+// A Shard is a part of the packages to analyze, so that the analysis can be
+// split across several runs, like parallel CI jobs. Packages are assigned
+// to shards by a hash of their import path, which doesn't depend on the
+// other packages, so every package is analyzed by exactly one of the runs
+// analyzing the same patterns with different shards.
+//
+// The zero Shard contains all packages.
+type Shard struct {
+	Index int // starting at 1
+	Count int
+}
+
+// ParseShard parses a shard of the form i/n, with 1 <= i <= n.
+func ParseShard(s string) (Shard, error) {
+	i := strings.IndexByte(s, '/')
+	if i < 0 {
+		return Shard{}, fmt.Errorf("invalid shard %q: want i/n", s)
+	}
+	index, err1 := strconv.Atoi(s[:i])
+	count, err2 := strconv.Atoi(s[i+1:])
+	if err1 != nil || err2 != nil || count < 1 || index < 1 || index > count {
+		return Shard{}, fmt.Errorf("invalid shard %q: want i/n with 1 <= i <= n", s)
+	}
+	return Shard{Index: index, Count: count}, nil
+}
+
+func (s *Shard) String() string {
+	if s.Count == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%d/%d", s.Index, s.Count)
+}
+
+// Set implements flag.Value.
+func (s *Shard) Set(v string) error {
+	sh, err := ParseShard(v)
+	if err != nil {
+		return err
+	}
+	*s = sh
+	return nil
+}
+
+// Contains returns whether the package with the given import path belongs
+// to the shard. External test packages belong to the shard of the package
+// they test.
+func (s Shard) Contains(pkgPath string) bool {
+	if s.Count <= 1 {
+		return true
+	}
+	h := fnv.New32a()
+	h.Write([]byte(strings.TrimSuffix(pkgPath, "_test")))
+	return int(h.Sum32()%uint32(s.Count)) == s.Index-1
+}
@@ -0,0 +1,111 @@
+// Copyright 2019 Axel Wagner
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package driver
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"strings"
+)
+
+// A Target is a build configuration to analyze packages in.
+type Target struct {
+	GOOS   string
+	GOARCH string
+	Tags   []string
+}
+
+// ParseTarget parses a target of the form goos/goarch[:tag,tag...]. Either
+// of goos and goarch may be empty, in which case the value from the
+// environment is used.
+func ParseTarget(s string) (Target, error) {
+	var t Target
+	if i := strings.IndexByte(s, ':'); i >= 0 {
+		for _, tag := range strings.Split(s[i+1:], ",") {
+			if tag = strings.TrimSpace(tag); tag != "" {
+				t.Tags = append(t.Tags, tag)
+			}
+		}
+		s = s[:i]
+	}
+	i := strings.IndexByte(s, '/')
+	if i < 0 {
+		return t, fmt.Errorf("invalid target %q: want goos/goarch[:tags]", s)
+	}
+	t.GOOS, t.GOARCH = s[:i], s[i+1:]
+	if strings.ContainsAny(t.GOOS+t.GOARCH, "/ ") {
+		return t, fmt.Errorf("invalid target %q: want goos/goarch[:tags]", s)
+	}
+	return t, nil
+}
+
+func (t Target) String() string {
+	s := t.GOOS + "/" + t.GOARCH
+	if len(t.Tags) > 0 {
+		s += ":" + strings.Join(t.Tags, ",")
+	}
+	return s
+}
+
+func (t Target) env() []string {
+	env := os.Environ()
+	if t.GOOS != "" {
+		env = append(env, "GOOS="+t.GOOS)
+	}
+	if t.GOARCH != "" {
+		env = append(env, "GOARCH="+t.GOARCH)
+	}
+	// Cross-configurations can't use cgo, as we usually lack a
+	// cross-compiler. Disabling it consistently makes results comparable,
+	// unless the environment asks for it explicitly.
+	if t.cross() && os.Getenv("CGO_ENABLED") == "" {
+		env = append(env, "CGO_ENABLED=0")
+	}
+	return env
+}
+
+// cross returns whether t is for another platform than the host.
+func (t Target) cross() bool {
+	return t.GOOS != "" && t.GOOS != runtime.GOOS || t.GOARCH != "" && t.GOARCH != runtime.GOARCH
+}
+
+func (t Target) buildFlags() []string {
+	if len(t.Tags) == 0 {
+		return nil
+	}
+	return []string{"-tags=" + strings.Join(t.Tags, ",")}
+}
+
+// Targets implements flag.Value for a repeatable list of targets.
+type Targets []Target
+
+func (ts *Targets) String() string {
+	var s []string
+	for _, t := range *ts {
+		s = append(s, t.String())
+	}
+	return strings.Join(s, " ")
+}
+
+// Set implements flag.Value.
+func (ts *Targets) Set(s string) error {
+	t, err := ParseTarget(s)
+	if err != nil {
+		return err
+	}
+	*ts = append(*ts, t)
+	return nil
+}
@@ -0,0 +1,3 @@
+module example.com/work/a
+
+go 1.12
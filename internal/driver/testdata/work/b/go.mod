@@ -0,0 +1,5 @@
+module example.com/work/b
+
+go 1.12
+
+require example.com/work/a v0.0.0
@@ -0,0 +1,513 @@
+// Copyright 2019 Axel Wagner
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package filter implements a small expression language selecting
+// diagnostics. An expression combines predicates with and, or, not and
+// parentheses (&&, || and ! may be used instead):
+//
+//	severity>=warning and not path:"**/*_test.go"
+//	(analyzer:redundantbranch or rule:GT1001) && message:"^goto"
+//
+// A predicate has the form field:value, which selects diagnostics whose
+// field matches the value, or field!=value, which selects the others.
+// Values are either bare words or Go string literals. The fields are
+//
+//	analyzer  the name of the analyzer, matched with path.Match
+//	rule      the rule ID, matched with path.Match
+//	category  the category of the diagnostic, matched with path.Match
+//	tag       a tag of the rule, like performance, matched with path.Match
+//	path      the file, relative to the root and slash-separated, matched
+//	          with a glob pattern in which ** matches any number of
+//	          directories. A pattern without slash matches the base name.
+//	message   the message, matched with a regular expression
+//	severity  the severity; it can also be compared with <, <=, > and >=
+//	covered   the test coverage of the line (yes, no or unknown), if known
+//	          from a coverage profile
+//	age       the time since the line was last changed according to git
+//	          blame, like 30d, 8w or 1y; it can also be compared with <, <=,
+//	          > and >=. Diagnostics without blame information never match.
+package filter
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/Merovius/go-tools/internal/blame"
+	"github.com/Merovius/go-tools/internal/coverage"
+	"github.com/Merovius/go-tools/internal/driver"
+	"github.com/Merovius/go-tools/internal/rules"
+)
+
+// A Filter selects diagnostics.
+type Filter struct {
+	root string
+	expr node
+}
+
+// Parse parses a filter expression. Paths are matched relative to root.
+func Parse(expr, root string) (*Filter, error) {
+	p := &parser{lex: lexer{src: expr}}
+	p.next()
+	e, err := p.parseOr()
+	if err == nil && p.tok.kind != tokEOF {
+		err = p.errorf("unexpected %s", p.tok)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid filter %q: %v", expr, err)
+	}
+	return &Filter{root: root, expr: e}, nil
+}
+
+// Match returns whether f selects d.
+func (f *Filter) Match(d driver.Diagnostic) bool {
+	file := d.Posn.Filename
+	if rel, err := filepath.Rel(f.root, file); err == nil && !strings.HasPrefix(rel, "..") {
+		file = rel
+	}
+	return f.expr.match(&d, filepath.ToSlash(file))
+}
+
+// Apply returns the diagnostics selected by f.
+func (f *Filter) Apply(diags []driver.Diagnostic) []driver.Diagnostic {
+	var out []driver.Diagnostic
+	for _, d := range diags {
+		if f.Match(d) {
+			out = append(out, d)
+		}
+	}
+	return out
+}
+
+type node interface {
+	match(d *driver.Diagnostic, file string) bool
+}
+
+type andNode struct{ x, y node }
+
+func (n andNode) match(d *driver.Diagnostic, file string) bool {
+	return n.x.match(d, file) && n.y.match(d, file)
+}
+
+type orNode struct{ x, y node }
+
+func (n orNode) match(d *driver.Diagnostic, file string) bool {
+	return n.x.match(d, file) || n.y.match(d, file)
+}
+
+type notNode struct{ x node }
+
+func (n notNode) match(d *driver.Diagnostic, file string) bool {
+	return !n.x.match(d, file)
+}
+
+type predicate func(d *driver.Diagnostic, file string) bool
+
+func (p predicate) match(d *driver.Diagnostic, file string) bool {
+	return p(d, file)
+}
+
+type parser struct {
+	lex lexer
+	tok item
+	err error
+}
+
+func (p *parser) next() {
+	if p.err != nil {
+		return
+	}
+	p.tok, p.err = p.lex.next()
+}
+
+func (p *parser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("at offset %d: %s", p.tok.pos, fmt.Sprintf(format, args...))
+}
+
+func (p *parser) parseOr() (node, error) {
+	x, err := p.parseAnd()
+	for err == nil && p.tok.kind == tokOr {
+		p.next()
+		var y node
+		y, err = p.parseAnd()
+		x = orNode{x, y}
+	}
+	return x, err
+}
+
+func (p *parser) parseAnd() (node, error) {
+	x, err := p.parseUnary()
+	for err == nil && p.tok.kind == tokAnd {
+		p.next()
+		var y node
+		y, err = p.parseUnary()
+		x = andNode{x, y}
+	}
+	return x, err
+}
+
+func (p *parser) parseUnary() (node, error) {
+	if p.err != nil {
+		return nil, p.err
+	}
+	switch p.tok.kind {
+	case tokNot:
+		p.next()
+		x, err := p.parseUnary()
+		return notNode{x}, err
+	case tokLparen:
+		p.next()
+		x, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.tok.kind != tokRparen {
+			return nil, p.errorf("expected ), got %s", p.tok)
+		}
+		p.next()
+		return x, p.err
+	case tokPred:
+		t := p.tok
+		pred, err := newPredicate(t.field, t.op, t.value)
+		if err != nil {
+			return nil, fmt.Errorf("at offset %d: %v", t.pos, err)
+		}
+		p.next()
+		return pred, p.err
+	}
+	return nil, p.errorf("unexpected %s", p.tok)
+}
+
+func newPredicate(field, op, value string) (node, error) {
+	switch field {
+	case "severity":
+		return severityPredicate(op, value)
+	case "age":
+		return agePredicate(op, value)
+	}
+	if op != ":" && op != "!=" {
+		return nil, fmt.Errorf("operator %s is only defined for severity and age", op)
+	}
+	var pred predicate
+	switch field {
+	case "analyzer", "rule", "category":
+		if _, err := path.Match(value, ""); err != nil {
+			return nil, fmt.Errorf("invalid pattern %q: %v", value, err)
+		}
+		pred = func(d *driver.Diagnostic, file string) bool {
+			var s string
+			switch field {
+			case "analyzer":
+				s = d.Analyzer
+			case "rule":
+				s = d.Rule
+			case "category":
+				s = d.Category
+			}
+			ok, _ := path.Match(value, s)
+			return ok
+		}
+	case "tag":
+		if _, err := path.Match(value, ""); err != nil {
+			return nil, fmt.Errorf("invalid pattern %q: %v", value, err)
+		}
+		pred = func(d *driver.Diagnostic, file string) bool {
+			r, _ := rules.ByID(d.Rule)
+			for _, t := range r.Tags {
+				if ok, _ := path.Match(value, t); ok {
+					return true
+				}
+			}
+			return false
+		}
+	case "path":
+		re, err := globRegexp(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern %q: %v", value, err)
+		}
+		base := !strings.Contains(value, "/")
+		pred = func(d *driver.Diagnostic, file string) bool {
+			if base {
+				file = path.Base(file)
+			}
+			return re.MatchString(file)
+		}
+	case "covered":
+		switch coverage.Status(value) {
+		case coverage.Covered, coverage.Uncovered, coverage.Unknown:
+		default:
+			return nil, fmt.Errorf("invalid coverage %q (want yes, no or unknown)", value)
+		}
+		pred = func(d *driver.Diagnostic, file string) bool {
+			return d.Annotations[coverage.Annotation] == value
+		}
+	case "message":
+		re, err := regexp.Compile(value)
+		if err != nil {
+			return nil, err
+		}
+		pred = func(d *driver.Diagnostic, file string) bool {
+			return re.MatchString(d.Message)
+		}
+	default:
+		return nil, fmt.Errorf("unknown field %q", field)
+	}
+	if op == "!=" {
+		return notNode{pred}, nil
+	}
+	return pred, nil
+}
+
+func severityPredicate(op, value string) (node, error) {
+	s, err := rules.ParseSeverity(value)
+	if err != nil {
+		return nil, err
+	}
+	var cmp func(x rules.Severity) bool
+	switch op {
+	case ":":
+		cmp = func(x rules.Severity) bool { return x == s }
+	case "!=":
+		cmp = func(x rules.Severity) bool { return x != s }
+	case "<":
+		cmp = func(x rules.Severity) bool { return x < s }
+	case "<=":
+		cmp = func(x rules.Severity) bool { return x <= s }
+	case ">":
+		cmp = func(x rules.Severity) bool { return x > s }
+	case ">=":
+		cmp = func(x rules.Severity) bool { return x >= s }
+	}
+	return predicate(func(d *driver.Diagnostic, file string) bool {
+		return cmp(d.Severity)
+	}), nil
+}
+
+func agePredicate(op, value string) (node, error) {
+	want, err := parseDays(value)
+	if err != nil {
+		return nil, err
+	}
+	return predicate(func(d *driver.Diagnostic, file string) bool {
+		a, ok := d.Annotations[blame.AgeAnnotation]
+		if !ok {
+			return false
+		}
+		age, err := parseDays(a)
+		if err != nil {
+			return false
+		}
+		switch op {
+		case ":":
+			return age == want
+		case "!=":
+			return age != want
+		case "<":
+			return age < want
+		case "<=":
+			return age <= want
+		case ">":
+			return age > want
+		default:
+			return age >= want
+		}
+	}), nil
+}
+
+// parseDays parses an age like 30d, 8w or 1y into days. A year has 365
+// days.
+func parseDays(s string) (int, error) {
+	units := map[byte]int{'d': 1, 'w': 7, 'y': 365}
+	if len(s) < 2 || units[s[len(s)-1]] == 0 {
+		return 0, fmt.Errorf("invalid age %q (want a number of days, weeks or years, like 30d, 8w or 1y)", s)
+	}
+	n, err := strconv.Atoi(s[:len(s)-1])
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid age %q (want a number of days, weeks or years, like 30d, 8w or 1y)", s)
+	}
+	return n * units[s[len(s)-1]], nil
+}
+
+// globRegexp translates a glob pattern to a regular expression. * and ?
+// don't match slashes, ** matches anything, and **/ any number of
+// directories.
+func globRegexp(glob string) (*regexp.Regexp, error) {
+	var b strings.Builder
+	b.WriteString("^")
+	for i := 0; i < len(glob); i++ {
+		switch c := glob[i]; c {
+		case '*':
+			if strings.HasPrefix(glob[i:], "**/") {
+				b.WriteString("(?:.*/)?")
+				i += 2
+			} else if strings.HasPrefix(glob[i:], "**") {
+				b.WriteString(".*")
+				i++
+			} else {
+				b.WriteString("[^/]*")
+			}
+		case '?':
+			b.WriteString("[^/]")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	b.WriteString("$")
+	return regexp.Compile(b.String())
+}
+
+type itemKind int
+
+const (
+	tokEOF itemKind = iota
+	tokAnd
+	tokOr
+	tokNot
+	tokLparen
+	tokRparen
+	tokPred
+)
+
+type item struct {
+	kind  itemKind
+	pos   int
+	field string
+	op    string
+	value string
+}
+
+func (t item) String() string {
+	switch t.kind {
+	case tokEOF:
+		return "end of expression"
+	case tokAnd:
+		return "and"
+	case tokOr:
+		return "or"
+	case tokNot:
+		return "not"
+	case tokLparen:
+		return "("
+	case tokRparen:
+		return ")"
+	}
+	return fmt.Sprintf("%s%s%q", t.field, t.op, t.value)
+}
+
+type lexer struct {
+	src string
+	pos int
+}
+
+func isIdent(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || c == '_'
+}
+
+func (l *lexer) next() (item, error) {
+	for l.pos < len(l.src) && strings.IndexByte(" \t\n\r", l.src[l.pos]) >= 0 {
+		l.pos++
+	}
+	t := item{pos: l.pos}
+	if l.pos == len(l.src) {
+		return t, nil
+	}
+	rest := l.src[l.pos:]
+	for _, op := range []struct {
+		s    string
+		kind itemKind
+	}{{"&&", tokAnd}, {"||", tokOr}, {"(", tokLparen}, {")", tokRparen}} {
+		if strings.HasPrefix(rest, op.s) {
+			l.pos += len(op.s)
+			t.kind = op.kind
+			return t, nil
+		}
+	}
+	if rest[0] == '!' && !strings.HasPrefix(rest, "!=") {
+		l.pos++
+		t.kind = tokNot
+		return t, nil
+	}
+	if !isIdent(rest[0]) {
+		return t, fmt.Errorf("at offset %d: unexpected %q", l.pos, rest[0])
+	}
+	i := 0
+	for i < len(rest) && isIdent(rest[i]) {
+		i++
+	}
+	word := rest[:i]
+	l.pos += i
+	rest = rest[i:]
+	var op string
+	for _, o := range []string{":", "!=", ">=", "<=", ">", "<"} {
+		if strings.HasPrefix(rest, o) {
+			op = o
+			break
+		}
+	}
+	if op == "" {
+		switch word {
+		case "and":
+			t.kind = tokAnd
+		case "or":
+			t.kind = tokOr
+		case "not":
+			t.kind = tokNot
+		default:
+			return t, fmt.Errorf("at offset %d: expected operator after %q", t.pos, word)
+		}
+		return t, nil
+	}
+	l.pos += len(op)
+	value, err := l.value()
+	if err != nil {
+		return t, err
+	}
+	t.kind, t.field, t.op, t.value = tokPred, word, op, value
+	return t, nil
+}
+
+// value lexes a bare or quoted value.
+func (l *lexer) value() (string, error) {
+	rest := l.src[l.pos:]
+	if strings.HasPrefix(rest, `"`) || strings.HasPrefix(rest, "`") {
+		q := rest[0]
+		for i := 1; i < len(rest); i++ {
+			if rest[i] == '\\' && q == '"' {
+				i++
+				continue
+			}
+			if rest[i] == q {
+				s, err := strconv.Unquote(rest[:i+1])
+				if err != nil {
+					return "", fmt.Errorf("at offset %d: %v", l.pos, err)
+				}
+				l.pos += i + 1
+				return s, nil
+			}
+		}
+		return "", fmt.Errorf("at offset %d: unterminated string", l.pos)
+	}
+	i := 0
+	for i < len(rest) && strings.IndexByte(" \t\n\r()", rest[i]) < 0 {
+		i++
+	}
+	if i == 0 {
+		return "", fmt.Errorf("at offset %d: missing value", l.pos)
+	}
+	l.pos += i
+	return rest[:i], nil
+}
@@ -0,0 +1,142 @@
+// Copyright 2019 Axel Wagner
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package filter
+
+import (
+	"go/token"
+	"testing"
+
+	"github.com/Merovius/go-tools/internal/driver"
+	"github.com/Merovius/go-tools/internal/rules"
+)
+
+var (
+	brk = driver.Diagnostic{
+		Analyzer: "redundantbranch",
+		Rule:     "GT1001",
+		Posn:     token.Position{Filename: "/src/a/a.go", Line: 3},
+		Message:  "break does not affect control flow",
+
+		Annotations: map[string]string{"age": "400d"},
+	}
+	gotoTest = driver.Diagnostic{
+		Analyzer: "redundantbranch",
+		Rule:     "GT1001",
+		Category: "goto",
+		Posn:     token.Position{Filename: "/src/a/b/b_test.go", Line: 7},
+		Message:  "goto does not affect control flow",
+
+		Annotations: map[string]string{"age": "10d"},
+	}
+	nilErr = driver.Diagnostic{
+		Analyzer: "nilness",
+		Rule:     "GT9999",
+		Severity: rules.Error,
+		Posn:     token.Position{Filename: "/elsewhere/c.go", Line: 1},
+		Message:  "nil dereference",
+
+		Annotations: map[string]string{"covered": "no"},
+	}
+)
+
+func TestMatch(t *testing.T) {
+	all := []driver.Diagnostic{brk, gotoTest, nilErr}
+	tcs := []struct {
+		expr string
+		want []bool
+	}{
+		{`analyzer:redundantbranch`, []bool{true, true, false}},
+		{`analyzer:redundant*`, []bool{true, true, false}},
+		{`analyzer!=redundantbranch`, []bool{false, false, true}},
+		{`rule:GT9999`, []bool{false, false, true}},
+		{`category:goto`, []bool{false, true, false}},
+		{`tag:style`, []bool{true, true, false}},
+		{`covered:no`, []bool{false, false, true}},
+		{`covered!=no`, []bool{true, true, false}},
+		{`age<1y`, []bool{false, true, false}},
+		{`age>=400d`, []bool{true, false, false}},
+		{`age>2w`, []bool{true, false, false}},
+		{`age:10d`, []bool{false, true, false}},
+		{`age!=10d`, []bool{true, false, false}},
+		{`path:"**/*_test.go"`, []bool{false, true, false}},
+		{`path:*_test.go`, []bool{false, true, false}},
+		{`path:a/*.go`, []bool{true, false, false}},
+		{`path:a/**`, []bool{true, true, false}},
+		{`path:/elsewhere/c.go`, []bool{false, false, true}},
+		{`message:"^(break|goto) "`, []bool{true, true, false}},
+		{"message:`nil`", []bool{false, false, true}},
+		{`severity:warning`, []bool{true, true, false}},
+		{`severity>=warning`, []bool{true, true, true}},
+		{`severity>warning`, []bool{false, false, true}},
+		{`severity<error`, []bool{true, true, false}},
+		{`severity<=info`, []bool{false, false, false}},
+		{`analyzer:redundantbranch and not path:**/*_test.go`, []bool{true, false, false}},
+		{`category:goto || severity:error`, []bool{false, true, true}},
+		{`!(category:goto || severity:error)`, []bool{true, false, false}},
+		{`analyzer:nilness or analyzer:redundantbranch and category:goto`, []bool{false, true, true}},
+		{`(analyzer:nilness or analyzer:redundantbranch) && category:goto`, []bool{false, true, false}},
+		{`not not rule:GT1001`, []bool{true, true, false}},
+	}
+	for _, tc := range tcs {
+		f, err := Parse(tc.expr, "/src")
+		if err != nil {
+			t.Errorf("Parse(%q): %v", tc.expr, err)
+			continue
+		}
+		for i, d := range all {
+			if got := f.Match(d); got != tc.want[i] {
+				t.Errorf("Parse(%q).Match(%v) = %v, want %v", tc.expr, d, got, tc.want[i])
+			}
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, expr := range []string{
+		``,
+		`analyzer`,
+		`analyzer:`,
+		`colour:red`,
+		`severity:fatal`,
+		`covered:maybe`,
+		`age<1`,
+		`age<1m`,
+		`age>-1d`,
+		`analyzer>x`,
+		`message:"("`,
+		`path:"[`,
+		`(analyzer:x`,
+		`analyzer:x)`,
+		`analyzer:x and`,
+		`analyzer:x rule:y`,
+		`analyzer:"x`,
+		`#`,
+	} {
+		if _, err := Parse(expr, "/"); err == nil {
+			t.Errorf("Parse(%q) succeeded, want error", expr)
+		}
+	}
+}
+
+func TestApply(t *testing.T) {
+	f, err := Parse("severity:error", "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := f.Apply([]driver.Diagnostic{brk, nilErr, gotoTest})
+	if len(got) != 1 || got[0].Message != nilErr.Message {
+		t.Errorf("Apply() = %v, want [%v]", got, nilErr)
+	}
+}
@@ -0,0 +1,68 @@
+// Copyright 2019 Axel Wagner
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package fingerprint computes identifiers for diagnostics, which are stable
+// under unrelated changes to the source. They are used to compare findings
+// between runs, for example to detect new findings.
+package fingerprint
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"path/filepath"
+	"strings"
+
+	"github.com/Merovius/go-tools/internal/driver"
+	"github.com/Merovius/go-tools/internal/posindex"
+)
+
+// A Fingerprinter computes fingerprints of diagnostics in files below a
+// root directory. It is safe for concurrent use.
+type Fingerprinter struct {
+	root string
+	ix   *posindex.Index
+}
+
+// New returns a Fingerprinter for files below root, which reads them using
+// ix. If ix is nil, a new index is used.
+func New(root string, ix *posindex.Index) *Fingerprinter {
+	if ix == nil {
+		ix = posindex.New()
+	}
+	return &Fingerprinter{root: root, ix: ix}
+}
+
+// Fingerprint returns the fingerprint of d. It depends on the analyzer, the
+// message, the path of the file relative to the root and the content of the
+// line the diagnostic is reported on, but not on the line number. Moving
+// code thus doesn't change it, but editing the flagged line does.
+func (f *Fingerprinter) Fingerprint(d driver.Diagnostic) string {
+	h := sha256.New()
+	for _, s := range []string{d.Analyzer, f.rel(d.Posn.Filename), d.Message} {
+		h.Write([]byte(s))
+		h.Write([]byte{0})
+	}
+	// An unreadable file just doesn't contribute its content.
+	h.Write(bytes.TrimSpace(f.ix.Line(d.Posn.Filename, d.Posn.Line)))
+	return hex.EncodeToString(h.Sum(nil)[:8])
+}
+
+// rel returns the slash-separated path of file, relative to the root.
+func (f *Fingerprinter) rel(file string) string {
+	if r, err := filepath.Rel(f.root, file); err == nil && !strings.HasPrefix(r, "..") {
+		file = r
+	}
+	return filepath.ToSlash(file)
+}
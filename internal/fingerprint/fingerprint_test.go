@@ -0,0 +1,75 @@
+// Copyright 2019 Axel Wagner
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fingerprint
+
+import (
+	"go/token"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Merovius/go-tools/internal/driver"
+)
+
+func TestFingerprint(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fingerprint")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	a := filepath.Join(dir, "a.go")
+	b := filepath.Join(dir, "b.go")
+	if err := ioutil.WriteFile(a, []byte("package a\n\n\tbreak\n"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(b, []byte("package a\nbreak\ncontinue\n"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	diag := func(file string, line int) driver.Diagnostic {
+		return driver.Diagnostic{
+			Analyzer: "redundantbranch",
+			Posn:     token.Position{Filename: file, Line: line},
+			Message:  "break does not affect control flow",
+		}
+	}
+
+	f := New(dir, nil)
+	if f.Fingerprint(diag(a, 3)) != New(dir, nil).Fingerprint(diag(a, 3)) {
+		t.Error("fingerprints are not deterministic")
+	}
+	if f.Fingerprint(diag(b, 2)) == f.Fingerprint(diag(b, 3)) {
+		t.Error("fingerprints don't depend on the line content")
+	}
+	if f.Fingerprint(diag(a, 3)) == f.Fingerprint(diag(b, 2)) {
+		t.Error("fingerprints don't depend on the file")
+	}
+
+	// Moving the file to a different root and the line down must not change
+	// the fingerprint.
+	dir2, err := ioutil.TempDir("", "fingerprint")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir2)
+	b2 := filepath.Join(dir2, "b.go")
+	if err := ioutil.WriteFile(b2, []byte("package a\n\n// comment\nbreak\n"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	if f.Fingerprint(diag(b, 2)) != New(dir2, nil).Fingerprint(diag(b2, 4)) {
+		t.Error("fingerprints depend on the line number or root")
+	}
+}
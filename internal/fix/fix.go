@@ -0,0 +1,290 @@
+// Copyright 2019 Axel Wagner
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package fix applies suggested fixes of analyzers to source files.
+//
+// Not all fixes are equally trustworthy, so every fix has a safety Level.
+// Analyzers declare the levels of their fixes with Register; fixes of
+// analyzers which don't are considered Unverified.
+package fix
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"go/token"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"sync"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/imports"
+)
+
+// Level is the safety level of a fix.
+type Level int
+
+const (
+	// Unsafe fixes might change the behavior of the program. They need to
+	// be reviewed.
+	Unsafe Level = iota
+	// Unverified fixes are intended to preserve behavior, but the analyzer
+	// can't prove that they do.
+	Unverified
+	// Safe fixes are known to preserve behavior.
+	Safe
+)
+
+var levelNames = []string{"unsafe", "unverified", "safe"}
+
+func (l Level) String() string {
+	if l < 0 || int(l) >= len(levelNames) {
+		return fmt.Sprintf("Level(%d)", int(l))
+	}
+	return levelNames[l]
+}
+
+// Set implements flag.Value.
+func (l *Level) Set(s string) error {
+	for i, n := range levelNames {
+		if n == s {
+			*l = Level(i)
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid fix level %q: must be one of %s", s, strings.Join(levelNames, ", "))
+}
+
+type registration struct {
+	def      Level
+	prefixes map[string]Level
+}
+
+var (
+	mu       sync.Mutex
+	registry = make(map[*analysis.Analyzer]*registration)
+)
+
+// Register declares the level of fixes suggested by a. It is meant to be
+// called from init functions.
+func Register(a *analysis.Analyzer, l Level) {
+	mu.Lock()
+	defer mu.Unlock()
+	reg(a).def = l
+}
+
+// RegisterPrefix declares the level of fixes suggested by a, whose message
+// starts with prefix. It overrides the level given to Register. If several
+// prefixes match, the longest one wins.
+func RegisterPrefix(a *analysis.Analyzer, prefix string, l Level) {
+	mu.Lock()
+	defer mu.Unlock()
+	reg(a).prefixes[prefix] = l
+}
+
+func reg(a *analysis.Analyzer) *registration {
+	r := registry[a]
+	if r == nil {
+		r = &registration{def: Unverified, prefixes: make(map[string]Level)}
+		registry[a] = r
+	}
+	return r
+}
+
+// LevelOf returns the level of a fix suggested by a.
+func LevelOf(a *analysis.Analyzer, f analysis.SuggestedFix) Level {
+	mu.Lock()
+	defer mu.Unlock()
+	r := registry[a]
+	if r == nil {
+		return Unverified
+	}
+	l, n := r.def, -1
+	for p, pl := range r.prefixes {
+		if strings.HasPrefix(f.Message, p) && len(p) > n {
+			l, n = pl, len(p)
+		}
+	}
+	return l
+}
+
+// An Edit replaces the bytes [Start, End) of a file with NewText.
+type Edit struct {
+	Filename string
+	Start    int
+	End      int
+	NewText  []byte
+}
+
+// A Fix is a suggested fix with resolved positions.
+type Fix struct {
+	Analyzer string
+	Message  string
+	Level    Level
+	Edits    []Edit
+}
+
+// Resolve resolves the positions of a fix suggested by a.
+func Resolve(fset *token.FileSet, a *analysis.Analyzer, sf analysis.SuggestedFix) Fix {
+	f := Fix{
+		Analyzer: a.Name,
+		Message:  sf.Message,
+		Level:    LevelOf(a, sf),
+	}
+	for _, e := range sf.TextEdits {
+		tf := fset.File(e.Pos)
+		end := e.End
+		if !end.IsValid() {
+			end = e.Pos
+		}
+		f.Edits = append(f.Edits, Edit{
+			Filename: tf.Name(),
+			Start:    tf.Offset(e.Pos),
+			End:      tf.Offset(end),
+			NewText:  e.NewText,
+		})
+	}
+	return f
+}
+
+// A Skip is a fix which was not applied.
+type Skip struct {
+	// Index is the index of the fix in the slice passed to Apply.
+	Index  int
+	Fix    Fix
+	Reason string
+}
+
+// Result summarizes the application of fixes.
+type Result struct {
+	// Files lists the modified files, sorted.
+	Files []string
+	// Applied is the number of applied fixes.
+	Applied int
+	// Skipped lists fixes which were not applied.
+	Skipped []Skip
+}
+
+// Options configure Apply.
+type Options struct {
+	// Level is the minimum level of fixes to apply.
+	Level Level
+
+	// Imports specifies whether imports should be re-resolved after
+	// applying fixes, like goimports does. Otherwise, files are only
+	// formatted with gofmt.
+	Imports bool
+}
+
+// Apply applies fixes and writes the modified files. Fixes are atomic: if
+// one of the edits of a fix overlaps with an edit of an earlier fix, none
+// of them are applied.
+func Apply(fixes []Fix, opts Options) (*Result, error) {
+	res := new(Result)
+	accepted := make(map[string][]Edit)
+	for i, f := range fixes {
+		if f.Level < opts.Level {
+			res.Skipped = append(res.Skipped, Skip{i, f, fmt.Sprintf("level %v below %v", f.Level, opts.Level)})
+			continue
+		}
+		if conflicts(accepted, f.Edits) {
+			res.Skipped = append(res.Skipped, Skip{i, f, "conflicts with another fix"})
+			continue
+		}
+		for _, e := range f.Edits {
+			accepted[e.Filename] = append(accepted[e.Filename], e)
+		}
+		res.Applied++
+	}
+
+	for name := range accepted {
+		res.Files = append(res.Files, name)
+	}
+	sort.Strings(res.Files)
+	for _, name := range res.Files {
+		src, err := ioutil.ReadFile(name)
+		if err != nil {
+			return nil, err
+		}
+		out, err := ApplyEdits(src, accepted[name])
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", name, err)
+		}
+		if formatted, err := formatFile(name, out, opts.Imports); err == nil {
+			out = formatted
+		}
+		if err := ioutil.WriteFile(name, out, 0666); err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
+}
+
+func formatFile(name string, src []byte, fixImports bool) ([]byte, error) {
+	if !fixImports {
+		return format.Source(src)
+	}
+	return imports.Process(name, src, &imports.Options{
+		Comments:  true,
+		TabIndent: true,
+		TabWidth:  8,
+	})
+}
+
+// conflicts returns whether any of edits overlaps with an accepted edit.
+// Identical edits don't conflict.
+func conflicts(accepted map[string][]Edit, edits []Edit) bool {
+	for _, e := range edits {
+		for _, a := range accepted[e.Filename] {
+			if a.Start == e.Start && a.End == e.End && bytes.Equal(a.NewText, e.NewText) {
+				continue
+			}
+			if e.Start < a.End && a.Start < e.End || e.Start == a.Start {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// ApplyEdits applies edits to src. Identical edits are applied once, other
+// overlapping edits are an error.
+func ApplyEdits(src []byte, edits []Edit) ([]byte, error) {
+	edits = append([]Edit(nil), edits...)
+	sort.SliceStable(edits, func(i, j int) bool {
+		if edits[i].Start != edits[j].Start {
+			return edits[i].Start < edits[j].Start
+		}
+		return edits[i].End < edits[j].End
+	})
+	var (
+		out  []byte
+		last int
+		prev *Edit
+	)
+	for i := range edits {
+		e := &edits[i]
+		if prev != nil && e.Start == prev.Start && e.End == prev.End && bytes.Equal(e.NewText, prev.NewText) {
+			continue
+		}
+		if e.Start < last || e.End < e.Start || e.End > len(src) {
+			return nil, fmt.Errorf("conflicting or invalid edit at offset %d", e.Start)
+		}
+		out = append(out, src[last:e.Start]...)
+		out = append(out, e.NewText...)
+		last, prev = e.End, e
+	}
+	return append(out, src[last:]...), nil
+}
@@ -0,0 +1,106 @@
+// Copyright 2019 Axel Wagner
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fix
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/tools/go/analysis"
+)
+
+func TestLevelOf(t *testing.T) {
+	a := &analysis.Analyzer{Name: "a"}
+	b := &analysis.Analyzer{Name: "b"}
+	Register(a, Safe)
+	RegisterPrefix(a, "Rename", Unverified)
+	RegisterPrefix(a, "Rename exported", Unsafe)
+
+	tcs := []struct {
+		a    *analysis.Analyzer
+		msg  string
+		want Level
+	}{
+		{a, "Remove break", Safe},
+		{a, "Rename x to y", Unverified},
+		{a, "Rename exported X to Y", Unsafe},
+		{b, "Remove break", Unverified},
+	}
+	for _, tc := range tcs {
+		if got := LevelOf(tc.a, analysis.SuggestedFix{Message: tc.msg}); got != tc.want {
+			t.Errorf("LevelOf(%s, %q) = %v, want %v", tc.a.Name, tc.msg, got, tc.want)
+		}
+	}
+}
+
+func TestApply(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fix")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	name := filepath.Join(dir, "a.go")
+	src := "package a\n\nvar x = 1\nvar y = 2\n"
+	if err := ioutil.WriteFile(name, []byte(src), 0666); err != nil {
+		t.Fatal(err)
+	}
+	off := func(s string) int {
+		for i := range src {
+			if len(src[i:]) >= len(s) && src[i:i+len(s)] == s {
+				return i
+			}
+		}
+		panic(s)
+	}
+	edit := func(old, new string) Edit {
+		return Edit{name, off(old), off(old) + len(old), []byte(new)}
+	}
+
+	fixes := []Fix{
+		{Message: "rename x", Level: Safe, Edits: []Edit{edit("x", "a")}},
+		{Message: "conflicting", Level: Safe, Edits: []Edit{edit("x = 1", "b = 1")}},
+		{Message: "unsafe", Level: Unsafe, Edits: []Edit{edit("y", "c")}},
+		{Message: "duplicate", Level: Unverified, Edits: []Edit{edit("x", "a"), edit("2", "3")}},
+	}
+	res, err := Apply(fixes, Options{Level: Unverified})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Applied != 2 {
+		t.Errorf("Apply applied %d fixes, want 2", res.Applied)
+	}
+	if len(res.Skipped) != 2 || res.Skipped[0].Index != 1 || res.Skipped[1].Index != 2 {
+		t.Errorf("Apply skipped %+v, want fixes 1 and 2", res.Skipped)
+	}
+	got, err := ioutil.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "package a\n\nvar a = 1\nvar y = 3\n"; string(got) != want {
+		t.Errorf("Apply wrote\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestLevelSet(t *testing.T) {
+	var l Level
+	if err := l.Set("unverified"); err != nil || l != Unverified {
+		t.Errorf("Set(unverified) = %v, %v; want %v, nil", l, err, Unverified)
+	}
+	if err := l.Set("bogus"); err == nil {
+		t.Error("Set(bogus) succeeded")
+	}
+}
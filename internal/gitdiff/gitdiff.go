@@ -0,0 +1,176 @@
+// Copyright 2019 Axel Wagner
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package gitdiff determines the lines changed in a git repository, to
+// restrict reports to them.
+package gitdiff
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// A Range is an inclusive range of lines.
+type Range struct {
+	Start, End int
+}
+
+// Changes maps absolute file names to the ranges of lines changed in them.
+type Changes map[string][]Range
+
+// Contains returns whether the given line of file was changed.
+func (c Changes) Contains(file string, line int) bool {
+	for _, r := range c[file] {
+		if r.Start <= line && line <= r.End {
+			return true
+		}
+	}
+	return false
+}
+
+// Files returns the changed files, sorted.
+func (c Changes) Files() []string {
+	var files []string
+	for f := range c {
+		files = append(files, f)
+	}
+	sort.Strings(files)
+	return files
+}
+
+// Diff runs "git diff" with the given extra arguments in dir and returns
+// the lines added or modified. For example, Diff(dir, "--cached") returns
+// the changes staged for the next commit.
+func Diff(dir string, args ...string) (Changes, error) {
+	root, err := git(dir, "rev-parse", "--show-toplevel")
+	if err != nil {
+		return nil, err
+	}
+	args = append([]string{"diff", "-U0", "--no-color", "--no-ext-diff", "--no-prefix"}, args...)
+	out, err := git(dir, args...)
+	if err != nil {
+		return nil, err
+	}
+	return parse(strings.TrimSpace(root), strings.NewReader(out))
+}
+
+// Contents returns the content at rev of the files matching paths whose
+// working tree content differs from it, keyed by their absolute names. An
+// empty rev stands for the index. Files not in rev are omitted. It is meant
+// as an overlay, to analyze the changes returned by Diff instead of the
+// working tree, like the staged content in a pre-commit hook.
+func Contents(dir, rev string, paths ...string) (map[string][]byte, error) {
+	root, err := git(dir, "rev-parse", "--show-toplevel")
+	if err != nil {
+		return nil, err
+	}
+	root = strings.TrimSpace(root)
+	args := []string{"diff", "--name-status", "--no-renames", "-z"}
+	if rev != "" {
+		args = append(args, rev)
+	}
+	out, err := git(dir, append(append(args, "--"), paths...)...)
+	if err != nil {
+		return nil, err
+	}
+	contents := make(map[string][]byte)
+	// The output is a list of NUL terminated pairs of status and name.
+	fs := strings.Split(strings.TrimSuffix(out, "\x00"), "\x00")
+	for i := 0; i+1 < len(fs); i += 2 {
+		status, name := fs[i], fs[i+1]
+		if status == "A" {
+			continue
+		}
+		b, err := git(dir, "cat-file", "blob", rev+":"+name)
+		if err != nil {
+			return nil, err
+		}
+		contents[filepath.Join(root, filepath.FromSlash(name))] = []byte(b)
+	}
+	return contents, nil
+}
+
+func git(dir string, args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	stderr := new(bytes.Buffer)
+	cmd.Stderr = stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("git %s: %v: %s", strings.Join(args, " "), err, strings.TrimSpace(stderr.String()))
+	}
+	return string(out), nil
+}
+
+// parse parses a diff with zero lines of context and no path prefixes.
+func parse(root string, r io.Reader) (Changes, error) {
+	c := make(Changes)
+	var file string
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		l := s.Text()
+		switch {
+		case strings.HasPrefix(l, "+++ "):
+			name := strings.TrimPrefix(l, "+++ ")
+			if name == "/dev/null" {
+				// deleted file
+				file = ""
+				continue
+			}
+			file = filepath.Join(root, filepath.FromSlash(name))
+		case strings.HasPrefix(l, "@@ "):
+			if file == "" {
+				continue
+			}
+			r, err := parseHunk(l)
+			if err != nil {
+				return nil, err
+			}
+			if r.End >= r.Start {
+				c[file] = append(c[file], r)
+			}
+		}
+	}
+	return c, s.Err()
+}
+
+// parseHunk parses a hunk header of the form "@@ -a,b +c,d @@" and returns
+// the range of lines in the new file.
+func parseHunk(l string) (Range, error) {
+	fs := strings.Fields(l)
+	if len(fs) < 4 || !strings.HasPrefix(fs[2], "+") {
+		return Range{}, fmt.Errorf("invalid hunk header %q", l)
+	}
+	spec := fs[2][1:]
+	n := 1
+	if i := strings.IndexByte(spec, ','); i >= 0 {
+		var err error
+		if n, err = strconv.Atoi(spec[i+1:]); err != nil {
+			return Range{}, fmt.Errorf("invalid hunk header %q", l)
+		}
+		spec = spec[:i]
+	}
+	start, err := strconv.Atoi(spec)
+	if err != nil {
+		return Range{}, fmt.Errorf("invalid hunk header %q", l)
+	}
+	return Range{start, start + n - 1}, nil
+}
@@ -0,0 +1,137 @@
+// Copyright 2019 Axel Wagner
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gitdiff
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const diff = `diff --git a.go a.go
+index 1111111..2222222 100644
+--- a.go
++++ a.go
+@@ -3 +3 @@ package a
+-var x = 1
++var x = 2
+@@ -10,0 +11,3 @@ func F() {
++	a()
++	b()
++	c()
+@@ -20,2 +23,0 @@ func G() {
+-	d()
+-	e()
+diff --git b/b.go b/b.go
+deleted file mode 100644
+index 3333333..0000000
+--- b/b.go
++++ /dev/null
+@@ -1,2 +0,0 @@
+-package b
+-
+`
+
+func TestParse(t *testing.T) {
+	root := filepath.FromSlash("/repo")
+	got, err := parse(root, strings.NewReader(diff))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Changes{
+		filepath.Join(root, "a.go"): {{3, 3}, {11, 13}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("parse() = %v, want %v", got, want)
+	}
+
+	a := filepath.Join(root, "a.go")
+	for line, want := range map[int]bool{2: false, 3: true, 11: true, 13: true, 14: false, 23: false} {
+		if got := got.Contains(a, line); got != want {
+			t.Errorf("Contains(a.go, %d) = %v, want %v", line, got, want)
+		}
+	}
+}
+
+func TestContents(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not found")
+	}
+	dir, err := ioutil.TempDir("", "gitdiff")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if dir, err = filepath.EvalSymlinks(dir); err != nil {
+		t.Fatal(err)
+	}
+	write := func(name, content string) {
+		t.Helper()
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+	run := func(args ...string) {
+		t.Helper()
+		args = append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)
+		if _, err := git(dir, args...); err != nil {
+			t.Fatal(err)
+		}
+	}
+	run("init", "-q")
+	write("a.go", "package a\n\nfunc F() {}\n")
+	write("b.go", "package a\n")
+	run("add", ".")
+	run("commit", "-q", "-m", "initial")
+
+	// a.go is partly staged: the staged change is on line 4, but two
+	// unstaged lines above it move it to line 6 in the working tree.
+	staged := "package a\n\nfunc F() {}\nfunc G() {}\n"
+	write("a.go", staged)
+	write("c.go", "package a\n")
+	run("add", "a.go", "c.go")
+	write("a.go", "package a\n\n// F does nothing.\n//\nfunc F() {}\nfunc G() {}\n")
+
+	changes, err := Diff(dir, "--cached")
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := filepath.Join(dir, "a.go")
+	if got, want := changes[a], []Range{{4, 4}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("staged changes of a.go = %v, want %v", got, want)
+	}
+
+	got, err := Contents(dir, "", "*.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string][]byte{a: []byte(staged)}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Contents(index) = %q, want %q", got, want)
+	}
+
+	// c.go is not in HEAD, so the working tree is used for it.
+	if got, err = Contents(dir, "HEAD", "*.go"); err != nil {
+		t.Fatal(err)
+	}
+	want = map[string][]byte{a: []byte("package a\n\nfunc F() {}\n")}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Contents(HEAD) = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,208 @@
+// Copyright 2019 Axel Wagner
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gomod
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// CacheDir returns the directory of the module cache, following the rules
+// of the go command.
+func CacheDir() string {
+	if dir := os.Getenv("GOMODCACHE"); dir != "" {
+		return dir
+	}
+	gopath := os.Getenv("GOPATH")
+	if gopath == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return ""
+		}
+		gopath = filepath.Join(home, "go")
+	}
+	return filepath.Join(filepath.SplitList(gopath)[0], "pkg", "mod")
+}
+
+// Retractions returns the versions of the module path retracted by the
+// go.mod file of its latest version in the module cache cache. Like the go
+// command, it prefers release versions to pre-releases. It returns nil if
+// the module is not in the cache.
+func Retractions(cache, path string) ([]Retract, error) {
+	dir := filepath.Join(cache, "cache", "download", filepath.FromSlash(escape(path)), "@v")
+	infos, err := ioutil.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var latest, name string
+	for _, fi := range infos {
+		if !strings.HasSuffix(fi.Name(), ".mod") {
+			continue
+		}
+		v := unescape(strings.TrimSuffix(fi.Name(), ".mod"))
+		if latest == "" || newer(v, latest) {
+			latest, name = v, fi.Name()
+		}
+	}
+	if latest == "" {
+		return nil, nil
+	}
+	data, err := ioutil.ReadFile(filepath.Join(dir, name))
+	if err != nil {
+		return nil, err
+	}
+	f, err := Parse(filepath.Join(dir, name), data)
+	if err != nil {
+		return nil, err
+	}
+	return f.Retract, nil
+}
+
+// newer returns whether v should be preferred to w as the latest version.
+func newer(v, w string) bool {
+	if pv, pw := prerelease(v) != "", prerelease(w) != ""; pv != pw {
+		return pw
+	}
+	return CompareVersions(v, w) > 0
+}
+
+// Covers returns whether r retracts the version v.
+func (r Retract) Covers(v string) bool {
+	return CompareVersions(r.Low, v) <= 0 && CompareVersions(v, r.High) <= 0
+}
+
+// CompareVersions compares the semantic versions v and w, returning -1, 0
+// or +1. Build metadata, like "+incompatible", is ignored.
+func CompareVersions(v, w string) int {
+	nv, nw := numbers(v), numbers(w)
+	for i := range nv {
+		if c := compareNumbers(nv[i], nw[i]); c != 0 {
+			return c
+		}
+	}
+	pv, pw := prerelease(v), prerelease(w)
+	switch {
+	case pv == pw:
+		return 0
+	case pv == "":
+		return 1
+	case pw == "":
+		return -1
+	}
+	iv, iw := strings.Split(pv, "."), strings.Split(pw, ".")
+	for i := 0; i < len(iv) && i < len(iw); i++ {
+		if c := compareIdents(iv[i], iw[i]); c != 0 {
+			return c
+		}
+	}
+	return compareInts(len(iv), len(iw))
+}
+
+// numbers returns the major, minor and patch version of v, with missing
+// ones being "0".
+func numbers(v string) [3]string {
+	v = strings.TrimPrefix(v, "v")
+	if i := strings.IndexAny(v, "-+"); i >= 0 {
+		v = v[:i]
+	}
+	n := [3]string{"0", "0", "0"}
+	for i, s := range strings.SplitN(v, ".", 3) {
+		n[i] = s
+	}
+	return n
+}
+
+// prerelease returns the pre-release suffix of v, without the "-".
+func prerelease(v string) string {
+	if i := strings.IndexByte(v, '+'); i >= 0 {
+		v = v[:i]
+	}
+	if i := strings.IndexByte(v, '-'); i >= 0 {
+		return v[i+1:]
+	}
+	return ""
+}
+
+func compareNumbers(a, b string) int {
+	a, b = strings.TrimLeft(a, "0"), strings.TrimLeft(b, "0")
+	if c := compareInts(len(a), len(b)); c != 0 {
+		return c
+	}
+	return strings.Compare(a, b)
+}
+
+// compareIdents compares pre-release identifiers. Numeric ones compare
+// numerically and lower than alphanumeric ones.
+func compareIdents(a, b string) int {
+	_, errA := strconv.ParseUint(a, 10, 64)
+	_, errB := strconv.ParseUint(b, 10, 64)
+	switch {
+	case errA == nil && errB == nil:
+		return compareNumbers(a, b)
+	case errA == nil:
+		return -1
+	case errB == nil:
+		return 1
+	}
+	return strings.Compare(a, b)
+}
+
+func compareInts(a, b int) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// escape escapes a module path or version for use in the module cache, by
+// replacing upper-case letters by "!" and the lower-case letter.
+func escape(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if unicode.IsUpper(r) {
+			b.WriteByte('!')
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// unescape reverses escape.
+func unescape(s string) string {
+	var b strings.Builder
+	upper := false
+	for _, r := range s {
+		if r == '!' {
+			upper = true
+			continue
+		}
+		if upper {
+			r, upper = unicode.ToUpper(r), false
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
@@ -0,0 +1,300 @@
+// Copyright 2019 Axel Wagner
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package gomod parses go.mod files, as far as analyzers need them. It
+// understands the module, go, require, replace, exclude and retract
+// directives, in their single-line and block forms, but doesn't validate
+// versions. It also parses the go.work files of workspaces.
+package gomod
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// A File is a parsed go.mod file.
+type File struct {
+	// Path is the path of the file.
+	Path string
+
+	Module  string
+	Go      string // version of the go directive, like "1.21", or ""
+	Require []Require
+	Replace []Replace
+	Exclude []Require
+	Retract []Retract
+}
+
+// A Require is a required module version.
+type Require struct {
+	Path     string
+	Version  string
+	Indirect bool // whether the requirement is marked // indirect
+	Line     int
+}
+
+// A Replace is a replace directive. NewVersion is empty if New is a
+// directory.
+type Replace struct {
+	Old, OldVersion string
+	New, NewVersion string
+	Line            int
+}
+
+// Local returns whether r replaces a module by a directory.
+func (r Replace) Local() bool {
+	return r.NewVersion == "" && (strings.HasPrefix(r.New, "./") || strings.HasPrefix(r.New, "../") || filepath.IsAbs(r.New) || r.New == "." || r.New == "..")
+}
+
+// A Retract retracts a version, or the versions from Low to High.
+type Retract struct {
+	Low, High string
+	Rationale string // the comment preceding or following the directive
+	Line      int
+}
+
+// Parse parses the go.mod file data. The path is used in errors.
+func Parse(path string, data []byte) (*File, error) {
+	f := &File{Path: path}
+	if err := parse(path, data, f.add); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// parse splits data into directives, passing each with its arguments, its
+// comment and its line number to add. Blocks are split into one directive
+// per line.
+func parse(path string, data []byte, add func(verb string, args []string, comment string, line int) error) error {
+	var (
+		block   string // directive of the current block, if any
+		comment string // comment of the previous line
+	)
+	for i, line := range strings.Split(string(data), "\n") {
+		n := i + 1
+		line, lineComment := splitComment(line)
+		fields, err := tokenize(line)
+		if err != nil {
+			return fmt.Errorf("%s:%d: %v", path, n, err)
+		}
+		if len(fields) == 0 {
+			comment = lineComment
+			continue
+		}
+		if block != "" {
+			if fields[0] == ")" {
+				block = ""
+				continue
+			}
+		} else if len(fields) == 2 && fields[1] == "(" {
+			block = fields[0]
+			continue
+		}
+		verb := block
+		if verb == "" {
+			verb, fields = fields[0], fields[1:]
+		}
+		if lineComment == "" {
+			lineComment = comment
+		}
+		if err := add(verb, fields, lineComment, n); err != nil {
+			return fmt.Errorf("%s:%d: %v", path, n, err)
+		}
+		comment = ""
+	}
+	if block != "" {
+		return fmt.Errorf("%s: unterminated %s block", path, block)
+	}
+	return nil
+}
+
+func (f *File) add(verb string, args []string, comment string, line int) error {
+	switch verb {
+	case "module":
+		if len(args) != 1 {
+			return fmt.Errorf("usage: module path")
+		}
+		f.Module = args[0]
+	case "go":
+		if len(args) != 1 {
+			return fmt.Errorf("usage: go version")
+		}
+		f.Go = args[0]
+	case "require", "exclude":
+		if len(args) != 2 {
+			return fmt.Errorf("usage: %s module/path v1.2.3", verb)
+		}
+		r := Require{args[0], args[1], strings.HasPrefix(strings.TrimSpace(comment), "indirect"), line}
+		if verb == "require" {
+			f.Require = append(f.Require, r)
+		} else {
+			f.Exclude = append(f.Exclude, r)
+		}
+	case "replace":
+		r, err := parseReplace(args, line)
+		if err != nil {
+			return err
+		}
+		f.Replace = append(f.Replace, r)
+	case "retract":
+		r := Retract{Rationale: strings.TrimSpace(comment), Line: line}
+		switch {
+		case len(args) == 1:
+			r.Low, r.High = args[0], args[0]
+		case len(args) == 5 && args[0] == "[" && args[2] == "," && args[4] == "]":
+			r.Low, r.High = args[1], args[3]
+		default:
+			return fmt.Errorf("usage: retract v1.2.3 or retract [v1.2.3, v1.3.0]")
+		}
+		f.Retract = append(f.Retract, r)
+	}
+	// Unknown directives, like toolchain or godebug, are ignored.
+	return nil
+}
+
+func parseReplace(args []string, line int) (Replace, error) {
+	i := indexOf(args, "=>")
+	if i < 1 || i > 2 || len(args)-i-1 < 1 || len(args)-i-1 > 2 {
+		return Replace{}, fmt.Errorf("usage: replace module/path [v1.2.3] => other/module [v1.4.5]")
+	}
+	r := Replace{Old: args[0], New: args[i+1], Line: line}
+	if i == 2 {
+		r.OldVersion = args[1]
+	}
+	if len(args) == i+3 {
+		r.NewVersion = args[i+2]
+	}
+	return r, nil
+}
+
+func indexOf(s []string, x string) int {
+	for i, v := range s {
+		if v == x {
+			return i
+		}
+	}
+	return -1
+}
+
+// splitComment splits a // comment off line.
+func splitComment(line string) (string, string) {
+	if i := strings.Index(line, "//"); i >= 0 && !inQuotes(line[:i]) {
+		return line[:i], strings.TrimSpace(line[i+2:])
+	}
+	return line, ""
+}
+
+func inQuotes(s string) bool {
+	return strings.Count(s, `"`)%2 == 1 || strings.Count(s, "`")%2 == 1
+}
+
+// tokenize splits line into fields. Quoted strings are unquoted, the
+// punctuation of retract ranges and "=>" are separate fields.
+func tokenize(line string) ([]string, error) {
+	var fields []string
+	for {
+		line = strings.TrimLeft(line, " \t\r")
+		if line == "" {
+			return fields, nil
+		}
+		switch c := line[0]; {
+		case c == '"' || c == '`':
+			end := strings.IndexByte(line[1:], c)
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated string")
+			}
+			s, err := strconv.Unquote(line[:end+2])
+			if err != nil {
+				return nil, err
+			}
+			fields, line = append(fields, s), line[end+2:]
+		case strings.ContainsRune("[],()", rune(c)):
+			fields, line = append(fields, line[:1]), line[1:]
+		case strings.HasPrefix(line, "=>"):
+			fields, line = append(fields, "=>"), line[2:]
+		default:
+			end := strings.IndexAny(line, " \t\r[],()\"`")
+			if i := strings.Index(line, "=>"); i >= 0 && (end < 0 || i < end) {
+				end = i
+			}
+			if end < 0 {
+				end = len(line)
+			}
+			fields, line = append(fields, line[:end]), line[end:]
+		}
+	}
+}
+
+var cache struct {
+	sync.Mutex
+	byDir map[string]*File
+}
+
+// Find returns the go.mod file of the module containing dir, or nil if
+// there is none. Results are cached, as many packages share a module.
+func Find(dir string) (*File, error) {
+	cache.Lock()
+	defer cache.Unlock()
+	return find(dir)
+}
+
+func find(dir string) (*File, error) {
+	if f, ok := cache.byDir[dir]; ok {
+		return f, nil
+	}
+	var f *File
+	path := filepath.Join(dir, "go.mod")
+	data, err := ioutil.ReadFile(path)
+	switch {
+	case err == nil:
+		if f, err = Parse(path, data); err != nil {
+			return nil, err
+		}
+	case !os.IsNotExist(err):
+		return nil, err
+	default:
+		if parent := filepath.Dir(dir); parent != dir {
+			if f, err = find(parent); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if cache.byDir == nil {
+		cache.byDir = make(map[string]*File)
+	}
+	cache.byDir[dir] = f
+	return f, nil
+}
+
+// ModuleOf returns the path of the module providing the package path,
+// which is the longest of the main module and the required modules being a
+// prefix of it, or "" if there is none.
+func (f *File) ModuleOf(path string) string {
+	best := ""
+	try := func(m string) {
+		if (path == m || strings.HasPrefix(path, m+"/")) && len(m) > len(best) {
+			best = m
+		}
+	}
+	try(f.Module)
+	for _, r := range f.Require {
+		try(r.Path)
+	}
+	return best
+}
@@ -0,0 +1,253 @@
+// Copyright 2019 Axel Wagner
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gomod
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+const goMod = `module example.com/m // the main module
+
+go 1.21
+
+require example.com/a v1.0.0
+
+require (
+	example.com/b v2.0.0+incompatible
+	example.com/c v0.1.0 // indirect
+)
+
+replace example.com/a => ../a
+
+replace (
+	example.com/b v2.0.0+incompatible => example.com/fork/b v2.0.1+incompatible
+	"example.com/c" => /abs/c
+)
+
+exclude example.com/a v0.9.0
+
+// Published by accident.
+retract v1.0.5
+
+retract [v1.1.0, v1.1.9] // broken
+`
+
+func TestParse(t *testing.T) {
+	f, err := Parse("go.mod", []byte(goMod))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &File{
+		Path:   "go.mod",
+		Module: "example.com/m",
+		Go:     "1.21",
+		Require: []Require{
+			{"example.com/a", "v1.0.0", false, 5},
+			{"example.com/b", "v2.0.0+incompatible", false, 8},
+			{"example.com/c", "v0.1.0", true, 9},
+		},
+		Replace: []Replace{
+			{"example.com/a", "", "../a", "", 12},
+			{"example.com/b", "v2.0.0+incompatible", "example.com/fork/b", "v2.0.1+incompatible", 15},
+			{"example.com/c", "", "/abs/c", "", 16},
+		},
+		Exclude: []Require{{"example.com/a", "v0.9.0", false, 19}},
+		Retract: []Retract{
+			{"v1.0.5", "v1.0.5", "Published by accident.", 22},
+			{"v1.1.0", "v1.1.9", "broken", 24},
+		},
+	}
+	if !reflect.DeepEqual(f, want) {
+		t.Errorf("Parse() = %+v, want %+v", f, want)
+	}
+	for i, local := range []bool{true, false, true} {
+		if got := f.Replace[i].Local(); got != local {
+			t.Errorf("%v.Local() = %v, want %v", f.Replace[i], got, local)
+		}
+	}
+	for path, want := range map[string]string{
+		"example.com/m":          "example.com/m",
+		"example.com/m/x/y":      "example.com/m",
+		"example.com/a/b":        "example.com/a",
+		"example.com/abc":        "",
+		"example.com/c/internal": "example.com/c",
+	} {
+		if got := f.ModuleOf(path); got != want {
+			t.Errorf("ModuleOf(%q) = %q, want %q", path, got, want)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, src := range []string{
+		"module",
+		"require example.com/a",
+		"replace example.com/a",
+		"retract [v1.0.0]",
+		"require (\nexample.com/a v1.0.0\n",
+		`module "example.com/m`,
+	} {
+		if _, err := Parse("go.mod", []byte(src)); err == nil {
+			t.Errorf("Parse(%q) succeeded, want error", src)
+		}
+	}
+}
+
+func TestFind(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gomod")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	sub := filepath.Join(dir, "a", "b")
+	if err := os.MkdirAll(sub, 0777); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "go.mod"), []byte("module example.com/m\n"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	f, err := Find(sub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f == nil || f.Module != "example.com/m" || f.Path != filepath.Join(dir, "go.mod") {
+		t.Errorf("Find(%q) = %+v, want module example.com/m", sub, f)
+	}
+}
+
+func TestParseWork(t *testing.T) {
+	const src = `go 1.22
+
+use ./a
+use (
+	./b // the b module
+	/abs/c
+)
+
+replace example.com/d => ../d
+`
+	w, err := ParseWork(filepath.Join("/ws", "go.work"), []byte(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w.Go != "1.22" || len(w.Replace) != 1 || w.Replace[0].New != "../d" {
+		t.Errorf("ParseWork = %+v", w)
+	}
+	want := []string{filepath.Join("/ws", "a"), filepath.Join("/ws", "b"), filepath.Clean("/abs/c")}
+	if got := w.Dirs(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Dirs() = %q, want %q", got, want)
+	}
+	if _, err := ParseWork("go.work", []byte("use ./a ./b\n")); err == nil {
+		t.Error("ParseWork succeeded for use with two directories, want error")
+	}
+}
+
+func TestFindWork(t *testing.T) {
+	defer os.Setenv("GOWORK", os.Getenv("GOWORK"))
+	os.Unsetenv("GOWORK")
+	dir, err := ioutil.TempDir("", "gomod")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	sub := filepath.Join(dir, "a", "b")
+	if err := os.MkdirAll(sub, 0777); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "go.work"), []byte("go 1.22\n\nuse ./a\n"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	w, err := FindWork(sub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w == nil || w.Path != filepath.Join(dir, "go.work") || !reflect.DeepEqual(w.Use, []string{"./a"}) {
+		t.Errorf("FindWork(%q) = %+v, want workspace using ./a", sub, w)
+	}
+	os.Setenv("GOWORK", "off")
+	if w, err := FindWork(sub); err != nil || w != nil {
+		t.Errorf("FindWork(%q) with GOWORK=off = %+v, %v, want nil", sub, w, err)
+	}
+}
+
+func TestCompareVersions(t *testing.T) {
+	// In increasing order.
+	versions := []string{
+		"v0.0.0-20190819174341-15fda70baffd",
+		"v0.1.0",
+		"v1.0.0-alpha",
+		"v1.0.0-alpha.1",
+		"v1.0.0-alpha.beta",
+		"v1.0.0-beta.2",
+		"v1.0.0-beta.11",
+		"v1.0.0-rc.1",
+		"v1.0.0",
+		"v1.9.0",
+		"v1.10.0",
+		"v2.0.0+incompatible",
+	}
+	for i, v := range versions {
+		for j, w := range versions {
+			want := compareInts(i, j)
+			if got := CompareVersions(v, w); got != want {
+				t.Errorf("CompareVersions(%q, %q) = %d, want %d", v, w, got, want)
+			}
+		}
+	}
+	r := Retract{Low: "v1.0.0", High: "v1.9.0"}
+	for v, want := range map[string]bool{"v0.1.0": false, "v1.0.0": true, "v1.2.3": true, "v1.9.0": true, "v1.10.0": false} {
+		if got := r.Covers(v); got != want {
+			t.Errorf("%v.Covers(%q) = %v, want %v", r, v, got, want)
+		}
+	}
+}
+
+func TestRetractions(t *testing.T) {
+	cache, err := ioutil.TempDir("", "gomod")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(cache)
+	dir := filepath.Join(cache, "cache", "download", "example.com", "!user", "@v")
+	if err := os.MkdirAll(dir, 0777); err != nil {
+		t.Fatal(err)
+	}
+	for name, data := range map[string]string{
+		"v1.0.0.mod":       "module example.com/User\n",
+		"v1.1.0.mod":       "module example.com/User\n\nretract v1.0.0 // broken\n",
+		"v1.2.0-rc.1.mod":  "module example.com/User\n\nretract v1.1.0\n",
+		"v1.1.0.info":      "{}",
+		"v1.1.0.zip.other": "",
+	} {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(data), 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+	got, err := Retractions(cache, "example.com/User")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Retract{{"v1.0.0", "v1.0.0", "broken", 3}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Retractions() = %+v, want %+v", got, want)
+	}
+	if got, err := Retractions(cache, "example.com/missing"); got != nil || err != nil {
+		t.Errorf("Retractions(missing) = %v, %v, want nil, nil", got, err)
+	}
+}
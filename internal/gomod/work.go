@@ -0,0 +1,110 @@
+// Copyright 2019 Axel Wagner
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gomod
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// A Work is a parsed go.work file.
+type Work struct {
+	// Path is the path of the file.
+	Path string
+
+	Go      string   // version of the go directive, or ""
+	Use     []string // module directories, as written
+	Replace []Replace
+}
+
+// ParseWork parses the go.work file data. The path is used in errors and to
+// resolve the module directories.
+func ParseWork(path string, data []byte) (*Work, error) {
+	w := &Work{Path: path}
+	if err := parse(path, data, w.add); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+func (w *Work) add(verb string, args []string, comment string, line int) error {
+	switch verb {
+	case "go":
+		if len(args) != 1 {
+			return fmt.Errorf("usage: go version")
+		}
+		w.Go = args[0]
+	case "use":
+		if len(args) != 1 {
+			return fmt.Errorf("usage: use ./module/dir")
+		}
+		w.Use = append(w.Use, args[0])
+	case "replace":
+		r, err := parseReplace(args, line)
+		if err != nil {
+			return err
+		}
+		w.Replace = append(w.Replace, r)
+	}
+	// Unknown directives, like toolchain or godebug, are ignored.
+	return nil
+}
+
+// Dirs returns the cleaned directories of the workspace modules, relative
+// directories being resolved against the directory of the go.work file.
+func (w *Work) Dirs() []string {
+	dirs := make([]string, len(w.Use))
+	for i, u := range w.Use {
+		if !filepath.IsAbs(u) {
+			u = filepath.Join(filepath.Dir(w.Path), u)
+		}
+		dirs[i] = filepath.Clean(u)
+	}
+	return dirs
+}
+
+// FindWork returns the go.work file of the workspace the go command uses
+// in dir, or nil if it doesn't use one. Like the go command, it honors the
+// GOWORK environment variable, which can name the file or be "off".
+func FindWork(dir string) (*Work, error) {
+	path := os.Getenv("GOWORK")
+	switch {
+	case path == "off":
+		return nil, nil
+	case path == "":
+		dir, err := filepath.Abs(dir)
+		if err != nil {
+			return nil, err
+		}
+		for {
+			path = filepath.Join(dir, "go.work")
+			if _, err := os.Stat(path); err == nil {
+				break
+			}
+			parent := filepath.Dir(dir)
+			if parent == dir {
+				return nil, nil
+			}
+			dir = parent
+		}
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseWork(path, data)
+}
@@ -0,0 +1,179 @@
+// Copyright 2019 Axel Wagner
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package goversion
+
+import (
+	"go/ast"
+	"strings"
+)
+
+// constraintVersion returns the minimum version implied by the build
+// constraint of f, or "" if it implies none. A //go:build line takes
+// precedence over // +build lines.
+func constraintVersion(f *ast.File) string {
+	var plus []string
+	for _, g := range f.Comments {
+		if g.Pos() >= f.Package {
+			break
+		}
+		for _, c := range g.List {
+			if strings.HasPrefix(c.Text, "//go:build ") {
+				return buildExpr(c.Text[len("//go:build "):])
+			}
+			if fs := strings.Fields(strings.TrimPrefix(c.Text, "//")); len(fs) > 0 && fs[0] == "+build" {
+				plus = append(plus, strings.Join(fs[1:], " "))
+			}
+		}
+	}
+	// Lines are and-ed, space separated options or-ed and comma separated
+	// terms and-ed.
+	var v string
+	for _, line := range plus {
+		v = and(v, plusLine(line))
+	}
+	return v
+}
+
+func plusLine(line string) string {
+	var v string
+	for i, opt := range strings.Fields(line) {
+		var o string
+		for _, term := range strings.Split(opt, ",") {
+			o = and(o, tag(term))
+		}
+		if i == 0 {
+			v = o
+		} else {
+			v = or(v, o)
+		}
+	}
+	return v
+}
+
+// and returns the version implied by two constraints which both hold.
+func and(a, b string) string {
+	if Compare(a, b) >= 0 {
+		return a
+	}
+	return b
+}
+
+// or returns the version implied by either of two constraints holding.
+func or(a, b string) string {
+	if a == "" || b == "" {
+		return ""
+	}
+	if Compare(a, b) <= 0 {
+		return a
+	}
+	return b
+}
+
+// tag returns the version implied by a single build tag.
+func tag(t string) string {
+	if Lang(t) == t {
+		return t
+	}
+	return ""
+}
+
+// buildExpr returns the version implied by a //go:build expression. It
+// returns "" for invalid expressions, which the go command rejects anyway.
+func buildExpr(s string) string {
+	p := &exprParser{s: s}
+	v := p.or()
+	if p.err || p.next() != "" {
+		return ""
+	}
+	return v
+}
+
+type exprParser struct {
+	s   string
+	tok string
+	err bool
+}
+
+// next returns the next token, without consuming it.
+func (p *exprParser) next() string {
+	if p.tok != "" {
+		return p.tok
+	}
+	p.s = strings.TrimLeft(p.s, " \t")
+	if p.s == "" {
+		return ""
+	}
+	n := 1
+	switch {
+	case strings.HasPrefix(p.s, "&&"), strings.HasPrefix(p.s, "||"):
+		n = 2
+	case p.s[0] == '!' || p.s[0] == '(' || p.s[0] == ')':
+	default:
+		n = strings.IndexAny(p.s, " \t!()&|")
+		if n < 0 {
+			n = len(p.s)
+		}
+		if n == 0 {
+			p.err = true
+			n = len(p.s)
+		}
+	}
+	p.tok, p.s = p.s[:n], p.s[n:]
+	return p.tok
+}
+
+func (p *exprParser) consume() string {
+	t := p.next()
+	p.tok = ""
+	return t
+}
+
+func (p *exprParser) or() string {
+	v := p.and()
+	for p.next() == "||" {
+		p.consume()
+		v = or(v, p.and())
+	}
+	return v
+}
+
+func (p *exprParser) and() string {
+	v := p.not()
+	for p.next() == "&&" {
+		p.consume()
+		v = and(v, p.not())
+	}
+	return v
+}
+
+func (p *exprParser) not() string {
+	switch t := p.consume(); t {
+	case "!":
+		// A negation never implies a minimum version.
+		p.not()
+		return ""
+	case "(":
+		v := p.or()
+		if p.consume() != ")" {
+			p.err = true
+		}
+		return v
+	case "", ")", "&&", "||":
+		p.err = true
+		return ""
+	default:
+		return tag(t)
+	}
+}
@@ -0,0 +1,227 @@
+// Copyright 2019 Axel Wagner
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package goversion determines the effective language version of files, so
+// analyzers can adapt to the Go version a file is compiled with. For
+// example, an analyzer suggesting the use of a newer language feature
+// should stay silent in modules declaring an older version.
+//
+// The version of a file is the one of the go directive of its module,
+// adjusted by the //go:build (or // +build) constraint of the file, as done
+// by the go command: a constraint implying a newer version upgrades the
+// file, one implying an older version, but at least go1.21, downgrades it.
+// Without a go.mod, the version of the toolchain is used.
+//
+// Versions are written like in go/types, as "go1.21".
+package goversion
+
+import (
+	"go/ast"
+	"go/build"
+	"go/token"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+
+	"golang.org/x/tools/go/analysis"
+)
+
+// Analyzer computes the language versions of the files of a package. Its
+// result is a *Result.
+var Analyzer = &analysis.Analyzer{
+	Name:             "goversion",
+	Doc:              "determine the effective language version of files",
+	Run:              run,
+	RunDespiteErrors: true,
+	ResultType:       reflect.TypeOf(new(Result)),
+}
+
+// A Result holds the language versions of the files of a package.
+type Result struct {
+	// Module is the version of the go directive of the module, or the
+	// version of the toolchain, if there is none.
+	Module string
+
+	files map[*ast.File]string
+}
+
+// File returns the effective language version of f.
+func (r *Result) File(f *ast.File) string {
+	if v, ok := r.files[f]; ok {
+		return v
+	}
+	return r.Module
+}
+
+// AtLeast returns whether f is compiled with language version v or later.
+func (r *Result) AtLeast(f *ast.File, v string) bool {
+	return Compare(r.File(f), v) >= 0
+}
+
+func run(pass *analysis.Pass) (interface{}, error) {
+	return newResult(pass.Fset, pass.Files), nil
+}
+
+func newResult(fset *token.FileSet, files []*ast.File) *Result {
+	r := &Result{files: make(map[*ast.File]string)}
+	for _, f := range files {
+		if r.Module == "" {
+			r.Module = Module(filepath.Dir(fset.File(f.Pos()).Name()))
+		}
+	}
+	if r.Module == "" {
+		r.Module = Toolchain()
+	}
+	for _, f := range files {
+		r.files[f] = FileVersion(r.Module, constraintVersion(f))
+	}
+	return r
+}
+
+// FileVersion returns the effective version of a file in a module with
+// version module, whose build constraint implies version constraint (which
+// may be empty).
+func FileVersion(module, constraint string) string {
+	if constraint == "" {
+		return module
+	}
+	if Compare(constraint, module) > 0 || Compare(constraint, "go1.21") >= 0 {
+		return constraint
+	}
+	return module
+}
+
+var modules struct {
+	sync.Mutex
+	byDir map[string]string
+}
+
+// Module returns the version of the go directive of the module containing
+// dir, or "" if there is none. Modules without go directive are go1.16, as
+// for the go command.
+func Module(dir string) string {
+	modules.Lock()
+	defer modules.Unlock()
+	return module(dir)
+}
+
+func module(dir string) string {
+	if v, ok := modules.byDir[dir]; ok {
+		return v
+	}
+	v := ""
+	if b, err := ioutil.ReadFile(filepath.Join(dir, "go.mod")); err == nil {
+		v = goDirective(b)
+	} else if parent := filepath.Dir(dir); parent != dir {
+		v = module(parent)
+	}
+	if modules.byDir == nil {
+		modules.byDir = make(map[string]string)
+	}
+	modules.byDir[dir] = v
+	return v
+}
+
+// goDirective returns the version of the go directive in the go.mod file
+// b, or go1.16 if there is none.
+func goDirective(b []byte) string {
+	for _, line := range strings.Split(string(b), "\n") {
+		if i := strings.Index(line, "//"); i >= 0 {
+			line = line[:i]
+		}
+		fs := strings.Fields(line)
+		if len(fs) == 2 && fs[0] == "go" {
+			if v := Lang("go" + fs[1]); v != "" {
+				return v
+			}
+		}
+	}
+	return "go1.16"
+}
+
+// Toolchain returns the language version of the toolchain.
+func Toolchain() string {
+	tags := build.Default.ReleaseTags
+	if len(tags) == 0 {
+		return ""
+	}
+	return tags[len(tags)-1]
+}
+
+// Lang returns the language version of v, dropping any patch version or
+// pre-release suffix, like "go1.21" for "go1.21.3" or "go1.22rc1". It
+// returns "" if v is not a valid version.
+func Lang(v string) string {
+	major, minor, ok := parse(v)
+	if !ok {
+		return ""
+	}
+	return "go" + strconv.Itoa(major) + "." + strconv.Itoa(minor)
+}
+
+// Compare compares the language versions a and b. Invalid versions are
+// older than all valid ones.
+func Compare(a, b string) int {
+	amaj, amin, aok := parse(a)
+	bmaj, bmin, bok := parse(b)
+	switch {
+	case aok != bok:
+		if aok {
+			return 1
+		}
+		return -1
+	case amaj != bmaj:
+		return cmpInt(amaj, bmaj)
+	default:
+		return cmpInt(amin, bmin)
+	}
+}
+
+func cmpInt(a, b int) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func parse(v string) (major, minor int, ok bool) {
+	if !strings.HasPrefix(v, "go") {
+		return 0, 0, false
+	}
+	v = v[len("go"):]
+	i := strings.IndexByte(v, '.')
+	if i < 0 {
+		return 0, 0, false
+	}
+	major, err := strconv.Atoi(v[:i])
+	if err != nil || major < 1 {
+		return 0, 0, false
+	}
+	v = v[i+1:]
+	n := 0
+	for n < len(v) && '0' <= v[n] && v[n] <= '9' {
+		n++
+	}
+	minor, err = strconv.Atoi(v[:n])
+	if err != nil {
+		return 0, 0, false
+	}
+	return major, minor, true
+}
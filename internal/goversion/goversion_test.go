@@ -0,0 +1,158 @@
+// Copyright 2019 Axel Wagner
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package goversion
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCompare(t *testing.T) {
+	tcs := []struct {
+		a, b string
+		want int
+	}{
+		{"go1.21", "go1.21", 0},
+		{"go1.21.3", "go1.21", 0},
+		{"go1.22rc1", "go1.22", 0},
+		{"go1.9", "go1.21", -1},
+		{"go1.21", "go1.9", 1},
+		{"go2.0", "go1.30", 1},
+		{"", "go1.0", -1},
+		{"go1", "go1.0", -1},
+		{"1.21", "go1.0", -1},
+		{"", "", 0},
+	}
+	for _, tc := range tcs {
+		if got := Compare(tc.a, tc.b); got != tc.want {
+			t.Errorf("Compare(%q, %q) = %d, want %d", tc.a, tc.b, got, tc.want)
+		}
+	}
+}
+
+func TestBuildExpr(t *testing.T) {
+	tcs := []struct {
+		expr string
+		want string
+	}{
+		{"linux", ""},
+		{"go1.21", "go1.21"},
+		{"go1.21.1", ""},
+		{"linux && go1.18", "go1.18"},
+		{"go1.18 && go1.20", "go1.20"},
+		{"go1.18 || go1.20", "go1.18"},
+		{"go1.18 || linux", ""},
+		{"!go1.18", ""},
+		{"!go1.18 && go1.16", "go1.16"},
+		{"(go1.18 || go1.19) && (linux || darwin)", "go1.18"},
+		{"go1.18 &&", ""},
+		{"(go1.18", ""},
+		{"go1.18)", ""},
+	}
+	for _, tc := range tcs {
+		if got := buildExpr(tc.expr); got != tc.want {
+			t.Errorf("buildExpr(%q) = %q, want %q", tc.expr, got, tc.want)
+		}
+	}
+}
+
+func TestConstraintVersion(t *testing.T) {
+	tcs := []struct {
+		src  string
+		want string
+	}{
+		{"package p", ""},
+		{"//go:build go1.22\n\npackage p", "go1.22"},
+		{"// +build go1.18\n\npackage p", "go1.18"},
+		{"// +build linux,go1.18 go1.20\n\npackage p", "go1.18"},
+		{"// +build linux go1.18\n// +build go1.19\n\npackage p", "go1.19"},
+		{"//go:build go1.21\n// +build go1.18\n\npackage p", "go1.21"},
+		{"// Copyright\n\n//go:build go1.21\n\npackage p", "go1.21"},
+		{"package p\n\n//go:build go1.21", ""},
+	}
+	for _, tc := range tcs {
+		f, err := parser.ParseFile(token.NewFileSet(), "p.go", tc.src, parser.ParseComments)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := constraintVersion(f); got != tc.want {
+			t.Errorf("constraintVersion(%q) = %q, want %q", tc.src, got, tc.want)
+		}
+	}
+}
+
+func TestFileVersion(t *testing.T) {
+	tcs := []struct {
+		module, constraint string
+		want               string
+	}{
+		{"go1.18", "", "go1.18"},
+		{"go1.18", "go1.20", "go1.20"},
+		{"go1.18", "go1.16", "go1.18"},
+		{"go1.22", "go1.21", "go1.21"},
+		{"go1.22", "go1.20", "go1.22"},
+	}
+	for _, tc := range tcs {
+		if got := FileVersion(tc.module, tc.constraint); got != tc.want {
+			t.Errorf("FileVersion(%q, %q) = %q, want %q", tc.module, tc.constraint, got, tc.want)
+		}
+	}
+}
+
+func TestModule(t *testing.T) {
+	dir, err := ioutil.TempDir("", "goversion")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	write := func(name, content string) {
+		name = filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(name), 0777); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(name, []byte(content), 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("a/go.mod", "module a\n\ngo 1.21.3 // comment\n")
+	write("a/b/c/c.go", "package c")
+	write("d/go.mod", "module d\n")
+	write("d/d.go", "//go:build go1.22\n\npackage d")
+
+	if got, want := Module(filepath.Join(dir, "a", "b", "c")), "go1.21"; got != want {
+		t.Errorf("Module(a/b/c) = %q, want %q", got, want)
+	}
+	if got, want := Module(filepath.Join(dir, "d")), "go1.16"; got != want {
+		t.Errorf("Module(d) = %q, want %q", got, want)
+	}
+
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, filepath.Join(dir, "d", "d.go"), nil, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := newResult(fset, []*ast.File{f})
+	if r.Module != "go1.16" || r.File(f) != "go1.22" {
+		t.Errorf("newResult: module %q, file %q; want go1.16, go1.22", r.Module, r.File(f))
+	}
+	if !r.AtLeast(f, "go1.22") || r.AtLeast(f, "go1.23") {
+		t.Errorf("AtLeast is inconsistent with version %q", r.File(f))
+	}
+}
@@ -0,0 +1,271 @@
+// Copyright 2019 Axel Wagner
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package hotspot determines how hot code is, according to a CPU profile or
+// a coverage profile, to restrict performance-oriented diagnostics to code
+// where they matter.
+//
+// For CPU profiles, as written by runtime/pprof, the hotness of a line is
+// the share of samples (in percent) in which the function containing it is
+// on the stack. For coverage profiles, as written by "go test
+// -coverprofile" in count or atomic mode, it is the execution count of the
+// line relative to the most executed block of the profile (in percent).
+package hotspot
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io/ioutil"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/Merovius/go-tools/internal/driver"
+	"github.com/Merovius/go-tools/internal/filter"
+	"golang.org/x/tools/cover"
+)
+
+// A Profile maps source lines to their hotness.
+type Profile struct {
+	// files maps the slash-separated file names of the profile to their
+	// data.
+	files map[string]*fileData
+	// byBase indexes the file names by their base name.
+	byBase map[string][]string
+}
+
+type fileData struct {
+	funcs  []function // sorted by start line
+	blocks []block
+}
+
+// A function of a CPU profile. Its extent is approximated as reaching up to
+// the start of the next function in the same file.
+type function struct {
+	name  string
+	start int
+	hot   float64
+}
+
+// A block of a coverage profile.
+type block struct {
+	start, end int
+	hot        float64
+}
+
+// Read reads a CPU profile in pprof format (possibly gzipped) or a coverage
+// profile from file.
+func Read(file string) (*Profile, error) {
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var p *Profile
+	if bytes.HasPrefix(b, []byte("mode:")) {
+		p, err = parseCoverage(file)
+	} else {
+		p, err = parseCPU(b)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", file, err)
+	}
+	return p, nil
+}
+
+func newProfile() *Profile {
+	return &Profile{
+		files:  make(map[string]*fileData),
+		byBase: make(map[string][]string),
+	}
+}
+
+func (p *Profile) file(name string) *fileData {
+	name = filepath.ToSlash(name)
+	fd := p.files[name]
+	if fd == nil {
+		fd = new(fileData)
+		p.files[name] = fd
+		base := path.Base(name)
+		p.byBase[base] = append(p.byBase[base], name)
+	}
+	return fd
+}
+
+// lookup returns the data of the profile for the file with the given
+// absolute name. As profiles are often created on other machines, or use
+// import paths instead of file names, the file of the profile sharing the
+// longest suffix of path elements with name is used.
+func (p *Profile) lookup(name string) *fileData {
+	name = filepath.ToSlash(name)
+	var (
+		best  string
+		bestN int
+	)
+	for _, cand := range p.byBase[path.Base(name)] {
+		if n := commonSuffix(cand, name); n > bestN {
+			best, bestN = cand, n
+		}
+	}
+	if best == "" {
+		return nil
+	}
+	return p.files[best]
+}
+
+// commonSuffix returns the number of trailing path elements of a and b
+// which are equal.
+func commonSuffix(a, b string) int {
+	as, bs := strings.Split(a, "/"), strings.Split(b, "/")
+	n := 0
+	for n < len(as) && n < len(bs) && as[len(as)-1-n] == bs[len(bs)-1-n] {
+		n++
+	}
+	return n
+}
+
+// Hotness returns the hotness of the given line of file, in percent. It
+// returns 0 for code not in the profile.
+func (p *Profile) Hotness(file string, line int) float64 {
+	fd := p.lookup(file)
+	if fd == nil {
+		return 0
+	}
+	var hot float64
+	if i := sort.Search(len(fd.funcs), func(i int) bool { return fd.funcs[i].start > line }); i > 0 {
+		hot = fd.funcs[i-1].hot
+	}
+	for _, b := range fd.blocks {
+		if b.start <= line && line <= b.end && b.hot > hot {
+			hot = b.hot
+		}
+	}
+	return hot
+}
+
+// Limit drops the diagnostics selected by sel (or all, if sel is nil) in
+// code with a hotness below threshold and annotates the others with their
+// hotness. Other diagnostics are kept unchanged.
+func (p *Profile) Limit(diags []driver.Diagnostic, sel *filter.Filter, threshold float64) []driver.Diagnostic {
+	var out []driver.Diagnostic
+	for _, d := range diags {
+		if sel != nil && !sel.Match(d) {
+			out = append(out, d)
+			continue
+		}
+		hot := p.Hotness(d.Posn.Filename, d.Posn.Line)
+		if hot < threshold {
+			continue
+		}
+		d.Message += fmt.Sprintf(" (hot: %.1f%%)", hot)
+		out = append(out, d)
+	}
+	return out
+}
+
+func parseCoverage(file string) (*Profile, error) {
+	profs, err := cover.ParseProfiles(file)
+	if err != nil {
+		return nil, err
+	}
+	var max int
+	for _, prof := range profs {
+		if prof.Mode == "set" {
+			return nil, fmt.Errorf("coverage profile has mode set, need count or atomic")
+		}
+		for _, b := range prof.Blocks {
+			if b.Count > max {
+				max = b.Count
+			}
+		}
+	}
+	p := newProfile()
+	for _, prof := range profs {
+		fd := p.file(prof.FileName)
+		for _, b := range prof.Blocks {
+			var hot float64
+			if max > 0 {
+				hot = 100 * float64(b.Count) / float64(max)
+			}
+			fd.blocks = append(fd.blocks, block{b.StartLine, b.EndLine, hot})
+		}
+	}
+	return p, nil
+}
+
+func parseCPU(b []byte) (*Profile, error) {
+	if bytes.HasPrefix(b, []byte{0x1f, 0x8b}) {
+		zr, err := gzip.NewReader(bytes.NewReader(b))
+		if err != nil {
+			return nil, err
+		}
+		if b, err = ioutil.ReadAll(bufio.NewReader(zr)); err != nil {
+			return nil, err
+		}
+	}
+	pp, err := decodeProfile(b)
+	if err != nil {
+		return nil, err
+	}
+	idx := pp.valueIndex()
+
+	funcs := make(map[uint64]pprofFunction)
+	for _, f := range pp.functions {
+		funcs[f.id] = f
+	}
+	locs := make(map[uint64][]uint64) // location ID to function IDs
+	for _, l := range pp.locations {
+		locs[l.id] = l.functions
+	}
+	var total int64
+	cum := make(map[uint64]int64)
+	for _, s := range pp.samples {
+		if idx >= len(s.values) {
+			continue
+		}
+		v := s.values[idx]
+		total += v
+		// Count every function once per sample, even if it recurses.
+		seen := make(map[uint64]bool)
+		for _, l := range s.locations {
+			for _, f := range locs[l] {
+				if !seen[f] {
+					seen[f] = true
+					cum[f] += v
+				}
+			}
+		}
+	}
+
+	p := newProfile()
+	for id, f := range funcs {
+		if f.file == "" || f.startLine == 0 {
+			continue
+		}
+		var hot float64
+		if total > 0 {
+			hot = 100 * float64(cum[id]) / float64(total)
+		}
+		fd := p.file(f.file)
+		fd.funcs = append(fd.funcs, function{f.name, int(f.startLine), hot})
+	}
+	for _, fd := range p.files {
+		sort.Slice(fd.funcs, func(i, j int) bool {
+			return fd.funcs[i].start < fd.funcs[j].start
+		})
+	}
+	return p, nil
+}
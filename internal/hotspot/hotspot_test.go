@@ -0,0 +1,227 @@
+// Copyright 2019 Axel Wagner
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hotspot
+
+import (
+	"bytes"
+	"compress/gzip"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime/pprof"
+	"testing"
+
+	"github.com/Merovius/go-tools/internal/driver"
+	"github.com/Merovius/go-tools/internal/filter"
+)
+
+// A minimal protobuf encoder, to construct profiles.
+
+func varint(v uint64) []byte {
+	var b []byte
+	for v >= 0x80 {
+		b = append(b, byte(v)|0x80)
+		v >>= 7
+	}
+	return append(b, byte(v))
+}
+
+func vfield(num int, v uint64) []byte {
+	return append(varint(uint64(num)<<3), varint(v)...)
+}
+
+func bfield(num int, parts ...[]byte) []byte {
+	payload := bytes.Join(parts, nil)
+	b := append(varint(uint64(num)<<3|2), varint(uint64(len(payload)))...)
+	return append(b, payload...)
+}
+
+func packed(num int, vs ...uint64) []byte {
+	var payload []byte
+	for _, v := range vs {
+		payload = append(payload, varint(v)...)
+	}
+	return bfield(num, payload)
+}
+
+func testProfile() []byte {
+	strs := []string{"", "samples", "count", "cpu", "nanoseconds", "main.hot", "/build/a/hot.go", "main.cold", "main.main"}
+	var parts [][]byte
+	parts = append(parts,
+		bfield(1, vfield(1, 1), vfield(2, 2)),
+		bfield(1, vfield(1, 3), vfield(2, 4)),
+		// Samples, leaf first.
+		bfield(2, packed(1, 1, 3), packed(2, 3, 300)),
+		bfield(2, packed(1, 2, 3), packed(2, 1, 100)),
+		// Locations.
+		bfield(4, vfield(1, 1), bfield(4, vfield(1, 1), vfield(2, 12))),
+		bfield(4, vfield(1, 2), bfield(4, vfield(1, 2), vfield(2, 22))),
+		bfield(4, vfield(1, 3), bfield(4, vfield(1, 3), vfield(2, 3))),
+		// Functions.
+		bfield(5, vfield(1, 1), vfield(2, 5), vfield(4, 6), vfield(5, 10)),
+		bfield(5, vfield(1, 2), vfield(2, 7), vfield(4, 6), vfield(5, 20)),
+		bfield(5, vfield(1, 3), vfield(2, 8), vfield(4, 6), vfield(5, 1)),
+	)
+	for _, s := range strs {
+		parts = append(parts, bfield(6, []byte(s)))
+	}
+	return bytes.Join(parts, nil)
+}
+
+func writeTemp(t *testing.T, dir, name string, b []byte) string {
+	t.Helper()
+	file := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(file, b, 0666); err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
+
+func TestCPU(t *testing.T) {
+	dir, err := ioutil.TempDir("", "hotspot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	buf := new(bytes.Buffer)
+	zw := gzip.NewWriter(buf)
+	zw.Write(testProfile())
+	zw.Close()
+	for name, b := range map[string][]byte{"raw.pprof": testProfile(), "gzipped.pprof": buf.Bytes()} {
+		p, err := Read(writeTemp(t, dir, name, b))
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		tcs := []struct {
+			file string
+			line int
+			want float64
+		}{
+			{"/home/me/src/a/hot.go", 12, 75},
+			{"/home/me/src/a/hot.go", 10, 75},
+			{"/home/me/src/a/hot.go", 25, 25},
+			{"/home/me/src/a/hot.go", 5, 100},
+			{"/home/me/src/b/other.go", 12, 0},
+		}
+		for _, tc := range tcs {
+			if got := p.Hotness(tc.file, tc.line); got != tc.want {
+				t.Errorf("%s: Hotness(%s, %d) = %v, want %v", name, tc.file, tc.line, got, tc.want)
+			}
+		}
+	}
+}
+
+func TestLimit(t *testing.T) {
+	dir, err := ioutil.TempDir("", "hotspot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	p, err := Read(writeTemp(t, dir, "cpu.pprof", testProfile()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	diag := func(analyzer string, line int) driver.Diagnostic {
+		return driver.Diagnostic{
+			Analyzer: analyzer,
+			Posn:     token.Position{Filename: "/src/a/hot.go", Line: line},
+			Message:  "slow",
+		}
+	}
+	sel, err := filter.Parse("analyzer:perf", "/src")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := p.Limit([]driver.Diagnostic{
+		diag("perf", 12),
+		diag("perf", 25),
+		diag("style", 25),
+	}, sel, 50)
+	want := []string{"perf: slow (hot: 75.0%)", "style: slow"}
+	if len(got) != len(want) {
+		t.Fatalf("Limit() = %v, want %v", got, want)
+	}
+	for i, d := range got {
+		if s := d.Analyzer + ": " + d.Message; s != want[i] {
+			t.Errorf("Limit()[%d] = %q, want %q", i, s, want[i])
+		}
+	}
+}
+
+func TestRuntimeProfile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "hotspot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	f, err := os.Create(filepath.Join(dir, "cpu.pprof"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := pprof.StartCPUProfile(f); err != nil {
+		t.Skip("can't profile:", err)
+	}
+	pprof.StopCPUProfile()
+	f.Close()
+	if _, err := Read(f.Name()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCoverage(t *testing.T) {
+	dir, err := ioutil.TempDir("", "hotspot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	p, err := Read(writeTemp(t, dir, "cover.out", []byte(`mode: count
+example.com/a/hot.go:3.10,5.2 2 10
+example.com/a/hot.go:6.2,8.3 1 5
+`)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for line, want := range map[int]float64{4: 100, 7: 50, 9: 0} {
+		if got := p.Hotness("/src/a/hot.go", line); got != want {
+			t.Errorf("Hotness(hot.go, %d) = %v, want %v", line, got, want)
+		}
+	}
+
+	_, err = Read(writeTemp(t, dir, "set.out", []byte(`mode: set
+example.com/a/hot.go:3.10,5.2 2 1
+`)))
+	if err == nil {
+		t.Error("Read(set mode) succeeded, want error")
+	}
+}
+
+func TestInvalid(t *testing.T) {
+	dir, err := ioutil.TempDir("", "hotspot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for name, b := range map[string][]byte{
+		"truncated": testProfile()[:20],
+		"empty":     nil,
+	} {
+		if _, err := Read(writeTemp(t, dir, name, b)); err == nil {
+			t.Errorf("Read(%s) succeeded, want error", name)
+		}
+	}
+}
@@ -0,0 +1,238 @@
+// Copyright 2019 Axel Wagner
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hotspot
+
+import (
+	"errors"
+	"fmt"
+)
+
+// This file decodes the parts of the profile.proto format of pprof needed
+// to attribute samples to functions. See
+// https://github.com/google/pprof/blob/master/proto/profile.proto.
+
+type pprofProfile struct {
+	sampleTypes []pprofValueType
+	samples     []pprofSample
+	locations   []pprofLocation
+	functions   []pprofFunction
+	strings     []string
+}
+
+type pprofValueType struct {
+	typ, unit int64 // indices into strings
+}
+
+type pprofSample struct {
+	locations []uint64 // leaf first
+	values    []int64
+}
+
+type pprofLocation struct {
+	id        uint64
+	functions []uint64 // inlined functions first
+}
+
+type pprofFunction struct {
+	id        uint64
+	name      string
+	file      string
+	startLine int64
+
+	nameIdx, fileIdx int64
+}
+
+// valueIndex returns the index of the sample value measuring CPU time, or of
+// the last one, which is the default in pprof.
+func (p *pprofProfile) valueIndex() int {
+	for i, vt := range p.sampleTypes {
+		if p.str(vt.typ) == "cpu" {
+			return i
+		}
+	}
+	return len(p.sampleTypes) - 1
+}
+
+func (p *pprofProfile) str(i int64) string {
+	if i < 0 || i >= int64(len(p.strings)) {
+		return ""
+	}
+	return p.strings[i]
+}
+
+func decodeProfile(b []byte) (*pprofProfile, error) {
+	p := new(pprofProfile)
+	err := decodeMessage(b, func(field int, w wireValue) error {
+		switch field {
+		case 1:
+			var vt pprofValueType
+			err := decodeMessage(w.bytes, func(field int, w wireValue) error {
+				switch field {
+				case 1:
+					vt.typ = int64(w.varint)
+				case 2:
+					vt.unit = int64(w.varint)
+				}
+				return nil
+			})
+			p.sampleTypes = append(p.sampleTypes, vt)
+			return err
+		case 2:
+			var s pprofSample
+			err := decodeMessage(w.bytes, func(field int, w wireValue) error {
+				switch field {
+				case 1:
+					return w.uint64s(func(v uint64) { s.locations = append(s.locations, v) })
+				case 2:
+					return w.uint64s(func(v uint64) { s.values = append(s.values, int64(v)) })
+				}
+				return nil
+			})
+			p.samples = append(p.samples, s)
+			return err
+		case 4:
+			var l pprofLocation
+			err := decodeMessage(w.bytes, func(field int, w wireValue) error {
+				switch field {
+				case 1:
+					l.id = w.varint
+				case 4:
+					return decodeMessage(w.bytes, func(field int, w wireValue) error {
+						if field == 1 {
+							l.functions = append(l.functions, w.varint)
+						}
+						return nil
+					})
+				}
+				return nil
+			})
+			p.locations = append(p.locations, l)
+			return err
+		case 5:
+			var f pprofFunction
+			err := decodeMessage(w.bytes, func(field int, w wireValue) error {
+				switch field {
+				case 1:
+					f.id = w.varint
+				case 2:
+					f.nameIdx = int64(w.varint)
+				case 4:
+					f.fileIdx = int64(w.varint)
+				case 5:
+					f.startLine = int64(w.varint)
+				}
+				return nil
+			})
+			p.functions = append(p.functions, f)
+			return err
+		case 6:
+			p.strings = append(p.strings, string(w.bytes))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(p.sampleTypes) == 0 {
+		return nil, errors.New("not a pprof profile: no sample types")
+	}
+	for i := range p.functions {
+		f := &p.functions[i]
+		f.name, f.file = p.str(f.nameIdx), p.str(f.fileIdx)
+	}
+	return p, nil
+}
+
+// A wireValue is a decoded protobuf field. Depending on the wire type,
+// either varint or bytes is set.
+type wireValue struct {
+	typ    int
+	varint uint64
+	bytes  []byte
+}
+
+// uint64s calls f for the values of a repeated integer field, which is
+// either packed or a single varint.
+func (w wireValue) uint64s(f func(uint64)) error {
+	if w.typ == 0 {
+		f(w.varint)
+		return nil
+	}
+	b := w.bytes
+	for len(b) > 0 {
+		v, n := decodeVarint(b)
+		if n == 0 {
+			return errors.New("invalid packed field")
+		}
+		f(v)
+		b = b[n:]
+	}
+	return nil
+}
+
+// decodeMessage calls f for every field of the protobuf message b.
+func decodeMessage(b []byte, f func(field int, w wireValue) error) error {
+	for len(b) > 0 {
+		key, n := decodeVarint(b)
+		if n == 0 {
+			return errors.New("invalid field key")
+		}
+		b = b[n:]
+		w := wireValue{typ: int(key & 7)}
+		switch w.typ {
+		case 0:
+			if w.varint, n = decodeVarint(b); n == 0 {
+				return errors.New("invalid varint")
+			}
+			b = b[n:]
+		case 1:
+			if len(b) < 8 {
+				return errors.New("truncated fixed64")
+			}
+			b = b[8:]
+		case 2:
+			l, n := decodeVarint(b)
+			if n == 0 || uint64(len(b)-n) < l {
+				return errors.New("truncated field")
+			}
+			w.bytes = b[n : n+int(l)]
+			b = b[n+int(l):]
+		case 5:
+			if len(b) < 4 {
+				return errors.New("truncated fixed32")
+			}
+			b = b[4:]
+		default:
+			return fmt.Errorf("unsupported wire type %d", w.typ)
+		}
+		if err := f(int(key>>3), w); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// decodeVarint decodes a varint from b and returns it and the number of
+// bytes read, or 0 if b doesn't start with a valid varint.
+func decodeVarint(b []byte) (uint64, int) {
+	var v uint64
+	for i := 0; i < len(b) && i < 10; i++ {
+		v |= uint64(b[i]&0x7f) << (7 * uint(i))
+		if b[i] < 0x80 {
+			return v, i + 1
+		}
+	}
+	return 0, 0
+}
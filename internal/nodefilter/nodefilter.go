@@ -0,0 +1,164 @@
+// Copyright 2019 Axel Wagner
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package nodefilter lets analyzers share a single traversal of the syntax
+// trees of a package. Every analyzer declares the node types it is
+// interested in with New, during initialization. The first analyzer
+// needing the result walks the inspector of inspect.Analyzer once, for the
+// union of all declared types, and records the matching nodes for each
+// filter. Other analyzers then only iterate over their own nodes, instead
+// of walking the whole tree again.
+package nodefilter
+
+import (
+	"go/ast"
+	"reflect"
+	"sync"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/passes/inspect"
+	"golang.org/x/tools/go/ast/inspector"
+)
+
+// Analyzer records the nodes matching all filters. Its result is a
+// *Result.
+var Analyzer = &analysis.Analyzer{
+	Name:             "nodefilter",
+	Doc:              "collect the nodes of interest to analyzers in one traversal",
+	Run:              run,
+	RunDespiteErrors: true,
+	Requires:         []*analysis.Analyzer{inspect.Analyzer},
+	ResultType:       reflect.TypeOf(new(Result)),
+}
+
+// A Filter selects nodes by their type.
+type Filter struct {
+	index int
+	types map[reflect.Type]bool
+	stack bool
+}
+
+var filters struct {
+	sync.Mutex
+	all []*Filter
+}
+
+// New returns a filter selecting nodes of the same types as the given ones,
+// like the types argument of inspector.Preorder. If stack is set, the
+// enclosing nodes of every node are recorded as well. New must be called
+// before the analysis starts, usually in a package-level variable
+// declaration.
+func New(stack bool, types ...ast.Node) *Filter {
+	f := &Filter{types: make(map[reflect.Type]bool), stack: stack}
+	for _, n := range types {
+		f.types[reflect.TypeOf(n)] = true
+	}
+	filters.Lock()
+	defer filters.Unlock()
+	f.index = len(filters.all)
+	filters.all = append(filters.all, f)
+	return f
+}
+
+// A Node is a node matching a filter.
+type Node struct {
+	ast.Node
+
+	// Stack lists the enclosing nodes, from the *ast.File down to and
+	// including Node, like in inspector.WithStack. It is only recorded for
+	// filters asking for it.
+	Stack []ast.Node
+}
+
+// A Result holds the nodes matching each filter, in depth-first order.
+type Result struct {
+	nodes [][]Node
+}
+
+// Nodes returns the nodes matching f.
+func (r *Result) Nodes(f *Filter) []Node {
+	if f.index >= len(r.nodes) {
+		panic("nodefilter: filter created after the analysis started")
+	}
+	return r.nodes[f.index]
+}
+
+func run(pass *analysis.Pass) (interface{}, error) {
+	filters.Lock()
+	fs := append([]*Filter(nil), filters.all...)
+	filters.Unlock()
+
+	return collect(pass.ResultOf[inspect.Analyzer].(*inspector.Inspector), fs, len(fs)), nil
+}
+
+// Walk returns the nodes of files matching the filters fs. It is meant for
+// syntax trees not covered by Analyzer, like files an analyzer parses
+// again. Other filters have no nodes in the result.
+func Walk(files []*ast.File, fs ...*Filter) *Result {
+	size := 0
+	for _, f := range fs {
+		if f.index >= size {
+			size = f.index + 1
+		}
+	}
+	return collect(inspector.New(files), fs, size)
+}
+
+// collect records the nodes matching the filters fs, in a result with room
+// for size filters.
+func collect(insp *inspector.Inspector, fs []*Filter, size int) *Result {
+	var (
+		types    []ast.Node
+		seen     = make(map[reflect.Type]bool)
+		byType   = make(map[reflect.Type][]*Filter)
+		anyStack bool
+	)
+	for _, f := range fs {
+		for t := range f.types {
+			if !seen[t] {
+				seen[t] = true
+				types = append(types, reflect.Zero(t).Interface().(ast.Node))
+			}
+			byType[t] = append(byType[t], f)
+		}
+		anyStack = anyStack || f.stack
+	}
+
+	r := &Result{nodes: make([][]Node, size)}
+	if len(types) == 0 {
+		return r
+	}
+	record := func(n ast.Node, stack []ast.Node) {
+		for _, f := range byType[reflect.TypeOf(n)] {
+			nd := Node{Node: n}
+			if f.stack {
+				nd.Stack = stack
+			}
+			r.nodes[f.index] = append(r.nodes[f.index], nd)
+		}
+	}
+	if !anyStack {
+		insp.Preorder(types, func(n ast.Node) { record(n, nil) })
+		return r
+	}
+	insp.WithStack(types, func(n ast.Node, push bool, stack []ast.Node) bool {
+		if push {
+			// The stack is reused by the inspector, so it is copied once
+			// and shared by all filters.
+			record(n, append([]ast.Node(nil), stack...))
+		}
+		return true
+	})
+	return r
+}
@@ -0,0 +1,88 @@
+// Copyright 2019 Axel Wagner
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nodefilter
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"reflect"
+	"testing"
+
+	"github.com/Merovius/go-tools/internal/analysistestx"
+	"golang.org/x/tools/go/analysis"
+)
+
+var (
+	calls = New(false, new(ast.CallExpr))
+	lits  = New(true, new(ast.BasicLit), new(ast.CompositeLit))
+)
+
+var testAnalyzer = &analysis.Analyzer{
+	Name:     "nodefiltertest",
+	Doc:      "report the nodes recorded by nodefilter",
+	Requires: []*analysis.Analyzer{Analyzer},
+	Run: func(pass *analysis.Pass) (interface{}, error) {
+		r := pass.ResultOf[Analyzer].(*Result)
+		for _, n := range r.Nodes(calls) {
+			if n.Stack != nil {
+				pass.Reportf(n.Pos(), "call with stack")
+			} else {
+				pass.Reportf(n.Pos(), "call")
+			}
+		}
+		for _, n := range r.Nodes(lits) {
+			if _, ok := n.Stack[0].(*ast.File); !ok || n.Stack[len(n.Stack)-1] != n.Node {
+				pass.Reportf(n.Pos(), "bad stack")
+				continue
+			}
+			pass.Reportf(n.Pos(), "literal at depth %d", len(n.Stack))
+		}
+		return nil, nil
+	},
+}
+
+func TestNodes(t *testing.T) {
+	analysistestx.Run(t, analysistestx.TestData(), testAnalyzer, "a")
+}
+
+func TestLateFilter(t *testing.T) {
+	r := &Result{nodes: make([][]Node, 1)}
+	defer func() {
+		if recover() == nil {
+			t.Error("Nodes did not panic for a filter created after the analysis")
+		}
+	}()
+	r.Nodes(&Filter{index: 1})
+}
+
+func TestWalk(t *testing.T) {
+	f, err := parser.ParseFile(token.NewFileSet(), "a.go", "package a\n\nvar x = f(g(1), []int{2})\n", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := Walk([]*ast.File{f}, lits)
+	if n := len(r.Nodes(calls)); n != 0 {
+		t.Errorf("Walk recorded %d calls, which weren't asked for", n)
+	}
+	var got []string
+	for _, n := range r.Nodes(lits) {
+		got = append(got, fmt.Sprintf("%T@%d", n.Node, len(n.Stack)))
+	}
+	if want := []string{"*ast.BasicLit@6", "*ast.CompositeLit@5", "*ast.BasicLit@6"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Walk recorded literals %v, want %v", got, want)
+	}
+}
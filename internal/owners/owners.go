@@ -0,0 +1,226 @@
+// Copyright 2019 Axel Wagner
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package owners determines the owners of files, to split reports by the
+// teams responsible for the flagged code.
+//
+// Owners are read from a CODEOWNERS file in the root directory, or in its
+// .github or docs subdirectory, using the syntax of GitHub: every line
+// consists of a gitignore-style pattern followed by owners, and the last
+// matching line determines the owners of a file. In addition, a file named
+// OWNERS, listing one owner per line, determines the owners of the files in
+// its directory and below. It takes precedence over CODEOWNERS, and the
+// OWNERS file in the closest directory applies. In both files, # starts a
+// comment.
+package owners
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/Merovius/go-tools/internal/driver"
+)
+
+// Unowned is the key of diagnostics in files without owners in the result
+// of Split.
+const Unowned = "unowned"
+
+// An Owners determines the owners of files below a root directory. It is
+// safe for concurrent use.
+type Owners struct {
+	root  string
+	rules []rule
+
+	mu   sync.Mutex
+	dirs map[string][]string // cached OWNERS lookups, nil if none
+}
+
+type rule struct {
+	re     *regexp.Regexp
+	owners []string
+}
+
+// Load reads the CODEOWNERS file in root, if any.
+func Load(root string) (*Owners, error) {
+	o := &Owners{root: root, dirs: make(map[string][]string)}
+	for _, name := range []string{"CODEOWNERS", ".github/CODEOWNERS", "docs/CODEOWNERS"} {
+		f, err := os.Open(filepath.Join(root, filepath.FromSlash(name)))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		if o.rules, err = parseCodeowners(f.Name(), f); err != nil {
+			return nil, err
+		}
+		break
+	}
+	return o, nil
+}
+
+func parseCodeowners(name string, f *os.File) ([]rule, error) {
+	var rules []rule
+	s := bufio.NewScanner(f)
+	for n := 1; s.Scan(); n++ {
+		fields := strings.Fields(stripComment(s.Text()))
+		if len(fields) == 0 {
+			continue
+		}
+		re, err := patternRegexp(fields[0])
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: invalid pattern %q: %v", name, n, fields[0], err)
+		}
+		rules = append(rules, rule{re, fields[1:]})
+	}
+	return rules, s.Err()
+}
+
+func stripComment(l string) string {
+	if i := strings.IndexByte(l, '#'); i >= 0 {
+		l = l[:i]
+	}
+	return strings.TrimSpace(l)
+}
+
+// patternRegexp translates a CODEOWNERS pattern to a regular expression
+// matching slash-separated paths relative to the root.
+func patternRegexp(pat string) (*regexp.Regexp, error) {
+	var b strings.Builder
+	// Patterns containing a slash (other than a trailing one) are relative
+	// to the root, others match at any depth.
+	if strings.Contains(strings.TrimSuffix(pat, "/"), "/") {
+		b.WriteString("^")
+		pat = strings.TrimPrefix(pat, "/")
+	} else {
+		b.WriteString("^(?:.*/)?")
+	}
+	dir := strings.HasSuffix(pat, "/")
+	pat = strings.TrimSuffix(pat, "/")
+	for i := 0; i < len(pat); i++ {
+		switch c := pat[i]; c {
+		case '*':
+			if strings.HasPrefix(pat[i:], "**/") {
+				b.WriteString("(?:.*/)?")
+				i += 2
+			} else if strings.HasPrefix(pat[i:], "**") {
+				b.WriteString(".*")
+				i++
+			} else {
+				b.WriteString("[^/]*")
+			}
+		case '?':
+			b.WriteString("[^/]")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	if dir {
+		// Only the contents of directories match.
+		b.WriteString("/.*$")
+	} else {
+		// The pattern matches files and directories.
+		b.WriteString("(?:/.*)?$")
+	}
+	return regexp.Compile(b.String())
+}
+
+// Of returns the owners of file, or nil if it has none. file may be
+// absolute or relative to the root.
+func (o *Owners) Of(file string) []string {
+	if filepath.IsAbs(file) {
+		rel, err := filepath.Rel(o.root, file)
+		if err != nil || strings.HasPrefix(rel, "..") {
+			return nil
+		}
+		file = rel
+	}
+	if owners := o.ownersFile(filepath.Dir(file)); owners != nil {
+		return owners
+	}
+	slash := filepath.ToSlash(file)
+	for i := len(o.rules) - 1; i >= 0; i-- {
+		if o.rules[i].re.MatchString(slash) {
+			return o.rules[i].owners
+		}
+	}
+	return nil
+}
+
+// ownersFile returns the owners listed in the closest OWNERS file of the
+// directory dir, relative to the root.
+func (o *Owners) ownersFile(dir string) []string {
+	o.mu.Lock()
+	owners, ok := o.dirs[dir]
+	o.mu.Unlock()
+	if ok {
+		return owners
+	}
+	if b, err := readLines(filepath.Join(o.root, dir, "OWNERS")); err == nil {
+		owners = b
+	} else if dir != "." {
+		owners = o.ownersFile(filepath.Dir(dir))
+	}
+	o.mu.Lock()
+	o.dirs[dir] = owners
+	o.mu.Unlock()
+	return owners
+}
+
+func readLines(file string) ([]string, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var lines []string
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		lines = append(lines, strings.Fields(stripComment(s.Text()))...)
+	}
+	return lines, s.Err()
+}
+
+// Split partitions diags by the owners of their files. Diagnostics in files
+// with several owners are in the partition of each of them.
+func (o *Owners) Split(diags []driver.Diagnostic) map[string][]driver.Diagnostic {
+	m := make(map[string][]driver.Diagnostic)
+	for _, d := range diags {
+		owners := o.Of(d.Posn.Filename)
+		if len(owners) == 0 {
+			owners = []string{Unowned}
+		}
+		for _, owner := range owners {
+			m[owner] = append(m[owner], d)
+		}
+	}
+	return m
+}
+
+// Names returns the owners in m, sorted.
+func Names(m map[string][]driver.Diagnostic) []string {
+	var names []string
+	for n := range m {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,101 @@
+// Copyright 2019 Axel Wagner
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package owners
+
+import (
+	"go/token"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/Merovius/go-tools/internal/driver"
+)
+
+const codeowners = `# Default owners.
+*                 @all
+*.go              @gophers
+/docs/            @docs
+internal/         @core @gophers
+/cmd/**/main.go   @cli # commands
+`
+
+func TestOf(t *testing.T) {
+	dir, err := ioutil.TempDir("", "owners")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	files := map[string]string{
+		".github/CODEOWNERS":   codeowners,
+		"third_party/OWNERS":   "alice\n# comment\nbob\n",
+		"third_party/x/OWNERS": "carol\n",
+	}
+	for name, content := range files {
+		file := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(file), 0777); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(file, []byte(content), 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+	o, err := Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tcs := []struct {
+		file string
+		want []string
+	}{
+		{"README.md", []string{"@all"}},
+		{"a.go", []string{"@gophers"}},
+		{"docs/a.go", []string{"@docs"}},
+		{"x/docs/a.go", []string{"@gophers"}},
+		{"internal/a/a.go", []string{"@core", "@gophers"}},
+		{"x/internal/a.go", []string{"@core", "@gophers"}},
+		{"cmd/gotools/main.go", []string{"@cli"}},
+		{"cmd/a/b/main.go", []string{"@cli"}},
+		{"cmd/gotools/run.go", []string{"@gophers"}},
+		{"third_party/y/a.go", []string{"alice", "bob"}},
+		{"third_party/x/a.go", []string{"carol"}},
+		{filepath.Join(dir, "a.go"), []string{"@gophers"}},
+		{"/elsewhere/a.go", nil},
+	}
+	for _, tc := range tcs {
+		if got := o.Of(filepath.FromSlash(tc.file)); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("Of(%s) = %v, want %v", tc.file, got, tc.want)
+		}
+	}
+
+	diags := []driver.Diagnostic{
+		{Posn: token.Position{Filename: filepath.Join(dir, "internal", "a.go")}},
+		{Posn: token.Position{Filename: filepath.Join(dir, "a.go")}},
+		{Posn: token.Position{Filename: "/elsewhere/a.go"}},
+	}
+	split := o.Split(diags)
+	count := make(map[string]int)
+	for owner, ds := range split {
+		count[owner] = len(ds)
+	}
+	want := map[string]int{"@core": 1, "@gophers": 2, Unowned: 1}
+	if !reflect.DeepEqual(count, want) {
+		t.Errorf("Split() has %v diagnostics per owner, want %v", count, want)
+	}
+	if got, want := Names(split), []string{"@core", "@gophers", Unowned}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Names() = %v, want %v", got, want)
+	}
+}
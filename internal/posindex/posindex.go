@@ -0,0 +1,143 @@
+// Copyright 2019 Axel Wagner
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package posindex indexes the lines of source files, for reporters which
+// need the content of flagged lines or have to convert between byte
+// offsets and line and column numbers. Every file is read and scanned only
+// once, no matter how many diagnostics refer to it, and the index can be
+// shared by all reporters of a run.
+package posindex
+
+import (
+	"bytes"
+	"go/token"
+	"io/ioutil"
+	"sort"
+	"sync"
+)
+
+// An Index holds the indexed files. It is safe for concurrent use.
+type Index struct {
+	mu    sync.Mutex
+	files map[string]*File
+}
+
+// New returns an empty index.
+func New() *Index {
+	return &Index{files: make(map[string]*File)}
+}
+
+// File returns the indexed file with the given name, reading it if
+// necessary. It returns nil if the file can't be read.
+func (ix *Index) File(name string) *File {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	f, ok := ix.files[name]
+	if !ok {
+		if src, err := ioutil.ReadFile(name); err == nil {
+			f = NewFile(name, src)
+		}
+		ix.files[name] = f
+	}
+	return f
+}
+
+// Add adds f to the index, replacing the file with the same name. This can
+// be used for content which is not on disk.
+func (ix *Index) Add(f *File) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	ix.files[f.Name] = f
+}
+
+// Line returns the content of line n of file, without the line
+// terminator, or nil if there is no such line.
+func (ix *Index) Line(file string, n int) []byte {
+	return ix.File(file).Line(n)
+}
+
+// A File is the content of a source file with the offsets of its lines.
+type File struct {
+	Name string
+	src  []byte
+
+	// lines holds the offset of the first byte of every line.
+	lines []int
+}
+
+// NewFile indexes src, the content of the file name.
+func NewFile(name string, src []byte) *File {
+	f := &File{Name: name, src: src, lines: []int{0}}
+	for i, b := range src {
+		if b == '\n' {
+			f.lines = append(f.lines, i+1)
+		}
+	}
+	return f
+}
+
+// LineCount returns the number of lines of f.
+func (f *File) LineCount() int {
+	if f == nil {
+		return 0
+	}
+	return len(f.lines)
+}
+
+// Line returns the content of the 1-based line n, without the line
+// terminator, or nil if there is no such line. It can be called on a nil
+// *File.
+func (f *File) Line(n int) []byte {
+	if f == nil || n < 1 || n > len(f.lines) {
+		return nil
+	}
+	end := len(f.src)
+	if n < len(f.lines) {
+		end = f.lines[n] - 1
+	}
+	return bytes.TrimSuffix(f.src[f.lines[n-1]:end], []byte("\r"))
+}
+
+// Position returns the position of the byte offset in f. Columns are
+// 1-based byte counts, as in go/token. It returns an invalid position, if
+// the offset is out of range.
+func (f *File) Position(offset int) token.Position {
+	if f == nil || offset < 0 || offset > len(f.src) {
+		return token.Position{}
+	}
+	i := sort.Search(len(f.lines), func(i int) bool { return f.lines[i] > offset }) - 1
+	return token.Position{
+		Filename: f.Name,
+		Offset:   offset,
+		Line:     i + 1,
+		Column:   offset - f.lines[i] + 1,
+	}
+}
+
+// Offset returns the byte offset of the 1-based line and column, or -1 if
+// they are out of range.
+func (f *File) Offset(line, column int) int {
+	if f == nil || line < 1 || line > len(f.lines) || column < 1 {
+		return -1
+	}
+	off := f.lines[line-1] + column - 1
+	end := len(f.src)
+	if line < len(f.lines) {
+		end = f.lines[line]
+	}
+	if off > end {
+		return -1
+	}
+	return off
+}
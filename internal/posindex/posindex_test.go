@@ -0,0 +1,89 @@
+// Copyright 2019 Axel Wagner
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package posindex
+
+import (
+	"go/token"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFile(t *testing.T) {
+	src := "package p\r\n\nfunc f() {}\nvar x"
+	f := NewFile("p.go", []byte(src))
+	if n := f.LineCount(); n != 4 {
+		t.Errorf("LineCount() = %d, want 4", n)
+	}
+	lines := []string{"", "package p", "", "func f() {}", "var x", ""}
+	for n, want := range lines {
+		if got := string(f.Line(n)); got != want {
+			t.Errorf("Line(%d) = %q, want %q", n, got, want)
+		}
+	}
+
+	fset := token.NewFileSet()
+	tf := fset.AddFile("p.go", -1, len(src))
+	tf.SetLinesForContent([]byte(src))
+	for off := 0; off <= len(src); off++ {
+		want := tf.Position(tf.Pos(off))
+		if got := f.Position(off); got != want {
+			t.Errorf("Position(%d) = %v, want %v", off, got, want)
+		}
+		if got := f.Offset(want.Line, want.Column); got != off {
+			t.Errorf("Offset(%d, %d) = %d, want %d", want.Line, want.Column, got, off)
+		}
+	}
+	if p := f.Position(len(src) + 1); p.IsValid() {
+		t.Errorf("Position beyond the end = %v, want invalid", p)
+	}
+	if off := f.Offset(2, 3); off != -1 {
+		t.Errorf("Offset(2, 3) = %d, want -1", off)
+	}
+}
+
+func TestIndex(t *testing.T) {
+	dir, err := ioutil.TempDir("", "posindex")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	name := filepath.Join(dir, "a.go")
+	if err := ioutil.WriteFile(name, []byte("package a\n\nvar x int\n"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	ix := New()
+	if got := string(ix.Line(name, 3)); got != "var x int" {
+		t.Errorf("Line(3) = %q, want %q", got, "var x int")
+	}
+	// The file is only read once.
+	if err := os.Remove(name); err != nil {
+		t.Fatal(err)
+	}
+	if got := string(ix.Line(name, 1)); got != "package a" {
+		t.Errorf("Line(1) after removal = %q, want %q", got, "package a")
+	}
+	ix.Add(NewFile(name, []byte("package b\n")))
+	if got := string(ix.Line(name, 1)); got != "package b" {
+		t.Errorf("Line(1) after Add = %q, want %q", got, "package b")
+	}
+	if ix.File(filepath.Join(dir, "missing.go")) != nil {
+		t.Error("File(missing.go) != nil")
+	}
+	if l := ix.Line(filepath.Join(dir, "missing.go"), 1); l != nil {
+		t.Errorf("Line of missing file = %q, want nil", l)
+	}
+}
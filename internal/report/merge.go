@@ -0,0 +1,161 @@
+// Copyright 2019 Axel Wagner
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package report
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+)
+
+// Merge combines reports in the JSON or SARIF format, like the ones of runs
+// analyzing different shards of the packages, into one report in the same
+// format, which it writes to w. The format is detected from the reports.
+// Findings contained in more than one report are only written once.
+func Merge(w io.Writer, reports ...io.Reader) error {
+	if len(reports) == 0 {
+		return fmt.Errorf("no reports to merge")
+	}
+	var (
+		format Format
+		docs   [][]byte
+	)
+	for i, r := range reports {
+		b, err := ioutil.ReadAll(r)
+		if err != nil {
+			return err
+		}
+		f, err := detectFormat(b)
+		if err != nil {
+			return fmt.Errorf("report %d: %v", i+1, err)
+		}
+		if i > 0 && f != format {
+			return fmt.Errorf("report %d is in format %s, the first in %s", i+1, f, format)
+		}
+		format = f
+		docs = append(docs, b)
+	}
+	if format == SARIF {
+		return mergeSARIF(w, docs)
+	}
+	return mergeJSON(w, docs)
+}
+
+// detectFormat returns the format of a report. SARIF logs are told from the
+// JSON format by their $schema key, which isn't a valid package ID.
+func detectFormat(b []byte) (Format, error) {
+	var m map[string]json.RawMessage
+	if err := json.Unmarshal(b, &m); err != nil {
+		return "", fmt.Errorf("not a JSON or SARIF report: %v", err)
+	}
+	if _, ok := m["$schema"]; ok {
+		return SARIF, nil
+	}
+	return JSON, nil
+}
+
+func mergeJSON(w io.Writer, docs [][]byte) error {
+	tree := make(map[string]map[string][]jsonDiagnostic)
+	seen := make(map[string]bool)
+	for _, doc := range docs {
+		var t map[string]map[string][]jsonDiagnostic
+		if err := json.Unmarshal(doc, &t); err != nil {
+			return err
+		}
+		for pkg, byAnalyzer := range t {
+			m := tree[pkg]
+			if m == nil {
+				m = make(map[string][]jsonDiagnostic)
+				tree[pkg] = m
+			}
+			for a, diags := range byAnalyzer {
+				for _, d := range diags {
+					if key := dedupKey(pkg, a, d); !seen[key] {
+						seen[key] = true
+						m[a] = append(m[a], d)
+					}
+				}
+			}
+		}
+	}
+	b, err := json.MarshalIndent(tree, "", "\t")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "%s\n", b)
+	return err
+}
+
+// mergeSARIF combines the runs of all logs into one. Rules are merged by
+// their ID and invocations are concatenated. The base URIs of the first log
+// are kept, as the ones of the others refer to the same root, checked out in
+// another place.
+func mergeSARIF(w io.Writer, docs [][]byte) error {
+	var (
+		out   sarifRun
+		index = make(map[string]int)
+		seen  = make(map[string]bool)
+	)
+	for i, doc := range docs {
+		var l sarifLog
+		if err := json.Unmarshal(doc, &l); err != nil {
+			return err
+		}
+		for _, run := range l.Runs {
+			if out.Results == nil {
+				out.Tool = run.Tool
+				out.Tool.Driver.Rules = nil
+				out.OriginalURIBaseIDs = run.OriginalURIBaseIDs
+				out.Results = []sarifResult{}
+			}
+			out.Invocations = append(out.Invocations, run.Invocations...)
+			for _, r := range run.Tool.Driver.Rules {
+				if _, ok := index[r.ID]; !ok {
+					index[r.ID] = len(out.Tool.Driver.Rules)
+					out.Tool.Driver.Rules = append(out.Tool.Driver.Rules, r)
+				}
+			}
+			for _, res := range run.Results {
+				if _, ok := index[res.RuleID]; !ok {
+					return fmt.Errorf("report %d: result of undescribed rule %s", i+1, res.RuleID)
+				}
+				res.RuleIndex = index[res.RuleID]
+				if key := dedupKey(res); !seen[key] {
+					seen[key] = true
+					out.Results = append(out.Results, res)
+				}
+			}
+		}
+	}
+	b, err := json.MarshalIndent(sarifLog{
+		Schema:  sarifSchema,
+		Version: sarifVersion,
+		Runs:    []sarifRun{out},
+	}, "", "\t")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "%s\n", b)
+	return err
+}
+
+// dedupKey returns a key identifying a finding by its encoding.
+func dedupKey(v ...interface{}) string {
+	var buf bytes.Buffer
+	json.NewEncoder(&buf).Encode(v)
+	return buf.String()
+}
@@ -0,0 +1,164 @@
+// Copyright 2019 Axel Wagner
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package report writes diagnostics in the output formats of gotools.
+package report
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/Merovius/go-tools/internal/driver"
+	"github.com/Merovius/go-tools/internal/posindex"
+	"golang.org/x/tools/go/analysis"
+)
+
+// A Format is an output format.
+type Format string
+
+// The supported formats.
+const (
+	Text  Format = "text"
+	JSON  Format = "json"
+	SARIF Format = "sarif"
+)
+
+// Options configure the output.
+type Options struct {
+	// Analyzers are the analyzers which were run. Formats describing the
+	// rules checked use them.
+	Analyzers []*analysis.Analyzer
+
+	// Root is the directory file names are relative to, in formats
+	// supporting relative file names.
+	Root string
+
+	// Index is used to read source files, for formats including source
+	// snippets. If nil, a new one is used.
+	Index *posindex.Index
+
+	// Notes describe why the analysis is incomplete, if it is, for formats
+	// which can state it.
+	Notes []string
+}
+
+// String implements flag.Value.
+func (f *Format) String() string {
+	return string(*f)
+}
+
+// Set implements flag.Value.
+func (f *Format) Set(s string) error {
+	switch Format(s) {
+	case Text, JSON, SARIF:
+		*f = Format(s)
+		return nil
+	}
+	return fmt.Errorf("unknown format %q", s)
+}
+
+// Write writes diags to w in format f.
+func Write(w io.Writer, f Format, diags []driver.Diagnostic, opts *Options) error {
+	switch f {
+	case Text:
+		return WriteText(w, diags)
+	case JSON:
+		return WriteJSON(w, diags)
+	case SARIF:
+		return WriteSARIF(w, diags, opts)
+	}
+	return fmt.Errorf("unknown format %q", f)
+}
+
+// WriteText writes one line per diagnostic.
+func WriteText(w io.Writer, diags []driver.Diagnostic) error {
+	for _, d := range diags {
+		if _, err := fmt.Fprintln(w, d); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// The JSON format is the one of "go vet -json", which maps package IDs to
+// analyzer names to diagnostics. Suggested fixes are given as edits of byte
+// offsets, so they can be applied without the source being parsed. Like in
+// go vet, the offsets refer to the file as it was analyzed; Level is an
+// extension giving the safety level of a fix, as are Rule, the ID of the
+// rule of a diagnostic, and its Annotations.
+
+type jsonDiagnostic struct {
+	Category       string             `json:"category,omitempty"`
+	Rule           string             `json:"rule,omitempty"`
+	Posn           string             `json:"posn"`
+	Message        string             `json:"message"`
+	SuggestedFixes []jsonSuggestedFix `json:"suggested_fixes,omitempty"`
+	Annotations    map[string]string  `json:"annotations,omitempty"`
+}
+
+type jsonSuggestedFix struct {
+	Message string         `json:"message"`
+	Level   string         `json:"level"`
+	Edits   []jsonTextEdit `json:"edits"`
+}
+
+type jsonTextEdit struct {
+	Filename string `json:"filename"`
+	Start    int    `json:"start"`
+	End      int    `json:"end"`
+	New      string `json:"new"`
+}
+
+// WriteJSON writes diags in the JSON format of go vet.
+func WriteJSON(w io.Writer, diags []driver.Diagnostic) error {
+	tree := make(map[string]map[string][]jsonDiagnostic)
+	for _, d := range diags {
+		jd := jsonDiagnostic{
+			Category:    d.Category,
+			Rule:        d.Rule,
+			Posn:        d.Posn.String(),
+			Message:     d.Message,
+			Annotations: d.Annotations,
+		}
+		for _, f := range d.Fixes {
+			jf := jsonSuggestedFix{
+				Message: f.Message,
+				Level:   f.Level.String(),
+				Edits:   []jsonTextEdit{},
+			}
+			for _, e := range f.Edits {
+				jf.Edits = append(jf.Edits, jsonTextEdit{
+					Filename: e.Filename,
+					Start:    e.Start,
+					End:      e.End,
+					New:      string(e.NewText),
+				})
+			}
+			jd.SuggestedFixes = append(jd.SuggestedFixes, jf)
+		}
+		m := tree[d.Package]
+		if m == nil {
+			m = make(map[string][]jsonDiagnostic)
+			tree[d.Package] = m
+		}
+		m[d.Analyzer] = append(m[d.Analyzer], jd)
+	}
+	b, err := json.MarshalIndent(tree, "", "\t")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "%s\n", b)
+	return err
+}
@@ -0,0 +1,257 @@
+// Copyright 2019 Axel Wagner
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package report
+
+import (
+	"bytes"
+	"encoding/json"
+	"go/token"
+	"io"
+	"io/ioutil"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/Merovius/go-tools/internal/driver"
+	"github.com/Merovius/go-tools/internal/fix"
+	"github.com/Merovius/go-tools/internal/posindex"
+	"golang.org/x/tools/go/analysis"
+)
+
+var diags = []driver.Diagnostic{
+	{
+		Analyzer: "redundantbranch",
+		Package:  "example.com/a",
+		Rule:     "GT1001",
+		Posn:     token.Position{Filename: "/src/a/a.go", Offset: 40, Line: 5, Column: 3},
+		Message:  "break does not affect control flow",
+		Fixes: []fix.Fix{{
+			Analyzer: "redundantbranch",
+			Message:  "remove break",
+			Level:    fix.Safe,
+			Edits:    []fix.Edit{{Filename: "/src/a/a.go", Start: 38, End: 46}},
+		}},
+		Annotations: map[string]string{"covered": "no"},
+	},
+	{
+		Analyzer: "redundantbranch",
+		Package:  "example.com/a",
+		Category: "goto",
+		Posn:     token.Position{Filename: "/src/a/b.go", Offset: 10, Line: 2, Column: 1},
+		Message:  "goto does not affect control flow",
+	},
+}
+
+func TestWriteJSON(t *testing.T) {
+	buf := new(bytes.Buffer)
+	if err := Write(buf, JSON, diags, &Options{}); err != nil {
+		t.Fatal(err)
+	}
+	var got interface{}
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	const want = `{
+		"example.com/a": {
+			"redundantbranch": [
+				{
+					"rule": "GT1001",
+					"posn": "/src/a/a.go:5:3",
+					"message": "break does not affect control flow",
+					"suggested_fixes": [{
+						"message": "remove break",
+						"level": "safe",
+						"edits": [{"filename": "/src/a/a.go", "start": 38, "end": 46, "new": ""}]
+					}],
+					"annotations": {"covered": "no"}
+				},
+				{
+					"category": "goto",
+					"posn": "/src/a/b.go:2:1",
+					"message": "goto does not affect control flow"
+				}
+			]
+		}
+	}`
+	var w interface{}
+	if err := json.Unmarshal([]byte(want), &w); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, w) {
+		t.Errorf("WriteJSON() =\n%s\nwant\n%s", buf, want)
+	}
+}
+
+func TestWriteSARIF(t *testing.T) {
+	buf := new(bytes.Buffer)
+	opts := &Options{
+		Analyzers: []*analysis.Analyzer{{Name: "redundantbranch", Doc: "short\n\nlong"}},
+		Root:      "/src",
+	}
+	if err := Write(buf, SARIF, diags, opts); err != nil {
+		t.Fatal(err)
+	}
+	var log sarifLog
+	if err := json.Unmarshal(buf.Bytes(), &log); err != nil {
+		t.Fatal(err)
+	}
+	if len(log.Runs) != 1 {
+		t.Fatalf("got %d runs, want 1", len(log.Runs))
+	}
+	run := log.Runs[0]
+	wantRules := []sarifRule{
+		{"GT1001", "redundantbranch", sarifMessage{"short"}, sarifMessage{"short\n\nlong"}, helpURI + "redundantbranch"},
+		// The second diagnostic has no rule ID.
+		{"redundantbranch", "redundantbranch/goto", sarifMessage{"short"}, sarifMessage{"short\n\nlong"}, helpURI + "redundantbranch"},
+	}
+	if !reflect.DeepEqual(run.Tool.Driver.Rules, wantRules) {
+		t.Errorf("rules = %+v, want %+v", run.Tool.Driver.Rules, wantRules)
+	}
+	if len(run.Results) != 2 {
+		t.Fatalf("got %d results, want 2", len(run.Results))
+	}
+	r := run.Results[0]
+	if r.RuleID != "GT1001" || r.RuleIndex != 0 {
+		t.Errorf("rule of result = %q (%d), want GT1001 (0)", r.RuleID, r.RuleIndex)
+	}
+	wantLoc := sarifPhysicalLoc{
+		ArtifactLocation: sarifArtifactLoc{URI: "a/a.go", URIBaseID: sarifRoot},
+		Region:           sarifRegion{StartLine: 5, StartColumn: 3},
+	}
+	if !reflect.DeepEqual(r.Locations[0].PhysicalLocation, wantLoc) {
+		t.Errorf("location = %+v, want %+v", r.Locations[0].PhysicalLocation, wantLoc)
+	}
+	wantFix := []sarifFix{{
+		Description: sarifMessage{"remove break"},
+		ArtifactChanges: []sarifArtifactChange{{
+			ArtifactLocation: sarifArtifactLoc{URI: "a/a.go", URIBaseID: sarifRoot},
+			Replacements:     []sarifReplacement{{DeletedRegion: sarifRegion{ByteOffset: 38, ByteLength: 8}}},
+		}},
+	}}
+	if !reflect.DeepEqual(r.Fixes, wantFix) {
+		t.Errorf("fixes = %+v, want %+v", r.Fixes, wantFix)
+	}
+	if !reflect.DeepEqual(r.Properties, map[string]string{"covered": "no"}) {
+		t.Errorf("properties = %v, want covered=no", r.Properties)
+	}
+	if r := run.Results[1]; r.RuleID != "redundantbranch" || r.RuleIndex != 1 {
+		t.Errorf("rule of result = %q (%d), want redundantbranch (1)", r.RuleID, r.RuleIndex)
+	}
+}
+
+func TestSARIFNotes(t *testing.T) {
+	buf := new(bytes.Buffer)
+	if err := Write(buf, SARIF, diags, &Options{Notes: []string{"stopped after 2 findings"}}); err != nil {
+		t.Fatal(err)
+	}
+	var log sarifLog
+	if err := json.Unmarshal(buf.Bytes(), &log); err != nil {
+		t.Fatal(err)
+	}
+	want := []sarifInvocation{{
+		ExecutionSuccessful:        true,
+		ToolExecutionNotifications: []sarifNotification{{"warning", sarifMessage{"stopped after 2 findings"}}},
+	}}
+	if got := log.Runs[0].Invocations; !reflect.DeepEqual(got, want) {
+		t.Errorf("invocations = %+v, want %+v", got, want)
+	}
+}
+
+func TestSARIFSnippets(t *testing.T) {
+	ix := posindex.New()
+	ix.Add(posindex.NewFile("/src/a/a.go", []byte("package a\n\nfunc f(x int) {\n\tfor !ok {\n\t\tbreak\n\t}\n}\n")))
+	buf := new(bytes.Buffer)
+	if err := WriteSARIF(buf, diags, &Options{Index: ix}); err != nil {
+		t.Fatal(err)
+	}
+	var got sarifLog
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	res := got.Runs[0].Results
+	if s := res[0].Locations[0].PhysicalLocation.Region.Snippet; s == nil || s.Text != "\t\tbreak" {
+		t.Errorf("snippet of a.go = %v, want %q", s, "\t\tbreak")
+	}
+	if s := res[1].Locations[0].PhysicalLocation.Region.Snippet; s != nil {
+		t.Errorf("snippet of unreadable b.go = %v, want none", s)
+	}
+	want := sarifRegion{StartLine: 5, StartColumn: 1, EndLine: 6, EndColumn: 1, ByteOffset: 38, ByteLength: 8}
+	if r := res[0].Fixes[0].ArtifactChanges[0].Replacements[0].DeletedRegion; r != want {
+		t.Errorf("deleted region = %+v, want %+v", r, want)
+	}
+}
+
+func TestFormatSet(t *testing.T) {
+	var f Format
+	if err := f.Set("json"); err != nil || f != JSON {
+		t.Errorf("Set(json) = %v, format %q", err, f)
+	}
+	if err := f.Set("xml"); err == nil {
+		t.Error("Set(xml) succeeded, want error")
+	}
+}
+
+func TestMerge(t *testing.T) {
+	for _, f := range []Format{JSON, SARIF} {
+		write := func(diags []driver.Diagnostic) []byte {
+			buf := new(bytes.Buffer)
+			if err := Write(buf, f, diags, &Options{Root: "/src"}); err != nil {
+				t.Fatal(err)
+			}
+			return buf.Bytes()
+		}
+		// The shards overlap in the second diagnostic.
+		shards := [][]byte{write(diags[:1]), write(diags[1:]), write(diags[1:])}
+		buf := new(bytes.Buffer)
+		if err := Merge(buf, bytes.NewReader(shards[0]), bytes.NewReader(shards[1]), bytes.NewReader(shards[2])); err != nil {
+			t.Errorf("Merge(%s): %v", f, err)
+			continue
+		}
+		var got, want interface{}
+		if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+			t.Fatal(err)
+		}
+		if err := json.Unmarshal(write(diags), &want); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("Merge(%s) =\n%s\nwant\n%s", f, buf, write(diags))
+		}
+	}
+}
+
+func TestMergeErrors(t *testing.T) {
+	js := new(bytes.Buffer)
+	sarif := new(bytes.Buffer)
+	if err := Write(js, JSON, diags, &Options{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := Write(sarif, SARIF, diags, &Options{}); err != nil {
+		t.Fatal(err)
+	}
+	for name, reports := range map[string][]string{
+		"none":  nil,
+		"text":  {"a.go:1:1: message\n"},
+		"mixed": {js.String(), sarif.String()},
+	} {
+		var rs []io.Reader
+		for _, r := range reports {
+			rs = append(rs, strings.NewReader(r))
+		}
+		if err := Merge(ioutil.Discard, rs...); err == nil {
+			t.Errorf("Merge(%s) succeeded, want error", name)
+		}
+	}
+}
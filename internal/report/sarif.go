@@ -0,0 +1,325 @@
+// Copyright 2019 Axel Wagner
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package report
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/Merovius/go-tools/internal/driver"
+	"github.com/Merovius/go-tools/internal/posindex"
+	"github.com/Merovius/go-tools/internal/rules"
+)
+
+// The SARIF format is described in
+// https://docs.oasis-open.org/sarif/sarif/v2.1.0/sarif-v2.1.0.html. Only
+// the parts needed by code scanning services are written.
+
+const (
+	sarifSchema  = "https://json.schemastore.org/sarif-2.1.0.json"
+	sarifVersion = "2.1.0"
+	sarifRoot    = "%SRCROOT%"
+	helpURI      = "https://github.com/Merovius/go-tools#"
+)
+
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool               sarifTool                   `json:"tool"`
+	Invocations        []sarifInvocation           `json:"invocations,omitempty"`
+	OriginalURIBaseIDs map[string]sarifArtifactLoc `json:"originalUriBaseIds,omitempty"`
+	Results            []sarifResult               `json:"results"`
+}
+
+// A sarifInvocation is only written to state that the analysis is
+// incomplete, with one notification per note.
+type sarifInvocation struct {
+	ExecutionSuccessful        bool                `json:"executionSuccessful"`
+	ToolExecutionNotifications []sarifNotification `json:"toolExecutionNotifications"`
+}
+
+type sarifNotification struct {
+	Level   string       `json:"level"`
+	Message sarifMessage `json:"message"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID               string       `json:"id"`
+	Name             string       `json:"name"`
+	ShortDescription sarifMessage `json:"shortDescription"`
+	FullDescription  sarifMessage `json:"fullDescription"`
+	HelpURI          string       `json:"helpUri"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleID    string          `json:"ruleId"`
+	RuleIndex int             `json:"ruleIndex"`
+	Level     string          `json:"level"`
+	Message   sarifMessage    `json:"message"`
+	Locations []sarifLocation `json:"locations"`
+	Fixes     []sarifFix      `json:"fixes,omitempty"`
+
+	// Properties holds the annotations of the diagnostic.
+	Properties map[string]string `json:"properties,omitempty"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLoc `json:"physicalLocation"`
+}
+
+type sarifPhysicalLoc struct {
+	ArtifactLocation sarifArtifactLoc `json:"artifactLocation"`
+	Region           sarifRegion      `json:"region"`
+}
+
+type sarifArtifactLoc struct {
+	URI       string `json:"uri"`
+	URIBaseID string `json:"uriBaseId,omitempty"`
+}
+
+type sarifRegion struct {
+	StartLine   int           `json:"startLine,omitempty"`
+	StartColumn int           `json:"startColumn,omitempty"`
+	EndLine     int           `json:"endLine,omitempty"`
+	EndColumn   int           `json:"endColumn,omitempty"`
+	ByteOffset  int           `json:"byteOffset,omitempty"`
+	ByteLength  int           `json:"byteLength,omitempty"`
+	Snippet     *sarifMessage `json:"snippet,omitempty"`
+}
+
+type sarifFix struct {
+	Description     sarifMessage          `json:"description"`
+	ArtifactChanges []sarifArtifactChange `json:"artifactChanges"`
+}
+
+type sarifArtifactChange struct {
+	ArtifactLocation sarifArtifactLoc   `json:"artifactLocation"`
+	Replacements     []sarifReplacement `json:"replacements"`
+}
+
+type sarifReplacement struct {
+	DeletedRegion   sarifRegion   `json:"deletedRegion"`
+	InsertedContent *sarifMessage `json:"insertedContent,omitempty"`
+}
+
+// WriteSARIF writes diags as a SARIF log. Every rule of the analyzers in
+// opts is described. Files below opts.Root are given relative to it.
+// Regions include the flagged source lines, if the files can be read.
+func WriteSARIF(w io.Writer, diags []driver.Diagnostic, opts *Options) error {
+	var (
+		rs    []sarifRule
+		index = make(map[string]int)
+		ix    = opts.Index
+	)
+	if ix == nil {
+		ix = posindex.New()
+	}
+	addRule := func(id, name, doc string) {
+		if _, ok := index[id]; ok {
+			return
+		}
+		index[id] = len(rs)
+		short := doc
+		if i := strings.IndexByte(doc, '\n'); i >= 0 {
+			short = doc[:i]
+		}
+		rs = append(rs, sarifRule{
+			ID:               id,
+			Name:             name,
+			ShortDescription: sarifMessage{short},
+			FullDescription:  sarifMessage{doc},
+			HelpURI:          helpURI + strings.Split(name, "/")[0],
+		})
+	}
+	docs := make(map[string]string)
+	for _, a := range opts.Analyzers {
+		docs[a.Name] = a.Doc
+		n := len(rs)
+		for _, r := range rules.All() {
+			if r.Analyzer == a.Name {
+				addRule(r.ID, ruleName(r.Analyzer, r.Category), a.Doc)
+			}
+		}
+		if len(rs) == n {
+			addRule(a.Name, a.Name, a.Doc)
+		}
+	}
+
+	run := sarifRun{
+		Tool: sarifTool{sarifDriver{
+			Name:           "gotools",
+			InformationURI: "https://github.com/Merovius/go-tools",
+		}},
+		Results: []sarifResult{},
+	}
+	if len(opts.Notes) > 0 {
+		// The analysis ran successfully, with bounds.
+		inv := sarifInvocation{ExecutionSuccessful: true}
+		for _, n := range opts.Notes {
+			inv.ToolExecutionNotifications = append(inv.ToolExecutionNotifications, sarifNotification{"warning", sarifMessage{n}})
+		}
+		run.Invocations = []sarifInvocation{inv}
+	}
+	if opts.Root != "" {
+		run.OriginalURIBaseIDs = map[string]sarifArtifactLoc{
+			sarifRoot: {URI: fileURI(opts.Root) + "/"},
+		}
+	}
+	for _, d := range diags {
+		id := d.Rule
+		if id == "" {
+			id = d.Analyzer
+		}
+		addRule(id, ruleName(d.Analyzer, d.Category), docs[d.Analyzer])
+		region := sarifRegion{
+			StartLine:   d.Posn.Line,
+			StartColumn: d.Posn.Column,
+		}
+		if d.End.IsValid() {
+			region.EndLine, region.EndColumn = d.End.Line, d.End.Column
+		}
+		region.Snippet = snippet(ix.File(d.Posn.Filename), region.StartLine, region.EndLine)
+		res := sarifResult{
+			RuleID:    id,
+			RuleIndex: index[id],
+			Level:     sarifLevel(d.Severity),
+			Message:   sarifMessage{d.Message},
+			Locations: []sarifLocation{{sarifPhysicalLoc{
+				ArtifactLocation: artifactLoc(d.Posn.Filename, opts.Root),
+				Region:           region,
+			}}},
+			Properties: d.Annotations,
+		}
+		for _, f := range d.Fixes {
+			sf := sarifFix{Description: sarifMessage{f.Message}}
+			byFile := make(map[string]int)
+			for _, e := range f.Edits {
+				i, ok := byFile[e.Filename]
+				if !ok {
+					i = len(sf.ArtifactChanges)
+					byFile[e.Filename] = i
+					sf.ArtifactChanges = append(sf.ArtifactChanges, sarifArtifactChange{
+						ArtifactLocation: artifactLoc(e.Filename, opts.Root),
+					})
+				}
+				r := sarifReplacement{
+					DeletedRegion: sarifRegion{ByteOffset: e.Start, ByteLength: e.End - e.Start},
+				}
+				// Not all consumers support byte offsets, so give lines
+				// and columns as well.
+				if f := ix.File(e.Filename); f != nil {
+					start, end := f.Position(e.Start), f.Position(e.End)
+					if start.IsValid() && end.IsValid() {
+						r.DeletedRegion.StartLine, r.DeletedRegion.StartColumn = start.Line, start.Column
+						r.DeletedRegion.EndLine, r.DeletedRegion.EndColumn = end.Line, end.Column
+					}
+				}
+				if len(e.NewText) > 0 {
+					r.InsertedContent = &sarifMessage{string(e.NewText)}
+				}
+				sf.ArtifactChanges[i].Replacements = append(sf.ArtifactChanges[i].Replacements, r)
+			}
+			res.Fixes = append(res.Fixes, sf)
+		}
+		run.Results = append(run.Results, res)
+	}
+	run.Tool.Driver.Rules = rs
+
+	b, err := json.MarshalIndent(sarifLog{
+		Schema:  sarifSchema,
+		Version: sarifVersion,
+		Runs:    []sarifRun{run},
+	}, "", "\t")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "%s\n", b)
+	return err
+}
+
+// sarifLevel returns the SARIF level of results of severity s.
+func sarifLevel(s rules.Severity) string {
+	switch {
+	case s >= rules.Error:
+		return "error"
+	case s <= rules.Info:
+		return "note"
+	}
+	return "warning"
+}
+
+func ruleName(analyzer, category string) string {
+	if category == "" {
+		return analyzer
+	}
+	return analyzer + "/" + category
+}
+
+// artifactLoc returns the location of file, relative to root if it is
+// below it.
+func artifactLoc(file, root string) sarifArtifactLoc {
+	if root != "" {
+		if rel, err := filepath.Rel(root, file); err == nil && !strings.HasPrefix(rel, "..") {
+			return sarifArtifactLoc{URI: filepath.ToSlash(rel), URIBaseID: sarifRoot}
+		}
+	}
+	return sarifArtifactLoc{URI: fileURI(file)}
+}
+
+func fileURI(path string) string {
+	path = filepath.ToSlash(path)
+	if !strings.HasPrefix(path, "/") {
+		// Windows drive letters.
+		path = "/" + path
+	}
+	return "file://" + path
+}
+
+// snippet returns the lines start to end (or only start, if end is zero) of
+// f, or nil if they can't be read.
+func snippet(f *posindex.File, start, end int) *sarifMessage {
+	if end < start {
+		end = start
+	}
+	if f == nil || start < 1 || end > f.LineCount() {
+		return nil
+	}
+	var lines []string
+	for n := start; n <= end; n++ {
+		lines = append(lines, string(f.Line(n)))
+	}
+	return &sarifMessage{strings.Join(lines, "\n")}
+}
@@ -0,0 +1,319 @@
+// Copyright 2019 Axel Wagner
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package rules assigns stable IDs to the checks of gotools. A rule is an
+// analyzer, or a category of the diagnostics of an analyzer. IDs are never
+// reused or changed, so findings stay addressable if analyzers are renamed,
+// merged or split: a renamed analyzer keeps its IDs, and a split analyzer
+// gives its categories the IDs of the old rules.
+package rules
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// A Rule is a check with a stable ID.
+type Rule struct {
+	ID       string
+	Analyzer string
+
+	// Category is the category of the diagnostics of the analyzer the rule
+	// applies to. If empty, it applies to all diagnostics of the analyzer
+	// without a more specific rule.
+	Category string
+
+	// Severity of the findings of the rule. The zero value is Warning.
+	Severity Severity
+
+	// Tags classify the rule, see the Tag constants.
+	Tags []string
+}
+
+// Tags of rules.
+const (
+	// Correctness rules find bugs.
+	Correctness = "correctness"
+	// Performance rules find inefficient code. With a profile, their
+	// findings can be restricted to hot code.
+	Performance = "performance"
+	// Security rules find vulnerabilities.
+	Security = "security"
+	// Style rules find code which is correct, but could be clearer.
+	Style = "style"
+)
+
+// HasTag returns whether r is tagged with tag.
+func (r Rule) HasTag(tag string) bool {
+	for _, t := range r.Tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// A Severity is the importance of a finding.
+type Severity int
+
+// The severities, from least to most important.
+const (
+	Info Severity = iota - 1
+	Warning
+	Error
+)
+
+var severityNames = map[Severity]string{
+	Info:    "info",
+	Warning: "warning",
+	Error:   "error",
+}
+
+func (s Severity) String() string {
+	if n, ok := severityNames[s]; ok {
+		return n
+	}
+	return fmt.Sprintf("Severity(%d)", int(s))
+}
+
+// ParseSeverity parses the name of a severity.
+func ParseSeverity(name string) (Severity, error) {
+	for s, n := range severityNames {
+		if n == name {
+			return s, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown severity %q (want info, warning or error)", name)
+}
+
+// table lists all rules. New rules are appended with the next free ID.
+// Entries must not be removed; to retire a rule, keep its entry, so the ID
+// isn't reused.
+var table = []Rule{
+	{ID: "GT1001", Analyzer: "redundantbranch", Tags: []string{Style}},
+	{ID: "GT1002", Analyzer: "assertmisuse", Tags: []string{Style}},
+	{ID: "GT1003", Analyzer: "assertmisuse", Category: "goroutine", Severity: Error, Tags: []string{Correctness}},
+	{ID: "GT1004", Analyzer: "assertmisuse", Category: "errderef", Tags: []string{Correctness}},
+	{ID: "GT1005", Analyzer: "assertmisuse", Category: "float", Tags: []string{Correctness}},
+	{ID: "GT1006", Analyzer: "stalemock", Tags: []string{Correctness}},
+	{ID: "GT1007", Analyzer: "protomisuse", Tags: []string{Correctness}},
+	{ID: "GT1008", Analyzer: "protomisuse", Category: "mapkey", Tags: []string{Correctness}},
+	{ID: "GT1009", Analyzer: "protomisuse", Category: "copy", Tags: []string{Correctness}},
+	{ID: "GT1010", Analyzer: "protomisuse", Category: "getter", Severity: Error, Tags: []string{Correctness}},
+	{ID: "GT1011", Analyzer: "grpchygiene", Tags: []string{Correctness}},
+	{ID: "GT1012", Analyzer: "grpchygiene", Category: "context", Tags: []string{Correctness}},
+	{ID: "GT1013", Analyzer: "grpchygiene", Category: "close", Tags: []string{Correctness}},
+	{ID: "GT1014", Analyzer: "httphandler", Tags: []string{Correctness}},
+	{ID: "GT1015", Analyzer: "httphandler", Category: "writeheader", Tags: []string{Correctness}},
+	{ID: "GT1016", Analyzer: "httphandler", Category: "body", Tags: []string{Security}},
+	{ID: "GT1017", Analyzer: "httphandler", Category: "store", Tags: []string{Correctness}},
+	{ID: "GT1018", Analyzer: "rwwrapper", Tags: []string{Correctness}},
+	{ID: "GT1019", Analyzer: "encodeiface", Tags: []string{Correctness}},
+	{ID: "GT1020", Analyzer: "encodeiface", Category: "json", Tags: []string{Correctness}},
+	{ID: "GT1021", Analyzer: "zipslip", Severity: Error, Tags: []string{Security}},
+	{ID: "GT1022", Analyzer: "zipslip", Category: "copy", Tags: []string{Security}},
+	{ID: "GT1023", Analyzer: "fileperm", Tags: []string{Security}},
+	{ID: "GT1024", Analyzer: "fileperm", Category: "decimal", Severity: Error, Tags: []string{Correctness}},
+	{ID: "GT1025", Analyzer: "envaccess", Tags: []string{Style}},
+	{ID: "GT1026", Analyzer: "envaccess", Category: "lookup", Tags: []string{Correctness}},
+	{ID: "GT1027", Analyzer: "envaccess", Category: "loop", Severity: Info, Tags: []string{Performance}},
+	{ID: "GT1028", Analyzer: "platformcall", Severity: Error, Tags: []string{Correctness}},
+	{ID: "GT1029", Analyzer: "platformcall", Category: "windows", Tags: []string{Correctness}},
+	{ID: "GT1030", Analyzer: "divzero", Tags: []string{Correctness}},
+	{ID: "GT1031", Analyzer: "indexrange", Severity: Error, Tags: []string{Correctness}},
+	{ID: "GT1032", Analyzer: "indexrange", Category: "len", Severity: Error, Tags: []string{Correctness}},
+	{ID: "GT1033", Analyzer: "indexrange", Category: "empty", Tags: []string{Correctness}},
+	{ID: "GT1034", Analyzer: "mapaccess", Tags: []string{Performance}},
+	{ID: "GT1035", Analyzer: "mapaccess", Category: "ignoredok", Tags: []string{Correctness}},
+	{ID: "GT1036", Analyzer: "constdecl", Tags: []string{Correctness}},
+	{ID: "GT1037", Analyzer: "constdecl", Category: "gap", Tags: []string{Style}},
+	{ID: "GT1038", Analyzer: "constdecl", Category: "untyped", Tags: []string{Style}},
+	{ID: "GT1039", Analyzer: "constdecl", Category: "stringer", Tags: []string{Correctness}},
+	{ID: "GT1040", Analyzer: "stringerdrift", Tags: []string{Correctness}},
+	{ID: "GT1041", Analyzer: "stringerdrift", Category: "missing", Severity: Error, Tags: []string{Correctness}},
+	{ID: "GT1042", Analyzer: "errortype", Tags: []string{Correctness}},
+	{ID: "GT1043", Analyzer: "errortype", Category: "receiver", Tags: []string{Correctness}},
+	{ID: "GT1044", Analyzer: "errortype", Category: "unwrap", Severity: Error, Tags: []string{Correctness}},
+	{ID: "GT1045", Analyzer: "cgoaudit", Severity: Error, Tags: []string{Correctness}},
+	{ID: "GT1046", Analyzer: "cgoaudit", Category: "free", Tags: []string{Correctness}},
+	{ID: "GT1047", Analyzer: "cgoaudit", Category: "flags", Tags: []string{Style}},
+	{ID: "GT1048", Analyzer: "finalizer", Tags: []string{Correctness}},
+	{ID: "GT1049", Analyzer: "finalizer", Category: "keepalive", Tags: []string{Correctness}},
+	{ID: "GT1050", Analyzer: "timeformat", Tags: []string{Correctness}},
+	{ID: "GT1051", Analyzer: "timeformat", Category: "tokens", Tags: []string{Correctness}},
+	{ID: "GT1052", Analyzer: "timeformat", Category: "location", Tags: []string{Style}},
+	{ID: "GT1053", Analyzer: "timeformat", Category: "local", Severity: Info, Tags: []string{Style}},
+	{ID: "GT1054", Analyzer: "durationmath", Tags: []string{Correctness}},
+	{ID: "GT1055", Analyzer: "durationmath", Category: "product", Tags: []string{Correctness}},
+	{ID: "GT1056", Analyzer: "durationmath", Category: "sleep", Tags: []string{Correctness}},
+	{ID: "GT1057", Analyzer: "moneyfloat", Tags: []string{Correctness}},
+	{ID: "GT1058", Analyzer: "moneyfloat", Category: "field", Tags: []string{Style}},
+	{ID: "GT1059", Analyzer: "casefold", Tags: []string{Performance, Correctness}},
+	{ID: "GT1060", Analyzer: "casefold", Category: "normalization", Severity: Info, Tags: []string{Correctness}},
+	{ID: "GT1061", Analyzer: "hotloop", Severity: Info, Tags: []string{Performance}},
+	{ID: "GT1062", Analyzer: "hotloop", Category: "alloc", Severity: Info, Tags: []string{Performance}},
+	{ID: "GT1063", Analyzer: "loopconv", Severity: Info, Tags: []string{Performance}},
+	{ID: "GT1064", Analyzer: "loopconv", Category: "strconv", Severity: Info, Tags: []string{Performance}},
+	{ID: "GT1065", Analyzer: "deferunlock", Severity: Info, Tags: []string{Performance}},
+	{ID: "GT1066", Analyzer: "heapescape", Severity: Info, Tags: []string{Performance}},
+	{ID: "GT1067", Analyzer: "heapescape", Category: "boxing", Severity: Info, Tags: []string{Performance}},
+	{ID: "GT1068", Analyzer: "heapescape", Category: "closure", Severity: Info, Tags: []string{Performance}},
+	{ID: "GT1069", Analyzer: "panicflow", Tags: []string{Style}},
+	{ID: "GT1070", Analyzer: "doccomment", Tags: []string{Style}},
+	{ID: "GT1071", Analyzer: "doccomment", Category: "deprecated", Tags: []string{Style}},
+	{ID: "GT1072", Analyzer: "doccomment", Category: "params", Tags: []string{Style}},
+	{ID: "GT1073", Analyzer: "pkgname", Tags: []string{Style}},
+	{ID: "GT1074", Analyzer: "pkgname", Category: "directory", Tags: []string{Style}},
+	{ID: "GT1075", Analyzer: "pkgname", Category: "stutter", Tags: []string{Style}},
+	{ID: "GT1076", Analyzer: "pkgname", Category: "multiple", Tags: []string{Style}},
+	{ID: "GT1077", Analyzer: "mainpkg", Tags: []string{Style}},
+	{ID: "GT1078", Analyzer: "mainpkg", Category: "flags", Tags: []string{Style}},
+	{ID: "GT1079", Analyzer: "mainpkg", Category: "args", Tags: []string{Style}},
+	{ID: "GT1080", Analyzer: "mainpkg", Category: "signal", Tags: []string{Correctness}},
+	{ID: "GT1081", Analyzer: "gorecover", Tags: []string{Correctness}},
+	{ID: "GT1082", Analyzer: "chandir", Tags: []string{Style}},
+	{ID: "GT1083", Analyzer: "ignoredresult", Tags: []string{Style}},
+	{ID: "GT1084", Analyzer: "constparam", Tags: []string{Style}},
+	{ID: "GT1085", Analyzer: "clone", Severity: Info, Tags: []string{Style}},
+	{ID: "GT1086", Analyzer: "todo", Severity: Info, Tags: []string{Style}},
+	{ID: "GT1087", Analyzer: "todo", Category: "age", Severity: Info, Tags: []string{Style}},
+	{ID: "GT1088", Analyzer: "licenseheader", Tags: []string{Style}},
+	{ID: "GT1089", Analyzer: "licenseheader", Category: "year", Severity: Info, Tags: []string{Style}},
+	{ID: "GT1090", Analyzer: "sprawl", Severity: Info, Tags: []string{Style}},
+	{ID: "GT1091", Analyzer: "sprawl", Category: "exported", Severity: Info, Tags: []string{Style}},
+	{ID: "GT1092", Analyzer: "internalimport", Tags: []string{Correctness}},
+	{ID: "GT1093", Analyzer: "internalimport", Category: "experimental", Severity: Info, Tags: []string{Style}},
+	{ID: "GT1094", Analyzer: "modhygiene", Tags: []string{Correctness}},
+	{ID: "GT1095", Analyzer: "modhygiene", Category: "retracted", Tags: []string{Correctness}},
+	{ID: "GT1096", Analyzer: "modhygiene", Category: "incompatible", Severity: Info, Tags: []string{Style}},
+	{ID: "GT1097", Analyzer: "buildtags", Tags: []string{Correctness}},
+	{ID: "GT1098", Analyzer: "buildtags", Category: "tags", Severity: Info, Tags: []string{Style}},
+	{ID: "GT1099", Analyzer: "cleanup", Tags: []string{Correctness}},
+	{ID: "GT1100", Analyzer: "cleanup", Category: "order", Tags: []string{Correctness}},
+	{ID: "GT1101", Analyzer: "cleanup", Category: "clobber", Tags: []string{Correctness}},
+	{ID: "GT1102", Analyzer: "unboundedread", Tags: []string{Security}},
+	{ID: "GT1103", Analyzer: "unboundedread", Category: "path", Tags: []string{Security}},
+	{ID: "GT1104", Analyzer: "selectmisuse", Severity: Info, Tags: []string{Style}},
+	{ID: "GT1105", Analyzer: "selectmisuse", Category: "busy", Tags: []string{Performance}},
+	{ID: "GT1106", Analyzer: "selectmisuse", Category: "timer", Tags: []string{Performance}},
+	{ID: "GT1107", Analyzer: "ctxloop", Tags: []string{Correctness}},
+	{ID: "GT1108", Analyzer: "since", Severity: Info, Tags: []string{Style}},
+	{ID: "GT1109", Analyzer: "since", Category: "format", Tags: []string{Style}},
+	{ID: "GT1110", Analyzer: "multierr", Tags: []string{Correctness}},
+	{ID: "GT1111", Analyzer: "keyedfields", Tags: []string{Style}},
+	{ID: "GT1112", Analyzer: "appendexpand", Tags: []string{Correctness}},
+	{ID: "GT1113", Analyzer: "importshadow", Tags: []string{Style}},
+	{ID: "GT1114", Analyzer: "typeswitchdefault", Severity: Info, Tags: []string{Correctness}},
+	{ID: "GT1115", Analyzer: "elemcopy", Tags: []string{Correctness}},
+	{ID: "GT1116", Analyzer: "errgroup", Tags: []string{Correctness}},
+	{ID: "GT1117", Analyzer: "singleflight", Tags: []string{Correctness}},
+	{ID: "GT1118", Analyzer: "prommetrics", Tags: []string{Correctness}},
+	{ID: "GT1119", Analyzer: "prommetrics", Category: "cardinality", Tags: []string{Performance, Security}},
+	{ID: "GT1120", Analyzer: "prommetrics", Category: "counter", Severity: Error, Tags: []string{Correctness}},
+	{ID: "GT1121", Analyzer: "otelspan", Tags: []string{Correctness}},
+	{ID: "GT1122", Analyzer: "patterns", Tags: []string{Style}},
+	{ID: "GT1123", Analyzer: "errname", Severity: Info, Tags: []string{Style}},
+	{ID: "GT1124", Analyzer: "ctxkey", Tags: []string{Correctness}},
+	{ID: "GT1125", Analyzer: "httproute", Tags: []string{Correctness}},
+	{ID: "GT1126", Analyzer: "sqltx", Tags: []string{Correctness}},
+	{ID: "GT1127", Analyzer: "retryloop", Tags: []string{Correctness, Performance}},
+	{ID: "GT1128", Analyzer: "retryloop", Category: "idempotency", Severity: Info, Tags: []string{Correctness}},
+	{ID: "GT1129", Analyzer: "jsonnumber", Tags: []string{Correctness}},
+	{ID: "GT1130", Analyzer: "strictdecode", Tags: []string{Correctness}},
+	{ID: "GT1131", Analyzer: "apiversion", Tags: []string{Correctness}},
+	{ID: "GT1132", Analyzer: "constraints", Tags: []string{Correctness}},
+	{ID: "GT1133", Analyzer: "genericbloat", Severity: Info, Tags: []string{Performance}},
+}
+
+var idRegexp = regexp.MustCompile(`^GT[0-9]{4}$`)
+
+// IsID returns whether s has the form of a rule ID.
+func IsID(s string) bool {
+	return idRegexp.MatchString(s)
+}
+
+// All returns all rules, ordered by ID.
+func All() []Rule {
+	return append([]Rule(nil), table...)
+}
+
+// ByID returns the rule with the given ID.
+func ByID(id string) (Rule, bool) {
+	for _, r := range table {
+		if r.ID == id {
+			return r, true
+		}
+	}
+	return Rule{}, false
+}
+
+// Lookup returns the rule of a diagnostic of analyzer with the given
+// category.
+func Lookup(analyzer, category string) (Rule, bool) {
+	var (
+		fallback Rule
+		found    bool
+	)
+	for _, r := range table {
+		if r.Analyzer != analyzer {
+			continue
+		}
+		if r.Category == category {
+			return r, true
+		}
+		if r.Category == "" {
+			fallback, found = r, true
+		}
+	}
+	return fallback, found
+}
+
+// ID returns the ID of the rule of a diagnostic of analyzer with the given
+// category, or "" if there is none.
+func ID(analyzer, category string) string {
+	r, _ := Lookup(analyzer, category)
+	return r.ID
+}
+
+// check verifies the consistency of the table.
+func check() error {
+	seen := make(map[string]bool)
+	type key struct{ analyzer, category string }
+	keys := make(map[key]bool)
+	for i, r := range table {
+		if !IsID(r.ID) {
+			return fmt.Errorf("invalid rule ID %q", r.ID)
+		}
+		if seen[r.ID] {
+			return fmt.Errorf("duplicate rule ID %s", r.ID)
+		}
+		if i > 0 && r.ID < table[i-1].ID {
+			return fmt.Errorf("rule %s out of order", r.ID)
+		}
+		seen[r.ID] = true
+		k := key{r.Analyzer, r.Category}
+		if keys[k] {
+			return fmt.Errorf("rule %s: duplicate rule for %s/%q", r.ID, r.Analyzer, r.Category)
+		}
+		keys[k] = true
+		for _, t := range r.Tags {
+			switch t {
+			case Correctness, Performance, Security, Style:
+			default:
+				return fmt.Errorf("rule %s: unknown tag %q", r.ID, t)
+			}
+		}
+	}
+	return nil
+}
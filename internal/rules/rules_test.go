@@ -0,0 +1,75 @@
+// Copyright 2019 Axel Wagner
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rules
+
+import (
+	"testing"
+
+	"github.com/Merovius/go-tools/internal/all"
+)
+
+func TestTable(t *testing.T) {
+	if err := check(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAnalyzersHaveRules(t *testing.T) {
+	for _, a := range all.WithOptional() {
+		if ID(a.Name, "") == "" {
+			t.Errorf("analyzer %s has no rule ID", a.Name)
+		}
+	}
+}
+
+func TestLookup(t *testing.T) {
+	defer func(old []Rule) { table = old }(table)
+	table = []Rule{
+		{ID: "GT0001", Analyzer: "a"},
+		{ID: "GT0002", Analyzer: "a", Category: "x"},
+		{ID: "GT0003", Analyzer: "b", Category: "y"},
+	}
+	tcs := []struct {
+		analyzer, category string
+		want               string
+	}{
+		{"a", "", "GT0001"},
+		{"a", "x", "GT0002"},
+		{"a", "z", "GT0001"},
+		{"b", "y", "GT0003"},
+		{"b", "", ""},
+		{"c", "", ""},
+	}
+	for _, tc := range tcs {
+		if got := ID(tc.analyzer, tc.category); got != tc.want {
+			t.Errorf("ID(%q, %q) = %q, want %q", tc.analyzer, tc.category, got, tc.want)
+		}
+	}
+	if r, ok := ByID("GT0002"); !ok || r.Category != "x" {
+		t.Errorf("ByID(GT0002) = %+v, %v", r, ok)
+	}
+}
+
+func TestParseSeverity(t *testing.T) {
+	for _, s := range []Severity{Info, Warning, Error} {
+		got, err := ParseSeverity(s.String())
+		if err != nil || got != s {
+			t.Errorf("ParseSeverity(%q) = %v, %v, want %v", s.String(), got, err, s)
+		}
+	}
+	if _, err := ParseSeverity("fatal"); err == nil {
+		t.Error("ParseSeverity(fatal) succeeded, want error")
+	}
+}
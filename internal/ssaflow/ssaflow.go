@@ -0,0 +1,251 @@
+// Copyright 2019 Axel Wagner
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package ssaflow provides simple flow-sensitive facts about SSA values, for
+// analyzers which need to know which conditions hold at an instruction.
+package ssaflow
+
+import (
+	"go/constant"
+	"go/token"
+	"go/types"
+
+	"golang.org/x/tools/go/ssa"
+)
+
+// Unconvert returns v without any conversions applied to it.
+func Unconvert(v ssa.Value) ssa.Value {
+	for {
+		switch c := v.(type) {
+		case *ssa.Convert:
+			v = c.X
+		case *ssa.ChangeType:
+			v = c.X
+		default:
+			return v
+		}
+	}
+}
+
+// BuiltinCall returns the call of one of the named builtin functions
+// computing v, or nil.
+func BuiltinCall(v ssa.Value, names ...string) *ssa.Call {
+	call, ok := Unconvert(v).(*ssa.Call)
+	if !ok {
+		return nil
+	}
+	b, ok := call.Call.Value.(*ssa.Builtin)
+	if !ok {
+		return nil
+	}
+	for _, name := range names {
+		if b.Name() == name {
+			return call
+		}
+	}
+	return nil
+}
+
+// Same returns whether a and b are the same value, up to conversions, the
+// results of calling the same builtin function, like len, with the same
+// argument, or loads of the same field of the same struct, which the
+// function never stores to.
+func Same(a, b ssa.Value) bool {
+	a, b = Unconvert(a), Unconvert(b)
+	if a == b || sameField(a, b) {
+		return true
+	}
+	ca, cb := BuiltinCall(a, "len", "cap"), BuiltinCall(b, "len", "cap")
+	if ca == nil || cb == nil {
+		return false
+	}
+	return ca.Call.Value.(*ssa.Builtin).Name() == cb.Call.Value.(*ssa.Builtin).Name() &&
+		len(ca.Call.Args) == 1 && len(cb.Call.Args) == 1 && Same(ca.Call.Args[0], cb.Call.Args[0])
+}
+
+// sameField returns whether a and b are the same field of the same struct
+// value, or loads of the same field through the same pointer, which their
+// function never stores to. Every load is a value of its own, even if
+// nothing changed in between.
+func sameField(a, b ssa.Value) bool {
+	if fa, ok := a.(*ssa.Field); ok {
+		fb, ok := b.(*ssa.Field)
+		return ok && fa.Field == fb.Field && Same(fa.X, fb.X)
+	}
+	la, ok := a.(*ssa.UnOp)
+	if !ok || la.Op != token.MUL {
+		return false
+	}
+	lb, ok := b.(*ssa.UnOp)
+	if !ok || lb.Op != token.MUL || la.Parent() != lb.Parent() || !sameAddr(la.X, lb.X) {
+		return false
+	}
+	fa, ok := la.X.(*ssa.FieldAddr)
+	if !ok {
+		return false
+	}
+	for _, blk := range la.Parent().Blocks {
+		for _, instr := range blk.Instrs {
+			st, ok := instr.(*ssa.Store)
+			if !ok {
+				continue
+			}
+			if sameAddr(st.Addr, fa.X) {
+				return false
+			}
+			if f, ok := st.Addr.(*ssa.FieldAddr); ok && f.Field == fa.Field && types.Identical(f.X.Type(), fa.X.Type()) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// sameAddr returns whether a and b are the same address, or the addresses
+// of the same field of the same address.
+func sameAddr(a, b ssa.Value) bool {
+	if a == b {
+		return true
+	}
+	fa, ok := a.(*ssa.FieldAddr)
+	if !ok {
+		return false
+	}
+	fb, ok := b.(*ssa.FieldAddr)
+	return ok && fa.Field == fb.Field && sameAddr(fa.X, fb.X)
+}
+
+// A Condition is a comparison X Op Y.
+type Condition struct {
+	Op   token.Token
+	X, Y ssa.Value
+}
+
+// Flip returns the equivalent condition with the operands swapped.
+func (c Condition) Flip() Condition {
+	return Condition{flipped[c.Op], c.Y, c.X}
+}
+
+// Negate returns the condition holding if c doesn't.
+func (c Condition) Negate() Condition {
+	return Condition{negated[c.Op], c.X, c.Y}
+}
+
+// About returns c with v as its left operand, if v is one of its operands.
+func (c Condition) About(v ssa.Value) (Condition, bool) {
+	switch {
+	case Same(c.X, v):
+		return c, true
+	case Same(c.Y, v):
+		return c.Flip(), true
+	}
+	return c, false
+}
+
+// AboutLen returns c with len(v) as its left operand, if it is one of its
+// operands.
+func (c Condition) AboutLen(v ssa.Value) (Condition, bool) {
+	isLen := func(x ssa.Value) bool {
+		call := BuiltinCall(x, "len")
+		return call != nil && len(call.Call.Args) == 1 && call.Call.Args[0] == v
+	}
+	switch {
+	case isLen(c.X):
+		return c, true
+	case isLen(c.Y):
+		return c.Flip(), true
+	}
+	return c, false
+}
+
+// Int returns the right operand of c, if it is an integer constant.
+func (c Condition) Int() (int64, bool) {
+	k, ok := c.Y.(*ssa.Const)
+	if !ok || k.Value == nil || k.Value.Kind() != constant.Int {
+		return 0, false
+	}
+	return constant.Int64Val(k.Value)
+}
+
+var flipped = map[token.Token]token.Token{
+	token.EQL: token.EQL,
+	token.NEQ: token.NEQ,
+	token.LSS: token.GTR,
+	token.GTR: token.LSS,
+	token.LEQ: token.GEQ,
+	token.GEQ: token.LEQ,
+}
+
+var negated = map[token.Token]token.Token{
+	token.EQL: token.NEQ,
+	token.NEQ: token.EQL,
+	token.LSS: token.GEQ,
+	token.GEQ: token.LSS,
+	token.GTR: token.LEQ,
+	token.LEQ: token.GTR,
+}
+
+// Conditions returns the comparisons which hold whenever b is executed,
+// because b can only be reached through a branch on them. Only branches of
+// blocks dominating b with a single successor edge into the dominated
+// block are considered.
+func Conditions(b *ssa.BasicBlock) []Condition {
+	var conds []Condition
+	for ; b != nil; b = b.Idom() {
+		if len(b.Preds) != 1 {
+			continue
+		}
+		p := b.Preds[0]
+		if len(p.Instrs) == 0 {
+			continue
+		}
+		ifi, ok := p.Instrs[len(p.Instrs)-1].(*ssa.If)
+		if !ok {
+			continue
+		}
+		op, ok := ifi.Cond.(*ssa.BinOp)
+		if !ok {
+			continue
+		}
+		if _, ok := flipped[op.Op]; !ok {
+			continue
+		}
+		c := Condition{op.Op, op.X, op.Y}
+		if p.Succs[0] != b {
+			c = c.Negate()
+		}
+		conds = append(conds, c)
+	}
+	return conds
+}
+
+// InLoop returns whether b is part of a loop, that is, whether it can be
+// executed again after it was executed.
+func InLoop(b *ssa.BasicBlock) bool {
+	seen := make(map[*ssa.BasicBlock]bool)
+	work := append([]*ssa.BasicBlock(nil), b.Succs...)
+	for len(work) > 0 {
+		s := work[len(work)-1]
+		work = work[:len(work)-1]
+		if s == b {
+			return true
+		}
+		if seen[s] {
+			continue
+		}
+		seen[s] = true
+		work = append(work, s.Succs...)
+	}
+	return false
+}
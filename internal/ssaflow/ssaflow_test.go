@@ -0,0 +1,202 @@
+// Copyright 2019 Axel Wagner
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ssaflow
+
+import (
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"testing"
+
+	"golang.org/x/tools/go/ssa"
+	"golang.org/x/tools/go/ssa/ssautil"
+)
+
+const src = `package p
+
+func mark(int) {}
+
+func f(xs []int, n int) {
+	if len(xs) == 0 {
+		mark(1)
+		return
+	}
+	mark(2)
+	if 3 < n {
+		mark(3)
+	}
+	for i := 0; i < len(xs); i++ {
+		mark(4)
+	}
+}
+`
+
+// marks returns the blocks containing calls of mark, by argument.
+func marks(t *testing.T) (*ssa.Function, map[int64]*ssa.BasicBlock) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "p.go", src, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkg := types.NewPackage("p", "")
+	ssapkg, _, err := ssautil.BuildPackage(&types.Config{Importer: importer.Default()}, fset, pkg, []*ast.File{f}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fn := ssapkg.Func("f")
+	blocks := make(map[int64]*ssa.BasicBlock)
+	for _, b := range fn.Blocks {
+		for _, instr := range b.Instrs {
+			call, ok := instr.(*ssa.Call)
+			if !ok || call.Call.StaticCallee() == nil || call.Call.StaticCallee().Name() != "mark" {
+				continue
+			}
+			n, _ := Condition{Y: call.Call.Args[0]}.Int()
+			blocks[n] = b
+		}
+	}
+	return fn, blocks
+}
+
+func TestConditions(t *testing.T) {
+	fn, blocks := marks(t)
+	xs, n := fn.Params[0], fn.Params[1]
+
+	type want struct {
+		op  token.Token
+		val int64
+	}
+	check := func(mark int64, about func(Condition) (Condition, bool), w []want) {
+		t.Helper()
+		var got []want
+		for _, c := range Conditions(blocks[mark]) {
+			c, ok := about(c)
+			if !ok {
+				continue
+			}
+			v, ok := c.Int()
+			if !ok {
+				v = -1
+			}
+			got = append(got, want{c.Op, v})
+		}
+		if len(got) != len(w) {
+			t.Fatalf("mark(%d): got conditions %v, want %v", mark, got, w)
+		}
+		for i := range w {
+			if got[i] != w[i] {
+				t.Errorf("mark(%d): condition %d is %v, want %v", mark, i, got[i], w[i])
+			}
+		}
+	}
+	aboutLen := func(c Condition) (Condition, bool) { return c.AboutLen(xs) }
+	aboutN := func(c Condition) (Condition, bool) { return c.About(n) }
+
+	check(1, aboutLen, []want{{token.EQL, 0}})
+	check(2, aboutLen, []want{{token.NEQ, 0}})
+	check(3, aboutN, []want{{token.GTR, 3}})
+	check(3, aboutLen, []want{{token.NEQ, 0}})
+	// The loop condition compares with the loop index, which isn't a
+	// constant.
+	check(4, aboutLen, []want{{token.GTR, -1}, {token.NEQ, 0}})
+}
+
+func TestSame(t *testing.T) {
+	fn, _ := marks(t)
+	var lens []ssa.Value
+	for _, b := range fn.Blocks {
+		for _, instr := range b.Instrs {
+			if v, ok := instr.(ssa.Value); ok && BuiltinCall(v, "len") != nil {
+				lens = append(lens, v)
+			}
+		}
+	}
+	if len(lens) != 2 {
+		t.Fatalf("got %d calls of len, want 2", len(lens))
+	}
+	if !Same(lens[0], lens[1]) {
+		t.Errorf("Same(%v, %v) = false, want true", lens[0], lens[1])
+	}
+	if Same(lens[0], fn.Params[1]) {
+		t.Errorf("Same(%v, n) = true, want false", lens[0])
+	}
+}
+
+const fieldSrc = `package p
+
+type T struct {
+	items []int
+	n     int
+}
+
+func (t *T) mean(sum int) int {
+	if len(t.items) == 0 {
+		return 0
+	}
+	return sum / len(t.items)
+}
+
+func (t *T) reset(sum int) int {
+	if t.n == 0 {
+		return 0
+	}
+	t.n = 0
+	return sum / t.n
+}
+`
+
+func TestSameField(t *testing.T) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "p.go", fieldSrc, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkg := types.NewPackage("p", "")
+	ssapkg, _, err := ssautil.BuildPackage(&types.Config{Importer: importer.Default()}, fset, pkg, []*ast.File{f}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// loads returns the loads of fields in the method name of *T.
+	loads := func(name string) []ssa.Value {
+		mset := ssapkg.Prog.MethodSets.MethodSet(types.NewPointer(pkg.Scope().Lookup("T").Type()))
+		fn := ssapkg.Prog.MethodValue(mset.Lookup(pkg, name))
+		var vs []ssa.Value
+		for _, b := range fn.Blocks {
+			for _, instr := range b.Instrs {
+				if v, ok := instr.(*ssa.UnOp); ok && v.Op == token.MUL {
+					vs = append(vs, v)
+				}
+			}
+		}
+		return vs
+	}
+	if vs := loads("mean"); len(vs) != 2 || !Same(vs[0], vs[1]) {
+		t.Errorf("loads of t.items in mean are not the same value: %v", vs)
+	}
+	if vs := loads("reset"); len(vs) != 2 || Same(vs[0], vs[1]) {
+		t.Errorf("loads of t.n around a store in reset are the same value: %v", vs)
+	}
+}
+
+func TestInLoop(t *testing.T) {
+	_, blocks := marks(t)
+	for n, want := range map[int64]bool{1: false, 2: false, 3: false, 4: true} {
+		if got := InLoop(blocks[n]); got != want {
+			t.Errorf("InLoop(block of mark(%d)) = %v, want %v", n, got, want)
+		}
+	}
+}
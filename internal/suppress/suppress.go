@@ -0,0 +1,235 @@
+// Copyright 2019 Axel Wagner
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package suppress applies suppressions to diagnostics. A diagnostic is
+// suppressed either by a linter directive in the source, like
+//
+//	//lint:ignore redundantbranch the break documents intent
+//
+// or by being recorded in a baseline file of known findings. Suppressions
+// can be configured to expire after some time, in which case their
+// diagnostics are reported again. The age of a suppression is the time the
+// directive or the baseline entry was last changed, according to git blame.
+package suppress
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"go/token"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/Merovius/go-tools/internal/blame"
+	"github.com/Merovius/go-tools/internal/driver"
+	"github.com/Merovius/go-tools/internal/fingerprint"
+	"github.com/Merovius/go-tools/internal/posindex"
+)
+
+// A Finding is a diagnostic recorded in a baseline. Only the fingerprint is
+// used for matching, the other fields are for humans.
+type Finding struct {
+	Fingerprint string
+	Analyzer    string
+	Position    string
+	Message     string
+}
+
+// A Baseline is a set of known findings, which are not reported.
+type Baseline struct {
+	Findings []Finding
+
+	file  string
+	lines map[string]int // line of the first entry with a fingerprint
+}
+
+// NewBaseline returns a baseline containing diags. Positions are recorded
+// relative to root.
+func NewBaseline(diags []driver.Diagnostic, root string) *Baseline {
+	fp := fingerprint.New(root, nil)
+	b := new(Baseline)
+	for _, d := range diags {
+		posn := d.Posn
+		if rel, err := filepath.Rel(root, posn.Filename); err == nil {
+			posn.Filename = filepath.ToSlash(rel)
+		}
+		b.Findings = append(b.Findings, Finding{
+			Fingerprint: fp.Fingerprint(d),
+			Analyzer:    d.Analyzer,
+			Position:    posn.String(),
+			Message:     d.Message,
+		})
+	}
+	sort.SliceStable(b.Findings, func(i, j int) bool {
+		return b.Findings[i].Fingerprint < b.Findings[j].Fingerprint
+	})
+	return b
+}
+
+// ReadBaseline reads a baseline from a JSON file.
+func ReadBaseline(file string) (*Baseline, error) {
+	buf, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	b := &Baseline{file: file, lines: make(map[string]int)}
+	if err := json.Unmarshal(buf, b); err != nil {
+		return nil, fmt.Errorf("%s: %v", file, err)
+	}
+	// Remember where the entries are, to find out when they were added.
+	for i, l := range bytes.Split(buf, []byte("\n")) {
+		var v struct{ Fingerprint string }
+		l = bytes.TrimSuffix(bytes.TrimSpace(l), []byte(","))
+		if !bytes.HasPrefix(l, []byte(`"Fingerprint"`)) {
+			continue
+		}
+		if err := json.Unmarshal(append(append([]byte("{"), l...), '}'), &v); err != nil {
+			continue
+		}
+		if _, ok := b.lines[v.Fingerprint]; !ok {
+			b.lines[v.Fingerprint] = i + 1
+		}
+	}
+	return b, nil
+}
+
+// Write writes b as JSON to file.
+func (b *Baseline) Write(file string) error {
+	buf, err := json.MarshalIndent(b, "", "\t")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(file, append(buf, '\n'), 0666)
+}
+
+// position returns the position of the entry with the given fingerprint in
+// the file b was read from.
+func (b *Baseline) position(fingerprint string) token.Position {
+	if b.file == "" {
+		return token.Position{}
+	}
+	return token.Position{Filename: b.file, Line: b.lines[fingerprint]}
+}
+
+// A Kind is the kind of a suppression.
+type Kind string
+
+// The kinds of suppressions.
+const (
+	Directive Kind = "directive"
+	Baselined Kind = "baseline"
+)
+
+// A Suppression is a suppressed diagnostic.
+type Suppression struct {
+	Diagnostic driver.Diagnostic
+	Kind       Kind
+
+	// Reason is the reason given in a linter directive.
+	Reason string
+
+	// Posn is the position of the linter directive or the baseline entry.
+	Posn token.Position
+
+	// Blame is the last change of the directive or the baseline entry. It
+	// is zero if it is unknown.
+	Blame blame.Line
+
+	// Expired is set if the suppression is older than allowed and the
+	// diagnostic is reported again.
+	Expired bool
+}
+
+// Age returns the age of the suppression at time now, or 0 if it is
+// unknown.
+func (s Suppression) Age(now time.Time) time.Duration {
+	if s.Blame.Time.IsZero() {
+		return 0
+	}
+	return now.Sub(s.Blame.Time)
+}
+
+// Options configure the application of suppressions.
+type Options struct {
+	// Baseline contains the known findings. It may be nil.
+	Baseline *Baseline
+
+	// Root is the directory fingerprints are computed relative to. It
+	// must be the same that was used to create the baseline.
+	Root string
+
+	// Blame, if not nil, determines the last change of a line. It is used
+	// to compute the age of suppressions.
+	Blame func(file string, line int) (blame.Line, error)
+
+	// ExpireAfter, if positive, is the age after which suppressions
+	// expire. It needs Blame to be set.
+	ExpireAfter time.Duration
+
+	// Now is the current time. If zero, time.Now is used.
+	Now time.Time
+
+	// Index is used to read source files. If nil, a new one is used.
+	Index *posindex.Index
+}
+
+// Apply applies suppressions to diags, which should include diagnostics
+// ignored by linter directives. It returns the diagnostics to report and
+// all suppressions, including expired ones.
+func Apply(diags []driver.Diagnostic, opts Options) (report []driver.Diagnostic, sups []Suppression) {
+	now := opts.Now
+	if now.IsZero() {
+		now = time.Now()
+	}
+	fp := fingerprint.New(opts.Root, opts.Index)
+	known := make(map[string]int)
+	if opts.Baseline != nil {
+		for _, f := range opts.Baseline.Findings {
+			known[f.Fingerprint]++
+		}
+	}
+	for _, d := range diags {
+		var s Suppression
+		if d.Ignored != nil {
+			s = Suppression{
+				Kind:   Directive,
+				Reason: d.Ignored.Reason,
+				Posn:   d.Ignored.Posn,
+			}
+		} else if h := fp.Fingerprint(d); known[h] > 0 {
+			known[h]--
+			s = Suppression{
+				Kind: Baselined,
+				Posn: opts.Baseline.position(h),
+			}
+		} else {
+			report = append(report, d)
+			continue
+		}
+		s.Diagnostic = d
+		if opts.Blame != nil && s.Posn.Line > 0 {
+			// A suppression of unknown age doesn't expire.
+			s.Blame, _ = opts.Blame(s.Posn.Filename, s.Posn.Line)
+		}
+		if opts.ExpireAfter > 0 && s.Age(now) > opts.ExpireAfter {
+			s.Expired = true
+			d.Message += fmt.Sprintf(" (%s suppression from %s expired)", s.Kind, s.Blame.Time.Format("2006-01-02"))
+			report = append(report, d)
+		}
+		sups = append(sups, s)
+	}
+	return report, sups
+}
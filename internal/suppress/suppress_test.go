@@ -0,0 +1,120 @@
+// Copyright 2019 Axel Wagner
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package suppress
+
+import (
+	"go/token"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Merovius/go-tools/internal/blame"
+	"github.com/Merovius/go-tools/internal/driver"
+)
+
+const src = `package a
+
+func F() {
+	//lint:ignore redundantbranch intentional
+	break
+	continue
+	return
+}
+`
+
+func TestApply(t *testing.T) {
+	dir, err := ioutil.TempDir("", "suppress")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "a.go")
+	if err := ioutil.WriteFile(file, []byte(src), 0666); err != nil {
+		t.Fatal(err)
+	}
+	diag := func(line int, msg string) driver.Diagnostic {
+		return driver.Diagnostic{
+			Analyzer: "redundantbranch",
+			Posn:     token.Position{Filename: file, Line: line, Column: 2},
+			Message:  msg,
+		}
+	}
+	ignored := diag(5, "redundant break")
+	ignored.Ignored = &driver.Ignore{
+		Posn:      token.Position{Filename: file, Line: 4, Column: 2},
+		Analyzers: []string{"redundantbranch"},
+		Reason:    "intentional",
+	}
+	known := diag(6, "redundant continue")
+	fresh := diag(7, "redundant return")
+
+	baseFile := filepath.Join(dir, "baseline.json")
+	if err := NewBaseline([]driver.Diagnostic{known}, dir).Write(baseFile); err != nil {
+		t.Fatal(err)
+	}
+	base, err := ReadBaseline(baseFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := base.Findings[0].Position; got != "a.go:6:2" {
+		t.Errorf("baseline position = %q, want %q", got, "a.go:6:2")
+	}
+
+	now := time.Date(2019, 7, 1, 0, 0, 0, 0, time.UTC)
+	blamed := make(map[string]bool)
+	opts := Options{
+		Baseline: base,
+		Root:     dir,
+		Blame: func(file string, line int) (blame.Line, error) {
+			blamed[filepath.Base(file)] = true
+			if file == baseFile {
+				return blame.Line{Author: "b", Time: now.AddDate(0, 0, -10)}, nil
+			}
+			return blame.Line{Author: "a", Time: now.AddDate(0, 0, -100)}, nil
+		},
+		Now: now,
+	}
+	all := []driver.Diagnostic{ignored, known, fresh}
+
+	report, sups := Apply(all, opts)
+	if len(report) != 1 || report[0].Message != fresh.Message {
+		t.Errorf("Apply() reports %v, want only %v", report, fresh)
+	}
+	if len(sups) != 2 {
+		t.Fatalf("Apply() returned %d suppressions, want 2", len(sups))
+	}
+	if s := sups[0]; s.Kind != Directive || s.Reason != "intentional" || s.Blame.Author != "a" || s.Expired {
+		t.Errorf("directive suppression = %+v", s)
+	}
+	if s := sups[1]; s.Kind != Baselined || s.Posn.Filename != baseFile || s.Blame.Author != "b" || s.Expired {
+		t.Errorf("baseline suppression = %+v", s)
+	}
+	if !blamed["a.go"] || !blamed["baseline.json"] {
+		t.Errorf("blamed files %v, want a.go and baseline.json", blamed)
+	}
+
+	// Only the directive is older than 30 days.
+	opts.ExpireAfter = 30 * 24 * time.Hour
+	report, sups = Apply(all, opts)
+	if len(report) != 2 || !strings.Contains(report[0].Message, "expired") {
+		t.Fatalf("Apply() with expiry reports %v, want expired directive and %v", report, fresh)
+	}
+	if !sups[0].Expired || sups[1].Expired {
+		t.Errorf("Expired = %v, %v, want true, false", sups[0].Expired, sups[1].Expired)
+	}
+}
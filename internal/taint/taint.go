@@ -0,0 +1,280 @@
+// Copyright 2019 Axel Wagner
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package taint tracks which SSA values of a function are derived from
+// untrusted data, like the body of an HTTP response or a command line
+// argument, for analyzers looking for such data reaching sensitive
+// functions.
+//
+// The analysis is intraprocedural: data flows through the instructions of a
+// function, through memory written and read in it and through calls of
+// functions known to pass data through, like filepath.Join. Values returned
+// by other functions are only tainted if they are sources themselves.
+package taint
+
+import (
+	"go/token"
+	"go/types"
+
+	"github.com/Merovius/go-tools/internal/analysisutil"
+	"golang.org/x/tools/go/ssa"
+)
+
+// Kind is a set of kinds of untrusted data.
+type Kind uint
+
+const (
+	// Network is data read from the network, like the body of an HTTP
+	// response or a connection, whose size the peer controls.
+	Network Kind = 1 << iota
+
+	// UserInput is data users control, like command line arguments and
+	// the parts of HTTP requests.
+	UserInput
+)
+
+// A Config describes the sources of untrusted data and how it propagates.
+type Config struct {
+	// Source returns the kinds of untrusted data v is a source of.
+	Source func(v ssa.Value) Kind
+
+	// Propagators are the functions, named like analysisutil.FuncName,
+	// whose results are tainted by their arguments and receivers.
+	Propagators map[string]bool
+}
+
+// Default uses the sources and propagators of this package.
+var Default = &Config{Source: Source, Propagators: Propagators}
+
+// Propagators are functions of the standard library passing data through.
+var Propagators = map[string]bool{
+	"(*net/http.Request).FormValue":     true,
+	"(*net/http.Request).PostFormValue": true,
+	"(*net/http.Request).Cookie":        true,
+	"(*net/url.URL).Query":              true,
+	"(net/url.Values).Get":              true,
+	"path/filepath.Join":                true,
+	"path/filepath.Clean":               true,
+	"path/filepath.Abs":                 true,
+	"path.Join":                         true,
+	"path.Clean":                        true,
+	"strings.TrimSpace":                 true,
+	"strings.TrimPrefix":                true,
+	"strings.TrimSuffix":                true,
+	"strings.ToLower":                   true,
+	"fmt.Sprintf":                       true,
+	"bufio.NewReader":                   true,
+	"bufio.NewReaderSize":               true,
+	"io.TeeReader":                      true,
+	"io.MultiReader":                    true,
+	"compress/gzip.NewReader":           true,
+	"compress/flate.NewReader":          true,
+}
+
+// userCalls are functions returning user input.
+var userCalls = map[string]bool{
+	"flag.Arg":                     true,
+	"flag.Args":                    true,
+	"flag.String":                  true,
+	"(*flag.FlagSet).Arg":          true,
+	"(*flag.FlagSet).Args":         true,
+	"(*flag.FlagSet).String":       true,
+	"(*net/http.Request).FormFile": true,
+}
+
+// connTypes are the types of network connections.
+var connTypes = []string{
+	"net.Conn",
+	"*net.TCPConn",
+	"*net.UDPConn",
+	"*net.UnixConn",
+	"*crypto/tls.Conn",
+}
+
+// Source returns the kinds of untrusted data v is a source of, by the
+// standard library: the bodies of HTTP responses and network connections
+// are Network data, HTTP requests, os.Args and command line flags are
+// UserInput.
+func Source(v ssa.Value) Kind {
+	var k Kind
+	t := v.Type()
+	for _, name := range connTypes {
+		if analysisutil.TypeName(t) == name {
+			k |= Network
+		}
+	}
+	if analysisutil.IsPointerTo(t, "net/http.Request") {
+		k |= UserInput
+	}
+	switch v := v.(type) {
+	case *ssa.FieldAddr:
+		if analysisutil.IsPointerTo(v.X.Type(), "net/http.Response") && field(v.X.Type(), v.Field) == "Body" {
+			k |= Network
+		}
+	case *ssa.Global:
+		if v.Pkg != nil && v.Pkg.Pkg.Path() == "os" && v.Name() == "Args" {
+			k |= UserInput
+		}
+	case *ssa.Call:
+		if userCalls[Callee(&v.Call)] {
+			k |= UserInput
+		}
+	}
+	return k
+}
+
+// field returns the name of field i of the struct pointed to by ptr.
+func field(ptr types.Type, i int) string {
+	p, ok := ptr.Underlying().(*types.Pointer)
+	if !ok {
+		return ""
+	}
+	s, ok := p.Elem().Underlying().(*types.Struct)
+	if !ok || i >= s.NumFields() {
+		return ""
+	}
+	return s.Field(i).Name()
+}
+
+// Callee returns the name of the function called by c, like
+// analysisutil.FuncName, or "" for dynamic calls.
+func Callee(c *ssa.CallCommon) string {
+	if c.IsInvoke() {
+		return analysisutil.FuncName(c.Method)
+	}
+	if fn := c.StaticCallee(); fn != nil {
+		if obj, ok := fn.Object().(*types.Func); ok {
+			return analysisutil.FuncName(obj)
+		}
+	}
+	return ""
+}
+
+// A Result maps the tainted values of a function to the kinds of untrusted
+// data they are derived from.
+type Result map[ssa.Value]Kind
+
+// Analyze returns the tainted values of fn.
+func (c *Config) Analyze(fn *ssa.Function) Result {
+	r := make(Result)
+	mark := func(v ssa.Value, k Kind) bool {
+		if v == nil || r[v]|k == r[v] {
+			return false
+		}
+		r[v] |= k
+		return true
+	}
+	for _, p := range fn.Params {
+		mark(p, c.Source(p))
+	}
+	for _, fv := range fn.FreeVars {
+		mark(fv, c.Source(fv))
+	}
+	for changed := true; changed; {
+		changed = false
+		for _, b := range fn.Blocks {
+			for _, instr := range b.Instrs {
+				if c.step(instr, r, mark) {
+					changed = true
+				}
+			}
+		}
+	}
+	return r
+}
+
+// step propagates taint to the value defined or the memory written by
+// instr and returns whether anything changed.
+func (c *Config) step(instr ssa.Instruction, r Result, mark func(ssa.Value, Kind) bool) bool {
+	if s, ok := instr.(*ssa.Store); ok {
+		k := r[s.Val]
+		changed := mark(s.Addr, k)
+		if root := root(s.Addr); root != s.Addr && mark(root, k) {
+			changed = true
+		}
+		return changed
+	}
+	v, ok := instr.(ssa.Value)
+	if !ok {
+		return false
+	}
+	// Operands which are not instructions, like globals, can be sources
+	// as well.
+	for _, op := range instr.Operands(nil) {
+		if *op != nil {
+			if _, ok := (*op).(ssa.Instruction); !ok {
+				mark(*op, c.Source(*op))
+			}
+		}
+	}
+	k := c.Source(v)
+	switch v := v.(type) {
+	case *ssa.Phi:
+		for _, e := range v.Edges {
+			k |= r[e]
+		}
+	case *ssa.UnOp:
+		k |= r[v.X]
+	case *ssa.BinOp:
+		if v.Op == token.ADD {
+			k |= r[v.X] | r[v.Y]
+		}
+	case *ssa.Convert:
+		k |= r[v.X]
+	case *ssa.ChangeType:
+		k |= r[v.X]
+	case *ssa.ChangeInterface:
+		k |= r[v.X]
+	case *ssa.MakeInterface:
+		k |= r[v.X]
+	case *ssa.TypeAssert:
+		k |= r[v.X]
+	case *ssa.Extract:
+		k |= r[v.Tuple]
+	case *ssa.Field:
+		k |= r[v.X]
+	case *ssa.FieldAddr:
+		k |= r[v.X]
+	case *ssa.Index:
+		k |= r[v.X]
+	case *ssa.IndexAddr:
+		k |= r[v.X]
+	case *ssa.Lookup:
+		k |= r[v.X]
+	case *ssa.Slice:
+		k |= r[v.X]
+	case *ssa.Call:
+		if c.Propagators[Callee(&v.Call)] {
+			k |= r[v.Call.Value]
+			for _, a := range v.Call.Args {
+				k |= r[a]
+			}
+		}
+	}
+	return mark(v, k)
+}
+
+// root returns the variable addr points into.
+func root(addr ssa.Value) ssa.Value {
+	for {
+		switch a := addr.(type) {
+		case *ssa.FieldAddr:
+			addr = a.X
+		case *ssa.IndexAddr:
+			addr = a.X
+		default:
+			return addr
+		}
+	}
+}
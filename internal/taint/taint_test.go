@@ -0,0 +1,102 @@
+// Copyright 2019 Axel Wagner
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package taint
+
+import (
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"testing"
+
+	"golang.org/x/tools/go/ssa"
+	"golang.org/x/tools/go/ssa/ssautil"
+)
+
+const src = `package p
+
+import (
+	"flag"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+func mark(int, interface{}) {}
+
+func f(r *http.Request, resp *http.Response, l net.Listener) {
+	mark(1, resp.Body)
+	mark(2, filepath.Join("/data", r.FormValue("name")))
+	mark(3, os.Args[1])
+	mark(4, strings.NewReader("x"))
+	mark(5, flag.Arg(0))
+	s := "default"
+	if len(os.Args) > 2 {
+		s = os.Args[2]
+	}
+	mark(6, s)
+	conn, _ := l.Accept()
+	mark(7, conn)
+	var p struct{ name string }
+	p.name = r.URL.Path
+	mark(8, p.name)
+	mark(9, strings.ToUpper(s))
+}
+`
+
+func TestAnalyze(t *testing.T) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "p.go", src, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkg := types.NewPackage("p", "")
+	ssapkg, _, err := ssautil.BuildPackage(&types.Config{Importer: importer.Default()}, fset, pkg, []*ast.File{f}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fn := ssapkg.Func("f")
+	r := Default.Analyze(fn)
+	got := make(map[int64]Kind)
+	for _, b := range fn.Blocks {
+		for _, instr := range b.Instrs {
+			call, ok := instr.(*ssa.Call)
+			if !ok || call.Call.StaticCallee() == nil || call.Call.StaticCallee().Name() != "mark" {
+				continue
+			}
+			n := call.Call.Args[0].(*ssa.Const).Int64()
+			got[n] = r[call.Call.Args[1]]
+		}
+	}
+	want := map[int64]Kind{
+		1: Network,
+		2: UserInput,
+		3: UserInput,
+		4: 0,
+		5: UserInput,
+		6: UserInput,
+		7: Network,
+		8: UserInput,
+		9: 0,
+	}
+	for n, k := range want {
+		if got[n] != k {
+			t.Errorf("kind of value %d = %v, want %v", n, got[n], k)
+		}
+	}
+}
@@ -0,0 +1,157 @@
+// Copyright 2019 Axel Wagner
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package internalimport defines an Analyzer that checks for imports of
+// packages which are not meant to be imported.
+package internalimport
+
+import (
+	"go/ast"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/Merovius/go-tools/internal/analysisutil"
+	"github.com/Merovius/go-tools/internal/gomod"
+	"github.com/Merovius/go-tools/internal/nodefilter"
+	"golang.org/x/tools/go/analysis"
+)
+
+const Doc = `check for imports of packages which are not meant to be imported
+
+The analyzer reports
+  - imports of internal packages of other modules. The go command only
+    checks import paths, so nested modules, replace directives and vendored
+    copies can make internal packages of another module importable. They are
+    not part of its API and can change in any release, and
+  - imports of packages of the same module marked as experimental, by a
+    paragraph of their package documentation starting with "Experimental:".
+    Packages below the experimental one are not reported.`
+
+var Analyzer = &analysis.Analyzer{
+	Name: "internalimport",
+	Doc:  Doc,
+	Run:  run,
+	Requires: []*analysis.Analyzer{
+		nodefilter.Analyzer,
+	},
+	FactTypes: []analysis.Fact{new(experimental)},
+}
+
+var imports = nodefilter.New(false, new(ast.ImportSpec))
+
+// experimental is a package fact of packages marked as experimental.
+type experimental struct {
+	Note string // the text of the marker
+}
+
+func (*experimental) AFact() {}
+
+func (e *experimental) String() string { return "experimental: " + e.Note }
+
+func run(pass *analysis.Pass) (interface{}, error) {
+	if len(pass.Files) == 0 {
+		return nil, nil
+	}
+	for _, f := range pass.Files {
+		if note, ok := marker(f.Doc); ok {
+			pass.ExportPackageFact(&experimental{note})
+			break
+		}
+	}
+
+	mod, err := gomod.Find(filepath.Dir(pass.Fset.File(pass.Files[0].Pos()).Name()))
+	if err != nil {
+		return nil, err
+	}
+	self := strings.TrimSuffix(analysisutil.TrimVendor(pass.Pkg.Path()), "_test")
+
+	nodes := pass.ResultOf[nodefilter.Analyzer].(*nodefilter.Result)
+	for _, n := range nodes.Nodes(imports) {
+		spec := n.Node.(*ast.ImportSpec)
+		path, err := strconv.Unquote(spec.Path.Value)
+		if err != nil {
+			continue
+		}
+		path = analysisutil.TrimVendor(path)
+		if mod != nil && isInternal(path) && !inModule(mod, path) {
+			owner := mod.ModuleOf(path)
+			if owner == "" {
+				// Not a required module, it is probably vendored.
+				owner = internalParent(path)
+			}
+			pass.Reportf(spec.Path.Pos(), "%s is an internal package of module %s; it is not part of its API and can change in any release", path, owner)
+			continue
+		}
+		checkExperimental(pass, spec, path, self, mod)
+	}
+	return nil, nil
+}
+
+func checkExperimental(pass *analysis.Pass, spec *ast.ImportSpec, path, self string, mod *gomod.File) {
+	var exp *experimental
+	for _, imp := range pass.Pkg.Imports() {
+		if analysisutil.TrimVendor(imp.Path()) == path {
+			e := new(experimental)
+			if pass.ImportPackageFact(imp, e) {
+				exp = e
+			}
+			break
+		}
+	}
+	if exp == nil || self == path || strings.HasPrefix(self, path+"/") {
+		return
+	}
+	if mod != nil && !inModule(mod, path) {
+		// Other modules document their stability themselves.
+		return
+	}
+	pass.Report(analysis.Diagnostic{
+		Pos:      spec.Path.Pos(),
+		Category: "experimental",
+		Message:  path + " is experimental (" + exp.Note + "); it can change or go away without notice",
+	})
+}
+
+// isInternal returns whether path has an internal element.
+func isInternal(path string) bool {
+	return strings.Contains("/"+path+"/", "/internal/")
+}
+
+// internalParent returns the path of the tree allowed to import the internal
+// package path.
+func internalParent(path string) string {
+	if i := strings.LastIndex("/"+path+"/", "/internal/"); i > 0 {
+		return path[:i-1]
+	}
+	return path
+}
+
+// inModule returns whether path is provided by the main module of f.
+func inModule(f *gomod.File, path string) bool {
+	return f.ModuleOf(path) == f.Module
+}
+
+// marker returns the text of the "Experimental:" paragraph of doc, if any.
+func marker(doc *ast.CommentGroup) (string, bool) {
+	if doc == nil {
+		return "", false
+	}
+	for _, para := range strings.Split(doc.Text(), "\n\n") {
+		if strings.HasPrefix(para, "Experimental:") {
+			return strings.Join(strings.Fields(strings.TrimPrefix(para, "Experimental:")), " "), true
+		}
+	}
+	return "", false
+}
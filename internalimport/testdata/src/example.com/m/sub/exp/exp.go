@@ -0,0 +1,7 @@
+// Package exp does things.
+//
+// Experimental: the API is not
+// stable yet.
+package exp // want package:"experimental: the API is not stable yet."
+
+const E = 1
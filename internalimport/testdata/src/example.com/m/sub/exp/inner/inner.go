@@ -0,0 +1,5 @@
+package inner
+
+import "example.com/m/sub/exp"
+
+const I = exp.E
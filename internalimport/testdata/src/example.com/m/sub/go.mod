@@ -0,0 +1,5 @@
+module example.com/m/sub
+
+go 1.12
+
+require example.com/m v1.0.0
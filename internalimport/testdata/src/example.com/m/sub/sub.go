@@ -0,0 +1,9 @@
+package sub
+
+import (
+	"example.com/m/internal/x" // want `example.com/m/internal/x is an internal package of module example.com/m; it is not part of its API`
+	"example.com/m/sub/exp"    // want `example.com/m/sub/exp is experimental \(the API is not stable yet.\); it can change or go away without notice`
+	"example.com/m/sub/internal/y"
+)
+
+const S = x.X + y.Y + exp.E
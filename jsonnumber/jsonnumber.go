@@ -0,0 +1,268 @@
+// Copyright 2019 Axel Wagner
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package jsonnumber defines an Analyzer that checks for integers read from
+// JSON decoded into interface{} values.
+package jsonnumber
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+
+	"github.com/Merovius/go-tools/internal/analysisutil"
+	"github.com/Merovius/go-tools/internal/nodefilter"
+	"golang.org/x/tools/go/analysis"
+)
+
+const Doc = `check for integers read from JSON decoded into interface{} values
+
+encoding/json decodes numbers into interface{} values, like the values of a
+map[string]interface{}, as float64, unless Decoder.UseNumber is used.
+float64 represents integers exactly only up to 2^53, so converting them to
+an integer type silently corrupts larger values, like IDs:
+
+	var m map[string]interface{}
+	json.Unmarshal(data, &m)
+	id := int64(m["id"].(float64)) // wrong for IDs above 2^53
+
+The analyzer reports such conversions of values asserted to float64 from
+values decoded in the same function, following assignments, range loops
+and type switches. Decode into a struct with integer fields instead, or
+use json.Number.`
+
+var Analyzer = &analysis.Analyzer{
+	Name: "jsonnumber",
+	Doc:  Doc,
+	Run:  run,
+	Requires: []*analysis.Analyzer{
+		nodefilter.Analyzer,
+	},
+}
+
+var funcs = nodefilter.New(false, new(ast.FuncDecl))
+
+func run(pass *analysis.Pass) (interface{}, error) {
+	if !analysisutil.Imports(pass.Pkg, "encoding/json") {
+		return nil, nil
+	}
+	nodes := pass.ResultOf[nodefilter.Analyzer].(*nodefilter.Result)
+	for _, n := range nodes.Nodes(funcs) {
+		fn := n.Node.(*ast.FuncDecl)
+		if fn.Body == nil || analysisutil.IsGenerated(pass, fn.Pos()) {
+			continue
+		}
+		checkFunc(pass, fn.Body)
+	}
+	return nil, nil
+}
+
+// A checker tracks the variables holding decoded JSON in a function.
+type checker struct {
+	info    *types.Info
+	decoded map[types.Object]bool // holding decoded interface{} values
+	floats  map[types.Object]bool // holding float64 asserted from them
+}
+
+func checkFunc(pass *analysis.Pass, body *ast.BlockStmt) {
+	c := &checker{
+		info:    pass.TypesInfo,
+		decoded: make(map[types.Object]bool),
+		floats:  make(map[types.Object]bool),
+	}
+	ast.Inspect(body, func(n ast.Node) bool {
+		call, ok := n.(*ast.CallExpr)
+		if !ok || len(call.Args) == 0 {
+			return true
+		}
+		var target ast.Expr
+		switch analysisutil.CalleeName(c.info, call) {
+		case "encoding/json.Unmarshal":
+			if len(call.Args) == 2 {
+				target = call.Args[1]
+			}
+		case "(*encoding/json.Decoder).Decode":
+			target = call.Args[0]
+		}
+		if u, ok := analysisutil.Unparen(target).(*ast.UnaryExpr); ok && u.Op == token.AND {
+			target = u.X
+		}
+		if target != nil && hasInterface(c.info.TypeOf(target), 0) {
+			if obj := c.root(target); obj != nil {
+				c.decoded[obj] = true
+			}
+		}
+		return true
+	})
+	if len(c.decoded) == 0 {
+		return
+	}
+	// Propagate through assignments until nothing changes, as loops can
+	// assign variables before their uses.
+	for changed := true; changed; {
+		changed = false
+		mark := func(m map[types.Object]bool, e ast.Expr) {
+			if obj := analysisutil.ObjectOf(c.info, e); obj != nil && !m[obj] {
+				m[obj] = true
+				changed = true
+			}
+		}
+		assign := func(lhs []ast.Expr, rhs []ast.Expr) {
+			if len(rhs) == 1 && len(lhs) > 1 {
+				// v, ok := x.(T) and v, ok := m[k]
+				lhs = lhs[:1]
+			}
+			for i := range lhs {
+				if i >= len(rhs) {
+					break
+				}
+				switch {
+				case c.isFloat(rhs[i]):
+					mark(c.floats, lhs[i])
+				case c.isDecoded(rhs[i]):
+					mark(c.decoded, lhs[i])
+				}
+			}
+		}
+		ast.Inspect(body, func(n ast.Node) bool {
+			switch n := n.(type) {
+			case *ast.AssignStmt:
+				assign(n.Lhs, n.Rhs)
+			case *ast.ValueSpec:
+				var lhs []ast.Expr
+				for _, id := range n.Names {
+					lhs = append(lhs, id)
+				}
+				assign(lhs, n.Values)
+			case *ast.RangeStmt:
+				if c.isDecoded(n.X) && n.Value != nil {
+					mark(c.decoded, n.Value)
+				}
+			case *ast.TypeSwitchStmt:
+				var x ast.Expr
+				switch a := n.Assign.(type) {
+				case *ast.AssignStmt:
+					x = a.Rhs[0].(*ast.TypeAssertExpr).X
+				case *ast.ExprStmt:
+					x = a.X.(*ast.TypeAssertExpr).X
+				}
+				if !c.isDecoded(x) {
+					break
+				}
+				for _, cc := range n.Body.List {
+					obj := c.info.Implicits[cc]
+					if obj == nil || c.floats[obj] || c.decoded[obj] {
+						continue
+					}
+					switch {
+					case isFloat64(obj.Type()):
+						c.floats[obj] = true
+						changed = true
+					case hasInterface(obj.Type(), 0):
+						c.decoded[obj] = true
+						changed = true
+					}
+				}
+			}
+			return true
+		})
+	}
+
+	ast.Inspect(body, func(n ast.Node) bool {
+		call, ok := n.(*ast.CallExpr)
+		if !ok || len(call.Args) != 1 {
+			return true
+		}
+		tv, ok := c.info.Types[call.Fun]
+		if !ok || !tv.IsType() {
+			return true
+		}
+		b, ok := tv.Type.Underlying().(*types.Basic)
+		if !ok || b.Info()&types.IsInteger == 0 || !c.isFloat(call.Args[0]) {
+			return true
+		}
+		pass.Reportf(call.Pos(), "conversion of a JSON number decoded into interface{} to %s loses precision above 2^53, as it is decoded as float64; decode into a struct with integer fields or use json.Number", types.TypeString(tv.Type, types.RelativeTo(pass.Pkg)))
+		return true
+	})
+}
+
+// root returns the variable e is an element or field of.
+func (c *checker) root(e ast.Expr) types.Object {
+	for {
+		switch x := analysisutil.Unparen(e).(type) {
+		case *ast.Ident:
+			return c.info.ObjectOf(x)
+		case *ast.IndexExpr:
+			e = x.X
+		case *ast.TypeAssertExpr:
+			e = x.X
+		case *ast.SelectorExpr:
+			if sel, ok := c.info.Selections[x]; !ok || sel.Kind() != types.FieldVal {
+				return nil
+			}
+			e = x.X
+		default:
+			return nil
+		}
+	}
+}
+
+// isDecoded returns whether e is part of a decoded value, and of a type
+// containing interface{}.
+func (c *checker) isDecoded(e ast.Expr) bool {
+	obj := c.root(e)
+	return obj != nil && c.decoded[obj] && hasInterface(c.info.TypeOf(e), 0)
+}
+
+// isFloat returns whether e is a float64 from a decoded value.
+func (c *checker) isFloat(e ast.Expr) bool {
+	e = analysisutil.Unparen(e)
+	if ta, ok := e.(*ast.TypeAssertExpr); ok && ta.Type != nil {
+		return isFloat64(c.info.TypeOf(ta.Type)) && c.isDecoded(ta.X)
+	}
+	obj := analysisutil.ObjectOf(c.info, e)
+	return obj != nil && c.floats[obj]
+}
+
+func isFloat64(t types.Type) bool {
+	b, ok := t.(*types.Basic)
+	return ok && b.Kind() == types.Float64
+}
+
+// hasInterface returns whether t is interface{} or contains it as the
+// element of maps, slices, arrays or pointers or as a field.
+func hasInterface(t types.Type, depth int) bool {
+	if t == nil || depth > 10 {
+		return false
+	}
+	switch u := t.Underlying().(type) {
+	case *types.Interface:
+		return u.NumMethods() == 0
+	case *types.Map:
+		return hasInterface(u.Elem(), depth+1)
+	case *types.Slice:
+		return hasInterface(u.Elem(), depth+1)
+	case *types.Array:
+		return hasInterface(u.Elem(), depth+1)
+	case *types.Pointer:
+		return hasInterface(u.Elem(), depth+1)
+	case *types.Struct:
+		for i := 0; i < u.NumFields(); i++ {
+			if hasInterface(u.Field(i).Type(), depth+1) {
+				return true
+			}
+		}
+	}
+	return false
+}
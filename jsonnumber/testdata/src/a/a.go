@@ -0,0 +1,73 @@
+package a
+
+import (
+	"encoding/json"
+	"io"
+	"strconv"
+)
+
+type ID int64
+
+func Map(data []byte) (int64, error) {
+	var m map[string]interface{}
+	if err := json.Unmarshal(data, &m); err != nil {
+		return 0, err
+	}
+	_ = int(m["count"].(float64)) // want `conversion of a JSON number decoded into interface{} to int loses precision above 2\^53`
+	f, ok := m["id"].(float64)
+	if !ok {
+		return 0, nil
+	}
+	_ = ID(f) // want `conversion of a JSON number decoded into interface{} to ID loses precision`
+	_ = float32(f)
+	return int64(f), nil // want `to int64 loses precision`
+}
+
+func Nested(r io.Reader) []uint64 {
+	var v interface{}
+	if err := json.NewDecoder(r).Decode(&v); err != nil {
+		return nil
+	}
+	var ids []uint64
+	items := v.(map[string]interface{})["items"].([]interface{})
+	for _, it := range items {
+		switch x := it.(type) {
+		case float64:
+			ids = append(ids, uint64(x)) // want `to uint64 loses precision`
+		case string:
+			n, _ := strconv.ParseUint(x, 10, 64)
+			ids = append(ids, n)
+		}
+	}
+	return ids
+}
+
+type Event struct {
+	Name    string
+	Payload map[string]interface{}
+}
+
+func Field(data []byte) int {
+	var e Event
+	json.Unmarshal(data, &e)
+	return int(e.Payload["seq"].(float64)) // want `to int loses precision`
+}
+
+func Typed(data []byte) int64 {
+	var s struct{ ID int64 }
+	json.Unmarshal(data, &s)
+	return s.ID
+}
+
+func Number(r io.Reader) int64 {
+	dec := json.NewDecoder(r)
+	dec.UseNumber()
+	var m map[string]interface{}
+	dec.Decode(&m)
+	n, _ := m["id"].(json.Number).Int64()
+	return n
+}
+
+func NotDecoded(m map[string]interface{}) int {
+	return int(m["x"].(float64))
+}
@@ -0,0 +1,106 @@
+// Copyright 2019 Axel Wagner
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package keyedfields defines an Analyzer that checks for composite literals
+// of structs of other packages with positional fields.
+package keyedfields
+
+import (
+	"go/ast"
+	"go/types"
+
+	"github.com/Merovius/go-tools/internal/analysisutil"
+	"github.com/Merovius/go-tools/internal/fix"
+	"github.com/Merovius/go-tools/internal/nodefilter"
+	"golang.org/x/tools/go/analysis"
+)
+
+const Doc = `check for struct literals of other packages with positional fields
+
+A composite literal like pkg.T{1, "x"} lists the values of all fields in
+order. It stops compiling when a field is added to pkg.T, which is usually
+considered a compatible change, and silently changes meaning if two fields
+of the same type are swapped. The analyzer reports such literals of struct
+types declared in other packages and suggests naming the fields, as in
+pkg.T{A: 1, B: "x"}. Literals of types of the same package, which change
+along with the type, and generated files are not reported.`
+
+var Analyzer = &analysis.Analyzer{
+	Name: "keyedfields",
+	Doc:  Doc,
+	Run:  run,
+	Requires: []*analysis.Analyzer{
+		nodefilter.Analyzer,
+	},
+}
+
+func init() {
+	// Naming the fields doesn't change the value of the literal.
+	fix.Register(Analyzer, fix.Safe)
+}
+
+var lits = nodefilter.New(false, new(ast.CompositeLit))
+
+func run(pass *analysis.Pass) (interface{}, error) {
+	nodes := pass.ResultOf[nodefilter.Analyzer].(*nodefilter.Result)
+	for _, n := range nodes.Nodes(lits) {
+		lit := n.Node.(*ast.CompositeLit)
+		if len(lit.Elts) == 0 {
+			continue
+		}
+		if _, ok := lit.Elts[0].(*ast.KeyValueExpr); ok {
+			continue
+		}
+		named := namedType(pass.TypesInfo.TypeOf(lit))
+		if named == nil {
+			continue
+		}
+		obj := named.Obj()
+		if obj.Pkg() == nil || obj.Pkg() == pass.Pkg {
+			continue
+		}
+		st, ok := named.Underlying().(*types.Struct)
+		if !ok || st.NumFields() != len(lit.Elts) || analysisutil.IsGenerated(pass, lit.Pos()) {
+			continue
+		}
+		var edits []analysis.TextEdit
+		for i, e := range lit.Elts {
+			edits = append(edits, analysis.TextEdit{
+				Pos:     e.Pos(),
+				End:     e.Pos(),
+				NewText: []byte(st.Field(i).Name() + ": "),
+			})
+		}
+		pass.Report(analysis.Diagnostic{
+			Pos:     lit.Pos(),
+			End:     lit.End(),
+			Message: "literal of " + analysisutil.TypeName(named) + " uses positional fields, which break when fields are added to it; name the fields",
+			SuggestedFixes: []analysis.SuggestedFix{{
+				Message:   "use keyed fields",
+				TextEdits: edits,
+			}},
+		})
+	}
+	return nil, nil
+}
+
+// namedType returns the named type of t, or of the type t points to, or
+// nil.
+func namedType(t types.Type) *types.Named {
+	if p, ok := t.(*types.Pointer); ok {
+		t = p.Elem()
+	}
+	named, _ := t.(*types.Named)
+	return named
+}
@@ -0,0 +1,26 @@
+package a
+
+import (
+	"b"
+	"image"
+)
+
+type local struct{ a, b int }
+
+var (
+	_ = b.Point{1, 2} // want `literal of b.Point uses positional fields, which break when fields are added to it; name the fields`
+	_ = &b.Point{ // want `literal of b.Point uses positional fields`
+		3,
+		4,
+	}
+	_ = b.Embed{b.Point{1, 2}, "x"} // want `literal of b.Embed uses positional fields` `literal of b.Point uses positional fields`
+	_ = []b.Point{{1, 2}}           // want `literal of b.Point uses positional fields`
+	_ = []*b.Point{{1, 2}}          // want `literal of b.Point uses positional fields`
+	_ = image.Point{5, 6}           // want `literal of image.Point uses positional fields`
+
+	_ = b.Point{X: 1, Y: 2}
+	_ = b.Point{}
+	_ = b.List{1, 2, 3}
+	_ = local{1, 2}
+	_ = struct{ a int }{1}
+)
@@ -0,0 +1,12 @@
+package b
+
+type Point struct {
+	X, Y int
+}
+
+type Embed struct {
+	Point
+	Name string
+}
+
+type List []int
@@ -0,0 +1,238 @@
+// Copyright 2019 Axel Wagner
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package licenseheader defines an Analyzer that checks that files start
+// with a license header.
+package licenseheader
+
+import (
+	"go/ast"
+	"go/token"
+	"io/ioutil"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Merovius/go-tools/internal/analysisutil"
+	"github.com/Merovius/go-tools/internal/fix"
+	"github.com/Merovius/go-tools/internal/nodefilter"
+	"golang.org/x/tools/go/analysis"
+)
+
+const Doc = `check that files start with a license header
+
+The header is given as text by the -template flag or as a file by the
+-template-file flag, usually in the flags of the configuration file. It is
+written without comment markers, with {year} standing for a year or a range
+of years, like 2019 or 2019-2021. Differences in white space are ignored. If
+no template is given, nothing is checked.
+
+The analyzer reports files without a header, suggesting to insert it with
+the current year, and files with a header not matching the template. With
+the -update-year flag, it also reports headers whose (last) year isn't the
+current one, suggesting to extend the range. Generated files are not
+checked.`
+
+var Analyzer = &analysis.Analyzer{
+	Name: "licenseheader",
+	Doc:  Doc,
+	Run:  run,
+	Requires: []*analysis.Analyzer{
+		nodefilter.Analyzer,
+	},
+}
+
+var (
+	template     string
+	templateFile string
+	updateYear   bool
+)
+
+func init() {
+	Analyzer.Flags.StringVar(&template, "template", "", "license header `text`, with {year} standing for the year")
+	Analyzer.Flags.StringVar(&templateFile, "template-file", "", "`file` containing the license header, overriding -template")
+	Analyzer.Flags.BoolVar(&updateYear, "update-year", false, "report headers whose year isn't the current one")
+	// The fixes only insert or change comments.
+	fix.Register(Analyzer, fix.Safe)
+}
+
+var files = nodefilter.New(false, new(ast.File))
+
+// now returns the current time. Tests replace it.
+var now = time.Now
+
+// A header is a parsed template.
+type header struct {
+	text string         // the template
+	re   *regexp.Regexp // matching the normalized text of headers
+	year int            // submatch of the first year of the last {year}, or -1
+}
+
+func parseTemplate(text string) *header {
+	text = strings.TrimSpace(text)
+	var (
+		expr strings.Builder
+		year = -1
+	)
+	expr.WriteString("^")
+	for i, part := range strings.Split(normalize(text), "{year}") {
+		if i > 0 {
+			expr.WriteString(`(\d{4})(?:\s*-\s*(\d{4}))?`)
+			year = 2*i - 1
+		}
+		expr.WriteString(regexp.QuoteMeta(part))
+	}
+	expr.WriteString("$")
+	return &header{text, regexp.MustCompile(expr.String()), year}
+}
+
+// normalize collapses white space in s.
+func normalize(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+func run(pass *analysis.Pass) (interface{}, error) {
+	text := template
+	if templateFile != "" {
+		b, err := ioutil.ReadFile(templateFile)
+		if err != nil {
+			return nil, err
+		}
+		text = string(b)
+	}
+	if strings.TrimSpace(text) == "" {
+		return nil, nil
+	}
+	h := parseTemplate(text)
+
+	nodes := pass.ResultOf[nodefilter.Analyzer].(*nodefilter.Result)
+	for _, n := range nodes.Nodes(files) {
+		f := n.Node.(*ast.File)
+		if analysisutil.IsGenerated(pass, f.Pos()) {
+			continue
+		}
+		checkFile(pass, f, h)
+	}
+	return nil, nil
+}
+
+func checkFile(pass *analysis.Pass, f *ast.File, h *header) {
+	// Diagnostics are reported at the package clause, as the header is
+	// about the whole file.
+	cg := firstComment(f)
+	if cg == nil {
+		start := pass.Fset.File(f.Pos()).Pos(0)
+		pass.Report(analysis.Diagnostic{
+			Pos:     f.Package,
+			Message: "file does not start with the license header",
+			SuggestedFixes: []analysis.SuggestedFix{{
+				Message: "insert the license header",
+				TextEdits: []analysis.TextEdit{{
+					Pos:     start,
+					End:     start,
+					NewText: []byte(comment(strings.Replace(h.text, "{year}", strconv.Itoa(now().Year()), -1)) + "\n"),
+				}},
+			}},
+		})
+		return
+	}
+	m := h.re.FindStringSubmatchIndex(normalize(cg.Text()))
+	if m == nil {
+		pass.Reportf(f.Package, "license header does not match the template")
+		return
+	}
+	if !updateYear || h.year < 0 {
+		return
+	}
+	text := normalize(cg.Text())
+	first := text[m[2*h.year]:m[2*h.year+1]]
+	last := first
+	if i := 2 * (h.year + 1); m[i] >= 0 {
+		last = text[m[i]:m[i+1]]
+	}
+	year := strconv.Itoa(now().Year())
+	if last >= year {
+		return
+	}
+	d := analysis.Diagnostic{
+		Pos:      f.Package,
+		Category: "year",
+		Message:  "license header ends with year " + last + "; update it to " + first + "-" + year,
+	}
+	if e, ok := yearEdit(cg, first, last, year); ok {
+		d.SuggestedFixes = []analysis.SuggestedFix{{
+			Message:   "update the year to " + year,
+			TextEdits: []analysis.TextEdit{e},
+		}}
+	}
+	pass.Report(d)
+}
+
+// yearEdit returns an edit replacing the year range first-last in the
+// comments of cg by first-year.
+func yearEdit(cg *ast.CommentGroup, first, last, year string) (analysis.TextEdit, bool) {
+	old := regexp.MustCompile(`\b` + first + `(\s*-\s*` + last + `)?\b`)
+	if first == last {
+		old = regexp.MustCompile(`\b` + first + `\b`)
+	}
+	for _, c := range cg.List {
+		if loc := old.FindStringIndex(c.Text); loc != nil {
+			return analysis.TextEdit{
+				Pos:     c.Slash + token.Pos(loc[0]),
+				End:     c.Slash + token.Pos(loc[1]),
+				NewText: []byte(first + "-" + year),
+			}, true
+		}
+	}
+	return analysis.TextEdit{}, false
+}
+
+// firstComment returns the first comment group of f before the package
+// clause, which is neither the package documentation nor a build
+// constraint, or nil.
+func firstComment(f *ast.File) *ast.CommentGroup {
+	for _, cg := range f.Comments {
+		if cg.Pos() > f.Package {
+			break
+		}
+		if cg == f.Doc || isConstraint(cg) {
+			continue
+		}
+		return cg
+	}
+	return nil
+}
+
+func isConstraint(cg *ast.CommentGroup) bool {
+	for _, c := range cg.List {
+		if !strings.HasPrefix(c.Text, "// +build") && !strings.HasPrefix(c.Text, "//go:build") {
+			return false
+		}
+	}
+	return true
+}
+
+// comment returns text as line comments.
+func comment(text string) string {
+	var b strings.Builder
+	for _, l := range strings.Split(strings.TrimSpace(text), "\n") {
+		if l = strings.TrimRight(l, " \t"); l == "" {
+			b.WriteString("//\n")
+		} else {
+			b.WriteString("// " + l + "\n")
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,34 @@
+// Copyright 2019 Axel Wagner
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package licenseheader
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Merovius/go-tools/internal/analysistestx"
+)
+
+func TestLicenseHeader(t *testing.T) {
+	defer func(f func() time.Time) { now = f }(now)
+	now = func() time.Time { return time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC) }
+
+	tmpl := "Copyright {year} The Authors\n\nUse of this source code is governed by the LICENSE file."
+	analysistestx.RunMatrix(t, analysistestx.TestData(), Analyzer,
+		analysistestx.Case{Name: "none", Patterns: []string{"b"}},
+		analysistestx.Case{Name: "template", Flags: map[string]string{"template": tmpl}, Patterns: []string{"a"}, Fixes: true},
+		analysistestx.Case{Name: "update-year", Flags: map[string]string{"template": tmpl, "update-year": "true"}, Patterns: []string{"c"}, Fixes: true},
+	)
+}
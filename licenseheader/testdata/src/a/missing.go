@@ -0,0 +1 @@
+package a // want `file does not start with the license header`
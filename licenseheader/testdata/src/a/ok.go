@@ -0,0 +1,7 @@
+// Copyright 2019 The Authors
+//
+// Use of this source code is governed by the
+// LICENSE file.
+
+// Package a is documented.
+package a
@@ -0,0 +1,3 @@
+// Copyright 2019 Someone Else. All rights reserved.
+
+package a // want `license header does not match the template`
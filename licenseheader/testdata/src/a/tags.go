@@ -0,0 +1,9 @@
+// +build linux
+
+/*
+Copyright 2015-2019 The Authors
+
+Use of this source code is governed by the LICENSE file.
+*/
+
+package a
@@ -0,0 +1,5 @@
+// Copyright 2021 The Authors
+//
+// Use of this source code is governed by the LICENSE file.
+
+package c
@@ -0,0 +1,5 @@
+// Copyright 2015 - 2019 The Authors
+//
+// Use of this source code is governed by the LICENSE file.
+
+package c // want `license header ends with year 2019; update it to 2015-2021`
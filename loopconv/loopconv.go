@@ -0,0 +1,253 @@
+// Copyright 2019 Axel Wagner
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package loopconv defines an Analyzer that checks for conversions in loops
+// which can be hoisted or done cheaper.
+package loopconv
+
+import (
+	"go/ast"
+	"go/constant"
+	"go/token"
+	"go/types"
+
+	"github.com/Merovius/go-tools/internal/analysisutil"
+	"github.com/Merovius/go-tools/internal/nodefilter"
+	"golang.org/x/tools/go/analysis"
+)
+
+const Doc = `check for conversions in loops which can be hoisted or done cheaper
+
+The analyzer reports
+  - type assertions and type switches in loops on values which don't change
+    in the loop. The assertion can be done once, before the loop, and
+  - formatting of single integers, booleans and quoted strings with package
+    fmt in loops, which package strconv does faster. When the result is
+    appended to a byte slice, the Append functions of strconv avoid the
+    allocation of the intermediate string.`
+
+var Analyzer = &analysis.Analyzer{
+	Name: "loopconv",
+	Doc:  Doc,
+	Run:  run,
+	Requires: []*analysis.Analyzer{
+		nodefilter.Analyzer,
+	},
+}
+
+var nodes = nodefilter.New(true, new(ast.TypeAssertExpr), new(ast.CallExpr))
+
+func run(pass *analysis.Pass) (interface{}, error) {
+	res := pass.ResultOf[nodefilter.Analyzer].(*nodefilter.Result)
+	type key struct {
+		loop ast.Node
+		x    string
+	}
+	reported := make(map[key]bool)
+	for _, n := range res.Nodes(nodes) {
+		loop := enclosingLoop(n.Stack)
+		if loop == nil {
+			continue
+		}
+		switch x := n.Node.(type) {
+		case *ast.TypeAssertExpr:
+			k := key{loop, analysisutil.Render(pass.Fset, x.X)}
+			if reported[k] || !invariant(pass, loop, x.X) {
+				continue
+			}
+			reported[k] = true
+			what := "type assertion"
+			if x.Type == nil {
+				what = "type switch"
+			}
+			pass.Reportf(x.Pos(), "%s on %s in a loop, although %s doesn't change in it; assert once before the loop", what, k.x, k.x)
+		case *ast.CallExpr:
+			checkFormat(pass, x, n.Stack)
+		}
+	}
+	return nil, nil
+}
+
+// enclosingLoop returns the innermost loop enclosing the last node of
+// stack, in the same function.
+func enclosingLoop(stack []ast.Node) ast.Node {
+	for i := len(stack) - 2; i >= 0; i-- {
+		switch n := stack[i].(type) {
+		case *ast.FuncDecl, *ast.FuncLit:
+			return nil
+		case *ast.ForStmt:
+			if stack[i+1] == n.Body {
+				return n
+			}
+		case *ast.RangeStmt:
+			if stack[i+1] == n.Body {
+				return n
+			}
+		}
+	}
+	return nil
+}
+
+// invariant returns whether e is a variable or a field selected from one,
+// which is neither declared nor changed in loop.
+func invariant(pass *analysis.Pass, loop ast.Node, e ast.Expr) bool {
+	var root *ast.Ident
+	for root == nil {
+		switch x := analysisutil.Unparen(e).(type) {
+		case *ast.Ident:
+			root = x
+		case *ast.SelectorExpr:
+			if _, ok := pass.TypesInfo.Selections[x]; !ok {
+				return false
+			}
+			e = x.X
+		default:
+			return false
+		}
+	}
+	v, ok := pass.TypesInfo.Uses[root].(*types.Var)
+	if !ok || loop.Pos() <= v.Pos() && v.Pos() < loop.End() {
+		return false
+	}
+	changed := false
+	written := func(e ast.Expr) {
+		for {
+			switch x := analysisutil.Unparen(e).(type) {
+			case *ast.Ident:
+				if pass.TypesInfo.Uses[x] == v {
+					changed = true
+				}
+				return
+			case *ast.SelectorExpr:
+				e = x.X
+			case *ast.IndexExpr:
+				e = x.X
+			case *ast.StarExpr:
+				e = x.X
+			default:
+				return
+			}
+		}
+	}
+	ast.Inspect(loop, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.AssignStmt:
+			for _, l := range n.Lhs {
+				written(l)
+			}
+		case *ast.IncDecStmt:
+			written(n.X)
+		case *ast.UnaryExpr:
+			if n.Op == token.AND {
+				written(n.X)
+			}
+		case *ast.RangeStmt:
+			if n.Key != nil {
+				written(n.Key)
+			}
+			if n.Value != nil {
+				written(n.Value)
+			}
+		}
+		return !changed
+	})
+	return !changed
+}
+
+// checkFormat reports calls to fmt.Sprint and fmt.Sprintf formatting a
+// single value, which strconv can format.
+func checkFormat(pass *analysis.Pass, call *ast.CallExpr, stack []ast.Node) {
+	var arg ast.Expr
+	verb := "v"
+	switch {
+	case analysisutil.IsCall(pass.TypesInfo, call, "fmt.Sprint") && len(call.Args) == 1:
+		arg = call.Args[0]
+	case analysisutil.IsCall(pass.TypesInfo, call, "fmt.Sprintf") && len(call.Args) == 2:
+		tv := pass.TypesInfo.Types[call.Args[0]]
+		if tv.Value == nil || tv.Value.Kind() != constant.String {
+			return
+		}
+		f := constant.StringVal(tv.Value)
+		if len(f) != 2 || f[0] != '%' {
+			return
+		}
+		arg, verb = call.Args[1], f[1:]
+	default:
+		return
+	}
+	t := pass.TypesInfo.TypeOf(arg)
+	if t == nil {
+		return
+	}
+	b, ok := t.Underlying().(*types.Basic)
+	if !ok {
+		return
+	}
+	if _, ok := t.(*types.Named); ok {
+		// Named types might implement fmt.Stringer or fmt.Formatter.
+		return
+	}
+	var fn string
+	switch {
+	case b.Info()&types.IsInteger != 0 && (verb == "v" || verb == "d"):
+		switch {
+		case b.Kind() == types.Int:
+			fn = "Itoa"
+		case b.Info()&types.IsUnsigned != 0:
+			fn = "FormatUint"
+		default:
+			fn = "FormatInt"
+		}
+	case b.Info()&types.IsBoolean != 0 && (verb == "v" || verb == "t"):
+		fn = "FormatBool"
+	case b.Info()&types.IsString != 0 && verb == "q":
+		fn = "Quote"
+	default:
+		return
+	}
+	if appended(call, stack) {
+		fn = appendFuncs[fn]
+	}
+	pass.Report(analysis.Diagnostic{
+		Pos:      call.Pos(),
+		End:      call.End(),
+		Category: "strconv",
+		Message:  analysisutil.Render(pass.Fset, call) + " in a loop; strconv." + fn + " is faster",
+	})
+}
+
+// appendFuncs maps functions of strconv to their Append variants.
+var appendFuncs = map[string]string{
+	"Itoa":       "AppendInt",
+	"FormatInt":  "AppendInt",
+	"FormatUint": "AppendUint",
+	"FormatBool": "AppendBool",
+	"Quote":      "AppendQuote",
+}
+
+// appended returns whether call is appended to a byte slice, like in
+// append(b, call...).
+func appended(call *ast.CallExpr, stack []ast.Node) bool {
+	for i := len(stack) - 2; i >= 0; i-- {
+		switch n := stack[i].(type) {
+		case *ast.ParenExpr:
+			continue
+		case *ast.CallExpr:
+			id, ok := n.Fun.(*ast.Ident)
+			return ok && id.Name == "append" && n.Ellipsis.IsValid() && len(n.Args) == 2 && analysisutil.Unparen(n.Args[1]) == call
+		}
+		return false
+	}
+	return false
+}
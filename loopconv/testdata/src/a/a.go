@@ -0,0 +1,66 @@
+package a
+
+import (
+	"fmt"
+	"io"
+)
+
+type handler struct {
+	out interface{}
+}
+
+type ID int
+
+func assertions(h *handler, items []interface{}) {
+	for _, it := range items {
+		if w, ok := h.out.(io.Writer); ok { // want `type assertion on h.out in a loop, although h.out doesn't change in it; assert once before the loop`
+			fmt.Fprintln(w, it)
+		}
+		if c, ok := h.out.(io.Closer); ok {
+			c.Close()
+		}
+		switch v := it.(type) {
+		case string:
+			_ = v
+		}
+	}
+	out := h.out
+	for i := 0; i < 3; i++ {
+		switch out.(type) { // want `type switch on out in a loop, although out doesn't change in it`
+		case io.Writer:
+		}
+	}
+	var cur interface{}
+	for _, it := range items {
+		cur = it
+		_, _ = cur.(string)
+	}
+	_, _ = h.out.(io.Writer)
+}
+
+func formatting(ns []int, us []uint64, bs []bool, ss []string, ids []ID) []byte {
+	var b []byte
+	var out []string
+	for i, n := range ns {
+		out = append(out, fmt.Sprint(n))        // want `fmt.Sprint\(n\) in a loop; strconv.Itoa is faster`
+		out = append(out, fmt.Sprintf("%d", i)) // want `strconv.Itoa is faster`
+		b = append(b, fmt.Sprintf("%d", n)...)  // want `strconv.AppendInt is faster`
+		b = append(b, fmt.Sprintf("%x", n)...)
+		out = append(out, fmt.Sprintf("%d: %d", i, n))
+	}
+	for _, u := range us {
+		out = append(out, fmt.Sprint(u)) // want `strconv.FormatUint is faster`
+	}
+	for _, v := range bs {
+		b = append(b, fmt.Sprintf("%t", v)...) // want `strconv.AppendBool is faster`
+	}
+	for _, s := range ss {
+		out = append(out, fmt.Sprintf("%q", s)) // want `strconv.Quote is faster`
+		out = append(out, fmt.Sprint(s))
+	}
+	for _, id := range ids {
+		out = append(out, fmt.Sprint(id))
+	}
+	_ = fmt.Sprint(len(ns))
+	return b
+}
@@ -0,0 +1,181 @@
+// Copyright 2019 Axel Wagner
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package mainpkg defines an Analyzer that checks the structure of main
+// packages.
+package mainpkg
+
+import (
+	"go/ast"
+	"go/types"
+
+	"github.com/Merovius/go-tools/internal/analysisutil"
+	"github.com/Merovius/go-tools/internal/nodefilter"
+	"golang.org/x/tools/go/analysis"
+)
+
+const Doc = `check the structure of main packages
+
+Programs are easier to test and to read when main only sets up the
+configuration and reports the error returned by a function doing the actual
+work, like
+
+	func main() {
+		flag.Parse()
+		if err := run(context.Background(), flag.Args()); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+The analyzer reports
+  - main functions with more statements than given by the -max-statements
+    flag,
+  - functions other than main and init using the global flag set, which
+    mixes parsing the command line with the logic of the program,
+  - uses of os.Args outside of main and init, and
+  - programs serving HTTP or gRPC without handling signals or shutting down
+    the server, so that open requests are aborted when the program is
+    stopped.`
+
+var Analyzer = &analysis.Analyzer{
+	Name: "mainpkg",
+	Doc:  Doc,
+	Run:  run,
+	Requires: []*analysis.Analyzer{
+		nodefilter.Analyzer,
+	},
+}
+
+var maxStatements = 20
+
+func init() {
+	Analyzer.Flags.IntVar(&maxStatements, "max-statements", maxStatements, "maximum number of statements in main")
+}
+
+var (
+	funcs     = nodefilter.New(false, new(ast.FuncDecl))
+	calls     = nodefilter.New(true, new(ast.CallExpr))
+	selectors = nodefilter.New(true, new(ast.SelectorExpr))
+)
+
+// serves are functions serving network requests until they fail.
+var serves = []string{
+	"net/http.ListenAndServe",
+	"net/http.ListenAndServeTLS",
+	"net/http.Serve",
+	"net/http.ServeTLS",
+	"(*net/http.Server).ListenAndServe",
+	"(*net/http.Server).ListenAndServeTLS",
+	"(*net/http.Server).Serve",
+	"(*net/http.Server).ServeTLS",
+	"(*google.golang.org/grpc.Server).Serve",
+}
+
+// shutdowns are functions indicating a graceful shutdown of servers.
+var shutdowns = []string{
+	"os/signal.Notify",
+	"os/signal.NotifyContext",
+	"(*net/http.Server).Shutdown",
+	"(*google.golang.org/grpc.Server).GracefulStop",
+}
+
+func run(pass *analysis.Pass) (interface{}, error) {
+	if pass.Pkg.Name() != "main" {
+		return nil, nil
+	}
+	nodes := pass.ResultOf[nodefilter.Analyzer].(*nodefilter.Result)
+	for _, n := range nodes.Nodes(funcs) {
+		fn := n.Node.(*ast.FuncDecl)
+		if fn.Recv == nil && fn.Name.Name == "main" && fn.Body != nil {
+			checkMain(pass, fn)
+		}
+	}
+
+	var (
+		served   []*ast.CallExpr
+		shutdown bool
+	)
+	for _, n := range nodes.Nodes(calls) {
+		call := n.Node.(*ast.CallExpr)
+		switch {
+		case analysisutil.IsCall(pass.TypesInfo, call, serves...):
+			served = append(served, call)
+		case analysisutil.IsCall(pass.TypesInfo, call, shutdowns...):
+			shutdown = true
+		}
+		fn := analysisutil.Callee(pass.TypesInfo, call)
+		if fn == nil || fn.Pkg() == nil || fn.Pkg().Path() != "flag" || fn.Type().(*types.Signature).Recv() != nil || fn.Name() == "NewFlagSet" {
+			continue
+		}
+		if name := outerFunc(n.Stack); name != "" && name != "main" && name != "init" {
+			pass.Report(analysis.Diagnostic{
+				Pos:      call.Pos(),
+				Category: "flags",
+				Message:  "flag." + fn.Name() + " called in " + name + "; use the global flag set only in main and pass the values as arguments",
+			})
+		}
+	}
+	if !shutdown {
+		for _, call := range served {
+			pass.Report(analysis.Diagnostic{
+				Pos:      call.Pos(),
+				Category: "signal",
+				Message:  "server is not shut down gracefully; use signal.NotifyContext to shut it down when the program is stopped",
+			})
+		}
+	}
+
+	for _, n := range nodes.Nodes(selectors) {
+		sel := n.Node.(*ast.SelectorExpr)
+		v, ok := pass.TypesInfo.Uses[sel.Sel].(*types.Var)
+		if !ok || v.Pkg() == nil || v.Pkg().Path() != "os" || v.Name() != "Args" {
+			continue
+		}
+		if name := outerFunc(n.Stack); name != "" && name != "main" && name != "init" {
+			pass.Report(analysis.Diagnostic{
+				Pos:      sel.Pos(),
+				Category: "args",
+				Message:  "os.Args used in " + name + "; pass the arguments from main instead",
+			})
+		}
+	}
+	return nil, nil
+}
+
+// outerFunc returns the name of the function declaration in stack, or ""
+// at package level. Function literals belong to the function containing
+// them.
+func outerFunc(stack []ast.Node) string {
+	for _, n := range stack {
+		if fn, ok := n.(*ast.FuncDecl); ok {
+			return fn.Name.Name
+		}
+	}
+	return ""
+}
+
+func checkMain(pass *analysis.Pass, fn *ast.FuncDecl) {
+	n := 0
+	ast.Inspect(fn.Body, func(node ast.Node) bool {
+		switch node.(type) {
+		case *ast.BlockStmt:
+		case ast.Stmt:
+			n++
+		}
+		return true
+	})
+	if n > maxStatements {
+		pass.Reportf(fn.Name.Pos(), "main has %d statements; move the logic to a function like run(ctx context.Context) error and only report its error in main", n)
+	}
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+)
+
+var verbose = flag.Bool("v", false, "verbose output")
+
+func init() {
+	flag.StringVar(new(string), "name", "", "name")
+}
+
+func main() {
+	flag.Parse()
+	if err := run(flag.Args()); err != nil {
+		fmt.Fprintln(os.Stderr, os.Args[0], err)
+		os.Exit(1)
+	}
+	func() {
+		fmt.Println(os.Args)
+	}()
+}
+
+func run(args []string) error {
+	if flag.NArg() == 0 { // want `flag.NArg called in run; use the global flag set only in main`
+		return nil
+	}
+	fs := flag.NewFlagSet("sub", flag.ContinueOnError)
+	fs.Parse(args)
+	fmt.Println(os.Args[1:]) // want `os.Args used in run; pass the arguments from main instead`
+	return nil
+}
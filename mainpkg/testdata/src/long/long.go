@@ -0,0 +1,12 @@
+package main
+
+import "fmt"
+
+func main() { // want `main has 5 statements; move the logic to a function like run`
+	x := 1
+	if x > 0 {
+		fmt.Println(x)
+	}
+	x++
+	fmt.Println(x)
+}
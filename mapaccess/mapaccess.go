@@ -0,0 +1,283 @@
+// Copyright 2019 Axel Wagner
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package mapaccess defines an Analyzer that checks for inefficient or
+// ambiguous map lookups.
+package mapaccess
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+	"strconv"
+
+	"github.com/Merovius/go-tools/internal/analysisutil"
+	"github.com/Merovius/go-tools/internal/fix"
+	"github.com/Merovius/go-tools/internal/nodefilter"
+	"golang.org/x/tools/go/analysis"
+)
+
+const Doc = `check for double and ambiguous map lookups
+
+The analyzer reports
+  - conditions like m[k] != nil, whose branch looks up m[k] again. It
+    suggests a single lookup with the comma-ok form instead, which is
+    equivalent unless the map contains zero values, and
+  - comma-ok lookups ignoring ok, like v, _ := m[k], of maps whose zero value
+    is a plausible entry, like 0 or "". Missing keys can't be told apart from
+    such entries without checking ok.`
+
+var Analyzer = &analysis.Analyzer{
+	Name: "mapaccess",
+	Doc:  Doc,
+	Run:  run,
+	Requires: []*analysis.Analyzer{
+		nodefilter.Analyzer,
+	},
+}
+
+func init() {
+	// The fix changes behavior if the map contains zero values.
+	fix.Register(Analyzer, fix.Unverified)
+}
+
+var (
+	ifs     = nodefilter.New(false, new(ast.IfStmt))
+	assigns = nodefilter.New(false, new(ast.AssignStmt))
+)
+
+func run(pass *analysis.Pass) (interface{}, error) {
+	nodes := pass.ResultOf[nodefilter.Analyzer].(*nodefilter.Result)
+	for _, n := range nodes.Nodes(ifs) {
+		checkDouble(pass, n.Node.(*ast.IfStmt))
+	}
+	for _, n := range nodes.Nodes(assigns) {
+		checkIgnoredOK(pass, n.Node.(*ast.AssignStmt))
+	}
+	return nil, nil
+}
+
+// mapIndex returns e as a lookup in a map, if it is one whose map and key
+// have no side effects.
+func mapIndex(info *types.Info, e ast.Expr) (*ast.IndexExpr, bool) {
+	idx, ok := analysisutil.Unparen(e).(*ast.IndexExpr)
+	if !ok {
+		return nil, false
+	}
+	if t := info.TypeOf(idx.X); t == nil {
+		return nil, false
+	} else if _, ok := t.Underlying().(*types.Map); !ok {
+		return nil, false
+	}
+	return idx, pure(idx.X) && pure(idx.Index)
+}
+
+// pure returns whether evaluating e has no side effects and always results
+// in the same value while its variables don't change.
+func pure(e ast.Expr) bool {
+	switch e := e.(type) {
+	case *ast.Ident, *ast.BasicLit:
+		return true
+	case *ast.SelectorExpr:
+		return pure(e.X)
+	case *ast.ParenExpr:
+		return pure(e.X)
+	}
+	return false
+}
+
+func checkDouble(pass *analysis.Pass, s *ast.IfStmt) {
+	cond, ok := analysisutil.Unparen(s.Cond).(*ast.BinaryExpr)
+	if !ok || cond.Op != token.NEQ || s.Init != nil {
+		return
+	}
+	idx, ok := mapIndex(pass.TypesInfo, cond.X)
+	zero := cond.Y
+	if !ok {
+		if idx, ok = mapIndex(pass.TypesInfo, cond.Y); !ok {
+			return
+		}
+		zero = cond.X
+	}
+	if !isZero(pass.TypesInfo, zero) {
+		return
+	}
+	lookup := analysisutil.Render(pass.Fset, idx)
+	uses, ok := lookups(pass, s.Body, idx, lookup)
+	if !ok || len(uses) == 0 {
+		return
+	}
+	v, okName := freeName(pass, s.Body, "v"), freeName(pass, s.Body, "ok")
+	edits := []analysis.TextEdit{{
+		Pos:     s.Cond.Pos(),
+		End:     s.Cond.End(),
+		NewText: []byte(v + ", " + okName + " := " + lookup + "; " + okName),
+	}}
+	for _, u := range uses {
+		edits = append(edits, analysis.TextEdit{Pos: u.Pos(), End: u.End(), NewText: []byte(v)})
+	}
+	pass.Report(analysis.Diagnostic{
+		Pos:     cond.Pos(),
+		End:     cond.End(),
+		Message: lookup + " is looked up again in the branch; look it up once with " + v + ", " + okName + " := " + lookup,
+		SuggestedFixes: []analysis.SuggestedFix{{
+			Message:   "use comma-ok lookup",
+			TextEdits: edits,
+		}},
+	})
+}
+
+// isZero returns whether e is the zero value of its type.
+func isZero(info *types.Info, e ast.Expr) bool {
+	tv, ok := info.Types[e]
+	if !ok {
+		return false
+	}
+	if tv.IsNil() {
+		return true
+	}
+	if tv.Value == nil {
+		return false
+	}
+	switch s := tv.Value.String(); s {
+	case "0", `""`, "false":
+		return true
+	}
+	return false
+}
+
+// lookups returns the lookups in body equal to idx, rendered as lookup.
+// It returns false if body might change the map, the key or the entry, so
+// that the lookups can't be replaced by a variable.
+func lookups(pass *analysis.Pass, body *ast.BlockStmt, idx *ast.IndexExpr, lookup string) ([]*ast.IndexExpr, bool) {
+	vars := make(map[types.Object]bool)
+	ast.Inspect(idx, func(n ast.Node) bool {
+		if id, ok := n.(*ast.Ident); ok {
+			if obj := pass.TypesInfo.Uses[id]; obj != nil {
+				vars[obj] = true
+			}
+		}
+		return true
+	})
+	var (
+		uses []*ast.IndexExpr
+		ok   = true
+	)
+	written := func(e ast.Expr) {
+		if i, isIdx := mapIndex(pass.TypesInfo, e); isIdx && analysisutil.Render(pass.Fset, i) == lookup {
+			ok = false
+		}
+		if vars[analysisutil.ObjectOf(pass.TypesInfo, e)] {
+			ok = false
+		}
+	}
+	ast.Inspect(body, func(n ast.Node) bool {
+		if !ok {
+			return false
+		}
+		switch n := n.(type) {
+		case *ast.AssignStmt:
+			for _, l := range n.Lhs {
+				written(l)
+			}
+		case *ast.IncDecStmt:
+			written(n.X)
+		case *ast.UnaryExpr:
+			if n.Op == token.AND {
+				written(n.X)
+			}
+		case *ast.CallExpr:
+			if id, isIdent := n.Fun.(*ast.Ident); isIdent && id.Name == "delete" {
+				ok = false
+			}
+		case *ast.FuncLit:
+			// Closures might be called after the branch.
+			ok = false
+		case *ast.IndexExpr:
+			if i, isIdx := mapIndex(pass.TypesInfo, n); isIdx && analysisutil.Render(pass.Fset, i) == lookup {
+				uses = append(uses, i)
+				return false
+			}
+		}
+		return true
+	})
+	return uses, ok
+}
+
+// freeName returns name, or name with a number appended, so that it
+// doesn't shadow anything used in body.
+func freeName(pass *analysis.Pass, body *ast.BlockStmt, name string) string {
+	used := make(map[string]bool)
+	ast.Inspect(body, func(n ast.Node) bool {
+		if id, ok := n.(*ast.Ident); ok {
+			used[id.Name] = true
+		}
+		return true
+	})
+	scope := pass.Pkg.Scope().Innermost(body.Pos())
+	candidate := name
+	for i := 2; used[candidate] || scope != nil && lookupParent(scope, candidate, body.Pos()); i++ {
+		candidate = name + strconv.Itoa(i)
+	}
+	return candidate
+}
+
+func lookupParent(scope *types.Scope, name string, pos token.Pos) bool {
+	_, obj := scope.LookupParent(name, pos)
+	return obj != nil
+}
+
+func checkIgnoredOK(pass *analysis.Pass, as *ast.AssignStmt) {
+	if len(as.Lhs) != 2 || len(as.Rhs) != 1 {
+		return
+	}
+	if id, ok := as.Lhs[1].(*ast.Ident); !ok || id.Name != "_" {
+		return
+	}
+	idx, ok := analysisutil.Unparen(as.Rhs[0]).(*ast.IndexExpr)
+	if !ok {
+		return
+	}
+	t := pass.TypesInfo.TypeOf(idx.X)
+	if t == nil {
+		return
+	}
+	m, ok := t.Underlying().(*types.Map)
+	if !ok {
+		return
+	}
+	var zero string
+	switch u := m.Elem().Underlying().(type) {
+	case *types.Basic:
+		switch {
+		case u.Info()&types.IsNumeric != 0:
+			zero = "0"
+		case u.Info()&types.IsString != 0:
+			zero = `""`
+		case u.Info()&types.IsBoolean != 0:
+			zero = "false"
+		}
+	case *types.Struct:
+		zero = "the zero " + types.TypeString(m.Elem(), types.RelativeTo(pass.Pkg))
+	}
+	if zero == "" {
+		return
+	}
+	pass.Report(analysis.Diagnostic{
+		Pos:      as.Lhs[1].Pos(),
+		End:      as.Lhs[1].End(),
+		Category: "ignoredok",
+		Message:  "ok of the map lookup is ignored, but missing keys can't be told apart from entries with value " + zero + "; check ok",
+	})
+}
@@ -0,0 +1,65 @@
+package a
+
+type point struct{ x, y int }
+
+type server struct {
+	handlers map[string]func()
+}
+
+func double(m map[string]*int, s *server, name string) {
+	if m[name] != nil { // want `m\[name\] is looked up again in the branch; look it up once with v, ok := m\[name\]`
+		println(*m[name])
+	}
+	if nil != s.handlers["x"] { // want `s.handlers\["x"\] is looked up again`
+		s.handlers["x"]()
+	}
+	v := 1
+	if m[name] != nil { // want `look it up once with v2, ok := m\[name\]`
+		println(*m[name], v)
+	}
+}
+
+func counts(c map[string]int, k string) {
+	if c[k] != 0 { // want `c\[k\] is looked up again`
+		println(c[k] + c[k])
+	}
+}
+
+func noDouble(m map[string]*int, c map[string]int, name string, f func() string) {
+	if m[name] != nil {
+		println("ok")
+	}
+	if m[name] == nil {
+		println(m[name])
+	}
+	if c[name] != 1 {
+		println(c[name])
+	}
+	if m[f()] != nil {
+		println(m[f()])
+	}
+	if c[name] != 0 {
+		c[name]++
+		println(c[name])
+	}
+	if c[name] != 0 {
+		name = "other"
+		println(c[name])
+	}
+	if c[name] != 0 {
+		delete(c, name)
+		println(c[name])
+	}
+	if x := 1; c[name] != 0 {
+		println(c[name], x)
+	}
+}
+
+func ignored(c map[string]int, s map[string]string, p map[string]point, ptrs map[string]*int, k string) {
+	n, _ := c[k]   // want `ok of the map lookup is ignored, but missing keys can't be told apart from entries with value 0; check ok`
+	str, _ := s[k] // want `value ""`
+	pt, _ := p[k]  // want `value the zero point`
+	ptr, _ := ptrs[k]
+	v, ok := c[k]
+	println(n, str, pt.x, ptr, v, ok)
+}
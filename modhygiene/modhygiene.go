@@ -0,0 +1,155 @@
+// Copyright 2019 Axel Wagner
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package modhygiene defines an Analyzer that checks the go.mod file of the
+// module of a package for directives which should not be committed.
+package modhygiene
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/Merovius/go-tools/internal/gomod"
+	"golang.org/x/tools/go/analysis"
+)
+
+const Doc = `check go.mod files for directives which should not be committed
+
+The analyzer reports, at the lines of the go.mod file of the module,
+  - replace directives replacing a module by a directory outside of the
+    repository. They are usually left over from local development, and the
+    module can't be built anywhere else,
+  - requirements of versions retracted by their module, according to the
+    latest version of it in the module cache (category "retracted"), and
+  - requirements of +incompatible versions, whose major version doesn't
+    follow semantic import versioning (category "incompatible").
+
+The gotools driver passes the go.mod file as one of the OtherFiles of each
+package. With other drivers, it is looked up from the directory of the
+package. As all packages of a module share the go.mod file, the driver
+reports each diagnostic only once.`
+
+var Analyzer = &analysis.Analyzer{
+	Name: "modhygiene",
+	Doc:  Doc,
+	Run:  run,
+}
+
+func run(pass *analysis.Pass) (interface{}, error) {
+	path, err := goModFile(pass)
+	if path == "" || err != nil {
+		return nil, err
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	mod, err := gomod.Parse(path, data)
+	if err != nil {
+		return nil, err
+	}
+	tf := pass.Fset.AddFile(path, -1, len(data))
+	tf.SetLinesForContent(data)
+	report := func(line int, category, format string, args ...interface{}) {
+		pass.Report(analysis.Diagnostic{
+			Pos:      tf.LineStart(line),
+			Category: category,
+			Message:  fmt.Sprintf(format, args...),
+		})
+	}
+
+	dir := filepath.Dir(path)
+	root := repoRoot(dir)
+	for _, r := range mod.Replace {
+		if !r.Local() {
+			continue
+		}
+		target := r.New
+		if !filepath.IsAbs(target) {
+			target = filepath.Join(dir, target)
+		}
+		if !within(root, target) {
+			report(r.Line, "", "%s is replaced by %s, which is outside of the repository; the module can't be built anywhere else", r.Old, r.New)
+		}
+	}
+
+	cache := gomod.CacheDir()
+	for _, r := range mod.Require {
+		if strings.HasSuffix(r.Version, "+incompatible") {
+			major := strings.SplitN(strings.TrimPrefix(r.Version, "v"), ".", 2)[0]
+			report(r.Line, "incompatible", "%s %s is an +incompatible version: major version %s doesn't follow semantic import versioning; prefer a version with a go.mod file or the /v%s module path", r.Path, r.Version, major, major)
+		}
+		if cache == "" {
+			continue
+		}
+		retracts, err := gomod.Retractions(cache, r.Path)
+		if err != nil {
+			return nil, err
+		}
+		for _, rt := range retracts {
+			if !rt.Covers(r.Version) {
+				continue
+			}
+			msg := fmt.Sprintf("%s %s is retracted by its module", r.Path, r.Version)
+			if rt.Rationale != "" {
+				msg += " (" + rt.Rationale + ")"
+			}
+			report(r.Line, "retracted", "%s; upgrade to a version which is not retracted", msg)
+			break
+		}
+	}
+	return nil, nil
+}
+
+// goModFile returns the path of the go.mod file of the package, or "" if it
+// is not in a module.
+func goModFile(pass *analysis.Pass) (string, error) {
+	for _, name := range pass.OtherFiles {
+		if filepath.Base(name) == "go.mod" {
+			return name, nil
+		}
+	}
+	if len(pass.Files) == 0 {
+		return "", nil
+	}
+	mod, err := gomod.Find(filepath.Dir(pass.Fset.File(pass.Files[0].Pos()).Name()))
+	if mod == nil || err != nil {
+		return "", err
+	}
+	return mod.Path, nil
+}
+
+// repoRoot returns the root of the repository containing dir, which is the
+// closest directory with a .git entry. If there is none, dir is returned.
+func repoRoot(dir string) string {
+	for d := dir; ; {
+		if _, err := os.Stat(filepath.Join(d, ".git")); err == nil {
+			return d
+		}
+		parent := filepath.Dir(d)
+		if parent == d {
+			return dir
+		}
+		d = parent
+	}
+}
+
+// within returns whether path is dir or inside of it.
+func within(dir, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
@@ -0,0 +1,112 @@
+// Copyright 2019 Axel Wagner
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package modhygiene
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"golang.org/x/tools/go/analysis"
+)
+
+const goMod = `module example.com/m
+
+go 1.16
+
+require (
+	example.com/old v1.0.0
+	example.com/big v2.1.0+incompatible
+	example.com/fine v1.1.0
+)
+
+replace example.com/lib => ../lib
+
+replace example.com/dev => ../../dev
+
+replace example.com/fork => example.com/other v1.0.0
+`
+
+func TestModHygiene(t *testing.T) {
+	cache, err := filepath.Abs(filepath.Join("testdata", "modcache"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Setenv("GOMODCACHE", os.Getenv("GOMODCACHE"))
+	os.Setenv("GOMODCACHE", cache)
+
+	tmp, err := ioutil.TempDir("", "modhygiene")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+	root := filepath.Join(tmp, "repo")
+	dir := filepath.Join(root, "m")
+	for _, d := range []string{filepath.Join(root, ".git"), filepath.Join(root, "lib"), dir} {
+		if err := os.MkdirAll(d, 0777); err != nil {
+			t.Fatal(err)
+		}
+	}
+	goModPath := filepath.Join(dir, "go.mod")
+	if err := ioutil.WriteFile(goModPath, []byte(goMod), 0666); err != nil {
+		t.Fatal(err)
+	}
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, filepath.Join(dir, "m.go"), "package m\n", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{
+		`13 : example.com/dev is replaced by ../../dev, which is outside of the repository; the module can't be built anywhere else`,
+		`6 retracted: example.com/old v1.0.0 is retracted by its module (Published with a broken API.); upgrade to a version which is not retracted`,
+		`7 incompatible: example.com/big v2.1.0+incompatible is an +incompatible version: major version 2 doesn't follow semantic import versioning; prefer a version with a go.mod file or the /v2 module path`,
+	}
+	for _, tc := range []struct {
+		name  string
+		files []*ast.File
+		other []string
+	}{
+		{"OtherFiles", nil, []string{goModPath}},
+		{"Lookup", []*ast.File{f}, nil},
+	} {
+		var got []string
+		pass := &analysis.Pass{
+			Analyzer:   Analyzer,
+			Fset:       fset,
+			Files:      tc.files,
+			OtherFiles: tc.other,
+			Report: func(d analysis.Diagnostic) {
+				posn := fset.Position(d.Pos)
+				if posn.Filename != goModPath {
+					t.Errorf("%s: diagnostic in %s, want %s", tc.name, posn.Filename, goModPath)
+				}
+				got = append(got, fmt.Sprintf("%d %s: %s", posn.Line, d.Category, d.Message))
+			},
+		}
+		if _, err := Analyzer.Run(pass); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: got diagnostics\n%q\nwant\n%q", tc.name, got, want)
+		}
+	}
+}
@@ -0,0 +1,3 @@
+module example.com/fine
+
+retract v1.0.0
@@ -0,0 +1,4 @@
+module example.com/old
+
+// Published with a broken API.
+retract v1.0.0
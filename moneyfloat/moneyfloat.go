@@ -0,0 +1,228 @@
+// Copyright 2019 Axel Wagner
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package moneyfloat defines an Analyzer that checks for floating-point
+// arithmetic on amounts of money.
+package moneyfloat
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+	"strings"
+
+	"github.com/Merovius/go-tools/internal/analysisutil"
+	"github.com/Merovius/go-tools/internal/nodefilter"
+	"golang.org/x/tools/go/analysis"
+)
+
+const Doc = `check for floating-point arithmetic on amounts of money
+
+Floating-point numbers can't represent most decimal fractions exactly, so
+sums of prices drift by fractions of cents and rounding them gives surprising
+results. The analyzer reports arithmetic on floating-point variables and
+fields whose names suggest amounts of money, like price or amount, and struct
+fields of such names with floating-point types. Use integers counting the
+minor unit, like cents, or a decimal type instead.
+
+Names are split into words, which are compared with those given by the
+-names flag. Floating-point types named by the -allow-types flag, like
+types with a documented rounding policy, are not reported.`
+
+var Analyzer = &analysis.Analyzer{
+	Name: "moneyfloat",
+	Doc:  Doc,
+	Run:  run,
+	Requires: []*analysis.Analyzer{
+		nodefilter.Analyzer,
+	},
+}
+
+var (
+	names      = "amount,balance,cost,discount,fee,fees,money,payment,price,refund,revenue,salary,tax,wage"
+	allowTypes string
+)
+
+func init() {
+	Analyzer.Flags.StringVar(&names, "names", names, "comma-separated words of names suggesting amounts of money")
+	Analyzer.Flags.StringVar(&allowTypes, "allow-types", "", "comma-separated floating-point types which are not reported, like example.com/money.Rate")
+}
+
+var (
+	binaries = nodefilter.New(true, new(ast.BinaryExpr))
+	assigns  = nodefilter.New(false, new(ast.AssignStmt))
+	fields   = nodefilter.New(false, new(ast.Field))
+)
+
+var arithmetic = map[token.Token]bool{
+	token.ADD:        true,
+	token.SUB:        true,
+	token.MUL:        true,
+	token.QUO:        true,
+	token.ADD_ASSIGN: true,
+	token.SUB_ASSIGN: true,
+	token.MUL_ASSIGN: true,
+	token.QUO_ASSIGN: true,
+}
+
+// A checker holds the configuration of a run.
+type checker struct {
+	pass  *analysis.Pass
+	words map[string]bool
+	allow map[string]bool
+}
+
+func run(pass *analysis.Pass) (interface{}, error) {
+	c := &checker{
+		pass:  pass,
+		words: split(strings.ToLower(names)),
+		allow: split(allowTypes),
+	}
+	nodes := pass.ResultOf[nodefilter.Analyzer].(*nodefilter.Result)
+	for _, n := range nodes.Nodes(binaries) {
+		e := n.Node.(*ast.BinaryExpr)
+		if !c.isArithmetic(e) || c.isArithmetic(parent(n.Stack)) {
+			// Expressions are reported as a whole.
+			continue
+		}
+		if id := c.money(e); id != "" {
+			pass.Reportf(e.Pos(), "floating-point arithmetic on %s, which seems to be an amount of money; use integer minor units, like cents, or a decimal type", id)
+		}
+	}
+	for _, n := range nodes.Nodes(assigns) {
+		as := n.Node.(*ast.AssignStmt)
+		if !arithmetic[as.Tok] || len(as.Lhs) != 1 || !c.isFloat(c.pass.TypesInfo.TypeOf(as.Lhs[0])) {
+			continue
+		}
+		id := c.name(as.Lhs[0])
+		if id == "" && !c.isArithmetic(analysisutil.Unparen(as.Rhs[0])) {
+			// Arithmetic on the right is reported on its own.
+			id = c.money(as.Rhs[0])
+		}
+		if id != "" {
+			pass.Reportf(as.Pos(), "floating-point arithmetic on %s, which seems to be an amount of money; use integer minor units, like cents, or a decimal type", id)
+		}
+	}
+	for _, n := range nodes.Nodes(fields) {
+		c.checkField(n.Node.(*ast.Field))
+	}
+	return nil, nil
+}
+
+func split(s string) map[string]bool {
+	m := make(map[string]bool)
+	for _, w := range strings.Split(s, ",") {
+		if w = strings.TrimSpace(w); w != "" {
+			m[w] = true
+		}
+	}
+	return m
+}
+
+// parent returns the innermost node enclosing the last node of stack,
+// which isn't a parenthesized expression.
+func parent(stack []ast.Node) ast.Node {
+	for i := len(stack) - 2; i >= 0; i-- {
+		if _, ok := stack[i].(*ast.ParenExpr); !ok {
+			return stack[i]
+		}
+	}
+	return nil
+}
+
+func (c *checker) isFloat(t types.Type) bool {
+	if t == nil || c.allow[analysisutil.TypeName(t)] {
+		return false
+	}
+	b, ok := t.Underlying().(*types.Basic)
+	return ok && b.Info()&types.IsFloat != 0
+}
+
+func (c *checker) isArithmetic(n ast.Node) bool {
+	e, ok := n.(*ast.BinaryExpr)
+	return ok && arithmetic[e.Op] && c.isFloat(c.pass.TypesInfo.TypeOf(e))
+}
+
+// money returns the first operand of the arithmetic expression e, whose
+// name suggests an amount of money.
+func (c *checker) money(e ast.Expr) string {
+	e = analysisutil.Unparen(e)
+	switch x := e.(type) {
+	case *ast.BinaryExpr:
+		if !c.isArithmetic(x) {
+			return ""
+		}
+		if id := c.money(x.X); id != "" {
+			return id
+		}
+		return c.money(x.Y)
+	case *ast.UnaryExpr:
+		return c.money(x.X)
+	case *ast.CallExpr:
+		// Conversions to floating-point types.
+		if tv := c.pass.TypesInfo.Types[x.Fun]; tv.IsType() && len(x.Args) == 1 {
+			return c.money(x.Args[0])
+		}
+		return ""
+	}
+	if !c.isFloat(c.pass.TypesInfo.TypeOf(e)) {
+		return ""
+	}
+	return c.name(e)
+}
+
+// name returns the rendered identifier or selector e, if its name suggests
+// an amount of money.
+func (c *checker) name(e ast.Expr) string {
+	var id *ast.Ident
+	switch x := analysisutil.Unparen(e).(type) {
+	case *ast.Ident:
+		id = x
+	case *ast.SelectorExpr:
+		id = x.Sel
+	default:
+		return ""
+	}
+	if !c.isMoney(id.Name) {
+		return ""
+	}
+	return analysisutil.Render(c.pass.Fset, e)
+}
+
+func (c *checker) isMoney(name string) bool {
+	for _, w := range analysisutil.Words(name) {
+		if c.words[w] {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *checker) checkField(f *ast.Field) {
+	if !c.isFloat(c.pass.TypesInfo.TypeOf(f.Type)) {
+		return
+	}
+	for _, name := range f.Names {
+		v, ok := c.pass.TypesInfo.Defs[name].(*types.Var)
+		if !ok || !v.IsField() || !c.isMoney(name.Name) {
+			continue
+		}
+		c.pass.Report(analysis.Diagnostic{
+			Pos:      name.Pos(),
+			End:      name.End(),
+			Category: "field",
+			Message:  "field " + name.Name + " seems to be an amount of money, but has floating-point type " + types.TypeString(v.Type(), types.RelativeTo(c.pass.Pkg)) + "; use integer minor units, like cents, or a decimal type",
+		})
+	}
+}
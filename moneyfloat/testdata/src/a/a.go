@@ -0,0 +1,45 @@
+package a
+
+type Item struct {
+	Name       string
+	UnitPrice  float64 // want `field UnitPrice seems to be an amount of money, but has floating-point type float64; use integer minor units, like cents, or a decimal type`
+	Quantity   int
+	Weight     float64
+	PriceCents int64
+}
+
+type Order struct {
+	Items                 []Item
+	Discount, ShippingFee float32 // want `field Discount seems to be` `field ShippingFee seems to be`
+}
+
+func total(o Order, taxRate float64) float64 {
+	var sum float64
+	for _, it := range o.Items {
+		sum += it.UnitPrice * float64(it.Quantity) // want `floating-point arithmetic on it.UnitPrice, which seems to be an amount of money; use integer minor units, like cents, or a decimal type`
+	}
+	sum -= float64(o.Discount)                            // want `floating-point arithmetic on o.Discount`
+	return (sum + float64(o.ShippingFee)) * (1 + taxRate) // want `floating-point arithmetic on o.ShippingFee`
+}
+
+func weights(items []Item) float64 {
+	var w float64
+	for _, it := range items {
+		w += it.Weight * float64(it.Quantity)
+	}
+	return w
+}
+
+func cents(items []Item) int64 {
+	var sum int64
+	for _, it := range items {
+		sum += it.PriceCents * int64(it.Quantity)
+	}
+	return sum
+}
+
+func balance(amount float64) {
+	var balance float64
+	balance += 1.5 // want `floating-point arithmetic on balance`
+	_ = amount / 3 // want `floating-point arithmetic on amount`
+}
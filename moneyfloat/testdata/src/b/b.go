@@ -0,0 +1,9 @@
+package b
+
+type Ratio float64
+
+func scores(score float64, price float64, scoreRatio Ratio) {
+	_ = score * 2 // want `floating-point arithmetic on score`
+	_ = price * 2
+	_ = scoreRatio * 2
+}
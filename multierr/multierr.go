@@ -0,0 +1,355 @@
+// Copyright 2019 Axel Wagner
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package multierr defines an Analyzer that checks for failures reported in
+// several results or joined errors which are handled only partially.
+package multierr
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+
+	"github.com/Merovius/go-tools/internal/analysisutil"
+	"github.com/Merovius/go-tools/internal/nodefilter"
+	"golang.org/x/tools/go/analysis"
+)
+
+const Doc = `check for failures reported in several results or joined errors handled only partially
+
+Some functions report failures in more than one result, like two errors, or
+a value with an error and a bool telling whether the value is valid, like
+(T, error, bool). The analyzer reports calls checking some of these results,
+but assigning others to _: either the discarded result doesn't matter and the
+function should not return it, or the failure it reports goes unnoticed. A
+bool and an error without another result, like the (bool, error) of
+path.Match, are a result and its failure, not two failures.
+
+The analyzer also reports errors created by errors.Join, which are only
+compared to nil. A joined error is returned by a function to report several
+failures, so callers are expected to inspect them, with errors.Is,
+errors.As, a type assertion or by printing them. Joined errors are followed
+through return statements of functions, in the same and in other packages,
+so the report is at the call site finally dropping them. An error which is
+printed, wrapped or passed to another function counts as handled.`
+
+var Analyzer = &analysis.Analyzer{
+	Name: "multierr",
+	Doc:  Doc,
+	Run:  run,
+	Requires: []*analysis.Analyzer{
+		nodefilter.Analyzer,
+	},
+	FactTypes: []analysis.Fact{new(joinFact)},
+}
+
+var (
+	funcs   = nodefilter.New(false, new(ast.FuncDecl))
+	assigns = nodefilter.New(false, new(ast.AssignStmt), new(ast.ValueSpec))
+)
+
+var errorType = types.Universe.Lookup("error").Type()
+
+// joinFact is an object fact of functions returning an error created by
+// errors.Join, directly or through other functions.
+type joinFact struct {
+	Index int    // of the result
+	Via   string // the called function creating the error
+}
+
+func (*joinFact) AFact() {}
+
+func (f *joinFact) String() string {
+	return fmt.Sprintf("result %d joins errors via %s", f.Index, f.Via)
+}
+
+func run(pass *analysis.Pass) (interface{}, error) {
+	nodes := pass.ResultOf[nodefilter.Analyzer].(*nodefilter.Result)
+	for _, n := range nodes.Nodes(assigns) {
+		lhs, rhs := assignment(n.Node)
+		if len(rhs) != 1 {
+			continue
+		}
+		if call, ok := analysisutil.Unparen(rhs[0]).(*ast.CallExpr); ok {
+			checkResults(pass, call, lhs)
+		}
+	}
+
+	// Determine the functions of the package returning joined errors. As
+	// they can call each other, repeat until nothing changes.
+	c := &checker{pass: pass, joins: make(map[*types.Func]*joinFact)}
+	var decls []*ast.FuncDecl
+	for _, n := range nodes.Nodes(funcs) {
+		if fn := n.Node.(*ast.FuncDecl); fn.Body != nil {
+			decls = append(decls, fn)
+		}
+	}
+	for changed := true; changed; {
+		changed = false
+		for _, fn := range decls {
+			obj, ok := pass.TypesInfo.Defs[fn.Name].(*types.Func)
+			if !ok || c.joins[obj] != nil {
+				continue
+			}
+			if f := c.returnsJoined(fn); f != nil {
+				c.joins[obj] = f
+				changed = true
+			}
+		}
+	}
+	for obj, f := range c.joins {
+		pass.ExportObjectFact(obj, f)
+	}
+	for _, fn := range decls {
+		c.checkDropped(fn.Body)
+	}
+	return nil, nil
+}
+
+// assignment returns the left and right hand sides of an assignment or
+// variable declaration.
+func assignment(n ast.Node) (lhs, rhs []ast.Expr) {
+	switch n := n.(type) {
+	case *ast.AssignStmt:
+		return n.Lhs, n.Rhs
+	case *ast.ValueSpec:
+		for _, id := range n.Names {
+			lhs = append(lhs, id)
+		}
+		return lhs, n.Values
+	}
+	return nil, nil
+}
+
+// checkResults reports results of call reporting failures, which are
+// assigned to _ while others are checked.
+func checkResults(pass *analysis.Pass, call *ast.CallExpr, lhs []ast.Expr) {
+	sig, ok := pass.TypesInfo.TypeOf(call.Fun).(*types.Signature)
+	if !ok || sig.Results().Len() != len(lhs) || len(lhs) < 2 {
+		return
+	}
+	var (
+		errs, bools int
+		kinds       = make([]string, len(lhs))
+	)
+	for i := range lhs {
+		t := sig.Results().At(i).Type()
+		switch {
+		case types.Identical(t, errorType):
+			kinds[i] = "error"
+			errs++
+		case types.Identical(t.Underlying(), types.Typ[types.Bool]):
+			kinds[i] = "bool"
+			bools++
+		}
+	}
+	// Several errors, or a value with an error and a bool.
+	if errs < 2 && (errs == 0 || bools == 0 || errs+bools == len(lhs)) {
+		return
+	}
+	var checked, discarded []int
+	for i, e := range lhs {
+		if kinds[i] == "" {
+			continue
+		}
+		if isBlank(e) {
+			discarded = append(discarded, i)
+		} else {
+			checked = append(checked, i)
+		}
+	}
+	if len(checked) == 0 {
+		return
+	}
+	name := "the function"
+	if fn := analysisutil.Callee(pass.TypesInfo, call); fn != nil {
+		name = fn.Name()
+	}
+	for _, i := range discarded {
+		pass.Reportf(lhs[i].Pos(), "%s reports failures in several results, but its %s result %d is discarded while the others are checked", name, kinds[i], i+1)
+	}
+}
+
+func isBlank(e ast.Expr) bool {
+	id, ok := e.(*ast.Ident)
+	return ok && id.Name == "_"
+}
+
+type checker struct {
+	pass  *analysis.Pass
+	joins map[*types.Func]*joinFact
+}
+
+// funcJoins returns the fact of fn, if it returns a joined error.
+func (c *checker) funcJoins(fn *types.Func) *joinFact {
+	if f, ok := c.joins[fn]; ok {
+		return f
+	}
+	f := new(joinFact)
+	if fn.Pkg() == c.pass.Pkg || !c.pass.ImportObjectFact(fn, f) {
+		return nil
+	}
+	return f
+}
+
+// source returns the function creating the joined error returned by call as
+// its result i, or "".
+func (c *checker) source(call *ast.CallExpr, i int) string {
+	fn := analysisutil.Callee(c.pass.TypesInfo, call)
+	if fn == nil {
+		return ""
+	}
+	if analysisutil.FuncName(fn) == "errors.Join" {
+		if i == 0 {
+			return "errors.Join"
+		}
+		return ""
+	}
+	if f := c.funcJoins(fn); f != nil && f.Index == i {
+		return fn.Name()
+	}
+	return ""
+}
+
+// A joined is a local variable holding a joined error.
+type joined struct {
+	via  string
+	call *ast.CallExpr // creating the error
+}
+
+// joinedVars returns the local variables in body assigned a joined error.
+func (c *checker) joinedVars(body *ast.BlockStmt) map[*types.Var]joined {
+	vars := make(map[*types.Var]joined)
+	ast.Inspect(body, func(n ast.Node) bool {
+		if _, ok := n.(*ast.FuncLit); ok {
+			return false
+		}
+		lhs, rhs := assignment(n)
+		for i, e := range rhs {
+			call, ok := analysisutil.Unparen(e).(*ast.CallExpr)
+			if !ok {
+				continue
+			}
+			if len(rhs) == 1 && len(lhs) > 1 {
+				// All results of a single call.
+				for j, l := range lhs {
+					c.addJoined(vars, l, call, j)
+				}
+			} else if i < len(lhs) {
+				c.addJoined(vars, lhs[i], call, 0)
+			}
+		}
+		return true
+	})
+	return vars
+}
+
+func (c *checker) addJoined(vars map[*types.Var]joined, lhs ast.Expr, call *ast.CallExpr, i int) {
+	v, ok := analysisutil.ObjectOf(c.pass.TypesInfo, lhs).(*types.Var)
+	if !ok || v.Parent() == c.pass.Pkg.Scope() {
+		return
+	}
+	if _, ok := vars[v]; ok {
+		return
+	}
+	if via := c.source(call, i); via != "" {
+		vars[v] = joined{via, call}
+	}
+}
+
+// returnsJoined returns the fact of the function fn, if it returns a joined
+// error.
+func (c *checker) returnsJoined(fn *ast.FuncDecl) *joinFact {
+	vars := c.joinedVars(fn.Body)
+	sig := c.pass.TypesInfo.Defs[fn.Name].Type().(*types.Signature)
+	var out *joinFact
+	ast.Inspect(fn.Body, func(n ast.Node) bool {
+		if _, ok := n.(*ast.FuncLit); ok || out != nil {
+			return false
+		}
+		ret, ok := n.(*ast.ReturnStmt)
+		if !ok {
+			return true
+		}
+		if len(ret.Results) == 1 && sig.Results().Len() > 1 {
+			// return f(), forwarding all results.
+			if call, ok := analysisutil.Unparen(ret.Results[0]).(*ast.CallExpr); ok {
+				for i := 0; i < sig.Results().Len(); i++ {
+					if via := c.source(call, i); via != "" {
+						out = &joinFact{i, via}
+					}
+				}
+			}
+			return false
+		}
+		for i, e := range ret.Results {
+			if !types.Identical(sig.Results().At(i).Type(), errorType) {
+				continue
+			}
+			e = analysisutil.Unparen(e)
+			if call, ok := e.(*ast.CallExpr); ok {
+				if via := c.source(call, 0); via != "" {
+					out = &joinFact{i, via}
+				}
+			} else if v, ok := analysisutil.ObjectOf(c.pass.TypesInfo, e).(*types.Var); ok {
+				if j, ok := vars[v]; ok {
+					out = &joinFact{i, j.via}
+				}
+			}
+		}
+		return false
+	})
+	return out
+}
+
+// checkDropped reports joined errors in body, which are only compared to
+// nil.
+func (c *checker) checkDropped(body *ast.BlockStmt) {
+	vars := c.joinedVars(body)
+	if len(vars) == 0 {
+		return
+	}
+	uses := make(map[*types.Var]int)
+	nilChecks := make(map[*types.Var]int)
+	ast.Inspect(body, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.Ident:
+			if v, ok := c.pass.TypesInfo.Uses[n].(*types.Var); ok {
+				uses[v]++
+			}
+		case *ast.BinaryExpr:
+			if n.Op != token.EQL && n.Op != token.NEQ {
+				break
+			}
+			for _, pair := range [][2]ast.Expr{{n.X, n.Y}, {n.Y, n.X}} {
+				v, ok := analysisutil.ObjectOf(c.pass.TypesInfo, pair[0]).(*types.Var)
+				if ok && c.pass.TypesInfo.Types[pair[1]].IsNil() {
+					nilChecks[v]++
+				}
+			}
+		}
+		return true
+	})
+	for v, j := range vars {
+		if uses[v] == 0 || uses[v] != nilChecks[v] {
+			continue
+		}
+		what := "the error returned by " + j.via
+		if j.via == "errors.Join" {
+			what = "the error created by errors.Join"
+		}
+		c.pass.Reportf(j.call.Pos(), "%s joins several errors, but %s is only compared to nil; inspect the joined errors with errors.Is or errors.As or print them", what, v.Name())
+	}
+}
@@ -0,0 +1,105 @@
+package a
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+)
+
+func two() (int, error, error) { return 0, nil, nil }
+
+func lookup() (string, error, bool) { return "", nil, false }
+
+func pair() (int, bool) { return 0, false }
+
+func match() (bool, error) { return false, nil }
+
+func results() {
+	_, err, _ := two() // want `two reports failures in several results, but its error result 3 is discarded while the others are checked`
+	fmt.Println(err)
+
+	v, err, _ := lookup() // want `lookup reports failures in several results, but its bool result 3 is discarded`
+	fmt.Println(v, err)
+
+	s, _, ok := lookup() // want `lookup reports failures in several results, but its error result 2 is discarded`
+	fmt.Println(s, ok)
+
+	var n, _, e2 = two() // want `two reports failures in several results, but its error result 2 is discarded`
+	fmt.Println(n, e2)
+
+	// All failures checked, or all ignored.
+	_, e1, e3 := two()
+	fmt.Println(e1, e3)
+	two()
+	_, _, _ = two()
+
+	// Only one failure result.
+	x, _ := pair()
+	fmt.Println(x)
+
+	// A bool result and its failure.
+	m, _ := match()
+	_, err = match()
+	fmt.Println(m, err)
+}
+
+// Validate joins the errors of all checks.
+func Validate() error { // want Validate:"result 0 joins errors via errors.Join"
+	var errs []error
+	if _, err := os.Stat("x"); err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+// Load forwards the joined error of Validate.
+func Load() (int, error) { // want Load:"result 1 joins errors via Validate"
+	err := Validate()
+	if err != nil {
+		return 0, err
+	}
+	return 1, nil
+}
+
+// Forward returns all results of Load.
+func Forward() (int, error) { // want Forward:"result 1 joins errors via Load"
+	return Load()
+}
+
+func dropped() {
+	err := Validate() // want `the error returned by Validate joins several errors, but err is only compared to nil; inspect the joined errors with errors.Is or errors.As or print them`
+	if err != nil {
+		os.Exit(1)
+	}
+
+	if _, err := Load(); err != nil { // want `the error returned by Load joins several errors, but err is only compared to nil`
+		return
+	}
+
+	joined := errors.Join(errors.New("a"), errors.New("b")) // want `the error created by errors.Join joins several errors`
+	if nil == joined {
+		return
+	}
+}
+
+func handled() {
+	if err := Validate(); err != nil {
+		log.Print(err)
+	}
+	if err := Validate(); errors.Is(err, os.ErrNotExist) {
+		return
+	}
+	_, err := Load()
+	var pe *os.PathError
+	if errors.As(err, &pe) {
+		return
+	}
+	if err := Validate(); err != nil {
+		panic(fmt.Errorf("validating: %w", err))
+	}
+	// Compared to nil, but not assigned to a variable.
+	if Validate() != nil {
+		return
+	}
+}
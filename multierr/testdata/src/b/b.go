@@ -0,0 +1,22 @@
+package b
+
+import (
+	"a"
+	"fmt"
+)
+
+func Run() (int, error) { // want Run:"result 1 joins errors via Forward"
+	n, err := a.Forward()
+	return n, err
+}
+
+func check() bool {
+	_, err := a.Load() // want `the error returned by Load joins several errors, but err is only compared to nil`
+	return err == nil
+}
+
+func print() {
+	if _, err := Run(); err != nil {
+		fmt.Println(err)
+	}
+}
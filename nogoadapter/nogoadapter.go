@@ -0,0 +1,86 @@
+// Copyright 2019 Axel Wagner
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package nogoadapter exposes the analyzers of this repository in the shape
+// expected by the nogo framework of rules_go, so Bazel users can enable them
+// per target.
+//
+// nogo expects each analyzer in its own library, exporting an Analyzer
+// variable. The analyzer packages of this repository already satisfy that,
+// so this package mainly provides the ordered list of analyzers and a
+// default configuration, to generate BUILD files and nogo config files from.
+package nogoadapter
+
+import (
+	"encoding/json"
+	"io"
+
+	"github.com/Merovius/go-tools/internal/all"
+	"golang.org/x/tools/go/analysis"
+)
+
+// Analyzers returns all analyzers, in the order nogo should run them.
+func Analyzers() []*analysis.Analyzer {
+	return append([]*analysis.Analyzer(nil), all.Analyzers...)
+}
+
+// AnalyzerConfig is the configuration of a single analyzer, as understood
+// by nogo. The keys of OnlyFiles and ExcludeFiles are regular expressions
+// matched against file paths, the values are comments explaining them.
+type AnalyzerConfig struct {
+	Description   string            `json:"description,omitempty"`
+	OnlyFiles     map[string]string `json:"only_files,omitempty"`
+	ExcludeFiles  map[string]string `json:"exclude_files,omitempty"`
+	AnalyzerFlags map[string]string `json:"analyzer_flags,omitempty"`
+}
+
+// Config maps analyzer names to their configuration. It is the format of
+// the JSON file passed as config to the nogo rule.
+type Config map[string]AnalyzerConfig
+
+// DefaultConfig returns the configuration to use with the analyzers of this
+// repository. It excludes external repositories and generated files, which
+// users can't fix.
+func DefaultConfig() Config {
+	c := make(Config)
+	for _, a := range all.Analyzers {
+		c[a.Name] = AnalyzerConfig{
+			Description: firstLine(a.Doc),
+			ExcludeFiles: map[string]string{
+				"external/":   "third party code",
+				"\\.pb\\.go$": "generated protobuf code",
+			},
+		}
+	}
+	return c
+}
+
+// Write writes c in the JSON format read by nogo.
+func (c Config) Write(w io.Writer) error {
+	b, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(b, '\n'))
+	return err
+}
+
+func firstLine(s string) string {
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\n' {
+			return s[:i]
+		}
+	}
+	return s
+}
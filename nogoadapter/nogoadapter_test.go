@@ -0,0 +1,54 @@
+// Copyright 2019 Axel Wagner
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nogoadapter
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestAnalyzers(t *testing.T) {
+	as := Analyzers()
+	if len(as) == 0 {
+		t.Fatal("Analyzers() returned no analyzers")
+	}
+	if !sort.SliceIsSorted(as, func(i, j int) bool { return as[i].Name < as[j].Name }) {
+		t.Error("Analyzers() is not sorted by name")
+	}
+}
+
+func TestDefaultConfig(t *testing.T) {
+	c := DefaultConfig()
+	for _, a := range Analyzers() {
+		if _, ok := c[a.Name]; !ok {
+			t.Errorf("DefaultConfig() does not configure %s", a.Name)
+		}
+	}
+
+	buf := new(bytes.Buffer)
+	if err := c.Write(buf); err != nil {
+		t.Fatal(err)
+	}
+	var got Config
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, c) {
+		t.Errorf("Config does not round-trip:\ngot  %v\nwant %v", got, c)
+	}
+}
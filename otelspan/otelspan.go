@@ -0,0 +1,278 @@
+// Copyright 2019 Axel Wagner
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package otelspan defines an Analyzer that checks the lifecycle of
+// OpenTelemetry spans.
+package otelspan
+
+import (
+	"go/ast"
+
+	"github.com/Merovius/go-tools/internal/analysisutil"
+	"github.com/Merovius/go-tools/internal/nodefilter"
+	"github.com/Merovius/go-tools/internal/taint"
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/passes/buildssa"
+	"golang.org/x/tools/go/ssa"
+)
+
+const Doc = `check the lifecycle of OpenTelemetry spans
+
+The analyzer reports
+  - spans started with Tracer.Start, which are not ended on all paths
+    returning from the function. They are never exported and leak memory.
+    Defer span.End right after starting the span,
+  - spans ended twice, like with a deferred and an explicit call of End,
+  - attributes, events or a status set on a span after End, which the span
+    ignores, and
+  - contexts returned by Start, which are discarded, or while the context
+    passed to Start is passed to calls afterwards. Spans started by called
+    functions are then not children of the span.
+
+Spans which are passed to other functions, returned or stored are not
+checked for End.`
+
+var Analyzer = &analysis.Analyzer{
+	Name: "otelspan",
+	Doc:  Doc,
+	Run:  run,
+	Requires: []*analysis.Analyzer{
+		buildssa.Analyzer,
+		nodefilter.Analyzer,
+	},
+}
+
+const (
+	tracePkg = "go.opentelemetry.io/otel/trace"
+	start    = "(" + tracePkg + ".Tracer).Start"
+)
+
+var assigns = nodefilter.New(true, new(ast.AssignStmt))
+
+// updates are the methods of spans changing what is exported.
+var updates = map[string]bool{
+	"AddEvent":      true,
+	"AddLink":       true,
+	"RecordError":   true,
+	"SetAttributes": true,
+	"SetName":       true,
+	"SetStatus":     true,
+}
+
+func run(pass *analysis.Pass) (interface{}, error) {
+	if !analysisutil.Imports(pass.Pkg, tracePkg) {
+		return nil, nil
+	}
+	ssainfo := pass.ResultOf[buildssa.Analyzer].(*buildssa.SSA)
+	for _, fn := range ssainfo.SrcFuncs {
+		for _, b := range fn.Blocks {
+			for _, instr := range b.Instrs {
+				if call, ok := instr.(*ssa.Call); ok && taint.Callee(&call.Call) == start {
+					checkSpan(pass, call)
+				}
+			}
+		}
+	}
+
+	nodes := pass.ResultOf[nodefilter.Analyzer].(*nodefilter.Result)
+	for _, n := range nodes.Nodes(assigns) {
+		as := n.Node.(*ast.AssignStmt)
+		if len(as.Lhs) != 2 || len(as.Rhs) != 1 {
+			continue
+		}
+		call, ok := analysisutil.Unparen(as.Rhs[0]).(*ast.CallExpr)
+		if !ok || !analysisutil.IsCall(pass.TypesInfo, call, start) || len(call.Args) == 0 {
+			continue
+		}
+		_, body := analysisutil.EnclosingFunc(n.Stack)
+		checkContext(pass, as, call, body)
+	}
+	return nil, nil
+}
+
+// A spanUse is a call of a method of a span.
+type spanUse struct {
+	instr  ssa.Instruction
+	method string
+}
+
+// checkSpan checks the span started by call.
+func checkSpan(pass *analysis.Pass, call *ssa.Call) {
+	var span ssa.Value
+	for _, ref := range *call.Referrers() {
+		if e, ok := ref.(*ssa.Extract); ok && e.Index == 1 {
+			span = e
+		}
+	}
+	if span == nil || len(*span.Referrers()) == 0 {
+		pass.Reportf(call.Pos(), "span is discarded, so it is never ended; call End on it, usually deferred right after Start")
+		return
+	}
+	var (
+		ends     []ssa.Instruction
+		uses     []spanUse
+		deferred bool
+	)
+	for _, ref := range *span.Referrers() {
+		var c *ssa.CallCommon
+		switch ref := ref.(type) {
+		case *ssa.Call:
+			c = &ref.Call
+		case *ssa.Defer:
+			c = &ref.Call
+		default:
+			// The span escapes.
+			return
+		}
+		if !c.IsInvoke() || c.Value != span {
+			return
+		}
+		if _, ok := ref.(*ssa.Defer); ok {
+			if c.Method.Name() == "End" {
+				deferred = true
+			}
+			continue
+		}
+		uses = append(uses, spanUse{ref, c.Method.Name()})
+		if c.Method.Name() == "End" {
+			ends = append(ends, ref)
+		}
+	}
+	if deferred {
+		for _, e := range ends {
+			pass.Reportf(e.Pos(), "span is ended twice: End is deferred and called explicitly")
+		}
+		return
+	}
+	if len(ends) == 0 {
+		pass.Reportf(call.Pos(), "span is never ended, so it isn't exported and leaks; defer span.End right after Start")
+		return
+	}
+	if reachesReturn(call, ends) {
+		pass.Reportf(call.Pos(), "span is not ended on all paths returning from the function; defer span.End right after Start")
+	}
+	for _, e := range ends {
+		for _, u := range uses {
+			if u.instr == e || !reaches(call, e, u.instr) {
+				continue
+			}
+			if u.method == "End" {
+				pass.Reportf(u.instr.Pos(), "span is ended twice; the second End has no effect")
+			} else if updates[u.method] {
+				pass.Reportf(u.instr.Pos(), "%s after the span is ended has no effect; call it before End", u.method)
+			}
+		}
+	}
+}
+
+// index returns the index of instr in its block.
+func index(instr ssa.Instruction) int {
+	for i, in := range instr.Block().Instrs {
+		if in == instr {
+			return i
+		}
+	}
+	return -1
+}
+
+// reachesReturn returns whether a return of the function is reachable from
+// the start call, without passing one of ends.
+func reachesReturn(start ssa.Instruction, ends []ssa.Instruction) bool {
+	blocked := make(map[*ssa.BasicBlock]int) // index of the first end
+	for _, e := range ends {
+		if i, ok := blocked[e.Block()]; !ok || index(e) < i {
+			blocked[e.Block()] = index(e)
+		}
+	}
+	seen := make(map[*ssa.BasicBlock]bool)
+	var visit func(b *ssa.BasicBlock, from int) bool
+	visit = func(b *ssa.BasicBlock, from int) bool {
+		if i, ok := blocked[b]; ok && i >= from {
+			return false
+		}
+		if _, ok := b.Instrs[len(b.Instrs)-1].(*ssa.Return); ok {
+			return true
+		}
+		for _, s := range b.Succs {
+			if s == start.Block() || seen[s] {
+				// Passing the start again starts a new span.
+				continue
+			}
+			seen[s] = true
+			if visit(s, 0) {
+				return true
+			}
+		}
+		return false
+	}
+	return visit(start.Block(), index(start)+1)
+}
+
+// reaches returns whether to is reachable from from, without passing the
+// start call.
+func reaches(start, from, to ssa.Instruction) bool {
+	if from.Block() == to.Block() && index(from) < index(to) {
+		return true
+	}
+	seen := make(map[*ssa.BasicBlock]bool)
+	var visit func(b *ssa.BasicBlock) bool
+	visit = func(b *ssa.BasicBlock) bool {
+		for _, s := range b.Succs {
+			if seen[s] {
+				continue
+			}
+			seen[s] = true
+			if s == to.Block() && (s != start.Block() || index(to) < index(start)) {
+				return true
+			}
+			if s != start.Block() && visit(s) {
+				return true
+			}
+		}
+		return false
+	}
+	return visit(from.Block())
+}
+
+// checkContext reports the context returned by the Start call assigned by
+// as, if it is discarded, or if the parent context is passed to a call
+// after as in body instead.
+func checkContext(pass *analysis.Pass, as *ast.AssignStmt, call *ast.CallExpr, body *ast.BlockStmt) {
+	if id, ok := as.Lhs[0].(*ast.Ident); ok && id.Name == "_" {
+		pass.Reportf(as.Lhs[0].Pos(), "context returned by Start is discarded, so spans started by called functions are not children of this span; pass it on")
+		return
+	}
+	ctx := analysisutil.ObjectOf(pass.TypesInfo, as.Lhs[0])
+	parent := analysisutil.ObjectOf(pass.TypesInfo, call.Args[0])
+	if ctx == nil || parent == nil || ctx == parent || body == nil {
+		return
+	}
+	var use *ast.Ident
+	ast.Inspect(body, func(n ast.Node) bool {
+		c, ok := n.(*ast.CallExpr)
+		if !ok || use != nil || c.Pos() < as.End() {
+			return use == nil
+		}
+		for _, a := range c.Args {
+			if id, ok := analysisutil.Unparen(a).(*ast.Ident); ok && pass.TypesInfo.Uses[id] == parent {
+				use = id
+				return false
+			}
+		}
+		return true
+	})
+	if use != nil {
+		pass.Reportf(use.Pos(), "%s is passed on after Start instead of the context it returned, %s, so spans started with it are not children of this span", use.Name, ctx.Name())
+	}
+}
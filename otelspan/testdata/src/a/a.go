@@ -0,0 +1,99 @@
+package a
+
+import (
+	"context"
+	"errors"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer trace.Tracer
+
+func work(ctx context.Context) error { return nil }
+
+func ok(ctx context.Context) error {
+	ctx, span := tracer.Start(ctx, "ok")
+	defer span.End()
+	span.SetAttributes("k", 1)
+	return work(ctx)
+}
+
+func never(ctx context.Context) error {
+	ctx, span := tracer.Start(ctx, "never") // want `span is never ended, so it isn't exported and leaks; defer span.End right after Start`
+	span.AddEvent("x")
+	return work(ctx)
+}
+
+func discarded(ctx context.Context) {
+	ctx, _ = tracer.Start(ctx, "discarded") // want `span is discarded, so it is never ended`
+	work(ctx)
+}
+
+func somePaths(ctx context.Context) error {
+	ctx, span := tracer.Start(ctx, "some") // want `span is not ended on all paths returning from the function; defer span.End right after Start`
+	if err := work(ctx); err != nil {
+		return err
+	}
+	span.End()
+	return nil
+}
+
+func allPaths(ctx context.Context) error {
+	ctx, span := tracer.Start(ctx, "all")
+	if err := work(ctx); err != nil {
+		span.RecordError(err)
+		span.End()
+		return err
+	}
+	span.End()
+	return nil
+}
+
+func twice(ctx context.Context) {
+	ctx, span := tracer.Start(ctx, "twice")
+	defer span.End()
+	work(ctx)
+	span.End() // want `span is ended twice: End is deferred and called explicitly`
+}
+
+func twiceExplicit(ctx context.Context) {
+	ctx, span := tracer.Start(ctx, "twice")
+	work(ctx)
+	span.End()
+	if ctx != nil {
+		span.End() // want `span is ended twice; the second End has no effect`
+	}
+}
+
+func afterEnd(ctx context.Context) {
+	ctx, span := tracer.Start(ctx, "after")
+	err := work(ctx)
+	span.End()
+	if err != nil {
+		span.RecordError(errors.New("x")) // want `RecordError after the span is ended has no effect; call it before End`
+	}
+	span.SetAttributes("k", 2) // want `SetAttributes after the span is ended has no effect`
+	_ = span.IsRecording()
+}
+
+func loop(ctx context.Context, n int) {
+	for i := 0; i < n; i++ {
+		ictx, span := tracer.Start(ctx, "iteration")
+		work(ictx)
+		span.End()
+	}
+}
+
+func escapes(ctx context.Context) trace.Span {
+	_, span := tracer.Start(ctx, "escapes") // want `context returned by Start is discarded, so spans started by called functions are not children of this span; pass it on`
+	return span
+}
+
+func parent(ctx context.Context) error {
+	spanCtx, span := tracer.Start(ctx, "parent")
+	defer span.End()
+	if err := work(spanCtx); err != nil {
+		return err
+	}
+	return work(ctx) // want `ctx is passed on after Start instead of the context it returned, spanCtx, so spans started with it are not children of this span`
+}
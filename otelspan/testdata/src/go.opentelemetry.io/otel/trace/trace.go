@@ -0,0 +1,16 @@
+package trace
+
+import "context"
+
+type Tracer interface {
+	Start(ctx context.Context, name string) (context.Context, Span)
+}
+
+type Span interface {
+	End()
+	AddEvent(name string)
+	SetAttributes(kv ...interface{})
+	SetName(name string)
+	RecordError(err error)
+	IsRecording() bool
+}
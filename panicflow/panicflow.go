@@ -0,0 +1,213 @@
+// Copyright 2019 Axel Wagner
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package panicflow defines an Analyzer that checks for panics used to
+// return errors.
+package panicflow
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+
+	"github.com/Merovius/go-tools/internal/analysisutil"
+	"github.com/Merovius/go-tools/internal/nodefilter"
+	"golang.org/x/tools/go/analysis"
+)
+
+const Doc = `check for panics used to return errors
+
+Some packages panic with a value of an unexported type deep inside their
+implementation and recover it in their exported functions, to return it as an
+error. The analyzer reports panics with such a value, if the value carries an
+error or the panic is guarded by a check of an error against nil: then the
+panic reports an expected condition, like invalid input, and the error
+should be returned instead. Panics are slow, hide the control flow from
+readers and turn into crashes if they escape the recovering function, for
+example from a new goroutine or a callback.`
+
+var Analyzer = &analysis.Analyzer{
+	Name: "panicflow",
+	Doc:  Doc,
+	Run:  run,
+	Requires: []*analysis.Analyzer{
+		nodefilter.Analyzer,
+	},
+}
+
+var (
+	funcs = nodefilter.New(false, new(ast.FuncDecl))
+	calls = nodefilter.New(true, new(ast.CallExpr))
+)
+
+var errorType = types.Universe.Lookup("error").Type().Underlying().(*types.Interface)
+
+func run(pass *analysis.Pass) (interface{}, error) {
+	nodes := pass.ResultOf[nodefilter.Analyzer].(*nodefilter.Result)
+
+	// caught maps the unexported types recovered by exported functions to
+	// the name of such a function.
+	caught := make(map[*types.TypeName]string)
+	for _, n := range nodes.Nodes(funcs) {
+		fn := n.Node.(*ast.FuncDecl)
+		if fn.Body == nil || !fn.Name.IsExported() {
+			continue
+		}
+		for _, stmt := range fn.Body.List {
+			d, ok := stmt.(*ast.DeferStmt)
+			if !ok {
+				continue
+			}
+			lit, ok := d.Call.Fun.(*ast.FuncLit)
+			if !ok || !callsRecover(pass, lit.Body) {
+				continue
+			}
+			for _, tn := range recovered(pass, lit.Body) {
+				if _, ok := caught[tn]; !ok {
+					caught[tn] = fn.Name.Name
+				}
+			}
+		}
+	}
+	if len(caught) == 0 {
+		return nil, nil
+	}
+
+	for _, n := range nodes.Nodes(calls) {
+		call := n.Node.(*ast.CallExpr)
+		if !isBuiltin(pass, call.Fun, "panic") || len(call.Args) != 1 {
+			continue
+		}
+		tn := typeName(pass.TypesInfo.TypeOf(call.Args[0]))
+		entry, ok := caught[tn]
+		if !ok || !carriesError(pass, call.Args[0]) && !guarded(pass, n.Stack) {
+			continue
+		}
+		pass.Reportf(call.Pos(), "panic with %s, recovered in %s, reports an expected error; return the error instead of using panics for control flow", tn.Name(), entry)
+	}
+	return nil, nil
+}
+
+func isBuiltin(pass *analysis.Pass, e ast.Expr, name string) bool {
+	id, ok := analysisutil.Unparen(e).(*ast.Ident)
+	if !ok {
+		return false
+	}
+	b, ok := pass.TypesInfo.Uses[id].(*types.Builtin)
+	return ok && b.Name() == name
+}
+
+func callsRecover(pass *analysis.Pass, body *ast.BlockStmt) bool {
+	found := false
+	ast.Inspect(body, func(n ast.Node) bool {
+		if call, ok := n.(*ast.CallExpr); ok && isBuiltin(pass, call.Fun, "recover") {
+			found = true
+		}
+		return !found
+	})
+	return found
+}
+
+// recovered returns the unexported types of the package the recovered value
+// is asserted to in body.
+func recovered(pass *analysis.Pass, body *ast.BlockStmt) []*types.TypeName {
+	var out []*types.TypeName
+	add := func(e ast.Expr) {
+		tn := typeName(pass.TypesInfo.TypeOf(e))
+		if tn != nil && tn.Pkg() == pass.Pkg && !tn.Exported() {
+			out = append(out, tn)
+		}
+	}
+	ast.Inspect(body, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.TypeAssertExpr:
+			if n.Type != nil {
+				add(n.Type)
+			}
+		case *ast.TypeSwitchStmt:
+			for _, c := range n.Body.List {
+				for _, e := range c.(*ast.CaseClause).List {
+					add(e)
+				}
+			}
+		case *ast.FuncLit:
+			return false
+		}
+		return true
+	})
+	return out
+}
+
+// typeName returns the name of the named type t or t points to.
+func typeName(t types.Type) *types.TypeName {
+	if p, ok := t.(*types.Pointer); ok {
+		t = p.Elem()
+	}
+	if n, ok := t.(*types.Named); ok {
+		return n.Obj()
+	}
+	return nil
+}
+
+// carriesError returns whether the panic value e is or contains an error.
+func carriesError(pass *analysis.Pass, e ast.Expr) bool {
+	t := pass.TypesInfo.TypeOf(e)
+	if types.Implements(t, errorType) {
+		return true
+	}
+	if p, ok := t.(*types.Pointer); ok {
+		t = p.Elem()
+	}
+	if st, ok := t.Underlying().(*types.Struct); ok {
+		for i := 0; i < st.NumFields(); i++ {
+			if types.Implements(st.Field(i).Type(), errorType) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// guarded returns whether the node at the top of stack is in the body of an
+// if statement comparing an error to nil.
+func guarded(pass *analysis.Pass, stack []ast.Node) bool {
+	for i := len(stack) - 2; i >= 0; i-- {
+		switch s := stack[i].(type) {
+		case *ast.FuncDecl, *ast.FuncLit:
+			return false
+		case *ast.IfStmt:
+			if stack[i+1] == s.Body && isErrCheck(pass, s.Cond) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func isErrCheck(pass *analysis.Pass, cond ast.Expr) bool {
+	found := false
+	ast.Inspect(cond, func(n ast.Node) bool {
+		b, ok := n.(*ast.BinaryExpr)
+		if !ok || b.Op != token.NEQ {
+			return !found
+		}
+		for _, pair := range [][2]ast.Expr{{b.X, b.Y}, {b.Y, b.X}} {
+			if pass.TypesInfo.Types[pair[1]].IsNil() && types.Implements(pass.TypesInfo.TypeOf(pair[0]), errorType) {
+				found = true
+			}
+		}
+		return !found
+	})
+	return found
+}
@@ -0,0 +1,81 @@
+package a
+
+import (
+	"errors"
+	"strconv"
+)
+
+type parseError struct {
+	err error
+}
+
+type bailout struct{}
+
+type internalBug struct {
+	msg string
+}
+
+func Parse(s string) (n int, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			pe, ok := r.(parseError)
+			if !ok {
+				panic(r)
+			}
+			err = pe.err
+		}
+	}()
+	return parse(s), nil
+}
+
+func parse(s string) int {
+	if s == "" {
+		panic(parseError{errors.New("empty input")}) // want `panic with parseError, recovered in Parse, reports an expected error`
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		panic(parseError{err}) // want `panic with parseError, recovered in Parse, reports an expected error`
+	}
+	if n < 0 {
+		panic(internalBug{"negative"})
+	}
+	return n
+}
+
+func Scan(s string) (ok bool) {
+	defer func() {
+		switch recover().(type) {
+		case nil:
+		case *bailout:
+			ok = false
+		}
+	}()
+	scan(s)
+	return true
+}
+
+func scan(s string) {
+	if _, err := strconv.Atoi(s); err != nil {
+		panic(&bailout{}) // want `panic with bailout, recovered in Scan, reports an expected error`
+	}
+	if len(s) > 10 {
+		panic(&bailout{})
+	}
+}
+
+func check(s string) {
+	if _, err := strconv.Atoi(s); err != nil {
+		panic(err)
+	}
+}
+
+type unexportedEntry struct{ err error }
+
+func run() (err error) {
+	defer func() {
+		if r, ok := recover().(unexportedEntry); ok {
+			err = r.err
+		}
+	}()
+	panic(unexportedEntry{errors.New("x")})
+}
@@ -0,0 +1,555 @@
+// Copyright 2019 Axel Wagner
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package patterns defines an Analyzer that reports code matching rules
+// written by the user.
+package patterns
+
+import (
+	"bufio"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/Merovius/go-tools/internal/analysisutil"
+	"github.com/Merovius/go-tools/internal/fix"
+	"github.com/Merovius/go-tools/internal/nodefilter"
+	"golang.org/x/tools/go/analysis"
+)
+
+const Doc = `report code matching user-written rules
+
+Rules are read from the file given by the -rules flag or, by default, from
+a file named gotools.rules in the directory of the package or the nearest
+of its parents, usually next to the configuration file. Every rule is a
+paragraph of lines of the form key: value, with lines starting with # being
+comments:
+
+	# errors.New with a formatted message
+	match: errors.New(fmt.Sprintf($*args))
+	report: use fmt.Errorf instead of errors.New(fmt.Sprintf(...))
+	suggest: fmt.Errorf($args)
+
+A match line gives a Go expression or statement. A rule can have several,
+matching alternatives. In them, $name matches any expression or statement,
+and $*name any list of them, like the arguments of a call; repeated
+variables must match the same code, except for $_ and $*_. Other
+identifiers match themselves, with the names of imported packages matching
+their uses under any local name. Parentheses and white space don't matter,
+but the code must match otherwise.
+
+The report line is the message of the diagnostic. The optional suggest line
+is a replacement for the matched code, suggested as a fix. In both, the
+variables of the pattern stand for the code they matched. Generated files
+are not checked.`
+
+var Analyzer = &analysis.Analyzer{
+	Name: "patterns",
+	Doc:  Doc,
+	Run:  run,
+	Requires: []*analysis.Analyzer{
+		nodefilter.Analyzer,
+	},
+}
+
+// FileName is the name of the rules files looked for by default.
+const FileName = "gotools.rules"
+
+var rulesFile string
+
+func init() {
+	Analyzer.Flags.StringVar(&rulesFile, "rules", "", "`file` to read the rules from (default "+FileName+" in the package directory or its parents)")
+	// Suggestions are written by the user, so nothing is known about them.
+	fix.Register(Analyzer, fix.Unverified)
+}
+
+// nodes selects the expressions and statements, which are the nodes
+// patterns can match.
+var nodes = nodefilter.New(false,
+	new(ast.ArrayType), new(ast.BasicLit), new(ast.BinaryExpr),
+	new(ast.CallExpr), new(ast.ChanType), new(ast.CompositeLit),
+	new(ast.FuncLit), new(ast.FuncType), new(ast.Ident),
+	new(ast.IndexExpr), new(ast.InterfaceType), new(ast.KeyValueExpr),
+	new(ast.MapType), new(ast.ParenExpr), new(ast.SelectorExpr),
+	new(ast.SliceExpr), new(ast.StarExpr), new(ast.StructType),
+	new(ast.TypeAssertExpr), new(ast.UnaryExpr),
+	new(ast.AssignStmt), new(ast.BlockStmt), new(ast.BranchStmt),
+	new(ast.DeclStmt), new(ast.DeferStmt), new(ast.ExprStmt),
+	new(ast.ForStmt), new(ast.GoStmt), new(ast.IfStmt),
+	new(ast.IncDecStmt), new(ast.LabeledStmt), new(ast.RangeStmt),
+	new(ast.ReturnStmt), new(ast.SelectStmt), new(ast.SendStmt),
+	new(ast.SwitchStmt), new(ast.TypeSwitchStmt),
+)
+
+// A rule is a parsed rule of a rules file.
+type rule struct {
+	patterns []pattern
+	report   string
+	suggest  string
+}
+
+// A pattern is the syntax tree of a match line, with the variables replaced
+// by identifiers starting with varPrefix or listPrefix.
+type pattern struct {
+	root ast.Node
+}
+
+const (
+	varPrefix  = "gotools_var_"
+	listPrefix = "gotools_list_"
+)
+
+// varRE matches the variables in patterns and templates.
+var varRE = regexp.MustCompile(`\$(\*?)([A-Za-z_][A-Za-z0-9_]*)`)
+
+// parseRules parses the rules file named file, with the contents read from
+// sc.
+func parseRules(file string, sc *bufio.Scanner) ([]*rule, error) {
+	var (
+		rules []*rule
+		cur   *rule
+		start int
+		vars  map[string]bool
+	)
+	finish := func() error {
+		if cur == nil {
+			return nil
+		}
+		r := cur
+		cur = nil
+		if len(r.patterns) == 0 {
+			return fmt.Errorf("%s:%d: rule has no match line", file, start)
+		}
+		if r.report == "" {
+			return fmt.Errorf("%s:%d: rule has no report line", file, start)
+		}
+		for _, t := range []string{r.report, r.suggest} {
+			for _, m := range varRE.FindAllStringSubmatch(t, -1) {
+				if !vars[m[2]] || m[2] == "_" {
+					return fmt.Errorf("%s:%d: $%s is not a variable of the patterns of the rule", file, start, m[2])
+				}
+			}
+		}
+		rules = append(rules, r)
+		return nil
+	}
+	for line := 1; sc.Scan(); line++ {
+		text := strings.TrimSpace(sc.Text())
+		if strings.HasPrefix(text, "#") {
+			continue
+		}
+		if text == "" {
+			if err := finish(); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if cur == nil {
+			cur, start, vars = new(rule), line, make(map[string]bool)
+		}
+		i := strings.IndexByte(text, ':')
+		if i < 0 {
+			return nil, fmt.Errorf("%s:%d: line is not of the form key: value", file, line)
+		}
+		key, value := strings.TrimSpace(text[:i]), strings.TrimSpace(text[i+1:])
+		switch key {
+		case "match":
+			p, err := parsePattern(value)
+			if err != nil {
+				return nil, fmt.Errorf("%s:%d: %v", file, line, err)
+			}
+			for _, m := range varRE.FindAllStringSubmatch(value, -1) {
+				vars[m[2]] = true
+			}
+			cur.patterns = append(cur.patterns, p)
+		case "report", "suggest":
+			dst := &cur.report
+			if key == "suggest" {
+				dst = &cur.suggest
+			}
+			if *dst != "" {
+				return nil, fmt.Errorf("%s:%d: rule has more than one %s line", file, line, key)
+			}
+			if value == "" {
+				return nil, fmt.Errorf("%s:%d: empty %s line", file, line, key)
+			}
+			*dst = value
+		default:
+			return nil, fmt.Errorf("%s:%d: unknown key %q", file, line, key)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	if err := finish(); err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+// parsePattern parses the text of a match line as an expression or, if it
+// isn't one, a single statement.
+func parsePattern(text string) (pattern, error) {
+	src := varRE.ReplaceAllStringFunc(text, func(v string) string {
+		if strings.HasPrefix(v, "$*") {
+			return listPrefix + v[2:]
+		}
+		return varPrefix + v[1:]
+	})
+	var root ast.Node
+	if e, err := parser.ParseExpr(src); err == nil {
+		root = e
+	} else {
+		f, err := parser.ParseFile(token.NewFileSet(), "", "package p; func _() {\n"+src+"\n}", 0)
+		if err != nil {
+			return pattern{}, fmt.Errorf("pattern %q is neither an expression nor a statement", text)
+		}
+		body := f.Decls[0].(*ast.FuncDecl).Body.List
+		if len(body) != 1 {
+			return pattern{}, fmt.Errorf("pattern %q is not a single statement", text)
+		}
+		root = body[0]
+	}
+	switch r := root.(type) {
+	case *ast.ExprStmt:
+		root = analysisutil.Unparen(r.X)
+	case ast.Expr:
+		root = analysisutil.Unparen(r)
+	}
+	if name, _ := wildcard(root); name != "" {
+		return pattern{}, fmt.Errorf("pattern %q matches anything", text)
+	}
+	return pattern{root}, nil
+}
+
+// wildcard returns the variable n stands for in a pattern and whether it
+// is a list variable. It returns "" if n isn't a variable.
+func wildcard(n ast.Node) (name string, list bool) {
+	if es, ok := n.(*ast.ExprStmt); ok {
+		n = es.X
+	}
+	id, ok := n.(*ast.Ident)
+	if !ok {
+		return "", false
+	}
+	if strings.HasPrefix(id.Name, listPrefix) {
+		return id.Name[len(listPrefix):], true
+	}
+	if strings.HasPrefix(id.Name, varPrefix) {
+		return id.Name[len(varPrefix):], false
+	}
+	return "", false
+}
+
+// ruleSets caches the parsed rules files, by file name, as all packages
+// usually share one.
+var ruleSets struct {
+	sync.Mutex
+	m map[string]ruleSet
+}
+
+type ruleSet struct {
+	rules []*rule
+	err   error
+}
+
+func loadRules(file string) ([]*rule, error) {
+	ruleSets.Lock()
+	defer ruleSets.Unlock()
+	if rs, ok := ruleSets.m[file]; ok {
+		return rs.rules, rs.err
+	}
+	var rs ruleSet
+	if f, err := os.Open(file); err != nil {
+		rs.err = err
+	} else {
+		rs.rules, rs.err = parseRules(file, bufio.NewScanner(f))
+		f.Close()
+	}
+	if ruleSets.m == nil {
+		ruleSets.m = make(map[string]ruleSet)
+	}
+	ruleSets.m[file] = rs
+	return rs.rules, rs.err
+}
+
+// findRules returns the rules file for the package in dir, or "" if there
+// is none.
+func findRules(dir string) string {
+	for d := dir; ; {
+		file := filepath.Join(d, FileName)
+		if _, err := os.Stat(file); err == nil {
+			return file
+		}
+		parent := filepath.Dir(d)
+		if parent == d {
+			return ""
+		}
+		d = parent
+	}
+}
+
+func run(pass *analysis.Pass) (interface{}, error) {
+	file := rulesFile
+	if file == "" {
+		if len(pass.Files) == 0 {
+			return nil, nil
+		}
+		dir, err := filepath.Abs(filepath.Dir(pass.Fset.File(pass.Files[0].Pos()).Name()))
+		if err != nil {
+			return nil, err
+		}
+		if file = findRules(dir); file == "" {
+			return nil, nil
+		}
+	}
+	rules, err := loadRules(file)
+	if err != nil {
+		return nil, err
+	}
+
+	byType := make(map[reflect.Type][]*rule)
+	for _, r := range rules {
+		seen := make(map[reflect.Type]bool)
+		for _, p := range r.patterns {
+			t := reflect.TypeOf(p.root)
+			if !seen[t] {
+				seen[t] = true
+				byType[t] = append(byType[t], r)
+			}
+		}
+	}
+	res := pass.ResultOf[nodefilter.Analyzer].(*nodefilter.Result)
+	for _, n := range res.Nodes(nodes) {
+		rs := byType[reflect.TypeOf(n.Node)]
+		if len(rs) == 0 || analysisutil.IsGenerated(pass, n.Pos()) {
+			continue
+		}
+		for _, r := range rs {
+			for _, p := range r.patterns {
+				m := &matcher{info: pass.TypesInfo, vars: make(map[string]binding)}
+				if m.node(p.root, n.Node) {
+					report(pass, r, n.Node, m.vars)
+					break
+				}
+			}
+		}
+	}
+	return nil, nil
+}
+
+func report(pass *analysis.Pass, r *rule, n ast.Node, vars map[string]binding) {
+	expand := func(t string) string {
+		return varRE.ReplaceAllStringFunc(t, func(v string) string {
+			return vars[varRE.FindStringSubmatch(v)[2]].render(pass.Fset)
+		})
+	}
+	d := analysis.Diagnostic{
+		Pos:     n.Pos(),
+		End:     n.End(),
+		Message: expand(r.report),
+	}
+	if r.suggest != "" {
+		text := expand(r.suggest)
+		d.SuggestedFixes = []analysis.SuggestedFix{{
+			Message:   "Replace with " + text,
+			TextEdits: []analysis.TextEdit{{Pos: n.Pos(), End: n.End(), NewText: []byte(text)}},
+		}}
+	}
+	pass.Report(d)
+}
+
+// A binding is the code matched by a variable.
+type binding struct {
+	nodes []ast.Node
+	list  bool
+}
+
+func (b binding) render(fset *token.FileSet) string {
+	sep := ", "
+	if len(b.nodes) > 0 {
+		if _, ok := b.nodes[0].(ast.Stmt); ok {
+			sep = "\n"
+		}
+	}
+	s := make([]string, len(b.nodes))
+	for i, n := range b.nodes {
+		s[i] = analysisutil.Render(fset, n)
+	}
+	return strings.Join(s, sep)
+}
+
+// A matcher matches patterns against code, recording the bindings of the
+// variables.
+type matcher struct {
+	info *types.Info
+	vars map[string]binding
+}
+
+var (
+	posType     = reflect.TypeOf(token.NoPos)
+	objectType  = reflect.TypeOf((*ast.Object)(nil))
+	scopeType   = reflect.TypeOf((*ast.Scope)(nil))
+	commentType = reflect.TypeOf((*ast.CommentGroup)(nil))
+	nodeType    = reflect.TypeOf((*ast.Node)(nil)).Elem()
+)
+
+// node reports whether the code n matches the pattern p.
+func (m *matcher) node(p, n ast.Node) bool {
+	if name, list := wildcard(p); name != "" && !list {
+		if _, ok := n.(ast.Stmt); ok {
+			if _, ok := p.(*ast.ExprStmt); !ok {
+				// An expression variable only matches expressions.
+				return false
+			}
+		}
+		return m.bind(name, binding{nodes: []ast.Node{n}})
+	}
+	if pe, ok := p.(ast.Expr); ok {
+		p = analysisutil.Unparen(pe)
+	}
+	if ne, ok := n.(ast.Expr); ok {
+		n = analysisutil.Unparen(ne)
+	}
+	if reflect.TypeOf(p) != reflect.TypeOf(n) {
+		return false
+	}
+	if pid, ok := p.(*ast.Ident); ok {
+		nid := n.(*ast.Ident)
+		if pn, ok := m.info.Uses[nid].(*types.PkgName); ok {
+			return pn.Imported().Name() == pid.Name
+		}
+		return pid.Name == nid.Name
+	}
+	return m.value(reflect.ValueOf(p).Elem(), reflect.ValueOf(n).Elem())
+}
+
+func (m *matcher) value(p, n reflect.Value) bool {
+	switch p.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if p.IsNil() || n.IsNil() {
+			return p.IsNil() && n.IsNil()
+		}
+		if p.Type().Implements(nodeType) || p.Kind() == reflect.Interface {
+			pn, ok1 := p.Interface().(ast.Node)
+			nn, ok2 := n.Interface().(ast.Node)
+			if ok1 && ok2 {
+				return m.node(pn, nn)
+			}
+		}
+		return m.value(p.Elem(), n.Elem())
+	case reflect.Struct:
+		for i := 0; i < p.NumField(); i++ {
+			switch p.Type().Field(i).Type {
+			case posType, objectType, scopeType, commentType:
+				continue
+			}
+			if !m.value(p.Field(i), n.Field(i)) {
+				return false
+			}
+		}
+		return true
+	case reflect.Slice:
+		if p.Type().Elem() == commentType {
+			return true
+		}
+		ps, ns := nodeList(p), nodeList(n)
+		if ps == nil {
+			// Not a list of nodes.
+			return reflect.DeepEqual(p.Interface(), n.Interface())
+		}
+		return m.list(ps, ns)
+	default:
+		return p.Interface() == n.Interface()
+	}
+}
+
+// nodeList converts a slice of nodes to []ast.Node. It returns nil if v
+// isn't one.
+func nodeList(v reflect.Value) []ast.Node {
+	if !v.Type().Elem().Implements(nodeType) {
+		return nil
+	}
+	l := make([]ast.Node, v.Len())
+	for i := range l {
+		l[i] = v.Index(i).Interface().(ast.Node)
+	}
+	return l
+}
+
+// list reports whether the code ns matches the patterns ps, trying all
+// lengths for list variables.
+func (m *matcher) list(ps, ns []ast.Node) bool {
+	if len(ps) == 0 {
+		return len(ns) == 0
+	}
+	if name, list := wildcard(ps[0]); list {
+		for i := 0; i <= len(ns); i++ {
+			saved := m.save()
+			if m.bind(name, binding{nodes: ns[:i], list: true}) && m.list(ps[1:], ns[i:]) {
+				return true
+			}
+			m.vars = saved
+		}
+		return false
+	}
+	if len(ns) == 0 {
+		return false
+	}
+	saved := m.save()
+	if m.node(ps[0], ns[0]) && m.list(ps[1:], ns[1:]) {
+		return true
+	}
+	m.vars = saved
+	return false
+}
+
+func (m *matcher) save() map[string]binding {
+	vars := make(map[string]binding, len(m.vars))
+	for k, v := range m.vars {
+		vars[k] = v
+	}
+	return vars
+}
+
+// bind binds the variable name to b. If it is already bound, it reports
+// whether b is the same code.
+func (m *matcher) bind(name string, b binding) bool {
+	if name == "_" {
+		return true
+	}
+	old, ok := m.vars[name]
+	if !ok {
+		m.vars[name] = b
+		return true
+	}
+	if old.list != b.list || len(old.nodes) != len(b.nodes) {
+		return false
+	}
+	eq := &matcher{info: m.info, vars: make(map[string]binding)}
+	for i := range b.nodes {
+		if !eq.node(old.nodes[i], b.nodes[i]) {
+			return false
+		}
+	}
+	return true
+}
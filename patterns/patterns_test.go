@@ -0,0 +1,59 @@
+// Copyright 2019 Axel Wagner
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package patterns
+
+import (
+	"bufio"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Merovius/go-tools/internal/analysistestx"
+)
+
+func TestPatterns(t *testing.T) {
+	dir := analysistestx.TestData()
+	analysistestx.RunMatrix(t, dir, Analyzer,
+		analysistestx.Case{Name: "file", Patterns: []string{"a"}, Fixes: true},
+		analysistestx.Case{
+			Name:     "flag",
+			Flags:    map[string]string{"rules": filepath.Join(dir, "rules.txt")},
+			Patterns: []string{"b"},
+		},
+	)
+}
+
+func TestParseRulesErrors(t *testing.T) {
+	tests := []struct {
+		rules string
+		err   string
+	}{
+		{"report: x", "rules:1: rule has no match line"},
+		{"match: f($x)", "rules:1: rule has no report line"},
+		{"match: f(\nreport: x", "rules:1: pattern \"f(\" is neither an expression nor a statement"},
+		{"match: $x", "rules:1: pattern \"$x\" matches anything"},
+		{"match: a(); b()\nreport: x", "rules:1: pattern \"a(); b()\" is not a single statement"},
+		{"# comment\nmatch: f($x)\nreport: $y", "rules:2: $y is not a variable of the patterns of the rule"},
+		{"match: f($x)\nreport: x\nreport: y", "rules:3: rule has more than one report line"},
+		{"match: f($x)\nwhere: $x", "rules:2: unknown key \"where\""},
+		{"match f($x)", "rules:1: line is not of the form key: value"},
+	}
+	for _, tc := range tests {
+		_, err := parseRules("rules", bufio.NewScanner(strings.NewReader(tc.rules)))
+		if err == nil || err.Error() != tc.err {
+			t.Errorf("parseRules(%q) = %v, want %q", tc.rules, err, tc.err)
+		}
+	}
+}
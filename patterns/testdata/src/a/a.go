@@ -0,0 +1,46 @@
+// Copyright 2019 Axel Wagner
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package a
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+func Errors(n int) error {
+	if n < 0 {
+		return errors.New(fmt.Sprintf("negative: %d", n)) // want `use fmt.Errorf\("negative: %d", n\) instead of errors.New\(fmt.Sprintf\(...\)\)`
+	}
+	if n == 0 {
+		return errors.New(fmt.Sprintf("zero")) // want `use fmt.Errorf\("zero"\) instead`
+	}
+	return errors.New(fmt.Sprint(n))
+}
+
+func Self(a, b int, s []int) bool {
+	_ = a == a       // want `comparison of a with itself`
+	_ = s[a] != s[a] // want `comparison of s\[a\] with itself`
+	_ = (a) == a     // want `comparison of \(a\) with itself`
+	_ = s[a] == s[b]
+	return a == b
+}
+
+func Contains(s, sub string) bool {
+	if strings.Index(s, sub) >= 0 { // want `use strings.Contains to check whether s contains sub`
+		return true
+	}
+	return strings.Index(s, "x") != -1 || strings.Index(s, "y") > 0 // want `use strings.Contains to check whether s contains "x"`
+}
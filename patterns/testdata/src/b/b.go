@@ -0,0 +1,48 @@
+// Copyright 2019 Axel Wagner
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package b
+
+import (
+	"os"
+	tm "time"
+)
+
+func Check(err error) error {
+	if err != nil { // want `return err unconditionally, as it is nil otherwise`
+		return err
+	}
+	return nil
+}
+
+func Wrapped(err error) error {
+	if err != nil {
+		return os.ErrNotExist
+	}
+	return nil
+}
+
+func Close(files []*os.File) {
+	for _, f := range files { // want `deferred calls in a loop over files run only when the function returns`
+		f.Sync()
+		defer f.Close()
+	}
+	for _, f := range files {
+		f.Close()
+	}
+}
+
+func Wait() {
+	tm.Sleep(tm.Second) // want `don't sleep in tests`
+}
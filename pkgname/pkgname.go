@@ -0,0 +1,204 @@
+// Copyright 2019 Axel Wagner
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package pkgname defines an Analyzer that checks the names of packages and
+// their exported identifiers.
+package pkgname
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/Merovius/go-tools/internal/nodefilter"
+	"golang.org/x/tools/go/analysis"
+)
+
+const Doc = `check the names of packages and their exported identifiers
+
+The analyzer reports
+  - packages with names that say nothing about their contents, like util or
+    common, as given by the -names flag,
+  - packages with names that differ from their directory. Prefixes and
+    suffixes like go- and -go, major version directories and separators in
+    directory names are ignored,
+  - exported identifiers starting with the package name, like
+    http.HTTPServer, which stutter when used from other packages, and
+  - directories containing files of other packages, besides external test
+    packages and files excluded by the "ignore" build tag.`
+
+var Analyzer = &analysis.Analyzer{
+	Name: "pkgname",
+	Doc:  Doc,
+	Run:  run,
+	Requires: []*analysis.Analyzer{
+		nodefilter.Analyzer,
+	},
+}
+
+var names = "common,helper,helpers,misc,util,utils"
+
+func init() {
+	Analyzer.Flags.StringVar(&names, "names", names, "comma-separated package names which are too generic")
+}
+
+var decls = nodefilter.New(true, new(ast.FuncDecl), new(ast.TypeSpec), new(ast.ValueSpec))
+
+func run(pass *analysis.Pass) (interface{}, error) {
+	name := strings.TrimSuffix(pass.Pkg.Name(), "_test")
+	if name == "main" {
+		return nil, nil
+	}
+	var clause *ast.File
+	for _, f := range pass.Files {
+		if !strings.HasSuffix(pass.Fset.File(f.Pos()).Name(), "_test.go") {
+			clause = f
+			break
+		}
+	}
+	if clause != nil && !strings.HasSuffix(pass.Pkg.Name(), "_test") {
+		checkPackage(pass, clause, name)
+	}
+
+	nodes := pass.ResultOf[nodefilter.Analyzer].(*nodefilter.Result)
+	for _, n := range nodes.Nodes(decls) {
+		switch d := n.Node.(type) {
+		case *ast.FuncDecl:
+			if d.Recv == nil {
+				checkStutter(pass, d.Name, name)
+			}
+		case *ast.TypeSpec:
+			if len(n.Stack) == 3 {
+				checkStutter(pass, d.Name, name)
+			}
+		case *ast.ValueSpec:
+			if len(n.Stack) == 3 {
+				for _, id := range d.Names {
+					checkStutter(pass, id, name)
+				}
+			}
+		}
+	}
+	return nil, nil
+}
+
+func checkPackage(pass *analysis.Pass, f *ast.File, name string) {
+	for _, n := range strings.Split(names, ",") {
+		if strings.TrimSpace(n) == name {
+			pass.Reportf(f.Name.Pos(), "package name %s says nothing about the contents of the package; name it after what it provides", name)
+			break
+		}
+	}
+
+	path := pass.Fset.File(f.Pos()).Name()
+	dir := filepath.Dir(path)
+	if d := dirName(dir); d != "" && d != strings.Replace(name, "_", "", -1) {
+		pass.Report(analysis.Diagnostic{
+			Pos:      f.Name.Pos(),
+			Category: "directory",
+			Message:  "package " + name + " is in directory " + filepath.Base(dir) + "; name the package like its directory",
+		})
+	}
+
+	fis, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return
+	}
+	fset := token.NewFileSet()
+	for _, fi := range fis {
+		if !strings.HasSuffix(fi.Name(), ".go") {
+			continue
+		}
+		other, err := parser.ParseFile(fset, filepath.Join(dir, fi.Name()), nil, parser.PackageClauseOnly|parser.ParseComments)
+		if err != nil || strings.TrimSuffix(other.Name.Name, "_test") == name || ignored(other) {
+			continue
+		}
+		pass.Report(analysis.Diagnostic{
+			Pos:      f.Name.Pos(),
+			Category: "multiple",
+			Message:  "directory of package " + name + " also contains package " + other.Name.Name + " in " + fi.Name() + "; move it to its own directory",
+		})
+	}
+}
+
+var (
+	majorVersion = regexp.MustCompile(`^v[0-9]+$`)
+	gopkgVersion = regexp.MustCompile(`\.v[0-9]+$`)
+)
+
+// dirName returns the package name expected in dir, with separators
+// removed, or "" if there is none.
+func dirName(dir string) string {
+	base := filepath.Base(dir)
+	if majorVersion.MatchString(base) {
+		base = filepath.Base(filepath.Dir(dir))
+	}
+	base = strings.ToLower(gopkgVersion.ReplaceAllString(base, ""))
+	base = strings.TrimPrefix(base, "go-")
+	base = strings.TrimSuffix(strings.TrimSuffix(base, "-go"), ".go")
+	base = strings.Map(func(r rune) rune {
+		if r == '-' || r == '_' || r == '.' {
+			return -1
+		}
+		return r
+	}, base)
+	if base == "" || base == "/" {
+		return ""
+	}
+	return base
+}
+
+// ignored returns whether f is excluded from builds by the "ignore" build
+// tag, as is usual for programs generating code.
+func ignored(f *ast.File) bool {
+	for _, cg := range f.Comments {
+		if cg.Pos() > f.Package {
+			break
+		}
+		for _, c := range cg.List {
+			for _, prefix := range []string{"// +build ", "//go:build "} {
+				if !strings.HasPrefix(c.Text, prefix) {
+					continue
+				}
+				for _, tag := range strings.Fields(c.Text[len(prefix):]) {
+					if tag == "ignore" {
+						return true
+					}
+				}
+			}
+		}
+	}
+	return false
+}
+
+func checkStutter(pass *analysis.Pass, id *ast.Ident, pkg string) {
+	if !id.IsExported() || len(id.Name) <= len(pkg) || !strings.HasPrefix(strings.ToLower(id.Name), pkg) {
+		return
+	}
+	rest := id.Name[len(pkg):]
+	if r, _ := utf8.DecodeRuneInString(rest); !unicode.IsUpper(r) {
+		return
+	}
+	pass.Report(analysis.Diagnostic{
+		Pos:      id.Pos(),
+		Category: "stutter",
+		Message:  pkg + "." + id.Name + " stutters; consider calling it " + rest,
+	})
+}
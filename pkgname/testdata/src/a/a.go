@@ -0,0 +1,3 @@
+package a // want `directory of package a also contains package extra in extra.go`
+
+func A() {}
@@ -0,0 +1,3 @@
+// +build never
+
+package extra
@@ -0,0 +1 @@
+package cee // want `package cee is in directory c; name the package like its directory`
@@ -0,0 +1,19 @@
+package httpx
+
+type HTTPXServer struct{} // want `httpx.HTTPXServer stutters; consider calling it Server`
+
+type Server struct{}
+
+func HttpxGet() {} // want `httpx.HttpxGet stutters; consider calling it Get`
+
+func (Server) HttpxGet() {}
+
+const (
+	Httpx        = 1
+	Httpxample   = 2
+	HttpxVersion = 3 // want `httpx.HttpxVersion stutters`
+)
+
+func f() {
+	type HttpxLocal int
+}
@@ -0,0 +1 @@
+package util // want `package name util says nothing about the contents of the package`
@@ -0,0 +1,295 @@
+// Copyright 2019 Axel Wagner
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package platformcall defines an Analyzer that checks for uses of
+// platform-specific APIs in files built on platforms which lack them.
+package platformcall
+
+import (
+	"go/ast"
+	"go/build"
+	"go/constant"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/Merovius/go-tools/internal/analysisutil"
+	"github.com/Merovius/go-tools/internal/nodefilter"
+	"golang.org/x/tools/go/analysis"
+)
+
+const Doc = `check for platform-specific APIs in files built on other platforms
+
+The analysis only sees the files of one build configuration, so code which
+doesn't compile on other platforms goes unnoticed until someone builds there.
+The analyzer reports
+  - uses of identifiers of package syscall, which are not declared on all
+    platforms the file is built on, and imports of golang.org/x/sys/unix in
+    files built on windows, and
+  - in packages with files for windows, calls of functions which always fail
+    there, like os.Chown, and unixgram and unixpacket sockets, in files built
+    on windows.
+
+The platforms considered are linux, darwin and, if the package has files
+built only on it, windows.`
+
+var Analyzer = &analysis.Analyzer{
+	Name: "platformcall",
+	Doc:  Doc,
+	Run:  run,
+	Requires: []*analysis.Analyzer{
+		nodefilter.Analyzer,
+	},
+}
+
+var (
+	selectors = nodefilter.New(false, new(ast.SelectorExpr))
+	calls     = nodefilter.New(false, new(ast.CallExpr))
+)
+
+func run(pass *analysis.Pass) (interface{}, error) {
+	usesSyscall := analysisutil.Imports(pass.Pkg, "syscall") || analysisutil.Imports(pass.Pkg, "golang.org/x/sys/unix")
+	if !usesSyscall && !analysisutil.Imports(pass.Pkg, "os") && !analysisutil.Imports(pass.Pkg, "net") {
+		return nil, nil
+	}
+	p := newPlatforms(pass)
+	if usesSyscall {
+		for _, f := range pass.Files {
+			checkUnixImport(pass, p, f)
+		}
+	}
+	nodes := pass.ResultOf[nodefilter.Analyzer].(*nodefilter.Result)
+	if usesSyscall {
+		for _, n := range nodes.Nodes(selectors) {
+			checkSyscall(pass, p, n.Node.(*ast.SelectorExpr))
+		}
+	}
+	if p.windows {
+		for _, n := range nodes.Nodes(calls) {
+			checkWindows(pass, p, n.Node.(*ast.CallExpr))
+		}
+	}
+	return nil, nil
+}
+
+// platforms determines the platforms files of a package are built on.
+type platforms struct {
+	windows bool // whether the package has files built only on windows
+	goos    map[string][]string
+}
+
+func newPlatforms(pass *analysis.Pass) *platforms {
+	p := &platforms{goos: make(map[string][]string)}
+	if len(pass.Files) == 0 {
+		return p
+	}
+	dir := filepath.Dir(pass.Fset.File(pass.Files[0].Pos()).Name())
+	fis, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return p
+	}
+	for _, fi := range fis {
+		if !strings.HasSuffix(fi.Name(), ".go") {
+			continue
+		}
+		if match("windows", dir, fi.Name()) && !match("linux", dir, fi.Name()) && !match("darwin", dir, fi.Name()) {
+			p.windows = true
+			break
+		}
+	}
+	return p
+}
+
+// match returns whether the file name in dir is built on goos.
+func match(goos, dir, name string) bool {
+	ctx := build.Default
+	ctx.GOOS = goos
+	ctx.GOARCH = "amd64"
+	ctx.CgoEnabled = false
+	ok, err := ctx.MatchFile(dir, name)
+	return err == nil && ok
+}
+
+// builtOn returns the considered platforms the file containing pos is
+// built on.
+func (p *platforms) builtOn(pass *analysis.Pass, pos token.Pos) []string {
+	path := pass.Fset.File(pos).Name()
+	if goos, ok := p.goos[path]; ok {
+		return goos
+	}
+	candidates := []string{"linux", "darwin"}
+	if p.windows {
+		candidates = append(candidates, "windows")
+	}
+	var goos []string
+	for _, g := range candidates {
+		if match(g, filepath.Dir(path), filepath.Base(path)) {
+			goos = append(goos, g)
+		}
+	}
+	p.goos[path] = goos
+	return goos
+}
+
+func checkUnixImport(pass *analysis.Pass, p *platforms, f *ast.File) {
+	for _, imp := range f.Imports {
+		if imp.Path.Value != `"golang.org/x/sys/unix"` {
+			continue
+		}
+		if contains(p.builtOn(pass, imp.Pos()), "windows") {
+			pass.Reportf(imp.Pos(), "golang.org/x/sys/unix doesn't build on windows, but the file is built there; add a build constraint")
+		}
+	}
+}
+
+func checkSyscall(pass *analysis.Pass, p *platforms, sel *ast.SelectorExpr) {
+	id, ok := sel.X.(*ast.Ident)
+	if !ok {
+		return
+	}
+	pn, ok := pass.TypesInfo.Uses[id].(*types.PkgName)
+	if !ok || pn.Imported().Path() != "syscall" {
+		return
+	}
+	for _, goos := range p.builtOn(pass, sel.Pos()) {
+		names := syscallNames(goos)
+		if names != nil && !names[sel.Sel.Name] {
+			pass.Reportf(sel.Pos(), "syscall.%s is not declared on %s, but the file is built there; add a build constraint", sel.Sel.Name, goos)
+			return
+		}
+	}
+}
+
+var syscallCache struct {
+	sync.Mutex
+	names map[string]map[string]bool
+}
+
+// syscallNames returns the names declared by package syscall on goos, or
+// nil if they can't be determined.
+func syscallNames(goos string) map[string]bool {
+	syscallCache.Lock()
+	defer syscallCache.Unlock()
+	if names, ok := syscallCache.names[goos]; ok {
+		return names
+	}
+	if syscallCache.names == nil {
+		syscallCache.names = make(map[string]map[string]bool)
+	}
+	ctx := build.Default
+	ctx.GOOS = goos
+	ctx.GOARCH = "amd64"
+	ctx.CgoEnabled = false
+	pkg, err := ctx.Import("syscall", "", 0)
+	if err != nil {
+		syscallCache.names[goos] = nil
+		return nil
+	}
+	names := make(map[string]bool)
+	fset := token.NewFileSet()
+	for _, name := range pkg.GoFiles {
+		f, err := parser.ParseFile(fset, filepath.Join(pkg.Dir, name), nil, 0)
+		if err != nil {
+			continue
+		}
+		for _, d := range f.Decls {
+			switch d := d.(type) {
+			case *ast.FuncDecl:
+				if d.Recv == nil {
+					names[d.Name.Name] = true
+				}
+			case *ast.GenDecl:
+				for _, spec := range d.Specs {
+					switch spec := spec.(type) {
+					case *ast.ValueSpec:
+						for _, id := range spec.Names {
+							names[id.Name] = true
+						}
+					case *ast.TypeSpec:
+						names[spec.Name.Name] = true
+					}
+				}
+			}
+		}
+	}
+	syscallCache.names[goos] = names
+	return names
+}
+
+// unsupported maps functions which don't work on windows to a description
+// of what they do there instead.
+var unsupported = map[string]string{
+	"os.Chown":         "always fails",
+	"os.Lchown":        "always fails",
+	"(*os.File).Chown": "always fails",
+	"os.Getuid":        "always returns -1",
+	"os.Geteuid":       "always returns -1",
+	"os.Getgid":        "always returns -1",
+	"os.Getegid":       "always returns -1",
+	"os.Getgroups":     "always fails",
+}
+
+// dials lists functions taking a network, which is their first string
+// argument.
+var dials = []string{
+	"net.Dial",
+	"net.DialTimeout",
+	"net.Listen",
+	"net.ListenPacket",
+	"(*net.Dialer).Dial",
+	"(*net.Dialer).DialContext",
+	"(*net.ListenConfig).Listen",
+	"(*net.ListenConfig).ListenPacket",
+}
+
+func checkWindows(pass *analysis.Pass, p *platforms, call *ast.CallExpr) {
+	name := analysisutil.CalleeName(pass.TypesInfo, call)
+	var msg string
+	if what, ok := unsupported[name]; ok {
+		msg = name + " " + what + " on windows"
+	} else if analysisutil.IsCall(pass.TypesInfo, call, dials...) {
+		for _, a := range call.Args {
+			tv := pass.TypesInfo.Types[a]
+			if tv.Value == nil || tv.Value.Kind() != constant.String {
+				continue
+			}
+			if network := constant.StringVal(tv.Value); network == "unixgram" || network == "unixpacket" {
+				msg = name + " with network " + network + " is not supported on windows"
+			}
+			break
+		}
+	}
+	if msg == "" || !contains(p.builtOn(pass, call.Pos()), "windows") {
+		return
+	}
+	pass.Report(analysis.Diagnostic{
+		Pos:      call.Pos(),
+		End:      call.End(),
+		Category: "windows",
+		Message:  msg + ", which the package supports; add a build constraint",
+	})
+}
+
+func contains(s []string, v string) bool {
+	for _, x := range s {
+		if x == v {
+			return true
+		}
+	}
+	return false
+}
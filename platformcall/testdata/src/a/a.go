@@ -0,0 +1,24 @@
+package a
+
+import (
+	"net"
+	"os"
+	"syscall"
+)
+
+func stop(pid int) error {
+	return syscall.Kill(pid, syscall.SIGTERM) // want `syscall.Kill is not declared on windows, but the file is built there; add a build constraint`
+}
+
+func open(path string) {
+	syscall.Open(path, syscall.O_RDONLY, 0)
+}
+
+func own(path string, f *os.File) {
+	os.Chown(path, 0, 0)                // want `os.Chown always fails on windows, which the package supports; add a build constraint`
+	f.Chown(0, 0)                       // want `\(\*os.File\).Chown always fails on windows`
+	_ = os.Getuid()                     // want `os.Getuid always returns -1 on windows`
+	net.Dial("unixgram", "/tmp/socket") // want `net.Dial with network unixgram is not supported on windows`
+	net.Dial("unix", "/tmp/socket")
+	net.Listen("tcp", ":8080")
+}
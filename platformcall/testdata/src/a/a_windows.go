@@ -0,0 +1,3 @@
+package a
+
+func stopWindows(pid int) error { return nil }
@@ -0,0 +1,5 @@
+package a
+
+import "golang.org/x/sys/unix" // want `golang.org/x/sys/unix doesn't build on windows, but the file is built there; add a build constraint`
+
+func kill(pid int) error { return unix.Kill(pid, 15) }
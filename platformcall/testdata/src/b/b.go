@@ -0,0 +1,18 @@
+package b
+
+import (
+	"os"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+func epoll() (int, error) {
+	return syscall.EpollCreate1(0) // want `syscall.EpollCreate1 is not declared on darwin, but the file is built there; add a build constraint`
+}
+
+func stop(pid int, path string) error {
+	os.Chown(path, 0, 0)
+	unix.Kill(pid, 15)
+	return syscall.Kill(pid, syscall.SIGTERM)
+}
@@ -0,0 +1,7 @@
+package b
+
+import "syscall"
+
+func epollLinux() (int, error) {
+	return syscall.EpollCreate1(0)
+}
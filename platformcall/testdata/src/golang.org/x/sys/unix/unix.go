@@ -0,0 +1,3 @@
+package unix
+
+func Kill(pid int, sig int) error { return nil }
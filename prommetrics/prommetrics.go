@@ -0,0 +1,280 @@
+// Copyright 2019 Axel Wagner
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package prommetrics defines an Analyzer that checks for misuse of the
+// Prometheus client library.
+package prommetrics
+
+import (
+	"go/ast"
+	"go/constant"
+	"go/token"
+	"go/types"
+	"strings"
+
+	"github.com/Merovius/go-tools/internal/analysisutil"
+	"github.com/Merovius/go-tools/internal/nodefilter"
+	"github.com/Merovius/go-tools/internal/taint"
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/passes/buildssa"
+	"golang.org/x/tools/go/ssa"
+)
+
+const Doc = `check for misuse of the Prometheus client library
+
+The analyzer reports
+  - metrics registered in HTTP handlers, with prometheus.MustRegister, a
+    Registerer or promauto. Registering a metric twice fails, so the second
+    request panics or gets an error. Register metrics once, at package level
+    or when setting up the server,
+  - label values derived from request data users control, like the URL path,
+    query parameters or headers (category "cardinality"). Every distinct
+    value creates a new time series, which users can make arbitrarily many
+    of, exhausting the memory of the program and the monitoring system. The
+    request method and protocol are not reported, and
+  - counters decremented by adding a negative number (category "counter").
+    Counters only go up, Add panics on negative values. Use a gauge for
+    values going up and down.`
+
+var Analyzer = &analysis.Analyzer{
+	Name: "prommetrics",
+	Doc:  Doc,
+	Run:  run,
+	Requires: []*analysis.Analyzer{
+		buildssa.Analyzer,
+		nodefilter.Analyzer,
+	},
+}
+
+const (
+	promPkg     = "github.com/prometheus/client_golang/prometheus"
+	promautoPkg = promPkg + "/promauto"
+)
+
+var calls = nodefilter.New(true, new(ast.CallExpr))
+
+var register = []string{
+	promPkg + ".MustRegister",
+	promPkg + ".Register",
+	"(" + promPkg + ".Registerer).MustRegister",
+	"(" + promPkg + ".Registerer).Register",
+	"(*" + promPkg + ".Registry).MustRegister",
+	"(*" + promPkg + ".Registry).Register",
+}
+
+// requestFields are the fields of http.Request with values users control
+// freely.
+var requestFields = map[string]bool{
+	"URL":        true,
+	"Header":     true,
+	"Form":       true,
+	"PostForm":   true,
+	"RemoteAddr": true,
+	"RequestURI": true,
+	"Host":       true,
+}
+
+// requestCalls are the methods of http.Request returning values users
+// control freely.
+var requestCalls = map[string]bool{
+	"(*net/http.Request).FormValue":     true,
+	"(*net/http.Request).PostFormValue": true,
+	"(*net/http.Request).Cookie":        true,
+	"(*net/http.Request).UserAgent":     true,
+	"(*net/http.Request).Referer":       true,
+}
+
+// labels configures the taint analysis for label values.
+var labels = &taint.Config{
+	Source: source,
+	Propagators: merge(taint.Propagators, map[string]bool{
+		"(net/http.Header).Get":       true,
+		"(*net/url.URL).String":       true,
+		"(*net/url.URL).EscapedPath":  true,
+		"(*net/url.URL).Hostname":     true,
+		"(*net/http.Cookie).String":   true,
+		"strings.Split":               true,
+		"strings.Join":                true,
+		"strconv.Itoa":                true,
+		"(*net/url.URL).RequestURI":   true,
+		"(net/url.Values).Encode":     true,
+		"strings.ToUpper":             true,
+		"strings.Replace":             true,
+		"strings.ReplaceAll":          true,
+		"(*strings.Replacer).Replace": true,
+		"path.Base":                   true,
+		"path.Dir":                    true,
+		"path/filepath.Base":          true,
+		"path/filepath.Dir":           true,
+	}),
+}
+
+func merge(ms ...map[string]bool) map[string]bool {
+	out := make(map[string]bool)
+	for _, m := range ms {
+		for k, v := range m {
+			out[k] = v
+		}
+	}
+	return out
+}
+
+// source returns UserInput for values of requests users control freely.
+func source(v ssa.Value) taint.Kind {
+	switch v := v.(type) {
+	case *ssa.FieldAddr:
+		if analysisutil.IsPointerTo(v.X.Type(), "net/http.Request") && requestFields[fieldName(v.X.Type(), v.Field)] {
+			return taint.UserInput
+		}
+	case *ssa.Call:
+		if requestCalls[taint.Callee(&v.Call)] {
+			return taint.UserInput
+		}
+	}
+	return 0
+}
+
+func fieldName(ptr types.Type, i int) string {
+	s, ok := ptr.Underlying().(*types.Pointer).Elem().Underlying().(*types.Struct)
+	if !ok || i >= s.NumFields() {
+		return ""
+	}
+	return s.Field(i).Name()
+}
+
+func run(pass *analysis.Pass) (interface{}, error) {
+	if !analysisutil.Imports(pass.Pkg, promPkg) && !analysisutil.Imports(pass.Pkg, promautoPkg) {
+		return nil, nil
+	}
+	nodes := pass.ResultOf[nodefilter.Analyzer].(*nodefilter.Result)
+	for _, n := range nodes.Nodes(calls) {
+		call := n.Node.(*ast.CallExpr)
+		if isRegistration(pass, call) {
+			if ft, _ := analysisutil.EnclosingFunc(n.Stack); ft != nil && isHandler(pass, ft) {
+				pass.Reportf(call.Pos(), "metric registered in an HTTP handler; registering it again on the next request fails, so register it once when setting up the server")
+			}
+		}
+		if analysisutil.IsCall(pass.TypesInfo, call, "("+promPkg+".Counter).Add") && len(call.Args) == 1 && isNegative(pass, call.Args[0]) {
+			pass.Report(analysis.Diagnostic{
+				Pos:      call.Pos(),
+				Category: "counter",
+				Message:  "counter decremented by adding a negative value, which panics; counters only go up, use a gauge",
+			})
+		}
+	}
+
+	ssainfo := pass.ResultOf[buildssa.Analyzer].(*buildssa.SSA)
+	for _, fn := range ssainfo.SrcFuncs {
+		checkLabels(pass, fn)
+	}
+	return nil, nil
+}
+
+// isRegistration returns whether call registers a metric.
+func isRegistration(pass *analysis.Pass, call *ast.CallExpr) bool {
+	if analysisutil.IsCall(pass.TypesInfo, call, register...) {
+		return true
+	}
+	name := analysisutil.CalleeName(pass.TypesInfo, call)
+	return strings.HasPrefix(name, promautoPkg+".New") ||
+		strings.HasPrefix(name, "("+promautoPkg+".Factory).New")
+}
+
+// isHandler returns whether ft is the type of an HTTP handler function.
+func isHandler(pass *analysis.Pass, ft *ast.FuncType) bool {
+	var params []types.Type
+	for _, f := range ft.Params.List {
+		t := pass.TypesInfo.TypeOf(f.Type)
+		for range f.Names {
+			params = append(params, t)
+		}
+		if len(f.Names) == 0 {
+			params = append(params, t)
+		}
+	}
+	return len(params) == 2 &&
+		analysisutil.IsType(params[0], "net/http.ResponseWriter") &&
+		analysisutil.IsPointerTo(params[1], "net/http.Request")
+}
+
+func isNegative(pass *analysis.Pass, e ast.Expr) bool {
+	e = analysisutil.Unparen(e)
+	if tv := pass.TypesInfo.Types[e]; tv.Value != nil {
+		return constant.Sign(tv.Value) < 0
+	}
+	u, ok := e.(*ast.UnaryExpr)
+	return ok && u.Op == token.SUB
+}
+
+// checkLabels reports label values in fn derived from request data.
+func checkLabels(pass *analysis.Pass, fn *ssa.Function) {
+	var uses []*ssa.Call
+	for _, b := range fn.Blocks {
+		for _, instr := range b.Instrs {
+			if call, ok := instr.(*ssa.Call); ok && isLabelCall(&call.Call) {
+				uses = append(uses, call)
+			}
+		}
+	}
+	if len(uses) == 0 {
+		return
+	}
+	tainted := labels.Analyze(fn)
+	for _, call := range uses {
+		args := call.Call.Args
+		if !call.Call.IsInvoke() {
+			// Skip the receiver.
+			args = args[1:]
+		}
+		for _, a := range args {
+			if tainted[a]&taint.UserInput != 0 || mapUpdated(a, tainted) {
+				pass.Report(analysis.Diagnostic{
+					Pos:      call.Pos(),
+					Category: "cardinality",
+					Message:  "label value derived from request data users control; every distinct value creates a new time series, so users can create arbitrarily many. Map it to a bounded set of values first",
+				})
+				break
+			}
+		}
+	}
+}
+
+// isLabelCall returns whether c selects a metric of a vector by labels.
+func isLabelCall(c *ssa.CallCommon) bool {
+	name := taint.Callee(c)
+	if !strings.HasPrefix(name, "(*"+promPkg+".") && !strings.HasPrefix(name, "("+promPkg+".") {
+		return false
+	}
+	for _, m := range []string{").WithLabelValues", ").GetMetricWithLabelValues", ").With", ").GetMetricWith"} {
+		if strings.HasSuffix(name, m) {
+			return true
+		}
+	}
+	return false
+}
+
+// mapUpdated returns whether v is a map literal with tainted values, like
+// prometheus.Labels{"path": r.URL.Path}.
+func mapUpdated(v ssa.Value, tainted taint.Result) bool {
+	mm, ok := v.(*ssa.MakeMap)
+	if !ok || mm.Referrers() == nil {
+		return false
+	}
+	for _, ref := range *mm.Referrers() {
+		if u, ok := ref.(*ssa.MapUpdate); ok && tainted[u.Value]&taint.UserInput != 0 {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,51 @@
+package a
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	requests = promauto.NewCounterVec(prometheus.CounterOpts{Name: "requests"}, []string{"path"})
+	inflight = prometheus.NewCounter(prometheus.CounterOpts{Name: "inflight"})
+)
+
+func init() {
+	prometheus.MustRegister(inflight)
+}
+
+func handler(w http.ResponseWriter, r *http.Request) {
+	c := prometheus.NewCounter(prometheus.CounterOpts{Name: "hits"})
+	prometheus.MustRegister(c)                                       // want `metric registered in an HTTP handler; registering it again on the next request fails, so register it once when setting up the server`
+	promauto.NewCounter(prometheus.CounterOpts{Name: "x"})           // want `metric registered in an HTTP handler`
+	promauto.With(nil).NewCounter(prometheus.CounterOpts{Name: "y"}) // want `metric registered in an HTTP handler`
+
+	requests.WithLabelValues(r.URL.Path).Inc()                            // want `label value derived from request data users control; every distinct value creates a new time series, so users can create arbitrarily many. Map it to a bounded set of values first`
+	requests.WithLabelValues(strings.ToLower(r.Header.Get("User"))).Inc() // want `label value derived from request data`
+	requests.With(prometheus.Labels{"path": r.FormValue("q")}).Inc()      // want `label value derived from request data`
+	requests.WithLabelValues(r.Method).Inc()
+	requests.WithLabelValues(route(r)).Inc()
+
+	inflight.Add(-1) // want `counter decremented by adding a negative value, which panics; counters only go up, use a gauge`
+	n := 2.0
+	inflight.Add(-n)                      // want `counter decremented`
+	requests.WithLabelValues("x").Add(-n) // want `counter decremented`
+	inflight.Add(n)
+}
+
+func route(r *http.Request) string {
+	if strings.HasPrefix(r.URL.Path, "/api/") {
+		return "api"
+	}
+	return "other"
+}
+
+func setup(reg *prometheus.Registry) http.Handler {
+	reg.MustRegister(inflight)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reg.MustRegister(inflight) // want `metric registered in an HTTP handler`
+	})
+}
@@ -0,0 +1,17 @@
+package promauto
+
+import "github.com/prometheus/client_golang/prometheus"
+
+func NewCounter(opts prometheus.CounterOpts) prometheus.Counter { return prometheus.NewCounter(opts) }
+
+func NewCounterVec(opts prometheus.CounterOpts, labels []string) *prometheus.CounterVec {
+	return prometheus.NewCounterVec(opts, labels)
+}
+
+type Factory struct{}
+
+func With(prometheus.Registerer) Factory { return Factory{} }
+
+func (Factory) NewCounter(opts prometheus.CounterOpts) prometheus.Counter {
+	return prometheus.NewCounter(opts)
+}
@@ -0,0 +1,46 @@
+package prometheus
+
+type Collector interface{ Describe() }
+
+type Registerer interface {
+	Register(Collector) error
+	MustRegister(...Collector)
+}
+
+type Registry struct{}
+
+func NewRegistry() *Registry                { return new(Registry) }
+func (*Registry) Register(Collector) error  { return nil }
+func (*Registry) MustRegister(...Collector) {}
+func MustRegister(...Collector)             {}
+func Register(Collector) error              { return nil }
+
+type Labels map[string]string
+
+type CounterOpts struct{ Name string }
+
+type Counter interface {
+	Collector
+	Inc()
+	Add(float64)
+}
+
+type counter struct{}
+
+func (counter) Describe()   {}
+func (counter) Inc()        {}
+func (counter) Add(float64) {}
+
+func NewCounter(CounterOpts) Counter { return counter{} }
+
+type CounterVec struct{}
+
+func (*CounterVec) Describe()                         {}
+func NewCounterVec(CounterOpts, []string) *CounterVec { return new(CounterVec) }
+func (*CounterVec) WithLabelValues(...string) Counter { return counter{} }
+func (*CounterVec) With(Labels) Counter               { return counter{} }
+
+type Gauge interface {
+	Collector
+	Add(float64)
+}
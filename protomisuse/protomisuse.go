@@ -0,0 +1,277 @@
+// Copyright 2019 Axel Wagner
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package protomisuse defines an Analyzer that checks for misuse of types
+// generated by the protocol buffer compiler.
+package protomisuse
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+	"strings"
+
+	"github.com/Merovius/go-tools/internal/analysisutil"
+	"github.com/Merovius/go-tools/internal/nodefilter"
+	"golang.org/x/tools/go/analysis"
+)
+
+const Doc = `check for misuse of protocol buffer messages
+
+Types generated by protoc-gen-go are meant to be handled through pointers and
+the functions of the proto package. The analyzer reports
+  - comparisons of message values with == or !=, which compare nested
+    messages by pointer instead of by their contents; use proto.Equal,
+  - maps keyed by messages, which have the same problem,
+  - copies of messages containing sync fields, like those generated by
+    protoc-gen-go since APIv2, which share internal state with the original,
+    and
+  - assignments to fields of messages returned by getters. Getters return nil
+    if the field isn't set, so the assignment panics, and some generators
+    return shared default instances instead, so it modifies those.`
+
+var Analyzer = &analysis.Analyzer{
+	Name: "protomisuse",
+	Doc:  Doc,
+	Run:  run,
+	Requires: []*analysis.Analyzer{
+		nodefilter.Analyzer,
+	},
+}
+
+var (
+	compares = nodefilter.New(false, new(ast.BinaryExpr))
+	maps     = nodefilter.New(false, new(ast.MapType))
+	copies   = nodefilter.New(false, new(ast.AssignStmt), new(ast.ValueSpec), new(ast.CallExpr),
+		new(ast.ReturnStmt), new(ast.CompositeLit), new(ast.RangeStmt))
+	mutations = nodefilter.New(false, new(ast.AssignStmt), new(ast.IncDecStmt))
+)
+
+// isMessage returns whether t is a named type generated for a protocol
+// buffer message, recognized by the ProtoMessage or ProtoReflect method of
+// its pointer type.
+func isMessage(t types.Type) bool {
+	n, ok := t.(*types.Named)
+	if !ok {
+		return false
+	}
+	if _, ok := n.Underlying().(*types.Struct); !ok {
+		return false
+	}
+	ms := types.NewMethodSet(types.NewPointer(n))
+	for _, name := range []string{"ProtoMessage", "ProtoReflect"} {
+		sel := ms.Lookup(nil, name)
+		if sel == nil {
+			continue
+		}
+		if sig, ok := sel.Type().(*types.Signature); ok && sig.Params().Len() == 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// isMessagePointer returns whether t is a pointer to a message.
+func isMessagePointer(t types.Type) bool {
+	p, ok := t.(*types.Pointer)
+	return ok && isMessage(p.Elem())
+}
+
+// hasSync returns whether values of t contain a type from package sync,
+// which must not be copied.
+func hasSync(t types.Type) bool {
+	return hasSyncSeen(t, make(map[types.Type]bool))
+}
+
+func hasSyncSeen(t types.Type, seen map[types.Type]bool) bool {
+	if seen[t] {
+		return false
+	}
+	seen[t] = true
+	if n, ok := t.(*types.Named); ok && n.Obj().Pkg() != nil && n.Obj().Pkg().Path() == "sync" {
+		return true
+	}
+	switch u := t.Underlying().(type) {
+	case *types.Struct:
+		for i := 0; i < u.NumFields(); i++ {
+			if hasSyncSeen(u.Field(i).Type(), seen) {
+				return true
+			}
+		}
+	case *types.Array:
+		return hasSyncSeen(u.Elem(), seen)
+	}
+	return false
+}
+
+func run(pass *analysis.Pass) (interface{}, error) {
+	nodes := pass.ResultOf[nodefilter.Analyzer].(*nodefilter.Result)
+	for _, n := range nodes.Nodes(compares) {
+		checkCompare(pass, n.Node.(*ast.BinaryExpr))
+	}
+	for _, n := range nodes.Nodes(maps) {
+		checkMap(pass, n.Node.(*ast.MapType))
+	}
+	for _, n := range nodes.Nodes(copies) {
+		for _, e := range copiedValues(n.Node) {
+			checkCopy(pass, e)
+		}
+	}
+	for _, n := range nodes.Nodes(mutations) {
+		switch n := n.Node.(type) {
+		case *ast.AssignStmt:
+			if n.Tok == token.DEFINE {
+				continue
+			}
+			for _, l := range n.Lhs {
+				checkMutation(pass, l)
+			}
+		case *ast.IncDecStmt:
+			checkMutation(pass, n.X)
+		}
+	}
+	return nil, nil
+}
+
+func checkCompare(pass *analysis.Pass, e *ast.BinaryExpr) {
+	if e.Op != token.EQL && e.Op != token.NEQ {
+		return
+	}
+	if analysisutil.IsConst(pass.TypesInfo, e.X) || analysisutil.IsConst(pass.TypesInfo, e.Y) {
+		// Comparing with nil is fine.
+		return
+	}
+	x, y := pass.TypesInfo.TypeOf(e.X), pass.TypesInfo.TypeOf(e.Y)
+	if x == nil || y == nil {
+		return
+	}
+	// Pointers are compared to check the identity of messages, which is
+	// fine.
+	if isMessage(x) && isMessage(y) {
+		pass.Report(analysis.Diagnostic{
+			Pos:     e.Pos(),
+			End:     e.End(),
+			Message: "protobuf messages compared with " + e.Op.String() + "; use proto.Equal to compare their contents",
+		})
+	}
+}
+
+func checkMap(pass *analysis.Pass, m *ast.MapType) {
+	k := pass.TypesInfo.TypeOf(m.Key)
+	if k == nil || !isMessage(k) && !isMessagePointer(k) {
+		return
+	}
+	pass.Report(analysis.Diagnostic{
+		Pos:      m.Pos(),
+		End:      m.End(),
+		Category: "mapkey",
+		Message:  "map keyed by protobuf message " + types.TypeString(k, types.RelativeTo(pass.Pkg)) + " doesn't compare keys by their contents",
+	})
+}
+
+// copiedValues returns the expressions whose values are copied by n.
+func copiedValues(n ast.Node) []ast.Expr {
+	switch n := n.(type) {
+	case *ast.AssignStmt:
+		return n.Rhs
+	case *ast.ValueSpec:
+		return n.Values
+	case *ast.CallExpr:
+		return n.Args
+	case *ast.ReturnStmt:
+		return n.Results
+	case *ast.CompositeLit:
+		var es []ast.Expr
+		for _, e := range n.Elts {
+			if kv, ok := e.(*ast.KeyValueExpr); ok {
+				e = kv.Value
+			}
+			es = append(es, e)
+		}
+		return es
+	case *ast.RangeStmt:
+		if n.Value != nil {
+			return []ast.Expr{n.Value}
+		}
+	}
+	return nil
+}
+
+func checkCopy(pass *analysis.Pass, e ast.Expr) {
+	e = analysisutil.Unparen(e)
+	switch e := e.(type) {
+	case *ast.Ident:
+		if _, ok := pass.TypesInfo.ObjectOf(e).(*types.Var); !ok {
+			return
+		}
+	case *ast.SelectorExpr, *ast.IndexExpr, *ast.StarExpr:
+	default:
+		// Other expressions create new values, which can't be shared.
+		return
+	}
+	t := pass.TypesInfo.TypeOf(e)
+	if t == nil || !isMessage(t) || !hasSync(t) {
+		return
+	}
+	pass.Report(analysis.Diagnostic{
+		Pos:      e.Pos(),
+		End:      e.End(),
+		Category: "copy",
+		Message:  "protobuf message " + types.TypeString(t, types.RelativeTo(pass.Pkg)) + " is copied, but contains sync fields; use a pointer or proto.Clone",
+	})
+}
+
+// checkMutation reports if the assignment to l modifies a message returned
+// by a getter.
+func checkMutation(pass *analysis.Pass, l ast.Expr) {
+	call := getterCall(analysisutil.Unparen(l))
+	if call == nil {
+		return
+	}
+	fn := analysisutil.Callee(pass.TypesInfo, call)
+	if fn == nil || len(call.Args) != 0 || !strings.HasPrefix(fn.Name(), "Get") {
+		return
+	}
+	sig := fn.Type().(*types.Signature)
+	if sig.Recv() == nil || !isMessagePointer(sig.Recv().Type()) || sig.Results().Len() != 1 || !isMessagePointer(sig.Results().At(0).Type()) {
+		return
+	}
+	pass.Report(analysis.Diagnostic{
+		Pos:      call.Pos(),
+		End:      call.End(),
+		Category: "getter",
+		Message:  "assignment to a field of the message returned by " + fn.Name() + ", which may be nil or shared; set the field of the parent message instead",
+	})
+}
+
+// getterCall returns the call whose result is the base of the selectors,
+// index and dereference expressions in e, if any.
+func getterCall(e ast.Expr) *ast.CallExpr {
+	for {
+		switch x := e.(type) {
+		case *ast.SelectorExpr:
+			e = x.X
+		case *ast.IndexExpr:
+			e = x.X
+		case *ast.StarExpr:
+			e = x.X
+		default:
+			return nil
+		}
+		if call, ok := analysisutil.Unparen(e).(*ast.CallExpr); ok {
+			return call
+		}
+		e = analysisutil.Unparen(e)
+	}
+}
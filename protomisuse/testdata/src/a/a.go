@@ -0,0 +1,52 @@
+package a
+
+import "google.golang.org/protobuf/proto"
+
+type plain struct{ n int }
+
+func compare(u, v *User, l, k *Legacy, p, q plain, a, b *Point) {
+	_ = *a == *b      // want `protobuf messages compared with ==; use proto.Equal to compare their contents`
+	_ = *a != Point{} // want `protobuf messages compared with !=`
+	// Comparing pointers checks the identity of messages.
+	_ = u == v
+	_ = l != k
+	_ = a == b
+	_ = u == nil
+	_ = p == q
+	_ = proto.Equal(u, v)
+}
+
+var byUser map[*User]int // want `map keyed by protobuf message \*User doesn't compare keys by their contents`
+
+var byName map[string]*User
+
+func copies(u *User, us []User, l *Legacy) User {
+	v := *u                // want `protobuf message User is copied, but contains sync fields; use a pointer or proto.Clone`
+	use(v)                 // want `protobuf message User is copied`
+	for _, w := range us { // want `protobuf message User is copied`
+		_ = w.Name
+	}
+	for i := range us {
+		_ = us[i].Name
+	}
+	m := *l
+	_ = m
+	_ = []User{us[0]} // want `protobuf message User is copied`
+	_ = User{Name: u.Name}
+	return *u // want `protobuf message User is copied`
+}
+
+func use(User) {}
+
+func getters(u *User) {
+	u.GetAddress().City = "Berlin"           // want `assignment to a field of the message returned by GetAddress, which may be nil or shared; set the field of the parent message instead`
+	u.GetAddress().Zip++                     // want `assignment to a field of the message returned by GetAddress`
+	(u.GetAddress()).Lines[0] = "Main St."   // want `assignment to a field of the message returned by GetAddress`
+	*u.GetAddress() = Address{City: "Paris"} // want `assignment to a field of the message returned by GetAddress`
+	u.Address.City = "Berlin"
+	if a := u.GetAddress(); a != nil {
+		a.City = "Berlin"
+	}
+	name := u.GetName()
+	_ = name
+}
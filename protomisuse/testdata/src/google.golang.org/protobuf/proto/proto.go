@@ -0,0 +1,9 @@
+package proto
+
+type Message interface {
+	ProtoReflect()
+}
+
+func Equal(x, y Message) bool { return false }
+
+func Clone(m Message) Message { return m }
@@ -0,0 +1,14 @@
+package protoimpl
+
+import "sync"
+
+type DoNotCopy [0]sync.Mutex
+
+type MessageState struct {
+	DoNotCopy
+	info *int
+}
+
+type SizeCache = int32
+
+type UnknownFields = []byte
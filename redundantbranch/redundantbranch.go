@@ -17,43 +17,53 @@
 package redundantbranch
 
 import (
+	"bytes"
 	"go/ast"
 	"go/token"
+	"io/ioutil"
 	"strings"
 
+	"github.com/Merovius/go-tools/internal/fix"
+	"github.com/Merovius/go-tools/internal/nodefilter"
 	"golang.org/x/tools/go/analysis"
-	"golang.org/x/tools/go/analysis/passes/inspect"
-	"golang.org/x/tools/go/ast/inspector"
 )
 
 const Doc = `check for goto/break/continue statements that don't affect control flow
 
 Examples are a break as the last statement in a case clause, a continue as the
-last statement in a loop or a goto jumping to the next statement. We also take into account nested loops and statements.`
+last statement in a loop or a goto jumping to the next statement. We also take into account nested loops and statements.
+
+The suggested fix deletes the statement, and its label if nothing else
+refers to it anymore.`
+
+var allowTerminalBreak bool
+
+func init() {
+	Analyzer.Flags.BoolVar(&allowTerminalBreak, "allow-terminal-break", false, "don't report a break as the last statement of a case clause")
+	fix.Register(Analyzer, fix.Safe)
+}
 
 var Analyzer = &analysis.Analyzer{
 	Name: "redundantbranch",
 	Doc:  Doc,
 	Run:  run,
 	Requires: []*analysis.Analyzer{
-		inspect.Analyzer,
+		nodefilter.Analyzer,
 	},
 }
 
-func run(pass *analysis.Pass) (interface{}, error) {
-	insp := pass.ResultOf[inspect.Analyzer].(*inspector.Inspector)
+var branches = nodefilter.New(true, new(ast.BranchStmt))
 
-	types := []ast.Node{
-		new(ast.BranchStmt),
-	}
+func run(pass *analysis.Pass) (interface{}, error) {
+	nodes := pass.ResultOf[nodefilter.Analyzer].(*nodefilter.Result)
 
-	insp.WithStack(types, func(n ast.Node, push bool, stack []ast.Node) bool {
-		branch := n.(*ast.BranchStmt)
+	for _, n := range nodes.Nodes(branches) {
+		branch, stack := n.Node.(*ast.BranchStmt), n.Stack
 
 		var ok bool
 		switch branch.Tok {
 		case token.BREAK:
-			ok = checkBreak(pass, stack)
+			ok = (allowTerminalBreak && isTerminalBreak(stack)) || checkBreak(pass, stack)
 		case token.GOTO:
 			ok = checkGoto(stack)
 		case token.CONTINUE:
@@ -62,15 +72,85 @@ func run(pass *analysis.Pass) (interface{}, error) {
 			ok = true
 		}
 		if !ok {
-			pass.Reportf(branch.Pos(), "%s does not affect control flow", strings.ToLower(branch.Tok.String()))
+			tok := strings.ToLower(branch.Tok.String())
+			pass.Report(analysis.Diagnostic{
+				Pos:            branch.Pos(),
+				End:            branch.End(),
+				Message:        tok + " does not affect control flow",
+				SuggestedFixes: removeBranch(pass, tok, stack),
+			})
 		}
-
-		return false
-	})
+	}
 
 	return nil, nil
 }
 
+// removeBranch returns a fix deleting the branch on top of stack, and its
+// label, if the branch was the only statement referring to it.
+func removeBranch(pass *analysis.Pass, tok string, stack []ast.Node) []analysis.SuggestedFix {
+	branch := stack[len(stack)-1].(*ast.BranchStmt)
+	tf := pass.Fset.File(branch.Pos())
+	src, err := ioutil.ReadFile(tf.Name())
+	if err != nil || len(src) != tf.Size() {
+		// Only delete the statement itself.
+		src = nil
+	}
+	sf := analysis.SuggestedFix{
+		Message:   "remove " + tok,
+		TextEdits: []analysis.TextEdit{deleteLines(tf, src, branch.Pos(), branch.End())},
+	}
+	if branch.Label != nil && refs(stack, branch.Label.Obj) == 1 {
+		// Keep the labeled statement, which might be on the same line.
+		l := branch.Label.Obj.Decl.(*ast.LabeledStmt)
+		sf.Message += " and label " + l.Label.Name
+		sf.TextEdits = append(sf.TextEdits, deleteLines(tf, src, l.Pos(), l.Colon+1))
+	}
+	return []analysis.SuggestedFix{sf}
+}
+
+// deleteLines returns an edit deleting the code from pos to end. If nothing
+// else is on its lines, the lines are deleted entirely.
+func deleteLines(tf *token.File, src []byte, pos, end token.Pos) analysis.TextEdit {
+	edit := analysis.TextEdit{Pos: pos, End: end}
+	if src == nil {
+		return edit
+	}
+	first, last := tf.Line(pos), tf.Line(end)
+	start := tf.LineStart(first)
+	next := token.Pos(tf.Base() + tf.Size())
+	if last < tf.LineCount() {
+		next = tf.LineStart(last + 1)
+	}
+	before := src[tf.Offset(start):tf.Offset(pos)]
+	after := src[tf.Offset(end):tf.Offset(next)]
+	if len(bytes.TrimSpace(before)) == 0 && len(bytes.TrimSpace(after)) == 0 {
+		edit.Pos, edit.End = start, next
+	}
+	return edit
+}
+
+// refs returns the number of branch statements referring to the label obj
+// in the function enclosing stack.
+func refs(stack []ast.Node, obj *ast.Object) int {
+	var body *ast.BlockStmt
+	for i := len(stack) - 1; i >= 0 && body == nil; i-- {
+		switch fn := stack[i].(type) {
+		case *ast.FuncDecl:
+			body = fn.Body
+		case *ast.FuncLit:
+			body = fn.Body
+		}
+	}
+	n := 0
+	ast.Inspect(body, func(node ast.Node) bool {
+		if b, ok := node.(*ast.BranchStmt); ok && b.Label != nil && b.Label.Obj == obj {
+			n++
+		}
+		return true
+	})
+	return n
+}
+
 func checkGoto(stack []ast.Node) bool {
 	branch := stack[len(stack)-1].(*ast.BranchStmt)
 
@@ -106,6 +186,25 @@ func checkBreak(pass *analysis.Pass, stack []ast.Node) bool {
 	return next != tgt
 }
 
+// isTerminalBreak returns whether the branch on top of stack is an unlabeled
+// break ending a case clause. Some people use them for symmetry with C.
+func isTerminalBreak(stack []ast.Node) bool {
+	branch := stack[len(stack)-1].(*ast.BranchStmt)
+	if branch.Label != nil {
+		return false
+	}
+	var body []ast.Stmt
+	switch cl := stack[len(stack)-2].(type) {
+	case *ast.CaseClause:
+		body = cl.Body
+	case *ast.CommClause:
+		body = cl.Body
+	default:
+		return false
+	}
+	return body[len(body)-1] == branch
+}
+
 func checkContinue(stack []ast.Node) bool {
 	branch := stack[len(stack)-1].(*ast.BranchStmt)
 
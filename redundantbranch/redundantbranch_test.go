@@ -17,20 +17,29 @@ package redundantbranch
 import (
 	"testing"
 
-	"golang.org/x/tools/go/analysis/analysistest"
+	"github.com/Merovius/go-tools/internal/analysistestx"
 )
 
 func TestBreak(t *testing.T) {
-	testdata := analysistest.TestData()
-	analysistest.Run(t, testdata, Analyzer, "b")
+	testdata := analysistestx.TestData()
+	analysistestx.RunWithSuggestedFixes(t, testdata, Analyzer, "b")
 }
 
 func TestContinue(t *testing.T) {
-	testdata := analysistest.TestData()
-	analysistest.Run(t, testdata, Analyzer, "c")
+	testdata := analysistestx.TestData()
+	analysistestx.RunWithSuggestedFixes(t, testdata, Analyzer, "c")
 }
 
 func TestGoto(t *testing.T) {
-	testdata := analysistest.TestData()
-	analysistest.Run(t, testdata, Analyzer, "g")
+	testdata := analysistestx.TestData()
+	analysistestx.RunWithSuggestedFixes(t, testdata, Analyzer, "g")
+}
+
+func TestAllowTerminalBreak(t *testing.T) {
+	testdata := analysistestx.TestData()
+	analysistestx.RunMatrix(t, testdata, Analyzer, analysistestx.Case{
+		Name:     "allow-terminal-break",
+		Flags:    map[string]string{"allow-terminal-break": "true"},
+		Patterns: []string{"bterm"},
+	})
 }
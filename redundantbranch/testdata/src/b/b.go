@@ -61,3 +61,12 @@ EvLoop:
 		fmt.Println("baz")
 	}
 }
+
+func LabeledSwitch(x int) {
+Sw:
+	switch x {
+	case 1:
+		fmt.Println("one")
+		break Sw // want `break does not affect control flow`
+	}
+}
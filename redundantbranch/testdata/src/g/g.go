@@ -20,3 +20,16 @@ func TestUselessGoto() {
 Foo:
 	return
 }
+
+func UnusedLabel(x int) {
+	if x > 0 {
+		goto done // want `goto does not affect control flow`
+	}
+done:
+	println(x)
+}
+
+func SameLine() {
+	goto end // want `goto does not affect control flow`
+end:
+}
@@ -0,0 +1,406 @@
+// Copyright 2019 Axel Wagner
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package retryloop defines an Analyzer that checks loops retrying failed
+// operations.
+package retryloop
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+	"strings"
+
+	"github.com/Merovius/go-tools/internal/analysisutil"
+	"github.com/Merovius/go-tools/internal/nodefilter"
+	"golang.org/x/tools/go/analysis"
+)
+
+const Doc = `check loops retrying failed operations
+
+A retry loop is a loop calling an operation which returns an error, with
+the same arguments in every iteration, and which ends the loop if the error
+is nil, or continues it if it isn't, like
+
+	for {
+		resp, err := client.Do(req)
+		if err == nil {
+			return resp, nil
+		}
+	}
+
+The analyzer reports retry loops
+  - without a delay between attempts, like a call of time.Sleep, a timer, a
+    receive from a channel or a function named like backoff, sleep, wait or
+    delay. Retrying immediately hammers a failing service and, without a
+    bound, spins the CPU,
+  - retrying an operation which might not be idempotent, like http.Post or
+    the Exec methods of database/sql, which might have taken effect despite
+    the error. These are reported for review, in category "idempotency",
+    and
+  - retrying an operation taking a context on every error, without
+    inspecting it or the context. After the context is canceled or its
+    deadline passed, every attempt fails with the same error.`
+
+var Analyzer = &analysis.Analyzer{
+	Name: "retryloop",
+	Doc:  Doc,
+	Run:  run,
+	Requires: []*analysis.Analyzer{
+		nodefilter.Analyzer,
+	},
+}
+
+// Range loops usually try alternatives, not the same operation again, so
+// only for loops are checked.
+var loops = nodefilter.New(false, new(ast.ForStmt))
+
+var errorType = types.Universe.Lookup("error").Type()
+
+// writes are operations which might not be idempotent.
+var writes = map[string]bool{
+	"net/http.Post":                    true,
+	"net/http.PostForm":                true,
+	"(*net/http.Client).Post":          true,
+	"(*net/http.Client).PostForm":      true,
+	"(*database/sql.DB).Exec":          true,
+	"(*database/sql.DB).ExecContext":   true,
+	"(*database/sql.Tx).Exec":          true,
+	"(*database/sql.Tx).ExecContext":   true,
+	"(*database/sql.Stmt).Exec":        true,
+	"(*database/sql.Stmt).ExecContext": true,
+	"(*database/sql.Conn).ExecContext": true,
+}
+
+// delays are functions waiting between attempts.
+var delays = map[string]bool{
+	"time.Sleep":     true,
+	"time.After":     true,
+	"time.AfterFunc": true,
+	"time.NewTimer":  true,
+	"time.NewTicker": true,
+	"time.Tick":      true,
+}
+
+func run(pass *analysis.Pass) (interface{}, error) {
+	nodes := pass.ResultOf[nodefilter.Analyzer].(*nodefilter.Result)
+	for _, n := range nodes.Nodes(loops) {
+		body := n.Node.(*ast.ForStmt).Body
+		if analysisutil.IsGenerated(pass, n.Pos()) {
+			continue
+		}
+		errVar, op := retry(pass.TypesInfo, body)
+		if errVar == nil || op == nil || !invariant(pass.TypesInfo, n.Node.(*ast.ForStmt), op) {
+			continue
+		}
+		checkLoop(pass, n.Node, body, errVar, op)
+	}
+	return nil, nil
+}
+
+// retry returns the error variable and the operation retried by the loop
+// with the given body, or nils if it isn't a retry loop.
+func retry(info *types.Info, body *ast.BlockStmt) (types.Object, *ast.CallExpr) {
+	var op *ast.CallExpr
+	for _, s := range body.List {
+		if call, _ := errCall(info, s); call != nil {
+			op = call
+		}
+		ifs, ok := s.(*ast.IfStmt)
+		if !ok {
+			continue
+		}
+		if ifs.Init != nil {
+			if call, _ := errCall(info, ifs.Init); call != nil {
+				op = call
+			}
+		}
+		if op == nil {
+			continue
+		}
+		obj, tok := nilComparison(info, ifs.Cond)
+		if obj == nil {
+			continue
+		}
+		switch {
+		case tok == token.EQL && exits(ifs.Body):
+			return obj, op
+		case tok == token.NEQ && continues(ifs.Body):
+			return obj, op
+		}
+	}
+	return nil, nil
+}
+
+// errCall returns the call assigned to an error variable by s, and the
+// variable.
+func errCall(info *types.Info, s ast.Stmt) (*ast.CallExpr, types.Object) {
+	as, ok := s.(*ast.AssignStmt)
+	if !ok || len(as.Rhs) != 1 {
+		return nil, nil
+	}
+	call, ok := analysisutil.Unparen(as.Rhs[0]).(*ast.CallExpr)
+	if !ok {
+		return nil, nil
+	}
+	for _, lhs := range as.Lhs {
+		if obj := analysisutil.ObjectOf(info, lhs); obj != nil && types.Identical(obj.Type(), errorType) {
+			return call, obj
+		}
+	}
+	return nil, nil
+}
+
+// invariant returns whether the operands of call are the same in every
+// iteration of loop, unlike the directory of a loop walking up to the root
+// directory. Variables declared outside of the body and assigned in the
+// loop change between iterations, as do the variables of the body computed
+// from them.
+func invariant(info *types.Info, loop *ast.ForStmt, call *ast.CallExpr) bool {
+	varying := make(map[types.Object]bool)
+	deps := make(map[types.Object][]ast.Expr)
+	assign := func(lhs ast.Expr, rhs ast.Expr) {
+		obj := analysisutil.ObjectOf(info, lhs)
+		if obj == nil {
+			return
+		}
+		if rhs == nil || obj.Pos() < loop.Body.Pos() || obj.Pos() > loop.Body.End() {
+			varying[obj] = true
+			return
+		}
+		deps[obj] = append(deps[obj], rhs)
+	}
+	visit := func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.AssignStmt:
+			for i, l := range n.Lhs {
+				if len(n.Lhs) == len(n.Rhs) {
+					assign(l, n.Rhs[i])
+				} else {
+					assign(l, n.Rhs[0])
+				}
+			}
+		case *ast.IncDecStmt:
+			assign(n.X, nil)
+		case *ast.RangeStmt:
+			if n.Key != nil {
+				assign(n.Key, nil)
+			}
+			if n.Value != nil {
+				assign(n.Value, nil)
+			}
+		}
+		return true
+	}
+	ast.Inspect(loop.Body, visit)
+	if loop.Post != nil {
+		ast.Inspect(loop.Post, visit)
+	}
+	for changed := true; changed; {
+		changed = false
+		for obj, rhs := range deps {
+			if varying[obj] {
+				continue
+			}
+			for _, e := range rhs {
+				if mentions(info, e, varying) {
+					varying[obj] = true
+					changed = true
+					break
+				}
+			}
+		}
+	}
+	if mentions(info, call.Fun, varying) {
+		return false
+	}
+	for _, arg := range call.Args {
+		if mentions(info, arg, varying) {
+			return false
+		}
+	}
+	return true
+}
+
+// mentions returns whether e uses one of the variables in vars.
+func mentions(info *types.Info, e ast.Expr, vars map[types.Object]bool) bool {
+	found := false
+	ast.Inspect(e, func(n ast.Node) bool {
+		if id, ok := n.(*ast.Ident); ok && vars[info.Uses[id]] {
+			found = true
+		}
+		return !found
+	})
+	return found
+}
+
+// nilComparison returns the error variable compared to nil by e and the
+// operator.
+func nilComparison(info *types.Info, e ast.Expr) (types.Object, token.Token) {
+	be, ok := analysisutil.Unparen(e).(*ast.BinaryExpr)
+	if !ok || be.Op != token.EQL && be.Op != token.NEQ {
+		return nil, 0
+	}
+	x, y := be.X, be.Y
+	if info.Types[x].IsNil() {
+		x, y = y, x
+	}
+	if !info.Types[y].IsNil() {
+		return nil, 0
+	}
+	obj := analysisutil.ObjectOf(info, x)
+	if obj == nil || !types.Identical(obj.Type(), errorType) {
+		return nil, 0
+	}
+	return obj, be.Op
+}
+
+// exits returns whether b ends with a return or an unlabeled break.
+func exits(b *ast.BlockStmt) bool {
+	if len(b.List) == 0 {
+		return false
+	}
+	switch s := b.List[len(b.List)-1].(type) {
+	case *ast.ReturnStmt:
+		return true
+	case *ast.BranchStmt:
+		return s.Tok == token.BREAK && s.Label == nil
+	}
+	return false
+}
+
+// continues returns whether b ends with an unlabeled continue.
+func continues(b *ast.BlockStmt) bool {
+	if len(b.List) == 0 {
+		return false
+	}
+	s, ok := b.List[len(b.List)-1].(*ast.BranchStmt)
+	return ok && s.Tok == token.CONTINUE && s.Label == nil
+}
+
+func checkLoop(pass *analysis.Pass, loop ast.Node, body *ast.BlockStmt, errVar types.Object, op *ast.CallExpr) {
+	info := pass.TypesInfo
+	var delayed, inspected bool
+	ast.Inspect(body, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.FuncLit:
+			return false
+		case *ast.UnaryExpr:
+			if n.Op == token.ARROW {
+				delayed = true
+			}
+		case *ast.SelectStmt:
+			delayed = true
+		case *ast.CallExpr:
+			fn := analysisutil.Callee(info, n)
+			if fn == nil {
+				break
+			}
+			name := strings.ToLower(fn.Name())
+			if delays[analysisutil.FuncName(fn)] || strings.Contains(name, "sleep") || strings.Contains(name, "backoff") || strings.Contains(name, "wait") || strings.Contains(name, "delay") {
+				delayed = true
+			}
+			if isContext(fn) && (fn.Name() == "Err" || fn.Name() == "Done") {
+				inspected = true
+			}
+			for _, arg := range n.Args {
+				if analysisutil.ObjectOf(info, arg) == errVar && n != op && inspects(fn) {
+					inspected = true
+				}
+			}
+		case *ast.SelectorExpr:
+			// Methods of the error, like Timeout.
+			if analysisutil.ObjectOf(info, n.X) == errVar {
+				inspected = true
+			}
+		case *ast.TypeAssertExpr:
+			if analysisutil.ObjectOf(info, n.X) == errVar {
+				inspected = true
+			}
+		case *ast.TypeSwitchStmt:
+			inspected = inspected || usesVar(info, n.Assign, errVar)
+		case *ast.SwitchStmt:
+			if n.Tag != nil && analysisutil.ObjectOf(info, n.Tag) == errVar {
+				inspected = true
+			}
+		case *ast.BinaryExpr:
+			if (n.Op == token.EQL || n.Op == token.NEQ) && (analysisutil.ObjectOf(info, n.X) == errVar || analysisutil.ObjectOf(info, n.Y) == errVar) {
+				if obj, _ := nilComparison(info, n); obj == nil {
+					inspected = true
+				}
+			}
+		}
+		return true
+	})
+
+	name := analysisutil.CalleeName(info, op)
+	if name == "" {
+		name = analysisutil.Render(pass.Fset, op.Fun)
+	}
+	if !delayed {
+		pass.Reportf(loop.Pos(), "loop retries %s immediately, without a delay or backoff between attempts", name)
+	}
+	if writes[analysisutil.CalleeName(info, op)] {
+		pass.Report(analysis.Diagnostic{
+			Pos:      op.Pos(),
+			End:      op.End(),
+			Category: "idempotency",
+			Message:  "retrying " + name + ", which might have taken effect despite the error; make sure it is idempotent",
+		})
+	}
+	if !inspected && takesContext(info, op) {
+		pass.Reportf(loop.Pos(), "loop retries %s on every error, including the context being canceled or its deadline passing; check the error or ctx.Err()", name)
+	}
+}
+
+// inspects returns whether a call of fn with an error inspects it, like
+// errors.Is or a function deciding whether to retry.
+func inspects(fn *types.Func) bool {
+	switch analysisutil.FuncName(fn) {
+	case "errors.Is", "errors.As":
+		return true
+	}
+	name := strings.ToLower(fn.Name())
+	for _, s := range []string{"retr", "temporary", "transient", "permanent", "fatal"} {
+		if strings.Contains(name, s) {
+			return true
+		}
+	}
+	return false
+}
+
+func isContext(fn *types.Func) bool {
+	recv := fn.Type().(*types.Signature).Recv()
+	return recv != nil && analysisutil.TypeName(recv.Type()) == "context.Context"
+}
+
+func takesContext(info *types.Info, call *ast.CallExpr) bool {
+	for _, arg := range call.Args {
+		if analysisutil.IsType(info.TypeOf(arg), "context.Context") {
+			return true
+		}
+	}
+	return false
+}
+
+func usesVar(info *types.Info, n ast.Node, v types.Object) bool {
+	found := false
+	ast.Inspect(n, func(n ast.Node) bool {
+		if id, ok := n.(*ast.Ident); ok && info.Uses[id] == v {
+			found = true
+		}
+		return !found
+	})
+	return found
+}
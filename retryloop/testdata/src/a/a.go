@@ -0,0 +1,167 @@
+package a
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+func Spin(url string) (*http.Response, error) {
+	for { // want `loop retries net/http.Get immediately, without a delay or backoff between attempts`
+		resp, err := http.Get(url)
+		if err == nil {
+			return resp, nil
+		}
+		log.Println(err)
+	}
+}
+
+func Sleep(url string) (*http.Response, error) {
+	var err error
+	for i := 0; i < 3; i++ {
+		var resp *http.Response
+		resp, err = http.Get(url)
+		if err == nil {
+			return resp, nil
+		}
+		time.Sleep(time.Duration(i) * time.Second)
+	}
+	return nil, err
+}
+
+func Post(url string) error {
+	for i := 0; i < 3; i++ {
+		resp, err := http.Post(url, "text/plain", strings.NewReader("x")) // want `retrying net/http.Post, which might have taken effect despite the error; make sure it is idempotent`
+		if err != nil {
+			backoff(i)
+			continue
+		}
+		resp.Body.Close()
+		break
+	}
+	return nil
+}
+
+func backoff(attempt int) {}
+
+func Exec(ctx context.Context, db *sql.DB) error {
+	for { // want `loop retries \(\*database/sql.DB\).ExecContext on every error, including the context being canceled`
+		_, err := db.ExecContext(ctx, "UPDATE t SET x = 1") // want `retrying \(\*database/sql.DB\).ExecContext`
+		if err == nil {
+			return nil
+		}
+		<-time.After(time.Second)
+	}
+}
+
+func Query(ctx context.Context, db *sql.DB) error {
+	for {
+		if _, err := db.QueryContext(ctx, "SELECT 1"); err == nil {
+			return nil
+		} else if errors.Is(err, context.Canceled) {
+			return err
+		}
+		time.Sleep(time.Second)
+	}
+}
+
+func Fetch(ctx context.Context, c *http.Client, req *http.Request) error {
+	for attempt := 0; ; attempt++ {
+		err := do(ctx, c, req)
+		if err == nil {
+			return nil
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		time.Sleep(time.Second)
+	}
+}
+
+func Temporary(ctx context.Context, c *http.Client, req *http.Request) error {
+	for {
+		err := do(ctx, c, req)
+		if err == nil {
+			return nil
+		}
+		if !isRetryable(err) {
+			return err
+		}
+		time.Sleep(time.Second)
+	}
+}
+
+func do(ctx context.Context, c *http.Client, req *http.Request) error { return nil }
+
+func isRetryable(err error) bool { return err != io.EOF }
+
+func Alternatives(urls []string) error {
+	for _, u := range urls {
+		if _, err := http.Get(u); err == nil {
+			return nil
+		}
+	}
+	return errors.New("all failed")
+}
+
+func Read(r io.Reader, buf []byte) error {
+	for {
+		n, err := r.Read(buf)
+		if n > 0 {
+			buf = buf[n:]
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// Rebuild builds the request again for every attempt, with the same
+// arguments.
+func Rebuild(url string) (*http.Response, error) {
+	for { // want `loop retries \(\*net/http.Client\).Do immediately`
+		req, err := http.NewRequest("GET", url, nil)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err == nil {
+			return resp, nil
+		}
+	}
+}
+
+// Root looks for a file in dir and its parents.
+func Root(dir string) (string, error) {
+	for {
+		path := filepath.Join(dir, "go.mod")
+		if _, err := os.Stat(path); err == nil {
+			return dir, nil
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", os.ErrNotExist
+		}
+		dir = parent
+	}
+}
+
+func RootFrom(dir string) string {
+	for d := dir; ; {
+		if _, err := os.Stat(filepath.Join(d, "go.mod")); err == nil {
+			return d
+		}
+		parent := filepath.Dir(d)
+		if parent == d {
+			return ""
+		}
+		d = parent
+	}
+}
@@ -0,0 +1,116 @@
+// Copyright 2019 Axel Wagner
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package rwwrapper defines an Analyzer that checks that wrappers of
+// http.ResponseWriter forward the optional interfaces of the wrapped writer.
+package rwwrapper
+
+import (
+	"go/ast"
+	"go/types"
+	"strings"
+
+	"github.com/Merovius/go-tools/internal/analysisutil"
+	"github.com/Merovius/go-tools/internal/nodefilter"
+	"golang.org/x/tools/go/analysis"
+)
+
+const Doc = `check that http.ResponseWriter wrappers forward optional interfaces
+
+Middleware often wraps the http.ResponseWriter passed to a handler, for
+example to record the status code. The ResponseWriter created by the server
+also implements http.Flusher, http.Hijacker and http.Pusher, but a wrapper
+embedding the http.ResponseWriter interface doesn't, so streaming responses,
+WebSockets and server push silently break behind the middleware.
+
+The analyzer reports struct types implementing http.ResponseWriter with a
+field of type http.ResponseWriter, which lack some of the Flush, Hijack and
+Push methods. Wrappers with an Unwrap method returning the wrapped writer are
+not reported, as http.ResponseController uses it to find the methods.`
+
+var Analyzer = &analysis.Analyzer{
+	Name: "rwwrapper",
+	Doc:  Doc,
+	Run:  run,
+	Requires: []*analysis.Analyzer{
+		nodefilter.Analyzer,
+	},
+}
+
+var typeSpecs = nodefilter.New(false, new(ast.TypeSpec))
+
+// optional lists the methods of the optional interfaces of the
+// ResponseWriter created by net/http.
+var optional = []string{"Flush", "Hijack", "Push"}
+
+func run(pass *analysis.Pass) (interface{}, error) {
+	if !analysisutil.Imports(pass.Pkg, "net/http") {
+		return nil, nil
+	}
+	nodes := pass.ResultOf[nodefilter.Analyzer].(*nodefilter.Result)
+	for _, n := range nodes.Nodes(typeSpecs) {
+		spec := n.Node.(*ast.TypeSpec)
+		obj, ok := pass.TypesInfo.Defs[spec.Name].(*types.TypeName)
+		if !ok {
+			continue
+		}
+		st, ok := obj.Type().Underlying().(*types.Struct)
+		if !ok || !wrapsWriter(st) {
+			continue
+		}
+		ms := types.NewMethodSet(types.NewPointer(obj.Type()))
+		if !isWriter(ms) || ms.Lookup(nil, "Unwrap") != nil {
+			continue
+		}
+		var missing []string
+		for _, name := range optional {
+			if ms.Lookup(nil, name) == nil {
+				missing = append(missing, name)
+			}
+		}
+		if len(missing) == 0 {
+			continue
+		}
+		pass.Reportf(spec.Name.Pos(), "ResponseWriter wrapper %s doesn't forward %s of the wrapped writer; implement %s or an Unwrap method",
+			obj.Name(), strings.Join(missing, ", "), plural(len(missing), "it", "them"))
+	}
+	return nil, nil
+}
+
+// wrapsWriter returns whether st has a field of type http.ResponseWriter.
+func wrapsWriter(st *types.Struct) bool {
+	for i := 0; i < st.NumFields(); i++ {
+		if analysisutil.IsType(st.Field(i).Type(), "net/http.ResponseWriter") {
+			return true
+		}
+	}
+	return false
+}
+
+// isWriter returns whether ms contains the methods of http.ResponseWriter.
+func isWriter(ms *types.MethodSet) bool {
+	for _, name := range []string{"Header", "Write", "WriteHeader"} {
+		if ms.Lookup(nil, name) == nil {
+			return false
+		}
+	}
+	return true
+}
+
+func plural(n int, one, many string) string {
+	if n == 1 {
+		return one
+	}
+	return many
+}
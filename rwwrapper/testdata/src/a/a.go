@@ -0,0 +1,67 @@
+package a
+
+import (
+	"bufio"
+	"bytes"
+	"net"
+	"net/http"
+)
+
+type statusWriter struct { // want `ResponseWriter wrapper statusWriter doesn't forward Flush, Hijack, Push of the wrapped writer; implement them or an Unwrap method`
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusWriter) WriteHeader(code int) {
+	w.status = code
+	w.ResponseWriter.WriteHeader(code)
+}
+
+type flushWriter struct { // want `ResponseWriter wrapper flushWriter doesn't forward Hijack, Push of the wrapped writer; implement them or an Unwrap method`
+	http.ResponseWriter
+}
+
+func (w flushWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+type namedWriter struct { // want `ResponseWriter wrapper namedWriter doesn't forward Push of the wrapped writer; implement it or an Unwrap method`
+	w http.ResponseWriter
+}
+
+func (w *namedWriter) Header() http.Header         { return w.w.Header() }
+func (w *namedWriter) Write(p []byte) (int, error) { return w.w.Write(p) }
+func (w *namedWriter) WriteHeader(code int)        { w.w.WriteHeader(code) }
+func (w *namedWriter) Flush()                      { w.w.(http.Flusher).Flush() }
+func (w *namedWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return w.w.(http.Hijacker).Hijack()
+}
+
+type completeWriter struct {
+	*namedWriter
+}
+
+func (w completeWriter) Push(target string, opts *http.PushOptions) error {
+	return w.w.(http.Pusher).Push(target, opts)
+}
+
+type unwrapWriter struct {
+	http.ResponseWriter
+}
+
+func (w *unwrapWriter) Unwrap() http.ResponseWriter { return w.ResponseWriter }
+
+type bufferWriter struct {
+	header http.Header
+	buf    bytes.Buffer
+}
+
+func (w *bufferWriter) Header() http.Header         { return w.header }
+func (w *bufferWriter) Write(p []byte) (int, error) { return w.buf.Write(p) }
+func (w *bufferWriter) WriteHeader(code int)        {}
+
+type notAWriter struct {
+	w http.ResponseWriter
+}
@@ -0,0 +1,197 @@
+// Copyright 2019 Axel Wagner
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package selectmisuse defines an Analyzer that checks for misused select
+// statements.
+package selectmisuse
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+
+	"github.com/Merovius/go-tools/internal/analysisutil"
+	"github.com/Merovius/go-tools/internal/goversion"
+	"github.com/Merovius/go-tools/internal/nodefilter"
+	"golang.org/x/tools/go/analysis"
+)
+
+const Doc = `check for misused select statements
+
+The analyzer reports
+  - selects with a single case and no default, which are plain channel
+    operations written in a more complicated way,
+  - loops consisting of a select with a default which neither waits nor
+    does any work (category "busy"). Such loops spin, burning a CPU until
+    one of the cases is ready. Block in the select instead, or wait in the
+    default, and
+  - calls of time.After in the cases of a select in a loop (category
+    "timer"). Every iteration creates a new timer, and before Go 1.23 they
+    are only garbage collected when they fire. Create a time.Timer outside
+    the loop and reset it instead. Modules whose go directive is go1.23 or
+    later are not reported.`
+
+var Analyzer = &analysis.Analyzer{
+	Name: "selectmisuse",
+	Doc:  Doc,
+	Run:  run,
+	Requires: []*analysis.Analyzer{
+		goversion.Analyzer,
+		nodefilter.Analyzer,
+	},
+}
+
+var selects = nodefilter.New(true, new(ast.SelectStmt))
+
+// waits are functions which block or yield the processor for a while.
+var waits = []string{
+	"time.Sleep",
+	"(*sync.Cond).Wait",
+	"(*sync.WaitGroup).Wait",
+	"(*sync.Mutex).Lock",
+	"(*sync.RWMutex).Lock",
+	"(*sync.RWMutex).RLock",
+}
+
+func run(pass *analysis.Pass) (interface{}, error) {
+	versions := pass.ResultOf[goversion.Analyzer].(*goversion.Result)
+	nodes := pass.ResultOf[nodefilter.Analyzer].(*nodefilter.Result)
+	for _, n := range nodes.Nodes(selects) {
+		sel := n.Node.(*ast.SelectStmt)
+		var (
+			cases int
+			def   *ast.CommClause
+		)
+		for _, s := range sel.Body.List {
+			if cc := s.(*ast.CommClause); cc.Comm == nil {
+				def = cc
+			} else {
+				cases++
+			}
+		}
+		if cases == 1 && def == nil {
+			pass.Reportf(sel.Pos(), "select with a single case and no default; use the channel operation directly")
+		}
+		loop := enclosingLoop(n.Stack)
+		if loop == nil {
+			continue
+		}
+		if def != nil && onlyStatement(loop, sel) && spins(pass, def) {
+			pass.Report(analysis.Diagnostic{
+				Pos:      sel.Pos(),
+				Category: "busy",
+				Message:  "loop around a select with a default which doesn't wait spins until a case is ready; block in the select or wait in the default",
+			})
+		}
+		if versions.AtLeast(analysisutil.File(pass, sel.Pos()), "go1.23") {
+			continue
+		}
+		for _, s := range sel.Body.List {
+			cc := s.(*ast.CommClause)
+			if cc.Comm == nil {
+				continue
+			}
+			ast.Inspect(cc.Comm, func(n ast.Node) bool {
+				if call, ok := n.(*ast.CallExpr); ok && analysisutil.IsCall(pass.TypesInfo, call, "time.After") {
+					pass.Report(analysis.Diagnostic{
+						Pos:      call.Pos(),
+						End:      call.End(),
+						Category: "timer",
+						Message:  "time.After in a select in a loop creates a timer per iteration, which is not garbage collected before it fires; create a time.Timer outside of the loop and reset it",
+					})
+				}
+				return true
+			})
+		}
+	}
+	return nil, nil
+}
+
+// enclosingLoop returns the innermost loop enclosing the last node of stack
+// in the same function, or nil.
+func enclosingLoop(stack []ast.Node) ast.Stmt {
+	for i := len(stack) - 2; i >= 0; i-- {
+		switch n := stack[i].(type) {
+		case *ast.FuncDecl, *ast.FuncLit:
+			return nil
+		case *ast.ForStmt:
+			return n
+		case *ast.RangeStmt:
+			return n
+		}
+	}
+	return nil
+}
+
+// onlyStatement returns whether sel is the only statement of a for loop,
+// which is not a range loop, as those can block in each iteration.
+func onlyStatement(loop ast.Stmt, sel *ast.SelectStmt) bool {
+	f, ok := loop.(*ast.ForStmt)
+	if !ok || len(f.Body.List) != 1 {
+		return false
+	}
+	if l, ok := f.Body.List[0].(*ast.LabeledStmt); ok {
+		return l.Stmt == sel
+	}
+	return f.Body.List[0] == sel
+}
+
+// spins returns whether the default clause def neither leaves the loop nor
+// waits or does work, which is assumed for calls of other functions.
+func spins(pass *analysis.Pass, def *ast.CommClause) bool {
+	spin := true
+	for _, s := range def.Body {
+		ast.Inspect(s, func(n ast.Node) bool {
+			switch n := n.(type) {
+			case *ast.FuncLit:
+				return false
+			case *ast.ReturnStmt, *ast.SendStmt:
+				spin = false
+			case *ast.BranchStmt:
+				// Unlabeled breaks leave the select, not the loop.
+				if n.Tok == token.GOTO || n.Label != nil {
+					spin = false
+				}
+			case *ast.UnaryExpr:
+				if n.Op == token.ARROW {
+					spin = false
+				}
+			case *ast.CallExpr:
+				if !cheap(pass, n) {
+					spin = false
+				}
+			}
+			return spin
+		})
+	}
+	return spin
+}
+
+// cheap returns whether call neither waits nor does work: conversions,
+// builtins other than panic and runtime.Gosched, which yields the processor
+// but returns immediately if no other goroutine is runnable.
+func cheap(pass *analysis.Pass, call *ast.CallExpr) bool {
+	if analysisutil.IsCall(pass.TypesInfo, call, waits...) {
+		return false
+	}
+	if tv, ok := pass.TypesInfo.Types[call.Fun]; ok && tv.IsType() {
+		return true
+	}
+	if id, ok := analysisutil.Unparen(call.Fun).(*ast.Ident); ok {
+		if b, ok := pass.TypesInfo.Uses[id].(*types.Builtin); ok {
+			return b.Name() != "panic"
+		}
+	}
+	return analysisutil.IsCall(pass.TypesInfo, call, "runtime.Gosched")
+}
@@ -0,0 +1,101 @@
+package a
+
+import (
+	"runtime"
+	"time"
+)
+
+func single(ch chan int) int {
+	select { // want `select with a single case and no default`
+	case v := <-ch:
+		return v
+	}
+}
+
+func nonBlocking(ch chan int) (int, bool) {
+	select {
+	case v := <-ch:
+		return v, true
+	default:
+		return 0, false
+	}
+}
+
+func busy(ch chan int, done chan struct{}) {
+	n := 0
+	for {
+		select { // want `loop around a select with a default which doesn't wait spins`
+		case v := <-ch:
+			n += v
+		case <-done:
+			return
+		default:
+			runtime.Gosched()
+		}
+	}
+}
+
+func busyEmpty(ch chan int) {
+	for {
+		select { // want `loop around a select with a default`
+		case <-ch:
+		default:
+		}
+	}
+}
+
+func sleeps(ch chan int) {
+	for {
+		select {
+		case <-ch:
+		case <-time.After(time.Second): // want `time.After in a select in a loop creates a timer per iteration`
+			return
+		default:
+			time.Sleep(time.Millisecond)
+		}
+	}
+}
+
+func works(ch chan int, done chan struct{}) {
+	for {
+		select {
+		case <-done:
+			return
+		default:
+			process()
+		}
+	}
+}
+
+func leaves(ch chan int) {
+loop:
+	for {
+		select {
+		case <-ch:
+		default:
+			break loop
+		}
+	}
+}
+
+func rangeLoop(chs chan chan int) {
+	for ch := range chs {
+		select {
+		case <-ch:
+		default:
+		}
+	}
+}
+
+func timerOutside(ch chan int) {
+	timeout := time.After(time.Minute)
+	for {
+		select {
+		case <-ch:
+		case <-timeout:
+			return
+		}
+	}
+}
+
+func process() {}
@@ -0,0 +1,13 @@
+package modern
+
+import "time"
+
+func timer(ch chan int) {
+	for {
+		select {
+		case <-ch:
+		case <-time.After(time.Second):
+			return
+		}
+	}
+}
@@ -0,0 +1,263 @@
+// Copyright 2019 Axel Wagner
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package since defines an Analyzer that checks that exported identifiers
+// document the version they were added in.
+package since
+
+import (
+	"go/ast"
+	"go/token"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/Merovius/go-tools/internal/analysisutil"
+	"github.com/Merovius/go-tools/internal/apisnap"
+	"github.com/Merovius/go-tools/internal/fix"
+	"github.com/Merovius/go-tools/internal/nodefilter"
+	"golang.org/x/tools/go/analysis"
+)
+
+const Doc = `check that exported identifiers carry a "Since: vX.Y" line
+
+Users of a library need to know which version they have to require for an
+identifier. This analyzer checks that the documentation of exported
+functions, types, variables, constants and methods contains a line like
+
+	// Since: v1.4
+
+Only identifiers missing from the API snapshot given by the -api flag need
+it, so that it can be introduced without annotating all existing API. The
+snapshot is written by "gotools api" when tagging a release. Without the
+flag, the analyzer does nothing; with an empty snapshot, every exported
+identifier needs the line. A group of
+declarations in parentheses can be annotated as a whole in the
+documentation of the group.
+
+If the -version flag is set to the upcoming version, the analyzer suggests
+inserting the line, so "gotools rewrite -analyzer=since" annotates all new
+identifiers. Lines starting with "Since" that don't have the expected form
+are reported as well. Test files, generated files and internal and main
+packages are not checked.`
+
+var Analyzer = &analysis.Analyzer{
+	Name: "since",
+	Doc:  Doc,
+	Run:  run,
+	Requires: []*analysis.Analyzer{
+		nodefilter.Analyzer,
+	},
+}
+
+var (
+	apiFile string
+	version string
+)
+
+func init() {
+	Analyzer.Flags.StringVar(&apiFile, "api", "", "API snapshot `file`; only identifiers missing from it need a Since line")
+	Analyzer.Flags.StringVar(&version, "version", "", "`version` to suggest in inserted Since lines, like v1.4")
+	// The fixes only insert comments.
+	fix.Register(Analyzer, fix.Safe)
+}
+
+var files = nodefilter.New(false, new(ast.File))
+
+var (
+	sinceLine = regexp.MustCompile(`^Since: v\d+(\.\d+){1,2}$`)
+	sinceLike = regexp.MustCompile(`(?i)^since\b`)
+)
+
+// snapshots caches the API snapshots read, by file name, as all packages
+// of a run use the same one.
+var snapshots struct {
+	sync.Mutex
+	m map[string]apisnap.Snapshot
+}
+
+func snapshot(name string) (apisnap.Snapshot, error) {
+	snapshots.Lock()
+	defer snapshots.Unlock()
+	if s, ok := snapshots.m[name]; ok {
+		return s, nil
+	}
+	s, err := apisnap.Read(name)
+	if err != nil {
+		return nil, err
+	}
+	if snapshots.m == nil {
+		snapshots.m = make(map[string]apisnap.Snapshot)
+	}
+	snapshots.m[name] = s
+	return s, nil
+}
+
+func run(pass *analysis.Pass) (interface{}, error) {
+	if apiFile == "" || !apisnap.Public(pass.Pkg) {
+		return nil, nil
+	}
+	snap, err := snapshot(apiFile)
+	if err != nil {
+		return nil, err
+	}
+	c := &checker{pass: pass, snap: snap}
+
+	nodes := pass.ResultOf[nodefilter.Analyzer].(*nodefilter.Result)
+	for _, n := range nodes.Nodes(files) {
+		f := n.Node.(*ast.File)
+		if analysisutil.IsTestFile(pass, f.Pos()) || analysisutil.IsGenerated(pass, f.Pos()) {
+			continue
+		}
+		for _, decl := range f.Decls {
+			c.decl(decl)
+		}
+	}
+	return nil, nil
+}
+
+type checker struct {
+	pass *analysis.Pass
+	snap apisnap.Snapshot
+}
+
+func (c *checker) decl(decl ast.Decl) {
+	switch d := decl.(type) {
+	case *ast.FuncDecl:
+		if !d.Name.IsExported() {
+			return
+		}
+		name := d.Name.Name
+		if d.Recv != nil {
+			recv := receiverName(d.Recv)
+			if !ast.IsExported(recv) {
+				return
+			}
+			name = recv + "." + name
+		}
+		c.check(d.Name, name, d.Doc, d)
+	case *ast.GenDecl:
+		if d.Tok == token.IMPORT {
+			return
+		}
+		if d.Lparen.IsValid() && hasSince(c.pass, d.Doc) {
+			// The group is annotated as a whole.
+			return
+		}
+		for _, spec := range d.Specs {
+			var (
+				doc   *ast.CommentGroup
+				names []*ast.Ident
+			)
+			switch s := spec.(type) {
+			case *ast.TypeSpec:
+				doc, names = s.Doc, []*ast.Ident{s.Name}
+			case *ast.ValueSpec:
+				doc, names = s.Doc, s.Names
+			}
+			var node ast.Node = spec
+			if !d.Lparen.IsValid() {
+				doc, node = d.Doc, d
+			}
+			for _, id := range names {
+				if id.IsExported() {
+					// Specs declaring several identifiers share their
+					// documentation, so one report is enough.
+					c.check(id, id.Name, doc, node)
+					break
+				}
+			}
+		}
+	}
+}
+
+// receiverName returns the name of the base type of a method receiver.
+func receiverName(recv *ast.FieldList) string {
+	if len(recv.List) == 0 {
+		return ""
+	}
+	t := recv.List[0].Type
+	if s, ok := t.(*ast.StarExpr); ok {
+		t = s.X
+	}
+	if id, ok := t.(*ast.Ident); ok {
+		return id.Name
+	}
+	return ""
+}
+
+// check reports the identifier id, declared by node and documented by doc,
+// if it lacks a Since line. name is its name in API snapshots.
+func (c *checker) check(id *ast.Ident, name string, doc *ast.CommentGroup, node ast.Node) {
+	if hasSince(c.pass, doc) {
+		return
+	}
+	if c.snap.Has(c.pass.Pkg.Path(), name) {
+		return
+	}
+	msg := name + " is not in the API snapshot, but has no \"Since: vX.Y\" line in its documentation"
+	d := analysis.Diagnostic{Pos: id.Pos(), End: id.End(), Message: msg}
+	if version != "" {
+		d.SuggestedFixes = []analysis.SuggestedFix{{
+			Message:   "add \"Since: " + version + "\"",
+			TextEdits: []analysis.TextEdit{insertSince(c.pass, doc, node)},
+		}}
+	}
+	c.pass.Report(d)
+}
+
+// hasSince returns whether doc contains a Since line. Malformed lines are
+// reported and count as present, to not report the identifier twice.
+func hasSince(pass *analysis.Pass, doc *ast.CommentGroup) bool {
+	if doc == nil {
+		return false
+	}
+	found := false
+	for _, cm := range doc.List {
+		text := strings.TrimSpace(strings.TrimPrefix(cm.Text, "//"))
+		if !sinceLike.MatchString(text) {
+			continue
+		}
+		found = true
+		if !sinceLine.MatchString(text) {
+			pass.Report(analysis.Diagnostic{
+				Pos:      cm.Pos(),
+				End:      cm.End(),
+				Category: "format",
+				Message:  "malformed Since line " + strconv.Quote(text) + "; write it as \"Since: vX.Y\"",
+			})
+		}
+	}
+	return found
+}
+
+// insertSince returns an edit adding a Since line to doc, or, if doc is
+// nil, a comment with it before node.
+func insertSince(pass *analysis.Pass, doc *ast.CommentGroup, node ast.Node) analysis.TextEdit {
+	if doc != nil {
+		indent := strings.Repeat("\t", pass.Fset.Position(doc.Pos()).Column-1)
+		return analysis.TextEdit{
+			Pos:     doc.End(),
+			End:     doc.End(),
+			NewText: []byte("\n" + indent + "//\n" + indent + "// Since: " + version),
+		}
+	}
+	indent := strings.Repeat("\t", pass.Fset.Position(node.Pos()).Column-1)
+	return analysis.TextEdit{
+		Pos:     node.Pos(),
+		End:     node.Pos(),
+		NewText: []byte("// Since: " + version + "\n" + indent),
+	}
+}
@@ -0,0 +1,40 @@
+// Copyright 2019 Axel Wagner
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package since
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/Merovius/go-tools/internal/analysistestx"
+)
+
+func TestSince(t *testing.T) {
+	dir := analysistestx.TestData()
+	analysistestx.RunMatrix(t, dir, Analyzer,
+		analysistestx.Case{Name: "no snapshot", Patterns: []string{"c"}},
+		analysistestx.Case{
+			Name:     "all",
+			Flags:    map[string]string{"api": filepath.Join(dir, "empty.txt")},
+			Patterns: []string{"a", "internal/x", "m"},
+		},
+		analysistestx.Case{
+			Name:     "snapshot",
+			Flags:    map[string]string{"api": filepath.Join(dir, "api.txt"), "version": "v1.1"},
+			Patterns: []string{"b"},
+			Fixes:    true,
+		},
+	)
+}
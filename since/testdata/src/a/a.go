@@ -0,0 +1,52 @@
+package a
+
+// F does things.
+//
+// Since: v1.2
+func F() {}
+
+// G does other things.
+func G() {} // want `G is not in the API snapshot, but has no "Since: vX.Y" line in its documentation`
+
+func g() {}
+
+// T is a type.
+//
+// Since: v1.0
+type T struct{}
+
+func (T) M() {} // want `T.M is not in the API snapshot, but has no "Since: vX.Y" line`
+
+// N is new.
+//
+// Since: v1.3
+func (*T) N() {}
+
+type t struct{}
+
+func (t) M() {}
+
+// Group of constants.
+//
+// Since: v1.1
+const (
+	A = iota
+	B
+)
+
+var (
+	// X is documented.
+	X int // want `X is not in the API snapshot, but has no "Since: vX.Y" line`
+
+	// Y, Z are documented.
+	//
+	// Since: v1.0
+	Y, Z int
+
+	y int
+)
+
+// Bad has a malformed line.
+//
+// since 1.2 // want `malformed Since line "since 1.2 `
+func Bad() {}
@@ -0,0 +1,20 @@
+package b
+
+// Old was in the last release.
+func Old() {}
+
+// New was added after it.
+func New() {} // want `New is not in the API snapshot, but has no "Since: vX.Y" line in its documentation`
+
+func Undocumented() {} // want `Undocumented is not in the API snapshot`
+
+type T int
+
+func (T) Old() {}
+
+func (T) New() {} // want `T.New is not in the API snapshot`
+
+var (
+	V1 int
+	V2 int // want `V2 is not in the API snapshot`
+)
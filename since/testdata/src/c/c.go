@@ -0,0 +1,4 @@
+package c
+
+// F is not reported without an API snapshot.
+func F() {}
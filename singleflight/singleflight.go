@@ -0,0 +1,282 @@
+// Copyright 2019 Axel Wagner
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package singleflight defines an Analyzer that checks for misuse of
+// golang.org/x/sync/singleflight.
+package singleflight
+
+import (
+	"go/ast"
+	"go/constant"
+	"go/token"
+	"go/types"
+	"strings"
+
+	"github.com/Merovius/go-tools/internal/analysisutil"
+	"github.com/Merovius/go-tools/internal/nodefilter"
+	"golang.org/x/tools/go/analysis"
+)
+
+const Doc = `check for misuse of golang.org/x/sync/singleflight
+
+A singleflight.Group runs a function once for all concurrent callers with
+the same key and hands its result to all of them. The analyzer reports
+  - keys depending on the current time, random numbers or addresses (%p),
+    which differ between callers, so calls are never deduplicated,
+  - results of Do, asserted to a pointer type, whose fields are assigned,
+    as all callers for the key share the pointee, and
+  - results of Do, asserted to a map or slice, whose elements are written,
+    which races with the other callers using it; concurrent writes to a map
+    crash the program.
+
+Results are not reported if the shared result of Do is used, as the code
+presumably copies shared values.`
+
+var Analyzer = &analysis.Analyzer{
+	Name: "singleflight",
+	Doc:  Doc,
+	Run:  run,
+	Requires: []*analysis.Analyzer{
+		nodefilter.Analyzer,
+	},
+}
+
+const groupType = "(*golang.org/x/sync/singleflight.Group)."
+
+var calls = nodefilter.New(true, new(ast.CallExpr))
+
+// random are functions whose results differ between calls.
+var random = []string{
+	"time.Now",
+	"time.Since",
+	"crypto/rand.Int",
+	"crypto/rand.Read",
+	"math/rand.Int",
+	"math/rand.Int31",
+	"math/rand.Int31n",
+	"math/rand.Int63",
+	"math/rand.Int63n",
+	"math/rand.Intn",
+	"math/rand.Float32",
+	"math/rand.Float64",
+	"math/rand.Uint32",
+	"math/rand.Uint64",
+	"(*math/rand.Rand).Int",
+	"(*math/rand.Rand).Int63",
+	"(*math/rand.Rand).Intn",
+	"(*math/rand.Rand).Uint64",
+}
+
+func run(pass *analysis.Pass) (interface{}, error) {
+	if !analysisutil.Imports(pass.Pkg, "golang.org/x/sync/singleflight") {
+		return nil, nil
+	}
+	nodes := pass.ResultOf[nodefilter.Analyzer].(*nodefilter.Result)
+	for _, n := range nodes.Nodes(calls) {
+		call := n.Node.(*ast.CallExpr)
+		if !analysisutil.IsCall(pass.TypesInfo, call, groupType+"Do", groupType+"DoChan", groupType+"Forget") || len(call.Args) == 0 {
+			continue
+		}
+		_, body := analysisutil.EnclosingFunc(n.Stack)
+		if body == nil {
+			continue
+		}
+		if why := nondeterministic(pass, call.Args[0], body); why != "" {
+			pass.Reportf(call.Args[0].Pos(), "singleflight key depends on %s, so it differs between callers and calls are never deduplicated", why)
+		}
+		if as, ok := n.Stack[len(n.Stack)-2].(*ast.AssignStmt); ok && analysisutil.IsCall(pass.TypesInfo, call, groupType+"Do") {
+			checkResult(pass, as, body)
+		}
+	}
+	return nil, nil
+}
+
+// nondeterministic returns what makes the key e differ between calls, or
+// "". Variables defined in body are followed to the expressions assigned
+// to them.
+func nondeterministic(pass *analysis.Pass, e ast.Expr, body *ast.BlockStmt) string {
+	why := ""
+	seen := make(map[types.Object]bool)
+	var visit func(e ast.Node)
+	visit = func(e ast.Node) {
+		ast.Inspect(e, func(n ast.Node) bool {
+			if why != "" {
+				return false
+			}
+			switch n := n.(type) {
+			case *ast.CallExpr:
+				if analysisutil.IsCall(pass.TypesInfo, n, random...) {
+					why = analysisutil.CalleeName(pass.TypesInfo, n)
+				} else if isPointerFormat(pass, n) {
+					why = "an address formatted with %p"
+				}
+			case *ast.Ident:
+				obj := pass.TypesInfo.Uses[n]
+				if obj == nil || seen[obj] || obj.Pos() < body.Pos() || obj.Pos() > body.End() {
+					break
+				}
+				seen[obj] = true
+				for _, v := range assignedTo(pass, obj, body) {
+					visit(v)
+				}
+			}
+			return true
+		})
+	}
+	visit(e)
+	return why
+}
+
+// isPointerFormat returns whether call formats with a %p verb.
+func isPointerFormat(pass *analysis.Pass, call *ast.CallExpr) bool {
+	if !analysisutil.IsCall(pass.TypesInfo, call, "fmt.Sprintf") || len(call.Args) == 0 {
+		return false
+	}
+	tv := pass.TypesInfo.Types[call.Args[0]]
+	return tv.Value != nil && tv.Value.Kind() == constant.String && strings.Contains(constant.StringVal(tv.Value), "%p")
+}
+
+// assignedTo returns the expressions assigned to obj in body.
+func assignedTo(pass *analysis.Pass, obj types.Object, body *ast.BlockStmt) []ast.Expr {
+	var out []ast.Expr
+	ast.Inspect(body, func(n ast.Node) bool {
+		as, ok := n.(*ast.AssignStmt)
+		if !ok || len(as.Lhs) != len(as.Rhs) {
+			return true
+		}
+		for i, l := range as.Lhs {
+			if analysisutil.ObjectOf(pass.TypesInfo, l) == obj {
+				out = append(out, as.Rhs[i])
+			}
+		}
+		return true
+	})
+	return out
+}
+
+// checkResult reports writes to the result of v, err, shared := g.Do(...)
+// through pointers, maps or slices in body.
+func checkResult(pass *analysis.Pass, as *ast.AssignStmt, body *ast.BlockStmt) {
+	if len(as.Lhs) != 3 {
+		return
+	}
+	if shared := analysisutil.ObjectOf(pass.TypesInfo, as.Lhs[2]); shared != nil && used(pass, shared, body) {
+		return
+	}
+	v := analysisutil.ObjectOf(pass.TypesInfo, as.Lhs[0])
+	if v == nil {
+		return
+	}
+	// results are v and the variables v is asserted to.
+	results := map[types.Object]bool{v: true}
+	ast.Inspect(body, func(n ast.Node) bool {
+		a, ok := n.(*ast.AssignStmt)
+		if !ok || len(a.Rhs) != 1 {
+			return true
+		}
+		if ta, ok := analysisutil.Unparen(a.Rhs[0]).(*ast.TypeAssertExpr); ok && analysisutil.ObjectOf(pass.TypesInfo, ta.X) == v {
+			if obj := analysisutil.ObjectOf(pass.TypesInfo, a.Lhs[0]); obj != nil {
+				results[obj] = true
+			}
+		}
+		return true
+	})
+	isResult := func(e ast.Expr) bool {
+		if ta, ok := e.(*ast.TypeAssertExpr); ok {
+			e = ta.X
+		}
+		return results[analysisutil.ObjectOf(pass.TypesInfo, e)]
+	}
+	ast.Inspect(body, func(n ast.Node) bool {
+		var lhs []ast.Expr
+		switch n := n.(type) {
+		case *ast.AssignStmt:
+			if n.Tok == token.DEFINE {
+				return true
+			}
+			lhs = n.Lhs
+		case *ast.IncDecStmt:
+			lhs = []ast.Expr{n.X}
+		case *ast.CallExpr:
+			if id, ok := analysisutil.Unparen(n.Fun).(*ast.Ident); ok && id.Name == "delete" && len(n.Args) == 2 {
+				if _, ok := pass.TypesInfo.Uses[id].(*types.Builtin); ok && isResult(analysisutil.Unparen(n.Args[0])) {
+					reportWrite(pass, n, "map")
+				}
+			}
+			return true
+		default:
+			return true
+		}
+		for _, l := range lhs {
+			switch l := analysisutil.Unparen(l).(type) {
+			case *ast.SelectorExpr:
+				if root := writeRoot(l.X); isResult(root) && isPointer(pass, root) {
+					pass.Reportf(l.Pos(), "assignment to %s modifies the result of singleflight Do, which is shared with all callers for the same key; copy it before modifying it", analysisutil.Render(pass.Fset, l))
+				}
+			case *ast.IndexExpr:
+				if root := writeRoot(l.X); isResult(root) {
+					switch pass.TypesInfo.TypeOf(l.X).Underlying().(type) {
+					case *types.Map:
+						reportWrite(pass, l, "map")
+					case *types.Slice:
+						reportWrite(pass, l, "slice")
+					}
+				}
+			}
+		}
+		return true
+	})
+}
+
+func reportWrite(pass *analysis.Pass, n ast.Node, kind string) {
+	msg := "write to the " + kind + " returned by singleflight Do, which is shared with all callers for the same key, races with them; copy it before modifying it"
+	if kind == "map" {
+		msg += " (concurrent map writes crash the program)"
+	}
+	pass.Reportf(n.Pos(), "%s", msg)
+}
+
+// writeRoot returns the innermost operand of selectors, dereferences and
+// parentheses of e.
+func writeRoot(e ast.Expr) ast.Expr {
+	for {
+		switch x := e.(type) {
+		case *ast.ParenExpr:
+			e = x.X
+		case *ast.SelectorExpr:
+			e = x.X
+		case *ast.StarExpr:
+			e = x.X
+		default:
+			return e
+		}
+	}
+}
+
+func isPointer(pass *analysis.Pass, e ast.Expr) bool {
+	_, ok := pass.TypesInfo.TypeOf(e).Underlying().(*types.Pointer)
+	return ok
+}
+
+// used returns whether obj is used in body.
+func used(pass *analysis.Pass, obj types.Object, body *ast.BlockStmt) bool {
+	found := false
+	ast.Inspect(body, func(n ast.Node) bool {
+		if id, ok := n.(*ast.Ident); ok && pass.TypesInfo.Uses[id] == obj {
+			found = true
+		}
+		return !found
+	})
+	return found
+}
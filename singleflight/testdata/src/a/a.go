@@ -0,0 +1,75 @@
+package a
+
+import (
+	"fmt"
+	"math/rand"
+	"strconv"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+type user struct {
+	name  string
+	inner *user
+}
+
+var g singleflight.Group
+
+func load() (interface{}, error) { return &user{}, nil }
+
+func keys(id int, u *user) {
+	g.Do(strconv.Itoa(id)+time.Now().String(), load) // want `singleflight key depends on time.Now, so it differs between callers and calls are never deduplicated`
+
+	key := fmt.Sprint(id, rand.Intn(10))
+	g.Do(key, load) // want `singleflight key depends on math/rand.Intn`
+
+	k := fmt.Sprintf("user-%p", u)
+	g.DoChan(k, load) // want `singleflight key depends on an address formatted with %p`
+	g.Forget(k)       // want `singleflight key depends on an address formatted with %p`
+
+	g.Do(fmt.Sprintf("user-%d", id), load)
+	g.Do(strconv.Itoa(id), load)
+}
+
+func results() {
+	v, err, _ := g.Do("a", load)
+	if err != nil {
+		return
+	}
+	u := v.(*user)
+	u.name = "x"         // want `assignment to u.name modifies the result of singleflight Do, which is shared with all callers for the same key; copy it before modifying it`
+	u.inner.name = "y"   // want `assignment to u.inner.name modifies the result of singleflight Do`
+	v.(*user).name = "z" // want `assignment to v.\(\*user\).name modifies the result`
+
+	m, _, _ := g.Do("m", load)
+	cache := m.(map[string]int)
+	cache["k"] = 1     // want `write to the map returned by singleflight Do, which is shared with all callers for the same key, races with them; copy it before modifying it \(concurrent map writes crash the program\)`
+	cache["n"]++       // want `write to the map returned by singleflight Do`
+	delete(cache, "k") // want `write to the map returned by singleflight Do`
+
+	s, _, _ := g.Do("s", load)
+	list, ok := s.([]int)
+	if ok {
+		list[0] = 1 // want `write to the slice returned by singleflight Do, which is shared with all callers for the same key, races with them; copy it before modifying it`
+	}
+
+	// Copies and reads.
+	c, _, _ := g.Do("c", load)
+	cp := *c.(*user)
+	cp.name = "copy"
+	fmt.Println(cp.name, cache["k"], list[0])
+	val, _, _ := g.Do("v", load)
+	uv := val.(user)
+	uv.name = "value"
+}
+
+func checked() {
+	v, _, shared := g.Do("a", load)
+	u := v.(*user)
+	if shared {
+		c := *u
+		u = &c
+	}
+	u.name = "x"
+}
@@ -0,0 +1,174 @@
+// Copyright 2019 Axel Wagner
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package sprawl defines an Analyzer that checks for files and packages
+// which grew too large.
+package sprawl
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"strings"
+
+	"github.com/Merovius/go-tools/internal/analysisutil"
+	"github.com/Merovius/go-tools/internal/nodefilter"
+	"golang.org/x/tools/go/analysis"
+)
+
+const Doc = `check for files and packages which grew too large
+
+The analyzer reports
+  - files with more lines or statements than given by the -max-lines and
+    -max-statements flags, and
+  - packages exporting more identifiers than given by the -max-exported
+    flag. Exported package-level constants, variables, types and functions
+    and exported methods of exported types are counted.
+
+Messages end with the measured values, like (lines=1200 statements=480), so
+that dashboards can collect them. Test files and generated files are not
+checked. A limit of 0 disables the check.`
+
+var Analyzer = &analysis.Analyzer{
+	Name: "sprawl",
+	Doc:  Doc,
+	Run:  run,
+	Requires: []*analysis.Analyzer{
+		nodefilter.Analyzer,
+	},
+}
+
+var (
+	maxLines      = 1000
+	maxStatements = 500
+	maxExported   = 100
+)
+
+func init() {
+	Analyzer.Flags.IntVar(&maxLines, "max-lines", maxLines, "maximum number of lines of a file")
+	Analyzer.Flags.IntVar(&maxStatements, "max-statements", maxStatements, "maximum number of statements of a file")
+	Analyzer.Flags.IntVar(&maxExported, "max-exported", maxExported, "maximum number of exported identifiers of a package")
+}
+
+var files = nodefilter.New(false, new(ast.File))
+
+// surface counts the exported identifiers of a package by kind.
+type surface struct {
+	consts, vars, types, funcs, methods int
+}
+
+func (s surface) total() int {
+	return s.consts + s.vars + s.types + s.funcs + s.methods
+}
+
+func run(pass *analysis.Pass) (interface{}, error) {
+	var (
+		exported surface
+		first    *ast.File
+	)
+	nodes := pass.ResultOf[nodefilter.Analyzer].(*nodefilter.Result)
+	for _, n := range nodes.Nodes(files) {
+		f := n.Node.(*ast.File)
+		if analysisutil.IsTestFile(pass, f.Pos()) || analysisutil.IsGenerated(pass, f.Pos()) {
+			continue
+		}
+		if first == nil {
+			first = f
+		}
+		checkFile(pass, f)
+		count(f, &exported)
+	}
+	if first != nil && maxExported > 0 && exported.total() > maxExported {
+		pass.Report(analysis.Diagnostic{
+			Pos:      first.Package,
+			Category: "exported",
+			Message: fmt.Sprintf("package exports %d identifiers, more than the budget of %d; split it (exported=%d consts=%d vars=%d types=%d funcs=%d methods=%d)",
+				exported.total(), maxExported, exported.total(), exported.consts, exported.vars, exported.types, exported.funcs, exported.methods),
+		})
+	}
+	return nil, nil
+}
+
+func checkFile(pass *analysis.Pass, f *ast.File) {
+	lines := pass.Fset.File(f.Pos()).LineCount()
+	stmts := 0
+	ast.Inspect(f, func(n ast.Node) bool {
+		switch n.(type) {
+		case *ast.BlockStmt:
+		case ast.Stmt:
+			stmts++
+		}
+		return true
+	})
+	var over []string
+	if maxLines > 0 && lines > maxLines {
+		over = append(over, fmt.Sprintf("%d lines, more than the budget of %d", lines, maxLines))
+	}
+	if maxStatements > 0 && stmts > maxStatements {
+		over = append(over, fmt.Sprintf("%d statements, more than the budget of %d", stmts, maxStatements))
+	}
+	if len(over) == 0 {
+		return
+	}
+	pass.Reportf(f.Package, "file has %s; split it (lines=%d statements=%d)", strings.Join(over, " and "), lines, stmts)
+}
+
+// count adds the exported identifiers declared in f to s.
+func count(f *ast.File, s *surface) {
+	for _, d := range f.Decls {
+		switch d := d.(type) {
+		case *ast.FuncDecl:
+			if !d.Name.IsExported() {
+				continue
+			}
+			if d.Recv == nil {
+				s.funcs++
+			} else if recvExported(d.Recv) {
+				s.methods++
+			}
+		case *ast.GenDecl:
+			for _, spec := range d.Specs {
+				switch spec := spec.(type) {
+				case *ast.TypeSpec:
+					if spec.Name.IsExported() {
+						s.types++
+					}
+				case *ast.ValueSpec:
+					for _, id := range spec.Names {
+						if !id.IsExported() {
+							continue
+						}
+						if d.Tok == token.CONST {
+							s.consts++
+						} else {
+							s.vars++
+						}
+					}
+				}
+			}
+		}
+	}
+}
+
+func recvExported(recv *ast.FieldList) bool {
+	if len(recv.List) != 1 {
+		return false
+	}
+	t := recv.List[0].Type
+	if s, ok := t.(*ast.StarExpr); ok {
+		t = s.X
+	}
+	id, ok := t.(*ast.Ident)
+	return ok && id.IsExported()
+}
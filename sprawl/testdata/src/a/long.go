@@ -0,0 +1,13 @@
+package a // want `file has 13 lines, more than the budget of 12 and 6 statements, more than the budget of 5; split it \(lines=13 statements=6\)`
+
+func f(x int) int {
+	x++
+	x++
+	x++
+	if x > 0 {
+		x--
+	}
+	return x
+}
+
+// The file has thirteen lines.
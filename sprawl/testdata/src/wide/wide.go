@@ -0,0 +1,17 @@
+package wide // want `file has 17 lines, more than the budget of 12; split it \(lines=17 statements=0\)` `package exports 6 identifiers, more than the budget of 4; split it \(exported=6 consts=1 vars=1 types=2 funcs=1 methods=1\)`
+
+const C, c = 1, 2
+
+var V int
+
+type T struct{}
+
+type U = T
+
+type unexported struct{}
+
+func F() {}
+
+func (T) M() {}
+
+func (unexported) M() {}
@@ -0,0 +1,194 @@
+// Copyright 2019 Axel Wagner
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package sqltx defines an Analyzer that checks the use of database/sql
+// transactions.
+package sqltx
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+
+	"github.com/Merovius/go-tools/internal/analysisutil"
+	"github.com/Merovius/go-tools/internal/nodefilter"
+	"golang.org/x/tools/go/analysis"
+)
+
+const Doc = `check the use of database/sql transactions
+
+The analyzer reports
+  - queries on a *sql.DB while a transaction begun on it in the same
+    function is open, that is before its last Commit or Rollback, which
+    are not part of the transaction. They don't see its changes and might
+    block on the locks it holds, and
+  - calls of (*sql.Tx).Commit whose error is ignored, including deferred
+    calls. If Commit fails, the changes of the transaction are lost.
+
+Deferred calls of Rollback don't end the transaction for the first check,
+as they run when the function returns.`
+
+var Analyzer = &analysis.Analyzer{
+	Name: "sqltx",
+	Doc:  Doc,
+	Run:  run,
+	Requires: []*analysis.Analyzer{
+		nodefilter.Analyzer,
+	},
+}
+
+var (
+	funcs = nodefilter.New(false, new(ast.FuncDecl), new(ast.FuncLit))
+	stmts = nodefilter.New(false, new(ast.ExprStmt), new(ast.AssignStmt), new(ast.DeferStmt), new(ast.GoStmt))
+)
+
+// queries are the methods of *sql.DB running statements.
+var queries = map[string]bool{
+	"Exec": true, "ExecContext": true,
+	"Query": true, "QueryContext": true,
+	"QueryRow": true, "QueryRowContext": true,
+	"Prepare": true, "PrepareContext": true,
+}
+
+func run(pass *analysis.Pass) (interface{}, error) {
+	if !analysisutil.Imports(pass.Pkg, "database/sql") {
+		return nil, nil
+	}
+	nodes := pass.ResultOf[nodefilter.Analyzer].(*nodefilter.Result)
+	for _, n := range nodes.Nodes(funcs) {
+		var body *ast.BlockStmt
+		switch fn := n.Node.(type) {
+		case *ast.FuncDecl:
+			body = fn.Body
+		case *ast.FuncLit:
+			body = fn.Body
+		}
+		if body != nil && !analysisutil.IsGenerated(pass, body.Pos()) {
+			checkFunc(pass, body)
+		}
+	}
+	for _, n := range nodes.Nodes(stmts) {
+		if analysisutil.IsGenerated(pass, n.Pos()) {
+			continue
+		}
+		var call ast.Expr
+		switch s := n.Node.(type) {
+		case *ast.ExprStmt:
+			call = s.X
+		case *ast.AssignStmt:
+			if len(s.Rhs) == 1 && isBlank(s.Lhs) {
+				call = s.Rhs[0]
+			}
+		case *ast.DeferStmt:
+			call = s.Call
+		case *ast.GoStmt:
+			call = s.Call
+		}
+		if c, ok := analysisutil.Unparen(call).(*ast.CallExpr); ok && isCommit(pass.TypesInfo, c) {
+			pass.Reportf(c.Pos(), "the error of Commit is ignored, but the changes of the transaction are lost if it fails")
+		}
+	}
+	return nil, nil
+}
+
+func isBlank(lhs []ast.Expr) bool {
+	for _, e := range lhs {
+		if id, ok := e.(*ast.Ident); !ok || id.Name != "_" {
+			return false
+		}
+	}
+	return true
+}
+
+func isCommit(info *types.Info, call *ast.CallExpr) bool {
+	return analysisutil.IsCall(info, call, "(*database/sql.Tx).Commit")
+}
+
+// A tx is a transaction begun in a function.
+type tx struct {
+	obj   types.Object // the variable holding it
+	db    string       // the receiver of Begin
+	begin token.Pos    // end of the call of Begin
+	end   token.Pos    // of the last Commit or Rollback
+}
+
+// A query is a call of a query method of a *sql.DB.
+type query struct {
+	call   *ast.CallExpr
+	db     string
+	method string
+}
+
+func checkFunc(pass *analysis.Pass, body *ast.BlockStmt) {
+	info := pass.TypesInfo
+	var (
+		txs      []*tx
+		qs       []query
+		deferred = make(map[*ast.CallExpr]bool)
+	)
+	ast.Inspect(body, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.FuncLit:
+			// Checked on its own.
+			return false
+		case *ast.DeferStmt:
+			deferred[n.Call] = true
+		case *ast.AssignStmt:
+			if len(n.Rhs) != 1 {
+				break
+			}
+			call, ok := analysisutil.Unparen(n.Rhs[0]).(*ast.CallExpr)
+			if !ok || !analysisutil.IsCall(info, call, "(*database/sql.DB).Begin", "(*database/sql.DB).BeginTx") {
+				break
+			}
+			if obj := analysisutil.ObjectOf(info, n.Lhs[0]); obj != nil {
+				db := analysisutil.Render(pass.Fset, call.Fun.(*ast.SelectorExpr).X)
+				txs = append(txs, &tx{obj: obj, db: db, begin: call.End(), end: body.End()})
+			}
+		case *ast.CallExpr:
+			fn := analysisutil.Callee(info, n)
+			sel, ok := n.Fun.(*ast.SelectorExpr)
+			if fn == nil || !ok {
+				break
+			}
+			switch name := analysisutil.FuncName(fn); {
+			case name == "(*database/sql.Tx).Commit" || name == "(*database/sql.Tx).Rollback":
+				if deferred[n] {
+					break
+				}
+				obj := analysisutil.ObjectOf(info, sel.X)
+				for _, t := range txs {
+					if t.obj == obj {
+						if t.end == body.End() || n.End() > t.end {
+							t.end = n.End()
+						}
+					}
+				}
+			case name == "(*database/sql.DB)."+fn.Name() && queries[fn.Name()]:
+				qs = append(qs, query{n, analysisutil.Render(pass.Fset, sel.X), fn.Name()})
+			}
+		}
+		return true
+	})
+	for _, q := range qs {
+		for _, t := range txs {
+			if q.db != t.db || q.call.Pos() < t.begin || q.call.Pos() >= t.end {
+				continue
+			}
+			line := pass.Fset.Position(t.begin).Line
+			pass.Reportf(q.call.Pos(), "%s.%s runs outside of the transaction %s, which is open since line %d; use %s.%s", q.db, q.method, t.obj.Name(), line, t.obj.Name(), q.method)
+			break
+		}
+	}
+}
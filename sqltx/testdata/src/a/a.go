@@ -0,0 +1,65 @@
+package a
+
+import (
+	"context"
+	"database/sql"
+)
+
+type Store struct {
+	db    *sql.DB
+	other *sql.DB
+}
+
+func (s *Store) Transfer(ctx context.Context, from, to int) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.ExecContext(ctx, "UPDATE accounts SET balance = balance - 1 WHERE id = ?", from); err != nil {
+		return err
+	}
+	var n int
+	if err := s.db.QueryRowContext(ctx, "SELECT balance FROM accounts WHERE id = ?", to).Scan(&n); err != nil { // want `s.db.QueryRowContext runs outside of the transaction tx, which is open since line 14; use tx.QueryRowContext`
+		return err
+	}
+	if _, err := s.other.ExecContext(ctx, "INSERT INTO audit VALUES (?)", from); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	_, err = s.db.ExecContext(ctx, "DELETE FROM pending WHERE id = ?", from)
+	return err
+}
+
+func Update(db *sql.DB) {
+	tx, err := db.Begin()
+	if err != nil {
+		return
+	}
+	if _, err := tx.Exec("UPDATE t SET x = 1"); err != nil {
+		tx.Rollback()
+		return
+	}
+	db.Exec("UPDATE u SET y = 2") // want `db.Exec runs outside of the transaction tx, which is open since line 37; use tx.Exec`
+	tx.Commit()                   // want `the error of Commit is ignored`
+}
+
+func Deferred(db *sql.DB) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Commit() // want `the error of Commit is ignored`
+	_, err = tx.Exec("UPDATE t SET x = 1")
+	return err
+}
+
+func Blank(db *sql.DB) {
+	tx, _ := db.Begin()
+	go func() {
+		db.Query("SELECT 1")
+	}()
+	_ = tx.Commit() // want `the error of Commit is ignored`
+}
@@ -0,0 +1,227 @@
+// Copyright 2019 Axel Wagner
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package stalemock defines an Analyzer that checks that mocks generated by
+// mockgen still match the interfaces they were generated from.
+package stalemock
+
+import (
+	"go/ast"
+	"go/types"
+	"path/filepath"
+	"strings"
+
+	"github.com/Merovius/go-tools/internal/analysisutil"
+	"github.com/Merovius/go-tools/internal/nodefilter"
+	"golang.org/x/tools/go/analysis"
+)
+
+const Doc = `check for mocks generated by mockgen that are out of date
+
+Mocks generated by github.com/golang/mock/mockgen are not updated when the
+interface they implement changes. A stale mock still compiles as long as it
+isn't used as the interface, and fails only when a test does. The analyzer
+compares the methods of every MockX type in a generated file with those of the
+interface X named in its "// Source:" header, and reports methods which were
+added to, removed from or changed in the interface since the mock was
+generated.
+
+The interface is looked up in the package of the mock and in the packages it
+imports, so mocks of interfaces in packages that aren't imported are not
+checked.`
+
+var Analyzer = &analysis.Analyzer{
+	Name: "stalemock",
+	Doc:  Doc,
+	Run:  run,
+	Requires: []*analysis.Analyzer{
+		nodefilter.Analyzer,
+	},
+}
+
+var typeSpecs = nodefilter.New(false, new(ast.TypeSpec))
+
+// A header is the information mockgen records in the header of a generated
+// file.
+type header struct {
+	// source is the file the mocks were generated from in source mode.
+	source string
+	// pkg and interfaces are the import path and the interfaces the
+	// mocks were generated from in reflect mode.
+	pkg        string
+	interfaces []string
+}
+
+// parseHeader returns the header of the file f, if it was generated by
+// mockgen.
+func parseHeader(f *ast.File) (header, bool) {
+	var (
+		h         header
+		generated bool
+		found     bool
+	)
+	for _, cg := range f.Comments {
+		if cg.Pos() > f.Package {
+			break
+		}
+		for _, c := range cg.List {
+			switch {
+			case strings.HasPrefix(c.Text, "// Code generated by MockGen."):
+				generated = true
+			case strings.HasPrefix(c.Text, "// Source: "):
+				src := strings.TrimSpace(strings.TrimPrefix(c.Text, "// Source: "))
+				if i := strings.Index(src, " (interfaces: "); i >= 0 && strings.HasSuffix(src, ")") {
+					h.pkg = src[:i]
+					for _, name := range strings.Split(src[i+len(" (interfaces: "):len(src)-1], ",") {
+						h.interfaces = append(h.interfaces, strings.TrimSpace(name))
+					}
+				} else {
+					h.source = src
+				}
+				found = true
+			}
+		}
+	}
+	return h, generated && found
+}
+
+func run(pass *analysis.Pass) (interface{}, error) {
+	headers := make(map[*ast.File]header)
+	for _, f := range pass.Files {
+		if h, ok := parseHeader(f); ok {
+			headers[f] = h
+		}
+	}
+	if len(headers) == 0 {
+		return nil, nil
+	}
+	nodes := pass.ResultOf[nodefilter.Analyzer].(*nodefilter.Result)
+	for _, n := range nodes.Nodes(typeSpecs) {
+		spec := n.Node.(*ast.TypeSpec)
+		name := strings.TrimPrefix(spec.Name.Name, "Mock")
+		if name == spec.Name.Name || strings.HasSuffix(name, "MockRecorder") {
+			continue
+		}
+		h, ok := headers[analysisutil.File(pass, spec.Pos())]
+		if !ok {
+			continue
+		}
+		mock, ok := pass.TypesInfo.Defs[spec.Name].(*types.TypeName)
+		if !ok {
+			continue
+		}
+		if h.source != "" {
+			if iface := lookupSource(pass, h.source, name); iface != nil {
+				check(pass, spec, mock, iface)
+			}
+			continue
+		}
+		if !contains(h.interfaces, name) {
+			continue
+		}
+		pkg := lookupPackage(pass.Pkg, h.pkg)
+		if pkg == nil {
+			continue
+		}
+		iface, ok := pkg.Scope().Lookup(name).(*types.TypeName)
+		if !ok || !types.IsInterface(iface.Type()) {
+			pass.Reportf(spec.Name.Pos(), "%s mocks %s.%s, which no longer exists; regenerate the mock", mock.Name(), h.pkg, name)
+			continue
+		}
+		check(pass, spec, mock, iface)
+	}
+	return nil, nil
+}
+
+func contains(s []string, v string) bool {
+	for _, x := range s {
+		if x == v {
+			return true
+		}
+	}
+	return false
+}
+
+// lookupPackage returns pkg or the package it imports with the given path.
+func lookupPackage(pkg *types.Package, path string) *types.Package {
+	if pkg.Path() == path {
+		return pkg
+	}
+	for _, imp := range pkg.Imports() {
+		if imp.Path() == path {
+			return imp
+		}
+	}
+	return nil
+}
+
+// lookupSource returns the interface called name in the package of pass or
+// one of its imports. If there are several, the one declared in a file with
+// the same name as source is preferred.
+func lookupSource(pass *analysis.Pass, source, name string) *types.TypeName {
+	var found []*types.TypeName
+	for _, pkg := range append([]*types.Package{pass.Pkg}, pass.Pkg.Imports()...) {
+		if obj, ok := pkg.Scope().Lookup(name).(*types.TypeName); ok && types.IsInterface(obj.Type()) {
+			found = append(found, obj)
+		}
+	}
+	for _, obj := range found {
+		if filepath.Base(pass.Fset.Position(obj.Pos()).Filename) == filepath.Base(source) {
+			return obj
+		}
+	}
+	if len(found) == 1 {
+		return found[0]
+	}
+	return nil
+}
+
+// check reports the differences between the methods of mock and iface.
+func check(pass *analysis.Pass, spec *ast.TypeSpec, mock, iface *types.TypeName) {
+	named, ok := mock.Type().(*types.Named)
+	if !ok {
+		return
+	}
+	methods := make(map[string]*types.Func)
+	for i := 0; i < named.NumMethods(); i++ {
+		if m := named.Method(i); m.Name() != "EXPECT" {
+			methods[m.Name()] = m
+		}
+	}
+	qual := types.RelativeTo(pass.Pkg)
+	it := iface.Type().Underlying().(*types.Interface)
+	var missing []string
+	for i := 0; i < it.NumMethods(); i++ {
+		want := it.Method(i)
+		got, ok := methods[want.Name()]
+		delete(methods, want.Name())
+		if !ok {
+			missing = append(missing, want.Name())
+			continue
+		}
+		if !types.Identical(got.Type(), want.Type()) {
+			pass.Reportf(got.Pos(), "%s.%s has signature %s, but %s.%s now has signature %s; regenerate the mock",
+				mock.Name(), got.Name(), types.TypeString(got.Type(), qual),
+				iface.Name(), want.Name(), types.TypeString(want.Type(), qual))
+		}
+	}
+	if len(missing) > 0 {
+		pass.Reportf(spec.Name.Pos(), "%s lacks method %s of %s; regenerate the mock", mock.Name(), strings.Join(missing, ", "), iface.Name())
+	}
+	for i := 0; i < named.NumMethods(); i++ {
+		if m := named.Method(i); methods[m.Name()] == m {
+			pass.Reportf(m.Pos(), "%s.%s is no longer a method of %s; regenerate the mock", mock.Name(), m.Name(), iface.Name())
+		}
+	}
+}
@@ -0,0 +1,13 @@
+package a
+
+type Item struct{}
+
+type Store interface {
+	Get(key string) (Item, error)
+	Put(key string, it Item) error
+	Delete(key string, force bool) error
+}
+
+type Clock interface {
+	Now() int64
+}
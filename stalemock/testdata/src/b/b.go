@@ -0,0 +1,5 @@
+package b
+
+type Runner interface {
+	Run(n int) error
+}
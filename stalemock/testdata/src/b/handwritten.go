@@ -0,0 +1,6 @@
+package b
+
+// MockWriter is not generated, so it isn't checked.
+type MockWriter struct{}
+
+func (MockWriter) Write(p []byte) (int, error) { return len(p), nil }
@@ -0,0 +1,308 @@
+// Copyright 2019 Axel Wagner
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package strictdecode defines an Analyzer that checks that configuration
+// is decoded strictly.
+package strictdecode
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"regexp"
+	"strings"
+
+	"github.com/Merovius/go-tools/internal/analysisutil"
+	"github.com/Merovius/go-tools/internal/fix"
+	"github.com/Merovius/go-tools/internal/nodefilter"
+	"golang.org/x/tools/go/analysis"
+)
+
+const Doc = `check that configuration is decoded strictly
+
+By default, encoding/json and the yaml packages ignore keys of the input
+which don't match a field of the struct decoded into. For configuration,
+that means typos in keys are silently dropped and the setting doesn't take
+effect. The analyzer reports decoding into structs whose type (or, for
+unnamed types, variable) name matches the -types regular expression,
+without rejecting unknown keys:
+  - json.Unmarshal, and Decode of a json.Decoder created in the function
+    without a call of DisallowUnknownFields,
+  - for gopkg.in/yaml.v3, Unmarshal, and Decode of a yaml.Decoder created in
+    the function without a call of KnownFields(true), and
+  - for gopkg.in/yaml.v2, Unmarshal, suggesting UnmarshalStrict, and
+    Decode of a Decoder without a call of SetStrict(true).
+
+The -libraries flag selects the libraries to check. Fixes are suggested
+where possible, but they make decoding fail for inputs which were accepted
+before.`
+
+var Analyzer = &analysis.Analyzer{
+	Name: "strictdecode",
+	Doc:  Doc,
+	Run:  run,
+	Requires: []*analysis.Analyzer{
+		nodefilter.Analyzer,
+	},
+}
+
+var (
+	libraries = "json,yaml"
+	typeNames = `(?i)conf|settings|opt(ion)?s|cfg`
+)
+
+func init() {
+	Analyzer.Flags.StringVar(&libraries, "libraries", libraries, "comma-separated libraries to check, json (encoding/json) and yaml (gopkg.in/yaml.v2 and v3)")
+	Analyzer.Flags.StringVar(&typeNames, "types", typeNames, "regular `expression` matching the names of configuration types; . matches all")
+	// Strict decoding rejects inputs accepted before.
+	fix.Register(Analyzer, fix.Unsafe)
+}
+
+var funcs = nodefilter.New(false, new(ast.FuncDecl), new(ast.FuncLit))
+
+// A library is a decoding package.
+type library struct {
+	name      string // in the -libraries flag
+	path      string
+	unmarshal string // the strict replacement of Unmarshal, if any
+	strict    string // the method of decoders making them strict
+	args      string // the arguments of strict
+}
+
+var libs = []library{
+	{name: "json", path: "encoding/json", strict: "DisallowUnknownFields"},
+	{name: "yaml", path: "gopkg.in/yaml.v2", unmarshal: "UnmarshalStrict", strict: "SetStrict", args: "true"},
+	{name: "yaml", path: "gopkg.in/yaml.v3", strict: "KnownFields", args: "true"},
+}
+
+func run(pass *analysis.Pass) (interface{}, error) {
+	re, err := regexp.Compile(typeNames)
+	if err != nil {
+		return nil, fmt.Errorf("invalid -types: %v", err)
+	}
+	enabled := split(libraries)
+	var active []library
+	for _, l := range libs {
+		if enabled[l.name] && analysisutil.Imports(pass.Pkg, l.path) {
+			active = append(active, l)
+		}
+	}
+	if len(active) == 0 {
+		return nil, nil
+	}
+	nodes := pass.ResultOf[nodefilter.Analyzer].(*nodefilter.Result)
+	for _, n := range nodes.Nodes(funcs) {
+		var body *ast.BlockStmt
+		switch fn := n.Node.(type) {
+		case *ast.FuncDecl:
+			body = fn.Body
+		case *ast.FuncLit:
+			body = fn.Body
+		}
+		if body != nil && !analysisutil.IsGenerated(pass, body.Pos()) {
+			c := &checker{pass: pass, re: re, libs: active}
+			c.checkFunc(body)
+		}
+	}
+	return nil, nil
+}
+
+func split(s string) map[string]bool {
+	m := make(map[string]bool)
+	for _, w := range strings.Split(s, ",") {
+		if w = strings.TrimSpace(w); w != "" {
+			m[w] = true
+		}
+	}
+	return m
+}
+
+type checker struct {
+	pass *analysis.Pass
+	re   *regexp.Regexp
+	libs []library
+}
+
+// A decoder is a decoder created in a function.
+type decoder struct {
+	lib    library
+	assign *ast.AssignStmt // creating it
+	strict bool
+}
+
+func (c *checker) checkFunc(body *ast.BlockStmt) {
+	info := c.pass.TypesInfo
+	decoders := make(map[types.Object]*decoder)
+	type decode struct {
+		call *ast.CallExpr
+		dec  types.Object // nil for Decode on other decoders
+		lib  library
+	}
+	var decodes []decode
+	ast.Inspect(body, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.FuncLit:
+			// Checked on its own.
+			return false
+		case *ast.AssignStmt:
+			if len(n.Lhs) != 1 || len(n.Rhs) != 1 {
+				break
+			}
+			call, ok := analysisutil.Unparen(n.Rhs[0]).(*ast.CallExpr)
+			if !ok {
+				break
+			}
+			for _, l := range c.libs {
+				if analysisutil.IsCall(info, call, l.path+".NewDecoder") {
+					if obj := analysisutil.ObjectOf(info, n.Lhs[0]); obj != nil {
+						decoders[obj] = &decoder{lib: l, assign: n}
+					}
+				}
+			}
+		case *ast.CallExpr:
+			fn := analysisutil.Callee(info, n)
+			if fn == nil || fn.Pkg() == nil {
+				break
+			}
+			for _, l := range c.libs {
+				if analysisutil.TrimVendor(fn.Pkg().Path()) != l.path {
+					continue
+				}
+				switch name := analysisutil.FuncName(fn); name {
+				case l.path + ".Unmarshal":
+					decodes = append(decodes, decode{n, nil, l})
+				case "(*" + l.path + ".Decoder).Decode":
+					var dec types.Object
+					if sel, ok := n.Fun.(*ast.SelectorExpr); ok {
+						dec = analysisutil.ObjectOf(info, sel.X)
+					}
+					decodes = append(decodes, decode{n, dec, l})
+				case "(*" + l.path + ".Decoder)." + l.strict:
+					sel, ok := n.Fun.(*ast.SelectorExpr)
+					if !ok {
+						break
+					}
+					if d := decoders[analysisutil.ObjectOf(info, sel.X)]; d != nil && (l.args == "" || len(n.Args) == 1 && isTrue(info, n.Args[0])) {
+						d.strict = true
+					}
+				}
+			}
+		}
+		return true
+	})
+
+	for _, d := range decodes {
+		target := d.call.Args[len(d.call.Args)-1]
+		name, ok := c.configName(target)
+		if !ok {
+			continue
+		}
+		fn := analysisutil.Callee(info, d.call)
+		if fn.Name() == "Unmarshal" {
+			c.reportUnmarshal(d.call, d.lib, name)
+			continue
+		}
+		dec := decoders[d.dec]
+		if dec != nil && dec.strict {
+			continue
+		}
+		if dec == nil && (d.dec != nil || !isNewDecoder(info, d.call, d.lib)) {
+			// A decoder created elsewhere might be strict.
+			continue
+		}
+		call := d.lib.strict + "(" + d.lib.args + ")"
+		diag := analysis.Diagnostic{
+			Pos:     d.call.Pos(),
+			End:     d.call.End(),
+			Message: fmt.Sprintf("decoding %s ignores unknown keys, so typos in the configuration are silently dropped; call %s on the decoder", name, call),
+		}
+		if dec != nil {
+			indent := c.indent(dec.assign)
+			recv := analysisutil.Render(c.pass.Fset, dec.assign.Lhs[0])
+			diag.SuggestedFixes = []analysis.SuggestedFix{{
+				Message:   "call " + call,
+				TextEdits: []analysis.TextEdit{{Pos: dec.assign.End(), End: dec.assign.End(), NewText: []byte("\n" + indent + recv + "." + call)}},
+			}}
+		}
+		c.pass.Report(diag)
+	}
+}
+
+func (c *checker) reportUnmarshal(call *ast.CallExpr, l library, name string) {
+	diag := analysis.Diagnostic{Pos: call.Pos(), End: call.End()}
+	if l.unmarshal == "" {
+		diag.Message = fmt.Sprintf("Unmarshal ignores unknown keys in %s, so typos in the configuration are silently dropped; use a Decoder and call %s(%s)", name, l.strict, l.args)
+		c.pass.Report(diag)
+		return
+	}
+	diag.Message = fmt.Sprintf("Unmarshal ignores unknown keys in %s, so typos in the configuration are silently dropped; use %s", name, l.unmarshal)
+	if sel, ok := call.Fun.(*ast.SelectorExpr); ok {
+		diag.SuggestedFixes = []analysis.SuggestedFix{{
+			Message:   "use " + l.unmarshal,
+			TextEdits: []analysis.TextEdit{{Pos: sel.Sel.Pos(), End: sel.Sel.End(), NewText: []byte(l.unmarshal)}},
+		}}
+	}
+	c.pass.Report(diag)
+}
+
+// isNewDecoder returns whether the Decode call is called directly on the
+// result of NewDecoder, like json.NewDecoder(r).Decode(&cfg).
+func isNewDecoder(info *types.Info, call *ast.CallExpr, l library) bool {
+	sel, ok := call.Fun.(*ast.SelectorExpr)
+	if !ok {
+		return false
+	}
+	inner, ok := analysisutil.Unparen(sel.X).(*ast.CallExpr)
+	return ok && analysisutil.IsCall(info, inner, l.path+".NewDecoder")
+}
+
+// configName returns the name of the struct the target of decoding points
+// to, if it is a configuration.
+func (c *checker) configName(target ast.Expr) (string, bool) {
+	info := c.pass.TypesInfo
+	ptr, ok := info.TypeOf(target).(*types.Pointer)
+	if !ok {
+		return "", false
+	}
+	if _, ok := ptr.Elem().Underlying().(*types.Struct); !ok {
+		return "", false
+	}
+	var name string
+	if n, ok := ptr.Elem().(*types.Named); ok {
+		name = n.Obj().Name()
+	} else {
+		e := target
+		if u, ok := analysisutil.Unparen(e).(*ast.UnaryExpr); ok && u.Op == token.AND {
+			e = u.X
+		}
+		obj := analysisutil.ObjectOf(info, e)
+		if obj == nil {
+			return "", false
+		}
+		name = obj.Name()
+	}
+	return name, c.re.MatchString(name)
+}
+
+func isTrue(info *types.Info, e ast.Expr) bool {
+	tv, ok := info.Types[e]
+	return ok && tv.Value != nil && tv.Value.String() == "true"
+}
+
+// indent returns the indentation of the line of n.
+func (c *checker) indent(n ast.Node) string {
+	posn := c.pass.Fset.Position(n.Pos())
+	return strings.Repeat("\t", posn.Column-1)
+}
@@ -0,0 +1,101 @@
+package a
+
+import (
+	"encoding/json"
+	"io"
+
+	yaml2 "gopkg.in/yaml.v2"
+	"gopkg.in/yaml.v3"
+)
+
+type Config struct {
+	Addr string
+}
+
+type ServerSettings struct {
+	Port int
+}
+
+type Event struct {
+	Name string
+}
+
+func jsonDecoder(r io.Reader) (*Config, error) {
+	dec := json.NewDecoder(r)
+	var cfg Config
+	if err := dec.Decode(&cfg); err != nil { // want `decoding Config ignores unknown keys, so typos in the configuration are silently dropped; call DisallowUnknownFields\(\) on the decoder`
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+func jsonStrict(r io.Reader) (*Config, error) {
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	var cfg Config
+	if err := dec.Decode(&cfg); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+func jsonChained(r io.Reader) (ServerSettings, error) {
+	var s ServerSettings
+	err := json.NewDecoder(r).Decode(&s) // want `decoding ServerSettings ignores unknown keys`
+	return s, err
+}
+
+func jsonUnmarshal(b []byte) (*Config, error) {
+	cfg := new(Config)
+	err := json.Unmarshal(b, cfg) // want `Unmarshal ignores unknown keys in Config, so typos in the configuration are silently dropped; use a Decoder and call DisallowUnknownFields\(\)`
+	return cfg, err
+}
+
+func notConfig(b []byte, r io.Reader) error {
+	var e Event
+	if err := json.Unmarshal(b, &e); err != nil {
+		return err
+	}
+	var m map[string]interface{}
+	return json.NewDecoder(r).Decode(&m)
+}
+
+func anonymous(b []byte) error {
+	var opts struct {
+		Verbose bool
+	}
+	return json.Unmarshal(b, &opts) // want `Unmarshal ignores unknown keys in opts`
+}
+
+func passedIn(dec *json.Decoder) (Config, error) {
+	var cfg Config
+	err := dec.Decode(&cfg)
+	return cfg, err
+}
+
+func yamlV2(b []byte, r io.Reader) (Config, error) {
+	var cfg Config
+	if err := yaml2.Unmarshal(b, &cfg); err != nil { // want `Unmarshal ignores unknown keys in Config, so typos in the configuration are silently dropped; use UnmarshalStrict`
+		return cfg, err
+	}
+	if err := yaml2.UnmarshalStrict(b, &cfg); err != nil {
+		return cfg, err
+	}
+	d := yaml2.NewDecoder(r)
+	d.SetStrict(false)
+	return cfg, d.Decode(&cfg) // want `call SetStrict\(true\) on the decoder`
+}
+
+func yamlV3(b []byte, r io.Reader) (Config, error) {
+	var cfg Config
+	if err := yaml.Unmarshal(b, &cfg); err != nil { // want `use a Decoder and call KnownFields\(true\)`
+		return cfg, err
+	}
+	d := yaml.NewDecoder(r)
+	if err := d.Decode(&cfg); err != nil { // want `call KnownFields\(true\) on the decoder`
+		return cfg, err
+	}
+	strict := yaml.NewDecoder(r)
+	strict.KnownFields(true)
+	return cfg, strict.Decode(&cfg)
+}
@@ -0,0 +1,25 @@
+package b
+
+import (
+	"encoding/json"
+
+	"gopkg.in/yaml.v3"
+)
+
+type Event struct {
+	Name string
+}
+
+type Config struct {
+	Addr string
+}
+
+func decode(b []byte) (Event, Config, error) {
+	var e Event
+	if err := json.Unmarshal(b, &e); err != nil { // want `Unmarshal ignores unknown keys in Event`
+		return e, Config{}, err
+	}
+	var cfg Config
+	err := yaml.Unmarshal(b, &cfg)
+	return e, cfg, err
+}
@@ -0,0 +1,263 @@
+// Copyright 2019 Axel Wagner
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package stringerdrift defines an Analyzer that checks that String methods
+// generated by stringer cover all constants of their type.
+package stringerdrift
+
+import (
+	"go/ast"
+	"go/constant"
+	"go/token"
+	"go/types"
+	"sort"
+	"strings"
+
+	"github.com/Merovius/go-tools/internal/analysisutil"
+	"github.com/Merovius/go-tools/internal/nodefilter"
+	"golang.org/x/tools/go/analysis"
+)
+
+const Doc = `check that String methods generated by stringer are up to date
+
+Stringer (golang.org/x/tools/cmd/stringer) generates String methods for the
+constants of a type at the time go generate is run. Constants added later
+print as "T(7)", until the code is generated again. For every
+//go:generate directive running stringer, the analyzer compares the
+constants of the types it names with those known to the generated file, and
+reports the missing ones.
+
+The generated file is found through the String method of the type. Files
+generated by versions of stringer recording the constants in a func _()
+are compared by name, for older ones the constant names are looked up in
+the generated name strings.
+
+Types without a String method are reported in the "missing" category.`
+
+var Analyzer = &analysis.Analyzer{
+	Name: "stringerdrift",
+	Doc:  Doc,
+	Run:  run,
+	Requires: []*analysis.Analyzer{
+		nodefilter.Analyzer,
+	},
+}
+
+var (
+	funcs      = nodefilter.New(false, new(ast.FuncDecl))
+	valueSpecs = nodefilter.New(false, new(ast.ValueSpec))
+)
+
+// A directive is a //go:generate directive running stringer.
+type directive struct {
+	pos         token.Pos
+	types       []string
+	trimPrefix  string
+	lineComment bool
+}
+
+func parseDirective(c *ast.Comment) (directive, bool) {
+	d := directive{pos: c.Pos()}
+	if !strings.HasPrefix(c.Text, "//go:generate ") {
+		return d, false
+	}
+	fields := strings.Fields(strings.TrimPrefix(c.Text, "//go:generate "))
+	i := 0
+	for ; i < len(fields); i++ {
+		if fields[i] == "stringer" || strings.HasSuffix(fields[i], "/stringer") {
+			break
+		}
+	}
+	if i == len(fields) {
+		return d, false
+	}
+	args := fields[i+1:]
+	for j := 0; j < len(args); j++ {
+		name, value := strings.TrimLeft(args[j], "-"), ""
+		if k := strings.IndexByte(name, '='); k >= 0 {
+			name, value = name[:k], name[k+1:]
+		} else if name != "linecomment" && j+1 < len(args) {
+			j++
+			value = args[j]
+		}
+		switch name {
+		case "type":
+			d.types = strings.Split(value, ",")
+		case "trimprefix":
+			d.trimPrefix = value
+		case "linecomment":
+			d.lineComment = value == "" || value == "true"
+		}
+	}
+	return d, len(d.types) > 0
+}
+
+func run(pass *analysis.Pass) (interface{}, error) {
+	nodes := pass.ResultOf[nodefilter.Analyzer].(*nodefilter.Result)
+	for _, f := range pass.Files {
+		for _, cg := range f.Comments {
+			for _, c := range cg.List {
+				if d, ok := parseDirective(c); ok {
+					for _, name := range d.types {
+						check(pass, nodes, d, name)
+					}
+				}
+			}
+		}
+	}
+	return nil, nil
+}
+
+func check(pass *analysis.Pass, nodes *nodefilter.Result, d directive, name string) {
+	tn, ok := pass.Pkg.Scope().Lookup(name).(*types.TypeName)
+	if !ok {
+		return
+	}
+	obj, _, _ := types.LookupFieldOrMethod(tn.Type(), false, pass.Pkg, "String")
+	if obj == nil {
+		pass.Report(analysis.Diagnostic{
+			Pos:      d.pos,
+			Category: "missing",
+			Message:  name + " has no String method; run go generate",
+		})
+		return
+	}
+	f := analysisutil.File(pass, obj.Pos())
+	if f == nil || !generatedByStringer(f) {
+		return
+	}
+	covered, ok := checkedNames(pass, nodes, f, tn.Type())
+	if !ok {
+		if d.lineComment {
+			return
+		}
+		covered = nameString(pass, nodes, f, name, d.trimPrefix)
+	}
+
+	// Constants with the value of a covered one print as its name.
+	var (
+		consts  []*types.Const
+		missing []string
+		values  = make(map[string]bool)
+	)
+	scope := pass.Pkg.Scope()
+	for _, n := range scope.Names() {
+		if c, ok := scope.Lookup(n).(*types.Const); ok && c.Type() == tn.Type() {
+			consts = append(consts, c)
+			if covered(c) {
+				values[c.Val().ExactString()] = true
+			}
+		}
+	}
+	for _, c := range consts {
+		if v := c.Val().ExactString(); !values[v] {
+			values[v] = true
+			missing = append(missing, c.Name())
+		}
+	}
+	if len(missing) == 0 {
+		return
+	}
+	sort.Slice(missing, func(i, j int) bool {
+		a, b := scope.Lookup(missing[i]).(*types.Const), scope.Lookup(missing[j]).(*types.Const)
+		return constant.Compare(a.Val(), token.LSS, b.Val())
+	})
+	var prints []string
+	for _, m := range missing {
+		prints = append(prints, name+"("+scope.Lookup(m).(*types.Const).Val().String()+")")
+	}
+	pass.Reportf(d.pos, "String method of %s in %s doesn't cover %s, which print as %s; run go generate",
+		name, fileName(pass, f), strings.Join(missing, ", "), strings.Join(prints, ", "))
+}
+
+func generatedByStringer(f *ast.File) bool {
+	for _, cg := range f.Comments {
+		if cg.Pos() > f.Package {
+			break
+		}
+		for _, c := range cg.List {
+			if strings.HasPrefix(c.Text, `// Code generated by "stringer `) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func fileName(pass *analysis.Pass, f *ast.File) string {
+	name := pass.Fset.File(f.Pos()).Name()
+	if i := strings.LastIndexAny(name, `/\`); i >= 0 {
+		name = name[i+1:]
+	}
+	return name
+}
+
+// checkedNames returns the constants of type t the generated file f was
+// generated for. Since 2018, stringer records them in a func _(), to cause
+// a compiler error if their values change:
+//
+//	func _() {
+//		var x [1]struct{}
+//		_ = x[Red-0]
+//	}
+//
+// It returns false if f has no such function.
+func checkedNames(pass *analysis.Pass, nodes *nodefilter.Result, f *ast.File, t types.Type) (func(*types.Const) bool, bool) {
+	names := make(map[*types.Const]bool)
+	found := false
+	for _, n := range nodes.Nodes(funcs) {
+		fn := n.Node.(*ast.FuncDecl)
+		if !inFile(f, fn) || fn.Recv != nil || fn.Name.Name != "_" || fn.Body == nil {
+			continue
+		}
+		found = true
+		ast.Inspect(fn.Body, func(n ast.Node) bool {
+			if id, ok := n.(*ast.Ident); ok {
+				if c, ok := pass.TypesInfo.Uses[id].(*types.Const); ok && c.Type() == t {
+					names[c] = true
+				}
+			}
+			return true
+		})
+	}
+	return func(c *types.Const) bool { return names[c] }, found
+}
+
+// nameString returns a function reporting whether the name of a constant is
+// contained in the name strings stringer generated for the type name.
+func nameString(pass *analysis.Pass, nodes *nodefilter.Result, f *ast.File, name, trimPrefix string) func(*types.Const) bool {
+	var all strings.Builder
+	for _, n := range nodes.Nodes(valueSpecs) {
+		if !inFile(f, n.Node) {
+			continue
+		}
+		for _, id := range n.Node.(*ast.ValueSpec).Names {
+			if !strings.HasPrefix(id.Name, "_"+name+"_name") {
+				continue
+			}
+			if c, ok := pass.TypesInfo.Defs[id].(*types.Const); ok && c.Val().Kind() == constant.String {
+				all.WriteString(constant.StringVal(c.Val()))
+				all.WriteByte(0)
+			}
+		}
+	}
+	s := all.String()
+	return func(c *types.Const) bool {
+		return strings.Contains(s, strings.TrimPrefix(c.Name(), trimPrefix))
+	}
+}
+
+func inFile(f *ast.File, n ast.Node) bool {
+	return f.Pos() <= n.Pos() && n.End() <= f.End()
+}
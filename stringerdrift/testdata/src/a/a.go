@@ -0,0 +1,37 @@
+package a
+
+//go:generate stringer -type=Color // want `String method of Color in color_string.go doesn't cover Purple, Pink, which print as Color\(3\), Color\(4\); run go generate`
+type Color int
+
+const (
+	Red Color = iota
+	Green
+	Blue
+	Purple
+	Pink
+	Crimson = Red
+)
+
+//go:generate stringer -type Shape -trimprefix=Shape // want `String method of Shape in shape_string.go doesn't cover ShapeHexagon, which print as Shape\(7\)`
+type Shape int
+
+const (
+	ShapeCircle Shape = iota
+	ShapeSquare
+	ShapeHexagon Shape = 7
+)
+
+//go:generate go run golang.org/x/tools/cmd/stringer -type=Size,Weight // want `Weight has no String method; run go generate`
+type Size int
+
+const (
+	Small Size = iota
+	Large
+)
+
+type Weight int
+
+const (
+	Light Weight = iota
+	Heavy
+)
@@ -0,0 +1,123 @@
+// Copyright 2019 Axel Wagner
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package suite provides curated groups of the analyzers of this
+// repository, to embed in vet tools:
+//
+//	func main() {
+//		multichecker.Main(suite.Correctness()...)
+//	}
+//
+// Groups are selected by the tags of the rules of the analyzers (see
+// internal/rules), so new analyzers join the right suites automatically.
+//
+// Some suites tune the defaults of analyzer flags. As analyzers and their
+// flags are global, calling a suite function sets these defaults on the
+// shared analyzers, overriding those set by earlier calls. Flags given on
+// the command line still take precedence, if they are parsed afterwards, as
+// multichecker.Main does.
+package suite
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/Merovius/go-tools/internal/all"
+	"github.com/Merovius/go-tools/internal/rules"
+	"golang.org/x/tools/go/analysis"
+)
+
+// defaults are the tuned flag defaults of the suites, by namespaced flag
+// name (analyzer.flag).
+var defaults = map[string]map[string]string{
+	// All is meant to be low-noise, so allow common, harmless redundancy.
+	"all": {
+		"redundantbranch.allow-terminal-break": "true",
+	},
+	"style": {
+		"redundantbranch.allow-terminal-break": "false",
+	},
+}
+
+// All returns all analyzers.
+func All() []*analysis.Analyzer {
+	return get("all", nil)
+}
+
+// Correctness returns the analyzers finding bugs.
+func Correctness() []*analysis.Analyzer {
+	return get("correctness", hasTag(rules.Correctness))
+}
+
+// Performance returns the analyzers finding inefficient code.
+func Performance() []*analysis.Analyzer {
+	return get("performance", hasTag(rules.Performance))
+}
+
+// Security returns the analyzers finding vulnerabilities.
+func Security() []*analysis.Analyzer {
+	return get("security", hasTag(rules.Security))
+}
+
+// Style returns the analyzers finding code which is correct, but could be
+// clearer.
+func Style() []*analysis.Analyzer {
+	return get("style", hasTag(rules.Style))
+}
+
+// hasTag returns a predicate selecting analyzers with a rule tagged tag.
+func hasTag(tag string) func(*analysis.Analyzer) bool {
+	return func(a *analysis.Analyzer) bool {
+		for _, r := range rules.All() {
+			if r.Analyzer == a.Name && r.HasTag(tag) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// get returns the analyzers selected by keep (or all, if it is nil) and
+// sets the flag defaults of the suite name.
+func get(name string, keep func(*analysis.Analyzer) bool) []*analysis.Analyzer {
+	var out []*analysis.Analyzer
+	for _, a := range all.Analyzers {
+		if keep == nil || keep(a) {
+			out = append(out, a)
+		}
+	}
+	if err := setDefaults(out, defaults[name]); err != nil {
+		panic(fmt.Sprintf("suite %s: %v", name, err))
+	}
+	return out
+}
+
+func setDefaults(analyzers []*analysis.Analyzer, values map[string]string) error {
+	for _, a := range analyzers {
+		var err error
+		a.Flags.VisitAll(func(f *flag.Flag) {
+			v, ok := values[a.Name+"."+f.Name]
+			if !ok || err != nil {
+				return
+			}
+			if err = f.Value.Set(v); err == nil {
+				f.DefValue = v
+			}
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
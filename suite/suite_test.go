@@ -0,0 +1,125 @@
+// Copyright 2019 Axel Wagner
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package suite
+
+import (
+	"flag"
+	"strings"
+	"testing"
+
+	"github.com/Merovius/go-tools/internal/all"
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/passes/inspect"
+)
+
+func TestSuites(t *testing.T) {
+	inAll := make(map[*analysis.Analyzer]bool)
+	for _, a := range All() {
+		inAll[a] = true
+	}
+	if len(inAll) != len(all.Analyzers) {
+		t.Errorf("All() returned %d analyzers, want %d", len(inAll), len(all.Analyzers))
+	}
+	inSuite := make(map[*analysis.Analyzer]bool)
+	for name, f := range map[string]func() []*analysis.Analyzer{
+		"Correctness": Correctness,
+		"Performance": Performance,
+		"Security":    Security,
+		"Style":       Style,
+	} {
+		as := f()
+		if err := analysis.Validate(as); err != nil {
+			t.Errorf("%s(): %v", name, err)
+		}
+		for _, a := range as {
+			if !inAll[a] {
+				t.Errorf("%s() returned %s, which is not in All()", name, a.Name)
+			}
+			inSuite[a] = true
+		}
+	}
+	for _, a := range all.Analyzers {
+		if !inSuite[a] {
+			t.Errorf("analyzer %s is in no suite", a.Name)
+		}
+	}
+}
+
+func lookup(name string) *flag.Flag {
+	i := strings.LastIndex(name, ".")
+	if i < 0 {
+		return nil
+	}
+	for _, a := range all.Analyzers {
+		if a.Name == name[:i] {
+			return a.Flags.Lookup(name[i+1:])
+		}
+	}
+	return nil
+}
+
+func TestDefaults(t *testing.T) {
+	for name, values := range defaults {
+		for f := range values {
+			if lookup(f) == nil {
+				t.Errorf("suite %s: unknown flag %s", name, f)
+			}
+		}
+	}
+
+	f := lookup("redundantbranch.allow-terminal-break")
+	defer f.Value.Set(f.DefValue)
+	All()
+	if f.Value.String() != "true" || f.DefValue != "true" {
+		t.Errorf("after All(), allow-terminal-break = %s (default %s), want true", f.Value, f.DefValue)
+	}
+	Style()
+	if f.Value.String() != "false" || f.DefValue != "false" {
+		t.Errorf("after Style(), allow-terminal-break = %s (default %s), want false", f.Value, f.DefValue)
+	}
+}
+
+// TestSharedTraversal checks that all analyzers walk syntax trees through
+// the shared inspector, instead of walking them on their own.
+func TestSharedTraversal(t *testing.T) {
+	// Analyzers walking syntax trees the inspector doesn't cover.
+	exempt := map[string]bool{
+		// buildtags only reads the build constraints of files, including
+		// the ones not built.
+		"buildtags": true,
+		// modhygiene only checks go.mod files.
+		"modhygiene": true,
+		// genericbloat only reads the instances recorded by the type
+		// checker.
+		"genericbloat": true,
+	}
+	var shares func(a *analysis.Analyzer) bool
+	shares = func(a *analysis.Analyzer) bool {
+		if a == inspect.Analyzer {
+			return true
+		}
+		for _, req := range a.Requires {
+			if shares(req) {
+				return true
+			}
+		}
+		return false
+	}
+	for _, a := range All() {
+		if !shares(a) && !exempt[a.Name] {
+			t.Errorf("analyzer %s does not use inspect.Analyzer", a.Name)
+		}
+	}
+}
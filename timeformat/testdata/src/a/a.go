@@ -0,0 +1,27 @@
+package a
+
+import "time"
+
+const iso = "2006-01-02"
+
+func layouts(s string, t time.Time) {
+	time.Parse(iso, s)
+	time.Parse(time.RFC3339, s)
+	time.Parse("YYYY-MM-DD", s)  // want `layout YYYY-MM-DD uses YYYY, MM, DD, which are copied verbatim; layouts are based on the reference time, like 2006-01-02`
+	t.Format("dd.MM.yyyy HH:mm") // want `layout dd.MM.yyyy HH:mm uses dd, MM, yyyy, HH, mm, which are copied verbatim; layouts are based on the reference time, like 02.01.2006 15:04`
+	time.Parse("2019-01-31", s)  // want `layout 2019-01-31 is not based on the reference time Mon Jan 2 15:04:05 MST 2006`
+	t.Format("%Y-%m-%d")         // want `layout %Y-%m-%d uses strftime directives`
+	t.AppendFormat(nil, "Mon Jan _2 15:04")
+	time.ParseInLocation("date", s, time.UTC) // want `layout date is not based on the reference time`
+	t.Format(s)
+	time.Parse("20060102150405", s)
+	time.Parse("20060102", s)
+	time.Parse("2006-01-02T15:04:05.0000Z07:00", s)
+	t.Format("2006-01-02 15:04:05,9999")
+}
+
+func local(t time.Time) time.Time {
+	time.LoadLocation("Europe/Berlin")
+	_ = time.Local   // want `use of time.Local; keep times in UTC and convert them only for display`
+	return t.Local() // want `conversion to local time; keep times in UTC and convert them only for display`
+}
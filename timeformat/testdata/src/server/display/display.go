@@ -0,0 +1,7 @@
+package display
+
+import "time"
+
+func Show(t time.Time) string {
+	return t.Local().Format(time.Kitchen)
+}
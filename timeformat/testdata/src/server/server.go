@@ -0,0 +1,14 @@
+package server
+
+import (
+	"net/http"
+	"time"
+)
+
+func handle(w http.ResponseWriter, r *http.Request) {
+	loc, _ := time.LoadLocation("America/New_York") // want `hard-coded time zone America/New_York in a package serving requests; use UTC or a zone from configuration or the request`
+	utc, _ := time.LoadLocation("UTC")
+	name := r.FormValue("tz")
+	user, _ := time.LoadLocation(name)
+	w.Write([]byte(time.Now().In(loc).String() + utc.String() + user.String()))
+}
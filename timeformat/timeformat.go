@@ -0,0 +1,226 @@
+// Copyright 2019 Axel Wagner
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package timeformat defines an Analyzer that checks the use of time
+// layouts and zones.
+package timeformat
+
+import (
+	"go/ast"
+	"go/constant"
+	"go/types"
+	"regexp"
+	"strings"
+
+	"github.com/Merovius/go-tools/internal/analysisutil"
+	"github.com/Merovius/go-tools/internal/nodefilter"
+	"golang.org/x/tools/go/analysis"
+)
+
+const Doc = `check the use of time layouts and zones
+
+The analyzer reports
+  - layouts passed to time.Parse and Time.Format which are not based on the
+    reference time Mon Jan 2 15:04:05 MST 2006, like concrete dates or
+    strftime directives,
+  - layouts using tokens of other languages, like YYYY or DD, which Go
+    copies verbatim,
+  - time.LoadLocation with a hard-coded zone in packages serving requests,
+    which should store and process times in UTC, and
+  - conversions to local time with Time.Local and uses of time.Local, where
+    times should be kept in UTC. They are not reported in main packages, in
+    tests and in the packages named by the -local flag.`
+
+var Analyzer = &analysis.Analyzer{
+	Name: "timeformat",
+	Doc:  Doc,
+	Run:  run,
+	Requires: []*analysis.Analyzer{
+		nodefilter.Analyzer,
+	},
+}
+
+var local string
+
+func init() {
+	Analyzer.Flags.StringVar(&local, "local", "", "comma-separated import paths of the packages allowed to use local time; paths ending in /... include subpackages")
+}
+
+var (
+	calls     = nodefilter.New(false, new(ast.CallExpr))
+	selectors = nodefilter.New(false, new(ast.SelectorExpr))
+)
+
+// layoutArgs maps functions to the index of their layout argument.
+var layoutArgs = map[string]int{
+	"time.Parse":               0,
+	"time.ParseInLocation":     0,
+	"(time.Time).Format":       0,
+	"(time.Time).AppendFormat": 1,
+}
+
+func run(pass *analysis.Pass) (interface{}, error) {
+	if !analysisutil.Imports(pass.Pkg, "time") {
+		return nil, nil
+	}
+	nodes := pass.ResultOf[nodefilter.Analyzer].(*nodefilter.Result)
+	server := analysisutil.Imports(pass.Pkg, "net/http") || analysisutil.Imports(pass.Pkg, "google.golang.org/grpc")
+	allowLocal := pass.Pkg.Name() == "main" || local != "" && analysisutil.MatchPackages(pass.Pkg.Path(), local)
+	for _, n := range nodes.Nodes(calls) {
+		call := n.Node.(*ast.CallExpr)
+		name := analysisutil.CalleeName(pass.TypesInfo, call)
+		if i, ok := layoutArgs[name]; ok && i < len(call.Args) {
+			checkLayout(pass, call.Args[i])
+			continue
+		}
+		switch name {
+		case "time.LoadLocation":
+			if server && len(call.Args) == 1 && !analysisutil.IsTestFile(pass, call.Pos()) {
+				checkLocation(pass, call)
+			}
+		case "(time.Time).Local":
+			if !allowLocal && !analysisutil.IsTestFile(pass, call.Pos()) {
+				pass.Report(analysis.Diagnostic{
+					Pos:      call.Pos(),
+					End:      call.End(),
+					Category: "local",
+					Message:  "conversion to local time; keep times in UTC and convert them only for display",
+				})
+			}
+		}
+	}
+	if allowLocal {
+		return nil, nil
+	}
+	for _, n := range nodes.Nodes(selectors) {
+		sel := n.Node.(*ast.SelectorExpr)
+		obj := pass.TypesInfo.Uses[sel.Sel]
+		if _, ok := obj.(*types.Var); !ok || obj.Pkg().Path() != "time" || obj.Name() != "Local" {
+			continue
+		}
+		if analysisutil.IsTestFile(pass, sel.Pos()) {
+			continue
+		}
+		pass.Report(analysis.Diagnostic{
+			Pos:      sel.Pos(),
+			End:      sel.End(),
+			Category: "local",
+			Message:  "use of time.Local; keep times in UTC and convert them only for display",
+		})
+	}
+	return nil, nil
+}
+
+// reference are the elements of layouts, as documented by package time.
+var reference = []string{
+	"2006", "06", "01", "1", "Jan", "January", "02", "2", "_2", "__2", "002",
+	"15", "03", "3", "04", "4", "05", "5", "Mon", "Monday", "PM", "pm", "MST",
+	"Z07", "-07", ".000", ",000", ".999", ",999",
+}
+
+var (
+	// tokens matches date and time tokens of other languages, like
+	// YYYY-MM-DD.
+	tokens = regexp.MustCompile(`\b(YYYY|yyyy|YY|yy|MM|DD|dd|HH|hh|mm|SS|ss)\b`)
+	// strftime matches strftime directives, like %Y-%m-%d.
+	strftime = regexp.MustCompile(`%[a-zA-Z]`)
+	// digits matches runs of digits.
+	digits = regexp.MustCompile(`\d+`)
+)
+
+// replacements maps tokens to the corresponding elements of layouts.
+var replacements = map[string]string{
+	"YYYY": "2006",
+	"yyyy": "2006",
+	"YY":   "06",
+	"yy":   "06",
+	"MM":   "01",
+	"DD":   "02",
+	"dd":   "02",
+	"HH":   "15",
+	"hh":   "03",
+	"mm":   "04",
+	"SS":   "05",
+	"ss":   "05",
+}
+
+func checkLayout(pass *analysis.Pass, arg ast.Expr) {
+	tv, ok := pass.TypesInfo.Types[arg]
+	if !ok || tv.Value == nil || tv.Value.Kind() != constant.String {
+		return
+	}
+	layout := constant.StringVal(tv.Value)
+	if layout == "" {
+		return
+	}
+	if ms := tokens.FindAllString(layout, -1); len(ms) > 0 {
+		fixed := tokens.ReplaceAllStringFunc(layout, func(m string) string { return replacements[m] })
+		pass.Report(analysis.Diagnostic{
+			Pos:      arg.Pos(),
+			End:      arg.End(),
+			Category: "tokens",
+			Message:  "layout " + layout + " uses " + strings.Join(ms, ", ") + ", which are copied verbatim; layouts are based on the reference time, like " + fixed,
+		})
+		return
+	}
+	switch {
+	case strftime.MatchString(layout):
+		pass.Reportf(arg.Pos(), "layout %s uses strftime directives; layouts are based on the reference time Mon Jan 2 15:04:05 MST 2006", layout)
+	case hasOtherYear(layout) || !hasReference(layout):
+		pass.Reportf(arg.Pos(), "layout %s is not based on the reference time Mon Jan 2 15:04:05 MST 2006", layout)
+	}
+}
+
+// hasOtherYear returns whether layout contains a year other than 2006, that
+// is, a run of four digits which is neither 2006 nor fractional seconds.
+// Longer runs are adjacent elements, like 20060102.
+func hasOtherYear(layout string) bool {
+	for _, m := range digits.FindAllStringIndex(layout, -1) {
+		run := layout[m[0]:m[1]]
+		if len(run) != 4 || run == "2006" {
+			continue
+		}
+		if m[0] > 0 && (layout[m[0]-1] == '.' || layout[m[0]-1] == ',') && (strings.Trim(run, "0") == "" || strings.Trim(run, "9") == "") {
+			continue
+		}
+		return true
+	}
+	return false
+}
+
+func hasReference(layout string) bool {
+	for _, r := range reference {
+		if strings.Contains(layout, r) {
+			return true
+		}
+	}
+	return false
+}
+
+func checkLocation(pass *analysis.Pass, call *ast.CallExpr) {
+	tv, ok := pass.TypesInfo.Types[call.Args[0]]
+	if !ok || tv.Value == nil || tv.Value.Kind() != constant.String {
+		return
+	}
+	switch name := constant.StringVal(tv.Value); name {
+	case "", "UTC":
+	default:
+		pass.Report(analysis.Diagnostic{
+			Pos:      call.Pos(),
+			End:      call.End(),
+			Category: "location",
+			Message:  "hard-coded time zone " + name + " in a package serving requests; use UTC or a zone from configuration or the request",
+		})
+	}
+}
@@ -0,0 +1,27 @@
+package a
+
+// TODO: make this faster. // want `TODO without an owner or issue reference; write it as TODO\(owner\)`
+func f() {}
+
+// TODO(gopher): make this faster.
+func g() {}
+
+// FIXME handle errors, see #123.
+func h() {}
+
+// FIXME: see PROJ-42.
+func i() {}
+
+// TODO see https://example.com/issue.
+func j() {}
+
+/* FIXME: this is broken. // want `FIXME without an owner or issue reference` */
+func k() {}
+
+func l() {
+	x := 1 // TODO // want `TODO without an owner`
+	_ = x
+}
+
+// TODOs are not reported, nor are words like MASTODON.
+func m() {}
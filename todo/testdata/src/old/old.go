@@ -0,0 +1,9 @@
+package old
+
+// TODO(gopher): this is old. // want `TODO is 100 days old \(last changed by gopher on \d{4}-\d{2}-\d{2}\); resolve it or turn it into an issue`
+
+// FIXME: old and unowned. // want `FIXME without an owner` `FIXME is 100 days old`
+
+// TODO(gopher): this is new.
+
+// TODO(gopher): this is uncommitted.
@@ -0,0 +1,122 @@
+// Copyright 2019 Axel Wagner
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package todo defines an Analyzer that checks TODO and FIXME comments.
+package todo
+
+import (
+	"go/ast"
+	"go/token"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Merovius/go-tools/internal/blame"
+	"github.com/Merovius/go-tools/internal/nodefilter"
+	"golang.org/x/tools/go/analysis"
+)
+
+const Doc = `check TODO and FIXME comments
+
+TODOs nobody is responsible for tend to stay forever. The analyzer reports
+TODO and FIXME comments without an owner or a reference to an issue, as
+matched by the regular expression given by the -pattern flag. By default,
+that is an owner like TODO(name), an issue like #123 or PROJ-123 or a URL.
+
+If the -max-age flag is set, the analyzer also reports TODO and FIXME
+comments last changed more than the given number of days ago, according to
+git blame. Lines which are not committed yet are not reported.`
+
+var Analyzer = &analysis.Analyzer{
+	Name: "todo",
+	Doc:  Doc,
+	Run:  run,
+	Requires: []*analysis.Analyzer{
+		nodefilter.Analyzer,
+	},
+}
+
+var (
+	pattern = `^\(\S+\)|#\d+|\b[A-Z][A-Z0-9]+-\d+\b|https?://`
+	maxAge  int
+)
+
+func init() {
+	Analyzer.Flags.StringVar(&pattern, "pattern", pattern, "regular expression matching owners and issue references in the text following TODO or FIXME")
+	Analyzer.Flags.IntVar(&maxAge, "max-age", 0, "report TODOs last changed more than `days` ago (0 to disable)")
+}
+
+var files = nodefilter.New(false, new(ast.File))
+
+// blameLine returns the blame information of a line. Tests replace it.
+var blameLine = blame.New().Line
+
+var keyword = regexp.MustCompile(`\b(TODO|FIXME)\b:?`)
+
+func run(pass *analysis.Pass) (interface{}, error) {
+	owned, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	nodes := pass.ResultOf[nodefilter.Analyzer].(*nodefilter.Result)
+	for _, n := range nodes.Nodes(files) {
+		for _, cg := range n.Node.(*ast.File).Comments {
+			for _, c := range cg.List {
+				checkComment(pass, c, owned)
+			}
+		}
+	}
+	return nil, nil
+}
+
+func checkComment(pass *analysis.Pass, c *ast.Comment, owned *regexp.Regexp) {
+	text := c.Text
+	for off := 0; off < len(text); {
+		line := text[off:]
+		if i := strings.IndexByte(line, '\n'); i >= 0 {
+			line = line[:i]
+		}
+		if m := keyword.FindStringSubmatchIndex(line); m != nil {
+			pos := c.Slash + token.Pos(off+m[0])
+			kw := line[m[2]:m[3]]
+			if !owned.MatchString(line[m[1]:]) {
+				pass.Reportf(pos, "%s without an owner or issue reference; write it as %s(owner) or reference an issue", kw, kw)
+			}
+			checkAge(pass, pos, kw)
+		}
+		off += len(line) + 1
+	}
+}
+
+func checkAge(pass *analysis.Pass, pos token.Pos, kw string) {
+	if maxAge <= 0 {
+		return
+	}
+	posn := pass.Fset.Position(pos)
+	l, err := blameLine(posn.Filename, posn.Line)
+	if err != nil || !l.Committed() {
+		// Files outside of a repository or not committed yet are new.
+		return
+	}
+	days := int(time.Since(l.Time).Hours() / 24)
+	if days <= maxAge {
+		return
+	}
+	pass.Report(analysis.Diagnostic{
+		Pos:      pos,
+		Category: "age",
+		Message:  kw + " is " + strconv.Itoa(days) + " days old (last changed by " + l.Author + " on " + l.Time.Format("2006-01-02") + "); resolve it or turn it into an issue",
+	})
+}
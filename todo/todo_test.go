@@ -0,0 +1,46 @@
+// Copyright 2019 Axel Wagner
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package todo
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Merovius/go-tools/internal/analysistestx"
+	"github.com/Merovius/go-tools/internal/blame"
+)
+
+func TestTodo(t *testing.T) {
+	defer func(f func(string, int) (blame.Line, error)) { blameLine = f }(blameLine)
+	blameLine = func(file string, line int) (blame.Line, error) {
+		if !strings.HasSuffix(file, "old.go") {
+			return blame.Line{}, nil
+		}
+		// Lines 3 and 5 are old, line 7 is new, everything else is
+		// uncommitted.
+		switch line {
+		case 3, 5:
+			return blame.Line{Commit: "c0ffee", Author: "gopher", Time: time.Now().AddDate(0, 0, -100)}, nil
+		case 7:
+			return blame.Line{Commit: "c0ffee", Author: "gopher", Time: time.Now().AddDate(0, 0, -10)}, nil
+		}
+		return blame.Line{Commit: "0000000"}, nil
+	}
+	analysistestx.RunMatrix(t, analysistestx.TestData(), Analyzer,
+		analysistestx.Case{Name: "default", Patterns: []string{"a"}},
+		analysistestx.Case{Name: "max-age", Flags: map[string]string{"max-age": "30"}, Patterns: []string{"old"}},
+	)
+}
@@ -0,0 +1,10 @@
+package b
+
+import "fmt"
+
+func stringer(s fmt.Stringer) {
+	// Listed in -closed.
+	switch s.(type) {
+	case nil:
+	}
+}
@@ -0,0 +1,30 @@
+package dep
+
+// A Shape is a geometric shape.
+//
+// Closed: the implementations are Circle and Rect.
+type Shape interface{ Area() float64 } // want Shape:"closed: the implementations are Circle and Rect."
+
+type Circle struct{}
+
+func (Circle) Area() float64 { return 0 }
+
+type Rect struct{}
+
+func (Rect) Area() float64 { return 0 }
+
+// A Value can get more implementations.
+type Value interface{ value() }
+
+type Int int
+
+func (Int) value() {}
+
+func Describe(v Value) string {
+	// Same package.
+	switch v.(type) {
+	case Int:
+		return "int"
+	}
+	return ""
+}
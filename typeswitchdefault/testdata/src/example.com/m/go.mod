@@ -0,0 +1,3 @@
+module example.com/m
+
+require example.com/dep v1.0.0
@@ -0,0 +1,7 @@
+package inner
+
+type Node interface{ node() }
+
+type Leaf struct{}
+
+func (Leaf) node() {}
@@ -0,0 +1,48 @@
+package m
+
+import (
+	"example.com/dep"
+	"example.com/m/inner"
+	"fmt"
+)
+
+func value(v dep.Value) {
+	switch v.(type) { // want `type switch on example.com/dep.Value, which is declared in another module, has no default case; values of types implementing it in later versions are silently ignored`
+	case dep.Int:
+	}
+
+	switch x := v.(type) { // want `type switch on example.com/dep.Value`
+	case dep.Int:
+		fmt.Println(x)
+	}
+
+	switch (v).(type) {
+	case dep.Int:
+	default:
+	}
+}
+
+func stringer(s fmt.Stringer) {
+	switch s.(type) { // want `type switch on fmt.Stringer`
+	case nil:
+	}
+}
+
+func others(s dep.Shape, n inner.Node, e error, i interface{}) {
+	// Closed interface.
+	switch s.(type) {
+	case dep.Circle:
+	case dep.Rect:
+	}
+	// Same module.
+	switch n.(type) {
+	case inner.Leaf:
+	}
+	// Unnamed and predeclared interfaces.
+	switch e.(type) {
+	case fmt.Formatter:
+	}
+	switch i.(type) {
+	case int:
+	}
+}
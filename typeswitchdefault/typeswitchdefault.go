@@ -0,0 +1,202 @@
+// Copyright 2019 Axel Wagner
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package typeswitchdefault defines an Analyzer that checks for type
+// switches on interfaces of other modules without a default case.
+package typeswitchdefault
+
+import (
+	"go/ast"
+	"go/types"
+	"path/filepath"
+	"strings"
+
+	"github.com/Merovius/go-tools/internal/analysisutil"
+	"github.com/Merovius/go-tools/internal/gomod"
+	"github.com/Merovius/go-tools/internal/nodefilter"
+	"golang.org/x/tools/go/analysis"
+)
+
+const Doc = `check for type switches on interfaces of other modules without a default case
+
+Another module can add implementations of its interfaces in any release.
+A type switch on such an interface without a default case silently ignores
+values of the new types, which often isn't intended. The analyzer reports
+such switches. An empty default case documents that other types are
+ignored deliberately.
+
+Interfaces whose implementations are fixed can be marked by a paragraph of
+their documentation starting with "Closed:", like
+
+	// A Shape is a geometric shape.
+	//
+	// Closed: the implementations are Circle and Rect.
+	type Shape interface { ... }
+
+Type switches on them are not reported. Interfaces which can't be marked,
+for example of the standard library, can be listed in the -closed flag.`
+
+var Analyzer = &analysis.Analyzer{
+	Name: "typeswitchdefault",
+	Doc:  Doc,
+	Run:  run,
+	Requires: []*analysis.Analyzer{
+		nodefilter.Analyzer,
+	},
+	FactTypes: []analysis.Fact{new(closed)},
+}
+
+var closedList string
+
+func init() {
+	Analyzer.Flags.StringVar(&closedList, "closed", "", "comma-separated interfaces with fixed implementations, like go/ast.Node")
+}
+
+var (
+	specs    = nodefilter.New(false, new(ast.GenDecl))
+	switches = nodefilter.New(false, new(ast.TypeSwitchStmt))
+)
+
+// closed is an object fact of interfaces marked as closed.
+type closed struct {
+	Note string // the text of the marker
+}
+
+func (*closed) AFact() {}
+
+func (c *closed) String() string { return "closed: " + c.Note }
+
+func run(pass *analysis.Pass) (interface{}, error) {
+	nodes := pass.ResultOf[nodefilter.Analyzer].(*nodefilter.Result)
+	for _, n := range nodes.Nodes(specs) {
+		exportClosed(pass, n.Node.(*ast.GenDecl))
+	}
+	if len(pass.Files) == 0 {
+		return nil, nil
+	}
+	mod, err := gomod.Find(filepath.Dir(pass.Fset.File(pass.Files[0].Pos()).Name()))
+	if err != nil {
+		return nil, err
+	}
+	allow := split(closedList)
+
+	for _, n := range nodes.Nodes(switches) {
+		sw := n.Node.(*ast.TypeSwitchStmt)
+		if hasDefault(sw) {
+			continue
+		}
+		named, ok := pass.TypesInfo.TypeOf(switchedExpr(sw)).(*types.Named)
+		if !ok || !types.IsInterface(named) {
+			continue
+		}
+		obj := named.Obj()
+		if obj.Pkg() == nil || obj.Pkg() == pass.Pkg || sameModule(mod, pass.Pkg.Path(), obj.Pkg().Path()) {
+			continue
+		}
+		name := analysisutil.TypeName(named)
+		if allow[name] || pass.ImportObjectFact(obj, new(closed)) {
+			continue
+		}
+		pass.Reportf(sw.Pos(), "type switch on %s, which is declared in another module, has no default case; values of types implementing it in later versions are silently ignored", name)
+	}
+	return nil, nil
+}
+
+// exportClosed exports facts about the interfaces declared by decl, which
+// are marked as closed.
+func exportClosed(pass *analysis.Pass, decl *ast.GenDecl) {
+	for _, spec := range decl.Specs {
+		ts, ok := spec.(*ast.TypeSpec)
+		if !ok {
+			continue
+		}
+		if _, ok := ts.Type.(*ast.InterfaceType); !ok {
+			continue
+		}
+		doc := ts.Doc
+		if doc == nil && len(decl.Specs) == 1 {
+			doc = decl.Doc
+		}
+		if note, ok := marker(doc); ok {
+			pass.ExportObjectFact(pass.TypesInfo.Defs[ts.Name], &closed{note})
+		}
+	}
+}
+
+// marker returns the text of the "Closed:" paragraph of doc, if any.
+func marker(doc *ast.CommentGroup) (string, bool) {
+	if doc == nil {
+		return "", false
+	}
+	for _, para := range strings.Split(doc.Text(), "\n\n") {
+		if strings.HasPrefix(para, "Closed:") {
+			return strings.Join(strings.Fields(strings.TrimPrefix(para, "Closed:")), " "), true
+		}
+	}
+	return "", false
+}
+
+func hasDefault(sw *ast.TypeSwitchStmt) bool {
+	for _, c := range sw.Body.List {
+		if c.(*ast.CaseClause).List == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// switchedExpr returns the expression x of a type switch on x.(type).
+func switchedExpr(sw *ast.TypeSwitchStmt) ast.Expr {
+	var e ast.Expr
+	switch s := sw.Assign.(type) {
+	case *ast.AssignStmt:
+		e = s.Rhs[0]
+	case *ast.ExprStmt:
+		e = s.X
+	}
+	return analysisutil.Unparen(e).(*ast.TypeAssertExpr).X
+}
+
+// sameModule returns whether the packages self and path are in the same
+// module, which is mod, if there is one.
+func sameModule(mod *gomod.File, self, path string) bool {
+	if mod != nil {
+		return mod.ModuleOf(path) == mod.Module
+	}
+	return repoRoot(self) == repoRoot(path)
+}
+
+// repoRoot approximates the root of the repository of a package path
+// without go.mod, like github.com/user/repo.
+func repoRoot(path string) string {
+	elems := strings.Split(path, "/")
+	if !strings.Contains(elems[0], ".") {
+		// The standard library, or a local package.
+		return elems[0]
+	}
+	if len(elems) > 3 {
+		elems = elems[:3]
+	}
+	return strings.Join(elems, "/")
+}
+
+func split(s string) map[string]bool {
+	m := make(map[string]bool)
+	for _, w := range strings.Split(s, ",") {
+		if w = strings.TrimSpace(w); w != "" {
+			m[w] = true
+		}
+	}
+	return m
+}
@@ -0,0 +1,70 @@
+package a
+
+import (
+	"bufio"
+	"flag"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+)
+
+func get(url string) ([]byte, error) {
+	resp, err := http.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	return ioutil.ReadAll(resp.Body) // want `ioutil.ReadAll of data from the network without a size limit`
+}
+
+func getLimited(url string) ([]byte, error) {
+	resp, err := http.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	return ioutil.ReadAll(io.LimitReader(resp.Body, 1<<20))
+}
+
+func conn(c net.Conn) ([]byte, error) {
+	return ioutil.ReadAll(bufio.NewReader(c)) // want `ioutil.ReadAll of data from the network`
+}
+
+func file(r io.Reader) ([]byte, error) {
+	return ioutil.ReadAll(r)
+}
+
+func args() ([]byte, error) {
+	return ioutil.ReadFile(os.Args[1]) // want `ioutil.ReadFile of a path users control`
+}
+
+func flagPath() ([]byte, error) {
+	name := flag.String("config", "config.json", "configuration file")
+	flag.Parse()
+	return ioutil.ReadFile(*name) // want `ioutil.ReadFile of a path users control`
+}
+
+func handler(w http.ResponseWriter, r *http.Request) {
+	p := filepath.Join("/srv", r.URL.Query().Get("file"))
+	b, err := ioutil.ReadFile(p) // want `ioutil.ReadFile of a path users control`
+	if err != nil {
+		return
+	}
+	w.Write(b)
+}
+
+func statted() ([]byte, error) {
+	name := os.Args[1]
+	fi, err := os.Stat(name)
+	if err != nil || fi.Size() > 1<<20 {
+		return nil, err
+	}
+	return ioutil.ReadFile(name)
+}
+
+func fixed() ([]byte, error) {
+	return ioutil.ReadFile("/etc/hosts")
+}
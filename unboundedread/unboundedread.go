@@ -0,0 +1,110 @@
+// Copyright 2019 Axel Wagner
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package unboundedread defines an Analyzer that checks for untrusted data
+// read into memory without a size limit.
+package unboundedread
+
+import (
+	"github.com/Merovius/go-tools/internal/nodefilter"
+	"github.com/Merovius/go-tools/internal/taint"
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/passes/buildssa"
+	"golang.org/x/tools/go/ssa"
+)
+
+const Doc = `check for untrusted data read into memory without a size limit
+
+The analyzer reports
+  - io.ReadAll and ioutil.ReadAll of data from the network, like the body of
+    an HTTP response or a connection. The peer decides how much is read, so
+    it can exhaust the memory of the program. Wrap the reader with
+    io.LimitReader, and
+  - os.ReadFile and ioutil.ReadFile of paths users control, like command
+    line arguments or request parameters (category "path"). The file can be
+    arbitrarily large, or a device like /dev/zero. Calls are not reported if
+    the path is passed to os.Stat or os.Lstat in the same function, which
+    usually checks the size.
+
+Untrusted data is tracked with the sources of package internal/taint, within
+a function. Request bodies in HTTP handlers are checked by httphandler.`
+
+var Analyzer = &analysis.Analyzer{
+	Name: "unboundedread",
+	Doc:  Doc,
+	Run:  run,
+	Requires: []*analysis.Analyzer{
+		buildssa.Analyzer,
+		nodefilter.Analyzer,
+	},
+}
+
+var (
+	readAll = map[string]string{
+		"io.ReadAll":        "io.ReadAll",
+		"io/ioutil.ReadAll": "ioutil.ReadAll",
+	}
+	readFile = map[string]string{
+		"os.ReadFile":        "os.ReadFile",
+		"io/ioutil.ReadFile": "ioutil.ReadFile",
+	}
+	stats = map[string]bool{
+		"os.Stat":  true,
+		"os.Lstat": true,
+	}
+)
+
+func run(pass *analysis.Pass) (interface{}, error) {
+	ssainfo := pass.ResultOf[buildssa.Analyzer].(*buildssa.SSA)
+	for _, fn := range ssainfo.SrcFuncs {
+		var (
+			calls   []*ssa.Call
+			statted = make(map[ssa.Value]bool)
+		)
+		for _, b := range fn.Blocks {
+			for _, instr := range b.Instrs {
+				call, ok := instr.(*ssa.Call)
+				if !ok || len(call.Call.Args) == 0 {
+					continue
+				}
+				name := taint.Callee(&call.Call)
+				switch {
+				case stats[name]:
+					statted[call.Call.Args[0]] = true
+				case readAll[name] != "" || readFile[name] != "":
+					calls = append(calls, call)
+				}
+			}
+		}
+		if len(calls) == 0 {
+			continue
+		}
+		tainted := taint.Default.Analyze(fn)
+		for _, call := range calls {
+			name := taint.Callee(&call.Call)
+			arg := call.Call.Args[0]
+			if short := readAll[name]; short != "" && tainted[arg]&taint.Network != 0 {
+				pass.Reportf(call.Pos(), "%s of data from the network without a size limit; the peer can exhaust the memory of the program, so wrap the reader with io.LimitReader", short)
+			}
+			if short := readFile[name]; short != "" && tainted[arg]&taint.UserInput != 0 && !statted[arg] {
+				pass.Report(analysis.Diagnostic{
+					Pos:      call.Pos(),
+					Category: "path",
+					Message:  short + " of a path users control reads the whole file, whatever its size; check its size first or read it through io.LimitReader",
+				})
+			}
+		}
+	}
+	return nil, nil
+}
@@ -0,0 +1,93 @@
+package a
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const maxSize = 1 << 30
+
+func unzip(r *zip.Reader, dest string) error {
+	for _, f := range r.File {
+		path := filepath.Join(dest, f.Name) // want `archive entry name is joined to a path without checking for ".." elements, so a malicious archive can write outside of the destination`
+		rc, err := f.Open()
+		if err != nil {
+			return err
+		}
+		out, err := os.Create(path)
+		if err != nil {
+			return err
+		}
+		io.Copy(out, rc) // want `archive entry is read without a size limit, so a malicious archive can exhaust memory or disk space; use io.CopyN or io.LimitReader`
+		out.Close()
+		rc.Close()
+	}
+	return nil
+}
+
+func unzipChecked(r *zip.Reader, dest string) error {
+	for _, f := range r.File {
+		name := f.Name
+		path := filepath.Join(dest, name)
+		if !strings.HasPrefix(path, filepath.Clean(dest)+string(os.PathSeparator)) {
+			return fmt.Errorf("illegal file path: %s", path)
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return err
+		}
+		out, err := os.Create(path)
+		if err != nil {
+			return err
+		}
+		io.CopyN(out, rc, maxSize)
+		out.Close()
+		rc.Close()
+	}
+	return nil
+}
+
+func untar(r io.Reader, dest string) error {
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err != nil {
+			return err
+		}
+		name := hdr.Name
+		b, err := ioutil.ReadAll(tr) // want `archive entry is read without a size limit`
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dest, name) // want `archive entry name is joined to a path`
+		ioutil.WriteFile(target, b, 0644)
+		io.Copy(ioutil.Discard, io.LimitReader(tr, maxSize))
+	}
+}
+
+func untarChecked(r io.Reader, dest string) error {
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err != nil {
+			return err
+		}
+		if strings.Contains(hdr.Name, "..") {
+			continue
+		}
+		target := filepath.Join(dest, hdr.Name)
+		os.MkdirAll(target, 0755)
+	}
+}
+
+func notAnEntry(dest, name string) string {
+	var z zip.Writer
+	_ = z
+	return filepath.Join(dest, name)
+}
@@ -0,0 +1,218 @@
+// Copyright 2019 Axel Wagner
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package zipslip defines an Analyzer that checks the extraction of zip and
+// tar archives for path traversal and decompression bombs.
+package zipslip
+
+import (
+	"go/ast"
+	"go/constant"
+	"go/types"
+
+	"github.com/Merovius/go-tools/internal/analysisutil"
+	"github.com/Merovius/go-tools/internal/nodefilter"
+	"golang.org/x/tools/go/analysis"
+)
+
+const Doc = `check extraction of archives for path traversal and unbounded copies
+
+The names of entries in zip and tar archives can contain ".." elements or be
+absolute. Code joining them to a destination directory without checking
+writes outside of it when extracting a malicious archive ("zip slip"). The
+analyzer reports joins of entry names in functions which don't check for this
+with strings.HasPrefix, strings.Contains, filepath.Rel or filepath.IsLocal.
+
+Compressed entries can also decompress to much more data than their size in
+the archive. The analyzer reports io.Copy and ioutil.ReadAll of entries
+without a size limit; use io.CopyN or io.LimitReader.`
+
+var Analyzer = &analysis.Analyzer{
+	Name: "zipslip",
+	Doc:  Doc,
+	Run:  run,
+	Requires: []*analysis.Analyzer{
+		nodefilter.Analyzer,
+	},
+}
+
+var calls = nodefilter.New(true, new(ast.CallExpr))
+
+var joins = []string{
+	"path/filepath.Join",
+	"path.Join",
+}
+
+// checks lists functions which are used to make sure paths don't leave a
+// directory.
+var checks = []string{
+	"strings.HasPrefix",
+	"strings.Contains",
+	"path/filepath.Rel",
+	"path/filepath.IsLocal",
+	"path/filepath.IsAbs",
+}
+
+var copies = []string{
+	"io.Copy",
+	"io.CopyBuffer",
+	"io/ioutil.ReadAll",
+	"io.ReadAll",
+}
+
+func run(pass *analysis.Pass) (interface{}, error) {
+	if !analysisutil.Imports(pass.Pkg, "archive/zip") && !analysisutil.Imports(pass.Pkg, "archive/tar") {
+		return nil, nil
+	}
+	nodes := pass.ResultOf[nodefilter.Analyzer].(*nodefilter.Result)
+	for _, n := range nodes.Nodes(calls) {
+		call := n.Node.(*ast.CallExpr)
+		_, body := analysisutil.EnclosingFunc(n.Stack)
+		if body == nil {
+			continue
+		}
+		switch {
+		case analysisutil.IsCall(pass.TypesInfo, call, joins...):
+			checkJoin(pass, call, body)
+		case analysisutil.IsCall(pass.TypesInfo, call, copies...):
+			checkCopy(pass, call, body)
+		}
+	}
+	return nil, nil
+}
+
+func checkJoin(pass *analysis.Pass, call *ast.CallExpr, body *ast.BlockStmt) {
+	entry := false
+	for _, a := range call.Args {
+		entry = entry || isEntryName(pass.TypesInfo, origin(pass.TypesInfo, body, a))
+	}
+	if !entry || checksPaths(pass.TypesInfo, body) {
+		return
+	}
+	pass.Report(analysis.Diagnostic{
+		Pos:     call.Pos(),
+		End:     call.End(),
+		Message: "archive entry name is joined to a path without checking for \"..\" elements, so a malicious archive can write outside of the destination",
+	})
+}
+
+// isEntryName returns whether e is the name of an archive entry.
+func isEntryName(info *types.Info, e ast.Expr) bool {
+	sel, ok := analysisutil.Unparen(e).(*ast.SelectorExpr)
+	if !ok || sel.Sel.Name != "Name" {
+		return false
+	}
+	t := info.TypeOf(sel.X)
+	if t == nil {
+		return false
+	}
+	if p, ok := t.(*types.Pointer); ok {
+		t = p.Elem()
+	}
+	for _, name := range []string{"archive/zip.File", "archive/zip.FileHeader", "archive/tar.Header"} {
+		if analysisutil.IsType(t, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// checksPaths returns whether body calls one of the functions used to check
+// paths. strings.Contains only counts if it looks for "..".
+func checksPaths(info *types.Info, body *ast.BlockStmt) bool {
+	found := false
+	ast.Inspect(body, func(n ast.Node) bool {
+		call, ok := n.(*ast.CallExpr)
+		if !ok || !analysisutil.IsCall(info, call, checks...) {
+			return !found
+		}
+		if analysisutil.IsCall(info, call, "strings.Contains") {
+			if len(call.Args) != 2 {
+				return true
+			}
+			tv := info.Types[call.Args[1]]
+			if tv.Value == nil || tv.Value.Kind() != constant.String || constant.StringVal(tv.Value) != ".." {
+				return true
+			}
+		}
+		found = true
+		return false
+	})
+	return found
+}
+
+// origin returns the expression assigned to e in body, if e is a local
+// variable defined there, or e itself.
+func origin(info *types.Info, body *ast.BlockStmt, e ast.Expr) ast.Expr {
+	obj := analysisutil.ObjectOf(info, e)
+	if obj == nil {
+		return e
+	}
+	var found ast.Expr
+	ast.Inspect(body, func(n ast.Node) bool {
+		as, ok := n.(*ast.AssignStmt)
+		if !ok || found != nil {
+			return found == nil
+		}
+		for i, l := range as.Lhs {
+			id, ok := l.(*ast.Ident)
+			if !ok || info.Defs[id] != obj {
+				continue
+			}
+			if len(as.Rhs) == len(as.Lhs) {
+				found = as.Rhs[i]
+			} else if len(as.Rhs) == 1 {
+				found = as.Rhs[0]
+			}
+		}
+		return found == nil
+	})
+	if found == nil {
+		return e
+	}
+	return found
+}
+
+func checkCopy(pass *analysis.Pass, call *ast.CallExpr, body *ast.BlockStmt) {
+	if len(call.Args) == 0 {
+		return
+	}
+	src := call.Args[0]
+	if !analysisutil.IsCall(pass.TypesInfo, call, "io/ioutil.ReadAll", "io.ReadAll") {
+		if len(call.Args) < 2 {
+			return
+		}
+		src = call.Args[1]
+	}
+	if !isEntry(pass.TypesInfo, src) && !isEntry(pass.TypesInfo, origin(pass.TypesInfo, body, src)) {
+		return
+	}
+	pass.Report(analysis.Diagnostic{
+		Pos:      call.Pos(),
+		End:      call.End(),
+		Category: "copy",
+		Message:  "archive entry is read without a size limit, so a malicious archive can exhaust memory or disk space; use io.CopyN or io.LimitReader",
+	})
+}
+
+// isEntry returns whether e is the content of an archive entry, that is a
+// *tar.Reader or the result of (*zip.File).Open.
+func isEntry(info *types.Info, e ast.Expr) bool {
+	e = analysisutil.Unparen(e)
+	if t := info.TypeOf(e); t != nil && analysisutil.IsPointerTo(t, "archive/tar.Reader") {
+		return true
+	}
+	call, ok := e.(*ast.CallExpr)
+	return ok && analysisutil.IsCall(info, call, "(*archive/zip.File).Open")
+}